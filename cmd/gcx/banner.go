@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/urfave/cli/v3"
+)
+
+// remoteTagTimeout bounds the git ls-remote of --confirm-version.
+const remoteTagTimeout = 5 * time.Second
+
+var confirmVersionFlag = &cli.BoolFlag{
+	Name:    "confirm-version",
+	Usage:   "Ask for confirmation (or --yes) when the version is not the latest tag of the git remote",
+	Sources: cli.EnvVars("GCX_CONFIRM_VERSION"),
+}
+
+var yesFlag = &cli.BoolFlag{
+	Name:  "yes",
+	Usage: "Continue without asking when --confirm-version finds a different remote tag",
+}
+
+// printBanner writes what the command is about to release to stderr: the
+// version, commit, branch, config and out_dir. With --confirm-version a
+// version other than the latest remote tag must be confirmed.
+func printBanner(ctx context.Context, c *cli.Command, cfg *config.Config) error {
+	info := git.Resolve(ctx)
+	outDir, err := cfg.OutputDir(info.Tag)
+	if err != nil {
+		return err
+	}
+	writeBanner(os.Stderr, c.FullName(), info, cfg.Channel, c.String("config"), outDir)
+	if !c.Bool("confirm-version") {
+		return nil
+	}
+
+	interactive := false
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+	}
+	return confirmVersion(ctx, info.Tag, c.Bool("yes"), interactive, os.Stdin, os.Stderr)
+}
+
+// writeBanner writes the banner of command, e.g. "gcx build", to w.
+func writeBanner(w io.Writer, command string, info git.Info, channel, configPath, outDir string) {
+	commit := info.Commit
+	if info.Branch != "" {
+		commit += " on " + info.Branch
+	} else {
+		commit += " (detached HEAD)"
+	}
+	if info.Dirty {
+		commit += ", uncommitted changes"
+	}
+	_, _ = fmt.Fprintf(w, "%s %s (channel %s)\n  commit:  %s\n  config:  %s\n  out_dir: %s\n",
+		command, info.Tag, channel, commit, configPath, outDir)
+}
+
+// confirmVersion compares version with the latest tag of the git remote
+// and, when they differ or the remote cannot be checked, asks on out to
+// continue. yes answers for the user; without a terminal the command fails.
+func confirmVersion(ctx context.Context, version string, yes, interactive bool, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteTagTimeout)
+	defer cancel()
+
+	latest, err := git.LatestRemoteTag(ctx)
+	var reason string
+	switch {
+	case err != nil:
+		reason = fmt.Sprintf("the latest remote tag is unknown (%v)", err)
+	case latest == version:
+		return nil
+	case latest == "":
+		reason = "the remote has no tags"
+	default:
+		reason = "the latest remote tag is " + latest
+	}
+
+	switch {
+	case yes:
+		log.Printf("Continuing with %s although %s (--yes)", version, reason)
+		return nil
+	case !interactive:
+		return fmt.Errorf("version %s: %s; fetch the tags or pass --yes", version, reason)
+	}
+	_, _ = fmt.Fprintf(out, "Version %s: %s. Continue? [y/N] ", version, reason)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("version %s not confirmed", version)
+}
@@ -1,24 +1,43 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/joho/godotenv"
+	"github.com/sxwebdev/gcx/internal/annotate"
+	"github.com/sxwebdev/gcx/internal/announce"
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/artifacts"
+	"github.com/sxwebdev/gcx/internal/attest"
 	"github.com/sxwebdev/gcx/internal/build"
+	"github.com/sxwebdev/gcx/internal/channel"
 	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/configtypes"
 	"github.com/sxwebdev/gcx/internal/deploy"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/gc"
 	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/policy"
 	"github.com/sxwebdev/gcx/internal/publish"
+	"github.com/sxwebdev/gcx/internal/release"
+	"github.com/sxwebdev/gcx/internal/sign"
+	"github.com/sxwebdev/gcx/internal/split"
+	"github.com/sxwebdev/gcx/internal/tmpl"
 	"github.com/urfave/cli/v3"
-	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -32,13 +51,6 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer cancel()
 
-	// Load .env file; warn if file exists but has errors
-	if err := godotenv.Load(); err != nil {
-		if !errors.Is(err, os.ErrNotExist) && !os.IsNotExist(err) {
-			log.Printf("Warning: failed to load .env file: %v", err)
-		}
-	}
-
 	configFlag := &cli.StringFlag{
 		Name:    "config",
 		Aliases: []string{"c"},
@@ -46,71 +58,698 @@ func main() {
 		Value:   "gcx.yaml",
 	}
 
+	jsonFlag := &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print output as JSON",
+	}
+
+	hooksTagsFlag := &cli.StringSliceFlag{
+		Name:  "hooks-tags",
+		Usage: "Run only the tagged hooks with one of these tags, e.g. fast,codegen; untagged hooks always run",
+	}
+	skipHooksTagsFlag := &cli.StringSliceFlag{
+		Name:  "skip-hooks-tags",
+		Usage: "Skip the tagged hooks with one of these tags, e.g. slow",
+	}
+
+	versionFlag := &cli.StringFlag{
+		Name:  "version",
+		Usage: "Version used instead of the git tag in ldflags, out_dir, archive and publish templates (also GCX_VERSION)",
+	}
+	commitFlag := &cli.StringFlag{
+		Name:  "commit",
+		Usage: "Commit hash used instead of the HEAD commit (also GCX_COMMIT)",
+	}
+
 	app := &cli.Command{
 		Name:  "gcx",
 		Usage: "A tool for cross-compiling and publishing Go binaries",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "chdir",
+				Aliases: []string{"C"},
+				Usage:   "Change to this directory before doing anything else",
+			},
+			&cli.BoolFlag{
+				Name:    "cwd-relative-paths",
+				Usage:   "Resolve relative paths in the config against the working directory instead of the config file's directory",
+				Sources: cli.EnvVars("GCX_CWD_RELATIVE_PATHS"),
+			},
+			&cli.StringFlag{
+				Name:    "channel",
+				Usage:   "Release channel exposed as {{.Channel}}: stable, beta or nightly (default: detected from the git tag)",
+				Sources: cli.EnvVars("GCX_CHANNEL"),
+			},
+			&cli.StringFlag{
+				Name:   "fail-at",
+				Usage:  "Developer flag: fail with an injected error at stage[:target], e.g. build:3, publish:s3-prod or deploy:cmd2",
+				Hidden: true,
+			},
+		}, metricsFlags...),
+		Before: setup,
+		After:  flushMetrics,
 		Commands: []*cli.Command{
 			{
-				Name:  "build",
-				Usage: "Compiles binaries",
-				Flags: []cli.Flag{configFlag},
+				Name:   "build",
+				Usage:  "Compiles binaries",
+				Before: overrideVersion,
+				Flags: []cli.Flag{
+					configFlag,
+					versionFlag,
+					commitFlag,
+					&cli.BoolFlag{
+						Name:  "force-all",
+						Usage: "Build everything, ignoring only_if_changed",
+					},
+					&cli.BoolFlag{
+						Name:  "list-targets",
+						Usage: "Print the resolved build targets without building",
+					},
+					&cli.BoolFlag{
+						Name:  "single-target",
+						Usage: "Build only for the host platform (or GOOS/GOARCH from the environment)",
+					},
+					&cli.BoolFlag{
+						Name:  "archive-stdout",
+						Usage: "Write a tar.gz of the single built target to stdout instead of creating archives",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the hooks and go build commands, the archives to create and the files to remove without running or writing anything",
+					},
+					&cli.BoolFlag{
+						Name:  "print-commands",
+						Usage: "Print the go build argv, effective environment (secrets hashed), go version and input fingerprint of every target as JSON without building",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-before-hooks",
+						Usage: "Do not run the before hooks",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-archives",
+						Usage: "Do not create archives; artifacts.json lists the raw binaries",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-after-hooks",
+						Usage: "Do not run the after hooks",
+					},
+					hooksTagsFlag,
+					skipHooksTagsFlag,
+					&cli.BoolFlag{
+						Name:  "skip-embed-checks",
+						Usage: "Do not check that embedded assets are newer than their sources",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-sign",
+						Usage: "Do not sign; checksums files are still written",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-upx",
+						Usage: "Do not compress binaries with upx",
+					},
+					&cli.StringFlag{
+						Name:  "annotations",
+						Usage: "Group go build output per target and annotate its errors: github (default when GITHUB_ACTIONS=true) or none",
+					},
+					&cli.IntFlag{
+						Name:  "parallelism",
+						Usage: "Run at most N go build processes at once (default: parallelism, else concurrency)",
+					},
+					jsonFlag,
+					confirmVersionFlag,
+					yesFlag,
+				},
 				Action: func(ctx context.Context, c *cli.Command) error {
-					cfg, err := config.Load(c.String("config"))
+					cfg, err := loadConfig(ctx, c)
 					if err != nil {
 						return err
 					}
-					if _, err := build.Run(ctx, cfg); err != nil {
+					if c.Bool("list-targets") {
+						return printTargets(ctx, cfg, c.Bool("json"))
+					}
+					if c.Int("parallelism") < 0 {
+						return fmt.Errorf("--parallelism must not be negative")
+					}
+					if c.Bool("dry-run") && c.Bool("archive-stdout") {
+						return fmt.Errorf("--dry-run and --archive-stdout cannot be combined")
+					}
+					if c.Bool("print-commands") && (c.Bool("dry-run") || c.Bool("archive-stdout")) {
+						return fmt.Errorf("--print-commands cannot be combined with --dry-run or --archive-stdout")
+					}
+					if err := printBanner(ctx, c, cfg); err != nil {
+						return err
+					}
+					opts := build.Options{
+						ForceAll:        c.Bool("force-all"),
+						SingleTarget:    c.Bool("single-target"),
+						SkipBeforeHooks: c.Bool("skip-before-hooks"),
+						SkipArchives:    c.Bool("skip-archives"),
+						SkipAfterHooks:  c.Bool("skip-after-hooks"),
+						HooksTags:       c.StringSlice("hooks-tags"),
+						SkipHooksTags:   c.StringSlice("skip-hooks-tags"),
+						SkipEmbedChecks: c.Bool("skip-embed-checks"),
+						SkipSign:        c.Bool("skip-sign"),
+						SkipUPX:         c.Bool("skip-upx"),
+						Annotations:     c.String("annotations"),
+						Parallelism:     int(c.Int("parallelism")),
+					}
+					if !c.IsSet("annotations") {
+						opts.Annotations = annotate.Detect()
+					}
+					if c.Bool("dry-run") {
+						opts.DryRun = os.Stdout
+					}
+					if c.Bool("print-commands") {
+						opts.Commands = os.Stdout
+					}
+					if c.Bool("archive-stdout") {
+						if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+							return fmt.Errorf("refusing to write an archive to a terminal; pipe or redirect stdout")
+						}
+						opts.ArchiveTo = os.Stdout
+					}
+					if _, err := build.Run(ctx, cfg, opts); err != nil {
 						return err
 					}
 					return nil
 				},
 			},
 			{
-				Name:  "publish",
-				Usage: "Publishes artifacts based on the configuration",
+				Name:  "targets",
+				Usage: "Prints the resolved build targets (alias for build --list-targets)",
+				Flags: []cli.Flag{configFlag, jsonFlag},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					cfg, err := loadConfig(ctx, c)
+					if err != nil {
+						return err
+					}
+					return printTargets(ctx, cfg, c.Bool("json"))
+				},
+			},
+			{
+				Name:   "publish",
+				Usage:  "Publishes artifacts based on the configuration",
+				Before: overrideVersion,
 				Flags: []cli.Flag{
 					configFlag,
+					versionFlag,
+					commitFlag,
 					&cli.StringFlag{
 						Name:    "name",
 						Aliases: []string{"n"},
 						Usage:   "Name of the publish configuration to execute",
 					},
+					&cli.StringFlag{
+						Name:  "timeout",
+						Usage: "Abort the whole publish stage after this duration, e.g. 10m (default: publish.timeout)",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Skip artifacts already uploaded to a destination according to the publish state file",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-announce",
+						Usage: "Do not run the announce announcers after publishing",
+					},
+					confirmVersionFlag,
+					yesFlag,
 				},
 				Action: func(ctx context.Context, c *cli.Command) error {
-					cfg, err := config.Load(c.String("config"))
+					cfg, err := loadConfig(ctx, c)
 					if err != nil {
 						return err
 					}
-					return publish.Run(ctx, cfg, c.String("name"))
+					if err := printBanner(ctx, c, cfg); err != nil {
+						return err
+					}
+					if c.IsSet("timeout") {
+						timeout, err := configtypes.ParseDuration(c.String("timeout"))
+						if err != nil {
+							return fmt.Errorf("invalid --timeout: %w", err)
+						}
+						cfg.Publish.Timeout = timeout
+					}
+					if err := publish.Run(ctx, cfg, c.String("name"), publish.Options{Resume: c.Bool("resume")}); err != nil {
+						return err
+					}
+					switch {
+					case len(cfg.Announce.Announcers) == 0 || c.Bool("skip-announce"):
+						return nil
+					case c.String("name") != "":
+						log.Printf("Not announcing: --name published a single destination (run gcx release announce once all are published)")
+						return nil
+					}
+					return announce.Run(ctx, cfg)
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "Verifies the signed checksums file and the artifacts it lists",
+				Flags: []cli.Flag{
+					configFlag,
+					&cli.StringFlag{
+						Name:     "allowed-signers",
+						Usage:    "ssh-keygen allowed_signers file with the trusted signing keys",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "identity",
+						Usage: "Principal the signature must belong to (default: signs[0].identity or gcx)",
+					},
+					&cli.StringFlag{
+						Name:  "dir",
+						Usage: "Directory with checksums.txt and its signature (default: out_dir of the current tag)",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					dir, identity := c.String("dir"), c.String("identity")
+					if dir == "" || identity == "" {
+						cfg, err := loadConfig(ctx, c)
+						if err != nil {
+							return err
+						}
+						if dir == "" {
+							if dir, err = cfg.OutputDir(git.GetTag(ctx)); err != nil {
+								return err
+							}
+						}
+						if identity == "" && len(cfg.Signs) > 0 {
+							identity = cfg.Signs[0].Identity
+						}
+					}
+					if identity == "" {
+						identity = sign.DefaultIdentity
+					}
+					return sign.VerifyDir(ctx, dir, c.String("allowed-signers"), identity)
 				},
 			},
 			{
-				Name:  "deploy",
-				Usage: "Deploys artifacts based on the configuration",
+				Name:   "deploy",
+				Usage:  "Deploys artifacts based on the configuration",
+				Before: overrideVersion,
 				Flags: []cli.Flag{
 					configFlag,
+					versionFlag,
+					commitFlag,
 					&cli.StringFlag{
 						Name:    "name",
 						Aliases: []string{"n"},
 						Usage:   "Name of the deploy configuration to execute",
 					},
+					&cli.BoolFlag{
+						Name:  "force-all",
+						Usage: "Deploy every target, ignoring only_if_changed",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Upload every file and run every command, ignoring skip_unchanged",
+					},
+					&cli.StringFlag{
+						Name:  "policy-override",
+						Usage: "Deploy despite deploy_policy violations; the reason is logged and sent with alerts",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip approval gates that set approval.allow_override and continue without asking on --confirm-version",
+					},
+					confirmVersionFlag,
 				},
 				Action: func(ctx context.Context, c *cli.Command) error {
-					cfg, err := config.Load(c.String("config"))
+					override := strings.TrimSpace(c.String("policy-override"))
+					if c.IsSet("policy-override") && override == "" {
+						return fmt.Errorf("--policy-override requires a reason")
+					}
+					cfg, err := loadConfig(ctx, c)
 					if err != nil {
 						return err
 					}
-					return deploy.Run(ctx, cfg, c.String("name"))
+					if err := printBanner(ctx, c, cfg); err != nil {
+						return err
+					}
+					return deploy.Run(ctx, cfg, c.String("name"), deploy.Options{
+						ForceAll:       c.Bool("force-all"),
+						PolicyOverride: override,
+						Yes:            c.Bool("yes"),
+						Force:          c.Bool("force"),
+					})
+				},
+				Commands: []*cli.Command{
+					{
+						Name:  "check",
+						Usage: "Checks that deploy targets are reachable without running any commands",
+						Flags: []cli.Flag{
+							configFlag,
+							&cli.StringFlag{
+								Name:    "name",
+								Aliases: []string{"n"},
+								Usage:   "Name of the deploy configuration to check",
+							},
+							&cli.BoolFlag{
+								Name:  "noop",
+								Usage: "Also run a no-op command (true) on each target",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							results, checkErr := deploy.Check(ctx, cfg, c.String("name"), c.Bool("noop"))
+							if err := deploy.WriteCheckTable(os.Stdout, results); err != nil {
+								return err
+							}
+							return checkErr
+						},
+					},
 				},
 			},
 			{
-				Name:  "release",
-				Usage: "Release related commands",
+				Name:  "artifacts",
+				Usage: "Artifact related commands",
+				Commands: []*cli.Command{
+					{
+						Name:  "pull",
+						Usage: "Downloads the published artifacts of a version from a blob",
+						Flags: []cli.Flag{
+							configFlag,
+							&cli.StringFlag{
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "Name of the publish configuration to download from",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "version",
+								Usage:    "Version to download (e.g. v1.4.2)",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "Output directory (default: artifacts/<version>)",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							blob, err := artifacts.FindBlob(cfg, c.String("name"))
+							if err != nil {
+								return err
+							}
+							outDir := c.String("output")
+							if outDir == "" {
+								outDir = filepath.Join("artifacts", c.String("version"))
+							}
+							// Without --channel, the version being pulled decides the channel
+							ch := c.String("channel")
+							if ch == "" {
+								ch = channel.FromTag(c.String("version"), true)
+							}
+							m, err := artifacts.Pull(ctx, blob, ch, c.String("version"), outDir)
+							if err != nil {
+								return err
+							}
+							log.Printf("Downloaded %d artifact(s) to %s", len(m.Artifacts), outDir)
+							return nil
+						},
+					},
+					{
+						Name:  "versions",
+						Usage: "Lists the versions published to a blob",
+						Flags: []cli.Flag{
+							configFlag,
+							&cli.StringFlag{
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "Name of the publish configuration to list",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Print versions as JSON",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							blob, err := artifacts.FindBlob(cfg, c.String("name"))
+							if err != nil {
+								return err
+							}
+							versions, err := artifacts.Versions(ctx, blob, cfg.Channel)
+							if err != nil {
+								return err
+							}
+							if c.Bool("json") {
+								return artifacts.WriteVersionsJSON(os.Stdout, versions)
+							}
+							return artifacts.WriteVersionsTable(os.Stdout, versions)
+						},
+					},
+					{
+						Name:      "inspect",
+						Usage:     "Lists the entries of an archive with their sizes, modes and SHA-256 without extracting it",
+						ArgsUsage: "<archive>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Print the entries as JSON",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("usage: gcx artifacts inspect <archive>")
+							}
+							entries, err := archive.Contents(c.Args().First())
+							if err != nil {
+								return err
+							}
+							if c.Bool("json") {
+								return artifacts.WriteContentsJSON(os.Stdout, entries)
+							}
+							return artifacts.WriteContentsTable(os.Stdout, entries)
+						},
+					},
+					{
+						Name:      "diff",
+						Usage:     "Compares two archives entry by entry; fails when they differ",
+						ArgsUsage: "<archive> <archive>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Print the differing entries as JSON",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if c.Args().Len() != 2 {
+								return fmt.Errorf("usage: gcx artifacts diff <archive> <archive>")
+							}
+							old, err := archive.Contents(c.Args().Get(0))
+							if err != nil {
+								return err
+							}
+							cur, err := archive.Contents(c.Args().Get(1))
+							if err != nil {
+								return err
+							}
+							diffs := artifacts.DiffContents(old, cur)
+							if c.Bool("json") {
+								err = artifacts.WriteDiffJSON(os.Stdout, diffs)
+							} else {
+								err = artifacts.WriteDiffTable(os.Stdout, c.Args().Get(0), c.Args().Get(1), diffs)
+							}
+							if err != nil {
+								return err
+							}
+							if len(diffs) > 0 {
+								return fmt.Errorf("%d archive entries differ", len(diffs))
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "join",
+						Usage:     "Reassembles an artifact published in parts (oversize: split) from its .parts.json manifest and the parts next to it",
+						ArgsUsage: "<name.parts.json>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "Path of the joined file (default: its original name next to the manifest)",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("usage: gcx artifacts join <name.parts.json>")
+							}
+							path, err := split.Join(c.Args().First(), c.String("output"))
+							if err != nil {
+								return err
+							}
+							log.Printf("Joined and verified %s", path)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "gc",
+				Usage: "Prunes old versioned build outputs and trims gcx caches",
+				Flags: []cli.Flag{
+					configFlag,
+					&cli.IntFlag{
+						Name:  "keep-last",
+						Usage: "Number of most recent output directories to keep (default: gc.keep_last or 3)",
+					},
+					&cli.StringFlag{
+						Name:  "max-age",
+						Usage: "Prune output directories older than this, e.g. 30d (default: gc.max_age)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print what would be removed",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					cfg, err := loadConfig(ctx, c)
+					if err != nil {
+						return err
+					}
+					opts := gc.OptionsFromConfig(cfg.GC)
+					if c.IsSet("keep-last") {
+						opts.KeepLast = int(c.Int("keep-last"))
+					}
+					if c.IsSet("max-age") {
+						maxAge, err := configtypes.ParseDuration(c.String("max-age"))
+						if err != nil {
+							return fmt.Errorf("invalid --max-age: %w", err)
+						}
+						opts.MaxAge = maxAge.Std()
+					}
+					opts.DryRun = c.Bool("dry-run")
+
+					removals, err := gc.Run(cfg, opts)
+					if opts.DryRun {
+						for _, r := range removals {
+							fmt.Printf("Would remove %s (%s): %s\n", r.Path, helpers.FormatBytes(r.Size), r.Reason)
+						}
+						fmt.Printf("Would free %s\n", helpers.FormatBytes(gc.Freed(removals)))
+					} else {
+						fmt.Printf("Freed %s\n", helpers.FormatBytes(gc.Freed(removals)))
+					}
+					return err
+				},
+			},
+			{
+				Name:   "release",
+				Usage:  "Builds, publishes and deploys the current tag; release related commands",
+				Before: overrideVersion,
+				Flags: []cli.Flag{
+					configFlag,
+					versionFlag,
+					commitFlag,
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Skip the stages a failed release of the same version and commit completed and resume the failed one",
+					},
+					&cli.IntFlag{
+						Name:  "retry-stage",
+						Usage: "Retry a failed stage up to N times, resuming partial publishes",
+					},
+					&cli.StringFlag{
+						Name:  "timeout",
+						Usage: "Abort the release after this duration, shared among the stages by release.stage_weights, e.g. 45m (default: release.timeout)",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-before-hooks",
+						Usage: "Do not run the before hooks of the build stage",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-archives",
+						Usage: "Do not create archives in the build stage; artifacts.json lists the raw binaries",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-after-hooks",
+						Usage: "Do not run the after hooks of the build stage",
+					},
+					hooksTagsFlag,
+					skipHooksTagsFlag,
+					&cli.BoolFlag{
+						Name:  "force-all",
+						Usage: "Build and deploy everything, ignoring only_if_changed",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Deploy: upload every file and run every command, ignoring skip_unchanged",
+					},
+					&cli.StringFlag{
+						Name:  "policy-override",
+						Usage: "Deploy despite deploy_policy violations; the reason is logged and sent with alerts",
+					},
+					confirmVersionFlag,
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip deploy approval gates that set approval.allow_override and continue without asking on --confirm-version",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					override := strings.TrimSpace(c.String("policy-override"))
+					if c.IsSet("policy-override") && override == "" {
+						return fmt.Errorf("--policy-override requires a reason")
+					}
+					cfg, err := loadConfig(ctx, c)
+					if err != nil {
+						return err
+					}
+					if err := printBanner(ctx, c, cfg); err != nil {
+						return err
+					}
+					if c.Int("retry-stage") < 0 {
+						return fmt.Errorf("--retry-stage must not be negative")
+					}
+					timeout := cfg.ReleaseSettings.Timeout
+					if c.IsSet("timeout") {
+						if timeout, err = configtypes.ParseDuration(c.String("timeout")); err != nil {
+							return fmt.Errorf("invalid --timeout: %w", err)
+						}
+					}
+					tag := git.GetTag(ctx)
+					outDir, err := cfg.OutputDir(tag)
+					if err != nil {
+						return err
+					}
+					return release.RunStages(ctx, outDir, cfg.FormatVersion(tag), git.GetCommitHash(ctx), releaseStages(cfg, build.Options{
+						ForceAll:        c.Bool("force-all"),
+						SkipBeforeHooks: c.Bool("skip-before-hooks"),
+						SkipArchives:    c.Bool("skip-archives"),
+						SkipAfterHooks:  c.Bool("skip-after-hooks"),
+						Annotations:     annotate.Detect(),
+						HooksTags:       c.StringSlice("hooks-tags"),
+						SkipHooksTags:   c.StringSlice("skip-hooks-tags"),
+					}, deploy.Options{
+						ForceAll:       c.Bool("force-all"),
+						PolicyOverride: override,
+						Yes:            c.Bool("yes"),
+						Force:          c.Bool("force"),
+					}), release.StageOptions{
+						Resume:     c.Bool("resume"),
+						Retries:    int(c.Int("retry-stage")),
+						RetryDelay: stageRetryDelay,
+						Timeout:    timeout.Std(),
+						Weights:    cfg.ReleaseSettings.StageWeights,
+						Summary:    os.Stdout,
+					})
+				},
 				Commands: []*cli.Command{
 					{
 						Name:  "changelog",
 						Usage: "Generate a changelog between the current and previous git tags",
 						Flags: []cli.Flag{
+							configFlag,
 							&cli.BoolFlag{
 								Name:    "stable",
 								Aliases: []string{"s"},
@@ -125,7 +764,16 @@ func main() {
 							} else {
 								previousTag = git.GetPreviousTag(ctx)
 							}
-							changelog, err := git.GetChangelog(ctx, previousTag, currentTag)
+							// The config is optional; it only provides the changelog settings
+							var opts git.ChangelogOptions
+							if _, err := os.Stat(c.String("config")); err == nil {
+								cfg, err := loadConfig(ctx, c)
+								if err != nil {
+									return err
+								}
+								opts = cfg.Changelog.Options()
+							}
+							changelog, err := git.GetChangelog(ctx, previousTag, currentTag, opts)
 							if err != nil {
 								return fmt.Errorf("generate changelog: %w", err)
 							}
@@ -133,6 +781,128 @@ func main() {
 							return nil
 						},
 					},
+					{
+						Name:  "announce",
+						Usage: "Runs the announce announcers for the published release of the current tag",
+						Flags: []cli.Flag{
+							configFlag,
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							if len(cfg.Announce.Announcers) == 0 {
+								return fmt.Errorf("no announce.announcers configured")
+							}
+							return announce.Run(ctx, cfg)
+						},
+					},
+					{
+						Name:  "diff",
+						Usage: "Compares the current build with the previous release before publishing",
+						Flags: []cli.Flag{
+							configFlag,
+							&cli.StringFlag{
+								Name:  "against",
+								Usage: "Previous version to compare with (default: previous git tag)",
+							},
+							&cli.StringFlag{
+								Name:    "name",
+								Aliases: []string{"n"},
+								Usage:   "Publish configuration to fetch the previous artifacts.json from (default: the only blob)",
+							},
+							&cli.StringFlag{
+								Name:  "from",
+								Usage: "Local artifacts.json (or its directory) of the previous release instead of a blob",
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Print the diff as JSON",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							opts := release.Options{Against: c.String("against"), From: c.String("from")}
+							if opts.From == "" {
+								switch {
+								case c.String("name") != "":
+									blob, err := artifacts.FindBlob(cfg, c.String("name"))
+									if err != nil {
+										return err
+									}
+									opts.Blob = &blob
+								case len(cfg.Blobs) == 1:
+									opts.Blob = &cfg.Blobs[0]
+								default:
+									return fmt.Errorf("--name or --from is required")
+								}
+							}
+							d, err := release.Run(ctx, cfg, opts)
+							if err != nil {
+								return err
+							}
+							if c.Bool("json") {
+								return release.WriteJSON(os.Stdout, d)
+							}
+							return release.WriteTable(os.Stdout, d)
+						},
+					},
+				},
+			},
+			{
+				Name:  "attest",
+				Usage: "Artifact attestation commands",
+				Commands: []*cli.Command{
+					{
+						Name:  "subjects",
+						Usage: "Print the attestation subjects (name and sha256) of the built artifacts",
+						Flags: []cli.Flag{
+							configFlag,
+							&cli.StringFlag{
+								Name:  "format",
+								Value: attest.FormatGitHub,
+								Usage: "Output format: github (in-toto subject JSON) or checksums (for subject-checksums of actions/attest-build-provenance)",
+							},
+							&cli.BoolFlag{
+								Name:  "push",
+								Usage: "Sign SLSA build provenance for the subjects with Sigstore and upload it to the GitHub attestations API (GitHub Actions only)",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							var env attest.Env
+							if c.Bool("push") {
+								// Fail before printing anything outside of GitHub Actions
+								if env, err = attest.LoadEnv(envvars.Get); err != nil {
+									return err
+								}
+							}
+
+							subjects, err := attest.Load(ctx, cfg)
+							if err != nil {
+								return err
+							}
+							if err := attest.WriteSubjects(os.Stdout, subjects, c.String("format")); err != nil {
+								return err
+							}
+							if !c.Bool("push") {
+								return nil
+							}
+							url, err := attest.NewPusher(env).Push(ctx, subjects)
+							if err != nil {
+								return fmt.Errorf("push attestation: %w", err)
+							}
+							log.Printf("Attestation for %d subject(s) created: %s", len(subjects), url)
+							return nil
+						},
+					},
 				},
 			},
 			{
@@ -150,6 +920,61 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "env",
+				Usage: "Lists the environment variables gcx reads, whether each is set (never its value) and the configured features that use it",
+				Flags: []cli.Flag{configFlag, jsonFlag},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					cfg, err := loadConfig(ctx, c)
+					if errors.Is(err, fs.ErrNotExist) {
+						// Without a config only the registry is listed
+						cfg, err = nil, nil
+					}
+					if err != nil {
+						return err
+					}
+					return printEnv(cfg, c.Bool("json"))
+				},
+			},
+			{
+				Name:  "context",
+				Usage: "Prints the template context of the current tag as templates see it, with secrets redacted",
+				Flags: []cli.Flag{
+					configFlag,
+					&cli.StringFlag{
+						Name:  "format",
+						Value: build.ContextYAML,
+						Usage: "Output format: yaml or json",
+					},
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "Build target of the archive fields (.Os, .Arch, .Ext...), e.g. linux/arm64 (default: the first target)",
+					},
+					&cli.StringFlag{
+						Name:  "render",
+						Usage: "Print this template rendered with the context instead, e.g. '{{.Binary}}_{{.Version}}_{{.Os}}'",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					cfg, err := loadConfig(ctx, c)
+					if err != nil {
+						return err
+					}
+					data, err := build.NewTemplateContext(ctx, cfg, c.String("target"))
+					if err != nil {
+						return err
+					}
+					if !c.IsSet("render") {
+						return build.WriteContext(os.Stdout, data, c.String("format"))
+					}
+					out, err := tmpl.Process("render", c.String("render"), data)
+					if err != nil {
+						return err
+					}
+					fmt.Println(out)
+					return nil
+				},
+			},
 			{
 				Name:  "version",
 				Usage: "Displays the current version",
@@ -162,6 +987,34 @@ func main() {
 				Name:  "config",
 				Usage: "Configuration related commands",
 				Commands: []*cli.Command{
+					{
+						Name:  "validate",
+						Usage: "Validate the configuration and check for artifact name collisions",
+						Flags: []cli.Flag{configFlag},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							cfg, err := loadConfig(ctx, c)
+							if err != nil {
+								return err
+							}
+							if err := cfg.Validate(); err != nil {
+								return fmt.Errorf("invalid config: %w", err)
+							}
+							if err := build.CheckNames(cfg, git.GetTag(ctx)); err != nil {
+								return err
+							}
+							if err := build.CheckTargets(ctx, cfg, false); err != nil {
+								return err
+							}
+							if err := cfg.ResolveIncludes(ctx); err != nil {
+								return fmt.Errorf("invalid config: %w", err)
+							}
+							if err := policy.Check(cfg.DeployPolicy, cfg.Deploys...); err != nil {
+								return err
+							}
+							fmt.Printf("%s is valid\n", c.String("config"))
+							return nil
+						},
+					},
 					{
 						Name:  "init",
 						Usage: "Initialize a new gcx.yaml configuration file",
@@ -221,17 +1074,12 @@ func main() {
 								},
 							}
 
-							buf := bytes.NewBuffer(nil)
-							encoder := yaml.NewEncoder(buf)
-							defer func() { _ = encoder.Close() }()
-
-							encoder.SetIndent(2)
-
-							if err := encoder.Encode(cfg); err != nil {
-								return fmt.Errorf("marshal config: %w", err)
+							data, err := config.Marshal(cfg)
+							if err != nil {
+								return err
 							}
 
-							if err := os.WriteFile(configPath, buf.Bytes(), 0o644); err != nil {
+							if err := os.WriteFile(configPath, data, 0o644); err != nil {
 								return fmt.Errorf("write config file: %w", err)
 							}
 
@@ -239,6 +1087,36 @@ func main() {
 							return nil
 						},
 					},
+					{
+						Name:      "set",
+						Usage:     "Set a value in the configuration file, keeping comments and formatting",
+						ArgsUsage: "<path> <value>",
+						Flags:     []cli.Flag{configFlag},
+						Action: func(_ context.Context, c *cli.Command) error {
+							if c.Args().Len() != 2 {
+								return fmt.Errorf("usage: gcx config set <path> <value>, e.g. gcx config set 'builds[0].goos' '[linux, darwin]'")
+							}
+							configPath := c.String("config")
+							info, err := os.Stat(configPath)
+							if err != nil {
+								return fmt.Errorf("read config file: %w", err)
+							}
+							data, err := os.ReadFile(configPath)
+							if err != nil {
+								return fmt.Errorf("read config file: %w", err)
+							}
+
+							data, err = config.Set(data, c.Args().Get(0), c.Args().Get(1))
+							if err != nil {
+								return err
+							}
+
+							if err := os.WriteFile(configPath, data, info.Mode().Perm()); err != nil {
+								return fmt.Errorf("write config file: %w", err)
+							}
+							return nil
+						},
+					},
 				},
 			},
 		},
@@ -248,3 +1126,137 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// setup switches to the --chdir directory, loads .env from there and
+// applies the GCX_VERSION and GCX_COMMIT overrides.
+func setup(ctx context.Context, c *cli.Command) (context.Context, error) {
+	if dir := c.String("chdir"); dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return ctx, fmt.Errorf("change directory: %w", err)
+		}
+	}
+
+	if s := c.String("fail-at"); s != "" {
+		p, err := inject.Parse(s)
+		if err != nil {
+			return ctx, err
+		}
+		log.Printf("Warning: failure injection armed at %s", p)
+		inject.Set(&p)
+	}
+
+	// Load .env file; warn if file exists but has errors
+	if err := godotenv.Load(); err != nil {
+		if !errors.Is(err, os.ErrNotExist) && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to load .env file: %v", err)
+		}
+	}
+
+	// Read after .env, which may set them
+	if err := setOverride("version", envvars.Get("GCX_VERSION"), "GCX_VERSION"); err != nil {
+		return ctx, err
+	}
+	return ctx, setOverride("commit", envvars.Get("GCX_COMMIT"), "GCX_COMMIT")
+}
+
+// overrideVersion applies the --version and --commit flags of build,
+// publish, deploy and release, which win over GCX_VERSION and GCX_COMMIT.
+func overrideVersion(ctx context.Context, c *cli.Command) (context.Context, error) {
+	for _, name := range []string{"version", "commit"} {
+		if err := setOverride(name, c.String(name), "--"+name); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+// setOverride makes the git package return value as the version or commit
+// and logs where it came from, so the log shows why the git tag is unused.
+func setOverride(name, value, source string) error {
+	if value == "" {
+		return nil
+	}
+	if strings.ContainsFunc(value, unicode.IsSpace) {
+		return fmt.Errorf("%s: %q must not contain whitespace", source, value)
+	}
+	if name == "version" {
+		git.SetVersion(value)
+		log.Printf("Using version %s from %s instead of the git tag", value, source)
+		return nil
+	}
+	git.SetCommit(value)
+	log.Printf("Using commit %s from %s instead of HEAD", value, source)
+	return nil
+}
+
+// loadConfig loads the --config file and, unless --cwd-relative-paths is
+// set, resolves its relative paths against the config file's directory.
+// The release channel comes from --channel or the git tag.
+func loadConfig(ctx context.Context, c *cli.Command) (*config.Config, error) {
+	path := c.String("config")
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Bool("cwd-relative-paths") {
+		cfg.ResolvePaths(filepath.Dir(path))
+	}
+	if cfg.Channel, err = channel.Resolve(ctx, c.String("channel")); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// stageRetryDelay is the wait before gcx release --retry-stage retries a
+// failed stage.
+const stageRetryDelay = 10 * time.Second
+
+// releaseStages returns the stages of gcx release: build, publish (and
+// announce) and deploy. Stages without configuration are skipped.
+func releaseStages(cfg *config.Config, buildOpts build.Options, deployOpts deploy.Options) []release.Stage {
+	return []release.Stage{
+		{Name: "build", Run: func(ctx context.Context, _ bool) error {
+			_, err := build.Run(ctx, cfg, buildOpts)
+			return err
+		}},
+		{Name: "publish", Run: func(ctx context.Context, resume bool) error {
+			if len(cfg.Blobs) == 0 {
+				log.Printf("Skipping publish: no blobs configured")
+				return nil
+			}
+			if err := publish.Run(ctx, cfg, "", publish.Options{Resume: resume}); err != nil {
+				return err
+			}
+			if len(cfg.Announce.Announcers) == 0 {
+				return nil
+			}
+			return announce.Run(ctx, cfg)
+		}},
+		{Name: "deploy", Run: func(ctx context.Context, _ bool) error {
+			if len(cfg.Deploys) == 0 {
+				log.Printf("Skipping deploy: no deploys configured")
+				return nil
+			}
+			return deploy.Run(ctx, cfg, "", deployOpts)
+		}},
+	}
+}
+
+func printEnv(cfg *config.Config, asJSON bool) error {
+	statuses, err := envvars.Report(cfg)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return envvars.WriteJSON(os.Stdout, statuses)
+	}
+	return envvars.WriteTable(os.Stdout, statuses)
+}
+
+func printTargets(ctx context.Context, cfg *config.Config, asJSON bool) error {
+	targets := build.CheckedTargets(ctx, cfg)
+	if asJSON {
+		return build.WriteTargetsJSON(os.Stdout, targets)
+	}
+	return build.WriteTargetsTable(os.Stdout, targets)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/metrics"
+	"github.com/urfave/cli/v3"
+)
+
+var metricsFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "metrics-push-url",
+		Usage:   "Prometheus Pushgateway URL to push run metrics to",
+		Sources: cli.EnvVars("GCX_METRICS_PUSH_URL"),
+	},
+	&cli.StringFlag{
+		Name:    "metrics-file",
+		Usage:   "Write run metrics in Prometheus text format to this file",
+		Sources: cli.EnvVars("GCX_METRICS_FILE"),
+	},
+	&cli.StringFlag{
+		Name:  "metrics-job",
+		Usage: "Job label for pushed and written metrics",
+		Value: "gcx",
+	},
+}
+
+// flushMetrics writes and/or pushes the metrics recorded during the run.
+// Failures are logged but never fail the command itself.
+func flushMetrics(ctx context.Context, c *cli.Command) error {
+	pushURL, file := c.String("metrics-push-url"), c.String("metrics-file")
+	if (pushURL == "" && file == "") || metrics.Default.Empty() {
+		return nil
+	}
+
+	labels := metrics.Labels{"job": c.String("metrics-job"), "version": git.GetTag(ctx)}
+
+	if file != "" {
+		if err := metrics.Default.WriteFile(file, labels); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	if pushURL != "" {
+		// The run context may already be cancelled; pushing uses its own timeout
+		grouping := metrics.Labels{"version": labels["version"]}
+		if err := metrics.Default.Push(context.WithoutCancel(ctx), pushURL, labels["job"], grouping); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package annotate turns compiler output into CI annotations, so build
+// errors show up on the offending lines instead of deep in the job log.
+// Parse is independent of the CI system; Formats holds one writer per
+// system.
+package annotate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/envvars"
+)
+
+// Annotation is a message located in a source file.
+type Annotation struct {
+	// File is the path as printed by the compiler; empty for messages
+	// that belong to no file, e.g. a failed build without compiler output.
+	File    string
+	Line    int
+	Col     int
+	Message string
+}
+
+// Format writes the captured output of one step, titled e.g. "go build
+// app linux/amd64", followed by its annotations.
+type Format func(w io.Writer, title string, output []byte, annotations []Annotation) error
+
+// Formats maps the --annotations values to their writers.
+var Formats = map[string]Format{
+	"github": WriteGitHub,
+}
+
+// Names returns the supported formats, sorted.
+func Names() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the writer of format; "" and "none" return nil.
+func Lookup(format string) (Format, error) {
+	if format == "" || format == "none" {
+		return nil, nil
+	}
+	if f, ok := Formats[format]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("annotations %q: expected one of %s or none", format, strings.Join(Names(), ", "))
+}
+
+// Detect returns the format of the CI system gcx runs in, or "".
+func Detect() string {
+	if envvars.Get("GITHUB_ACTIONS") == "true" {
+		return "github"
+	}
+	return ""
+}
+
+// location matches "file:line:col: message" and "file:line: message".
+var location = regexp.MustCompile(`^(\S+?):(\d+)(?::(\d+))?: (.+)$`)
+
+// skipped are located lines that carry no message of their own.
+var skipped = []string{"too many errors"}
+
+// Parse extracts the located messages of go build (or go vet) output.
+// Indented lines that follow a message, such as "have (int)" and "want
+// (string)", are appended to it. Package headers ("# pkg") and other
+// lines are ignored.
+func Parse(output []byte) []Annotation {
+	var annotations []Annotation
+	sc := bufio.NewScanner(bytes.NewReader(output))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	continuation := false
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "\t") {
+			if continuation {
+				a := &annotations[len(annotations)-1]
+				a.Message += "\n" + strings.TrimSpace(line)
+			}
+			continue
+		}
+		continuation = false
+		m := location.FindStringSubmatch(line)
+		if m == nil || slices.Contains(skipped, m[4]) {
+			continue
+		}
+		a := Annotation{File: m[1], Message: m[4]}
+		a.Line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			a.Col, _ = strconv.Atoi(m[3])
+		}
+		annotations = append(annotations, a)
+		continuation = true
+	}
+	return annotations
+}
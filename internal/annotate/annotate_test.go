@@ -0,0 +1,84 @@
+package annotate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const goBuildOutput = `# github.com/acme/app/cmd/app
+cmd/app/main.go:5:2: undefined: foo
+cmd/app/main.go:9:9: cannot use x (variable of type int) as string value in return statement
+./util.go:3:8: "os" imported and not used
+pkg/x.go:12: missing return
+cmd/app/run.go:20:14: not enough arguments in call to run
+	have (int)
+	want (int, string)
+cmd/app/main.go:30:1: too many errors
+go: downloading example.com/mod v1.0.0
+`
+
+func TestParse(t *testing.T) {
+	got := Parse([]byte(goBuildOutput))
+	want := []Annotation{
+		{File: "cmd/app/main.go", Line: 5, Col: 2, Message: "undefined: foo"},
+		{File: "cmd/app/main.go", Line: 9, Col: 9, Message: "cannot use x (variable of type int) as string value in return statement"},
+		{File: "./util.go", Line: 3, Col: 8, Message: `"os" imported and not used`},
+		{File: "pkg/x.go", Line: 12, Message: "missing return"},
+		{File: "cmd/app/run.go", Line: 20, Col: 14, Message: "not enough arguments in call to run\nhave (int)\nwant (int, string)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := Parse([]byte("# pkg\nlink: duplicated definition of symbol main.main\n")); got != nil {
+		t.Errorf("Parse(unlocated) = %+v, want none", got)
+	}
+}
+
+func TestWriteGitHub(t *testing.T) {
+	var b strings.Builder
+	annotations := []Annotation{
+		{File: "cmd/app/main.go", Line: 5, Col: 2, Message: "undefined: foo"},
+		{File: "a,b.go", Line: 1, Message: "100% wrong\nreally"},
+		{Message: "go build app linux/amd64: exit status 1"},
+	}
+	output := []byte("# pkg\ncmd/app/main.go:5:2: undefined: foo")
+	if err := WriteGitHub(&b, "go build app linux/amd64", output, annotations); err != nil {
+		t.Fatal(err)
+	}
+	want := "::group::go build app linux/amd64\n" +
+		"# pkg\ncmd/app/main.go:5:2: undefined: foo\n" +
+		"::endgroup::\n" +
+		"::error file=cmd/app/main.go,line=5,col=2,title=go build app linux/amd64::undefined: foo\n" +
+		"::error file=a%2Cb.go,line=1,title=go build app linux/amd64::100%25 wrong%0Areally\n" +
+		"::error title=go build app linux/amd64::go build app linux/amd64: exit status 1\n"
+	if b.String() != want {
+		t.Errorf("WriteGitHub() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, format := range []string{"", "none"} {
+		if f, err := Lookup(format); f != nil || err != nil {
+			t.Errorf("Lookup(%q) = %v, %v; want nil, nil", format, f, err)
+		}
+	}
+	if f, err := Lookup("github"); f == nil || err != nil {
+		t.Errorf("Lookup(github) = %v, %v", f, err)
+	}
+	if _, err := Lookup("gitlab"); err == nil || !strings.Contains(err.Error(), "expected one of github or none") {
+		t.Errorf("Lookup(gitlab) error = %v", err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if got := Detect(); got != "github" {
+		t.Errorf("Detect() = %q, want github", got)
+	}
+	t.Setenv("GITHUB_ACTIONS", "")
+	if got := Detect(); got != "" {
+		t.Errorf("Detect() = %q, want none", got)
+	}
+}
@@ -0,0 +1,48 @@
+package annotate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteGitHub writes output in a collapsible ::group:: block, followed by
+// one ::error workflow command per annotation, which GitHub Actions shows
+// on the file and line.
+func WriteGitHub(w io.Writer, title string, output []byte, annotations []Annotation) error {
+	var b strings.Builder
+	b.WriteString("::group::" + escapeData(title) + "\n")
+	b.Write(output)
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	b.WriteString("::endgroup::\n")
+
+	for _, a := range annotations {
+		var props []string
+		if a.File != "" {
+			props = append(props, "file="+escapeProperty(a.File))
+			if a.Line > 0 {
+				props = append(props, "line="+strconv.Itoa(a.Line))
+			}
+			if a.Col > 0 {
+				props = append(props, "col="+strconv.Itoa(a.Col))
+			}
+		}
+		props = append(props, "title="+escapeProperty(title))
+		fmt.Fprintf(&b, "::error %s::%s\n", strings.Join(props, ","), escapeData(a.Message))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,195 @@
+// Package announce runs the announcers of a release once it is published.
+package announce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/metrics"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// Data is the announcement of a release: the template data of shoutrrr
+// messages and webhook headers, and the JSON body of webhooks and exec
+// announcers.
+type Data struct {
+	ProjectName string `json:"project_name"`
+	Version     string `json:"version"`
+	Channel     string `json:"channel"`
+	Commit      string `json:"commit,omitempty"`
+	Changelog   string `json:"changelog,omitempty"`
+	// Duration is the time from the start of the build to the
+	// announcement, e.g. "4m12s".
+	Duration  string     `json:"duration,omitempty"`
+	Artifacts []Artifact `json:"artifacts"`
+	// Env is the environment for templates; it is never sent.
+	Env map[string]string `json:"-"`
+}
+
+// Artifact is a published artifact of the release manifest.
+type Artifact struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Goos    string `json:"goos,omitempty"`
+	Goarch  string `json:"goarch,omitempty"`
+	Goarm   string `json:"goarm,omitempty"`
+	Variant string `json:"variant,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	// URL is the download link rendered from announce.download_url.
+	URL string `json:"url,omitempty"`
+}
+
+// urlData is the template data of announce.download_url.
+type urlData struct {
+	config.ObjectTemplateData
+	// Object is the remote object name from the blob's object_template.
+	Object string
+}
+
+// Run sends the announcement of the release of the current git tag, built
+// in out_dir, through every enabled announcer. All announcers are
+// attempted; the returned error joins their failures.
+func Run(ctx context.Context, cfg *config.Config) (err error) {
+	if len(cfg.Announce.Announcers) == 0 {
+		return nil
+	}
+	defer func(start time.Time) { metrics.ObserveStage("announce", start, err) }(time.Now())
+
+	tag := git.GetTag(ctx)
+	rel := cfg.Release(tag)
+	outDir, err := cfg.OutputDir(tag)
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		return fmt.Errorf("load release manifest (run gcx build first): %w", err)
+	}
+
+	data, err := newData(cfg, m, outDir, rel, time.Now())
+	if err != nil {
+		return err
+	}
+	data.Commit = git.GetCommitHash(ctx)
+	if data.Changelog, err = git.GetChangelog(ctx, git.GetPreviousTag(ctx), tag, cfg.Changelog.Options()); err != nil {
+		log.Printf("Warning: announcing without a changelog: %v", err)
+	}
+	return send(ctx, cfg, rel, data)
+}
+
+// newData returns the announcement of the manifest m of outDir, linking
+// the artifacts publish uploads: the files directly in outDir.
+func newData(cfg *config.Config, m *manifest.Manifest, outDir string, rel config.ReleaseData, now time.Time) (Data, error) {
+	data := Data{
+		ProjectName: cfg.Project(),
+		Version:     rel.Version,
+		Channel:     rel.Channel,
+		Artifacts:   []Artifact{},
+		Env:         envvars.Environ(),
+	}
+	started := m.Started
+	if started.IsZero() {
+		started = m.Created
+	}
+	if !started.IsZero() {
+		data.Duration = now.Sub(started).Round(time.Second).String()
+	}
+
+	blob := linkedBlob(cfg)
+	for _, a := range m.Artifacts {
+		if filepath.Clean(filepath.Dir(a.Path)) != filepath.Clean(outDir) {
+			continue
+		}
+		artifact := Artifact{
+			Name: a.Name, Type: a.Type,
+			Goos: a.Goos, Goarch: a.Goarch, Goarm: a.Goarm, Variant: a.Variant,
+			Size: a.Size, SHA256: a.SHA256,
+		}
+		if cfg.Announce.DownloadURL != "" {
+			url, err := downloadURL(cfg.Announce.DownloadURL, blob, a, rel)
+			if err != nil {
+				return Data{}, fmt.Errorf("announce download_url of %s: %w", a.Name, err)
+			}
+			artifact.URL = url
+		}
+		data.Artifacts = append(data.Artifacts, artifact)
+	}
+	return data, nil
+}
+
+// linkedBlob returns the blob announce.blob names, or the only blob.
+func linkedBlob(cfg *config.Config) *config.BlobConfig {
+	for i, blob := range cfg.Blobs {
+		if blob.Name == cfg.Announce.Blob || (cfg.Announce.Blob == "" && len(cfg.Blobs) == 1) {
+			return &cfg.Blobs[i]
+		}
+	}
+	return nil
+}
+
+func downloadURL(urlTemplate string, blob *config.BlobConfig, a manifest.Artifact, rel config.ReleaseData) (string, error) {
+	var shortSha256 string
+	if len(a.SHA256) >= checksum.ShortLen {
+		shortSha256 = a.SHA256[:checksum.ShortLen]
+	}
+	object := a.Name
+	if blob != nil {
+		var err error
+		if object, err = blob.ObjectName(a.Name, rel, shortSha256); err != nil {
+			return "", err
+		}
+	}
+	data := urlData{
+		ObjectTemplateData: config.ObjectTemplateData{Name: a.Name, Version: rel.Version, Channel: rel.Channel, ShortSha256: shortSha256},
+		Object:             object,
+	}
+	return tmpl.Process("download_url", urlTemplate, data)
+}
+
+// send runs the enabled announcers in order.
+func send(ctx context.Context, cfg *config.Config, rel config.ReleaseData, data Data) error {
+	var errs []error
+	for _, a := range cfg.Announce.Announcers {
+		enabled, err := a.IsEnabled(rel)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("announce %q: %w", a.Name, err))
+			continue
+		}
+		if !enabled {
+			log.Printf("Skipping announcer %s: disabled on channel %s", a.Name, rel.Channel)
+			continue
+		}
+		log.Printf("Announcing %s %s via %s", data.ProjectName, data.Version, a.Name)
+		if err := announce(ctx, cfg.Dir, a, data); err != nil {
+			log.Printf("Announcer %s failed: %v", a.Name, err)
+			errs = append(errs, fmt.Errorf("announce %q: %w", a.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d announcer(s) failed: %w", len(errs), len(cfg.Announce.Announcers), errors.Join(errs...))
+	}
+	return nil
+}
+
+func announce(ctx context.Context, dir string, a config.AnnouncerConfig, data Data) error {
+	switch a.Type {
+	case config.AnnouncerShoutrrr:
+		return sendShoutrrr(a, data)
+	case config.AnnouncerWebhook:
+		return sendWebhook(ctx, a, data)
+	case config.AnnouncerExec:
+		return runExec(ctx, dir, a, data)
+	default:
+		return fmt.Errorf("unsupported announcer type %q", a.Type)
+	}
+}
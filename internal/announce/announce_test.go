@@ -0,0 +1,141 @@
+package announce
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+func TestNewData(t *testing.T) {
+	outDir := filepath.Join("dist", "v1.2.0")
+	started := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &manifest.Manifest{
+		Started: started,
+		Created: started.Add(time.Minute),
+		Artifacts: []manifest.Artifact{
+			{Name: "app", Path: filepath.Join(outDir, "app_linux_amd64", "app"), Type: manifest.TypeBinary, Goos: "linux", Goarch: "amd64"},
+			{Name: "app_linux_amd64.tar.gz", Path: filepath.Join(outDir, "app_linux_amd64.tar.gz"), Type: manifest.TypeArchive, Goos: "linux", Goarch: "amd64", SHA256: "abcdef0123456789"},
+			{Name: "checksums.txt", Path: filepath.Join(outDir, "checksums.txt"), Type: manifest.TypeChecksum, SHA256: "0123456789abcdef"},
+		},
+	}
+	cfg := &config.Config{
+		ProjectName: "app",
+		Blobs:       []config.BlobConfig{{Name: "s3", ObjectTemplate: "{{.ShortSha256}}-{{.Name}}"}},
+		Announce:    config.AnnounceConfig{DownloadURL: "https://dl.example.com/{{.Channel}}/{{.Version}}/{{.Object}}"},
+	}
+	rel := config.ReleaseData{Version: "v1.2.0", Channel: "stable"}
+
+	data, err := newData(cfg, m, outDir, rel, started.Add(4*time.Minute+12*time.Second+300*time.Millisecond))
+	if err != nil {
+		t.Fatalf("newData() error = %v", err)
+	}
+	if data.ProjectName != "app" || data.Version != "v1.2.0" || data.Channel != "stable" || data.Duration != "4m12s" {
+		t.Errorf("data = %+v", data)
+	}
+	want := map[string]string{
+		"app_linux_amd64.tar.gz": "https://dl.example.com/stable/v1.2.0/abcdef-app_linux_amd64.tar.gz",
+		"checksums.txt":          "https://dl.example.com/stable/v1.2.0/012345-checksums.txt",
+	}
+	if len(data.Artifacts) != len(want) {
+		t.Fatalf("artifacts = %+v, want only the published files", data.Artifacts)
+	}
+	for _, a := range data.Artifacts {
+		if a.URL != want[a.Name] {
+			t.Errorf("%s url = %q, want %q", a.Name, a.URL, want[a.Name])
+		}
+	}
+
+	// Without a single blob the object is the file name
+	cfg.Blobs = append(cfg.Blobs, config.BlobConfig{Name: "ssh"})
+	if data, err = newData(cfg, m, outDir, rel, started); err != nil {
+		t.Fatal(err)
+	}
+	if got := data.Artifacts[1].URL; got != "https://dl.example.com/stable/v1.2.0/checksums.txt" {
+		t.Errorf("url without a linked blob = %q", got)
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Setenv("GCX_TEST_TOKEN", "secret")
+	var received Data
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "feed is read-only", http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Dir: dir,
+		Announce: config.AnnounceConfig{Announcers: []config.AnnouncerConfig{
+			{Name: "feed", Type: config.AnnouncerWebhook, URL: failing.URL},
+			{Name: "hook", Type: config.AnnouncerWebhook, URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer {{.Env.GCX_TEST_TOKEN}}"}},
+			{Name: "script", Type: config.AnnouncerExec, Command: `cat > announced.json && echo "$GCX_VERSION $GCX_CHANNEL" > env.txt`},
+			{Name: "nightly", Type: config.AnnouncerExec, Command: "touch nightly", Enabled: `{{eq .Channel "nightly"}}`},
+		}},
+	}
+	rel := config.ReleaseData{Version: "v1.2.0", Channel: "stable"}
+	data := Data{
+		ProjectName: "app", Version: rel.Version, Channel: rel.Channel, Changelog: "* fix",
+		Artifacts: []Artifact{{Name: "app.tar.gz", Type: manifest.TypeArchive, URL: "https://dl.example.com/app.tar.gz"}},
+		Env:       envvars.Environ(),
+	}
+
+	err := send(context.Background(), cfg, rel, data)
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 announcer(s) failed") || !strings.Contains(err.Error(), `announce "feed"`) {
+		t.Fatalf("send() error = %v, want the feed failure", err)
+	}
+	if received.Version != "v1.2.0" || received.Changelog != "* fix" || len(received.Artifacts) != 1 || received.Artifacts[0].URL != "https://dl.example.com/app.tar.gz" {
+		t.Errorf("webhook received %+v", received)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	body, err := os.ReadFile(filepath.Join(dir, "announced.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "GCX_TEST_TOKEN") || !strings.Contains(string(body), `"project_name":"app"`) {
+		t.Errorf("exec stdin = %s", body)
+	}
+	if env, _ := os.ReadFile(filepath.Join(dir, "env.txt")); string(env) != "v1.2.0 stable\n" {
+		t.Errorf("exec env = %q", env)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nightly")); err == nil {
+		t.Error("disabled announcer ran")
+	}
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	data := Data{
+		ProjectName: "app", Version: "v1.2.0", Channel: "stable", Duration: "4m12s", Changelog: "* fix",
+		Artifacts: []Artifact{{Name: "app.tar.gz", URL: "https://dl.example.com/app.tar.gz"}, {Name: "app.sbom.json"}},
+	}
+	got, err := tmpl.Process("template", DefaultTemplate, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app v1.2.0 released on stable in 4m12s\n\napp.tar.gz: https://dl.example.com/app.tar.gz\n\n* fix"
+	if got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package announce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/containrrr/shoutrrr"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// DefaultTemplate is the message of shoutrrr announcers without a template.
+const DefaultTemplate = `{{.ProjectName}} {{.Version}} released{{if .Channel}} on {{.Channel}}{{end}}{{if .Duration}} in {{.Duration}}{{end}}
+{{range .Artifacts}}{{if .URL}}
+{{.Name}}: {{.URL}}{{end}}{{end}}{{if .Changelog}}
+
+{{.Changelog}}{{end}}`
+
+// webhookTimeout bounds each webhook request.
+const webhookTimeout = 30 * time.Second
+
+func sendShoutrrr(a config.AnnouncerConfig, data Data) error {
+	text := a.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	msg, err := tmpl.Process("template", text, data)
+	if err != nil {
+		return err
+	}
+	sender, err := shoutrrr.CreateSender(a.URLs...)
+	if err != nil {
+		return fmt.Errorf("create sender: %w", err)
+	}
+	var failed int
+	for _, e := range sender.Send(msg, nil) {
+		if e != nil {
+			failed++
+			err = e
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d url(s) failed: %w", failed, len(a.URLs), err)
+	}
+	return nil
+}
+
+// sendWebhook posts data as JSON to the announcer URL and expects a 2xx
+// response.
+func sendWebhook(ctx context.Context, a config.AnnouncerConfig, data Data) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal announcement: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gcx")
+	for name, value := range a.Headers {
+		rendered, err := tmpl.Process("headers."+name, value, data)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, rendered)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", a.URL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post %s: %s: %s", a.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// runExec runs the announcer command in dir with data as JSON on stdin and
+// the version and channel as GCX_VERSION and GCX_CHANNEL.
+func runExec(ctx context.Context, dir string, a config.AnnouncerConfig, data Data) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal announcement: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", a.Command)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GCX_VERSION="+data.Version, "GCX_CHANNEL="+data.Channel)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %q failed: %w", a.Command, err)
+	}
+	return nil
+}
@@ -1,23 +1,59 @@
 package archive
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
 
 // Archiver creates an archive from a source path.
 type Archiver interface {
 	// Archive creates an archive from srcPath and writes it to destPath.
 	Archive(srcPath, destPath string) error
+	// Write streams an archive of srcPath to w, e.g. stdout.
+	Write(w io.Writer, srcPath string) error
 	// Extension returns the file extension (e.g., "tar.gz", "zip").
 	Extension() string
 }
 
-// New creates an Archiver for the given format.
-func New(format string) (Archiver, error) {
+// toFile creates destPath and writes the archive of srcPath into it.
+func toFile(a Archiver, srcPath, destPath string) (retErr error) {
+	f, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("create archive file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil && retErr == nil {
+			retErr = fmt.Errorf("close archive file: %w", err)
+		}
+	}()
+	return a.Write(f, srcPath)
+}
+
+// Formats lists the supported archive formats.
+var Formats = []string{"tar", "tar.gz", "tar.xz", "tar.zst", "zip"}
+
+// BinaryFormat is the pseudo-format of archive configs that copies the bare
+// binary next to the archives instead of archiving it.
+const BinaryFormat = "binary"
+
+// New creates an Archiver for the given format. level is the compression
+// level of tar.gz (1-9) and tar.zst (1-22) archives, 0 for the default;
+// other formats ignore it.
+func New(format string, level int) (Archiver, error) {
 	switch format {
+	case "tar":
+		return &Tar{}, nil
 	case "tar.gz":
-		return &TarGz{}, nil
+		return &TarGz{Level: level}, nil
+	case "tar.xz":
+		return &TarXz{}, nil
+	case "tar.zst":
+		return &TarZst{Level: level}, nil
 	case "zip":
 		return &Zip{}, nil
 	default:
-		return nil, fmt.Errorf("unsupported archive format: %s", format)
+		return nil, fmt.Errorf("unsupported archive format %q: expected one of %s", format, strings.Join(Formats, ", "))
 	}
 }
@@ -4,25 +4,60 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
 )
 
 func TestNew(t *testing.T) {
+	t.Run("tar", func(t *testing.T) {
+		a, err := New("tar", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Extension() != "tar" {
+			t.Errorf("Extension() = %q, want %q", a.Extension(), "tar")
+		}
+	})
+
 	t.Run("tar.gz", func(t *testing.T) {
-		a, err := New("tar.gz")
+		a, err := New("tar.gz", 1)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if a.Extension() != "tar.gz" {
-			t.Errorf("Extension() = %q, want %q", a.Extension(), "tar.gz")
+		if a.Extension() != "tar.gz" || a.(*TarGz).Level != 1 {
+			t.Errorf("New(tar.gz, 1) = %+v", a)
+		}
+	})
+
+	t.Run("tar.xz", func(t *testing.T) {
+		a, err := New("tar.xz", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Extension() != "tar.xz" {
+			t.Errorf("Extension() = %q, want %q", a.Extension(), "tar.xz")
+		}
+	})
+
+	t.Run("tar.zst", func(t *testing.T) {
+		a, err := New("tar.zst", 19)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Extension() != "tar.zst" || a.(*TarZst).Level != 19 {
+			t.Errorf("New(tar.zst, 19) = %+v", a)
 		}
 	})
 
 	t.Run("zip", func(t *testing.T) {
-		a, err := New("zip")
+		a, err := New("zip", 0)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -32,9 +67,9 @@ func TestNew(t *testing.T) {
 	})
 
 	t.Run("unsupported", func(t *testing.T) {
-		_, err := New("rar")
-		if err == nil {
-			t.Error("expected error for unsupported format")
+		_, err := New("txz", 0)
+		if err == nil || !strings.Contains(err.Error(), `"txz": expected one of tar, tar.gz, tar.xz, tar.zst, zip`) {
+			t.Errorf("New(txz) error = %v", err)
 		}
 	})
 }
@@ -85,6 +120,42 @@ func TestTarGzArchiveFile(t *testing.T) {
 	}
 }
 
+func TestTarGzLevel(t *testing.T) {
+	dir := t.TempDir()
+	srcFile := filepath.Join(dir, "data.txt")
+	var data strings.Builder
+	for i := range 20000 {
+		fmt.Fprintf(&data, "line %d of a compressible file\n", i%997)
+	}
+	if err := os.WriteFile(srcFile, []byte(data.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := map[int]int64{}
+	for _, level := range []int{0, 1, 9} {
+		destFile := filepath.Join(dir, fmt.Sprintf("data%d.tar.gz", level))
+		if err := (&TarGz{Level: level}).Archive(srcFile, destFile); err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		entries, err := Contents(destFile)
+		if err != nil || len(entries) != 1 || entries[0].Size != int64(data.Len()) {
+			t.Fatalf("level %d: Contents() = %+v, %v", level, entries, err)
+		}
+		info, err := os.Stat(destFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[level] = info.Size()
+	}
+	if sizes[1] <= sizes[9] {
+		t.Errorf("level 1 archive (%d bytes) not larger than level 9 (%d bytes)", sizes[1], sizes[9])
+	}
+
+	if err := (&TarGz{Level: 10}).Archive(srcFile, filepath.Join(dir, "bad.tar.gz")); err == nil {
+		t.Error("expected error for level 10")
+	}
+}
+
 func TestTarGzArchiveDir(t *testing.T) {
 	dir := t.TempDir()
 	srcDir := filepath.Join(dir, "myapp_v1.0.0_linux_amd64")
@@ -132,6 +203,142 @@ func TestTarGzArchiveDir(t *testing.T) {
 	}
 }
 
+func TestTarArchiveDir(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "app_v1.0.0_linux_amd64")
+	if err := os.MkdirAll(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "assets.zst"), []byte("packed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	destFile := filepath.Join(dir, "app.tar")
+	if err := (&Tar{}).Archive(srcDir, destFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// The archive is a plain tar stream without compression
+	f, err := os.Open(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	tr := tar.NewReader(f)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "app_v1.0.0_linux_amd64/assets.zst" {
+			content, _ := io.ReadAll(tr)
+			if string(content) != "packed" {
+				t.Errorf("content = %q", content)
+			}
+		}
+	}
+	if len(names) != 2 || names[0] != "app_v1.0.0_linux_amd64/" || names[1] != "app_v1.0.0_linux_amd64/assets.zst" {
+		t.Errorf("entries = %v", names)
+	}
+}
+
+func TestTarXzArchiveDir(t *testing.T) {
+	dir := t.TempDir()
+
+	srcDir := filepath.Join(dir, "app_v1.0.0_linux_arm")
+	if err := os.MkdirAll(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "app"), []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	destFile := filepath.Join(dir, "app.tar.xz")
+	if err := (&TarXz{}).Archive(srcDir, destFile); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	xr, err := xz.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(xr)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "app_v1.0.0_linux_arm/app" {
+			if header.FileInfo().Mode().Perm() != 0o755 {
+				t.Errorf("app mode = %v, want 0755", header.FileInfo().Mode())
+			}
+			content, _ := io.ReadAll(tr)
+			if string(content) != "binary" {
+				t.Errorf("content = %q", content)
+			}
+		}
+	}
+	if len(names) != 2 || names[0] != "app_v1.0.0_linux_arm/" || names[1] != "app_v1.0.0_linux_arm/app" {
+		t.Errorf("entries = %v", names)
+	}
+}
+
+func TestTarZstArchiveFile(t *testing.T) {
+	dir := t.TempDir()
+
+	srcFile := filepath.Join(dir, "app")
+	if err := os.WriteFile(srcFile, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	destFile := filepath.Join(dir, "app.tar.zst")
+	if err := (&TarZst{Level: 19}).Archive(srcFile, destFile); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	header, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != "app" || header.FileInfo().Mode().Perm() != 0o755 {
+		t.Errorf("entry = %s %v, want app 0755", header.Name, header.FileInfo().Mode())
+	}
+	content, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "binary" {
+		t.Errorf("content = %q", content)
+	}
+}
+
 func TestZipArchiveFile(t *testing.T) {
 	dir := t.TempDir()
 
@@ -174,3 +381,75 @@ func TestZipArchiveFile(t *testing.T) {
 		t.Errorf("content = %q, want %q", string(content), "hello world")
 	}
 }
+
+func TestZipArchiveDir(t *testing.T) {
+	dir := t.TempDir()
+
+	srcDir := filepath.Join(dir, "app_v1.0.0_windows_amd64")
+	if err := os.MkdirAll(filepath.Join(srcDir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "app"), []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "docs", "README"), []byte("readme"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	destFile := filepath.Join(dir, "app.zip")
+	if err := (&Zip{}).Archive(srcDir, destFile); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	modes := make(map[string]os.FileMode)
+	for _, f := range r.File {
+		modes[f.Name] = f.Mode()
+	}
+	if m, ok := modes["app_v1.0.0_windows_amd64/"]; !ok || !m.IsDir() {
+		t.Errorf("missing directory entry: %v", modes)
+	}
+	if m := modes["app_v1.0.0_windows_amd64/app"]; m.Perm() != 0o755 {
+		t.Errorf("app mode = %v, want 0755", m)
+	}
+	if m, ok := modes["app_v1.0.0_windows_amd64/docs/README"]; !ok || m.Perm() != 0o644 {
+		t.Errorf("docs/README mode = %v (present %v), want 0644", m, ok)
+	}
+}
+
+func TestTarGzWrite(t *testing.T) {
+	dir := t.TempDir()
+	srcFile := filepath.Join(dir, "hello.txt")
+	if err := os.WriteFile(srcFile, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A pipe is not seekable, like stdout
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError((&TarGz{}).Write(pw, srcFile)) }()
+
+	gr, err := gzip.NewReader(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	header, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != "hello.txt" {
+		t.Errorf("entry name = %q, want hello.txt", header.Name)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("content = %q, want %q", data, "hello world")
+	}
+}
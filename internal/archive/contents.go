@@ -0,0 +1,227 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Entry is a file or directory inside an archive.
+type Entry struct {
+	// Path is the slash-separated name in the archive; directories end
+	// with a slash.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Mode is the octal permission bits, e.g. "0755".
+	Mode string `json:"mode"`
+	// SHA256 is the hex digest of the content of files.
+	SHA256 string `json:"sha256,omitempty"`
+	// Header holds the header values normalized in reproducible archives.
+	Header *Header `json:"header,omitempty"`
+}
+
+// Header is the part of an entry header that reproducible archives
+// normalize. Zip archives have no owners.
+type Header struct {
+	ModTime time.Time `json:"mtime"`
+	UID     int       `json:"uid"`
+	GID     int       `json:"gid"`
+	Uname   string    `json:"uname,omitempty"`
+	Gname   string    `json:"gname,omitempty"`
+}
+
+// FormatOf returns the format of the archive at path from its extension.
+func FormatOf(path string) (string, error) {
+	for _, f := range Formats {
+		if strings.HasSuffix(path, "."+f) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not an archive: expected one of %s", path, strings.Join(Formats, ", "))
+}
+
+// Contents lists the entries of the archive at path in archive order,
+// hashing the content of every file. Nothing is extracted.
+func Contents(path string) ([]Entry, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "zip" {
+		return zipContents(path)
+	}
+
+	tr, closeTar, err := openTar(path, format)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTar()
+
+	var entries []Entry
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		e := Entry{
+			Path: h.Name,
+			Mode: fmt.Sprintf("%04o", fs.FileMode(h.Mode).Perm()),
+			Header: &Header{
+				ModTime: h.ModTime.UTC(),
+				UID:     h.Uid,
+				GID:     h.Gid,
+				Uname:   h.Uname,
+				Gname:   h.Gname,
+			},
+		}
+		if h.Typeflag == tar.TypeReg {
+			if e.Size, e.SHA256, err = digest(tr); err != nil {
+				return nil, fmt.Errorf("read %s in %s: %w", h.Name, path, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Files calls fn with the name and content of every regular file in the
+// archive at path, in archive order. Nothing is extracted.
+func Files(path string, fn func(name string, r io.Reader) error) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	if format == "zip" {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		defer func() { _ = zr.Close() }()
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("read %s in %s: %w", f.Name, path, err)
+			}
+			err = fn(f.Name, rc)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tr, closeTar, err := openTar(path, format)
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(h.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// openTar opens the tar-based archive at path for reading through its
+// decompressor. The returned function closes both.
+func openTar(path, format string) (*tar.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r io.Reader = f
+	closeAll := func() { _ = f.Close() }
+	switch format {
+	case "tar.gz":
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		r = gr
+	case "tar.xz":
+		xr, err := xz.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		r = xr
+	case "tar.zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		r = zr
+		closeAll = func() { zr.Close(); _ = f.Close() }
+	}
+	return tar.NewReader(r), closeAll, nil
+}
+
+func zipContents(path string) ([]Entry, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	entries := make([]Entry, 0, len(zr.File))
+	for _, f := range zr.File {
+		e := Entry{
+			Path:   f.Name,
+			Mode:   fmt.Sprintf("%04o", f.Mode().Perm()),
+			Header: &Header{ModTime: f.Modified.UTC()},
+		}
+		if !f.Mode().IsDir() {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("read %s in %s: %w", f.Name, path, err)
+			}
+			e.Size, e.SHA256, err = digest(rc)
+			_ = rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read %s in %s: %w", f.Name, path, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func digest(r io.Reader) (int64, string, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
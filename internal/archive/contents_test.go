@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContents(t *testing.T) {
+	dir := t.TempDir()
+	srcDir, content := writeSource(t, dir, "app_v1.0.0_linux_amd64", 10)
+	if err := os.Chmod(filepath.Join(srcDir, "docs", "README"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	readme := sha256.Sum256([]byte("readme"))
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var archivers []Archiver
+	var paths []string
+	for _, format := range Formats {
+		a, err := New(format, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archivers = append(archivers, a)
+		paths = append(paths, filepath.Join(dir, "app."+format))
+	}
+	written, err := ArchiveAll(Source{Path: srcDir, ModTime: modTime}, archivers, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{
+		{Path: "app_v1.0.0_linux_amd64/", Mode: "0755"},
+		{Path: "app_v1.0.0_linux_amd64/app", Mode: "0755", Size: 10, SHA256: hex.EncodeToString(sum[:])},
+		{Path: "app_v1.0.0_linux_amd64/docs/", Mode: "0755"},
+		// Normalized from 0600
+		{Path: "app_v1.0.0_linux_amd64/docs/README", Mode: "0644", Size: 6, SHA256: hex.EncodeToString(readme[:])},
+	}
+
+	for _, path := range paths {
+		got, err := Contents(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) || len(written) != len(want) {
+			t.Fatalf("%s: entries = %+v, written %+v", filepath.Base(path), got, written)
+		}
+		for i, e := range got {
+			// ArchiveAll lists what Contents reads back
+			if w := written[i]; w.Path != e.Path || w.Mode != e.Mode || w.Size != e.Size || w.SHA256 != e.SHA256 || *w.Header != *e.Header {
+				t.Errorf("%s: written entry %d = %+v, read %+v", filepath.Base(path), i, w, e)
+			}
+			if e.Path != want[i].Path || e.Mode != want[i].Mode || e.Size != want[i].Size || e.SHA256 != want[i].SHA256 {
+				t.Errorf("%s: entry %d = %+v, want %+v", filepath.Base(path), i, e, want[i])
+			}
+			if e.Header == nil || !e.Header.ModTime.Equal(modTime) || e.Header.UID != 0 || e.Header.Uname != "" {
+				t.Errorf("%s: %s header = %+v, want normalized", filepath.Base(path), e.Path, e.Header)
+			}
+		}
+	}
+
+	if _, err := Contents(filepath.Join(dir, "app.rar")); err == nil || !strings.Contains(err.Error(), "is not an archive") {
+		t.Errorf("Contents() error = %v, want an unsupported format", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	srcDir, content := writeSource(t, dir, "app_v1.0.0_linux_amd64", 10)
+
+	for _, format := range []string{"tar.gz", "zip"} {
+		a, err := New(format, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "app."+format)
+		if _, err := ArchiveAll(Source{Path: srcDir}, []Archiver{a}, []string{path}); err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		err = Files(path, func(name string, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			got[name] = string(data)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"app_v1.0.0_linux_amd64/app":         string(content),
+			"app_v1.0.0_linux_amd64/docs/README": "readme",
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("%s: files = %q, want %q", format, got, want)
+		}
+	}
+}
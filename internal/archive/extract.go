@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ExtractFile writes the content of the file entry name, a path as listed
+// by Contents, of the archive at path to w. Nothing else is extracted.
+func ExtractFile(path, name string, w io.Writer) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	if format == "zip" {
+		return extractZip(path, name, w)
+	}
+
+	tr, closeTar, err := openTar(path, format)
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s has no file %s", path, name)
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		if h.Name != name || h.FileInfo().IsDir() {
+			continue
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			return fmt.Errorf("extract %s from %s: %w", name, path, err)
+		}
+		return nil
+	}
+}
+
+func extractZip(zipPath, name string, w io.Writer) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", zipPath, err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	for _, f := range zr.File {
+		if f.Name != name || f.Mode().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("extract %s from %s: %w", name, zipPath, err)
+		}
+		defer func() { _ = rc.Close() }()
+		if _, err := io.Copy(w, rc); err != nil {
+			return fmt.Errorf("extract %s from %s: %w", name, zipPath, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s has no file %s", zipPath, name)
+}
@@ -0,0 +1,327 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// chunkSize is the size of the blocks read from source files and handed to
+// every format writer.
+const chunkSize = 1 << 20
+
+// entryWriter adds entries to one archive.
+type entryWriter interface {
+	// WriteDir adds a directory entry.
+	WriteDir(name string, info fs.FileInfo) error
+	// CreateFile adds a file entry whose content is written to the
+	// returned writer before the next entry is added.
+	CreateFile(name string, info fs.FileInfo) (io.Writer, error)
+	// Close finishes the archive without closing the underlying writer.
+	Close() error
+}
+
+// entryArchiver is implemented by every Archiver in this package.
+type entryArchiver interface {
+	newEntryWriter(w io.Writer) (entryWriter, error)
+}
+
+// entry is a file or directory of the archived source.
+type entry struct {
+	// name is the slash-separated path inside the archive.
+	name string
+	path string
+	info fs.FileInfo
+}
+
+// File is an extra file or directory added to an archive next to the
+// source, e.g. a LICENSE or shell completions.
+type File struct {
+	// Path is the file or directory on disk; directories are added
+	// recursively.
+	Path string
+	// Name is the slash-separated path inside the archive, relative to
+	// the archived source directory.
+	Name string
+}
+
+// Source is the content of an archive: a file or directory and extra
+// files.
+type Source struct {
+	Path   string
+	Extras []File
+	// ModTime, when set, makes the archive reproducible: every entry gets
+	// this modification time, and modes are normalized to 0755 for
+	// directories and executables and 0644 for other files.
+	ModTime time.Time
+}
+
+// normalizedInfo overrides the time and mode of a reproducible entry.
+type normalizedInfo struct {
+	fs.FileInfo
+	modTime time.Time
+}
+
+func (n normalizedInfo) ModTime() time.Time { return n.modTime }
+
+func (n normalizedInfo) Mode() fs.FileMode {
+	mode := n.FileInfo.Mode()
+	switch {
+	case mode.IsDir():
+		return fs.ModeDir | 0o755
+	case mode&0o111 != 0:
+		return 0o755
+	default:
+		return 0o644
+	}
+}
+
+// listEntries walks the source and its extra files once and returns the
+// entries of their archive, named relative to the parent of the source.
+func listEntries(src Source) ([]entry, error) {
+	entries, err := walkSource(src.Path, src.Extras)
+	if err != nil || src.ModTime.IsZero() {
+		return entries, err
+	}
+	for i := range entries {
+		entries[i].info = normalizedInfo{FileInfo: entries[i].info, modTime: src.ModTime}
+	}
+	return entries, nil
+}
+
+func walkSource(srcPath string, extras []File) ([]entry, error) {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("stat source: %w", err)
+	}
+	base := filepath.Base(srcPath)
+	entries, err := walk(srcPath, base)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extras of a single-file source sit next to it
+	prefix := ""
+	if srcInfo.IsDir() {
+		prefix = base
+	}
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		seen[e.name] = true
+	}
+	for _, extra := range extras {
+		extraEntries, err := walk(extra.Path, path.Join(prefix, extra.Name))
+		if err != nil {
+			return nil, fmt.Errorf("extra file %s: %w", extra.Path, err)
+		}
+		for _, e := range extraEntries {
+			if seen[e.name] {
+				if e.info.IsDir() {
+					continue
+				}
+				return nil, fmt.Errorf("extra file %s: archive already has %s", extra.Path, e.name)
+			}
+			seen[e.name] = true
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// walk returns the entries of root, which is named name in the archive.
+func walk(root, name string) ([]entry, error) {
+	var entries []entry
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, p)
+		if err != nil {
+			return fmt.Errorf("relative path: %w", err)
+		}
+		// Entry names always use forward slashes
+		entries = append(entries, entry{name: path.Join(name, filepath.ToSlash(relPath)), path: p, info: info})
+		return nil
+	})
+	return entries, err
+}
+
+// ArchiveAll writes an archive of src for every archiver to the destPaths
+// entry with the same index. Each source file is read once and its content
+// is streamed to all archivers, which compress concurrently. The entries of
+// the archives, which all formats share, are hashed while streaming and
+// returned as Contents would list them.
+func ArchiveAll(src Source, archivers []Archiver, destPaths []string) (_ []Entry, retErr error) {
+	if len(archivers) != len(destPaths) {
+		return nil, fmt.Errorf("%d archivers for %d destinations", len(archivers), len(destPaths))
+	}
+
+	writers := make([]io.Writer, len(destPaths))
+	for i, destPath := range destPaths {
+		f, err := os.Create(destPath)
+		if err != nil {
+			return nil, fmt.Errorf("create archive file: %w", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil && retErr == nil {
+				retErr = fmt.Errorf("close archive file: %w", err)
+			}
+		}()
+		writers[i] = f
+	}
+	return writeAll(src, archivers, writers)
+}
+
+// writeAll streams the archive of src in the format of archivers[i] to
+// writers[i] and returns its entries.
+func writeAll(src Source, archivers []Archiver, writers []io.Writer) ([]Entry, error) {
+	entries, err := listEntries(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil chunk ends the content of the current file. Closing the
+	// channels early tells the format writers that reading failed.
+	chans := make([]chan []byte, len(archivers))
+	errs := make([]error, len(archivers))
+	var wg sync.WaitGroup
+	for i, a := range archivers {
+		ea, ok := a.(entryArchiver)
+		if !ok {
+			return nil, fmt.Errorf("%s archives cannot share a source", a.Extension())
+		}
+		chans[i] = make(chan []byte, 4)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = consume(ea, writers[i], entries, chans[i])
+			// Drain so the reader never blocks on a failed format
+			for range chans[i] {
+			}
+		}()
+	}
+
+	contents, readErr := produce(entries, chans)
+	for _, ch := range chans {
+		close(ch)
+	}
+	wg.Wait()
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archivers[i].Extension(), err)
+		}
+	}
+	return contents, nil
+}
+
+// produce reads every file entry once, sends its chunks to all chans and
+// returns the listed entries.
+func produce(entries []entry, chans []chan []byte) ([]Entry, error) {
+	contents := make([]Entry, len(entries))
+	for i, e := range entries {
+		contents[i] = Entry{
+			Path: e.name,
+			Mode: fmt.Sprintf("%04o", e.info.Mode().Perm()),
+			// As tar rounds it; zip keeps the same seconds
+			Header: &Header{ModTime: e.info.ModTime().Round(time.Second).UTC()},
+		}
+		if e.info.IsDir() {
+			contents[i].Path += "/"
+			continue
+		}
+		var err error
+		if contents[i].Size, contents[i].SHA256, err = sendFile(e.path, chans); err != nil {
+			return nil, err
+		}
+	}
+	return contents, nil
+}
+
+// sendFile sends the content of path to all chans and returns its size and
+// hex SHA-256.
+func sendFile(path string, chans []chan []byte) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("open file: %w", err)
+	}
+	defer func() {
+		_ = file.Close() // read-only, safe to ignore
+	}()
+
+	h := sha256.New()
+	var size int64
+	for {
+		// Chunks are shared read-only by the format writers, so every
+		// read needs a fresh buffer
+		buf := make([]byte, chunkSize)
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			size += int64(n)
+			for _, ch := range chans {
+				ch <- buf[:n]
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	for _, ch := range chans {
+		ch <- nil
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// consume writes the archive of entries to w, taking file content from ch.
+func consume(a entryArchiver, w io.Writer, entries []entry, ch <-chan []byte) (retErr error) {
+	ew, err := a.newEntryWriter(w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := ew.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	for _, e := range entries {
+		if e.info.IsDir() {
+			if err := ew.WriteDir(e.name, e.info); err != nil {
+				return err
+			}
+			continue
+		}
+		fw, err := ew.CreateFile(e.name, e.info)
+		if err != nil {
+			return err
+		}
+		for {
+			chunk, ok := <-ch
+			if !ok {
+				return fmt.Errorf("source of %s was not read completely", e.name)
+			}
+			if chunk == nil {
+				break
+			}
+			if _, err := fw.Write(chunk); err != nil {
+				return fmt.Errorf("write %s: %w", e.name, err)
+			}
+		}
+	}
+	return nil
+}
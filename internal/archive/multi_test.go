@@ -0,0 +1,225 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/sync/errgroup"
+)
+
+// writeSource creates dir/name/app with size bytes of partly compressible
+// content, like a binary, and dir/name/docs/README.
+func writeSource(t testing.TB, dir, name string, size int) (srcDir string, content []byte) {
+	t.Helper()
+	srcDir = filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Join(srcDir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	content = make([]byte, size)
+	for i := 0; i < size; i += 4096 {
+		block := content[i:min(i+4096, size)]
+		if i/4096%2 == 0 {
+			rng.Read(block)
+		} else {
+			copy(block, bytes.Repeat([]byte("gcx "), len(block)/4+1))
+		}
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "app"), content, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "docs", "README"), []byte("readme"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return srcDir, content
+}
+
+func TestArchiveAll(t *testing.T) {
+	dir := t.TempDir()
+	// Larger than chunkSize and not a multiple of it
+	srcDir, content := writeSource(t, dir, "app_v1.0.0_linux_amd64", 3*chunkSize+17)
+
+	archivers := []Archiver{&TarGz{}, &TarZst{Level: 3}, &Zip{}}
+	paths := []string{filepath.Join(dir, "a.tar.gz"), filepath.Join(dir, "a.tar.zst"), filepath.Join(dir, "a.zip")}
+	if _, err := ArchiveAll(Source{Path: srcDir}, archivers, paths); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"app_v1.0.0_linux_amd64/":            "",
+		"app_v1.0.0_linux_amd64/app":         string(content),
+		"app_v1.0.0_linux_amd64/docs/":       "",
+		"app_v1.0.0_linux_amd64/docs/README": "readme",
+	}
+	check := func(format string, got map[string]string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s entries = %d, want %d", format, len(got), len(want))
+		}
+		for name, data := range want {
+			if g, ok := got[name]; !ok || g != data {
+				t.Errorf("%s: entry %s missing or with %d bytes, want %d", format, name, len(g), len(data))
+			}
+		}
+	}
+
+	readTar := func(r io.Reader) map[string]string {
+		got := make(map[string]string)
+		tr := tar.NewReader(r)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return got
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[header.Name] = string(data)
+		}
+	}
+
+	f, err := os.Open(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("tar.gz", readTar(gr))
+
+	f, err = os.Open(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	check("tar.zst", readTar(zr))
+
+	r, err := zip.OpenReader(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	got := make(map[string]string)
+	for _, file := range r.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[file.Name] = string(data)
+	}
+	check("zip", got)
+}
+
+func TestArchiveAllExtras(t *testing.T) {
+	dir := t.TempDir()
+	srcDir, _ := writeSource(t, dir, "app_v1.0.0_linux_amd64", 10)
+	license := filepath.Join(dir, "LICENSE")
+	if err := os.WriteFile(license, []byte("license"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	completions := filepath.Join(dir, "completions")
+	if err := os.MkdirAll(completions, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(completions, "app.bash"), []byte("bash"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Extras join the directories of the source
+	extras := []File{{Path: license, Name: "docs/LICENSE"}, {Path: completions, Name: "completions"}}
+	entries, err := listEntries(Source{Path: srcDir, Extras: extras})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	want := "app_v1.0.0_linux_amd64,app_v1.0.0_linux_amd64/app,app_v1.0.0_linux_amd64/docs,app_v1.0.0_linux_amd64/docs/README," +
+		"app_v1.0.0_linux_amd64/docs/LICENSE,app_v1.0.0_linux_amd64/completions,app_v1.0.0_linux_amd64/completions/app.bash"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("entries = %s, want %s", got, want)
+	}
+
+	path := filepath.Join(dir, "a.tar.gz")
+	_, err = ArchiveAll(Source{Path: srcDir, Extras: []File{{Path: license, Name: "docs/README"}}}, []Archiver{&TarGz{}}, []string{path})
+	if err == nil || !strings.Contains(err.Error(), "archive already has app_v1.0.0_linux_amd64/docs/README") {
+		t.Errorf("ArchiveAll() error = %v, want a duplicate entry", err)
+	}
+}
+
+func TestArchiveAllMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ArchiveAll(Source{Path: filepath.Join(dir, "missing")}, []Archiver{&TarGz{}}, []string{filepath.Join(dir, "a.tar.gz")})
+	if err == nil {
+		t.Fatal("expected an error for a missing source")
+	}
+}
+
+// benchmarkFormats are the formats a typical release archives a binary in.
+func benchmarkFormats() []Archiver {
+	return []Archiver{&TarGz{}, &Zip{}, &TarZst{}}
+}
+
+func benchmarkSource(b *testing.B) (srcDir string, paths []string) {
+	b.Helper()
+	dir := b.TempDir()
+	srcDir, _ = writeSource(b, dir, "app_v1.0.0_linux_amd64", 100<<20)
+	for _, a := range benchmarkFormats() {
+		paths = append(paths, filepath.Join(dir, "app."+a.Extension()))
+	}
+	b.SetBytes(100 << 20)
+	b.ResetTimer()
+	return srcDir, paths
+}
+
+// BenchmarkArchivePerFormat archives a 100 MB artifact the way builds did
+// before ArchiveAll: one goroutine per format, each reading the source.
+func BenchmarkArchivePerFormat(b *testing.B) {
+	srcDir, paths := benchmarkSource(b)
+	for b.Loop() {
+		var eg errgroup.Group
+		for i, a := range benchmarkFormats() {
+			eg.Go(func() error { return a.Archive(srcDir, paths[i]) })
+		}
+		if err := eg.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkArchiveAll archives a 100 MB artifact in the same formats,
+// reading the source once.
+func BenchmarkArchiveAll(b *testing.B) {
+	srcDir, paths := benchmarkSource(b)
+	for b.Loop() {
+		if _, err := ArchiveAll(Source{Path: srcDir}, benchmarkFormats(), paths); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
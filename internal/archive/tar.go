@@ -0,0 +1,25 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+)
+
+// Tar creates uncompressed tar archives, for artifacts that are already
+// compressed and would only cost CPU time to compress again.
+type Tar struct{}
+
+func (t *Tar) Extension() string { return "tar" }
+
+func (t *Tar) Archive(srcPath, destPath string) error {
+	return toFile(t, srcPath, destPath)
+}
+
+func (t *Tar) Write(w io.Writer, srcPath string) error {
+	_, err := writeAll(Source{Path: srcPath}, []Archiver{t}, []io.Writer{w})
+	return err
+}
+
+func (t *Tar) newEntryWriter(w io.Writer) (entryWriter, error) {
+	return &tarWriter{tw: tar.NewWriter(w)}, nil
+}
@@ -5,108 +5,89 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
 )
 
 // TarGz creates tar.gz archives.
-type TarGz struct{}
+type TarGz struct {
+	// Level is the gzip compression level (1-9); 0 selects the default.
+	Level int
+}
 
 func (t *TarGz) Extension() string { return "tar.gz" }
 
-func (t *TarGz) Archive(srcPath, destPath string) (retErr error) {
-	f, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("create archive file: %w", err)
-	}
-	defer func() {
-		if err := f.Close(); err != nil && retErr == nil {
-			retErr = fmt.Errorf("close archive file: %w", err)
-		}
-	}()
-
-	gw := gzip.NewWriter(f)
-	defer func() {
-		if err := gw.Close(); err != nil && retErr == nil {
-			retErr = fmt.Errorf("close gzip writer: %w", err)
-		}
-	}()
+func (t *TarGz) Archive(srcPath, destPath string) error {
+	return toFile(t, srcPath, destPath)
+}
 
-	tw := tar.NewWriter(gw)
-	defer func() {
-		if err := tw.Close(); err != nil && retErr == nil {
-			retErr = fmt.Errorf("close tar writer: %w", err)
-		}
-	}()
+func (t *TarGz) Write(w io.Writer, srcPath string) error {
+	_, err := writeAll(Source{Path: srcPath}, []Archiver{t}, []io.Writer{w})
+	return err
+}
 
-	srcInfo, err := os.Stat(srcPath)
-	if err != nil {
-		return fmt.Errorf("stat source: %w", err)
+func (t *TarGz) newEntryWriter(w io.Writer) (entryWriter, error) {
+	level := gzip.DefaultCompression
+	if t.Level > 0 {
+		level = t.Level
 	}
-
-	if srcInfo.IsDir() {
-		return addDirToTar(tw, srcPath, filepath.Base(srcPath))
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, fmt.Errorf("create gzip writer: %w", err)
 	}
-	return addFileToTar(tw, srcPath, filepath.Base(srcPath))
+	return newTarWriter(gw, "gzip"), nil
 }
 
-func addFileToTar(tw *tar.Writer, filePath, nameInTar string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("open file: %w", err)
-	}
-	defer func() {
-		_ = file.Close() // read-only, safe to ignore
-	}()
+// tarWriter writes a tar stream to a compressor, which is closed after
+// the tar writer. Uncompressed tar archives have no compressor.
+type tarWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+	// kind names the compression in close errors.
+	kind string
+}
 
-	stat, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("stat file: %w", err)
-	}
+func newTarWriter(compressor io.WriteCloser, kind string) *tarWriter {
+	return &tarWriter{tw: tar.NewWriter(compressor), compressor: compressor, kind: kind}
+}
 
+func (t *tarWriter) WriteDir(name string, info fs.FileInfo) error {
 	header := &tar.Header{
-		Name:    nameInTar,
-		Size:    stat.Size(),
-		Mode:    int64(stat.Mode()),
-		ModTime: stat.ModTime(),
+		Name:     name + "/",
+		Mode:     int64(info.Mode()),
+		ModTime:  info.ModTime(),
+		Typeflag: tar.TypeDir,
 	}
-	if err := tw.WriteHeader(header); err != nil {
+	if err := t.tw.WriteHeader(header); err != nil {
 		return fmt.Errorf("write tar header: %w", err)
 	}
-	if _, err := io.Copy(tw, file); err != nil {
-		return fmt.Errorf("write file to tar: %w", err)
-	}
 	return nil
 }
 
-func addDirToTar(tw *tar.Writer, dirPath, baseInTar string) error {
-	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(dirPath, path)
-		if err != nil {
-			return fmt.Errorf("relative path: %w", err)
-		}
-
-		var nameInTar string
-		if relPath == "." {
-			nameInTar = baseInTar
-		} else {
-			nameInTar = filepath.Join(baseInTar, relPath)
-		}
+func (t *tarWriter) CreateFile(name string, info fs.FileInfo) (io.Writer, error) {
+	header := &tar.Header{
+		Name:    name,
+		Size:    info.Size(),
+		Mode:    int64(info.Mode()),
+		ModTime: info.ModTime(),
+	}
+	if err := t.tw.WriteHeader(header); err != nil {
+		return nil, fmt.Errorf("write tar header: %w", err)
+	}
+	return t.tw, nil
+}
 
-		if info.IsDir() {
-			header := &tar.Header{
-				Name:     nameInTar + "/",
-				Mode:     int64(info.Mode()),
-				ModTime:  info.ModTime(),
-				Typeflag: tar.TypeDir,
-			}
-			return tw.WriteHeader(header)
+func (t *tarWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		if t.compressor != nil {
+			_ = t.compressor.Close()
 		}
-
-		return addFileToTar(tw, path, nameInTar)
-	})
+		return fmt.Errorf("close tar writer: %w", err)
+	}
+	if t.compressor == nil {
+		return nil
+	}
+	if err := t.compressor.Close(); err != nil {
+		return fmt.Errorf("close %s writer: %w", t.kind, err)
+	}
+	return nil
 }
@@ -0,0 +1,31 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// TarXz creates tar.xz archives. They are smaller than tar.gz at the cost
+// of slower compression.
+type TarXz struct{}
+
+func (t *TarXz) Extension() string { return "tar.xz" }
+
+func (t *TarXz) Archive(srcPath, destPath string) error {
+	return toFile(t, srcPath, destPath)
+}
+
+func (t *TarXz) Write(w io.Writer, srcPath string) error {
+	_, err := writeAll(Source{Path: srcPath}, []Archiver{t}, []io.Writer{w})
+	return err
+}
+
+func (t *TarXz) newEntryWriter(w io.Writer) (entryWriter, error) {
+	xw, err := xz.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("create xz writer: %w", err)
+	}
+	return newTarWriter(xw, "xz"), nil
+}
@@ -0,0 +1,37 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TarZst creates tar.zst archives, which decompress much faster than tar.gz.
+type TarZst struct {
+	// Level is the zstd compression level (1-22); 0 selects the default.
+	Level int
+}
+
+func (t *TarZst) Extension() string { return "tar.zst" }
+
+func (t *TarZst) Archive(srcPath, destPath string) error {
+	return toFile(t, srcPath, destPath)
+}
+
+func (t *TarZst) Write(w io.Writer, srcPath string) error {
+	_, err := writeAll(Source{Path: srcPath}, []Archiver{t}, []io.Writer{w})
+	return err
+}
+
+func (t *TarZst) newEntryWriter(w io.Writer) (entryWriter, error) {
+	var opts []zstd.EOption
+	if t.Level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(t.Level)))
+	}
+	zw, err := zstd.NewWriter(w, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create zstd writer: %w", err)
+	}
+	return newTarWriter(zw, "zstd"), nil
+}
@@ -4,8 +4,7 @@ import (
 	"archive/zip"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
 )
 
 // Zip creates zip archives.
@@ -13,89 +12,55 @@ type Zip struct{}
 
 func (z *Zip) Extension() string { return "zip" }
 
-func (z *Zip) Archive(srcPath, destPath string) (retErr error) {
-	f, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("create archive file: %w", err)
-	}
-	defer func() {
-		if err := f.Close(); err != nil && retErr == nil {
-			retErr = fmt.Errorf("close archive file: %w", err)
-		}
-	}()
+func (z *Zip) Archive(srcPath, destPath string) error {
+	return toFile(z, srcPath, destPath)
+}
 
-	zw := zip.NewWriter(f)
-	defer func() {
-		if err := zw.Close(); err != nil && retErr == nil {
-			retErr = fmt.Errorf("close zip writer: %w", err)
-		}
-	}()
+func (z *Zip) Write(w io.Writer, srcPath string) error {
+	_, err := writeAll(Source{Path: srcPath}, []Archiver{z}, []io.Writer{w})
+	return err
+}
 
-	srcInfo, err := os.Stat(srcPath)
-	if err != nil {
-		return fmt.Errorf("stat source: %w", err)
-	}
+func (z *Zip) newEntryWriter(w io.Writer) (entryWriter, error) {
+	return zipWriter{zip.NewWriter(w)}, nil
+}
 
-	if srcInfo.IsDir() {
-		return addDirToZip(zw, srcPath, filepath.Base(srcPath))
-	}
-	return addFileToZip(zw, srcPath, filepath.Base(srcPath))
+type zipWriter struct {
+	zw *zip.Writer
 }
 
-func addFileToZip(zw *zip.Writer, filePath, nameInZip string) error {
-	file, err := os.Open(filePath)
+func (z zipWriter) WriteDir(name string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
 	if err != nil {
-		return fmt.Errorf("open file: %w", err)
+		return fmt.Errorf("create zip header: %w", err)
 	}
-	defer func() {
-		_ = file.Close() // read-only, safe to ignore
-	}()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("stat file: %w", err)
+	header.Name = name + "/"
+	if _, err := z.zw.CreateHeader(header); err != nil {
+		return fmt.Errorf("create zip entry: %w", err)
 	}
+	return nil
+}
 
-	header, err := zip.FileInfoHeader(stat)
+func (z zipWriter) CreateFile(name string, info fs.FileInfo) (io.Writer, error) {
+	header, err := zip.FileInfoHeader(info)
 	if err != nil {
-		return fmt.Errorf("create zip header: %w", err)
+		return nil, fmt.Errorf("create zip header: %w", err)
 	}
-	header.Name = nameInZip
+	// FileInfoHeader records the Unix mode, so executables stay executable
+	// when extracted with unzip
+	header.Name = name
 	header.Method = zip.Deflate
 
-	w, err := zw.CreateHeader(header)
+	w, err := z.zw.CreateHeader(header)
 	if err != nil {
-		return fmt.Errorf("create zip entry: %w", err)
-	}
-	if _, err := io.Copy(w, file); err != nil {
-		return fmt.Errorf("write file to zip: %w", err)
+		return nil, fmt.Errorf("create zip entry: %w", err)
 	}
-	return nil
+	return w, nil
 }
 
-func addDirToZip(zw *zip.Writer, dirPath, baseInZip string) error {
-	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(dirPath, path)
-		if err != nil {
-			return fmt.Errorf("relative path: %w", err)
-		}
-
-		var nameInZip string
-		if relPath == "." {
-			nameInZip = baseInZip
-		} else {
-			nameInZip = filepath.Join(baseInZip, relPath)
-		}
-
-		if info.IsDir() {
-			_, err := zw.Create(nameInZip + "/")
-			return err
-		}
-
-		return addFileToZip(zw, path, nameInZip)
-	})
+func (z zipWriter) Close() error {
+	if err := z.zw.Close(); err != nil {
+		return fmt.Errorf("close zip writer: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,160 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+)
+
+// Entry statuses of a contents diff.
+const (
+	EntryAdded   = "added"
+	EntryRemoved = "removed"
+	EntryChanged = "changed"
+)
+
+// EntryDiff is an archive entry that differs between two archives.
+type EntryDiff struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	// Fields names the differing values of changed entries, e.g. sha256
+	// or mtime.
+	Fields []string       `json:"fields,omitempty"`
+	Old    *archive.Entry `json:"old,omitempty"`
+	New    *archive.Entry `json:"new,omitempty"`
+}
+
+// DiffContents compares the entries of two archives by path, in the order
+// of old followed by the entries only new has.
+func DiffContents(old, cur []archive.Entry) []EntryDiff {
+	byPath := make(map[string]archive.Entry, len(cur))
+	for _, e := range cur {
+		byPath[e.Path] = e
+	}
+	diffs := []EntryDiff{}
+	seen := make(map[string]bool, len(old))
+	for _, o := range old {
+		seen[o.Path] = true
+		n, ok := byPath[o.Path]
+		if !ok {
+			diffs = append(diffs, EntryDiff{Path: o.Path, Status: EntryRemoved, Old: &o})
+			continue
+		}
+		if fields := changedFields(o, n); len(fields) > 0 {
+			diffs = append(diffs, EntryDiff{Path: o.Path, Status: EntryChanged, Fields: fields, Old: &o, New: &n})
+		}
+	}
+	for _, n := range cur {
+		if !seen[n.Path] {
+			diffs = append(diffs, EntryDiff{Path: n.Path, Status: EntryAdded, New: &n})
+		}
+	}
+	return diffs
+}
+
+// changedFields lists the values that differ between two entries with the
+// same path. Header values are compared when both entries have them.
+func changedFields(a, b archive.Entry) []string {
+	var fields []string
+	add := func(name string, differ bool) {
+		if differ {
+			fields = append(fields, name)
+		}
+	}
+	add("size", a.Size != b.Size)
+	add("mode", a.Mode != b.Mode)
+	add("sha256", a.SHA256 != b.SHA256)
+	if a.Header != nil && b.Header != nil {
+		add("mtime", !a.Header.ModTime.Equal(b.Header.ModTime))
+		add("uid", a.Header.UID != b.Header.UID)
+		add("gid", a.Header.GID != b.Header.GID)
+		add("uname", a.Header.Uname != b.Header.Uname)
+		add("gname", a.Header.Gname != b.Header.Gname)
+	}
+	return fields
+}
+
+// WriteContentsTable prints the entries of an archive.
+func WriteContentsTable(w io.Writer, entries []archive.Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODE\tSIZE\tMTIME\tSHA256\tPATH")
+	for _, e := range entries {
+		mtime := "-"
+		if e.Header != nil {
+			mtime = e.Header.ModTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", e.Mode, e.Size, mtime, orDash(e.SHA256), e.Path)
+	}
+	return tw.Flush()
+}
+
+// WriteContentsJSON prints the entries of an archive as indented JSON.
+func WriteContentsJSON(w io.Writer, entries []archive.Entry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		return fmt.Errorf("encode contents: %w", err)
+	}
+	return nil
+}
+
+// WriteDiffTable prints the differing entries of two archives, or that
+// they are identical entry by entry.
+func WriteDiffTable(w io.Writer, old, cur string, diffs []EntryDiff) error {
+	fmt.Fprintf(w, "Comparing %s with %s\n\n", old, cur)
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "The archives are identical entry by entry")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tPATH\tDIFFERENCES")
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Status, d.Path, describeFields(d))
+	}
+	return tw.Flush()
+}
+
+// WriteDiffJSON prints the differing entries of two archives as indented
+// JSON.
+func WriteDiffJSON(w io.Writer, diffs []EntryDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(diffs); err != nil {
+		return fmt.Errorf("encode contents diff: %w", err)
+	}
+	return nil
+}
+
+// describeFields renders the changed values of d, e.g.
+// "size 10 -> 12, sha256".
+func describeFields(d EntryDiff) string {
+	if d.Status != EntryChanged {
+		return "-"
+	}
+	parts := make([]string, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		switch f {
+		case "size":
+			f += " " + strconv.FormatInt(d.Old.Size, 10) + " -> " + strconv.FormatInt(d.New.Size, 10)
+		case "mode":
+			f += " " + d.Old.Mode + " -> " + d.New.Mode
+		case "mtime":
+			f += " " + d.Old.Header.ModTime.Format(time.RFC3339) + " -> " + d.New.Header.ModTime.Format(time.RFC3339)
+		}
+		parts = append(parts, f)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package artifacts
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+)
+
+func TestDiffContents(t *testing.T) {
+	t1 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	old := []archive.Entry{
+		{Path: "app/", Mode: "0755", Header: &archive.Header{ModTime: t1}},
+		{Path: "app/app", Mode: "0755", Size: 10, SHA256: "aa", Header: &archive.Header{ModTime: t1}},
+		{Path: "app/README", Mode: "0644", Size: 6, SHA256: "bb", Header: &archive.Header{ModTime: t1}},
+	}
+	cur := []archive.Entry{
+		{Path: "app/", Mode: "0755", Header: &archive.Header{ModTime: t1}},
+		{Path: "app/app", Mode: "0755", Size: 12, SHA256: "cc", Header: &archive.Header{ModTime: t2, UID: 1000}},
+		{Path: "app/LICENSE", Mode: "0644", Size: 3, SHA256: "dd", Header: &archive.Header{ModTime: t1}},
+	}
+
+	diffs := DiffContents(old, cur)
+	if len(diffs) != 3 {
+		t.Fatalf("diffs = %+v, want 3", diffs)
+	}
+	if d := diffs[0]; d.Path != "app/app" || d.Status != EntryChanged || !slices.Equal(d.Fields, []string{"size", "sha256", "mtime", "uid"}) {
+		t.Errorf("diffs[0] = %+v", d)
+	}
+	if d := diffs[1]; d.Path != "app/README" || d.Status != EntryRemoved {
+		t.Errorf("diffs[1] = %+v", d)
+	}
+	if d := diffs[2]; d.Path != "app/LICENSE" || d.Status != EntryAdded {
+		t.Errorf("diffs[2] = %+v", d)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDiffTable(&buf, "a.tar.gz", "b.tar.gz", diffs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "size 10 -> 12, sha256, mtime 2024-05-01T00:00:00Z -> 2024-05-01T01:00:00Z, uid") {
+		t.Errorf("table = %s", buf.String())
+	}
+
+	if diffs := DiffContents(old, old); len(diffs) != 0 {
+		t.Errorf("identical contents differ: %+v", diffs)
+	}
+}
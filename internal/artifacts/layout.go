@@ -0,0 +1,80 @@
+package artifacts
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// versionMarker is rendered in place of {{.Version}} to locate it in a directory template.
+const versionMarker = "__GCX_VERSION__"
+
+// layout describes where versions live inside a rendered blob directory template,
+// e.g. "releases/myapp-{{.Version}}/bin" has parent "releases", prefix "myapp-",
+// and rest "bin". {{.Channel}} is rendered with the channel of the layout, and
+// {{.Tag}} like {{.Version}}.
+type layout struct {
+	template string
+	channel  string
+	// hasVersion is false when the template does not reference {{.Version}};
+	// all versions then share one directory.
+	hasVersion bool
+	parent     string
+	prefix     string
+	suffix     string
+	rest       string
+}
+
+func parseLayout(dirTemplate, channel string) (layout, error) {
+	rendered, err := tmpl.Process("directory", dirTemplate, map[string]string{"Version": versionMarker, "Tag": versionMarker, "Channel": channel})
+	if err != nil {
+		return layout{}, fmt.Errorf("process directory template: %w", err)
+	}
+
+	l := layout{template: dirTemplate, channel: channel}
+	before, after, found := strings.Cut(rendered, versionMarker)
+	if !found {
+		l.parent = strings.TrimSuffix(rendered, "/")
+		return l, nil
+	}
+	l.hasVersion = true
+
+	if i := strings.LastIndex(before, "/"); i >= 0 {
+		l.parent, l.prefix = before[:i], before[i+1:]
+	} else {
+		l.prefix = before
+	}
+	if i := strings.Index(after, "/"); i >= 0 {
+		l.suffix, l.rest = after[:i], strings.Trim(after[i+1:], "/")
+	} else {
+		l.suffix = after
+	}
+	return l, nil
+}
+
+// dir renders the directory holding the artifacts of version.
+func (l layout) dir(version string) (string, error) {
+	dir, err := tmpl.Process("directory", l.template, map[string]string{"Version": version, "Tag": version, "Channel": l.channel})
+	if err != nil {
+		return "", fmt.Errorf("process directory template: %w", err)
+	}
+	return strings.TrimSuffix(dir, "/"), nil
+}
+
+// versionFromEntry extracts the version from an entry name under parent.
+func (l layout) versionFromEntry(name string) (string, bool) {
+	if !l.hasVersion || len(name) <= len(l.prefix)+len(l.suffix) {
+		return "", false
+	}
+	if !strings.HasPrefix(name, l.prefix) || !strings.HasSuffix(name, l.suffix) {
+		return "", false
+	}
+	return name[len(l.prefix) : len(name)-len(l.suffix)], true
+}
+
+// versionDir returns the path of the version entry below parent.
+func (l layout) versionDir(version string) string {
+	return path.Join(l.parent, l.prefix+version+l.suffix)
+}
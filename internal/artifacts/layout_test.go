@@ -0,0 +1,60 @@
+package artifacts
+
+import "testing"
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		hasVersion bool
+		parent     string
+		prefix     string
+		suffix     string
+		rest       string
+	}{
+		{name: "version directory", template: "releases/{{.Version}}", hasVersion: true, parent: "releases"},
+		{name: "prefix and suffix", template: "apps/myapp-{{.Version}}-linux/bin", hasVersion: true, parent: "apps", prefix: "myapp-", suffix: "-linux", rest: "bin"},
+		{name: "version at root", template: "{{.Version}}", hasVersion: true},
+		{name: "no version", template: "releases/latest/", parent: "releases/latest"},
+		{name: "channel", template: "{{.Channel}}/app-{{.Version}}", hasVersion: true, parent: "beta", prefix: "app-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := parseLayout(tt.template, "beta")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.hasVersion != tt.hasVersion || l.parent != tt.parent || l.prefix != tt.prefix || l.suffix != tt.suffix || l.rest != tt.rest {
+				t.Errorf("parseLayout(%q) = %+v", tt.template, l)
+			}
+		})
+	}
+}
+
+func TestLayoutVersionFromEntry(t *testing.T) {
+	l, err := parseLayout("apps/myapp-{{.Version}}-linux", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entry string
+		want  string
+		ok    bool
+	}{
+		{entry: "myapp-v1.4.2-linux", want: "v1.4.2", ok: true},
+		{entry: "myapp--linux"},
+		{entry: "other-v1.4.2-linux"},
+		{entry: "myapp-v1.4.2-darwin"},
+	}
+	for _, tt := range tests {
+		got, ok := l.versionFromEntry(tt.entry)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("versionFromEntry(%q) = %q, %v; want %q, %v", tt.entry, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if dir, err := l.dir("v1.4.2"); err != nil || dir != "apps/myapp-v1.4.2-linux" {
+		t.Errorf("dir() = %q, %v", dir, err)
+	}
+}
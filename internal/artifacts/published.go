@@ -0,0 +1,86 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/publish"
+)
+
+// PublishedManifest returns the build manifest published for version on
+// channel to blob. Versions published without one (e.g. before gcx published
+// artifacts.json) yield a manifest built from the remote listing, holding
+// only names and sizes; complete is false then.
+func PublishedManifest(ctx context.Context, blob config.BlobConfig, channel, version string) (m *manifest.Manifest, complete bool, err error) {
+	l, err := parseLayout(blob.Directory, channel)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fetcher, err := publish.NewFetcher(blob)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = fetcher.Close() }()
+
+	return publishedManifest(ctx, fetcher, l, blob, version)
+}
+
+func publishedManifest(ctx context.Context, fetcher publish.Fetcher, l layout, blob config.BlobConfig, version string) (*manifest.Manifest, bool, error) {
+	files, err := versionFiles(ctx, fetcher, l, blob, version)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, f := range files {
+		if f.Name != manifest.FileName {
+			continue
+		}
+		m, err := downloadManifest(ctx, fetcher, f.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		// Versions sharing a directory overwrite each other's manifest
+		if m.Version == version {
+			return m, true, nil
+		}
+		log.Printf("Warning: %s in %q belongs to %s, not %s", manifest.FileName, blob.Name, m.Version, version)
+	}
+
+	remoteDir, err := l.dir(version)
+	if err != nil {
+		return nil, false, err
+	}
+	m := &manifest.Manifest{Version: version, Source: sourceURL(blob, remoteDir)}
+	for _, f := range files {
+		if f.Name == manifest.FileName {
+			continue
+		}
+		m.Artifacts = append(m.Artifacts, manifest.Artifact{
+			Name:   f.Name,
+			Type:   manifest.TypeFromName(f.Name),
+			Size:   f.Size,
+			Remote: f.Path,
+		})
+	}
+	return m, false, nil
+}
+
+func downloadManifest(ctx context.Context, fetcher publish.Fetcher, remotePath string) (*manifest.Manifest, error) {
+	dir, err := os.MkdirTemp("", "gcx-manifest-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	localPath := filepath.Join(dir, manifest.FileName)
+	if err := fetcher.Download(ctx, remotePath, localPath); err != nil {
+		return nil, err
+	}
+	return manifest.Load(localPath)
+}
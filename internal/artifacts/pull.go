@@ -0,0 +1,206 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/publish"
+)
+
+// FindBlob returns the blob configuration with the given name.
+func FindBlob(cfg *config.Config, name string) (config.BlobConfig, error) {
+	for _, blob := range cfg.Blobs {
+		if blob.Name == name {
+			return blob, nil
+		}
+	}
+	return config.BlobConfig{}, fmt.Errorf("publish configuration %q not found", name)
+}
+
+// Pull downloads the artifacts published for version on channel from blob
+// into outDir, verifies them against a published checksums file when
+// present and writes an artifacts.json describing what was fetched.
+func Pull(ctx context.Context, blob config.BlobConfig, channel, version, outDir string) (*manifest.Manifest, error) {
+	if version == "" {
+		return nil, fmt.Errorf("version is required")
+	}
+
+	l, err := parseLayout(blob.Directory, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, err := publish.NewFetcher(blob)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fetcher.Close() }()
+
+	return pull(ctx, fetcher, l, blob, version, outDir)
+}
+
+func pull(ctx context.Context, fetcher publish.Fetcher, l layout, blob config.BlobConfig, version, outDir string) (*manifest.Manifest, error) {
+	remoteDir, err := l.dir(version)
+	if err != nil {
+		return nil, err
+	}
+	all, err := versionFiles(ctx, fetcher, l, blob, version)
+	if err != nil {
+		return nil, err
+	}
+	// The published build manifest describes the build machine; pull writes its own
+	files := slices.DeleteFunc(all, func(f publish.RemoteFile) bool { return f.Name == manifest.FileName })
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create output directory: %w", err)
+	}
+
+	m := &manifest.Manifest{
+		Version: version,
+		Source:  sourceURL(blob, remoteDir),
+	}
+
+	sums := make(map[string]string)
+	for _, f := range files {
+		localPath := filepath.Join(outDir, f.Name)
+		log.Printf("Downloading %s to %s", f.Path, localPath)
+		if err := fetcher.Download(ctx, f.Path, localPath); err != nil {
+			return nil, err
+		}
+		if manifest.IsChecksumFile(f.Name) {
+			parsed, err := parseChecksumFile(localPath)
+			if err != nil {
+				return nil, err
+			}
+			// Names recorded under checksum.path_prefix match the
+			// files downloaded into outDir by their base name
+			for name, sum := range parsed {
+				sums[path.Base(name)] = sum
+			}
+		}
+	}
+
+	for _, f := range files {
+		localPath := filepath.Join(outDir, f.Name)
+		artifact := manifest.Artifact{
+			Name:   f.Name,
+			Path:   localPath,
+			Type:   manifest.TypeFromName(f.Name),
+			Size:   f.Size,
+			Remote: f.Path,
+		}
+
+		if expected, ok := sums[f.Name]; ok {
+			if err := checksum.Verify(localPath, expected); err != nil {
+				return nil, fmt.Errorf("verify %s: %w", f.Name, err)
+			}
+			artifact.Verified = true
+		}
+
+		digest, err := checksum.File(localPath)
+		if err != nil {
+			return nil, fmt.Errorf("checksum %s: %w", f.Name, err)
+		}
+		artifact.Size = digest.Size
+		artifact.SHA256 = digest.SHA256Hex()
+
+		m.Artifacts = append(m.Artifacts, artifact)
+	}
+
+	if len(sums) == 0 {
+		log.Printf("Warning: no checksums file found for version %s, artifacts were not verified", version)
+	}
+
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// versionFiles lists the files published for version and fails with the
+// available versions when there are none.
+func versionFiles(ctx context.Context, fetcher publish.Fetcher, l layout, blob config.BlobConfig, version string) ([]publish.RemoteFile, error) {
+	remoteDir, err := l.dir(version)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fetcher.List(ctx, remoteDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []publish.RemoteFile
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		// Without {{.Version}} in the directory, versions share one directory
+		// and are told apart by file name.
+		if !l.hasVersion && !strings.Contains(e.Name, version) && !manifest.IsChecksumFile(e.Name) && e.Name != manifest.FileName {
+			continue
+		}
+		files = append(files, e)
+	}
+
+	if len(files) == 0 || (len(files) == 1 && files[0].Name == manifest.FileName) {
+		available, err := availableVersions(ctx, fetcher, l)
+		if err != nil {
+			return nil, fmt.Errorf("version %s not found in %q (listing versions failed: %w)", version, blob.Name, err)
+		}
+		if len(available) == 0 {
+			return nil, fmt.Errorf("version %s not found in %q: no versions published under %q", version, blob.Name, l.parent)
+		}
+		return nil, fmt.Errorf("version %s not found in %q; available versions: %s", version, blob.Name, strings.Join(available, ", "))
+	}
+	return files, nil
+}
+
+func parseChecksumFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open checksums file: %w", err)
+	}
+	defer func() {
+		_ = f.Close() // read-only, safe to ignore
+	}()
+
+	sums, err := checksum.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return sums, nil
+}
+
+// availableVersions lists the version names published under the layout's parent directory.
+func availableVersions(ctx context.Context, fetcher publish.Fetcher, l layout) ([]string, error) {
+	infos, err := listVersions(ctx, fetcher, l)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(infos))
+	for _, info := range infos {
+		versions = append(versions, info.Version)
+	}
+	return versions, nil
+}
+
+func sourceURL(blob config.BlobConfig, dir string) string {
+	switch blob.Provider {
+	case "s3":
+		return "s3://" + blob.Bucket + "/" + strings.TrimPrefix(dir, "/")
+	case "ssh":
+		return "ssh://" + blob.Server + "/" + strings.TrimPrefix(dir, "/")
+	default:
+		return dir
+	}
+}
@@ -0,0 +1,235 @@
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/publish"
+)
+
+// versionInNameRegex finds a semver-looking version inside a file name. It is
+// used when the directory template does not contain {{.Version}}.
+var versionInNameRegex = regexp.MustCompile(`v?\d+\.\d+\.\d+(?:-[0-9A-Za-z.]+)?`)
+
+// VersionInfo describes one version published to a blob.
+type VersionInfo struct {
+	Version  string    `json:"version"`
+	Path     string    `json:"path"`
+	Files    int       `json:"files"`
+	Size     int64     `json:"size"`
+	Uploaded time.Time `json:"uploaded,omitzero"`
+}
+
+// Versions lists the versions published to blob on channel, sorted by semver
+// (oldest first).
+func Versions(ctx context.Context, blob config.BlobConfig, channel string) ([]VersionInfo, error) {
+	l, err := parseLayout(blob.Directory, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, err := publish.NewFetcher(blob)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fetcher.Close() }()
+
+	return listVersions(ctx, fetcher, l)
+}
+
+func listVersions(ctx context.Context, fetcher publish.Fetcher, l layout) ([]VersionInfo, error) {
+	entries, err := fetcher.List(ctx, l.parent)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*VersionInfo)
+	if l.hasVersion {
+		for _, e := range entries {
+			v, ok := l.versionFromEntry(e.Name)
+			if !ok {
+				continue
+			}
+			dir, err := l.dir(v)
+			if err != nil {
+				return nil, err
+			}
+			files, err := fetcher.List(ctx, dir)
+			if err != nil {
+				return nil, err
+			}
+			info := &VersionInfo{Version: v, Path: dir}
+			for _, f := range files {
+				info.add(f)
+			}
+			byVersion[v] = info
+		}
+	} else {
+		// All versions share one directory; group files by the version in their name.
+		for _, e := range entries {
+			v := versionInNameRegex.FindString(e.Name)
+			if v == "" || e.IsDir {
+				continue
+			}
+			info, ok := byVersion[v]
+			if !ok {
+				info = &VersionInfo{Version: v, Path: l.parent}
+				byVersion[v] = info
+			}
+			info.add(e)
+		}
+	}
+
+	versions := make([]VersionInfo, 0, len(byVersion))
+	for _, info := range byVersion {
+		versions = append(versions, *info)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i].Version, versions[j].Version) < 0
+	})
+	return versions, nil
+}
+
+func (v *VersionInfo) add(f publish.RemoteFile) {
+	if f.IsDir {
+		return
+	}
+	v.Files++
+	v.Size += f.Size
+	if f.ModTime.After(v.Uploaded) {
+		v.Uploaded = f.ModTime
+	}
+}
+
+// WriteVersionsTable prints versions as an aligned table.
+func WriteVersionsTable(w io.Writer, versions []VersionInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tFILES\tSIZE\tUPLOADED")
+	for _, v := range versions {
+		uploaded := "-"
+		if !v.Uploaded.IsZero() {
+			uploaded = v.Uploaded.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", v.Version, v.Files, helpers.FormatBytes(v.Size), uploaded)
+	}
+	return tw.Flush()
+}
+
+// WriteVersionsJSON prints versions as indented JSON.
+func WriteVersionsJSON(w io.Writer, versions []VersionInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(versions); err != nil {
+		return fmt.Errorf("encode versions: %w", err)
+	}
+	return nil
+}
+
+// compareVersions orders versions by semver precedence. Versions that are not
+// semver sort before all semver versions, lexically among themselves.
+func compareVersions(a, b string) int {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	switch {
+	case !okA && !okB:
+		return strings.Compare(a, b)
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := range 3 {
+		if va.core[i] != vb.core[i] {
+			if va.core[i] < vb.core[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(va.pre, vb.pre)
+}
+
+type semver struct {
+	core [3]int
+	pre  []string
+}
+
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+") // build metadata does not affect precedence
+	core, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var v semver
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v.core[i] = n
+	}
+	if hasPre {
+		if pre == "" {
+			return semver{}, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// comparePrerelease implements semver rule 11: a release has higher precedence
+// than any of its pre-releases, numeric identifiers compare numerically and
+// sort before alphanumeric ones.
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
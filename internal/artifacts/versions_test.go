@@ -0,0 +1,269 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/publish"
+)
+
+// memFetcher serves files from memory, keyed by full remote path.
+type memFetcher struct {
+	files map[string]string
+	mod   time.Time
+}
+
+func (f *memFetcher) Name() string { return "mem" }
+
+func (f *memFetcher) List(_ context.Context, dir string) ([]publish.RemoteFile, error) {
+	dir = strings.Trim(dir, "/")
+	seen := make(map[string]bool)
+	var out []publish.RemoteFile
+	for p, content := range f.files {
+		rel := p
+		if dir != "" {
+			if !strings.HasPrefix(p, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, dir+"/")
+		}
+		name, _, isDir := strings.Cut(rel, "/")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		rf := publish.RemoteFile{Name: name, Path: path.Join(dir, name), IsDir: isDir, ModTime: f.mod}
+		if !isDir {
+			rf.Size = int64(len(content))
+		}
+		out = append(out, rf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (f *memFetcher) Download(_ context.Context, remotePath, localPath string) error {
+	return os.WriteFile(localPath, []byte(f.files[remotePath]), 0o644)
+}
+
+func (f *memFetcher) Close() error { return nil }
+
+func TestListVersions(t *testing.T) {
+	mod := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("version directory", func(t *testing.T) {
+		fetcher := &memFetcher{mod: mod, files: map[string]string{
+			"releases/app-v1.10.0/bin/app.tar.gz":     "aaaa",
+			"releases/app-v1.2.0/bin/app.tar.gz":      "bb",
+			"releases/app-v1.2.0/bin/checksums.txt":   "c",
+			"releases/app-v1.2.0-rc.1/bin/app.tar.gz": "d",
+			"releases/other/readme.txt":               "x",
+		}}
+		l, err := parseLayout("releases/app-{{.Version}}/bin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions, err := listVersions(context.Background(), fetcher, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, v := range versions {
+			names = append(names, v.Version)
+		}
+		if got, want := strings.Join(names, ","), "v1.2.0-rc.1,v1.2.0,v1.10.0"; got != want {
+			t.Fatalf("versions = %s, want %s", got, want)
+		}
+		if v := versions[1]; v.Files != 2 || v.Size != 3 || !v.Uploaded.Equal(mod) || v.Path != "releases/app-v1.2.0/bin" {
+			t.Errorf("unexpected info for v1.2.0: %+v", v)
+		}
+	})
+
+	t.Run("shared directory", func(t *testing.T) {
+		fetcher := &memFetcher{mod: mod, files: map[string]string{
+			"releases/app_v1.0.0_linux_amd64.tar.gz":  "aa",
+			"releases/app_v1.0.0_darwin_arm64.tar.gz": "bbb",
+			"releases/app_v0.9.1_linux_amd64.tar.gz":  "c",
+			"releases/checksums.txt":                  "x",
+		}}
+		l, err := parseLayout("releases", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions, err := listVersions(context.Background(), fetcher, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 2 || versions[0].Version != "v0.9.1" || versions[1].Version != "v1.0.0" {
+			t.Fatalf("unexpected versions: %+v", versions)
+		}
+		if versions[1].Files != 2 || versions[1].Size != 5 {
+			t.Errorf("unexpected info for v1.0.0: %+v", versions[1])
+		}
+	})
+}
+
+func TestCompareVersions(t *testing.T) {
+	ordered := []string{
+		"latest",
+		"v0.9.0",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"1.0.1",
+		"v1.10.0",
+	}
+	for i := 0; i < len(ordered)-1; i++ {
+		a, b := ordered[i], ordered[i+1]
+		if compareVersions(a, b) >= 0 || compareVersions(b, a) <= 0 {
+			t.Errorf("expected %s < %s", a, b)
+		}
+	}
+	if compareVersions("v1.0.0+build.1", "v1.0.0") != 0 {
+		t.Error("build metadata must not affect precedence")
+	}
+}
+
+func TestWriteVersions(t *testing.T) {
+	versions := []VersionInfo{
+		{Version: "v1.0.0", Files: 2, Size: 1536, Uploaded: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Version: "v1.1.0", Files: 1, Size: 10},
+	}
+
+	var table bytes.Buffer
+	if err := WriteVersionsTable(&table, versions); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", table.String())
+	}
+	if !strings.Contains(lines[1], "1.5 KiB") || !strings.Contains(lines[1], "2026-01-02T03:04:05Z") {
+		t.Errorf("unexpected row: %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "10 B") || !strings.HasSuffix(lines[2], "-") {
+		t.Errorf("unexpected row: %q", lines[2])
+	}
+
+	var out bytes.Buffer
+	if err := WriteVersionsJSON(&out, versions); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0]["version"] != "v1.0.0" {
+		t.Errorf("unexpected JSON: %s", out.String())
+	}
+	if _, ok := decoded[1]["uploaded"]; ok {
+		t.Errorf("zero upload time should be omitted: %s", out.String())
+	}
+}
+
+func TestPull(t *testing.T) {
+	fetcher := &memFetcher{files: map[string]string{
+		"releases/v1.0.0/app.tar.gz":    "hello world",
+		"releases/v1.0.0/checksums.txt": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9  app.tar.gz\n",
+		"releases/v0.9.0/app.tar.gz":    "old",
+	}}
+	l, err := parseLayout("releases/{{.Version}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := config.BlobConfig{Name: "s3", Provider: "s3", Bucket: "bucket"}
+
+	t.Run("verified download", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := pull(context.Background(), fetcher, l, blob, "v1.0.0", dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Source != "s3://bucket/releases/v1.0.0" || len(m.Artifacts) != 2 {
+			t.Fatalf("unexpected manifest: %+v", m)
+		}
+		for _, a := range m.Artifacts {
+			if a.Name == "app.tar.gz" && (!a.Verified || a.Type != manifest.TypeArchive) {
+				t.Errorf("unexpected artifact: %+v", a)
+			}
+		}
+		if _, err := manifest.Load(filepath.Join(dir, manifest.FileName)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		bad := &memFetcher{files: map[string]string{
+			"releases/v1.0.0/app.tar.gz":    "tampered",
+			"releases/v1.0.0/checksums.txt": fetcher.files["releases/v1.0.0/checksums.txt"],
+		}}
+		if _, err := pull(context.Background(), bad, l, blob, "v1.0.0", t.TempDir()); err == nil {
+			t.Error("expected checksum mismatch error")
+		}
+	})
+
+	t.Run("missing version lists available ones", func(t *testing.T) {
+		_, err := pull(context.Background(), fetcher, l, blob, "v2.0.0", t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "v0.9.0, v1.0.0") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestPublishedManifest(t *testing.T) {
+	blob := config.BlobConfig{Name: "mem", Directory: "releases/{{.Version}}"}
+	l, err := parseLayout(blob.Directory, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("manifest published", func(t *testing.T) {
+		fetcher := &memFetcher{files: map[string]string{
+			"releases/v1.0.0/app_v1.0.0_linux_amd64.tar.gz": "archive",
+			"releases/v1.0.0/artifacts.json":                `{"version":"v1.0.0","go_version":"1.22.3","artifacts":[{"name":"app_v1.0.0_linux_amd64.tar.gz","type":"archive","goos":"linux","goarch":"amd64","size":7}]}`,
+		}}
+		m, complete, err := publishedManifest(context.Background(), fetcher, l, blob, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !complete || m.GoVersion != "1.22.3" || len(m.Artifacts) != 1 || m.Artifacts[0].Goos != "linux" {
+			t.Errorf("got complete=%v %+v", complete, m)
+		}
+	})
+
+	t.Run("listing only", func(t *testing.T) {
+		fetcher := &memFetcher{files: map[string]string{
+			"releases/v1.0.0/app_v1.0.0_linux_amd64.tar.gz": "archive",
+			"releases/v1.0.0/checksums.txt":                 "sums",
+		}}
+		m, complete, err := publishedManifest(context.Background(), fetcher, l, blob, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if complete || len(m.Artifacts) != 2 || m.Artifacts[0].Size != 7 || m.Artifacts[0].Type != manifest.TypeArchive {
+			t.Errorf("got complete=%v %+v", complete, m)
+		}
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		fetcher := &memFetcher{files: map[string]string{"releases/v1.0.0/app": "x"}}
+		if _, _, err := publishedManifest(context.Background(), fetcher, l, blob, "v0.9.0"); err == nil || !strings.Contains(err.Error(), "available versions: v1.0.0") {
+			t.Errorf("error = %v", err)
+		}
+	})
+}
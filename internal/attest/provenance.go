@@ -0,0 +1,109 @@
+package attest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	statementType     = "https://in-toto.io/Statement/v1"
+	provenanceType    = "https://slsa.dev/provenance/v1"
+	workflowBuildType = "https://actions.github.io/buildtypes/workflow/v1"
+)
+
+// Statement is an in-toto statement.
+type Statement struct {
+	Type          string    `json:"_type"`
+	Subject       []Subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     any       `json:"predicate"`
+}
+
+// Claims are the GitHub Actions OIDC token claims the provenance is built
+// from. Taking them from the signed token instead of the environment keeps
+// the provenance consistent with the signing certificate.
+type Claims struct {
+	Subject           string `json:"sub"`
+	Repository        string `json:"repository"`
+	RepositoryID      string `json:"repository_id"`
+	RepositoryOwnerID string `json:"repository_owner_id"`
+	Ref               string `json:"ref"`
+	SHA               string `json:"sha"`
+	EventName         string `json:"event_name"`
+	WorkflowRef       string `json:"workflow_ref"`
+	JobWorkflowRef    string `json:"job_workflow_ref"`
+	RunID             string `json:"run_id"`
+	RunAttempt        string `json:"run_attempt"`
+	RunnerEnvironment string `json:"runner_environment"`
+}
+
+// parseClaims decodes the payload of a JWT without verifying it; Fulcio
+// verifies the token when it issues the certificate.
+func parseClaims(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("OIDC token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, fmt.Errorf("decode OIDC token: %w", err)
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Claims{}, fmt.Errorf("parse OIDC token claims: %w", err)
+	}
+	if c.Subject == "" || c.Repository == "" || c.WorkflowRef == "" {
+		return Claims{}, fmt.Errorf("OIDC token is not a GitHub Actions token")
+	}
+	return c, nil
+}
+
+// Provenance returns the SLSA provenance statement for subjects built by
+// the workflow run described by claims, in the layout of
+// actions/attest-build-provenance.
+func Provenance(subjects []Subject, claims Claims, serverURL string) Statement {
+	serverURL = strings.TrimSuffix(serverURL, "/")
+	repoURL := serverURL + "/" + claims.Repository
+
+	// workflow_ref is owner/repo/.github/workflows/file.yml@ref
+	workflowPath, _, _ := strings.Cut(strings.TrimPrefix(claims.WorkflowRef, claims.Repository+"/"), "@")
+
+	predicate := map[string]any{
+		"buildDefinition": map[string]any{
+			"buildType": workflowBuildType,
+			"externalParameters": map[string]any{
+				"workflow": map[string]string{
+					"ref":        claims.Ref,
+					"repository": repoURL,
+					"path":       workflowPath,
+				},
+			},
+			"internalParameters": map[string]any{
+				"github": map[string]string{
+					"event_name":          claims.EventName,
+					"repository_id":       claims.RepositoryID,
+					"repository_owner_id": claims.RepositoryOwnerID,
+					"runner_environment":  claims.RunnerEnvironment,
+				},
+			},
+			"resolvedDependencies": []map[string]any{{
+				"uri":    "git+" + repoURL + "@" + claims.Ref,
+				"digest": map[string]string{"gitCommit": claims.SHA},
+			}},
+		},
+		"runDetails": map[string]any{
+			"builder": map[string]string{"id": serverURL + "/" + claims.JobWorkflowRef},
+			"metadata": map[string]string{
+				"invocationId": fmt.Sprintf("%s/actions/runs/%s/attempts/%s", repoURL, claims.RunID, claims.RunAttempt),
+			},
+		},
+	}
+	return Statement{
+		Type:          statementType,
+		Subject:       subjects,
+		PredicateType: provenanceType,
+		Predicate:     predicate,
+	}
+}
@@ -0,0 +1,424 @@
+package attest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Public-good Sigstore instances. GitHub signs attestations of private
+// repositories with its own instance, which is not supported.
+const (
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+	DefaultRekorURL  = "https://rekor.sigstore.dev"
+)
+
+const (
+	bundleMediaType   = "application/vnd.dev.sigstore.bundle.v0.3+json"
+	inTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// Env is the GitHub Actions environment needed to push attestations.
+type Env struct {
+	// Token is a GitHub token with the attestations: write permission.
+	Token string
+	// TokenRequestURL and TokenRequestToken request the OIDC token; they
+	// are only set for jobs with the id-token: write permission.
+	TokenRequestURL   string
+	TokenRequestToken string
+	// Repository is owner/name.
+	Repository string
+	APIURL     string
+	ServerURL  string
+}
+
+// LoadEnv reads the Actions environment through getenv and names every
+// missing variable.
+func LoadEnv(getenv func(string) string) (Env, error) {
+	env := Env{
+		Token:             getenv("GITHUB_TOKEN"),
+		TokenRequestURL:   getenv("ACTIONS_ID_TOKEN_REQUEST_URL"),
+		TokenRequestToken: getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"),
+		Repository:        getenv("GITHUB_REPOSITORY"),
+		APIURL:            getenv("GITHUB_API_URL"),
+		ServerURL:         getenv("GITHUB_SERVER_URL"),
+	}
+	if env.APIURL == "" {
+		env.APIURL = "https://api.github.com"
+	}
+	if env.ServerURL == "" {
+		env.ServerURL = "https://github.com"
+	}
+
+	var missing []string
+	for _, v := range []struct{ name, value string }{
+		{"GITHUB_TOKEN", env.Token},
+		{"ACTIONS_ID_TOKEN_REQUEST_URL", env.TokenRequestURL},
+		{"ACTIONS_ID_TOKEN_REQUEST_TOKEN", env.TokenRequestToken},
+		{"GITHUB_REPOSITORY", env.Repository},
+	} {
+		if v.value == "" {
+			missing = append(missing, v.name)
+		}
+	}
+	if len(missing) > 0 {
+		return env, fmt.Errorf("pushing attestations needs a GitHub Actions job with id-token: write and attestations: write; missing %s",
+			strings.Join(missing, ", "))
+	}
+	return env, nil
+}
+
+// Pusher signs build provenance with a short-lived Sigstore certificate
+// for the workflow identity and uploads it to the GitHub attestations API.
+type Pusher struct {
+	Env       Env
+	FulcioURL string
+	RekorURL  string
+	Client    *http.Client
+}
+
+// NewPusher creates a Pusher for the public-good Sigstore instance.
+func NewPusher(env Env) *Pusher {
+	return &Pusher{
+		Env:       env,
+		FulcioURL: DefaultFulcioURL,
+		RekorURL:  DefaultRekorURL,
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Push attests the provenance of subjects and returns the URL of the
+// attestation on GitHub.
+func (p *Pusher) Push(ctx context.Context, subjects []Subject) (string, error) {
+	token, err := p.idToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	claims, err := parseClaims(token)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("generate signing key: %w", err)
+	}
+	certPEM, err := p.certificate(ctx, key, token, claims.Subject)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return "", fmt.Errorf("fulcio returned an invalid certificate")
+	}
+
+	payload, err := json.Marshal(Provenance(subjects, claims, p.Env.ServerURL))
+	if err != nil {
+		return "", fmt.Errorf("encode provenance: %w", err)
+	}
+	dsse, err := signEnvelope(key, payload)
+	if err != nil {
+		return "", err
+	}
+	entry, err := p.logEntry(ctx, dsse, certPEM)
+	if err != nil {
+		return "", err
+	}
+	b, err := newBundle(block.Bytes, dsse, entry)
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	url := fmt.Sprintf("%s/repos/%s/attestations", strings.TrimSuffix(p.Env.APIURL, "/"), p.Env.Repository)
+	header := http.Header{
+		"Authorization":        {"Bearer " + p.Env.Token},
+		"Accept":               {"application/vnd.github+json"},
+		"X-Github-Api-Version": {"2022-11-28"},
+	}
+	if err := p.do(ctx, http.MethodPost, url, header, map[string]any{"bundle": b}, &created); err != nil {
+		return "", fmt.Errorf("upload attestation: %w", err)
+	}
+	return fmt.Sprintf("%s/%s/attestations/%d", strings.TrimSuffix(p.Env.ServerURL, "/"), p.Env.Repository, created.ID), nil
+}
+
+// idToken requests an OIDC token for the sigstore audience.
+func (p *Pusher) idToken(ctx context.Context) (string, error) {
+	sep := "?"
+	if strings.Contains(p.Env.TokenRequestURL, "?") {
+		sep = "&"
+	}
+	var resp struct {
+		Value string `json:"value"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + p.Env.TokenRequestToken}}
+	if err := p.do(ctx, http.MethodGet, p.Env.TokenRequestURL+sep+"audience=sigstore", header, nil, &resp); err != nil {
+		return "", fmt.Errorf("request OIDC token: %w", err)
+	}
+	if resp.Value == "" {
+		return "", fmt.Errorf("request OIDC token: empty token")
+	}
+	return resp.Value, nil
+}
+
+// certificate asks Fulcio for a certificate of key bound to the token
+// identity and returns the PEM leaf certificate.
+func (p *Pusher) certificate(ctx context.Context, key *ecdsa.PrivateKey, token, subject string) (string, error) {
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("encode public key: %w", err)
+	}
+	// Fulcio checks possession of the key with a signature of the subject
+	digest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign proof of possession: %w", err)
+	}
+
+	req := map[string]any{
+		"credentials": map[string]string{"oidcIdentityToken": token},
+		"publicKeyRequest": map[string]any{
+			"publicKey": map[string]string{
+				"algorithm": "ECDSA",
+				"content":   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})),
+			},
+			"proofOfPossession": base64.StdEncoding.EncodeToString(proof),
+		},
+	}
+	type chain struct {
+		Chain struct {
+			Certificates []string `json:"certificates"`
+		} `json:"chain"`
+	}
+	var resp struct {
+		Embedded *chain `json:"signedCertificateEmbeddedSct"`
+		Detached *chain `json:"signedCertificateDetachedSct"`
+	}
+	url := strings.TrimSuffix(p.FulcioURL, "/") + "/api/v2/signingCert"
+	if err := p.do(ctx, http.MethodPost, url, nil, req, &resp); err != nil {
+		return "", fmt.Errorf("request signing certificate: %w", err)
+	}
+	c := resp.Embedded
+	if c == nil {
+		c = resp.Detached
+	}
+	if c == nil || len(c.Chain.Certificates) == 0 {
+		return "", fmt.Errorf("request signing certificate: empty certificate chain")
+	}
+	return c.Chain.Certificates[0], nil
+}
+
+// envelope is a DSSE envelope.
+type envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []signature `json:"signatures"`
+}
+
+type signature struct {
+	Sig string `json:"sig"`
+}
+
+// signEnvelope signs payload as an in-toto DSSE envelope.
+func signEnvelope(key *ecdsa.PrivateKey, payload []byte) (envelope, error) {
+	digest := sha256.Sum256(pae(inTotoPayloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return envelope{}, fmt.Errorf("sign provenance: %w", err)
+	}
+	return envelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []signature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// pae is the DSSE pre-authentication encoding.
+func pae(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
+
+// rekorEntry is a transparency log entry as returned by Rekor.
+type rekorEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		InclusionProof *struct {
+			Checkpoint string   `json:"checkpoint"`
+			Hashes     []string `json:"hashes"`
+			LogIndex   int64    `json:"logIndex"`
+			RootHash   string   `json:"rootHash"`
+			TreeSize   int64    `json:"treeSize"`
+		} `json:"inclusionProof"`
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// logEntry records the signed envelope in the Rekor transparency log.
+func (p *Pusher) logEntry(ctx context.Context, env envelope, certPEM string) (rekorEntry, error) {
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return rekorEntry{}, fmt.Errorf("encode envelope: %w", err)
+	}
+	req := map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]any{
+			"proposedContent": map[string]any{
+				"envelope":  string(envJSON),
+				"verifiers": []string{base64.StdEncoding.EncodeToString([]byte(certPEM))},
+			},
+		},
+	}
+	var resp map[string]rekorEntry
+	url := strings.TrimSuffix(p.RekorURL, "/") + "/api/v1/log/entries"
+	if err := p.do(ctx, http.MethodPost, url, nil, req, &resp); err != nil {
+		return rekorEntry{}, fmt.Errorf("upload to transparency log: %w", err)
+	}
+	for _, entry := range resp {
+		if entry.Verification.InclusionProof == nil {
+			return rekorEntry{}, fmt.Errorf("upload to transparency log: entry has no inclusion proof")
+		}
+		return entry, nil
+	}
+	return rekorEntry{}, fmt.Errorf("upload to transparency log: empty response")
+}
+
+// bundle is a Sigstore bundle in its protobuf JSON form.
+type bundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		Certificate struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificate"`
+		TlogEntries []tlogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope envelope `json:"dsseEnvelope"`
+}
+
+type tlogEntry struct {
+	LogIndex int64 `json:"logIndex,string"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	KindVersion struct {
+		Kind    string `json:"kind"`
+		Version string `json:"version"`
+	} `json:"kindVersion"`
+	IntegratedTime   int64 `json:"integratedTime,string"`
+	InclusionPromise struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	InclusionProof struct {
+		LogIndex   int64    `json:"logIndex,string"`
+		RootHash   string   `json:"rootHash"`
+		TreeSize   int64    `json:"treeSize,string"`
+		Hashes     []string `json:"hashes"`
+		Checkpoint struct {
+			Envelope string `json:"envelope"`
+		} `json:"checkpoint"`
+	} `json:"inclusionProof"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// newBundle combines the certificate, the envelope and its log entry.
+// Rekor reports hashes and the log ID in hex, bundles use base64.
+func newBundle(certDER []byte, env envelope, entry rekorEntry) (bundle, error) {
+	var b bundle
+	b.MediaType = bundleMediaType
+	b.VerificationMaterial.Certificate.RawBytes = base64.StdEncoding.EncodeToString(certDER)
+	b.DSSEEnvelope = env
+
+	var t tlogEntry
+	t.LogIndex = entry.LogIndex
+	t.KindVersion.Kind = "dsse"
+	t.KindVersion.Version = "0.0.1"
+	t.IntegratedTime = entry.IntegratedTime
+	t.InclusionPromise.SignedEntryTimestamp = entry.Verification.SignedEntryTimestamp
+	t.CanonicalizedBody = entry.Body
+
+	proof := entry.Verification.InclusionProof
+	t.InclusionProof.LogIndex = proof.LogIndex
+	t.InclusionProof.TreeSize = proof.TreeSize
+	t.InclusionProof.Checkpoint.Envelope = proof.Checkpoint
+	var err error
+	if t.LogID.KeyID, err = hexToBase64(entry.LogID); err != nil {
+		return bundle{}, fmt.Errorf("log ID: %w", err)
+	}
+	if t.InclusionProof.RootHash, err = hexToBase64(proof.RootHash); err != nil {
+		return bundle{}, fmt.Errorf("root hash: %w", err)
+	}
+	t.InclusionProof.Hashes = make([]string, len(proof.Hashes))
+	for i, h := range proof.Hashes {
+		if t.InclusionProof.Hashes[i], err = hexToBase64(h); err != nil {
+			return bundle{}, fmt.Errorf("inclusion proof hash: %w", err)
+		}
+	}
+
+	b.VerificationMaterial.TlogEntries = []tlogEntry{t}
+	return b, nil
+}
+
+func hexToBase64(s string) (string, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// do sends body as JSON and decodes the JSON response into out.
+func (p *Pusher) do(ctx context.Context, method, url string, header http.Header, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for name, values := range header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package attest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testClaims = Claims{
+	Subject:           "repo:acme/app:ref:refs/tags/v1.0.0",
+	Repository:        "acme/app",
+	RepositoryID:      "1",
+	RepositoryOwnerID: "2",
+	Ref:               "refs/tags/v1.0.0",
+	SHA:               "0123456789abcdef0123456789abcdef01234567",
+	EventName:         "push",
+	WorkflowRef:       "acme/app/.github/workflows/release.yml@refs/tags/v1.0.0",
+	JobWorkflowRef:    "acme/app/.github/workflows/release.yml@refs/tags/v1.0.0",
+	RunID:             "42",
+	RunAttempt:        "1",
+	RunnerEnvironment: "github-hosted",
+}
+
+func testJWT(t *testing.T, claims Claims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString(payload) + ".c2ln"
+}
+
+// fakeSigstore serves the OIDC token, Fulcio, Rekor and the GitHub
+// attestations API, checking every signature it receives.
+func fakeSigstore(t *testing.T) (*httptest.Server, *json.RawMessage) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaf *x509.Certificate
+	uploaded := new(json.RawMessage)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != "sigstore" {
+			http.Error(w, "bad token request", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": testJWT(t, testClaims)})
+	})
+	mux.HandleFunc("POST /api/v2/signingCert", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PublicKeyRequest struct {
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+				ProofOfPossession []byte `json:"proofOfPossession"`
+			} `json:"publicKeyRequest"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		block, _ := pem.Decode([]byte(req.PublicKeyRequest.PublicKey.Content))
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		digest := sha256.Sum256([]byte(testClaims.Subject))
+		if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], req.PublicKeyRequest.ProofOfPossession) {
+			http.Error(w, "bad proof of possession", http.StatusBadRequest)
+			return
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "sigstore"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(10 * time.Minute),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		leaf, _ = x509.ParseCertificate(der)
+		certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"signedCertificateEmbeddedSct": map[string]any{"chain": map[string]any{"certificates": []string{certPEM}}},
+		})
+	})
+	mux.HandleFunc("POST /api/v1/log/entries", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Spec struct {
+				ProposedContent struct {
+					Envelope string `json:"envelope"`
+				} `json:"proposedContent"`
+			} `json:"spec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var env struct {
+			PayloadType string `json:"payloadType"`
+			Payload     []byte `json:"payload"`
+			Signatures  []struct {
+				Sig []byte `json:"sig"`
+			} `json:"signatures"`
+		}
+		if err := json.Unmarshal([]byte(req.Spec.ProposedContent.Envelope), &env); err != nil || len(env.Signatures) != 1 {
+			http.Error(w, "bad envelope", http.StatusBadRequest)
+			return
+		}
+		digest := sha256.Sum256(pae(env.PayloadType, env.Payload))
+		if !ecdsa.VerifyASN1(leaf.PublicKey.(*ecdsa.PublicKey), digest[:], env.Signatures[0].Sig) {
+			http.Error(w, "bad envelope signature", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"uuid1": {"body": "Ym9keQ==", "integratedTime": 1700000000, "logID": "c0ffee",
+			"logIndex": 123456, "verification": {"signedEntryTimestamp": "c2V0",
+			"inclusionProof": {"checkpoint": "rekor.sigstore.dev - 1\n", "hashes": ["aa", "bb"], "logIndex": 456,
+			"rootHash": "cc", "treeSize": 1000}}}}`))
+	})
+	mux.HandleFunc("POST /repos/acme/app/attestations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Bundle json.RawMessage `json:"bundle"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*uploaded = req.Bundle
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 7}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, uploaded
+}
+
+func TestPush(t *testing.T) {
+	srv, uploaded := fakeSigstore(t)
+	p := NewPusher(Env{
+		Token:             "gh-token",
+		TokenRequestURL:   srv.URL + "/token?api-version=2.0",
+		TokenRequestToken: "request-token",
+		Repository:        "acme/app",
+		APIURL:            srv.URL,
+		ServerURL:         "https://github.com",
+	})
+	p.FulcioURL, p.RekorURL = srv.URL, srv.URL
+
+	subjects := []Subject{{Name: "app_v1.0.0_linux_amd64.tar.gz", Digest: map[string]string{"sha256": strings.Repeat("ab", 32)}}}
+	url, err := p.Push(t.Context(), subjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://github.com/acme/app/attestations/7" {
+		t.Errorf("url = %q", url)
+	}
+
+	var b struct {
+		MediaType            string `json:"mediaType"`
+		VerificationMaterial struct {
+			TlogEntries []struct {
+				LogIndex       string                 `json:"logIndex"`
+				LogID          struct{ KeyID string } `json:"logId"`
+				InclusionProof struct {
+					LogIndex string   `json:"logIndex"`
+					RootHash string   `json:"rootHash"`
+					Hashes   []string `json:"hashes"`
+				} `json:"inclusionProof"`
+			} `json:"tlogEntries"`
+		} `json:"verificationMaterial"`
+		DSSEEnvelope struct {
+			Payload []byte `json:"payload"`
+		} `json:"dsseEnvelope"`
+	}
+	if err := json.Unmarshal(*uploaded, &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.MediaType != bundleMediaType {
+		t.Errorf("mediaType = %q", b.MediaType)
+	}
+	entry := b.VerificationMaterial.TlogEntries[0]
+	// Hex values from Rekor are base64 in bundles, and int64 are strings
+	if entry.LogIndex != "123456" || entry.InclusionProof.LogIndex != "456" || entry.LogID.KeyID != "wP/u" ||
+		entry.InclusionProof.RootHash != "zA==" || strings.Join(entry.InclusionProof.Hashes, ",") != "qg==,uw==" {
+		t.Errorf("tlog entry = %+v", entry)
+	}
+
+	var statement struct {
+		Type      string    `json:"_type"`
+		Subject   []Subject `json:"subject"`
+		Predicate struct {
+			BuildDefinition struct {
+				ExternalParameters struct {
+					Workflow map[string]string `json:"workflow"`
+				} `json:"externalParameters"`
+			} `json:"buildDefinition"`
+			RunDetails struct {
+				Builder  map[string]string `json:"builder"`
+				Metadata map[string]string `json:"metadata"`
+			} `json:"runDetails"`
+		} `json:"predicate"`
+	}
+	if err := json.Unmarshal(b.DSSEEnvelope.Payload, &statement); err != nil {
+		t.Fatal(err)
+	}
+	if statement.Type != statementType || len(statement.Subject) != 1 || statement.Subject[0].Name != subjects[0].Name {
+		t.Errorf("statement = %+v", statement)
+	}
+	if path := statement.Predicate.BuildDefinition.ExternalParameters.Workflow["path"]; path != ".github/workflows/release.yml" {
+		t.Errorf("workflow path = %q", path)
+	}
+	if id := statement.Predicate.RunDetails.Metadata["invocationId"]; id != "https://github.com/acme/app/actions/runs/42/attempts/1" {
+		t.Errorf("invocationId = %q", id)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	_, err := LoadEnv(func(name string) string {
+		if name == "GITHUB_TOKEN" {
+			return "token"
+		}
+		return ""
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing ACTIONS_ID_TOKEN_REQUEST_URL, ACTIONS_ID_TOKEN_REQUEST_TOKEN, GITHUB_REPOSITORY") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package attest lists the build artifacts as subjects of GitHub artifact
+// attestations and publishes SLSA build provenance for them.
+package attest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// Subject formats.
+const (
+	// FormatGitHub is the JSON subject list of in-toto statements, as shown
+	// by GitHub attestations.
+	FormatGitHub = "github"
+	// FormatChecksums is the sha256sum format read by the subject-checksums
+	// input of actions/attest-build-provenance.
+	FormatChecksums = "checksums"
+)
+
+// Formats lists the supported subject formats.
+var Formats = []string{FormatGitHub, FormatChecksums}
+
+// Subject is an artifact in an in-toto statement.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SHA256 returns the hex SHA-256 digest of s.
+func (s Subject) SHA256() string { return s.Digest["sha256"] }
+
+// Load returns the subjects of the build of the current git tag in out_dir.
+func Load(ctx context.Context, cfg *config.Config) ([]Subject, error) {
+	outDir, err := cfg.OutputDir(git.GetTag(ctx))
+	if err != nil {
+		return nil, err
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("load build (run gcx build first): %w", err)
+	}
+	return Subjects(m, outDir)
+}
+
+// Subjects returns a subject for every artifact of m. Names are slash paths
+// relative to outDir, so binaries of different platforms stay distinct.
+// Checksums missing from manifests of older builds are computed.
+func Subjects(m *manifest.Manifest, outDir string) ([]Subject, error) {
+	subjects := make([]Subject, 0, len(m.Artifacts))
+	for _, a := range m.Artifacts {
+		name := a.Name
+		if rel, err := filepath.Rel(outDir, a.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+
+		sum := a.SHA256
+		if sum == "" {
+			digest, err := checksum.File(a.Path)
+			if err != nil {
+				return nil, fmt.Errorf("checksum %s: %w", a.Name, err)
+			}
+			sum = digest.SHA256Hex()
+		}
+		subjects = append(subjects, Subject{Name: name, Digest: map[string]string{"sha256": sum}})
+	}
+	if len(subjects) == 0 {
+		return nil, fmt.Errorf("%s lists no artifacts", manifest.FileName)
+	}
+	return subjects, nil
+}
+
+// WriteSubjects prints subjects in format.
+func WriteSubjects(w io.Writer, subjects []Subject, format string) error {
+	switch format {
+	case FormatGitHub:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(subjects); err != nil {
+			return fmt.Errorf("encode subjects: %w", err)
+		}
+		return nil
+	case FormatChecksums:
+		for _, s := range subjects {
+			if _, err := fmt.Fprintf(w, "%s  %s\n", s.SHA256(), s.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported subject format %q: expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
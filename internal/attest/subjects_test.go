@@ -0,0 +1,88 @@
+package attest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestSubjects(t *testing.T) {
+	outDir := t.TempDir()
+	binPath := filepath.Join(outDir, "app_linux_amd64", "app")
+	if err := os.MkdirAll(filepath.Dir(binPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Manifests of older builds have no checksums
+	if err := os.WriteFile(binPath, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	binSum := sha256.Sum256([]byte("binary"))
+	archiveSum := strings.Repeat("ab", 32)
+
+	m := &manifest.Manifest{Artifacts: []manifest.Artifact{
+		{Name: "app_v1.0.0_darwin_arm64.tar.gz", Path: filepath.Join(outDir, "app_v1.0.0_darwin_arm64.tar.gz"), SHA256: archiveSum},
+		{Name: "app", Path: binPath},
+	}}
+	subjects, err := Subjects(m, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subject{
+		{Name: "app_v1.0.0_darwin_arm64.tar.gz", Digest: map[string]string{"sha256": archiveSum}},
+		{Name: "app_linux_amd64/app", Digest: map[string]string{"sha256": hex.EncodeToString(binSum[:])}},
+	}
+	if len(subjects) != len(want) {
+		t.Fatalf("subjects = %v", subjects)
+	}
+	for i := range want {
+		if subjects[i].Name != want[i].Name || subjects[i].SHA256() != want[i].SHA256() {
+			t.Errorf("subjects[%d] = %v, want %v", i, subjects[i], want[i])
+		}
+	}
+
+	t.Run("github", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteSubjects(&buf, subjects, FormatGitHub); err != nil {
+			t.Fatal(err)
+		}
+		var got []map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		digest, _ := got[0]["digest"].(map[string]any)
+		if got[0]["name"] != want[0].Name || digest["sha256"] != archiveSum {
+			t.Errorf("subject = %v", got[0])
+		}
+	})
+
+	t.Run("checksums", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteSubjects(&buf, subjects, FormatChecksums); err != nil {
+			t.Fatal(err)
+		}
+		wantOut := archiveSum + "  app_v1.0.0_darwin_arm64.tar.gz\n" + hex.EncodeToString(binSum[:]) + "  app_linux_amd64/app\n"
+		if buf.String() != wantOut {
+			t.Errorf("output = %q, want %q", buf.String(), wantOut)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		err := WriteSubjects(&bytes.Buffer{}, subjects, "slsa")
+		if err == nil || !strings.Contains(err.Error(), "expected one of github, checksums") {
+			t.Errorf("error = %v", err)
+		}
+	})
+}
+
+func TestSubjectsEmpty(t *testing.T) {
+	if _, err := Subjects(&manifest.Manifest{}, t.TempDir()); err == nil {
+		t.Error("expected an error for a manifest without artifacts")
+	}
+}
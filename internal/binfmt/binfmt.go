@@ -0,0 +1,262 @@
+// Package binfmt identifies the platform of an executable from its header
+// and, for Go binaries, from the build info the linker embeds.
+//
+// Only ELF, Mach-O, PE and WebAssembly files are recognized; other files,
+// e.g. scripts or the a.out binaries of plan9, are not executables to it.
+package binfmt
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Executable formats.
+const (
+	ELF   = "ELF"
+	MachO = "Mach-O"
+	PE    = "PE"
+	Wasm  = "wasm"
+)
+
+// ErrNotExecutable reports a file in none of the recognized formats.
+var ErrNotExecutable = errors.New("not an ELF, Mach-O, PE or wasm executable")
+
+// HeaderSize is the number of leading bytes Sniff needs.
+const HeaderSize = 4
+
+// Info describes an executable.
+type Info struct {
+	// Format is ELF, MachO, PE or Wasm.
+	Format string
+	// Arch is the GOARCH of the machine type in the header, empty for a
+	// machine Go does not target.
+	Arch string
+	// Goos and Goarch are the target recorded in the Go build info, empty
+	// when there is none, e.g. for C or UPX-packed binaries.
+	Goos   string
+	Goarch string
+}
+
+// String returns goos/goarch from the build info, or the format and
+// architecture of the header, e.g. "Mach-O arm64".
+func (i Info) String() string {
+	if i.Goos != "" {
+		return i.Goos + "/" + i.Goarch
+	}
+	if i.Arch == "" {
+		return i.Format + " for an unknown machine"
+	}
+	return i.Format + " " + i.Arch
+}
+
+// Matches reports whether the executable is built for goos/goarch. Without
+// build info the header format must be the one goos uses and the header
+// architecture must be goarch.
+func (i Info) Matches(goos, goarch string) bool {
+	if i.Goos != "" {
+		return i.Goos == goos && i.Goarch == goarch
+	}
+	if f := formatOf(goos); f != "" && f != i.Format {
+		return false
+	}
+	return i.Arch == "" || i.Arch == goarch
+}
+
+// formatOf returns the executable format of goos, or "" when binfmt does
+// not recognize it.
+func formatOf(goos string) string {
+	switch goos {
+	case "darwin", "ios":
+		return MachO
+	case "windows":
+		return PE
+	case "js", "wasip1":
+		return Wasm
+	case "plan9", "aix":
+		return ""
+	default:
+		return ELF
+	}
+}
+
+// Sniff returns the format of a file starting with header, or "" when it
+// is not an executable. header should hold HeaderSize bytes.
+func Sniff(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte(elf.ELFMAG)):
+		return ELF
+	case bytes.HasPrefix(header, []byte("MZ")):
+		return PE
+	case bytes.HasPrefix(header, []byte("\x00asm")):
+		return Wasm
+	case len(header) >= 4:
+		switch binary.LittleEndian.Uint32(header) {
+		case macho.Magic32, macho.Magic64:
+			return MachO
+		}
+		switch binary.BigEndian.Uint32(header) {
+		case macho.Magic32, macho.Magic64:
+			return MachO
+		}
+	}
+	return ""
+}
+
+// Detect identifies the executable in r. It returns ErrNotExecutable for
+// other files and an error for a truncated or malformed header.
+func Detect(r io.ReaderAt) (Info, error) {
+	header := make([]byte, 64)
+	n, readErr := r.ReadAt(header, 0)
+	if readErr != nil && !errors.Is(readErr, io.EOF) {
+		return Info{}, readErr
+	}
+	header = header[:n]
+
+	info := Info{Format: Sniff(header)}
+	var err error
+	switch info.Format {
+	case ELF:
+		info.Arch, err = elfArch(header)
+	case MachO:
+		info.Arch, err = machoArch(header)
+	case PE:
+		info.Arch, err = peArch(r, header)
+	case Wasm:
+		info.Arch = "wasm"
+	default:
+		return Info{}, ErrNotExecutable
+	}
+	if errors.Is(err, ErrNotExecutable) {
+		return Info{}, err
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("read %s header: %w", info.Format, err)
+	}
+
+	// Packed and non-Go binaries have no readable build info
+	if bi, err := buildinfo.Read(r); err == nil {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "GOOS":
+				info.Goos = s.Value
+			case "GOARCH":
+				info.Goarch = s.Value
+			}
+		}
+		if info.Goos == "" || info.Goarch == "" {
+			info.Goos, info.Goarch = "", ""
+		}
+	}
+	return info, nil
+}
+
+var errTruncated = errors.New("truncated header")
+
+func elfArch(h []byte) (string, error) {
+	if len(h) < 20 {
+		return "", errTruncated
+	}
+	is64 := elf.Class(h[elf.EI_CLASS]) == elf.ELFCLASS64
+	var order binary.ByteOrder = binary.LittleEndian
+	if elf.Data(h[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	le := elf.Data(h[elf.EI_DATA]) != elf.ELFDATA2MSB
+	switch elf.Machine(order.Uint16(h[18:])) {
+	case elf.EM_386:
+		return "386", nil
+	case elf.EM_X86_64:
+		return "amd64", nil
+	case elf.EM_ARM:
+		return "arm", nil
+	case elf.EM_AARCH64:
+		return "arm64", nil
+	case elf.EM_LOONGARCH:
+		return "loong64", nil
+	case elf.EM_MIPS:
+		return mipsArch(is64, le), nil
+	case elf.EM_PPC64:
+		if le {
+			return "ppc64le", nil
+		}
+		return "ppc64", nil
+	case elf.EM_RISCV:
+		if is64 {
+			return "riscv64", nil
+		}
+	case elf.EM_S390:
+		if is64 {
+			return "s390x", nil
+		}
+	}
+	return "", nil
+}
+
+// mipsArch returns the MIPS architecture of the word size and byte order,
+// which share one ELF machine type.
+func mipsArch(is64, le bool) string {
+	switch {
+	case is64 && le:
+		return "mips64le"
+	case is64:
+		return "mips64"
+	case le:
+		return "mipsle"
+	default:
+		return "mips"
+	}
+}
+
+func machoArch(h []byte) (string, error) {
+	if len(h) < 8 {
+		return "", errTruncated
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if m := binary.BigEndian.Uint32(h); m == macho.Magic32 || m == macho.Magic64 {
+		order = binary.BigEndian
+	}
+	switch macho.Cpu(order.Uint32(h[4:])) {
+	case macho.Cpu386:
+		return "386", nil
+	case macho.CpuAmd64:
+		return "amd64", nil
+	case macho.CpuArm:
+		return "arm", nil
+	case macho.CpuArm64:
+		return "arm64", nil
+	}
+	return "", nil
+}
+
+func peArch(r io.ReaderAt, h []byte) (string, error) {
+	// The DOS header points to the PE signature at offset 0x3c
+	if len(h) < 0x40 {
+		return "", errTruncated
+	}
+	var coff [6]byte
+	if _, err := r.ReadAt(coff[:], int64(binary.LittleEndian.Uint32(h[0x3c:]))); err != nil {
+		return "", errTruncated
+	}
+	if string(coff[:4]) != "PE\x00\x00" {
+		// A DOS program or just a file starting with MZ
+		return "", ErrNotExecutable
+	}
+	switch binary.LittleEndian.Uint16(coff[4:]) {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386", nil
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64", nil
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm", nil
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64", nil
+	}
+	return "", nil
+}
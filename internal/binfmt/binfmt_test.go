@@ -0,0 +1,137 @@
+package binfmt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		file string
+		want Info
+	}{
+		{"linux_amd64.elf", Info{Format: ELF, Arch: "amd64"}},
+		{"linux_arm64.elf", Info{Format: ELF, Arch: "arm64"}},
+		{"linux_arm.elf", Info{Format: ELF, Arch: "arm"}},
+		{"linux_mipsle.elf", Info{Format: ELF, Arch: "mipsle"}},
+		{"linux_mips64.elf", Info{Format: ELF, Arch: "mips64"}},
+		{"linux_ppc64le.elf", Info{Format: ELF, Arch: "ppc64le"}},
+		{"darwin_arm64.macho", Info{Format: MachO, Arch: "arm64"}},
+		{"darwin_amd64.macho", Info{Format: MachO, Arch: "amd64"}},
+		{"windows_amd64.exe", Info{Format: PE, Arch: "amd64"}},
+		{"windows_arm64.exe", Info{Format: PE, Arch: "arm64"}},
+		{"js_wasm.wasm", Info{Format: Wasm, Arch: "wasm"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := Detect(f)
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Detect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectErrors(t *testing.T) {
+	tests := []struct {
+		file          string
+		notExecutable bool
+	}{
+		{"script.sh", true},
+		{"truncated.elf", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			_, err = Detect(f)
+			if err == nil {
+				t.Fatal("Detect() succeeded, want an error")
+			}
+			if errors.Is(err, ErrNotExecutable) != tt.notExecutable {
+				t.Errorf("Detect() error = %v, want ErrNotExecutable: %v", err, tt.notExecutable)
+			}
+		})
+	}
+}
+
+func TestDetectBuildInfo(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := Detect(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Goos != runtime.GOOS || got.Goarch != runtime.GOARCH {
+		t.Errorf("Detect(test binary) = %+v, want the build info of %s/%s", got, runtime.GOOS, runtime.GOARCH)
+	}
+	if !got.Matches(runtime.GOOS, runtime.GOARCH) {
+		t.Errorf("Matches(%s, %s) = false", runtime.GOOS, runtime.GOARCH)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		info         Info
+		goos, goarch string
+		want         bool
+	}{
+		{Info{Format: ELF, Arch: "amd64"}, "linux", "amd64", true},
+		{Info{Format: ELF, Arch: "amd64"}, "freebsd", "amd64", true},
+		{Info{Format: ELF, Arch: "amd64"}, "linux", "arm64", false},
+		{Info{Format: MachO, Arch: "arm64"}, "linux", "arm64", false},
+		{Info{Format: MachO, Arch: "arm64"}, "darwin", "arm64", true},
+		{Info{Format: PE, Arch: "amd64"}, "windows", "amd64", true},
+		{Info{Format: Wasm, Arch: "wasm"}, "wasip1", "wasm", true},
+		{Info{Format: ELF}, "linux", "sparc64", true},
+		// Build info tells ELF systems apart
+		{Info{Format: ELF, Arch: "amd64", Goos: "freebsd", Goarch: "amd64"}, "linux", "amd64", false},
+		{Info{Format: ELF, Arch: "amd64", Goos: "linux", Goarch: "amd64"}, "linux", "amd64", true},
+	}
+	for _, tt := range tests {
+		if got := tt.info.Matches(tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("%v.Matches(%s, %s) = %v, want %v", tt.info, tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"\x7fELF", ELF},
+		{"\xcf\xfa\xed\xfe", MachO},
+		{"\xfe\xed\xfa\xce", MachO},
+		{"MZ\x90\x00", PE},
+		{"\x00asm", Wasm},
+		{"#!/b", ""},
+		{"\x7fE", ""},
+	}
+	for _, tt := range tests {
+		if got := Sniff([]byte(tt.header)); got != tt.want {
+			t.Errorf("Sniff(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
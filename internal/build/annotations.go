@@ -0,0 +1,57 @@
+package build
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sxwebdev/gcx/internal/annotate"
+)
+
+// annotationsMu keeps the groups of parallel targets from interleaving.
+var annotationsMu sync.Mutex
+
+// writeAnnotations writes the captured go build output of one target to w
+// in format, annotating its errors. Paths are made relative to the
+// working directory, as CI systems expect paths from the repository root,
+// and a failed build without located errors gets one annotation with err.
+func writeAnnotations(w io.Writer, format annotate.Format, dir, title string, output []byte, err error) {
+	if len(output) == 0 && err == nil {
+		return
+	}
+	annotations := annotate.Parse(output)
+	for i, a := range annotations {
+		annotations[i].File = relativeToWD(dir, a.File)
+	}
+	if err != nil && len(annotations) == 0 {
+		annotations = append(annotations, annotate.Annotation{Message: title + ": " + err.Error()})
+	}
+
+	annotationsMu.Lock()
+	defer annotationsMu.Unlock()
+	if err := format(w, title, output, annotations); err != nil {
+		log.Printf("Warning: write annotations: %v", err)
+	}
+}
+
+// relativeToWD resolves path, relative to dir, against the working
+// directory. Paths outside of it are returned absolute.
+func relativeToWD(dir, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	if rel, err := filepath.Rel(wd, abs); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
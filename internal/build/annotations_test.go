@@ -0,0 +1,45 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/annotate"
+)
+
+func TestWriteAnnotations(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := "go build app linux/amd64"
+
+	t.Run("paths from the working directory", func(t *testing.T) {
+		var b strings.Builder
+		output := []byte("# example.com/app\nmain.go:3:2: undefined: foo\n")
+		writeAnnotations(&b, annotate.WriteGitHub, filepath.Join(wd, "testdata", "app"), title, output, errors.New("exit status 1"))
+		want := "::error file=testdata/app/main.go,line=3,col=2,title=go build app linux/amd64::undefined: foo\n"
+		if !strings.HasPrefix(b.String(), "::group::"+title+"\n") || !strings.HasSuffix(b.String(), want) {
+			t.Errorf("output =\n%s\nwant a group and\n%s", b.String(), want)
+		}
+	})
+
+	t.Run("failure without located errors", func(t *testing.T) {
+		var b strings.Builder
+		writeAnnotations(&b, annotate.WriteGitHub, "", title, nil, errors.New("exit status 2"))
+		if !strings.HasSuffix(b.String(), "::error title=go build app linux/amd64::go build app linux/amd64: exit status 2\n") {
+			t.Errorf("output =\n%s", b.String())
+		}
+	})
+
+	t.Run("quiet success", func(t *testing.T) {
+		var b strings.Builder
+		writeAnnotations(&b, annotate.WriteGitHub, "", title, nil, nil)
+		if b.Len() != 0 {
+			t.Errorf("output = %q, want nothing", b.String())
+		}
+	})
+}
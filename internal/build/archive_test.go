@@ -0,0 +1,439 @@
+package build
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestCreateArchivesShortSha256(t *testing.T) {
+	outDir := t.TempDir()
+	artifact := Artifact{BinaryName: "app", Version: "v1.0.0", OS: "linux", Arch: "amd64"}
+	artifact.DirPath = outputDir(true, outDir, artifact)
+	if err := os.MkdirAll(artifact.DirPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(artifact.DirPath, "app"), []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Archives: []config.ArchiveConfig{
+		{Formats: []string{"tar.gz", "zip"}, NameTemplate: "{{.Binary}}_{{.Version}}_{{.Os}}_{{.Arch}}_{{.ShortSha256}}"},
+	}}
+	archives, _, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, []Artifact{artifact})
+	if err != nil {
+		t.Fatalf("createArchives() error = %v", err)
+	}
+
+	paths := archives[artifact.DirPath]
+	if len(paths) != 2 {
+		t.Fatalf("archives = %v, want 2", paths)
+	}
+	for i, ext := range []string{".tar.gz", ".zip"} {
+		digest, err := checksum.File(paths[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "app_v1.0.0_linux_amd64_" + digest.ShortSHA256() + ext
+		if got := filepath.Base(paths[i]); got != want {
+			t.Errorf("archive name = %q, want %q", got, want)
+		}
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temporary archive %s left behind", e.Name())
+		}
+	}
+}
+
+func TestRenameHashedExisting(t *testing.T) {
+	dir := t.TempDir()
+	tmpPath := filepath.Join(dir, ".app.0.zip.tmp")
+	if err := os.WriteFile(tmpPath, []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// sha256("archive") starts with 0eb3e3
+	finalPath := filepath.Join(dir, "app_0eb3e3.zip")
+	if err := os.WriteFile(finalPath, []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Archives: []config.ArchiveConfig{{NameTemplate: "{{.Binary}}_{{.ShortSha256}}"}}}
+	_, err := renameHashed(cfg, 0, Artifact{BinaryName: "app"}, tmpPath, "zip")
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("renameHashed() error = %v, want already exists", err)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("temporary archive was not removed: %v", err)
+	}
+	if data, _ := os.ReadFile(finalPath); string(data) != "other" {
+		t.Errorf("existing archive was overwritten: %q", data)
+	}
+}
+
+func TestCreateArchivesBinary(t *testing.T) {
+	outDir := t.TempDir()
+	var artifacts []Artifact
+	for _, a := range []Artifact{
+		{BinaryName: "app", Group: "suite", OS: "linux", Arch: "amd64", Executable: true},
+		{BinaryName: "worker", Group: "suite", OS: "linux", Arch: "amd64", Executable: true},
+		{BinaryName: "app", Group: "suite", OS: "windows", Arch: "amd64", Ext: ".exe", Executable: true},
+	} {
+		a.Version = "v1.0.0"
+		a.DirPath = outputDir(true, outDir, a)
+		if err := os.MkdirAll(a.DirPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(a.DirPath, a.FileName()), []byte(a.BinaryName+" "+a.OS), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	cfg := &config.Config{Archives: []config.ArchiveConfig{
+		{Formats: []string{"binary", "tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"},
+	}}
+	archives, contents, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, artifacts)
+	if err != nil {
+		t.Fatalf("createArchives() error = %v", err)
+	}
+
+	var got []string
+	for _, dir := range []string{artifacts[0].DirPath, artifacts[2].DirPath} {
+		for _, p := range archives[dir] {
+			got = append(got, filepath.Base(p))
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("source directory %s was not removed", dir)
+		}
+	}
+	want := []string{
+		"suite_linux_amd64.tar.gz", "app_linux_amd64", "worker_linux_amd64",
+		"suite_windows_amd64.tar.gz", "app_windows_amd64.exe",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("outputs = %v, want %v", got, want)
+	}
+
+	bare := filepath.Join(outDir, "worker_linux_amd64")
+	info, err := os.Stat(bare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(bare); string(data) != "worker linux" || info.Mode().Perm() != 0o755 {
+		t.Errorf("bare binary = %q, mode %v", data, info.Mode().Perm())
+	}
+	if _, ok := contents[bare]; ok {
+		t.Error("bare binary has archive contents")
+	}
+
+	types := make(map[string]string)
+	for _, e := range artifactEntries(artifacts, archives) {
+		types[e.Name] = e.Type
+	}
+	if types["app_windows_amd64.exe"] != manifest.TypeBinary || types["suite_windows_amd64.tar.gz"] != manifest.TypeArchive {
+		t.Errorf("manifest types = %v", types)
+	}
+}
+
+// TestCreateArchivesBuilds checks that archive configs with a builds filter
+// only archive the artifacts of the named builds.
+func TestCreateArchivesBuilds(t *testing.T) {
+	outDir := t.TempDir()
+	var artifacts []Artifact
+	for _, a := range []Artifact{
+		{BinaryName: "server", ID: "server", Builds: []string{"server"}},
+		{BinaryName: "cli", ID: "cli", Builds: []string{"cli"}},
+		{BinaryName: "tool"},
+	} {
+		a.Version, a.OS, a.Arch, a.Executable = "v1.0.0", "linux", "amd64", true
+		a.DirPath = outputDir(true, outDir, a)
+		if err := os.MkdirAll(a.DirPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(a.DirPath, a.FileName()), []byte(a.BinaryName), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	cfg := &config.Config{Archives: []config.ArchiveConfig{
+		{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}", Builds: []string{"server"}},
+		{Formats: []string{"zip", "binary"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}", Builds: []string{"cli"}},
+	}}
+	archives, _, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, artifacts)
+	if err != nil {
+		t.Fatalf("createArchives() error = %v", err)
+	}
+
+	want := map[string][]string{
+		artifacts[0].DirPath: {"server_linux_amd64.tar.gz"},
+		artifacts[1].DirPath: {"cli_linux_amd64.zip", "cli_linux_amd64"},
+	}
+	if len(archives) != len(want) {
+		t.Errorf("archived directories = %v", archives)
+	}
+	for dir, names := range want {
+		var got []string
+		for _, p := range archives[dir] {
+			got = append(got, filepath.Base(p))
+		}
+		if !slices.Equal(got, names) {
+			t.Errorf("outputs of %s = %v, want %v", filepath.Base(dir), got, names)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(artifacts[2].DirPath, "tool")); err != nil {
+		t.Errorf("binary of a build no archive config applies to: %v", err)
+	}
+
+	builds := make(map[string][]string)
+	for _, e := range artifactEntries(artifacts, archives) {
+		builds[e.Name] = e.Builds
+	}
+	if !slices.Equal(builds["server_linux_amd64.tar.gz"], []string{"server"}) || !slices.Equal(builds["cli_linux_amd64"], []string{"cli"}) || builds["tool"] != nil {
+		t.Errorf("manifest builds = %v", builds)
+	}
+}
+
+func TestCreateArchivesFiles(t *testing.T) {
+	repo := t.TempDir()
+	for name, content := range map[string]string{
+		"LICENSE":                  "license",
+		"README.md":                "readme",
+		"completions/app.bash":     "bash",
+		"completions/zsh/_app":     "zsh",
+		"internal/skipped/main.go": "package skipped",
+	} {
+		path := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newArtifact := func(outDir string) Artifact {
+		artifact := Artifact{BinaryName: "app", Version: "v1.0.0", OS: "linux", Arch: "amd64"}
+		artifact.DirPath = outputDir(true, outDir, artifact)
+		if err := os.MkdirAll(artifact.DirPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(artifact.DirPath, "app"), []byte("binary"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return artifact
+	}
+
+	files := []config.ArchiveFile{{Src: "LICENSE*"}, {Src: "completions"}, {Src: "README.md", Dst: "docs"}, {Src: "CHANGELOG*"}}
+	outDir := t.TempDir()
+	artifact := newArtifact(outDir)
+	cfg := &config.Config{Dir: repo, Archives: []config.ArchiveConfig{
+		{Formats: []string{"zip"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}", Files: files},
+		{Formats: []string{"zip"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}_bare"},
+	}}
+	archives, _, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, []Artifact{artifact})
+	if err != nil {
+		t.Fatalf("createArchives() error = %v", err)
+	}
+
+	entries := func(path string) string {
+		t.Helper()
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = r.Close() }()
+		var names []string
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+		slices.Sort(names)
+		return strings.Join(names, ",")
+	}
+	paths := archives[artifact.DirPath]
+	base := filepath.Base(artifact.DirPath) + "/"
+	want := []string{"", "LICENSE", "app", "completions/", "completions/app.bash", "completions/zsh/", "completions/zsh/_app", "docs/README.md"}
+	for i := range want {
+		want[i] = base + want[i]
+	}
+	if got := entries(paths[0]); got != strings.Join(want, ",") {
+		t.Errorf("entries = %s, want %s", got, strings.Join(want, ","))
+	}
+	if got := entries(paths[1]); got != base+","+base+"app" {
+		t.Errorf("entries without files = %s", got)
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		outDir := t.TempDir()
+		cfg.Archives = []config.ArchiveConfig{{Formats: []string{"zip"}, Files: files, Strict: true}}
+		_, _, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, []Artifact{newArtifact(outDir)})
+		if err == nil || !strings.Contains(err.Error(), `archive files "CHANGELOG*" match nothing`) {
+			t.Errorf("createArchives() error = %v, want an unmatched glob", err)
+		}
+	})
+}
+
+func TestCreateArchivesContents(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1714564800")
+	outDir := t.TempDir()
+	artifact := Artifact{BinaryName: "app", Version: "v1.0.0", OS: "linux", Arch: "amd64"}
+	artifact.DirPath = outputDir(true, outDir, artifact)
+	if err := os.MkdirAll(artifact.DirPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(artifact.DirPath, "app"), []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Archives: []config.ArchiveConfig{
+		{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"},
+		{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}_repro", Reproducible: true},
+	}}
+	archives, contents, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, []Artifact{artifact})
+	if err != nil {
+		t.Fatalf("createArchives() error = %v", err)
+	}
+	paths := archives[artifact.DirPath]
+	plain, repro := contents[paths[0]], contents[paths[1]]
+	if len(plain) != 2 || plain[1].Path != "app_v1.0.0_linux_amd64/app" || plain[1].Size != 6 || plain[1].SHA256 == "" || plain[1].Header != nil {
+		t.Errorf("contents = %+v, want entries without headers", plain)
+	}
+	if len(repro) != 2 || repro[1].Header == nil || repro[1].Header.ModTime.Unix() != 1714564800 {
+		t.Errorf("reproducible contents = %+v, want SOURCE_DATE_EPOCH times", repro)
+	}
+
+	entries, err := recordContents(artifactEntries([]Artifact{artifact}, archives), contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || len(entries[0].Contents) != 2 || entries[0].ContentsFile != "" {
+		t.Errorf("entries = %+v, want inline contents", entries)
+	}
+
+	t.Run("sidecar", func(t *testing.T) {
+		long := make([]archive.Entry, maxInlineContents+1)
+		for i := range long {
+			long[i] = archive.Entry{Path: fmt.Sprintf("app/%d", i), Mode: "0644"}
+		}
+		entries, err := recordContents(artifactEntries([]Artifact{artifact}, archives), map[string][]archive.Entry{paths[0]: long})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 3 || entries[0].Contents != nil || entries[0].ContentsFile != "app_linux_amd64.tar.gz.contents.json" {
+			t.Fatalf("entries = %+v, want a sidecar", entries)
+		}
+		if sidecar := entries[2]; sidecar.Name != entries[0].ContentsFile || sidecar.Type != manifest.TypeFile {
+			t.Errorf("sidecar entry = %+v", sidecar)
+		}
+		data, err := os.ReadFile(paths[0] + manifest.ContentsSuffix)
+		if err != nil || !strings.Contains(string(data), `"path": "app/100"`) {
+			t.Errorf("sidecar = %s, %v", data, err)
+		}
+	})
+}
+
+func TestArchiveBaseNameFields(t *testing.T) {
+	t.Setenv("GCX_TEST_FLAVOR", "lite")
+	artifact := Artifact{BinaryName: "app", Version: "1.2.3", Tag: "v1.2.3", Commit: "abc1234", OS: "linux", Arch: "arm", Arm: "7"}
+	cfg := &config.Config{
+		ProjectName: "myproject",
+		Archives: []config.ArchiveConfig{
+			{NameTemplate: "{{.ProjectName}}_{{.Tag}}_{{.Os}}_{{.Arch}}v{{.Arm}}_{{.ShortCommit}}_{{.Env.GCX_TEST_FLAVOR}}"},
+			{NameTemplate: "{{.Binary}}_{{.Missing}}"},
+		},
+	}
+	got, err := archiveBaseName(cfg, 0, artifact, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "myproject_v1.2.3_linux_armv7_abc1234_lite" {
+		t.Errorf("archiveBaseName() = %q", got)
+	}
+
+	_, err = archiveBaseName(cfg, 1, artifact, "")
+	if err == nil || !strings.HasPrefix(err.Error(), "archives[1]: process name_template:") {
+		t.Errorf("archiveBaseName() error = %v, want it to name archives[1]", err)
+	}
+}
+
+// TestCreateArchivesUnderscores checks that underscores in binary names and
+// versions reach the name template intact.
+func TestCreateArchivesUnderscores(t *testing.T) {
+	outDir := t.TempDir()
+	a := Artifact{BinaryName: "my_tool", Version: "v1.0.0_rc1", OS: "linux", Arch: "arm", Arm: "7", Executable: true}
+	a.DirPath = outputDir(true, outDir, a)
+	if err := os.MkdirAll(a.DirPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a.DirPath, a.FileName()), []byte("bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Archives: []config.ArchiveConfig{
+		{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}-{{.Version}}-{{.Os}}-{{.Arch}}v{{.Arm}}"},
+	}}
+	archives, _, err := createArchives(context.Background(), cfg, newStager(cfg), outDir, []Artifact{a})
+	if err != nil {
+		t.Fatalf("createArchives() error = %v", err)
+	}
+	if got := archives[a.DirPath]; len(got) != 1 || filepath.Base(got[0]) != "my_tool-v1.0.0_rc1-linux-armv7.tar.gz" {
+		t.Errorf("archives = %v", got)
+	}
+}
+
+func TestCreateArchivesVerifyContents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		wantErr string
+	}{
+		{fixture: "linux_amd64.elf"},
+		{fixture: "darwin_arm64.macho", wantErr: "is built for Mach-O arm64, but artifacts.json records linux/amd64"},
+		{fixture: "linux_arm64.elf", wantErr: "is built for ELF arm64"},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"tar.gz", "zip", archive.BinaryFormat} {
+			t.Run(tt.fixture+"/"+format, func(t *testing.T) {
+				binary, err := os.ReadFile(filepath.Join("..", "binfmt", "testdata", tt.fixture))
+				if err != nil {
+					t.Fatal(err)
+				}
+				outDir := t.TempDir()
+				artifact := Artifact{BinaryName: "app", Version: "v1.0.0", OS: "linux", Arch: "amd64", Executable: true}
+				artifact.DirPath = outputDir(true, outDir, artifact)
+				if err := os.MkdirAll(artifact.DirPath, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(artifact.DirPath, "app"), binary, 0o755); err != nil {
+					t.Fatal(err)
+				}
+
+				cfg := &config.Config{Archives: []config.ArchiveConfig{
+					{Formats: []string{format}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}", VerifyContents: true},
+				}}
+				_, _, err = createArchives(context.Background(), cfg, newStager(cfg), outDir, []Artifact{artifact})
+				if tt.wantErr == "" && err != nil {
+					t.Fatalf("createArchives() error = %v", err)
+				}
+				if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Fatalf("createArchives() error = %v, want %q", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
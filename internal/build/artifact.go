@@ -1,12 +1,57 @@
 package build
 
+import "github.com/sxwebdev/gcx/internal/config"
+
 // Artifact holds structured metadata about a built binary.
 // This eliminates the fragile filename-parsing approach.
 type Artifact struct {
 	BinaryName string
-	Version    string
+	ID         string // id of the build, if any
+	Version    string // tag formatted by version_format
+	Tag        string // git tag as is
+	Channel    string
+	Commit     string // short commit hash
 	OS         string
 	Arch       string
 	Arm        string
+	Variant    string // GOAMD64, GOARM64, GOMIPS or GORISCV64 value
 	DirPath    string // path to the directory containing the binary
+	Ext        string // file extension of the binary, e.g. ".exe"
+	// Executable is false for outputs run by a host runtime, e.g. wasm.
+	Executable bool
+	// Extras are support files written next to the binary, e.g. wasm_exec.js.
+	Extras []string
+	// Group is the build group sharing the output directory, if any.
+	Group string
+	// Builds are the ids of the builds sharing the output directory,
+	// matched by the builds filters of archives and blobs.
+	Builds []string
+	// Instrumented is set for binaries built with go build -cover.
+	Instrumented bool
+}
+
+// DirName returns the name the output directory and archives are derived
+// from: the build group, or the binary name for ungrouped builds.
+func (a Artifact) DirName() string {
+	if a.Group != "" {
+		return a.Group
+	}
+	return a.BinaryName
+}
+
+// FileName returns the name of the built file, including its extension.
+func (a Artifact) FileName() string {
+	return a.BinaryName + a.Ext
+}
+
+// buildIDs returns the ids of the builds whose binaries share the output
+// directory of buildCfg: those of its group, or its own id.
+func buildIDs(cfg *config.Config, buildCfg config.BuildConfig) []string {
+	var ids []string
+	for _, b := range cfg.Builds {
+		if b.ID != "" && (b.ID == buildCfg.ID || buildCfg.Group != "" && b.Group == buildCfg.Group) {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids
 }
@@ -0,0 +1,53 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// binaryBaseName renders the name of the bare binary of artifact for the
+// binary format of the archive config at index i, without extension.
+// {{.Binary}} is the binary name also for grouped builds.
+func binaryBaseName(cfg *config.Config, i int, artifact Artifact, shortSha256 string) (string, error) {
+	artifact.Group = ""
+	return archiveBaseName(cfg, i, artifact, shortSha256)
+}
+
+// copyBinary stages the binary of artifact in artifactsDir under its
+// binaryBaseName plus the platform extension and returns the new path. The
+// binary is still archived from its directory, so move links it instead.
+func copyBinary(cfg *config.Config, stage *stager, i int, artifact Artifact, artifactsDir string) (string, error) {
+	src := filepath.Join(artifact.DirPath, artifact.FileName())
+	var shortSha256 string
+	if usesShortSha256(cfg.Archives[i].NameTemplate) {
+		digest, err := checksum.File(src)
+		if err != nil {
+			return "", fmt.Errorf("checksum binary: %w", err)
+		}
+		shortSha256 = digest.ShortSHA256()
+	}
+	name, err := binaryBaseName(cfg, i, artifact, shortSha256)
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(artifactsDir, name+artifact.Ext)
+	if _, err := os.Lstat(dst); err == nil {
+		return "", fmt.Errorf("copy %s to %s: destination already exists", src, dst)
+	}
+	strategy := stage.strategy
+	if strategy == config.StagingMove {
+		strategy = config.StagingHardlink
+	}
+	if err := stage.stage(src, dst, strategy); err != nil {
+		return "", err
+	}
+	if artifact.Executable {
+		return dst, os.Chmod(dst, 0o755)
+	}
+	return dst, nil
+}
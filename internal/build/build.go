@@ -1,64 +1,306 @@
 package build
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/sxwebdev/gcx/internal/annotate"
 	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/checksums"
 	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
 	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/gitauth"
 	"github.com/sxwebdev/gcx/internal/hook"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/metrics"
+	"github.com/sxwebdev/gcx/internal/sign"
 	"github.com/sxwebdev/gcx/internal/tmpl"
+	"github.com/sxwebdev/gcx/internal/toolchain"
 	"golang.org/x/sync/errgroup"
 )
 
 var envVarRegex = regexp.MustCompile(`{{\.Env\.([^}]+)}}`)
 
-// ArchiveTemplateData contains data for archive name template.
+// killWaitDelay is how long a killed go build may keep its output open,
+// e.g. through a hung linker, before Run stops waiting for it.
+const killWaitDelay = 5 * time.Second
+
+// ArchiveTemplateData contains data for archive name template. It is also
+// used for prebuilt path templates.
 type ArchiveTemplateData struct {
+	// Binary is the binary name, or the group of grouped builds.
 	Binary  string
 	Version string
+	Channel string
 	Os      string
 	Arch    string
+	// Arm is the GOARM value of arm targets, e.g. "7".
+	Arm string
+	// Variant is the sub-architecture variant of the target: Arm, or the
+	// GOAMD64, GOARM64, GOMIPS or GORISCV64 value, e.g. "v3".
+	Variant string
+	// Ext is the binary extension for the target, e.g. ".exe" or ".wasm".
+	Ext string
+	// Instrumented is true for coverage-instrumented builds.
+	Instrumented bool
+	// Tag is the git tag as is, with its leading v.
+	Tag         string
+	ShortCommit string
+	ProjectName string
+	Env         map[string]string
+	// ShortSha256 is the short SHA-256 of the archive content. It is only
+	// set for archive names, which are rendered again once the archive exists.
+	ShortSha256 string
+}
+
+// templateData returns the archive template data of artifact.
+func templateData(cfg *config.Config, artifact Artifact) ArchiveTemplateData {
+	return ArchiveTemplateData{
+		Binary:       artifact.DirName(),
+		Version:      artifact.Version,
+		Channel:      artifact.Channel,
+		Os:           artifact.OS,
+		Arch:         artifact.Arch,
+		Arm:          artifact.Arm,
+		Variant:      cmp.Or(artifact.Arm, artifact.Variant),
+		Ext:          artifact.Ext,
+		Instrumented: artifact.Instrumented,
+		Tag:          artifact.Tag,
+		ShortCommit:  artifact.Commit,
+		ProjectName:  cfg.Project(),
+		Env:          envvars.Environ(),
+	}
+}
+
+// Options holds command-line overrides for Run.
+type Options struct {
+	// ForceAll builds everything, ignoring only_if_changed.
+	ForceAll bool
+	// SingleTarget builds only the targets of the host platform (or of the
+	// GOOS and GOARCH environment variables).
+	SingleTarget bool
+	// ArchiveTo receives a tar.gz of the only built target instead of the
+	// configured archives, generated files and signatures. Run fails when
+	// more than one target would be built, and writes nothing else to
+	// stdout so the stream can be piped.
+	ArchiveTo io.Writer
+	// SkipBeforeHooks, SkipArchives and SkipAfterHooks bypass those stages.
+	// Without archives, artifacts.json lists the raw binaries.
+	SkipBeforeHooks bool
+	SkipArchives    bool
+	SkipAfterHooks  bool
+	// HooksTags runs only the tagged hooks with one of these tags, and
+	// SkipHooksTags skips the tagged hooks with one of its tags. Untagged
+	// hooks always run.
+	HooksTags     []string
+	SkipHooksTags []string
+	// SkipEmbedChecks bypasses embed_checks.
+	SkipEmbedChecks bool
+	// SkipSign writes the checksums files without signing anything.
+	SkipSign bool
+	// SkipUPX leaves binaries uncompressed despite upx.enabled.
+	SkipUPX bool
+	// Parallelism caps the concurrent go build processes, overriding the
+	// parallelism of the config when positive.
+	Parallelism int
+	// Annotations is a format of annotate.Formats, e.g. github. go build
+	// output is then captured per target, grouped and its errors annotated.
+	Annotations string
+	// DryRun receives the hooks and commands Run would execute, with their
+	// environment changes, the files of the output directory it would
+	// remove and the archives it would create. Nothing is then executed or
+	// written, and embed_checks are skipped as the before hooks do not run.
+	DryRun io.Writer
+	// Commands receives the go build process of every target, as the
+	// JSON array artifacts.json records under commands, instead of
+	// building. Nothing is executed or written, as in a dry run.
+	Commands io.Writer
+}
+
+// skipped returns the names of the stages o bypasses.
+func (o Options) skipped() []string {
+	var stages []string
+	if o.SkipBeforeHooks {
+		stages = append(stages, "before hooks")
+	}
+	if o.SkipArchives {
+		stages = append(stages, "archives")
+	}
+	if o.SkipAfterHooks {
+		stages = append(stages, "after hooks")
+	}
+	if o.SkipEmbedChecks {
+		stages = append(stages, "embed checks")
+	}
+	if o.SkipSign {
+		stages = append(stages, "signing")
+	}
+	if o.SkipUPX {
+		stages = append(stages, "upx")
+	}
+	return stages
 }
 
 // Run performs cross-compilation of binaries according to the configuration.
-func Run(ctx context.Context, cfg *config.Config) ([]Artifact, error) {
+func Run(ctx context.Context, cfg *config.Config, opts Options) (_ []Artifact, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveStage("build", start, err) }()
+
+	// Output of go build and hooks; stdout belongs to the archive stream
+	var stdout io.Writer = os.Stdout
+	if opts.Commands != nil && opts.DryRun == nil {
+		opts.DryRun = io.Discard
+	}
+	if opts.DryRun != nil && opts.ArchiveTo != nil {
+		return nil, fmt.Errorf("a dry run cannot stream an archive")
+	}
+	if opts.ArchiveTo != nil {
+		stdout = os.Stderr
+		if err := checkSingleTarget(cfg, opts.SingleTarget); err != nil {
+			return nil, err
+		}
+	}
+
+	annotations, err := annotate.Lookup(opts.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Signs) > 0 && !opts.SkipSign {
+		available := sign.Available
+		if cfg.Signs[0].Provider == "cosign" {
+			available = sign.CosignAvailable
+		}
+		if err := available(); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.SBOMs) > 0 && opts.ArchiveTo == nil {
+		if err := sbomAvailable(cfg.SBOMs[0]); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.SkipUPX {
+		if err := upxAvailable(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := CheckTargets(ctx, cfg, opts.SingleTarget); err != nil {
+		return nil, err
+	}
+	verified := make(map[[2]string]bool)
+	for _, buildCfg := range cfg.Builds {
+		if buildCfg.Prebuilt != nil {
+			continue
+		}
+		dir := buildDir(cfg, buildCfg)
+		if err := checkMain(dir, buildCfg); err != nil {
+			return nil, fmt.Errorf("build %s: %w", binaryName(buildCfg), err)
+		}
+		goBinary, err := lookGoBinary(dir, buildCfg)
+		if err != nil {
+			return nil, fmt.Errorf("build %s: %w", binaryName(buildCfg), err)
+		}
+		// Every toolchain the builds compile with is verified once per module,
+		// the go directive of go.mod even without go_version
+		if key := [2]string{goBinary, dir}; !verified[key] {
+			verified[key] = true
+			if err := toolchain.Verify(ctx, goBinary, dir, cfg.GoVersion, cfg.StrictToolchain); err != nil {
+				return nil, fmt.Errorf("build %s: %w", binaryName(buildCfg), err)
+			}
+		}
+		if err := checkCGO(buildCfg, buildTargets(buildCfg, opts.SingleTarget)); err != nil {
+			return nil, fmt.Errorf("build %s: %w", binaryName(buildCfg), err)
+		}
+	}
+
+	// Credentials for private modules live only as long as the build
+	auth, err := gitauth.Setup(cfg.GitAuth, cfg.GoPrivate)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = auth.Close() }()
+
 	// Execute before hooks
-	if len(cfg.Before.Hooks) > 0 {
-		if err := hook.Run(ctx, cfg.Before.Hooks); err != nil {
+	if len(cfg.Before.Hooks) > 0 && !opts.SkipBeforeHooks && opts.DryRun != nil {
+		printHooks(opts.DryRun, "before", selectHooks("before", cfg.Before.Hooks, opts))
+	} else if len(cfg.Before.Hooks) > 0 && !opts.SkipBeforeHooks {
+		if err := hook.RunEnv(ctx, cfg.Dir, stdout, auth.Vars(), selectHooks("before", cfg.Before.Hooks, opts)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Embedded assets are checked after the hooks that may build them
+	if len(cfg.EmbedChecks) > 0 && !opts.SkipEmbedChecks && opts.DryRun == nil {
+		if err := checkEmbeds(ctx, cfg); err != nil {
 			return nil, err
 		}
 	}
 
-	outDir := cfg.OutDir
+	currentTag := git.GetTag(ctx)
+	version := cfg.FormatVersion(currentTag)
+	commitHash := git.GetCommitHash(ctx)
+	buildDate := time.Now().Format(time.RFC3339)
+	if cfg.Channel != "" {
+		log.Printf("Building %s on channel %s", currentTag, cfg.Channel)
+	}
+
+	outDir, err := cfg.OutputDir(currentTag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail before anything is written if two artifacts would overwrite each other
+	if err := CheckNames(cfg, currentTag); err != nil {
+		return nil, err
+	}
 
 	// Clean and recreate the output directory
-	if _, err := os.Stat(outDir); err == nil {
+	if opts.DryRun != nil {
+		if err := printRemovals(opts.DryRun, outDir); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(outDir); err == nil {
 		if err := os.RemoveAll(outDir); err != nil {
 			return nil, fmt.Errorf("clean output directory: %w", err)
 		}
 	}
-	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return nil, fmt.Errorf("create output directory: %w", err)
+	if opts.DryRun == nil {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return nil, fmt.Errorf("create output directory: %w", err)
+		}
 	}
 
-	currentTag := git.GetTag(ctx)
-	commitHash := git.GetCommitHash(ctx)
-	buildDate := time.Now().Format(time.RFC3339)
+	run := newRunInputs(ctx, cfg, outDir, buildDate)
 
-	// Extract referenced env vars from all ldflags (compiled once, not in loop)
+	// Extract referenced env vars from all templated build settings
+	// (compiled once, not in loop)
 	envVarNames := make(map[string]bool)
 	for _, buildCfg := range cfg.Builds {
-		for _, ldflag := range buildCfg.Ldflags {
+		templates := slices.Concat([]string{buildCfg.Main}, buildCfg.Flags, buildCfg.Ldflags,
+			buildCfg.Gcflags, buildCfg.Asmflags, buildCfg.Tags, buildCfg.Env)
+		for _, o := range buildCfg.Overrides {
+			templates = slices.Concat(templates, o.Flags, o.Ldflags, o.Gcflags, o.Asmflags, o.Env)
+		}
+		for _, ldflag := range templates {
 			matches := envVarRegex.FindAllStringSubmatch(ldflag, -1)
 			for _, match := range matches {
 				if len(match) > 1 {
@@ -68,163 +310,669 @@ func Run(ctx context.Context, cfg *config.Config) ([]Artifact, error) {
 		}
 	}
 
+	// Unset variables render empty rather than as "<no value>", in ldflags
+	// too, so templated flags and env entries that reference them are
+	// dropped
 	envVars := make(map[string]string)
 	for name := range envVarNames {
-		if value := os.Getenv(name); value != "" {
-			envVars[name] = value
-		}
+		envVars[name] = envvars.Named(name)
 	}
 
 	tmplData := struct {
 		Version string
+		Tag     string
+		Channel string
 		Commit  string
 		Date    string
 		Env     map[string]string
 	}{
-		Version: currentTag,
+		Version: version,
+		Tag:     currentTag,
+		Channel: cfg.Channel,
 		Commit:  commitHash,
 		Date:    buildDate,
 		Env:     envVars,
 	}
 
-	var allArtifacts []Artifact
+	var (
+		allArtifacts []Artifact
+		commands     []manifest.Command
+	)
 
 	concurrency := cfg.Concurrency
 	if concurrency <= 0 {
 		concurrency = runtime.NumCPU()
 	}
 
+	// Every target of every build is one task; as each go build uses
+	// several cores, parallelism caps the processes separately
+	parallelism := cmp.Or(opts.Parallelism, cfg.Parallelism, concurrency)
+	eg := errgroup.Group{}
+	eg.SetLimit(parallelism)
+	log.Printf("Running up to %d go build processes in parallel", parallelism)
+	// tasks are closed as the queued targets finish, for checkInjected
+	var tasks []chan struct{}
+
+	changes := newChangeDetector()
+	stage := newStager(cfg)
+	// wasmExec is the wasm_exec.js of the local Go distribution, looked up once
+	var wasmExec string
+	// changelog is generated once for all builds with embed_changelog
+	var changelog, changelogDir string
+	defer func() {
+		if changelogDir != "" {
+			_ = os.RemoveAll(changelogDir)
+		}
+	}()
+
 	for _, buildCfg := range cfg.Builds {
-		binaryBase := buildCfg.OutputName
-		if binaryBase == "" {
-			parts := strings.Split(buildCfg.Main, "/")
-			binaryBase = parts[len(parts)-1]
+		mainPath, err := tmpl.Process("main", buildCfg.Main, tmplData)
+		if err != nil {
+			return nil, fmt.Errorf("build %s: main: %w", binaryName(buildCfg), err)
+		}
+		buildCfg.Main = mainPath
+		binaryBase := binaryName(buildCfg)
+
+		if !opts.ForceAll {
+			skip, reason, err := changes.skip(ctx, buildCfg.OnlyIfChanged, buildCfg.TagPrefix)
+			if err != nil {
+				return nil, fmt.Errorf("build %s: %w", binaryBase, err)
+			}
+			if skip {
+				log.Printf("Skipping build %s: %s", binaryBase, reason)
+				continue
+			}
 		}
 
 		usePlatformSuffix := !buildCfg.DisablePlatformSuffix
+		dir := buildDir(cfg, buildCfg)
 
-		// Process ldflags templates
-		var processedLdflags []string
-		for _, ldflag := range buildCfg.Ldflags {
-			result, err := tmpl.Process("ldflag", ldflag, tmplData)
+		buildAuth := auth
+		if buildCfg.GitAuth != nil {
+			buildAuth, err = gitauth.Setup(buildCfg.GitAuth, cfg.GoPrivate)
 			if err != nil {
-				return nil, fmt.Errorf("process ldflag template %q: %w", ldflag, err)
+				return nil, fmt.Errorf("build %s: %w", binaryBase, err)
 			}
-			processedLdflags = append(processedLdflags, result)
+			defer func() { _ = buildAuth.Close() }()
 		}
 
-		eg := errgroup.Group{}
-		eg.SetLimit(concurrency)
-
-		log.Printf("Use %d CPU cores for building...\n", concurrency)
+		// goBinary compiles the targets: go, or the build's gobinary
+		var goBinary string
+		if buildCfg.Prebuilt == nil {
+			if goBinary, err = lookGoBinary(dir, buildCfg); err != nil {
+				return nil, fmt.Errorf("build %s: %w", binaryBase, err)
+			}
+			if module, err := modulePath(ctx, goBinary, dir, buildCfg, buildAuth.Vars()); err != nil {
+				log.Printf("Warning: build %s: module path unknown: %v", binaryBase, err)
+			} else {
+				log.Printf("Build %s compiles %s of module %s", binaryBase, buildCfg.Main, module)
+			}
+		}
 
-		type buildTarget struct {
-			goos, goarch, goarm string
+		tags, err := renderTags(buildCfg.Tags, tmplData)
+		if err != nil {
+			return nil, fmt.Errorf("build %s: %w", binaryBase, err)
 		}
 
-		// Collect all build targets
-		var targets []buildTarget
-		for _, goos := range buildCfg.Goos {
-			for _, goarch := range buildCfg.Goarch {
-				if goarch == "arm" && goos != "linux" {
-					continue
+		// embedLdflag and embedArgs carry the changelog into every target
+		var (
+			embedLdflag string
+			embedArgs   []string
+		)
+		if buildCfg.EmbedChangelog != nil && buildCfg.Prebuilt == nil {
+			if changelogDir == "" {
+				if changelog, err = releaseChangelog(ctx, cfg, currentTag); err != nil {
+					return nil, err
 				}
-				if goarch == "arm" && len(buildCfg.Goarm) > 0 {
-					for _, goarm := range buildCfg.Goarm {
-						targets = append(targets, buildTarget{goos, goarch, goarm})
-					}
-				} else {
-					targets = append(targets, buildTarget{goos, goarch, ""})
+				if changelogDir, err = os.MkdirTemp("", "gcx-changelog-"); err != nil {
+					return nil, fmt.Errorf("embed changelog: %w", err)
 				}
 			}
+			ldflag, args, err := changelogArgs(ctx, goBinary, dir, buildCfg, changelog, changelogDir)
+			if err != nil {
+				return nil, fmt.Errorf("build %s: %w", binaryBase, err)
+			}
+			embedLdflag, embedArgs = ldflag, args
+		}
+
+		// Recorded with the commands; prebuilt builds have none
+		var goVersion string
+		if buildCfg.Prebuilt == nil {
+			if goVersion, err = toolchain.Version(ctx, goBinary, dir); err != nil {
+				log.Printf("Warning: build %s: go version not recorded with its commands: %v", binaryBase, err)
+			}
+		}
+
+		for _, key := range buildCfg.UnusedVariants() {
+			log.Printf("Warning: build %s: %s is set but goarch has no matching architecture", binaryBase, key)
+		}
+		for _, target := range ResolveTargets(buildCfg) {
+			if target.Skipped() {
+				log.Printf("Skipping %s: %s", target, target.SkipReason)
+			}
+		}
+		targets := buildTargets(buildCfg, opts.SingleTarget)
+
+		if buildCfg.IncludeWasmExec && wasmExec == "" {
+			if wasmExec, err = wasmExecSource(ctx, goBinary, dir); err != nil {
+				return nil, fmt.Errorf("build %s: %w", binaryBase, err)
+			}
 		}
 
 		for _, target := range targets {
 			artifact := Artifact{
-				BinaryName: binaryBase,
-				Version:    currentTag,
-				OS:         target.goos,
-				Arch:       target.goarch,
-				Arm:        target.goarm,
+				BinaryName:   binaryBase,
+				ID:           buildCfg.ID,
+				Version:      version,
+				Tag:          currentTag,
+				Channel:      cfg.Channel,
+				Commit:       commitHash,
+				OS:           target.Goos,
+				Arch:         target.Goarch,
+				Arm:          target.Goarm,
+				Variant:      target.Variant,
+				Group:        buildCfg.Group,
+				Builds:       buildIDs(cfg, buildCfg),
+				Instrumented: buildCfg.Instrumented(),
 			}
+			buildmode := targetBuildmode(buildCfg, target)
+			p := platformFor(buildCfg, target.Goos, buildmode)
+			artifact.Ext, artifact.Executable = p.Ext, p.Executable
 			artifact.DirPath = outputDir(usePlatformSuffix, outDir, artifact)
+			if buildCfg.IncludeWasmExec && target.Goos == "js" {
+				artifact.Extras = []string{wasmExecName}
+			}
+			if hasHeader(buildmode) {
+				artifact.Extras = append(artifact.Extras, artifact.BinaryName+".h")
+			}
 
 			allArtifacts = append(allArtifacts, artifact)
+			// --fail-at build:N counts targets across builds from 1 and fails
+			// after targets 1..N-1 finished
+			task := strconv.Itoa(len(allArtifacts))
 
 			// Capture for goroutine
 			t := target
 			dirPath := artifact.DirPath
+			fileName := artifact.FileName()
+			executable := artifact.Executable
+			extras := artifact.Extras
+			// upx only packs executables; wasm modules are left as they are
+			compress := executable && !opts.SkipUPX && buildCfg.UPX.Applies(t.Goos, t.Goarch)
 
-			eg.Go(func() error {
-				envs := os.Environ()
-				envs = append(envs, "GOOS="+t.goos, "GOARCH="+t.goarch)
-				if t.goarm != "" {
-					envs = append(envs, "GOARM="+t.goarm)
+			if buildCfg.Prebuilt != nil {
+				src, err := prebuiltPath(cfg, buildCfg, artifact)
+				if err != nil {
+					return nil, fmt.Errorf("build %s: %w", binaryBase, err)
+				}
+				// upx rewrites the binary, which must not reach a linked src
+				strategy := stage.strategy
+				if compress && strategy == config.StagingHardlink {
+					strategy = config.StagingCopy
+				}
+				if opts.DryRun != nil {
+					fmt.Fprintf(opts.DryRun, "Would %s %s to %s\n", strategy, src, filepath.Join(dirPath, fileName))
+					if compress {
+						printUPX(opts.DryRun, buildCfg.UPX, filepath.Join(dirPath, fileName))
+					}
+					continue
 				}
-				envs = append(envs, buildCfg.Env...)
+				earlier, done := tasks, make(chan struct{})
+				tasks = append(tasks, done)
+				eg.Go(func() error {
+					defer close(done)
+					log.Printf("Staging prebuilt %s for %s from %s by %s...", binaryBase, t, src, strategy)
+					err := checkInjected(ctx, earlier, task, t.String())
+					if err == nil {
+						err = copyPrebuilt(stage, strategy, src, dirPath, fileName, executable)
+					}
+					if err != nil {
+						return fmt.Errorf("prebuilt %s for %s: %w", binaryBase, t, err)
+					}
+					if compress {
+						return compressUPX(ctx, buildCfg.UPX, filepath.Join(dirPath, fileName))
+					}
+					return nil
+				})
+				continue
+			}
 
-				outputName := filepath.Join(dirPath, binaryBase)
+			// Overrides may change the settings of each target
+			flagTemplates, ldflagTemplates, envTemplates := targetSettings(buildCfg, t)
+			cgo, fallback := cgoEnv(buildCfg.CGO, t)
+			if fallback {
+				log.Printf("Building %s for %s with CGO_ENABLED=0: no toolchain in cgo.targets", binaryBase, t)
+			}
+			ldflags, err := targetLdflags(ldflagTemplates, tmplData, embedLdflag)
+			if err != nil {
+				return nil, fmt.Errorf("build %s for %s: %w", binaryBase, t, err)
+			}
+			flags, err := renderFlags(flagTemplates, tmplData)
+			if err != nil {
+				return nil, fmt.Errorf("build %s for %s: %w", binaryBase, t, err)
+			}
+			gcflagTemplates, asmflagTemplates := targetToolFlags(buildCfg, t)
+			gcflags, err := toolFlags("gcflags", gcflagTemplates, tmplData)
+			if err != nil {
+				return nil, fmt.Errorf("build %s for %s: %w", binaryBase, t, err)
+			}
+			asmflags, err := toolFlags("asmflags", asmflagTemplates, tmplData)
+			if err != nil {
+				return nil, fmt.Errorf("build %s for %s: %w", binaryBase, t, err)
+			}
+			env, err := renderEnv(envTemplates, tmplData)
+			if err != nil {
+				return nil, fmt.Errorf("build %s for %s: %w", binaryBase, t, err)
+			}
 
-				args := []string{"build"}
-				args = append(args, buildCfg.Flags...)
-				if len(processedLdflags) > 0 {
-					args = append(args, "-ldflags", strings.Join(processedLdflags, " "))
+			envs := os.Environ()
+			envs = append(envs, "GOOS="+t.Goos, "GOARCH="+t.Goarch)
+			if variant := t.variant(); variant != "" {
+				env, _ := buildCfg.Variants(t.Goarch)
+				envs = append(envs, env+"="+variant)
+			}
+			envs = append(envs, buildAuth.Vars()...)
+			envs = append(envs, env...)
+			envs = append(envs, cgo...)
+
+			outputName := filepath.Join(dirPath, fileName)
+			if dir != "" {
+				// go build runs in the build or config directory; keep -o pointing at out_dir
+				abs, err := filepath.Abs(outputName)
+				if err != nil {
+					return nil, fmt.Errorf("build %s/%s: %w", t.Goos, t.Goarch, err)
 				}
-				args = append(args, "-o", outputName, buildCfg.Main)
+				outputName = abs
+			}
 
-				if t.goarm != "" {
-					log.Printf("Building %s for %s/%s arm%s...", binaryBase, t.goos, t.goarch, t.goarm)
-				} else {
-					log.Printf("Building %s for %s/%s...", binaryBase, t.goos, t.goarch)
+			args := buildCommand(buildCfg)
+			args = append(args, buildCfg.Coverage.Flags()...)
+			if buildmode != "" {
+				args = append(args, "-buildmode="+buildmode)
+			}
+			args = append(args, flags...)
+			if tags != "" {
+				args = append(args, "-tags", tags)
+			}
+			args = append(args, embedArgs...)
+			args = append(args, gcflags...)
+			args = append(args, asmflags...)
+			if ldflags != "" {
+				args = append(args, "-ldflags", ldflags)
+			}
+			args = append(args, "-o", outputName, buildCfg.Main)
+			var generated string
+			if len(embedArgs) > 0 {
+				// The overlay names the changelog by a temporary path
+				generated = changelog
+			}
+			commands = append(commands, recordCommand(filepath.Join(dirPath, fileName), artifact,
+				append([]string{goBinary}, args...), dir, envs, goVersion, run,
+				[]string{buildAuth.Dir(), changelogDir}, generated))
+
+			if opts.DryRun != nil {
+				printCommand(opts.DryRun, dir, envs, goBinary, args)
+				if slices.Contains(extras, wasmExecName) {
+					fmt.Fprintf(opts.DryRun, "Would copy %s to %s\n", wasmExec, filepath.Join(dirPath, wasmExecName))
+				}
+				if compress {
+					printUPX(opts.DryRun, buildCfg.UPX, outputName)
 				}
+				continue
+			}
 
-				cmd := exec.CommandContext(ctx, "go", args...)
+			earlier, done := tasks, make(chan struct{})
+			tasks = append(tasks, done)
+			eg.Go(func() error {
+				defer close(done)
+				log.Printf("Building %s for %s...", binaryBase, t)
+
+				// A timeout kills this target only; the others keep building
+				buildCtx := ctx
+				if buildCfg.Timeout > 0 {
+					var cancel context.CancelFunc
+					buildCtx, cancel = context.WithTimeout(ctx, buildCfg.Timeout.Std())
+					defer cancel()
+				}
+				cmd := exec.CommandContext(buildCtx, goBinary, args...)
 				cmd.Env = envs
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err := cmd.Run(); err != nil {
-					return fmt.Errorf("build %s/%s: %w", t.goos, t.goarch, err)
+				cmd.Dir = dir
+				// Compiler and linker processes of a killed go build may
+				// hold its output open; stop waiting for them
+				cmd.WaitDelay = killWaitDelay
+				// Lines of targets building at the same time name their target
+				prefix := fmt.Sprintf("[%s %s] ", binaryBase, t)
+				out := newLineWriter(stdout, prefix)
+				errOut := newLineWriter(os.Stderr, prefix)
+				defer func() { _ = out.Flush() }()
+				defer func() { _ = errOut.Flush() }()
+				cmd.Stdout = out
+				var output bytes.Buffer
+				cmd.Stderr = io.MultiWriter(errOut, &output)
+				if annotations != nil {
+					cmd.Stderr = &output
+				}
+				start := time.Now()
+				err := checkInjected(ctx, earlier, task, t.String())
+				if err == nil {
+					err = cmd.Run()
+				}
+				if annotations != nil {
+					title := fmt.Sprintf("%s %s %s %s", filepath.Base(goBinary), strings.Join(buildCommand(buildCfg), " "), binaryBase, t)
+					writeAnnotations(os.Stderr, annotations, dir, title, output.Bytes(), err)
+				}
+				if err != nil && buildCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+					return fmt.Errorf("build %s for %s: killed after the timeout of %s: %w", binaryBase, t, buildCfg.Timeout, err)
+				}
+				if err != nil && buildmode != "" {
+					// go reports unsupported modes as "-buildmode=... not
+					// supported on ..."; name the target alongside it
+					return fmt.Errorf("build %s for %s with -buildmode=%s: %w: %s", binaryBase, t, buildmode, err, lastLine(output.Bytes()))
+				}
+				if err != nil {
+					return fmt.Errorf("build %s/%s: %w", t.Goos, t.Goarch, err)
+				}
+				metrics.ObserveBuild(binaryBase, t.String(), time.Since(start))
+
+				// go build marks every output executable; modules such as
+				// wasm are data for a host runtime
+				if !executable {
+					if err := os.Chmod(outputName, 0o644); err != nil {
+						return fmt.Errorf("build %s/%s: %w", t.Goos, t.Goarch, err)
+					}
+				}
+				if slices.Contains(extras, wasmExecName) {
+					if err := copyFile(wasmExec, filepath.Join(dirPath, wasmExecName)); err != nil {
+						return fmt.Errorf("build %s/%s: %w", t.Goos, t.Goarch, err)
+					}
+				}
+				if compress {
+					return compressUPX(ctx, buildCfg.UPX, outputName)
 				}
 				return nil
 			})
 		}
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, fmt.Errorf("build error: %w", err)
+	}
+
+	if opts.Commands != nil {
+		if commands == nil {
+			commands = []manifest.Command{}
+		}
+		data, err := json.MarshalIndent(commands, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := opts.Commands.Write(append(data, '\n')); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DryRun != nil {
+		if !opts.SkipArchives {
+			if err := printArchives(opts.DryRun, cfg, outDir, allArtifacts); err != nil {
+				return nil, err
+			}
+		}
+		if len(cfg.After.Hooks) > 0 && !opts.SkipAfterHooks {
+			printHooks(opts.DryRun, "after", selectHooks("after", cfg.After.Hooks, opts))
+		}
+		return allArtifacts, nil
+	}
+
+	var entries []manifest.Artifact
+	if opts.ArchiveTo != nil {
+		if len(allArtifacts) != 1 {
+			return nil, fmt.Errorf("nothing to stream: the build was skipped by only_if_changed")
+		}
+		a := allArtifacts[0]
+		log.Printf("Streaming tar.gz of %s to stdout", a.DirPath)
+		if err := (&archive.TarGz{}).Write(opts.ArchiveTo, a.DirPath); err != nil {
+			return nil, fmt.Errorf("stream archive: %w", err)
+		}
+		entries = artifactEntries(allArtifacts, nil)
+	} else {
+		var (
+			archives map[string][]string
+			contents map[string][]archive.Entry
+		)
+		if !opts.SkipArchives {
+			if archives, contents, err = createArchives(ctx, cfg, stage, outDir, allArtifacts); err != nil {
+				return nil, fmt.Errorf("create archives: %w", err)
+			}
+		}
+
+		entries = artifactEntries(allArtifacts, archives)
+		if entries, err = recordContents(entries, contents); err != nil {
+			return nil, err
+		}
+		sboms, err := generateSBOMs(ctx, cfg, cfg.Release(currentTag), entries)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, sboms...)
+
+		generated, err := generateFiles(cfg, outDir, GeneratedFileData{
+			Version:   version,
+			Channel:   cfg.Channel,
+			Commit:    commitHash,
+			Date:      buildDate,
+			Env:       envvars.Environ(),
+			Artifacts: entries,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-		if err := eg.Wait(); err != nil {
-			return nil, fmt.Errorf("build error: %w", err)
+		signed, err := signChecksums(ctx, cfg, currentTag, outDir, entries, opts.SkipSign)
+		if err != nil {
+			return nil, err
 		}
+		entries = append(entries, fileEntries(append(generated, signed...))...)
+	}
+
+	// Recorded for gcx release diff; prebuilt-only configs may have no toolchain
+	goVersion, err := toolchain.Version(ctx, "", cfg.Dir)
+	if err != nil {
+		log.Printf("Warning: go version not recorded in %s: %v", manifest.FileName, err)
 	}
 
-	// Create archives
-	if err := createArchives(ctx, cfg, outDir, allArtifacts); err != nil {
-		return nil, fmt.Errorf("create archives: %w", err)
+	// The manifest also marks the directory as created by gcx (see gcx gc)
+	if err := writeManifest(outDir, cfg.Release(currentTag), goVersion, start, entries, commands); err != nil {
+		return nil, err
 	}
 
 	// Execute after hooks
-	if len(cfg.After.Hooks) > 0 {
-		if err := hook.Run(ctx, cfg.After.Hooks); err != nil {
+	if len(cfg.After.Hooks) > 0 && !opts.SkipAfterHooks {
+		if err := hook.RunEnv(ctx, cfg.Dir, stdout, auth.Vars(), selectHooks("after", cfg.After.Hooks, opts)); err != nil {
 			return nil, err
 		}
 	}
 
+	if summary := stage.summary(); summary != "" {
+		log.Print(summary)
+	}
+	if skipped := opts.skipped(); len(skipped) > 0 {
+		log.Printf("Build finished; skipped %s", strings.Join(skipped, ", "))
+	}
+
 	return allArtifacts, nil
 }
 
+// checkSingleTarget fails unless the builds resolve to exactly one target,
+// ignoring only_if_changed.
+func checkSingleTarget(cfg *config.Config, singleTarget bool) error {
+	var names []string
+	for _, buildCfg := range cfg.Builds {
+		var targets []Target
+		for _, target := range ResolveTargets(buildCfg) {
+			if !target.Skipped() {
+				targets = append(targets, target)
+			}
+		}
+		if singleTarget {
+			targets = forHost(targets)
+		}
+		for _, target := range targets {
+			names = append(names, target.Build+" "+target.String())
+		}
+	}
+	if len(names) != 1 {
+		return fmt.Errorf("streaming an archive requires exactly one target, but %d would be built: %s",
+			len(names), strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// signChecksums writes the checksums files for the files in outDir when
+// checksum or signs is configured, and signs them when signs is configured
+// and skipSign is false. cosign also signs the archives among entries.
+// It returns the paths it created.
+func signChecksums(ctx context.Context, cfg *config.Config, tag, outDir string, entries []manifest.Artifact, skipSign bool) ([]string, error) {
+	if !cfg.Checksum.Enabled() && len(cfg.Signs) == 0 {
+		return nil, nil
+	}
+	prefix, err := cfg.Checksum.Prefix(cfg.Release(tag))
+	if err != nil {
+		return nil, fmt.Errorf("checksum: %w", err)
+	}
+	paths, err := checksums.Write(outDir, cfg.Checksum.Algorithms(), checksums.Options{Format: cfg.Checksum.Format, Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Signs) == 0 || skipSign {
+		return paths, nil
+	}
+
+	if cfg.Signs[0].Provider == "cosign" {
+		return signCosign(ctx, cfg.Signs[0], paths, entries)
+	}
+
+	signer, err := sign.NewSSH(ctx, cfg.Signs[0])
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = signer.Close() }()
+	created := slices.Clone(paths)
+	for _, sumsPath := range paths {
+		log.Printf("Signing %s with ssh-keygen", sumsPath)
+		sigPath, err := signer.Sign(ctx, sumsPath)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, sigPath)
+	}
+	if line, err := signer.AllowedSigners(ctx); err != nil {
+		log.Printf("Warning: cannot print allowed_signers entry: %v", err)
+	} else {
+		log.Printf("Verifiers need this line in their allowed_signers file:\n%s", line)
+	}
+	return created, nil
+}
+
+// signCosign signs the checksums files at paths and every archive among
+// entries with cosign, returning the checksums files and the signatures.
+func signCosign(ctx context.Context, cfg config.SignConfig, paths []string, entries []manifest.Artifact) ([]string, error) {
+	files := slices.Clone(paths)
+	for _, e := range entries {
+		if e.Type == manifest.TypeArchive {
+			files = append(files, e.Path)
+		}
+	}
+
+	signer := sign.NewCosign(cfg)
+	mode := "key"
+	if signer.Keyless() {
+		mode = "keyless"
+	}
+	created := slices.Clone(paths)
+	for _, path := range files {
+		log.Printf("Signing %s with cosign (%s)", path, mode)
+		outputs, err := signer.Sign(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, outputs...)
+	}
+	return created, nil
+}
+
+// coverSuffix ends the output directory name of coverage-instrumented
+// builds, e.g. myapp_v1.0.0_linux_amd64_cover.
+const coverSuffix = "_cover"
+
 // outputDir returns the directory path for a built artifact.
 func outputDir(usePlatformSuffix bool, outDir string, a Artifact) string {
+	name := fmt.Sprintf("%s_%s", a.DirName(), a.Version)
 	if usePlatformSuffix {
-		name := fmt.Sprintf("%s_%s_%s_%s", a.BinaryName, a.Version, a.OS, a.Arch)
-		if a.Arm != "" {
-			name = fmt.Sprintf("%s_%s_%s_%s_%s", a.BinaryName, a.Version, a.OS, a.Arch, a.Arm)
+		name = fmt.Sprintf("%s_%s_%s_%s", a.DirName(), a.Version, a.OS, a.Arch)
+		if variant := cmp.Or(a.Arm, a.Variant); variant != "" {
+			name = fmt.Sprintf("%s_%s_%s_%s_%s", a.DirName(), a.Version, a.OS, a.Arch, variant)
 		}
-		return filepath.Join(outDir, name)
 	}
-	return filepath.Join(outDir, fmt.Sprintf("%s_%s", a.BinaryName, a.Version))
+	// Instrumented builds never share a directory, or archive, with normal ones
+	if a.Instrumented {
+		name += coverSuffix
+	}
+	return filepath.Join(outDir, name)
+}
+
+// archiveBaseName renders the archive name of artifact without extension
+// for the archive config at index i. It defaults to the name of the
+// artifact directory.
+func archiveBaseName(cfg *config.Config, i int, artifact Artifact, shortSha256 string) (string, error) {
+	archiveCfg := cfg.Archives[i]
+	if archiveCfg.NameTemplate == "" {
+		return filepath.Base(artifact.DirPath), nil
+	}
+	tmplData := templateData(cfg, artifact)
+	tmplData.ShortSha256 = shortSha256
+	name, err := tmpl.Process("archive_name", archiveCfg.NameTemplate, tmplData)
+	if err != nil {
+		return "", fmt.Errorf("archives[%d]: process name_template: %w", i, err)
+	}
+	return name, nil
+}
+
+// usesShortSha256 reports whether an archive name template embeds the
+// archive content hash.
+func usesShortSha256(nameTemplate string) bool {
+	return strings.Contains(nameTemplate, "ShortSha256")
+}
+
+// renameHashed renames the archive written to tmpPath to its final name,
+// rendered with the short SHA-256 of its content, and returns the new path.
+// The temporary file is removed when the rename fails.
+func renameHashed(cfg *config.Config, i int, artifact Artifact, tmpPath, ext string) (string, error) {
+	digest, err := checksum.File(tmpPath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("checksum archive: %w", err)
+	}
+	name, err := archiveBaseName(cfg, i, artifact, digest.ShortSHA256())
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+	finalPath := filepath.Join(filepath.Dir(tmpPath), name+"."+ext)
+	if _, err := os.Lstat(finalPath); err == nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("rename %s to %s: destination already exists", tmpPath, finalPath)
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("rename %s to %s: %w", tmpPath, finalPath, err)
+	}
+	return finalPath, nil
 }
 
 // createArchives creates archives for all built artifacts using structured metadata.
-func createArchives(ctx context.Context, cfg *config.Config, artifactsDir string, artifacts []Artifact) error {
+// It returns the archive paths created for each artifact directory and the
+// entries of each archive.
+func createArchives(ctx context.Context, cfg *config.Config, stage *stager, artifactsDir string, artifacts []Artifact) (map[string][]string, map[string][]archive.Entry, error) {
 	if len(cfg.Archives) == 0 {
-		return nil
+		return nil, nil, nil
 	}
 
 	concurrency := cfg.Concurrency
@@ -237,51 +985,205 @@ func createArchives(ctx context.Context, cfg *config.Config, artifactsDir string
 
 	log.Printf("Use %d CPU cores for creating archives...\n", concurrency)
 
+	var modTime time.Time
+	if slices.ContainsFunc(cfg.Archives, func(a config.ArchiveConfig) bool { return a.Reproducible }) {
+		var err error
+		if modTime, err = reproducibleTime(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Archive configs with the same extra files and reproducibility share
+	// a source, whose archives are written from one read of the files
+	var (
+		sources  []archive.Source
+		sourceOf = make([]int, len(cfg.Archives))
+	)
+	for j, archiveCfg := range cfg.Archives {
+		files, err := archiveFiles(cfg.Dir, archiveCfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("archives[%d]: %w", j, err)
+		}
+		src := archive.Source{Extras: files}
+		if archiveCfg.Reproducible {
+			src.ModTime = modTime
+		}
+		sourceOf[j] = slices.IndexFunc(sources, func(s archive.Source) bool {
+			return slices.Equal(s.Extras, src.Extras) && s.ModTime.Equal(src.ModTime)
+		})
+		if sourceOf[j] < 0 {
+			sourceOf[j] = len(sources)
+			sources = append(sources, src)
+		}
+	}
+
 	var archivedDirs []string
+	archives := make(map[string][]string)
+	contents := make(map[string][]archive.Entry)
+	// mu guards contents and binaries
+	var mu sync.Mutex
 
+	// The binary format copies every binary, also of grouped builds, out of
+	// its directory. binaries holds the copies per directory in artifact
+	// order, sized before the copies start; each task fills its own slot
+	type binaryCopy struct {
+		artifact Artifact
+		config   int
+		slot     int
+	}
+	var copies []binaryCopy
+	binaries := make(map[string][]string)
 	for _, artifact := range artifacts {
-		tmplData := ArchiveTemplateData{
-			Binary:  artifact.BinaryName,
-			Version: artifact.Version,
-			Os:      artifact.OS,
-			Arch:    artifact.Arch,
+		for j, archiveCfg := range cfg.Archives {
+			if slices.Contains(archiveCfg.Formats, archive.BinaryFormat) && archiveCfg.Applies([]string{artifact.ID}) {
+				copies = append(copies, binaryCopy{artifact, j, len(binaries[artifact.DirPath])})
+				binaries[artifact.DirPath] = append(binaries[artifact.DirPath], "")
+			}
 		}
-
-		for _, archiveCfg := range cfg.Archives {
-			archiveName := filepath.Base(artifact.DirPath)
-			if archiveCfg.NameTemplate != "" {
-				result, err := tmpl.Process("archive_name", archiveCfg.NameTemplate, tmplData)
-				if err != nil {
-					return fmt.Errorf("process archive name template: %w", err)
+	}
+	for _, c := range copies {
+		eg.Go(func() error {
+			path, err := copyBinary(cfg, stage, c.config, c.artifact, artifactsDir)
+			if err != nil {
+				return fmt.Errorf("copy binary of %s: %w", c.artifact.DirPath, err)
+			}
+			if cfg.Archives[c.config].VerifyContents {
+				if err := verifyBinary(path, c.artifact); err != nil {
+					return err
 				}
-				archiveName = result
+			}
+			mu.Lock()
+			binaries[c.artifact.DirPath][c.slot] = path
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	for _, artifact := range artifacts {
+		// Grouped artifacts share a directory, which is archived once
+		if _, done := archives[artifact.DirPath]; done {
+			continue
+		}
+
+		// Every format of every archive config is written by one task, so
+		// the source directory is read once per source however many formats
+		// it has
+		var (
+			archivers []archive.Archiver
+			paths     []string
+			renames   []func(tmpPath string) (string, error)
+			configs   []int
+		)
+		for j, archiveCfg := range cfg.Archives {
+			if !archiveCfg.Applies(artifact.Builds) {
+				continue
+			}
+			hashed := usesShortSha256(archiveCfg.NameTemplate)
+			archiveName, err := archiveBaseName(cfg, j, artifact, "")
+			if err != nil {
+				return nil, nil, err
 			}
 
 			for _, format := range archiveCfg.Formats {
-				archiver, err := archive.New(format)
-				if err != nil {
-					log.Printf("Unsupported archive format: %s", format)
+				if format == archive.BinaryFormat {
 					continue
 				}
+				archiver, err := archive.New(format, archiveCfg.CompressionLevel)
+				if err != nil {
+					return nil, nil, err
+				}
 
-				archiveFileName := archiveName + "." + archiver.Extension()
-				archivePath := filepath.Join(artifactsDir, archiveFileName)
-				sourcePath := artifact.DirPath
+				ext := archiver.Extension()
+				archivePath := filepath.Join(artifactsDir, archiveName+"."+ext)
+				var rename func(string) (string, error)
+				if hashed {
+					// The final name depends on the content, so the archive
+					// is written under a hidden name and renamed afterwards
+					archivePath = filepath.Join(artifactsDir, fmt.Sprintf(".%s.%d.%s.tmp", filepath.Base(artifact.DirPath), j, ext))
+					rename = func(tmpPath string) (string, error) {
+						return renameHashed(cfg, j, artifact, tmpPath, ext)
+					}
+				}
+				archivers = append(archivers, archiver)
+				paths = append(paths, archivePath)
+				renames = append(renames, rename)
+				configs = append(configs, j)
+			}
+		}
+		if len(archivers) == 0 {
+			continue
+		}
 
-				archivedDirs = append(archivedDirs, artifact.DirPath)
+		sourcePath := artifact.DirPath
+		archivedDirs = append(archivedDirs, sourcePath)
+		// The task replaces hashed paths in its own slice once written
+		final := slices.Clone(paths)
+		archives[sourcePath] = final
 
-				eg.Go(func() error {
-					if err := archiver.Archive(sourcePath, archivePath); err != nil {
-						return fmt.Errorf("create %s archive: %w", format, err)
+		eg.Go(func() error {
+			// The entries of every archive, listed while writing it
+			written := make([][]archive.Entry, len(paths))
+			for n, src := range sources {
+				src.Path = sourcePath
+				var (
+					srcArchivers []archive.Archiver
+					srcPaths     []string
+					srcIndexes   []int
+				)
+				for i := range archivers {
+					if sourceOf[configs[i]] == n {
+						srcArchivers = append(srcArchivers, archivers[i])
+						srcPaths = append(srcPaths, paths[i])
+						srcIndexes = append(srcIndexes, i)
 					}
-					return nil
-				})
+				}
+				if len(srcArchivers) == 0 {
+					continue
+				}
+				entries, err := archive.ArchiveAll(src, srcArchivers, srcPaths)
+				if err != nil {
+					return fmt.Errorf("create archives of %s: %w", sourcePath, err)
+				}
+				for _, i := range srcIndexes {
+					written[i] = entries
+				}
 			}
-		}
+			for i, rename := range renames {
+				finalPath := paths[i]
+				if rename != nil {
+					var err error
+					if finalPath, err = rename(paths[i]); err != nil {
+						return err
+					}
+				}
+				if cfg.Archives[configs[i]].VerifyContents {
+					if err := verifyArchive(finalPath, artifact); err != nil {
+						return err
+					}
+				}
+				entries := written[i]
+				if !cfg.Archives[configs[i]].Reproducible {
+					// Times and owners are only meaningful when normalized
+					entries = slices.Clone(entries)
+					for k := range entries {
+						entries[k].Header = nil
+					}
+				}
+				final[i] = finalPath
+				mu.Lock()
+				contents[finalPath] = entries
+				mu.Unlock()
+			}
+			return nil
+		})
 	}
 
 	if err := eg.Wait(); err != nil {
-		return err
+		return nil, nil, err
+	}
+	for dir, paths := range binaries {
+		archives[dir] = append(archives[dir], paths...)
+		archivedDirs = append(archivedDirs, dir)
 	}
 
 	// Remove archived source directories
@@ -297,5 +1199,27 @@ func createArchives(ctx context.Context, cfg *config.Config, artifactsDir string
 	}
 
 	log.Println("All archives created successfully.")
-	return nil
+	return archives, contents, nil
+}
+
+// checkInjected returns the --fail-at failure armed for the target with
+// ids. It first waits for the earlier targets, which may still be building
+// in parallel, so that build:N fails after all the work queued before N.
+func checkInjected(ctx context.Context, earlier []chan struct{}, ids ...string) error {
+	if inject.Armed("build", ids...) {
+		for _, done := range earlier {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return inject.Check("build", ids...)
+}
+
+// lastLine returns the last non-blank line of output, trimmed.
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
 }
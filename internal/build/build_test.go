@@ -1,7 +1,26 @@
 package build
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/configtypes"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/manifest"
 )
 
 func TestOutputDir(t *testing.T) {
@@ -43,3 +62,529 @@ func TestOutputDir(t *testing.T) {
 		})
 	}
 }
+
+func TestRunSkip(t *testing.T) {
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "app_linux_amd64"), []byte("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hookDir := t.TempDir()
+	before, after := filepath.Join(hookDir, "before"), filepath.Join(hookDir, "after")
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		OutDir: outDir,
+		Before: config.HooksConfig{Hooks: []config.Hook{{Cmd: "touch " + before}}},
+		After:  config.HooksConfig{Hooks: []config.Hook{{Cmd: "touch " + after}}},
+		Builds: []config.BuildConfig{{
+			OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+			Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+	}
+	opts := Options{SkipBeforeHooks: true, SkipArchives: true, SkipAfterHooks: true}
+	if _, err := Run(context.Background(), cfg, opts); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, path := range []string{before, after} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("hook creating %s ran", filepath.Base(path))
+		}
+	}
+	// artifacts.json lists the raw binary so it can still be published
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Artifacts) != 1 || m.Artifacts[0].Name != "app" || m.Artifacts[0].Type != manifest.TypeBinary || m.Artifacts[0].SHA256 == "" {
+		t.Fatalf("manifest artifacts = %+v, want the raw binary", m.Artifacts)
+	}
+	if _, err := os.Stat(m.Artifacts[0].Path); err != nil {
+		t.Errorf("binary listed in the manifest: %v", err)
+	}
+}
+
+// fakeCosign puts a cosign in PATH that writes the requested signature and
+// certificate files.
+func fakeCosign(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+while [ $# -gt 1 ]; do
+	case "$1" in
+	--output-signature) echo sig > "$2"; shift ;;
+	--output-certificate) echo cert > "$2"; shift ;;
+	esac
+	shift
+done
+`
+	if err := os.WriteFile(filepath.Join(dir, "cosign"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRunCosign(t *testing.T) {
+	fakeCosign(t)
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "app_linux_amd64"), []byte("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+			Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+		Signs:    []config.SignConfig{{Provider: "cosign"}},
+	}
+	// Keyless signing writes a signature and a certificate per file
+	signed := []string{"app_linux_amd64.tar.gz.sig", "app_linux_amd64.tar.gz.pem", "checksums.txt.sig", "checksums.txt.pem"}
+
+	t.Run("skip sign", func(t *testing.T) {
+		if _, err := Run(context.Background(), cfg, Options{SkipSign: true}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "checksums.txt")); err != nil {
+			t.Errorf("checksums.txt not written: %v", err)
+		}
+		for _, name := range signed {
+			if _, err := os.Stat(filepath.Join(outDir, name)); err == nil {
+				t.Errorf("%s written with --skip-sign", name)
+			}
+		}
+	})
+
+	if _, err := Run(context.Background(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for _, a := range m.Artifacts {
+		listed[a.Name] = true
+	}
+	names, err := ResolveNames(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := make(map[string]bool)
+	for _, n := range names {
+		resolved[filepath.Base(n.Path)] = true
+	}
+	for _, name := range signed {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+		if !listed[name] || !resolved[name] {
+			t.Errorf("%s listed in the manifest: %v, resolved: %v", name, listed[name], resolved[name])
+		}
+	}
+}
+
+// fakeSyft puts a syft in PATH that writes the --output document, or fails
+// for artifacts whose name contains "broken".
+func fakeSyft(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$2" in *broken*) echo "cannot scan $2" >&2; exit 1 ;; esac
+echo '{"bomFormat":"CycloneDX"}' > "${4#cyclonedx-json=}"
+`
+	if err := os.WriteFile(filepath.Join(dir, "syft"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRunSBOM(t *testing.T) {
+	fakeSyft(t)
+	binDir := t.TempDir()
+	for _, name := range []string{"app_linux_amd64", "app_darwin_amd64", "broken_linux_amd64"} {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte("app"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			OutputName: "app", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64"},
+			Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+		Checksum: config.ChecksumConfig{Algorithm: []string{"sha256"}},
+		SBOMs:    []config.SBOMConfig{{}},
+	}
+	if _, err := Run(context.Background(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sboms []string
+	for _, a := range m.Artifacts {
+		if a.Type == manifest.TypeSBOM {
+			sboms = append(sboms, a.Name)
+		}
+	}
+	want := []string{"app_linux_amd64.tar.gz.sbom.json", "app_darwin_amd64.tar.gz.sbom.json"}
+	if !slices.Equal(sboms, want) {
+		t.Errorf("manifest sboms = %v, want %v", sboms, want)
+	}
+	// SBOMs are written before the checksums file, so it lists them
+	sums, err := os.ReadFile(filepath.Join(outDir, "checksums.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range want {
+		if !strings.Contains(string(sums), name) {
+			t.Errorf("checksums.txt does not list %s", name)
+		}
+	}
+
+	t.Run("tool failure", func(t *testing.T) {
+		cfg.Builds[0].OutputName = "broken"
+		cfg.Builds[0].Goos = []string{"linux"}
+		_, err := Run(context.Background(), cfg, Options{})
+		if err == nil || !strings.Contains(err.Error(), "cannot scan") {
+			t.Errorf("error = %v, want the syft failure", err)
+		}
+	})
+}
+
+// fakeUPX puts an upx in PATH that replaces the binary with "packed", or
+// fails with a message on stderr when UPX_FAIL is set.
+func fakeUPX(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+for last; do :; done
+if [ -n "$UPX_FAIL" ]; then echo "upx: $last: NotCompressibleException" >&2; exit 2; fi
+echo packed > "$last"
+`
+	if err := os.WriteFile(filepath.Join(dir, "upx"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRunInjectedFailure(t *testing.T) {
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "app_linux_amd64"), []byte("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "dist")
+	prebuilt := &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")}
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{
+			{OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Prebuilt: prebuilt},
+			{OutputName: "app", Goos: []string{"darwin"}, Goarch: []string{"arm64"}, Prebuilt: prebuilt},
+		},
+	}
+
+	// A missing darwin binary is the real failure of the second target
+	_, realErr := Run(context.Background(), cfg, Options{})
+	if realErr == nil || !strings.HasPrefix(realErr.Error(), "build error: prebuilt app for darwin/arm64: ") {
+		t.Fatalf("Run() error = %v, want a failure of darwin/arm64", realErr)
+	}
+
+	if err := os.WriteFile(filepath.Join(binDir, "app_darwin_arm64"), []byte("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range []string{"build:2", "build:darwin/arm64"} {
+		p, err := inject.Parse(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inject.Set(&p)
+		t.Cleanup(func() { inject.Set(nil) })
+		_, err = Run(context.Background(), cfg, Options{})
+		if !errors.Is(err, inject.ErrInjected) || !strings.HasPrefix(err.Error(), "build error: prebuilt app for darwin/arm64: injected failure at "+at) {
+			t.Errorf("Run() with --fail-at %s error = %v, want the injected failure on the same path", at, err)
+		}
+		if paths, _ := filepath.Glob(filepath.Join(outDir, "app_*_linux_amd64", "app")); len(paths) != 1 {
+			t.Errorf("--fail-at %s: the first target was not built: %v", at, paths)
+		}
+	}
+}
+
+// injectWatcher is a log output that records which of the paths matched
+// by glob exist when the injected failure is logged.
+type injectWatcher struct {
+	glob  string
+	mu    sync.Mutex
+	built []string
+}
+
+func (w *injectWatcher) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("INJECTED FAILURE")) {
+		w.mu.Lock()
+		w.built, _ = filepath.Glob(w.glob)
+		w.mu.Unlock()
+	}
+	return len(p), nil
+}
+
+// TestRunInjectedFailureOrder checks that build:N fails only after the
+// targets before N finished, although they build in parallel.
+func TestRunInjectedFailureOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	// fakego builds amd64 slowly, so a parallel build:3 would fail first
+	script := `#!/bin/sh
+[ "$1" = build ] || exit 0
+while [ $# -gt 0 ]; do [ "$1" = -o ] && out=$2; shift; done
+[ "$GOARCH" = amd64 ] && sleep 0.3
+mkdir -p "$(dirname "$out")" && : > "$out"
+`
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "dist")
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app", GoBinary: "./bin/fakego",
+			Goos: []string{"linux"}, Goarch: []string{"amd64", "arm64", "386"},
+		}},
+	}
+
+	watcher := &injectWatcher{glob: filepath.Join(outDir, "app_*_linux_*", "app")}
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(watcher)
+	p, err := inject.Parse("build:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inject.Set(&p)
+	t.Cleanup(func() { inject.Set(nil) })
+
+	_, err = Run(context.Background(), cfg, Options{SkipArchives: true, Parallelism: 3})
+	if !errors.Is(err, inject.ErrInjected) {
+		t.Fatalf("Run() error = %v, want the injected failure", err)
+	}
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	if len(watcher.built) != 2 {
+		t.Errorf("built before the injected failure: %v, want linux/amd64 and linux/arm64", watcher.built)
+	}
+}
+
+func TestRunUPX(t *testing.T) {
+	fakeUPX(t)
+	binDir := t.TempDir()
+	for _, name := range []string{"app_linux_amd64", "app_darwin_arm64"} {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte("app"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	prebuilt := &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")}
+	upx := config.UPXConfig{Enabled: true, Level: 9, Targets: []string{"linux/amd64", "darwin/*"}, Exclude: []string{"darwin/arm64"}}
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{
+			{OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Prebuilt: prebuilt, UPX: upx},
+			{OutputName: "app", Goos: []string{"darwin"}, Goarch: []string{"arm64"}, Prebuilt: prebuilt, UPX: upx},
+		},
+	}
+
+	content := func(goos, goarch string) string {
+		paths, _ := filepath.Glob(filepath.Join(outDir, "app_*_"+goos+"_"+goarch, "app"))
+		if len(paths) != 1 {
+			t.Fatalf("binaries for %s/%s = %v", goos, goarch, paths)
+		}
+		data, err := os.ReadFile(paths[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	if _, err := Run(context.Background(), cfg, Options{SkipUPX: true}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := content("linux", "amd64"); got != "app" {
+		t.Errorf("linux/amd64 = %q with --skip-upx, want uncompressed", got)
+	}
+
+	if _, err := Run(context.Background(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := content("linux", "amd64"); got != "packed" {
+		t.Errorf("linux/amd64 = %q, want compressed", got)
+	}
+	if got := content("darwin", "arm64"); got != "app" {
+		t.Errorf("excluded darwin/arm64 = %q, want uncompressed", got)
+	}
+
+	t.Run("failure", func(t *testing.T) {
+		t.Setenv("UPX_FAIL", "1")
+		_, err := Run(context.Background(), cfg, Options{})
+		if err == nil || !strings.Contains(err.Error(), "NotCompressibleException") {
+			t.Errorf("error = %v, want the upx stderr", err)
+		}
+	})
+}
+
+// TestRunWindowsExe checks that windows binaries get .exe in both output
+// layouts while archive names keep the bare binary name.
+func TestRunWindowsExe(t *testing.T) {
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "app_windows_amd64"), []byte("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, flat := range []bool{false, true} {
+		outDir := filepath.Join(t.TempDir(), "dist")
+		cfg := &config.Config{
+			OutDir: outDir,
+			Builds: []config.BuildConfig{{
+				OutputName: "app", Goos: []string{"windows"}, Goarch: []string{"amd64"},
+				DisablePlatformSuffix: flat,
+				Prebuilt:              &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+			}},
+			Archives: []config.ArchiveConfig{{Formats: []string{"zip"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+		}
+		if _, err := Run(context.Background(), cfg, Options{}); err != nil {
+			t.Fatalf("Run(flat %v) error = %v", flat, err)
+		}
+		m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Artifacts) != 1 || m.Artifacts[0].Name != "app_windows_amd64.zip" {
+			t.Fatalf("flat %v: manifest artifacts = %+v", flat, m.Artifacts)
+		}
+		zr, err := zip.OpenReader(m.Artifacts[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []string
+		for _, f := range zr.File {
+			entries = append(entries, path.Base(f.Name))
+		}
+		_ = zr.Close()
+		if !slices.Contains(entries, "app.exe") {
+			t.Errorf("flat %v: archive entries = %v, want app.exe", flat, entries)
+		}
+	}
+}
+
+// TestRunParallelism builds the targets of two builds with a gobinary that
+// records how many copies of it run at once.
+func TestRunParallelism(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	// fakego counts running go build copies by marker files and creates the
+	// -o file as go build does
+	script := `#!/bin/sh
+[ "$1" = build ] || exit 0
+dir=$(dirname "$0")
+touch "$dir/running.$$"
+ls "$dir" | grep -c '^running\.' >> "$dir/counts"
+sleep 0.3
+while [ $# -gt 0 ]; do [ "$1" = -o ] && out=$2; shift; done
+mkdir -p "$(dirname "$out")" && : > "$out"
+rm "$dir/running.$$"
+`
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ config, flag, want int }{{2, 0, 2}, {4, 1, 1}} {
+		_ = os.Remove(filepath.Join(dir, "bin", "counts"))
+		cfg := &config.Config{
+			Dir:         dir,
+			OutDir:      filepath.Join(t.TempDir(), "dist"),
+			Parallelism: tt.config,
+			Builds: []config.BuildConfig{
+				{Main: ".", OutputName: "app", GoBinary: "./bin/fakego", Goos: []string{"linux"}, Goarch: []string{"amd64", "arm64", "386"}},
+				{Main: ".", OutputName: "tool", GoBinary: "./bin/fakego", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+			},
+		}
+		artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true, Parallelism: tt.flag})
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if len(artifacts) != 4 {
+			t.Fatalf("artifacts = %d, want 4", len(artifacts))
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "bin", "counts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		most := 0
+		for _, n := range strings.Fields(string(data)) {
+			count, _ := strconv.Atoi(n)
+			most = max(most, count)
+		}
+		if most != tt.want {
+			t.Errorf("parallelism %d, --parallelism %d: %d go build processes at once, want %d", tt.config, tt.flag, most, tt.want)
+		}
+	}
+}
+
+// TestRunTimeout kills the go build of a hung target after the build's
+// timeout while the other target finishes.
+func TestRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do [ "$1" = -o ] && out=$2; shift; done
+[ "$GOARCH" = arm64 ] && exec sleep 30
+mkdir -p "$(dirname "$out")" && : > "$out"
+`
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "dist")
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app", GoBinary: "./bin/fakego",
+			Goos: []string{"linux"}, Goarch: []string{"amd64", "arm64"},
+			Timeout: configtypes.Duration(200 * time.Millisecond),
+		}},
+	}
+
+	start := time.Now()
+	_, err := Run(context.Background(), cfg, Options{SkipArchives: true, Parallelism: 2})
+	if err == nil || !strings.Contains(err.Error(), "app for linux/arm64: killed after the timeout of 200ms") {
+		t.Fatalf("Run() error = %v, want the arm64 target killed", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Run() took %s, want the hung target killed", elapsed)
+	}
+	matches, _ := filepath.Glob(filepath.Join(outDir, "*_linux_amd64", "app"))
+	if len(matches) != 1 {
+		t.Errorf("amd64 binary not built alongside the hung target: %v", matches)
+	}
+}
@@ -0,0 +1,47 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// cgoEnv returns the environment builds[].cgo adds to go build for t: the
+// target's toolchain with CGO_ENABLED=1, or CGO_ENABLED=0 when it has
+// none, which fallback reports. It is nil unless cgo is enabled.
+func cgoEnv(cgo *config.CGOConfig, t Target) (env []string, fallback bool) {
+	if cgo == nil || !cgo.Enabled {
+		return nil, false
+	}
+	tc, ok := cgo.Toolchain(t.Goos, t.Goarch, t.Goarm)
+	if !ok {
+		return []string{"CGO_ENABLED=0"}, true
+	}
+	env = []string{"CGO_ENABLED=1"}
+	for _, v := range []struct{ name, value string }{
+		{"CC", tc.CC},
+		{"CXX", tc.CXX},
+		{"CGO_CFLAGS", tc.CFlags},
+		{"CGO_LDFLAGS", tc.LDFlags},
+	} {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	return env, false
+}
+
+// checkCGO fails when strict cgo has no toolchain for one of targets, so
+// that nothing is built for a matrix that cannot be completed.
+func checkCGO(buildCfg config.BuildConfig, targets []Target) error {
+	cgo := buildCfg.CGO
+	if cgo == nil || !cgo.Enabled || !cgo.Strict {
+		return nil
+	}
+	for _, t := range targets {
+		if _, ok := cgo.Toolchain(t.Goos, t.Goarch, t.Goarm); !ok {
+			return fmt.Errorf("cgo: no toolchain for %s in cgo.targets (cgo.strict)", t)
+		}
+	}
+	return nil
+}
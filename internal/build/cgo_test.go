@@ -0,0 +1,108 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestCgoEnv(t *testing.T) {
+	cgo := &config.CGOConfig{
+		Enabled: true,
+		Targets: map[string]config.CToolchain{
+			"linux/amd64":    {CC: "x86_64-linux-musl-gcc", LDFlags: "-static"},
+			"linux/arm":      {CC: "arm-linux-gnueabi-gcc"},
+			"linux/arm/arm7": {CC: "arm-linux-gnueabihf-gcc", CXX: "arm-linux-gnueabihf-g++", CFlags: "-O2"},
+		},
+	}
+	tests := []struct {
+		target   Target
+		env      []string
+		fallback bool
+	}{
+		{Target{Goos: "linux", Goarch: "amd64"}, []string{"CGO_ENABLED=1", "CC=x86_64-linux-musl-gcc", "CGO_LDFLAGS=-static"}, false},
+		{Target{Goos: "linux", Goarch: "arm", Goarm: "6"}, []string{"CGO_ENABLED=1", "CC=arm-linux-gnueabi-gcc"}, false},
+		{Target{Goos: "linux", Goarch: "arm", Goarm: "7"}, []string{"CGO_ENABLED=1", "CC=arm-linux-gnueabihf-gcc", "CXX=arm-linux-gnueabihf-g++", "CGO_CFLAGS=-O2"}, false},
+		{Target{Goos: "darwin", Goarch: "arm64"}, []string{"CGO_ENABLED=0"}, true},
+	}
+	for _, tt := range tests {
+		env, fallback := cgoEnv(cgo, tt.target)
+		if !slices.Equal(env, tt.env) || fallback != tt.fallback {
+			t.Errorf("cgoEnv(%s) = %v, %v; want %v, %v", tt.target, env, fallback, tt.env, tt.fallback)
+		}
+	}
+
+	cgo.Enabled = false
+	if env, _ := cgoEnv(cgo, Target{Goos: "linux", Goarch: "amd64"}); env != nil {
+		t.Errorf("cgoEnv() with cgo disabled = %v, want nil", env)
+	}
+}
+
+func TestCheckCGO(t *testing.T) {
+	buildCfg := config.BuildConfig{
+		Main: ".", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64"},
+		CGO: &config.CGOConfig{Enabled: true, Strict: true, Targets: map[string]config.CToolchain{"linux/amd64": {CC: "gcc"}}},
+	}
+	err := checkCGO(buildCfg, buildTargets(buildCfg, false))
+	if err == nil || !strings.Contains(err.Error(), "no toolchain for darwin/amd64") {
+		t.Errorf("checkCGO() error = %v, want darwin/amd64 reported", err)
+	}
+
+	buildCfg.CGO.Strict = false
+	if err := checkCGO(buildCfg, buildTargets(buildCfg, false)); err != nil {
+		t.Errorf("checkCGO() without strict error = %v", err)
+	}
+}
+
+// TestRunCGO builds a cgo program for the host with the toolchain and
+// CGO_CFLAGS of its cgo.targets entry.
+func TestRunCGO(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\n// int value() { return VALUE; }\nimport \"C\"\n\nimport \"fmt\"\n\nfunc main() { fmt.Print(C.value()) }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: filepath.Join(t.TempDir(), "dist"),
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app",
+			Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH},
+			Env: []string{"CC=false"},
+			CGO: &config.CGOConfig{Enabled: true, Strict: true, Targets: map[string]config.CToolchain{
+				runtime.GOOS + "/" + runtime.GOARCH: {CC: "gcc", CFlags: "-DVALUE=42"},
+			}},
+		}},
+	}
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	a := artifacts[0]
+	out, err := exec.Command(filepath.Join(a.DirPath, a.FileName())).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "42" {
+		t.Errorf("output = %q, want 42", out)
+	}
+}
@@ -0,0 +1,115 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/git"
+)
+
+// maxChangelogSize bounds the embedded changelog; longer ones are truncated.
+const maxChangelogSize = 1 << 20
+
+// maxLdflagsChangelog is the largest changelog embedded with -X. The flag
+// is a single linker argument, which Linux limits to 128 KiB; larger
+// changelogs are compiled in through an -overlay file instead.
+const maxLdflagsChangelog = 32 << 10
+
+// truncatedNote ends a changelog cut at maxChangelogSize.
+const truncatedNote = "\n\n(changelog truncated)\n"
+
+// releaseChangelog returns the changelog between the previous and the
+// current tag, as printed by gcx release changelog.
+func releaseChangelog(ctx context.Context, cfg *config.Config, currentTag string) (string, error) {
+	changelog, err := git.GetChangelog(ctx, git.GetPreviousTag(ctx), currentTag, cfg.Changelog.Options())
+	if err != nil {
+		return "", fmt.Errorf("embed changelog: %w", err)
+	}
+	return changelog, nil
+}
+
+// truncateChangelog cuts text to at most limit bytes, at the end of a line
+// when possible and never inside a UTF-8 sequence, and marks the cut.
+func truncateChangelog(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit - len(truncatedNote)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	text = text[:cut]
+	if i := strings.LastIndexByte(text, '\n'); i > 0 {
+		text = text[:i]
+	}
+	return text + truncatedNote
+}
+
+// changelogArgs returns how go build embeds text in the embed_changelog
+// variable of buildCfg: an -X ldflag when text fits on the command line,
+// otherwise -overlay arguments adding a generated file, written to tmpDir,
+// to the package of the variable. goBinary runs go build in dir.
+func changelogArgs(ctx context.Context, goBinary, dir string, buildCfg config.BuildConfig, text, tmpDir string) (ldflag string, args []string, err error) {
+	text = truncateChangelog(text, maxChangelogSize)
+	if len(text) <= maxLdflagsChangelog {
+		if quoted, ok := ldflagsQuote(buildCfg.EmbedChangelog.Var + "=" + text); ok {
+			return "-X " + quoted, nil, nil
+		}
+	}
+	overlay, err := changelogOverlay(ctx, goBinary, dir, buildCfg, text, tmpDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("embed changelog: %w", err)
+	}
+	return "", []string{"-overlay", overlay}, nil
+}
+
+// changelogOverlay writes a Go file setting the variable to text in an
+// init function, and the -overlay file placing it in the package directory.
+func changelogOverlay(ctx context.Context, goBinary, dir string, buildCfg config.BuildConfig, text, tmpDir string) (string, error) {
+	importPath, name := buildCfg.EmbedChangelog.Split()
+	if importPath == "main" {
+		importPath = buildCfg.Main
+	}
+	cmd := exec.CommandContext(ctx, goBinary, "list", "-f", "{{.Dir}}\n{{.Name}}", importPath)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), buildCfg.Env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("locate package %s: %w", importPath, err)
+	}
+	pkgDir, pkgName, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+
+	src, err := os.CreateTemp(tmpDir, "changelog-*.go")
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("// Code generated by gcx. DO NOT EDIT.\n\npackage %s\n\nfunc init() {\n\t%s = %s\n}\n",
+		pkgName, name, strconv.Quote(text))
+	if _, err := src.WriteString(code); err != nil {
+		_ = src.Close()
+		return "", err
+	}
+	if err := src.Close(); err != nil {
+		return "", err
+	}
+
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(pkgDir, "gcx_changelog.go"): src.Name()},
+	})
+	if err != nil {
+		return "", err
+	}
+	overlayPath := strings.TrimSuffix(src.Name(), ".go") + ".json"
+	if err := os.WriteFile(overlayPath, overlay, 0o644); err != nil {
+		return "", err
+	}
+	return overlayPath, nil
+}
@@ -0,0 +1,97 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestTruncateChangelog(t *testing.T) {
+	if got := truncateChangelog("* fix\n", 100); got != "* fix\n" {
+		t.Errorf("short changelog = %q", got)
+	}
+
+	text := strings.Repeat("* déjà vu by @dev in abc1234\n", 100)
+	got := truncateChangelog(text, 1000)
+	if len(got) > 1000 || !strings.HasSuffix(got, truncatedNote) || !utf8.ValidString(got) {
+		t.Fatalf("truncated to %d bytes: %q", len(got), got)
+	}
+	if body := strings.TrimSuffix(got, truncatedNote); !strings.HasSuffix(body, "abc1234") {
+		t.Errorf("cut inside a line: %q", body[len(body)-20:])
+	}
+}
+
+// TestChangelogArgs builds a program printing the embedded changelog with
+// both the -X and the -overlay mechanism.
+func TestChangelogArgs(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                      "module example.com/app\n\ngo 1.21\n",
+		"cmd/app/main.go":             "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/internal/version\"\n)\n\nvar changelog string\n\nfunc main() { fmt.Print(changelog + \"|\" + version.Changelog) }\n",
+		"internal/version/version.go": "package version\n\nvar Changelog = \"none\"\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		varName string
+		text    string
+		overlay bool
+	}{
+		{"ldflags", "main.changelog", "## Changes\n\n* quote \"x\" by @dev\n", false},
+		{"both quotes", "main.changelog", "* it's \"new\"\n", true},
+		{"large", "main.changelog", strings.Repeat("* change by @dev in abc1234\n", 2000), true},
+		{"other package", "example.com/app/internal/version.Changelog", strings.Repeat("* x `y` \\z\n", 5000), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildCfg := config.BuildConfig{Main: "./cmd/app", EmbedChangelog: &config.EmbedChangelogConfig{Var: tt.varName}}
+			ldflag, args, err := changelogArgs(context.Background(), "go", dir, buildCfg, tt.text, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(args) > 0) != tt.overlay || (ldflag != "") == tt.overlay {
+				t.Fatalf("ldflag = %.40q, args = %v; want overlay %v", ldflag, args, tt.overlay)
+			}
+
+			bin := filepath.Join(t.TempDir(), "app")
+			buildArgs := append([]string{"build"}, args...)
+			if ldflag != "" {
+				buildArgs = append(buildArgs, "-ldflags", ldflag)
+			}
+			cmd := exec.Command("go", append(buildArgs, "-o", bin, "./cmd/app")...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("go build: %v\n%s", err, out)
+			}
+			out, err := exec.Command(bin).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.text + "|none"
+			if tt.varName != "main.changelog" {
+				want = "|" + tt.text
+			}
+			if string(out) != want {
+				t.Errorf("embedded changelog = %.80q, want %.80q", out, want)
+			}
+		})
+	}
+}
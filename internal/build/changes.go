@@ -0,0 +1,38 @@
+package build
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sxwebdev/gcx/internal/git"
+)
+
+// changeDetector caches git changes per tag prefix for only_if_changed.
+type changeDetector struct {
+	byPrefix map[string]*git.Changes
+}
+
+func newChangeDetector() *changeDetector {
+	return &changeDetector{byPrefix: make(map[string]*git.Changes)}
+}
+
+// skip reports whether a build limited to globs has no changes since the
+// previous tag with tagPrefix.
+func (d *changeDetector) skip(ctx context.Context, globs []string, tagPrefix string) (bool, string, error) {
+	if len(globs) == 0 {
+		return false, "", nil
+	}
+	changes, ok := d.byPrefix[tagPrefix]
+	if !ok {
+		var err error
+		changes, err = git.GetChanges(ctx, tagPrefix)
+		if err != nil {
+			return false, "", fmt.Errorf("detect changes: %w", err)
+		}
+		d.byPrefix[tagPrefix] = changes
+	}
+	if changes.Any(globs) {
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("no changes matching only_if_changed between %s and %s", changes.From, changes.To), nil
+}
@@ -0,0 +1,174 @@
+package build
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// tmpPlaceholder stands in for the temporary directories of a run in
+// recorded commands, e.g. that of git_auth credentials.
+const tmpPlaceholder = "<tmp>"
+
+// datePlaceholder stands in for the build date in fingerprints when the
+// time of the source is unknown.
+const datePlaceholder = "<date>"
+
+// runInputs are the inputs of a run shared by all of its commands.
+type runInputs struct {
+	// root is the config directory; paths below it are recorded relative
+	// to it, so checkouts at different paths record the same commands.
+	root string
+	// date is the {{.Date}} of the run. Fingerprints use sourceDate, the
+	// SOURCE_DATE_EPOCH or commit time, instead.
+	date, sourceDate string
+	// source is the git.SourceDigest of the work tree.
+	source string
+}
+
+// newRunInputs returns the inputs of a run building into outDir at date.
+func newRunInputs(ctx context.Context, cfg *config.Config, outDir, date string) runInputs {
+	run := runInputs{root: cfg.Dir, date: date, sourceDate: datePlaceholder}
+	if t, err := reproducibleTime(ctx); err == nil {
+		run.sourceDate = t.Format(time.RFC3339)
+	}
+	var err error
+	if run.source, err = git.SourceDigest(ctx, cfg.Dir, outDir); err != nil {
+		log.Printf("Warning: command fingerprints do not cover the source files: %v", err)
+	}
+	return run
+}
+
+// relPath returns path relative to the config directory when it is below
+// it.
+func (r runInputs) relPath(path string) string {
+	if r.root == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(r.root, path); err == nil && filepath.IsAbs(path) && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return path
+}
+
+// toolchainVars are the variables of the gcx environment that change what
+// go build produces besides those starting with GO or CGO_.
+var toolchainVars = []string{"AR", "CC", "CXX", "FC", "GCCGO", "PKG_CONFIG"}
+
+// recordCommand returns the record of the go build process of binary,
+// which runs argv in dir with env. Only the variables env adds to the
+// environment of gcx and the parent's toolchainVars, GO* and CGO_* ones are
+// kept, so unrelated variables such as CI run ids do not change the
+// fingerprint. Paths below tempDirs are replaced by tmpPlaceholder, and
+// generated is the content of files the build reads from them.
+func recordCommand(binary string, artifact Artifact, argv []string, dir string, env []string, goVersion string, run runInputs, tempDirs []string, generated string) manifest.Command {
+	cmd := manifest.Command{
+		Binary:    run.relPath(binary),
+		Build:     artifact.ID,
+		Goos:      artifact.OS,
+		Goarch:    artifact.Arch,
+		Goarm:     artifact.Arm,
+		Variant:   artifact.Variant,
+		Args:      make([]string, len(argv)),
+		Dir:       run.relPath(dir),
+		Env:       effectiveEnv(os.Environ(), env),
+		GoVersion: goVersion,
+		Source:    run.source,
+	}
+	replacer := pathReplacer(tempDirs, run.root)
+	for i, arg := range argv {
+		cmd.Args[i] = replacer.Replace(run.relPath(arg))
+	}
+	for i, kv := range cmd.Env {
+		cmd.Env[i] = replacer.Replace(kv)
+	}
+
+	// The date of the run would change the fingerprint of every run
+	// embedding {{.Date}}
+	dateless := func(values []string) []string {
+		if run.date == "" {
+			return values
+		}
+		out := make([]string, len(values))
+		for i, v := range values {
+			out[i] = strings.ReplaceAll(v, run.date, run.sourceDate)
+		}
+		return out
+	}
+	sum := sha256.Sum256([]byte(generated))
+	// json.Marshal of a struct is stable and keeps fields apart
+	data, _ := json.Marshal(struct {
+		Args      []string
+		Dir       string
+		Env       []string
+		GoVersion string
+		Source    string
+		Generated string
+	}{dateless(cmd.Args), cmd.Dir, dateless(cmd.Env), cmd.GoVersion, cmd.Source, hex.EncodeToString(sum[:])})
+	fingerprint := sha256.Sum256(data)
+	cmd.Fingerprint = hex.EncodeToString(fingerprint[:])
+	return cmd
+}
+
+// pathReplacer replaces each of tempDirs by tmpPlaceholder and strips root
+// from the paths below it.
+func pathReplacer(tempDirs []string, root string) *strings.Replacer {
+	var pairs []string
+	for _, dir := range tempDirs {
+		if dir != "" {
+			pairs = append(pairs, dir, tmpPlaceholder)
+		}
+	}
+	if root != "" {
+		pairs = append(pairs, root+string(filepath.Separator), "")
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// effectiveEnv returns the variables of env, with later entries winning,
+// that differ from parent or are toolchain variables, sorted by name.
+// Secret values are replaced by their SHA-256.
+func effectiveEnv(parent, env []string) []string {
+	changed := make(map[string]bool)
+	for _, kv := range envDiff(parent, env) {
+		k, _, _ := strings.Cut(kv, "=")
+		changed[k] = true
+	}
+	values := make(map[string]string)
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		values[k] = v
+	}
+
+	var vars []string
+	for k, v := range values {
+		if !changed[k] && !toolchainVar(k) {
+			continue
+		}
+		if v != "" && envvars.Secret(k) {
+			sum := sha256.Sum256([]byte(v))
+			v = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		vars = append(vars, k+"="+v)
+	}
+	slices.Sort(vars)
+	return vars
+}
+
+// toolchainVar reports whether the variable name affects the output of go
+// build wherever it is set.
+func toolchainVar(name string) bool {
+	return strings.HasPrefix(name, "GO") || strings.HasPrefix(name, "CGO_") || slices.Contains(toolchainVars, name)
+}
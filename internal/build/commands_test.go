@@ -0,0 +1,134 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestEffectiveEnv(t *testing.T) {
+	parent := []string{"PATH=/bin", "CI_RUN_ID=42", "GOFLAGS=-mod=mod", "CC=gcc", "HOME=/root"}
+	env := append(slices.Clone(parent), "GOOS=linux", "API_TOKEN=hunter2", "CGO_ENABLED=1", "CGO_ENABLED=0", "HOME=/root")
+	want := []string{
+		// sha256 of "hunter2"
+		"API_TOKEN=sha256:f52fbd32b2b3b86ff88ef6c490628285f482af15ddcb29541f94bcf526a3f6c7",
+		"CC=gcc",
+		"CGO_ENABLED=0",
+		"GOFLAGS=-mod=mod",
+		"GOOS=linux",
+	}
+	if got := effectiveEnv(parent, env); !slices.Equal(got, want) {
+		t.Errorf("effectiveEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestRecordCommand(t *testing.T) {
+	artifact := Artifact{ID: "app", OS: "linux", Arch: "arm", Arm: "7"}
+	run := runInputs{root: "/src/app", date: "2024-05-01T10:00:00Z", sourceDate: "2024-04-30T08:00:00Z", source: "4b825dc6"}
+	record := func(run runInputs, tmp, arg, generated string) manifest.Command {
+		argv := []string{"go", "build", "-overlay", tmp + "/overlay.json", arg, "-ldflags", "-X main.date=" + run.date, "-o", run.root + "/dist/app", "."}
+		env := append(os.Environ(), "GOOS=linux", "NETRC="+tmp+"/.netrc")
+		return recordCommand(run.root+"/dist/app", artifact, argv, run.root, env, "1.22.3", run, []string{"", tmp}, generated)
+	}
+
+	first := record(run, "/tmp/gcx-1", "-trimpath", "changelog")
+	if !slices.Contains(first.Args, tmpPlaceholder+"/overlay.json") || !slices.Contains(first.Env, "NETRC="+tmpPlaceholder+"/.netrc") {
+		t.Errorf("temporary paths kept: args %q, env %q", first.Args, first.Env)
+	}
+	if first.Binary != "dist/app" || first.Dir != "." || !slices.Contains(first.Args, "dist/app") {
+		t.Errorf("paths not relative to the config directory: binary %q, dir %q, args %q", first.Binary, first.Dir, first.Args)
+	}
+	if first.Build != "app" || first.Goarm != "7" || first.GoVersion != "1.22.3" || first.Source != "4b825dc6" || len(first.Fingerprint) != 64 {
+		t.Errorf("recordCommand() = %+v", first)
+	}
+
+	// The temporary directory, the checkout path and the date of the run
+	// differ between the runs
+	later := run
+	later.root, later.date = "/home/ci/app", "2024-05-02T11:30:00Z"
+	if again := record(later, "/tmp/gcx-2", "-trimpath", "changelog"); again.Fingerprint != first.Fingerprint {
+		t.Errorf("fingerprint changed across runs with identical inputs: %s, %s", first.Fingerprint, again.Fingerprint)
+	}
+	otherSource := run
+	otherSource.source = "9f3e1a77"
+	for name, other := range map[string]manifest.Command{
+		"args":      record(run, "/tmp/gcx-1", "-race", "changelog"),
+		"generated": record(run, "/tmp/gcx-1", "-trimpath", "other changelog"),
+		"source":    record(otherSource, "/tmp/gcx-1", "-trimpath", "changelog"),
+	} {
+		if other.Fingerprint == first.Fingerprint {
+			t.Errorf("fingerprint ignores %s", name)
+		}
+	}
+}
+
+// TestRunPrintCommands prints the commands of a build twice, then builds
+// it, and checks that artifacts.json records the same commands.
+func TestRunPrintCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nwhile [ $# -gt 0 ]; do [ \"$1\" = -o ] && out=$2; shift; done\nmkdir -p \"$(dirname \"$out\")\" && : > \"$out\"\n"
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "dist")
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			ID: "app", Main: ".", OutputName: "app", GoBinary: "./bin/fakego",
+			Goos: []string{"linux"}, Goarch: []string{"amd64", "arm64"},
+			Env: []string{"CGO_ENABLED=0", "DEPLOY_TOKEN=hunter2"},
+		}},
+	}
+
+	var outputs []string
+	for range 2 {
+		var out bytes.Buffer
+		if _, err := Run(context.Background(), cfg, Options{Commands: &out}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		outputs = append(outputs, out.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("commands differ across runs:\n%s\n%s", outputs[0], outputs[1])
+	}
+	if _, err := os.Stat(outDir); err == nil {
+		t.Error("--print-commands wrote the output directory")
+	}
+	if strings.Contains(outputs[0], "hunter2") {
+		t.Errorf("commands contain the secret value:\n%s", outputs[0])
+	}
+	var printed []manifest.Command
+	if err := json.Unmarshal([]byte(outputs[0]), &printed); err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) != 2 || printed[1].Goarch != "arm64" || !slices.Contains(printed[0].Env, "CGO_ENABLED=0") {
+		t.Fatalf("printed commands = %+v", printed)
+	}
+
+	if _, err := Run(context.Background(), cfg, Options{SkipArchives: true}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Commands) != 2 || m.Commands[0].Fingerprint != printed[0].Fingerprint || m.Commands[1].Fingerprint != printed[1].Fingerprint {
+		t.Errorf("manifest commands = %+v, want the printed ones", m.Commands)
+	}
+}
@@ -0,0 +1,65 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// maxInlineContents is the longest entry list recorded in artifacts.json;
+// longer lists go to a sidecar next to the archive.
+const maxInlineContents = 100
+
+// reproducibleTime returns the modification time of reproducible archive
+// entries: SOURCE_DATE_EPOCH when set, or the commit time of HEAD.
+func reproducibleTime(ctx context.Context) (time.Time, error) {
+	if epoch := envvars.Get("SOURCE_DATE_EPOCH"); epoch != "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("SOURCE_DATE_EPOCH %q: want seconds since the Unix epoch", epoch)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	t, err := git.CommitTime(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reproducible archives need SOURCE_DATE_EPOCH or a git commit: %w", err)
+	}
+	return t, nil
+}
+
+// recordContents adds the entry lists of archives to their manifest
+// entries. Long lists are written to <archive>.contents.json sidecars,
+// which are appended as file entries.
+func recordContents(entries []manifest.Artifact, contents map[string][]archive.Entry) ([]manifest.Artifact, error) {
+	var sidecars []string
+	for i, e := range entries {
+		list, ok := contents[e.Path]
+		if !ok {
+			continue
+		}
+		if len(list) <= maxInlineContents {
+			entries[i].Contents = list
+			continue
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshal contents of %s: %w", e.Name, err)
+		}
+		sidecar := e.Path + manifest.ContentsSuffix
+		if err := os.WriteFile(sidecar, append(data, '\n'), 0o644); err != nil {
+			return nil, fmt.Errorf("write contents of %s: %w", e.Name, err)
+		}
+		entries[i].ContentsFile = filepath.Base(sidecar)
+		sidecars = append(sidecars, sidecar)
+	}
+	return append(entries, fileEntries(sidecars)...), nil
+}
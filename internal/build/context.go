@@ -0,0 +1,212 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of WriteContext.
+const (
+	ContextYAML = "yaml"
+	ContextJSON = "json"
+)
+
+// redacted replaces the values of secret variables in TemplateContext.
+const redacted = "[redacted]"
+
+// TemplateContext is the template data gcx context prints: the fields of
+// archive name templates for one build target, the commit and build date
+// of ldflags and the artifacts of generated_files. Release templates, e.g.
+// out_dir and blob directories, see Version, Channel and Tag of it.
+type TemplateContext struct {
+	ArchiveTemplateData
+	Commit string
+	Date   string
+	// Artifacts lists the artifacts of the last gcx build of the tag, if
+	// its out_dir exists.
+	Artifacts []manifest.Artifact
+}
+
+// NewTemplateContext resolves the template context of the current tag for
+// target, goos/goarch[/variant] as gcx targets prints it, or the first
+// target of the first build when empty. Env holds the variables config
+// templates reference, with the values of secrets redacted.
+func NewTemplateContext(ctx context.Context, cfg *config.Config, target string) (*TemplateContext, error) {
+	tag := git.GetTag(ctx)
+	commit := git.GetCommitHash(ctx)
+
+	artifact, err := contextArtifact(cfg, tag, commit, target)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		target = Target{Goos: artifact.OS, Goarch: artifact.Arch, Goarm: artifact.Arm, Variant: artifact.Variant}.String()
+		log.Printf("Target fields are those of %s %s (select another with --target)", artifact.BinaryName, target)
+	}
+	c := &TemplateContext{
+		ArchiveTemplateData: templateData(cfg, artifact),
+		Commit:              commit,
+		Date:                time.Now().Format(time.RFC3339),
+		Artifacts:           []manifest.Artifact{},
+	}
+	if c.Env, err = templateEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	outDir, err := cfg.OutputDir(tag)
+	if err != nil {
+		return nil, err
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	switch {
+	case err == nil:
+		c.Artifacts = m.Artifacts
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return c, nil
+}
+
+// contextArtifact returns the artifact of target, as ResolveNames does
+// without building anything.
+func contextArtifact(cfg *config.Config, tag, commit, target string) (Artifact, error) {
+	var names []string
+	for _, buildCfg := range cfg.Builds {
+		for _, t := range ResolveTargets(buildCfg) {
+			if t.Skipped() {
+				continue
+			}
+			names = append(names, t.String())
+			if target != "" && t.String() != target {
+				continue
+			}
+			artifact := Artifact{
+				BinaryName:   t.Build,
+				ID:           buildCfg.ID,
+				Version:      cfg.FormatVersion(tag),
+				Tag:          tag,
+				Channel:      cfg.Channel,
+				Commit:       commit,
+				OS:           t.Goos,
+				Arch:         t.Goarch,
+				Arm:          t.Goarm,
+				Variant:      t.Variant,
+				Group:        buildCfg.Group,
+				Instrumented: buildCfg.Instrumented(),
+			}
+			artifact.Ext = platformFor(buildCfg, t.Goos, targetBuildmode(buildCfg, t)).Ext
+			return artifact, nil
+		}
+	}
+	if target == "" {
+		return Artifact{}, fmt.Errorf("no build targets to resolve the template context for")
+	}
+	slices.Sort(names)
+	return Artifact{}, fmt.Errorf("no build target %s, expected one of %s", target, strings.Join(slices.Compact(names), ", "))
+}
+
+// templateEnv returns the variables referenced as {{.Env.NAME}} in cfg.
+// Unset variables are empty, as templates render them. Secrets by name
+// and variables named by token_env or key_env are redacted.
+func templateEnv(cfg *config.Config) (map[string]string, error) {
+	statuses, err := envvars.Report(cfg)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for _, s := range statuses {
+		if !slices.Contains(s.UsedBy, "templates") {
+			continue
+		}
+		value := envvars.Named(s.Name)
+		secret := envvars.Secret(s.Name) || slices.ContainsFunc(s.UsedBy, func(f string) bool { return strings.HasSuffix(f, "_env") })
+		if value != "" && secret {
+			value = redacted
+		}
+		env[s.Name] = value
+	}
+	return env, nil
+}
+
+// WriteContext prints c in format, with the field names templates use.
+func WriteContext(w io.Writer, c *TemplateContext, format string) error {
+	fields := templateFields(reflect.ValueOf(c))
+	switch format {
+	case "", ContextYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(fields); err != nil {
+			return fmt.Errorf("encode context: %w", err)
+		}
+		return enc.Close()
+	case ContextJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(fields); err != nil {
+			return fmt.Errorf("encode context: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported context format %q: expected yaml or json", format)
+	}
+}
+
+// templateFields returns v as templates address it: structs become maps
+// keyed by field name, so json tags like those of manifest.Artifact do
+// not rename them, and the fields of embedded structs are promoted.
+func templateFields(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return templateFields(v.Elem())
+	case reflect.Struct:
+		fields := make(map[string]any)
+		addFields(fields, v)
+		return fields
+	case reflect.Slice:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = templateFields(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m[fmt.Sprint(iter.Key().Interface())] = templateFields(iter.Value())
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+func addFields(fields map[string]any, v reflect.Value) {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		switch {
+		case !f.IsExported():
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			addFields(fields, v.Field(i))
+		default:
+			fields[f.Name] = templateFields(v.Field(i))
+		}
+	}
+}
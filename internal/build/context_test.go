@@ -0,0 +1,104 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+func TestContextArtifact(t *testing.T) {
+	cfg := &config.Config{Builds: []config.BuildConfig{
+		{Main: "./cmd/app", Goos: []string{"linux", "windows"}, Goarch: []string{"amd64"}},
+	}}
+
+	artifact, err := contextArtifact(cfg, "v1.2.0", "abc1234", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifact.OS != "linux" || artifact.Ext != "" {
+		t.Errorf("default target = %s%s, want linux without extension", artifact.OS, artifact.Ext)
+	}
+
+	artifact, err = contextArtifact(cfg, "v1.2.0", "abc1234", "windows/amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := TemplateContext{ArchiveTemplateData: templateData(cfg, artifact), Commit: "abc1234"}
+	got, err := tmpl.Process("render", "{{.Binary}}_{{.Version}}_{{.Os}}{{.Ext}} {{.Commit}}", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "app_v1.2.0_windows.exe abc1234"; got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+
+	_, err = contextArtifact(cfg, "v1.2.0", "abc1234", "darwin/arm64")
+	if err == nil || !strings.Contains(err.Error(), "expected one of linux/amd64, windows/amd64") {
+		t.Errorf("contextArtifact(darwin/arm64) error = %v, want the available targets", err)
+	}
+}
+
+func TestTemplateEnvRedacts(t *testing.T) {
+	t.Setenv("USER_NAME", "bob")
+	t.Setenv("DEPLOY_TOKEN", "s3cret")
+	t.Setenv("RELEASE_NOTE", "signed")
+	cfg := &config.Config{
+		Builds: []config.BuildConfig{{
+			Main:    "./cmd/app",
+			Ldflags: []string{"-X main.who={{.Env.USER_NAME}} -X main.token={{.Env.DEPLOY_TOKEN}} -X main.note={{.Env.RELEASE_NOTE}} -X main.x={{.Env.UNSET_VAR}}"},
+		}},
+		Signs: []config.SignConfig{{KeyEnv: "RELEASE_NOTE"}},
+	}
+	env, err := templateEnv(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"USER_NAME": "bob", "DEPLOY_TOKEN": redacted, "RELEASE_NOTE": redacted, "UNSET_VAR": ""}
+	if len(env) != len(want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+	for name, value := range want {
+		if env[name] != value {
+			t.Errorf("env[%s] = %q, want %q", name, env[name], value)
+		}
+	}
+}
+
+func TestWriteContext(t *testing.T) {
+	c := &TemplateContext{
+		ArchiveTemplateData: ArchiveTemplateData{Binary: "app", Os: "linux"},
+		Artifacts:           []manifest.Artifact{{Name: "app.tar.gz", SHA256: "ab12"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteContext(&buf, c, ContextJSON); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Binary    string
+		Os        string
+		Artifacts []map[string]any
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// Fields are named as templates address them, not by their json tags
+	if got.Binary != "app" || got.Os != "linux" || len(got.Artifacts) != 1 || got.Artifacts[0]["SHA256"] != "ab12" {
+		t.Errorf("context = %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteContext(&buf, c, ContextYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Binary: app\n") || !strings.Contains(buf.String(), "Name: app.tar.gz\n") {
+		t.Errorf("yaml context = %s", buf.String())
+	}
+	if err := WriteContext(&buf, c, "toml"); err == nil {
+		t.Error("WriteContext(toml) succeeded, want an unsupported format error")
+	}
+}
@@ -0,0 +1,75 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// TestRunCoverage builds the same main with and without coverage and
+// checks that the instrumented binary is labeled, kept apart and writes
+// coverage data to GOCOVERDIR.
+func TestRunCoverage(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	build := config.BuildConfig{Main: ".", OutputName: "app", Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH}}
+	covered := build
+	covered.Coverage = &config.CoverageConfig{Enabled: true, Covermode: config.CovermodeAtomic}
+	cfg := &config.Config{Dir: dir, OutDir: filepath.Join(t.TempDir(), "dist"), Builds: []config.BuildConfig{build, covered}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckNames(cfg, "v1.0.0"); err != nil {
+		t.Fatalf("CheckNames() error = %v", err)
+	}
+
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(artifacts) != 2 || artifacts[0].Instrumented || !artifacts[1].Instrumented {
+		t.Fatalf("artifacts = %+v", artifacts)
+	}
+	if !strings.HasSuffix(artifacts[1].DirPath, "_cover") || artifacts[0].DirPath+"_cover" != artifacts[1].DirPath {
+		t.Errorf("output dirs = %s, %s", artifacts[0].DirPath, artifacts[1].DirPath)
+	}
+
+	m, err := manifest.Load(filepath.Join(cfg.OutDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range m.Artifacts {
+		if a.Type == manifest.TypeBinary && a.Instrumented != strings.Contains(a.Path, "_cover") {
+			t.Errorf("%s: instrumented = %v", a.Path, a.Instrumented)
+		}
+	}
+
+	coverDir := t.TempDir()
+	cmd := exec.Command(filepath.Join(artifacts[1].DirPath, artifacts[1].FileName()))
+	cmd.Env = append(os.Environ(), "GOCOVERDIR="+coverDir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run instrumented binary: %v: %s", err, out)
+	}
+	if entries, _ := os.ReadDir(coverDir); len(entries) == 0 {
+		t.Error("instrumented binary wrote no coverage data")
+	}
+}
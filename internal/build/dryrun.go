@@ -0,0 +1,154 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/shellutil"
+)
+
+// shellSafeRegex matches words that need no quoting in a shell command.
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellWord quotes s for a shell unless it is safe as is.
+func shellWord(s string) string {
+	if shellSafeRegex.MatchString(s) {
+		return s
+	}
+	return shellutil.Quote(s)
+}
+
+// envDiff returns the entries of env, in "KEY=value" form, that are not in
+// parent or change its value. Later entries override earlier ones, as for
+// exec; the result is in the order of the overriding entries.
+func envDiff(parent, env []string) []string {
+	before := make(map[string]string, len(parent))
+	for _, kv := range parent {
+		k, v, _ := strings.Cut(kv, "=")
+		before[k] = v
+	}
+	last := make(map[string]int, len(env))
+	for i, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		last[k] = i
+	}
+	var diff []string
+	for i, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		if last[k] != i {
+			continue
+		}
+		if old, ok := before[k]; !ok || old != v {
+			diff = append(diff, kv)
+		}
+	}
+	return diff
+}
+
+// printCommand prints the command name with args as a shell line, prefixed
+// with the changes env makes to the environment of gcx and preceded by a
+// cd when dir is set.
+func printCommand(w io.Writer, dir string, env []string, name string, args []string) {
+	var words []string
+	if dir != "" {
+		words = append(words, "cd", shellWord(dir), "&&")
+	}
+	for _, kv := range envDiff(os.Environ(), env) {
+		k, v, _ := strings.Cut(kv, "=")
+		words = append(words, k+"="+shellWord(v))
+	}
+	words = append(words, shellWord(name))
+	for _, arg := range args {
+		words = append(words, shellWord(arg))
+	}
+	fmt.Fprintf(w, "Would run: %s\n", strings.Join(words, " "))
+}
+
+// printUPX prints the upx command compressing the binary at path.
+func printUPX(w io.Writer, upxCfg config.UPXConfig, path string) {
+	printCommand(w, "", nil, "upx", upxArgs(upxCfg, path))
+}
+
+// printHooks prints the hooks of stage, before or after.
+func printHooks(w io.Writer, stage string, hooks []string) {
+	for _, h := range hooks {
+		if h != "" {
+			fmt.Fprintf(w, "Would run %s hook: %s\n", stage, h)
+		}
+	}
+}
+
+// printRemovals prints the files of outDir, then outDir itself, which the
+// build removes before writing to it. A missing outDir prints nothing.
+func printRemovals(w io.Writer, outDir string) error {
+	if _, err := os.Stat(outDir); err != nil {
+		return nil
+	}
+	err := filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			fmt.Fprintf(w, "Would remove %s\n", path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("list output directory: %w", err)
+	}
+	fmt.Fprintf(w, "Would remove %s\n", outDir)
+	return nil
+}
+
+// printArchives prints the archives and binary copies createArchives would
+// write to outDir for artifacts. Names that embed the content hash show a
+// placeholder instead.
+func printArchives(w io.Writer, cfg *config.Config, outDir string, artifacts []Artifact) error {
+	archived := make(map[string]bool)
+	for _, artifact := range artifacts {
+		for j, archiveCfg := range cfg.Archives {
+			if !slices.Contains(archiveCfg.Formats, archive.BinaryFormat) || !archiveCfg.Applies([]string{artifact.ID}) {
+				continue
+			}
+			name, err := binaryBaseName(cfg, j, artifact, hashPlaceholder(filepath.Base(artifact.DirPath)+"/"+artifact.FileName()))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Would create %s\n", filepath.Join(outDir, name+artifact.Ext))
+		}
+
+		// Grouped artifacts share a directory, which is archived once
+		if archived[artifact.DirPath] {
+			continue
+		}
+		archived[artifact.DirPath] = true
+		for j, archiveCfg := range cfg.Archives {
+			if !archiveCfg.Applies(artifact.Builds) {
+				continue
+			}
+			archiveName, err := archiveBaseName(cfg, j, artifact, hashPlaceholder(filepath.Base(artifact.DirPath)))
+			if err != nil {
+				return err
+			}
+			for _, format := range archiveCfg.Formats {
+				if format == archive.BinaryFormat {
+					continue
+				}
+				archiver, err := archive.New(format, archiveCfg.CompressionLevel)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "Would create %s\n", filepath.Join(outDir, archiveName+"."+archiver.Extension()))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestEnvDiff(t *testing.T) {
+	parent := []string{"HOME=/root", "GOOS=darwin", "PATH=/bin"}
+	env := append(slices.Clone(parent), "GOOS=linux", "GOARCH=amd64", "HOME=/root", "CGO_ENABLED=1", "CGO_ENABLED=0")
+	want := []string{"GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0"}
+	if got := envDiff(parent, env); !slices.Equal(got, want) {
+		t.Errorf("envDiff() = %q, want %q", got, want)
+	}
+}
+
+// TestRunDryRun prints the commands of a build with a gobinary that would
+// record running go build, and checks that neither go build, the hooks nor
+// the cleanup of the output directory ran.
+func TestRunDryRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	ran := filepath.Join(dir, "ran")
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte("#!/bin/sh\n[ \"$1\" = build ] && touch "+ran+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "dist")
+	stale := filepath.Join(outDir, "old", "app")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: outDir,
+		Before: config.HooksConfig{Hooks: []config.Hook{{Cmd: "touch " + ran}}},
+		After:  config.HooksConfig{Hooks: []config.Hook{{Cmd: "touch " + ran}}},
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app", GoBinary: "./bin/fakego",
+			Goos: []string{"linux"}, Goarch: []string{"amd64", "arm64"},
+			Env:     []string{"CGO_ENABLED=0"},
+			Ldflags: []string{"-s -w"},
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+	}
+	var out bytes.Buffer
+	artifacts, err := Run(context.Background(), cfg, Options{DryRun: &out})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(artifacts) != 2 {
+		t.Errorf("artifacts = %d, want 2", len(artifacts))
+	}
+	if _, err := os.Stat(ran); err == nil {
+		t.Error("the dry run executed a hook or go build")
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("the dry run cleaned the output directory: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"Would run before hook: touch " + ran,
+		"Would remove " + stale,
+		"Would run: cd " + dir + " && GOOS=linux GOARCH=amd64 CGO_ENABLED=0 " + filepath.Join(dir, "bin", "fakego") +
+			" build -ldflags '-s -w' -o " + filepath.Join(outDir, "app_0.0.0_linux_amd64", "app") + " .",
+		"Would create " + filepath.Join(outDir, "app_linux_arm64.tar.gz"),
+		"Would run after hook: touch " + ran,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("dry run output lacks %q:\n%s", want, got)
+		}
+	}
+}
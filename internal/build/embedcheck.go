@@ -0,0 +1,288 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/checksums"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/helpers"
+)
+
+// maxStaleListed bounds the sources an embed check failure lists.
+const maxStaleListed = 10
+
+// checkEmbeds runs the embed_checks of cfg, whose paths are relative to
+// the config directory.
+func checkEmbeds(ctx context.Context, cfg *config.Config) error {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "."
+	}
+	for i, check := range cfg.EmbedChecks {
+		if err := checkEmbed(ctx, dir, check); err != nil {
+			return fmt.Errorf("embed_checks[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkEmbed fails when check.Path is older than one of its sources. With
+// compare auto, mtimes of a clean git checkout say nothing about when the
+// assets were built, so the sources are compared with the stamp instead.
+func checkEmbed(ctx context.Context, dir string, check config.EmbedCheckConfig) error {
+	sources, err := embedSources(dir, check)
+	if err != nil {
+		return err
+	}
+
+	mode := check.Compare
+	if mode == "" || mode == config.CompareAuto {
+		pristine, err := git.Pristine(ctx, dir, check.Path, globRoots(check.NewerThan)...)
+		if err != nil {
+			log.Printf("Warning: embed check of %s compares mtimes: %v", check.Path, err)
+		}
+		switch {
+		case !pristine:
+			mode = config.CompareMtime
+		case check.Stamp == "":
+			log.Printf("Warning: skipping embed check of %s: its mtimes come from the git checkout and no stamp is set", check.Path)
+			return nil
+		default:
+			mode = config.CompareHash
+		}
+	}
+
+	if mode == config.CompareHash {
+		return compareStamp(dir, check, sources)
+	}
+	if err := compareMtimes(dir, check.Path, sources); err != nil {
+		return err
+	}
+	if check.Stamp != "" {
+		return writeStamp(dir, check, sources)
+	}
+	return nil
+}
+
+// embedSources returns the files matching the newer_than entries of check,
+// relative to dir and slash-separated. Files below path and the stamp are
+// not sources.
+func embedSources(dir string, check config.EmbedCheckConfig) ([]string, error) {
+	embedded := path.Clean(filepath.ToSlash(check.Path))
+	stamp := path.Clean(filepath.ToSlash(check.Stamp))
+
+	var sources []string
+	for _, pattern := range check.NewerThan {
+		pattern = path.Clean(filepath.ToSlash(pattern))
+		root := globRoots([]string{pattern})[0]
+		literal := root == pattern
+		matched := false
+		err := filepath.WalkDir(filepath.Join(dir, root), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == embedded || strings.HasPrefix(rel, embedded+"/") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || rel == stamp || (!literal && !helpers.MatchGlob(pattern, rel)) {
+				return nil
+			}
+			matched = true
+			if !slices.Contains(sources, rel) {
+				sources = append(sources, rel)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("newer_than %q: %w", pattern, err)
+		}
+		if !matched {
+			return nil, fmt.Errorf("newer_than %q matches no files", pattern)
+		}
+	}
+	slices.Sort(sources)
+	return sources, nil
+}
+
+// globRoots returns the directory each pattern is matched below: its
+// leading segments without glob metacharacters.
+func globRoots(patterns []string) []string {
+	roots := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		var static []string
+		for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+			if strings.ContainsAny(segment, `*?[\`) {
+				break
+			}
+			static = append(static, segment)
+		}
+		roots[i] = path.Clean(strings.Join(static, "/"))
+		if roots[i] == "" {
+			roots[i] = "."
+		}
+	}
+	return roots
+}
+
+// compareMtimes fails when a source was modified after the newest file of
+// embedded, listing the newer sources and by how much.
+func compareMtimes(dir, embedded string, sources []string) error {
+	newest, built, err := newestFile(filepath.Join(dir, embedded))
+	if err != nil {
+		return fmt.Errorf("path %s: %w", embedded, err)
+	}
+
+	var stale []string
+	for _, source := range sources {
+		info, err := os.Stat(filepath.Join(dir, source))
+		if err != nil {
+			return err
+		}
+		if d := info.ModTime().Sub(built); d > 0 {
+			stale = append(stale, fmt.Sprintf("%s (%s newer)", source, d.Round(time.Second)))
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	rel, _ := filepath.Rel(dir, newest)
+	return fmt.Errorf("%s is older than %d source(s), last built %s (%s): %s; rebuild it or run gcx build --skip-embed-checks",
+		embedded, len(stale), built.Format(time.RFC3339), filepath.ToSlash(rel), listStale(stale))
+}
+
+// newestFile returns the most recently modified file at p, a file or a
+// directory, and its mtime.
+func newestFile(p string) (string, time.Time, error) {
+	var newest string
+	var mtime time.Time
+	err := filepath.WalkDir(p, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if newest == "" || info.ModTime().After(mtime) {
+			newest, mtime = p, info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if newest == "" {
+		return "", time.Time{}, fmt.Errorf("contains no files")
+	}
+	return newest, mtime, nil
+}
+
+// stampContent returns the stamp of sources: their SHA-256 sums in GNU
+// coreutils format, so sha256sum -c checks it as well.
+func stampContent(dir string, sources []string) ([]byte, error) {
+	entries := make([]checksums.Entry, len(sources))
+	for i, source := range sources {
+		digest, err := checksum.File(filepath.Join(dir, source))
+		if err != nil {
+			return nil, fmt.Errorf("checksum %s: %w", source, err)
+		}
+		entries[i] = checksums.Entry{Name: source, Sum: digest.SHA256Hex()}
+	}
+	var buf bytes.Buffer
+	if err := checksums.Encode(&buf, "sha256", entries, checksums.Options{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeStamp records the sums of sources in the stamp of check when they
+// changed, after the mtimes showed its path is fresh.
+func writeStamp(dir string, check config.EmbedCheckConfig, sources []string) error {
+	content, err := stampContent(dir, sources)
+	if err != nil {
+		return err
+	}
+	stampPath := filepath.Join(dir, check.Stamp)
+	if old, err := os.ReadFile(stampPath); err == nil && bytes.Equal(old, content) {
+		return nil
+	}
+	if err := os.WriteFile(stampPath, content, 0o644); err != nil {
+		return fmt.Errorf("write stamp: %w", err)
+	}
+	log.Printf("Updated embed stamp %s of %s; commit it with the assets", check.Stamp, check.Path)
+	return nil
+}
+
+// compareStamp fails when sources differ from those the stamp of check
+// records, listing the changed, added and removed files.
+func compareStamp(dir string, check config.EmbedCheckConfig, sources []string) error {
+	f, err := os.Open(filepath.Join(dir, check.Stamp))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("stamp %s of %s does not exist: build the assets and run gcx build to write it", check.Stamp, check.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("read stamp: %w", err)
+	}
+	recorded, err := checksum.Parse(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("parse stamp %s: %w", check.Stamp, err)
+	}
+
+	var changes []string
+	for _, source := range sources {
+		sum, ok := recorded[source]
+		if !ok {
+			changes = append(changes, "added "+source)
+			continue
+		}
+		delete(recorded, source)
+		digest, err := checksum.File(filepath.Join(dir, source))
+		if err != nil {
+			return fmt.Errorf("checksum %s: %w", source, err)
+		}
+		if digest.SHA256Hex() != sum {
+			changes = append(changes, "changed "+source)
+		}
+	}
+	for name := range recorded {
+		changes = append(changes, "removed "+name)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	slices.Sort(changes)
+	return fmt.Errorf("sources of %s differ from stamp %s: %s; rebuild it and run gcx build to update the stamp, or gcx build --skip-embed-checks",
+		check.Path, check.Stamp, listStale(changes))
+}
+
+// listStale joins the first maxStaleListed of items, counting the rest.
+func listStale(items []string) string {
+	listed := items[:min(len(items), maxStaleListed)]
+	msg := strings.Join(listed, ", ")
+	if more := len(items) - len(listed); more > 0 {
+		msg += fmt.Sprintf(" and %d more", more)
+	}
+	return msg
+}
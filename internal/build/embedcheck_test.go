@@ -0,0 +1,150 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// writeEmbedTree writes files relative to dir with the given age.
+func writeEmbedTree(t *testing.T, dir string, age time.Duration, files ...string) {
+	t.Helper()
+	mtime := time.Now().Add(-age)
+	for _, name := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckEmbedMtime(t *testing.T) {
+	dir := t.TempDir()
+	writeEmbedTree(t, dir, 2*time.Hour, "web/src/app.ts", "web/src/style.css", "web/README.md")
+	writeEmbedTree(t, dir, time.Hour, "ui/dist/index.html", "ui/dist/app.js")
+	check := config.EmbedCheckConfig{
+		Path:      "ui/dist",
+		NewerThan: []string{"web/src/**/*.ts", "web/src/*.css"},
+		Compare:   config.CompareMtime,
+		Stamp:     "ui/sources.sha256",
+	}
+	ctx := context.Background()
+
+	if err := checkEmbed(ctx, dir, check); err != nil {
+		t.Fatalf("checkEmbed() of fresh assets = %v", err)
+	}
+	stamp, err := os.ReadFile(filepath.Join(dir, check.Stamp))
+	if err != nil {
+		t.Fatalf("stamp not written: %v", err)
+	}
+	if !strings.Contains(string(stamp), "  web/src/app.ts\n") || strings.Contains(string(stamp), "README") {
+		t.Errorf("stamp = %q", stamp)
+	}
+
+	// The README is no source; the stylesheet is
+	writeEmbedTree(t, dir, 0, "web/README.md")
+	writeEmbedTree(t, dir, 30*time.Minute, "web/src/style.css")
+	err = checkEmbed(ctx, dir, check)
+	if err == nil || !strings.Contains(err.Error(), "ui/dist is older than 1 source(s)") || !strings.Contains(err.Error(), "web/src/style.css (30m0s newer)") {
+		t.Errorf("checkEmbed() of stale assets = %v", err)
+	}
+
+	check.NewerThan = []string{"web/src/**/*.go"}
+	if err := checkEmbed(ctx, dir, check); err == nil || !strings.Contains(err.Error(), `newer_than "web/src/**/*.go" matches no files`) {
+		t.Errorf("checkEmbed() without sources = %v", err)
+	}
+}
+
+func TestCheckEmbedHash(t *testing.T) {
+	dir := t.TempDir()
+	writeEmbedTree(t, dir, time.Hour, "web/src/app.ts", "web/src/old.ts", "ui/dist/index.html")
+	check := config.EmbedCheckConfig{Path: "ui/dist", NewerThan: []string{"web/src"}, Compare: config.CompareHash, Stamp: "ui/sources.sha256"}
+	ctx := context.Background()
+
+	if err := checkEmbed(ctx, dir, check); err == nil || !strings.Contains(err.Error(), "stamp ui/sources.sha256 of ui/dist does not exist") {
+		t.Errorf("checkEmbed() without a stamp = %v", err)
+	}
+	if err := writeStamp(dir, check, []string{"web/src/app.ts", "web/src/old.ts"}); err != nil {
+		t.Fatal(err)
+	}
+	// Mtimes do not matter when hashing
+	writeEmbedTree(t, dir, 0, "web/src/app.ts")
+	if err := checkEmbed(ctx, dir, check); err != nil {
+		t.Errorf("checkEmbed() with matching sources = %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "web/src/app.ts"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "web/src/old.ts")); err != nil {
+		t.Fatal(err)
+	}
+	writeEmbedTree(t, dir, 0, "web/src/new.ts")
+	err := checkEmbed(ctx, dir, check)
+	if err == nil || !strings.Contains(err.Error(), "added web/src/new.ts, changed web/src/app.ts, removed web/src/old.ts") {
+		t.Errorf("checkEmbed() with edited sources = %v", err)
+	}
+}
+
+// TestCheckEmbedAuto checks that a clean git checkout, whose mtimes are
+// those of the checkout, is compared with the stamp instead.
+func TestCheckEmbedAuto(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	writeEmbedTree(t, dir, 2*time.Hour, "ui/dist/index.html")
+	writeEmbedTree(t, dir, time.Hour, "web/src/app.ts")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.email=test@example.com", "-c", "user.name=test", "-c", "commit.gpgsign=false", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	ctx := context.Background()
+	check := config.EmbedCheckConfig{Path: "ui/dist", NewerThan: []string{"web/src"}}
+
+	// Older by mtime, but without a stamp nothing can be told
+	if err := checkEmbed(ctx, dir, check); err != nil {
+		t.Errorf("checkEmbed() of a checkout without stamp = %v", err)
+	}
+
+	check.Stamp = "ui/sources.sha256"
+	if err := checkEmbed(ctx, dir, check); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("checkEmbed() of a checkout with a missing stamp = %v", err)
+	}
+
+	// A local change makes the mtimes meaningful again
+	if err := os.WriteFile(filepath.Join(dir, "web/src/app.ts"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkEmbed(ctx, dir, check); err == nil || !strings.Contains(err.Error(), "ui/dist is older than 1 source(s)") {
+		t.Errorf("checkEmbed() after an edit = %v", err)
+	}
+}
+
+func TestGlobRoots(t *testing.T) {
+	got := globRoots([]string{"web/src/**/*.ts", "web/src", "*.css", "assets/[a-z]*/logo.svg"})
+	want := []string{"web/src", "web/src", ".", "assets"}
+	if !slices.Equal(got, want) {
+		t.Errorf("globRoots() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// renderEnv renders the env entries of a target with the ldflags template
+// fields. Templated entries that render to nothing or to a variable with an
+// empty value are dropped instead of exporting an empty variable; literal
+// entries such as "GOFLAGS=" are kept to clear an inherited variable.
+func renderEnv(env []string, data any) ([]string, error) {
+	var rendered []string
+	for _, e := range env {
+		out, err := tmpl.Process("env", e, data)
+		if err != nil {
+			return nil, fmt.Errorf("env %q: %w", e, err)
+		}
+		if name, value, _ := strings.Cut(out, "="); strings.Contains(e, "{{") && (name == "" || value == "") {
+			continue
+		}
+		rendered = append(rendered, out)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,34 @@
+package build
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRenderEnv(t *testing.T) {
+	data := map[string]any{"Version": "v1.2.0", "Env": map[string]string{"DSN": "https://sentry.example", "EMPTY": ""}}
+	tests := []struct {
+		env     []string
+		want    []string
+		wantErr string
+	}{
+		{nil, nil, ""},
+		{[]string{"CGO_ENABLED=0", "APP_VERSION={{.Version}}"}, []string{"CGO_ENABLED=0", "APP_VERSION=v1.2.0"}, ""},
+		{[]string{"SENTRY_DSN={{.Env.DSN}}", "OPTIONAL={{.Env.EMPTY}}", "{{.Env.EMPTY}}"}, []string{"SENTRY_DSN=https://sentry.example"}, ""},
+		{[]string{"GOFLAGS=", "CGO_CFLAGS="}, []string{"GOFLAGS=", "CGO_CFLAGS="}, ""},
+		{[]string{"CGO_ENABLED=0", "BAD={{.Version"}, nil, `env "BAD={{.Version"`},
+	}
+	for _, tt := range tests {
+		got, err := renderEnv(tt.env, data)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("renderEnv(%q) error = %v, want %q", tt.env, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("renderEnv(%q) = %q, %v; want %q", tt.env, got, err, tt.want)
+		}
+	}
+}
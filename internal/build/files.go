@@ -0,0 +1,49 @@
+package build
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// archiveFiles expands the files globs of archiveCfg against dir, the
+// config directory. Globs that match nothing are logged, or fail with
+// strict. Matches keep their path relative to dir, or are placed in dst.
+func archiveFiles(dir string, archiveCfg config.ArchiveConfig) ([]archive.File, error) {
+	if dir == "" {
+		dir = "."
+	}
+	var files []archive.File
+	for _, f := range archiveCfg.Files {
+		pattern := f.Src
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("archive files %q: %w", f.Src, err)
+		}
+		if len(matches) == 0 {
+			if archiveCfg.Strict {
+				return nil, fmt.Errorf("archive files %q match nothing", f.Src)
+			}
+			log.Printf("Warning: archive files %q match nothing", f.Src)
+			continue
+		}
+
+		for _, match := range matches {
+			name := filepath.Base(match)
+			if f.Dst != "" {
+				name = path.Join(f.Dst, name)
+			} else if rel, err := filepath.Rel(dir, match); err == nil && filepath.IsLocal(rel) {
+				name = filepath.ToSlash(rel)
+			}
+			files = append(files, archive.File{Path: match, Name: name})
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,25 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// renderFlags renders the flags templates of a target into go build
+// arguments, one per entry. Entries that render to nothing are dropped.
+func renderFlags(flags []string, data any) ([]string, error) {
+	var rendered []string
+	for i, f := range flags {
+		out, err := tmpl.Process("flag", f, data)
+		if err != nil {
+			return nil, fmt.Errorf("flags[%d]: %w", i, err)
+		}
+		if strings.TrimSpace(out) == "" {
+			continue
+		}
+		rendered = append(rendered, out)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,86 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestRenderFlags(t *testing.T) {
+	data := map[string]any{"Version": "v1.2.0", "Env": map[string]string{"PGO_PROFILE": "default.pgo", "EMPTY": ""}}
+	tests := []struct {
+		flags   []string
+		want    []string
+		wantErr string
+	}{
+		{nil, nil, ""},
+		{[]string{"-trimpath", "-pgo={{.Env.PGO_PROFILE}}"}, []string{"-trimpath", "-pgo=default.pgo"}, ""},
+		{[]string{"{{.Env.EMPTY}}", "{{with .Env.EMPTY}}-pgo={{.}}{{end}}", "-trimpath"}, []string{"-trimpath"}, ""},
+		{[]string{"-trimpath", "-pgo={{.Env.PGO_PROFILE"}, nil, "flags[1]:"},
+	}
+	for _, tt := range tests {
+		got, err := renderFlags(tt.flags, data)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("renderFlags(%q) error = %v, want %q", tt.flags, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("renderFlags(%q) = %q, %v; want %q", tt.flags, got, err, tt.want)
+		}
+	}
+}
+
+// TestRunTemplatedFlagsAndMain checks that flags and main are rendered
+// before go build runs, and that flags rendering empty are left out.
+func TestRunTemplatedFlagsAndMain(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/app\n\ngo 1.21\n",
+		"cmd/lite/main.go": "package main\n\nimport \"fmt\"\n\nvar flavor string\n\nfunc main() { fmt.Print(flavor) }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GCX_TEST_EDITION", "lite")
+	t.Setenv("GCX_TEST_EMPTY", "")
+
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: filepath.Join(t.TempDir(), "dist"),
+		Builds: []config.BuildConfig{{
+			Main: "./cmd/{{.Env.GCX_TEST_EDITION}}", OutputName: "app",
+			Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH},
+			Flags: []string{"{{.Env.GCX_TEST_EMPTY}}", "-ldflags=-X=main.flavor={{.Env.GCX_TEST_EDITION}}"},
+		}},
+	}
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	a := artifacts[0]
+	out, err := exec.Command(filepath.Join(a.DirPath, a.FileName())).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "lite" {
+		t.Errorf("output = %q, want %q", out, "lite")
+	}
+}
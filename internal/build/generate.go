@@ -0,0 +1,86 @@
+package build
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// GeneratedFileData is the template context of generated_files content.
+type GeneratedFileData struct {
+	Version string
+	Channel string
+	Commit  string
+	Date    string
+	Env     map[string]string
+	// Artifacts lists the release artifacts in manifest order, with Size
+	// and SHA256 filled in, e.g. {{range .Artifacts}}{{.Name}}{{end}}.
+	Artifacts []manifest.Artifact
+}
+
+// generatedFileName renders the name_template of a generated file.
+func generatedFileName(fileCfg config.GeneratedFileConfig, rel config.ReleaseData) (string, error) {
+	name, err := tmpl.Process("name_template", fileCfg.NameTemplate, rel)
+	if err != nil {
+		return "", err
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("name_template rendered to %q, want a plain file name", name)
+	}
+	return name, nil
+}
+
+// generateFiles renders every generated_files entry into outDir and returns
+// the paths written. data.Artifacts is completed with sizes and checksums.
+func generateFiles(cfg *config.Config, outDir string, data GeneratedFileData) ([]string, error) {
+	if len(cfg.GeneratedFiles) == 0 {
+		return nil, nil
+	}
+
+	data.Artifacts = slices.Clone(data.Artifacts)
+	for i, a := range data.Artifacts {
+		digest, err := checksum.File(a.Path)
+		if err != nil {
+			return nil, fmt.Errorf("checksum %s: %w", a.Name, err)
+		}
+		data.Artifacts[i].SHA256 = digest.SHA256Hex()
+		data.Artifacts[i].Size = digest.Size
+	}
+
+	var paths []string
+	for i, fileCfg := range cfg.GeneratedFiles {
+		name, err := generatedFileName(fileCfg, config.ReleaseData{Version: data.Version, Channel: data.Channel})
+		if err != nil {
+			return nil, fmt.Errorf("generated_files[%d]: %w", i, err)
+		}
+
+		content := fileCfg.ContentTemplate
+		if fileCfg.Source != "" {
+			src, err := os.ReadFile(fileCfg.Source)
+			if err != nil {
+				return nil, fmt.Errorf("generated_files[%d]: read source: %w", i, err)
+			}
+			content = string(src)
+		}
+		out, err := tmpl.Process(name, content, data)
+		if err != nil {
+			return nil, fmt.Errorf("generated_files[%d]: %w", i, err)
+		}
+
+		path := filepath.Join(outDir, name)
+		if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+			return nil, fmt.Errorf("write generated file %s: %w", name, err)
+		}
+		log.Printf("Generated %s", path)
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
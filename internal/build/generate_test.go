@@ -0,0 +1,64 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestGenerateFiles(t *testing.T) {
+	outDir := t.TempDir()
+	archivePath := filepath.Join(outDir, "app_v1.0.0_linux_amd64.tar.gz")
+	if err := os.WriteFile(archivePath, []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(t.TempDir(), "install.sh.tmpl")
+	if err := os.WriteFile(source, []byte(`VERSION={{.Version}}
+{{range .Artifacts}}{{if eq .Type "archive"}}{{.Goos}}/{{.Goarch}} {{.Name}} {{.SHA256}}
+{{end}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{GeneratedFiles: []config.GeneratedFileConfig{
+		{NameTemplate: "version.txt", ContentTemplate: "{{.Version}}\n"},
+		{NameTemplate: "install-{{.Version}}.sh", Source: source},
+	}}
+	entries := []manifest.Artifact{{
+		Name: filepath.Base(archivePath), Path: archivePath, Type: manifest.TypeArchive,
+		Goos: "linux", Goarch: "amd64",
+	}}
+	paths, err := generateFiles(cfg, outDir, GeneratedFileData{Version: "v1.0.0", Artifacts: entries})
+	if err != nil {
+		t.Fatalf("generateFiles() error = %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[1]) != "install-v1.0.0.sh" {
+		t.Fatalf("generateFiles() = %v", paths)
+	}
+	if entries[0].SHA256 != "" {
+		t.Error("generateFiles() modified the caller's entries")
+	}
+
+	tests := map[string]string{
+		"version.txt": "v1.0.0\n",
+		"install-v1.0.0.sh": "VERSION=v1.0.0\n" +
+			"linux/amd64 app_v1.0.0_linux_amd64.tar.gz 0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3\n",
+	}
+	for name, want := range tests {
+		data, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestGeneratedFileName(t *testing.T) {
+	if _, err := generatedFileName(config.GeneratedFileConfig{NameTemplate: "../{{.Version}}.txt"}, config.ReleaseData{Version: "v1"}); err == nil {
+		t.Error("generatedFileName() accepted a path outside out_dir")
+	}
+}
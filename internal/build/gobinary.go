@@ -0,0 +1,32 @@
+package build
+
+import (
+	"cmp"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// lookGoBinary returns the path of the tool compiling buildCfg in dir: its
+// gobinary, or go. A gobinary with a path separator is relative to dir,
+// where the tool runs; other names are looked up on PATH.
+func lookGoBinary(dir string, buildCfg config.BuildConfig) (string, error) {
+	name := cmp.Or(buildCfg.GoBinary, "go")
+	if strings.ContainsRune(name, '/') && !filepath.IsAbs(name) && dir != "" {
+		name = filepath.Join(dir, name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("gobinary %q not found: %w", cmp.Or(buildCfg.GoBinary, "go"), err)
+	}
+	return path, nil
+}
+
+// buildCommand returns the arguments of the tool before the build flags:
+// the words of builds[].command, or build.
+func buildCommand(buildCfg config.BuildConfig) []string {
+	return strings.Fields(cmp.Or(buildCfg.Command, "build"))
+}
@@ -0,0 +1,118 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// TestRunGoBinary builds with a wrapper of go whose subcommand is set by
+// command, and checks that a missing gobinary fails before anything is built.
+func TestRunGoBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("wrapper is a shell script")
+	}
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+		// gowrap wrapped build ... logs its arguments and runs go build ...,
+		// other subcommands such as version go straight to go
+		"bin/gowrap": "#!/bin/sh\nif [ \"$1\" = wrapped ]; then echo \"$@\" > \"$(dirname \"$0\")/args\"; shift; fi\nexec " + goPath + " \"$@\"\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newConfig := func(gobinary string) *config.Config {
+		return &config.Config{
+			Dir:    dir,
+			OutDir: filepath.Join(t.TempDir(), "dist"),
+			Builds: []config.BuildConfig{{
+				Main: ".", OutputName: "app",
+				GoBinary: gobinary, Command: "wrapped build",
+				Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH},
+				Flags: []string{"-trimpath"},
+			}},
+		}
+	}
+
+	cfg := newConfig("./bin/gowrap")
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(artifacts[0].DirPath, artifacts[0].FileName())); err != nil {
+		t.Errorf("binary not built: %v", err)
+	}
+	args, err := os.ReadFile(filepath.Join(dir, "bin", "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(args), "wrapped build -trimpath -o ") {
+		t.Errorf("gobinary arguments = %q", args)
+	}
+
+	cfg = newConfig("gcx-no-such-tool")
+	_, err = Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err == nil || !strings.Contains(err.Error(), `gobinary "gcx-no-such-tool" not found`) {
+		t.Fatalf("Run() error = %v, want gobinary not found", err)
+	}
+	if _, err := os.Stat(cfg.OutDir); !os.IsNotExist(err) {
+		t.Errorf("out_dir created before the gobinary check: %v", err)
+	}
+}
+
+// TestRunGoBinaryToolchain checks go_version against the toolchain of the
+// gobinary rather than the go on PATH, and the go directive of go.mod even
+// without go_version.
+func TestRunGoBinaryToolchain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = version ] && echo 'tinygo version 0.31.2 linux/amd64 (using go version go1.19.13 and LLVM version 17.0.1)'\n"
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Dir:       dir,
+		OutDir:    filepath.Join(dir, "dist"),
+		GoVersion: ">=1.21",
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app", GoBinary: "./bin/fakego",
+			Goos: []string{"linux"}, Goarch: []string{"amd64"},
+		}},
+	}
+	_, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err == nil || !strings.Contains(err.Error(), `go version 1.19.13 does not satisfy go_version ">=1.21"`) {
+		t.Fatalf("Run() error = %v, want the gobinary's go version checked", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.GoVersion = ""
+	_, err = Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err == nil || !strings.Contains(err.Error(), "go version 1.19.13 is older than go 1.21 required by go.mod") {
+		t.Fatalf("Run() error = %v, want the go directive checked", err)
+	}
+}
@@ -0,0 +1,37 @@
+package build
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// selectHooks returns the commands of the hooks of stage, e.g. "before",
+// that the tag filters of opts select. Untagged hooks always run; every
+// tagged hook filtered out is logged with the flag that dropped it.
+func selectHooks(stage string, hooks []config.Hook, opts Options) []string {
+	var cmds []string
+	for _, h := range hooks {
+		if len(h.Tags) > 0 {
+			if len(opts.HooksTags) > 0 && !hasTag(h.Tags, opts.HooksTags) {
+				log.Printf("Skipping %s hook %q: tags %s not in --hooks-tags %s",
+					stage, h.Cmd, strings.Join(h.Tags, ","), strings.Join(opts.HooksTags, ","))
+				continue
+			}
+			if hasTag(h.Tags, opts.SkipHooksTags) {
+				log.Printf("Skipping %s hook %q: tags %s match --skip-hooks-tags %s",
+					stage, h.Cmd, strings.Join(h.Tags, ","), strings.Join(opts.SkipHooksTags, ","))
+				continue
+			}
+		}
+		cmds = append(cmds, h.Cmd)
+	}
+	return cmds
+}
+
+// hasTag reports whether tags contains one of filter.
+func hasTag(tags, filter []string) bool {
+	return slices.ContainsFunc(tags, func(t string) bool { return slices.Contains(filter, t) })
+}
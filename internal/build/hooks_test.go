@@ -0,0 +1,35 @@
+package build
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestSelectHooks(t *testing.T) {
+	hooks := []config.Hook{
+		{Cmd: "go mod tidy"},
+		{Cmd: "go generate ./...", Tags: []string{"codegen"}},
+		{Cmd: "npm run build", Tags: []string{"slow", "frontend"}},
+	}
+	tests := []struct {
+		name       string
+		tags, skip []string
+		want       []string
+	}{
+		{"no filters", nil, nil, []string{"go mod tidy", "go generate ./...", "npm run build"}},
+		{"hooks-tags", []string{"codegen"}, nil, []string{"go mod tidy", "go generate ./..."}},
+		{"skip-hooks-tags", nil, []string{"slow"}, []string{"go mod tidy", "go generate ./..."}},
+		{"both", []string{"codegen", "frontend"}, []string{"slow"}, []string{"go mod tidy", "go generate ./..."}},
+		{"unknown tag", []string{"lint"}, nil, []string{"go mod tidy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectHooks("before", hooks, Options{HooksTags: tt.tags, SkipHooksTags: tt.skip})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selectHooks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
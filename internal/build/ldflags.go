@@ -0,0 +1,180 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// ldflagsSpace are the bytes go build splits -ldflags at.
+const ldflagsSpace = " \t\n\r"
+
+// ldflagField is a field of an ldflags entry before rendering.
+type ldflagField struct {
+	Text string
+	// Block is set for fields holding an {{if}}, {{range}} or {{with}}
+	// block, whose output may be several fields.
+	Block bool
+}
+
+// renderLdflags renders the ldflags entries of a build and returns their
+// fields unquoted. Entries are split at whitespace outside template
+// actions and quotes before rendering, so a value with spaces, e.g.
+// -X "main.builder={{.Env.USER_NAME}}", stays one field. Fields that render
+// to nothing are dropped.
+func renderLdflags(ldflags []string, data any) ([]string, error) {
+	var fields []string
+	for i, ldflag := range ldflags {
+		entryFields, err := splitLdflag(ldflag)
+		if err != nil {
+			return nil, fmt.Errorf("ldflags[%d] %q: %w", i, ldflag, err)
+		}
+		for _, f := range entryFields {
+			out, err := tmpl.Process("ldflag", f.Text, data)
+			if err != nil {
+				return nil, fmt.Errorf("ldflags[%d]: %w", i, err)
+			}
+			if f.Block {
+				fields = append(fields, splitQuoted(out)...)
+				continue
+			}
+			if out = strings.Trim(out, ldflagsSpace); out != "" {
+				fields = append(fields, out)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// joinLdflags joins fields into the -ldflags value of go build, quoting
+// fields that contain whitespace or quotes.
+func joinLdflags(fields []string) (string, error) {
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !strings.ContainsAny(f, ldflagsSpace+`'"`) && !strings.ContainsRune(f, 0) {
+			quoted = append(quoted, f)
+			continue
+		}
+		q, ok := ldflagsQuote(f)
+		if !ok {
+			return "", fmt.Errorf("ldflags: %q contains both single and double quotes (or a NUL byte) and cannot be passed to go build", f)
+		}
+		quoted = append(quoted, q)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// ldflagsQuote quotes value as one -ldflags field. go build splits -ldflags
+// at whitespace outside quotes and knows no escapes, so a value containing
+// both quote characters (or a NUL byte) cannot be passed.
+func ldflagsQuote(value string) (string, bool) {
+	switch {
+	case strings.ContainsRune(value, 0):
+		return "", false
+	case !strings.Contains(value, "'"):
+		return "'" + value + "'", true
+	case !strings.Contains(value, `"`):
+		return `"` + value + `"`, true
+	}
+	return "", false
+}
+
+// splitLdflag splits an ldflags template at whitespace outside template
+// actions, quotes and control blocks. A quote opening a field runs to the
+// matching quote, which are both removed.
+func splitLdflag(s string) ([]ldflagField, error) {
+	var (
+		fields []ldflagField
+		cur    strings.Builder
+		field  ldflagField
+		open   bool // a field has started
+		quote  byte
+		depth  int // open control blocks
+	)
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "{{") {
+			end := strings.Index(s[i:], "}}")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed action")
+			}
+			action := s[i : i+end+2]
+			switch actionKeyword(action) {
+			case "if", "range", "with", "block", "define":
+				depth++
+				field.Block = true
+			case "end":
+				depth--
+			}
+			cur.WriteString(action)
+			open = true
+			i += len(action)
+			continue
+		}
+
+		c := s[i]
+		i++
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+				continue
+			}
+		case (c == '\'' || c == '"') && !open:
+			quote, open = c, true
+			continue
+		case strings.IndexByte(ldflagsSpace, c) >= 0 && depth <= 0:
+			if open {
+				field.Text = cur.String()
+				fields = append(fields, field)
+				cur.Reset()
+				field, open = ldflagField{}, false
+			}
+			continue
+		}
+		cur.WriteByte(c)
+		open = true
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c string", quote)
+	}
+	if open {
+		field.Text = cur.String()
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// actionKeyword returns the first word of a template action, e.g. "if"
+// for {{- if .Env.DEBUG }}.
+func actionKeyword(action string) string {
+	action = strings.TrimPrefix(action, "{{")
+	action = strings.TrimPrefix(action, "-")
+	word, _, _ := strings.Cut(strings.TrimLeft(action, ldflagsSpace), " ")
+	return strings.TrimSuffix(strings.TrimSuffix(word, "}}"), "-")
+}
+
+// splitQuoted splits rendered ldflags like go build does: at whitespace,
+// with a quote opening a field running to the matching quote.
+func splitQuoted(s string) []string {
+	var fields []string
+	for {
+		s = strings.TrimLeft(s, ldflagsSpace)
+		if s == "" {
+			return fields
+		}
+		if q := s[0]; q == '\'' || q == '"' {
+			if end := strings.IndexByte(s[1:], q); end >= 0 {
+				fields = append(fields, s[1:end+1])
+				s = s[end+2:]
+				continue
+			}
+		}
+		end := strings.IndexAny(s, ldflagsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		fields = append(fields, s[:end])
+		s = s[end:]
+	}
+}
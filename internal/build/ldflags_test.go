@@ -0,0 +1,133 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderLdflags(t *testing.T) {
+	data := struct {
+		Version string
+		Date    string
+		Env     map[string]string
+	}{
+		Version: "v1.2.0",
+		Date:    "Mon, 02 Jan 2006 15:04:05 MST",
+		Env:     map[string]string{"COMPANY": "Acme Inc", "DEBUG": "1", "QUOTED": `say "hi"`, "AUTHOR": "Jürgen Müller 日本"},
+	}
+	tests := []struct {
+		name    string
+		ldflags []string
+		want    []string
+	}{
+		{"plain", []string{"-s -w", "-X main.version={{.Version}}"}, []string{"-s", "-w", "-X", "main.version=v1.2.0"}},
+		{"value with spaces", []string{"-X main.date={{.Date}}"}, []string{"-X", "main.date=Mon, 02 Jan 2006 15:04:05 MST"}},
+		{"spaces inside actions", []string{`-X main.company={{ index .Env "COMPANY" }}`}, []string{"-X", "main.company=Acme Inc"}},
+		{"quoted field", []string{`-X 'main.greeting={{.Env.QUOTED}} from {{.Env.COMPANY}}'`}, []string{"-X", `main.greeting=say "hi" from Acme Inc`}},
+		{"unicode", []string{"-X main.author={{.Env.AUTHOR}}"}, []string{"-X", "main.author=Jürgen Müller 日本"}},
+		{"trimmed and empty", []string{"  -s  ", "{{.Env.MISSING_EMPTY}}", ""}, []string{"-s"}},
+		{"block", []string{"{{ if .Env.DEBUG }}-X main.debug=true -X 'main.mode=very verbose'{{ end }}"}, []string{"-X", "main.debug=true", "-X", "main.mode=very verbose"}},
+	}
+	data.Env["MISSING_EMPTY"] = ""
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderLdflags(tt.ldflags, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("renderLdflags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := renderLdflags([]string{"-s", "-X 'main.x=y"}, data); err == nil || !strings.Contains(err.Error(), "ldflags[1]") {
+		t.Errorf("unterminated quote error = %v", err)
+	}
+}
+
+func TestJoinLdflags(t *testing.T) {
+	got, err := joinLdflags([]string{"-s", "-X", "main.date=Mon Jan 2", "-X", `main.msg=say "hi"`, "-X", "main.name=it's"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `-s -X 'main.date=Mon Jan 2' -X 'main.msg=say "hi"' -X "main.name=it's"`
+	if got != want {
+		t.Errorf("joinLdflags() = %s, want %s", got, want)
+	}
+
+	_, err = joinLdflags([]string{"-X", `main.msg=it's "new"`})
+	if err == nil || !strings.Contains(err.Error(), "cannot be passed to go build") {
+		t.Errorf("joinLdflags() error = %v", err)
+	}
+}
+
+func TestLdflagsQuote(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{"main.changelog=## What's Changed\n", `"main.changelog=## What's Changed` + "\n\"", true},
+		{`main.changelog=say "hi"`, `'main.changelog=say "hi"'`, true},
+		{`main.changelog=it's "new"`, "", false},
+		{"main.changelog=a\x00b", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ldflagsQuote(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ldflagsQuote(%q) = %q, %v; want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestLdflagsGoBuild checks that go build receives rendered values intact.
+func TestLdflagsGoBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nimport \"fmt\"\n\nvar date, company, author string\n\nfunc main() { fmt.Print(date + \"|\" + company + \"|\" + author) }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := struct{ Date, Author string }{Date: "2024-05-01 12:00:00 +0000 UTC", Author: "Jürgen Müller"}
+	fields, err := renderLdflags([]string{
+		"-s -w",
+		"-X main.date={{.Date}}",
+		`-X 'main.company=Acme "Widgets" Inc'`,
+		"-X main.author={{.Author}}",
+	}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ldflags, err := joinLdflags(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(t.TempDir(), "app")
+	cmd := exec.Command("go", "build", "-ldflags", ldflags, "-o", bin, ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	out, err := exec.Command(bin).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `2024-05-01 12:00:00 +0000 UTC|Acme "Widgets" Inc|Jürgen Müller`
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,57 @@
+package build
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// outputMu keeps the lines of concurrent go build processes whole.
+var outputMu sync.Mutex
+
+// lineWriter writes complete lines to w, each starting with prefix. A
+// trailing partial line is kept until the next newline or Flush.
+type lineWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newLineWriter(w io.Writer, prefix string) *lineWriter {
+	return &lineWriter{w: w, prefix: prefix}
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	i := bytes.LastIndexByte(l.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	if err := l.write(l.buf[:i+1]); err != nil {
+		return 0, err
+	}
+	l.buf = append(l.buf[:0], l.buf[i+1:]...)
+	return len(p), nil
+}
+
+// Flush writes a trailing partial line, ending it with a newline.
+func (l *lineWriter) Flush() error {
+	if len(l.buf) == 0 {
+		return nil
+	}
+	err := l.write(append(l.buf, '\n'))
+	l.buf = l.buf[:0]
+	return err
+}
+
+func (l *lineWriter) write(lines []byte) error {
+	var out bytes.Buffer
+	for line := range bytes.Lines(lines) {
+		out.WriteString(l.prefix)
+		out.Write(line)
+	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	_, err := l.w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,27 @@
+package build
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newLineWriter(&buf, "[app linux/amd64] ")
+	if _, err := w.Write([]byte("# example.com/app\n./main.go:3")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[app linux/amd64] # example.com/app\n"; buf.String() != want {
+		t.Errorf("partial line written: %q, want %q", buf.String(), want)
+	}
+	if _, err := w.Write([]byte(":2: undefined: x\nno newline")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "[app linux/amd64] # example.com/app\n[app linux/amd64] ./main.go:3:2: undefined: x\n[app linux/amd64] no newline\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
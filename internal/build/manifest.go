@@ -0,0 +1,107 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/metrics"
+)
+
+// artifactEntries returns the manifest entries of the built artifacts.
+// Archived artifacts are listed by their archives and the bare binaries of
+// the binary format, the rest by their binaries.
+func artifactEntries(artifacts []Artifact, archives map[string][]string) []manifest.Artifact {
+	var entries []manifest.Artifact
+	listed := make(map[string]bool)
+	for _, a := range artifacts {
+		paths, archived := archives[a.DirPath]
+		if archived {
+			// Grouped artifacts share their directory's archives
+			if listed[a.DirPath] {
+				continue
+			}
+			listed[a.DirPath] = true
+		} else {
+			paths = []string{filepath.Join(a.DirPath, a.FileName())}
+			for _, extra := range a.Extras {
+				paths = append(paths, filepath.Join(a.DirPath, extra))
+			}
+		}
+
+		entry := manifest.Artifact{
+			Goos:         a.OS,
+			Goarch:       a.Arch,
+			Goarm:        a.Arm,
+			Variant:      a.Variant,
+			Builds:       a.Builds,
+			Instrumented: a.Instrumented,
+		}
+		for i, p := range paths {
+			entry.Name = filepath.Base(p)
+			entry.Path = p
+			switch {
+			case archived && isBareBinary(p):
+				entry.Type = manifest.TypeBinary
+			case archived:
+				entry.Type = manifest.TypeArchive
+			case i == 0:
+				entry.Type = manifest.TypeBinary
+			default:
+				entry.Type = manifest.TypeFile
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// isBareBinary reports whether path, an output of createArchives, is a
+// binary copied by the binary format rather than an archive.
+func isBareBinary(path string) bool {
+	_, err := archive.FormatOf(path)
+	return err != nil
+}
+
+// fileEntries returns manifest entries for further outputs such as the
+// checksums file, its signature and generated files.
+func fileEntries(files []string) []manifest.Artifact {
+	entries := make([]manifest.Artifact, 0, len(files))
+	for _, p := range files {
+		name := filepath.Base(p)
+		entries = append(entries, manifest.Artifact{Name: name, Path: p, Type: manifest.TypeFromName(name)})
+	}
+	return entries
+}
+
+// writeManifest records entries, with their sizes and SHA-256, and the
+// go build commands in outDir/artifacts.json.
+func writeManifest(outDir string, rel config.ReleaseData, goVersion string, started time.Time, entries []manifest.Artifact, commands []manifest.Command) error {
+	m := &manifest.Manifest{
+		Version:   rel.Version,
+		Channel:   rel.Channel,
+		GoVersion: goVersion,
+		Started:   started.UTC(),
+		Created:   time.Now().UTC(),
+		Artifacts: []manifest.Artifact{},
+		Commands:  commands,
+	}
+
+	for _, entry := range entries {
+		if digest, err := checksum.File(entry.Path); err == nil {
+			entry.Size = digest.Size
+			entry.SHA256 = digest.SHA256Hex()
+		}
+		metrics.ObserveArtifact(entry.Name, entry.Type, entry.Size)
+		m.Artifacts = append(m.Artifacts, entry)
+	}
+
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		return fmt.Errorf("write build manifest: %w", err)
+	}
+	return nil
+}
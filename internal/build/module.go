@@ -0,0 +1,69 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// buildDir returns the directory go build runs in for buildCfg: its dir,
+// or the config file's directory.
+func buildDir(cfg *config.Config, buildCfg config.BuildConfig) string {
+	if buildCfg.Dir != "" {
+		return buildCfg.Dir
+	}
+	return cfg.Dir
+}
+
+// checkMain fails when the dir of buildCfg or its main, if main is a
+// relative or absolute path rather than an import path, does not exist.
+// A templated main is left to go build, as it is rendered later.
+func checkMain(dir string, buildCfg config.BuildConfig) error {
+	if buildCfg.Dir != "" {
+		info, err := os.Stat(buildCfg.Dir)
+		if err != nil {
+			return fmt.Errorf("dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("dir: %s is not a directory", buildCfg.Dir)
+		}
+	}
+	main := buildCfg.Main
+	if main != "." && main != ".." && !strings.HasPrefix(main, "./") && !strings.HasPrefix(main, "../") && !filepath.IsAbs(main) ||
+		strings.Contains(main, "{{") {
+		return nil
+	}
+	path := main
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if dir == "" {
+			dir = "."
+		}
+		return fmt.Errorf("main %s not found in %s", main, dir)
+	}
+	return nil
+}
+
+// modulePath returns the path of the module go build compiles the main
+// package of buildCfg from when run in dir, as reported by go list of
+// goBinary. env holds the git_auth variables of the build.
+func modulePath(ctx context.Context, goBinary, dir string, buildCfg config.BuildConfig, env []string) (string, error) {
+	cmd := exec.CommandContext(ctx, goBinary, "list", "-f", "{{with .Module}}{{.Path}}{{end}}", buildCfg.Main)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), env...), buildCfg.Env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go list %s: %w", buildCfg.Main, err)
+	}
+	if path := strings.TrimSpace(string(out)); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("go list %s: not in a module", buildCfg.Main)
+}
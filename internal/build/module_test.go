@@ -0,0 +1,105 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestCheckMain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "cmd", "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		buildCfg config.BuildConfig
+		wantErr  string
+	}{
+		{"relative main", config.BuildConfig{Main: "./cmd/app"}, ""},
+		{"dir and main", config.BuildConfig{Dir: dir, Main: "./cmd/app"}, ""},
+		{"import path", config.BuildConfig{Main: "example.com/app/cmd/app"}, ""},
+		{"missing main", config.BuildConfig{Main: "./cmd/other"}, "main ./cmd/other not found in " + dir},
+		{"missing dir", config.BuildConfig{Dir: filepath.Join(dir, "services"), Main: "."}, "dir: "},
+		{"dir is a file", config.BuildConfig{Dir: filepath.Join(dir, "file"), Main: "."}, "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMain(dir, tt.buildCfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkMain() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkMain() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRunWorkspace builds a main package of a go.work module other than
+// the one at the config directory.
+func TestRunWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	root := t.TempDir()
+	files := map[string]string{
+		"go.work":                          "go 1.21\n\nuse ./services/api\n",
+		"services/api/go.mod":              "module example.com/api\n\ngo 1.21\n",
+		"services/api/cmd/api/main.go":     "package main\n\nfunc main() {}\n",
+		"services/other/go.mod":            "module example.com/other\n\ngo 1.21\n",
+		"services/other/cmd/other/main.go": "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// -mod=mod is rejected in workspace mode
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOWORK", "")
+
+	buildCfg := config.BuildConfig{
+		Dir:  filepath.Join(root, "services", "api"),
+		Main: "./cmd/api", OutputName: "api",
+		Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH},
+	}
+	if module, err := modulePath(context.Background(), "go", buildCfg.Dir, buildCfg, nil); err != nil || module != "example.com/api" {
+		t.Errorf("modulePath() = %q, %v; want example.com/api", module, err)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{Dir: root, OutDir: outDir, Builds: []config.BuildConfig{buildCfg}}
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(artifacts) != 1 {
+		t.Fatalf("Run() artifacts = %+v", artifacts)
+	}
+	if _, err := os.Stat(filepath.Join(artifacts[0].DirPath, artifacts[0].FileName())); err != nil {
+		t.Errorf("binary not built: %v", err)
+	}
+
+	cfg.Builds[0].Main = "./cmd/other"
+	if _, err := Run(context.Background(), cfg, Options{SkipArchives: true}); err == nil || !strings.Contains(err.Error(), "main ./cmd/other not found") {
+		t.Errorf("Run() with a main outside dir error = %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package build
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/checksums"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/sign"
+)
+
+// Name is a final local file or remote destination produced by the pipeline.
+type Name struct {
+	// Path is the local file path or the remote destination key.
+	Path string
+	// Source describes the config entry that produces the name.
+	Source string
+	// Origin is the local file uploaded to a remote destination; it is empty
+	// for local names.
+	Origin string
+}
+
+// Collision is a pair of config entries that produce the same final name.
+type Collision struct {
+	Path   string
+	First  string
+	Second string
+}
+
+// CollisionError reports artifacts that would silently overwrite each other.
+type CollisionError struct {
+	Collisions []Collision
+}
+
+func (e *CollisionError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d artifact name collision(s):\n", len(e.Collisions))
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPRODUCED BY\tOVERWRITTEN BY")
+	for _, c := range e.Collisions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Path, c.First, c.Second)
+	}
+	_ = tw.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// ResolveNames computes every local file and remote destination key the
+// pipeline produces for the git tag, without building anything. Targets skipped
+// by only_if_changed are included, so the result does not depend on git history.
+func ResolveNames(cfg *config.Config, tag string) ([]Name, error) {
+	outDir, err := cfg.OutputDir(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []Name{{Path: filepath.Join(outDir, manifest.FileName), Source: "build manifest"}}
+	// published holds the top-level files of outDir, which is what publishers upload
+	published := []Name{names[0]}
+	// archivedDirs holds the output directories of grouped builds already archived
+	archivedDirs := make(map[string]bool)
+
+	for i, buildCfg := range cfg.Builds {
+		for _, target := range ResolveTargets(buildCfg) {
+			if target.Skipped() {
+				continue
+			}
+			artifact := Artifact{
+				BinaryName:   target.Build,
+				ID:           buildCfg.ID,
+				Version:      cfg.FormatVersion(tag),
+				Tag:          tag,
+				Channel:      cfg.Channel,
+				Commit:       commitPlaceholder,
+				OS:           target.Goos,
+				Arch:         target.Goarch,
+				Arm:          target.Goarm,
+				Variant:      target.Variant,
+				Group:        buildCfg.Group,
+				Builds:       buildIDs(cfg, buildCfg),
+				Instrumented: buildCfg.Instrumented(),
+			}
+			buildmode := targetBuildmode(buildCfg, target)
+			p := platformFor(buildCfg, target.Goos, buildmode)
+			artifact.Ext, artifact.Executable = p.Ext, p.Executable
+			artifact.DirPath = outputDir(!buildCfg.DisablePlatformSuffix, outDir, artifact)
+			buildSource := fmt.Sprintf("builds[%d] %s", i, target)
+
+			binary := Name{
+				Path:   filepath.Join(artifact.DirPath, artifact.FileName()),
+				Source: buildSource + " binary",
+			}
+			names = append(names, binary)
+			archived := slices.ContainsFunc(cfg.Archives, func(a config.ArchiveConfig) bool { return a.Applies(artifact.Builds) })
+			if len(cfg.SBOMs) > 0 && !archived {
+				names = append(names, Name{Path: binary.Path + manifest.SBOMSuffix, Source: "sboms[0] for " + buildSource})
+			}
+			if buildCfg.IncludeWasmExec && target.Goos == "js" {
+				names = append(names, Name{
+					Path:   filepath.Join(artifact.DirPath, wasmExecName),
+					Source: buildSource + " " + wasmExecName,
+				})
+			}
+			if hasHeader(buildmode) {
+				names = append(names, Name{
+					Path:   filepath.Join(artifact.DirPath, artifact.BinaryName+".h"),
+					Source: buildSource + " header",
+				})
+			}
+
+			for j, archiveCfg := range cfg.Archives {
+				if !slices.Contains(archiveCfg.Formats, archive.BinaryFormat) || !archiveCfg.Applies([]string{artifact.ID}) {
+					continue
+				}
+				hash := hashPlaceholder(filepath.Base(artifact.DirPath) + "/" + artifact.FileName())
+				name, err := binaryBaseName(cfg, j, artifact, hash)
+				if err != nil {
+					return nil, err
+				}
+				bare := Name{
+					Path:   filepath.Join(outDir, name+artifact.Ext),
+					Source: fmt.Sprintf("archives[%d] %s for %s", j, archive.BinaryFormat, buildSource),
+				}
+				names = append(names, bare)
+				published = append(published, bare)
+				if len(cfg.SBOMs) > 0 {
+					sbom := Name{Path: bare.Path + manifest.SBOMSuffix, Source: "sboms[0] for " + bare.Source}
+					names = append(names, sbom)
+					published = append(published, sbom)
+				}
+			}
+
+			if archivedDirs[artifact.DirPath] {
+				continue
+			}
+			archivedDirs[artifact.DirPath] = true
+			for j, archiveCfg := range cfg.Archives {
+				if !archiveCfg.Applies(artifact.Builds) {
+					continue
+				}
+				archiveName, err := archiveBaseName(cfg, j, artifact, hashPlaceholder(filepath.Base(artifact.DirPath)))
+				if err != nil {
+					return nil, err
+				}
+				for _, format := range archiveCfg.Formats {
+					archiver, err := archive.New(format, archiveCfg.CompressionLevel)
+					if err != nil {
+						continue
+					}
+					name := Name{
+						Path:   filepath.Join(outDir, archiveName+"."+archiver.Extension()),
+						Source: fmt.Sprintf("archives[%d] %s for %s", j, format, buildSource),
+					}
+					names = append(names, name)
+					published = append(published, name)
+					if len(cfg.SBOMs) > 0 {
+						sbom := Name{Path: name.Path + manifest.SBOMSuffix, Source: "sboms[0] for " + name.Source}
+						names = append(names, sbom)
+						published = append(published, sbom)
+					}
+					if len(cfg.Signs) > 0 && sign.SignsArchives(cfg.Signs[0]) {
+						for _, path := range sign.SignatureFiles(cfg.Signs[0], name.Path) {
+							sig := Name{Path: path, Source: "signs[0]"}
+							names = append(names, sig)
+							published = append(published, sig)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for i, fileCfg := range cfg.GeneratedFiles {
+		fileName, err := generatedFileName(fileCfg, cfg.Release(tag))
+		if err != nil {
+			return nil, fmt.Errorf("generated_files[%d]: %w", i, err)
+		}
+		name := Name{Path: filepath.Join(outDir, fileName), Source: fmt.Sprintf("generated_files[%d]", i)}
+		names = append(names, name)
+		published = append(published, name)
+	}
+
+	if cfg.Checksum.Enabled() || len(cfg.Signs) > 0 {
+		for _, file := range checksums.Files(cfg.Checksum.Algorithms()) {
+			source := "checksum"
+			if !cfg.Checksum.Enabled() {
+				source = "signs[0]"
+			}
+			files := []Name{{Path: filepath.Join(outDir, file), Source: source}}
+			if len(cfg.Signs) > 0 {
+				for _, sig := range sign.SignatureFiles(cfg.Signs[0], filepath.Join(outDir, file)) {
+					files = append(files, Name{Path: sig, Source: "signs[0]"})
+				}
+			}
+			names = append(names, files...)
+			published = append(published, files...)
+		}
+	}
+
+	rel := cfg.Release(tag)
+	for i, blob := range cfg.Blobs {
+		enabled, err := blob.IsEnabled(rel)
+		if err != nil {
+			return nil, fmt.Errorf("blobs[%d]: %w", i, err)
+		}
+		if !enabled {
+			continue
+		}
+		remoteDir, err := blob.RemoteDir(rel)
+		if err != nil {
+			return nil, fmt.Errorf("blobs[%d]: %w", i, err)
+		}
+		for _, local := range published {
+			fileName := filepath.Base(local.Path)
+			objectName, err := blob.ObjectName(fileName, rel, hashPlaceholder(fileName))
+			if err != nil {
+				return nil, fmt.Errorf("blobs[%d]: %w", i, err)
+			}
+			names = append(names, Name{
+				Path:   remoteKey(blob, remoteDir, objectName),
+				Source: fmt.Sprintf("blobs[%d] %s: %s", i, blob.Name, local.Source),
+				Origin: local.Path,
+			})
+		}
+	}
+
+	return names, nil
+}
+
+// commitPlaceholder stands in for the commit hash, which is the same for
+// every name of a release.
+const commitPlaceholder = "<commit>"
+
+// hashPlaceholder stands in for the content hash of the file produced from
+// name, which is only known after the build. Each file gets its own
+// placeholder, so hashed names collide only if their other parts do.
+func hashPlaceholder(name string) string {
+	return "<sha256 of " + name + ">"
+}
+
+// remoteKey returns the destination of fileName uploaded to blob, qualified
+// with the bucket or server so that keys of different destinations never match.
+func remoteKey(blob config.BlobConfig, remoteDir, fileName string) string {
+	switch blob.Provider {
+	case "s3":
+		return strings.TrimSuffix(blob.Endpoint, "/") + "/" + blob.Bucket + "/" + path.Join(remoteDir, fileName)
+	default:
+		return blob.Server + ":" + filepath.Join(remoteDir, fileName)
+	}
+}
+
+// FindCollisions returns every pair of names with the same path. Remote
+// names uploaded from the same local file (e.g. two blobs sharing a
+// directory) do not collide, since both write identical content.
+func FindCollisions(names []Name) []Collision {
+	var collisions []Collision
+	first := make(map[string]Name, len(names))
+	for _, n := range names {
+		prev, ok := first[n.Path]
+		if !ok {
+			first[n.Path] = n
+			continue
+		}
+		if n.Origin != "" && n.Origin == prev.Origin {
+			continue
+		}
+		collisions = append(collisions, Collision{Path: n.Path, First: prev.Source, Second: n.Source})
+	}
+	return collisions
+}
+
+// CheckNames resolves the names produced for the git tag and returns a
+// *CollisionError when two config entries map to the same final name.
+func CheckNames(cfg *config.Config, tag string) error {
+	names, err := ResolveNames(cfg, tag)
+	if err != nil {
+		return err
+	}
+	if collisions := FindCollisions(names); len(collisions) > 0 {
+		return &CollisionError{Collisions: collisions}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package build
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestCheckNames(t *testing.T) {
+	base := func() *config.Config {
+		return &config.Config{
+			OutDir: "dist",
+			Builds: []config.BuildConfig{
+				{Main: "./cmd/app", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64", "arm64"}},
+			},
+			Archives: []config.ArchiveConfig{
+				{Formats: []string{"tar.gz", "zip"}, NameTemplate: "{{.Binary}}_{{.Version}}_{{.Os}}_{{.Arch}}"},
+			},
+			Blobs: []config.BlobConfig{
+				{Provider: "s3", Name: "s3", Bucket: "b", Endpoint: "https://s3.example.com", Directory: "releases/{{.Version}}"},
+				{Provider: "ssh", Name: "ssh", Server: "host", Directory: "/srv/{{.Version}}"},
+			},
+		}
+	}
+
+	t.Run("no collisions", func(t *testing.T) {
+		if err := CheckNames(base(), "v1.0.0"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("archive template without arch", func(t *testing.T) {
+		cfg := base()
+		cfg.Archives[0].NameTemplate = "{{.Binary}}_{{.Os}}"
+		err := CheckNames(cfg, "v1.0.0")
+		var collErr *CollisionError
+		if !errors.As(err, &collErr) {
+			t.Fatalf("expected CollisionError, got %v", err)
+		}
+		// two formats per OS, each colliding once locally
+		if len(collErr.Collisions) != 4 {
+			t.Errorf("got %d collisions, want 4: %v", len(collErr.Collisions), err)
+		}
+		c := collErr.Collisions[0]
+		if c.Path != "dist/app_linux.tar.gz" {
+			t.Errorf("Path = %q", c.Path)
+		}
+		if c.First != "archives[0] tar.gz for builds[0] linux/amd64" || c.Second != "archives[0] tar.gz for builds[0] linux/arm64" {
+			t.Errorf("unexpected sources: %+v", c)
+		}
+		if !strings.Contains(err.Error(), "PRODUCED BY") {
+			t.Errorf("error should contain a table: %v", err)
+		}
+	})
+
+	t.Run("archive builds filters", func(t *testing.T) {
+		cfg := base()
+		cfg.Builds[0].ID = "app"
+		cfg.Builds = append(cfg.Builds, config.BuildConfig{ID: "cli", Main: "./cmd/cli", Goos: []string{"linux"}, Goarch: []string{"amd64"}})
+		// Both configs render the same names, but for different builds
+		cfg.Archives[0].NameTemplate = "release_{{.Os}}_{{.Arch}}"
+		cfg.Archives[0].Builds = []string{"app"}
+		cfg.Archives = append(cfg.Archives, config.ArchiveConfig{
+			Formats: []string{"binary"}, NameTemplate: "release_{{.Os}}_{{.Arch}}", Builds: []string{"cli"},
+		})
+		if err := CheckNames(cfg, "v1.0.0"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cfg.Archives[1].Formats = []string{"tar.gz"}
+		var collErr *CollisionError
+		if err := CheckNames(cfg, "v1.0.0"); !errors.As(err, &collErr) || len(collErr.Collisions) != 1 {
+			t.Fatalf("expected one collision, got %v", err)
+		}
+	})
+
+	t.Run("bare binaries of grouped builds", func(t *testing.T) {
+		cfg := base()
+		cfg.Builds[0].Group = "suite"
+		cfg.Builds = append(cfg.Builds, config.BuildConfig{
+			Main: "./cmd/worker", Group: "suite", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64", "arm64"},
+		})
+		cfg.Archives[0].Formats = []string{"binary", "tar.gz"}
+		if err := CheckNames(cfg, "v1.0.0"); err != nil {
+			t.Fatalf("binaries are named after themselves, not the group: %v", err)
+		}
+
+		cfg.Archives[0].NameTemplate = "{{.Binary}}_{{.Os}}"
+		var collErr *CollisionError
+		if err := CheckNames(cfg, "v1.0.0"); !errors.As(err, &collErr) {
+			t.Fatalf("expected CollisionError, got %v", err)
+		}
+		if c := collErr.Collisions[0]; c.Path != "dist/app_linux" || c.First != "archives[0] binary for builds[0] linux/amd64" {
+			t.Errorf("unexpected collision: %+v", c)
+		}
+	})
+
+	t.Run("binaries without platform suffix", func(t *testing.T) {
+		cfg := base()
+		cfg.Builds[0].DisablePlatformSuffix = true
+		cfg.Archives = nil
+		err := CheckNames(cfg, "v1.0.0")
+		var collErr *CollisionError
+		if !errors.As(err, &collErr) {
+			t.Fatalf("expected CollisionError, got %v", err)
+		}
+		if collErr.Collisions[0].Path != "dist/app_v1.0.0/app" {
+			t.Errorf("Path = %q", collErr.Collisions[0].Path)
+		}
+	})
+
+	t.Run("two builds with the same output name", func(t *testing.T) {
+		cfg := base()
+		cfg.Builds = append(cfg.Builds, config.BuildConfig{
+			Main: "./cmd/other", OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+		})
+		err := CheckNames(cfg, "v1.0.0")
+		var collErr *CollisionError
+		if !errors.As(err, &collErr) {
+			t.Fatalf("expected CollisionError, got %v", err)
+		}
+		if c := collErr.Collisions[0]; !strings.HasPrefix(c.Second, "builds[1]") {
+			t.Errorf("unexpected collision: %+v", c)
+		}
+	})
+
+	t.Run("blobs sharing a destination", func(t *testing.T) {
+		cfg := base()
+		cfg.Blobs = append(cfg.Blobs, cfg.Blobs[0])
+		if err := CheckNames(cfg, "v1.0.0"); err != nil {
+			t.Fatalf("identical uploads should not collide: %v", err)
+		}
+	})
+
+	t.Run("hashed names", func(t *testing.T) {
+		cfg := base()
+		cfg.Archives[0].NameTemplate = "{{.Binary}}_{{.Os}}_{{.Arch}}_{{.ShortSha256}}"
+		cfg.Blobs[0].ObjectTemplate = "{{.ShortSha256}}-{{.Name}}"
+		if err := CheckNames(cfg, "v1.0.0"); err != nil {
+			t.Fatalf("hashed names should not collide: %v", err)
+		}
+
+		cfg.Archives[0].NameTemplate = "{{.Binary}}_{{.ShortSha256}}"
+		cfg.Archives = append(cfg.Archives, cfg.Archives[0])
+		var collErr *CollisionError
+		if err := CheckNames(cfg, "v1.0.0"); !errors.As(err, &collErr) {
+			t.Fatalf("identical archives should collide, got %v", err)
+		}
+	})
+	t.Run("checksums files", func(t *testing.T) {
+		cfg := base()
+		cfg.Checksum.Algorithm = []string{"sha256", "sha512"}
+		cfg.GeneratedFiles = []config.GeneratedFileConfig{{NameTemplate: "checksums_sha512.txt", ContentTemplate: "x"}}
+		var collErr *CollisionError
+		if err := CheckNames(cfg, "v1.0.0"); !errors.As(err, &collErr) {
+			t.Fatalf("expected CollisionError, got %v", err)
+		}
+		if c := collErr.Collisions[0]; c.Path != "dist/checksums_sha512.txt" || c.Second != "checksum" {
+			t.Errorf("unexpected collision: %+v", c)
+		}
+	})
+}
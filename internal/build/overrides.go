@@ -0,0 +1,79 @@
+package build
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// targetSettings returns the flags, ldflags templates and env of buildCfg
+// for t after applying the overrides matching t in order.
+func targetSettings(buildCfg config.BuildConfig, t Target) (flags, ldflags, env []string) {
+	flags, ldflags, env = buildCfg.Flags, buildCfg.Ldflags, buildCfg.Env
+	for _, o := range buildCfg.Overrides {
+		if !o.Matches(t.Goos, t.Goarch, t.Goarm) {
+			continue
+		}
+		flags = mergeSetting(flags, o.Flags, o.Merge)
+		ldflags = mergeSetting(ldflags, o.Ldflags, o.Merge)
+		env = mergeSetting(env, o.Env, o.Merge)
+	}
+	return flags, ldflags, env
+}
+
+// targetToolFlags returns the gcflags and asmflags templates of buildCfg
+// for t after applying the overrides matching t in order.
+func targetToolFlags(buildCfg config.BuildConfig, t Target) (gcflags, asmflags []string) {
+	gcflags, asmflags = buildCfg.Gcflags, buildCfg.Asmflags
+	for _, o := range buildCfg.Overrides {
+		if !o.Matches(t.Goos, t.Goarch, t.Goarm) {
+			continue
+		}
+		gcflags = mergeSetting(gcflags, o.Gcflags, o.Merge)
+		asmflags = mergeSetting(asmflags, o.Asmflags, o.Merge)
+	}
+	return gcflags, asmflags
+}
+
+// targetBuildmode returns the buildmode of buildCfg for t: that of the last
+// matching override setting one, or the build's.
+func targetBuildmode(buildCfg config.BuildConfig, t Target) string {
+	mode := buildCfg.Buildmode
+	for _, o := range buildCfg.Overrides {
+		if o.Buildmode != "" && o.Matches(t.Goos, t.Goarch, t.Goarm) {
+			mode = o.Buildmode
+		}
+	}
+	return mode
+}
+
+// mergeSetting merges the entries of an override into base. An override
+// that does not set the setting keeps base; replace with an empty list
+// clears it.
+func mergeSetting(base, override []string, merge string) []string {
+	switch {
+	case override == nil:
+		return base
+	case merge == config.MergeReplace:
+		return override
+	}
+	return append(slices.Clip(base), override...)
+}
+
+// targetLdflags renders the ldflags templates of a target into the
+// -ldflags value of go build, followed by the embed_changelog -X flag.
+func targetLdflags(templates []string, data any, embedLdflag string) (string, error) {
+	fields, err := renderLdflags(templates, data)
+	if err != nil {
+		return "", err
+	}
+	ldflags, err := joinLdflags(fields)
+	if err != nil {
+		return "", err
+	}
+	if embedLdflag != "" {
+		ldflags = strings.TrimPrefix(ldflags+" "+embedLdflag, " ")
+	}
+	return ldflags, nil
+}
@@ -0,0 +1,137 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestTargetSettings(t *testing.T) {
+	buildCfg := config.BuildConfig{
+		Flags:   []string{"-trimpath"},
+		Ldflags: []string{"-s -w"},
+		Env:     []string{"CGO_ENABLED=0"},
+		Overrides: []config.OverrideConfig{
+			{TargetMatch: config.TargetMatch{Goos: "linux"}, Flags: []string{"-tags=sqlite_static"}},
+			{TargetMatch: config.TargetMatch{Goos: "windows", Goarch: "amd64"}, Merge: config.MergeReplace, Env: []string{"CC=x86_64-w64-mingw32-gcc"}},
+			{TargetMatch: config.TargetMatch{Goarch: "arm", Goarm: "6"}, Merge: config.MergeReplace, Ldflags: []string{}},
+			{TargetMatch: config.TargetMatch{Goos: "linux"}, Env: []string{"CGO_ENABLED=1"}},
+		},
+	}
+	tests := []struct {
+		target              Target
+		flags, ldflags, env []string
+	}{
+		{Target{Goos: "darwin", Goarch: "arm64"}, []string{"-trimpath"}, []string{"-s -w"}, []string{"CGO_ENABLED=0"}},
+		{Target{Goos: "linux", Goarch: "amd64"}, []string{"-trimpath", "-tags=sqlite_static"}, []string{"-s -w"}, []string{"CGO_ENABLED=0", "CGO_ENABLED=1"}},
+		{Target{Goos: "windows", Goarch: "amd64"}, []string{"-trimpath"}, []string{"-s -w"}, []string{"CC=x86_64-w64-mingw32-gcc"}},
+		{Target{Goos: "linux", Goarch: "arm", Goarm: "6"}, []string{"-trimpath", "-tags=sqlite_static"}, []string{}, []string{"CGO_ENABLED=0", "CGO_ENABLED=1"}},
+	}
+	for _, tt := range tests {
+		flags, ldflags, env := targetSettings(buildCfg, tt.target)
+		if !slices.Equal(flags, tt.flags) || !slices.Equal(ldflags, tt.ldflags) || !slices.Equal(env, tt.env) {
+			t.Errorf("targetSettings(%s) = %v, %v, %v; want %v, %v, %v", tt.target, flags, ldflags, env, tt.flags, tt.ldflags, tt.env)
+		}
+	}
+	if !slices.Equal(buildCfg.Env, []string{"CGO_ENABLED=0"}) {
+		t.Errorf("overrides changed the build env to %v", buildCfg.Env)
+	}
+}
+
+func TestTargetToolFlags(t *testing.T) {
+	buildCfg := config.BuildConfig{
+		Gcflags: []string{"-trimpath=/src"},
+		Overrides: []config.OverrideConfig{
+			{TargetMatch: config.TargetMatch{Goos: "linux", Goarch: "amd64"}, Gcflags: []string{"all=-N -l"}, Asmflags: []string{"-trimpath=/src"}},
+			{TargetMatch: config.TargetMatch{Goos: "windows"}, Merge: config.MergeReplace, Gcflags: []string{}},
+		},
+	}
+	tests := []struct {
+		target            Target
+		gcflags, asmflags []string
+	}{
+		{Target{Goos: "linux", Goarch: "amd64"}, []string{"-trimpath=/src", "all=-N -l"}, []string{"-trimpath=/src"}},
+		{Target{Goos: "linux", Goarch: "arm64"}, []string{"-trimpath=/src"}, nil},
+		{Target{Goos: "windows", Goarch: "amd64"}, []string{}, nil},
+	}
+	for _, tt := range tests {
+		gcflags, asmflags := targetToolFlags(buildCfg, tt.target)
+		if !slices.Equal(gcflags, tt.gcflags) || !slices.Equal(asmflags, tt.asmflags) {
+			t.Errorf("targetToolFlags(%s) = %v, %v; want %v, %v", tt.target, gcflags, asmflags, tt.gcflags, tt.asmflags)
+		}
+	}
+}
+
+func TestTargetBuildmode(t *testing.T) {
+	buildCfg := config.BuildConfig{
+		Buildmode: "pie",
+		Overrides: []config.OverrideConfig{
+			{TargetMatch: config.TargetMatch{Goos: "linux"}, Buildmode: "c-shared"},
+			{TargetMatch: config.TargetMatch{Goos: "linux", Goarch: "arm64"}, Flags: []string{"-trimpath"}},
+		},
+	}
+	tests := []struct {
+		target Target
+		want   string
+	}{
+		{Target{Goos: "linux", Goarch: "amd64"}, "c-shared"},
+		{Target{Goos: "linux", Goarch: "arm64"}, "c-shared"},
+		{Target{Goos: "darwin", Goarch: "arm64"}, "pie"},
+	}
+	for _, tt := range tests {
+		if got := targetBuildmode(buildCfg, tt.target); got != tt.want {
+			t.Errorf("targetBuildmode(%s) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+// TestRunOverrideLdflags checks that override ldflags are rendered like
+// the build's, including {{.Env}} variables only they reference.
+func TestRunOverrideLdflags(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nimport \"fmt\"\n\nvar version, flavor string\n\nfunc main() { fmt.Print(version + \"|\" + flavor) }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GCX_TEST_FLAVOR", "lite edition")
+
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: filepath.Join(t.TempDir(), "dist"),
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app",
+			Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH},
+			Ldflags: []string{"-X main.version={{.Version}}"},
+			Overrides: []config.OverrideConfig{{
+				TargetMatch: config.TargetMatch{Goos: runtime.GOOS},
+				Ldflags:     []string{`-X "main.flavor={{.Env.GCX_TEST_FLAVOR}}"`},
+			}},
+		}},
+	}
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	a := artifacts[0]
+	out, err := exec.Command(filepath.Join(a.DirPath, a.FileName())).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a.Version + "|lite edition"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
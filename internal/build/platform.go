@@ -0,0 +1,128 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/toolchain"
+)
+
+// wasmExecName is the JavaScript support file js/wasm modules are loaded with.
+const wasmExecName = "wasm_exec.js"
+
+// platform describes how go build output for a GOOS is named and treated.
+type platform struct {
+	// Ext is appended to the binary name, e.g. ".exe".
+	Ext string
+	// Executable is false for outputs that are loaded by a host runtime
+	// rather than run directly, such as WebAssembly modules.
+	Executable bool
+}
+
+// platforms lists the GOOS values that differ from the default: a native
+// executable without an extension (linux, darwin, aix, plan9, ...).
+var platforms = map[string]platform{
+	"windows": {Ext: ".exe", Executable: true},
+	"js":      {Ext: ".wasm"},
+	"wasip1":  {Ext: ".wasm"},
+}
+
+// platformFor returns the conventions for goos, with the extension
+// overridden by the build's extensions map when present. Libraries built
+// with a buildmode such as c-shared take the extension of their kind.
+func platformFor(buildCfg config.BuildConfig, goos, buildmode string) platform {
+	if ext, ok := libraryExt(goos, buildmode); ok {
+		return platform{Ext: ext}
+	}
+	p, ok := platforms[goos]
+	if !ok {
+		p = platform{Executable: true}
+	}
+	if ext, ok := buildCfg.Extensions[goos]; ok {
+		p.Ext = ext
+	}
+	return p
+}
+
+// libraryExt returns the extension of the library go build writes for
+// buildmode on goos, or false for modes that produce an executable.
+func libraryExt(goos, buildmode string) (string, bool) {
+	switch buildmode {
+	case "c-shared":
+		switch goos {
+		case "windows":
+			return ".dll", true
+		case "darwin", "ios":
+			return ".dylib", true
+		}
+		return ".so", true
+	case "c-archive", "archive":
+		return ".a", true
+	case "plugin", "shared":
+		return ".so", true
+	}
+	return "", false
+}
+
+// hasHeader reports whether buildmode writes a C header next to the
+// library, named after it with a .h extension.
+func hasHeader(buildmode string) bool {
+	return buildmode == "c-shared" || buildmode == "c-archive"
+}
+
+// wasmSkipReason explains why goos/goarch is not a valid WebAssembly pair,
+// or returns "" when it is valid or unrelated to WebAssembly.
+func wasmSkipReason(goos, goarch string) string {
+	wasmOS := goos == "js" || goos == "wasip1"
+	switch {
+	case goarch == "wasm" && !wasmOS:
+		return "wasm is only supported by js and wasip1"
+	case wasmOS && goarch != "wasm":
+		return goos + " only supports wasm"
+	}
+	return ""
+}
+
+// wasmExecSource locates wasm_exec.js in the Go distribution goBinary uses
+// in dir. Go 1.24 moved it from misc/wasm to lib/wasm.
+func wasmExecSource(ctx context.Context, goBinary, dir string) (string, error) {
+	goroot, err := toolchain.GOROOT(ctx, goBinary, dir)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range []string{"lib", "misc"} {
+		path := filepath.Join(goroot, dir, "wasm", wasmExecName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %s", wasmExecName, goroot)
+}
+
+// copyFile copies src to dst with mode 0644.
+func copyFile(src, dst string) (retErr error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return nil
+}
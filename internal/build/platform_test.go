@@ -0,0 +1,131 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestPlatformFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions map[string]string
+		goos       string
+		buildmode  string
+		wantExt    string
+		wantExec   bool
+	}{
+		{name: "linux", goos: "linux", wantExt: "", wantExec: true},
+		{name: "aix", goos: "aix", wantExt: "", wantExec: true},
+		{name: "windows", goos: "windows", wantExt: ".exe", wantExec: true},
+		{name: "js", goos: "js", wantExt: ".wasm", wantExec: false},
+		{name: "wasip1", goos: "wasip1", wantExt: ".wasm", wantExec: false},
+		{name: "override", extensions: map[string]string{"linux": ".bin"}, goos: "linux", wantExt: ".bin", wantExec: true},
+		{name: "override removes extension", extensions: map[string]string{"windows": ""}, goos: "windows", wantExt: "", wantExec: true},
+		{name: "pie", goos: "linux", buildmode: "pie", wantExt: "", wantExec: true},
+		{name: "c-shared linux", goos: "linux", buildmode: "c-shared", wantExt: ".so", wantExec: false},
+		{name: "c-shared darwin", goos: "darwin", buildmode: "c-shared", wantExt: ".dylib", wantExec: false},
+		{name: "c-shared windows", goos: "windows", buildmode: "c-shared", wantExt: ".dll", wantExec: false},
+		{name: "c-archive", goos: "linux", buildmode: "c-archive", wantExt: ".a", wantExec: false},
+		{name: "c-shared ignores extensions", extensions: map[string]string{"linux": ".bin"}, goos: "linux", buildmode: "c-shared", wantExt: ".so", wantExec: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := platformFor(config.BuildConfig{Extensions: tt.extensions}, tt.goos, tt.buildmode)
+			if p.Ext != tt.wantExt || p.Executable != tt.wantExec {
+				t.Errorf("platformFor(%s, %q) = %+v, want ext %q executable %v", tt.goos, tt.buildmode, p, tt.wantExt, tt.wantExec)
+			}
+		})
+	}
+}
+
+func TestArchiveBaseNameExt(t *testing.T) {
+	artifact := Artifact{BinaryName: "app", Version: "v1.0.0", OS: "js", Arch: "wasm", Ext: ".wasm"}
+	cfg := &config.Config{Archives: []config.ArchiveConfig{{NameTemplate: "{{.Binary}}{{.Ext}}_{{.Version}}"}}}
+	got, err := archiveBaseName(cfg, 0, artifact, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "app.wasm_v1.0.0" {
+		t.Errorf("archiveBaseName() = %q", got)
+	}
+	if artifact.FileName() != "app.wasm" {
+		t.Errorf("FileName() = %q", artifact.FileName())
+	}
+}
+
+// TestRunWasm builds both WebAssembly runtimes through to their archives,
+// which name_template tells apart by {{.Os}}.
+func TestRunWasm(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() { println(\"hello\") }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app",
+			Goos: []string{"js", "wasip1"}, Goarch: []string{"wasm"},
+			IncludeWasmExec: true,
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+	}
+	if _, err := Run(context.Background(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := map[string][]string{
+		"app_js_wasm.tar.gz":     {"app.wasm", wasmExecName},
+		"app_wasip1_wasm.tar.gz": {"app.wasm"},
+	}
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range m.Artifacts {
+		files, ok := want[a.Name]
+		if !ok || a.Type != manifest.TypeArchive {
+			t.Errorf("unexpected artifact %s (%s)", a.Name, a.Type)
+			continue
+		}
+		delete(want, a.Name)
+		var got []string
+		for _, e := range a.Contents {
+			if strings.HasSuffix(e.Path, "/") {
+				continue
+			}
+			got = append(got, path.Base(e.Path))
+			// Modules are loaded by a host runtime, not executed
+			if path.Base(e.Path) == "app.wasm" && e.Mode != "0644" {
+				t.Errorf("%s: %s mode = %s, want 0644", a.Name, e.Path, e.Mode)
+			}
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, files) {
+			t.Errorf("%s contents = %v, want %v", a.Name, got, files)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing archives: %v", want)
+	}
+}
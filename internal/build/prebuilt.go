@@ -0,0 +1,54 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// prebuiltPath renders the prebuilt path_template of buildCfg for artifact.
+func prebuiltPath(cfg *config.Config, buildCfg config.BuildConfig, artifact Artifact) (string, error) {
+	data := templateData(cfg, artifact)
+	data.Binary = artifact.BinaryName
+	path, err := tmpl.Process("path_template", buildCfg.Prebuilt.PathTemplate, data)
+	if err != nil {
+		return "", fmt.Errorf("process prebuilt path template: %w", err)
+	}
+	return path, nil
+}
+
+// copyPrebuilt stages the prebuilt binary src at dirPath/fileName by
+// strategy, keeping it executable unless the platform output is not.
+func copyPrebuilt(stage *stager, strategy, src, dirPath, fileName string, executable bool) error {
+	info, err := os.Stat(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s does not exist", src)
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", src)
+	}
+
+	if err := os.MkdirAll(dirPath, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	// A hard link shares its mode with src, which must stay as the user left it
+	chmod := executable && info.Mode().Perm() != 0o755
+	if chmod && strategy == config.StagingHardlink {
+		strategy = config.StagingCopy
+	}
+	dst := filepath.Join(dirPath, fileName)
+	if err := stage.stage(src, dst, strategy); err != nil {
+		return err
+	}
+	if chmod {
+		return os.Chmod(dst, 0o755)
+	}
+	return nil
+}
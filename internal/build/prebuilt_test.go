@@ -0,0 +1,96 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestRunPrebuiltGroup(t *testing.T) {
+	binDir := t.TempDir()
+	for _, name := range []string{"app_linux_amd64", "sidecar_linux_amd64"} {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{
+			{
+				OutputName: "app", Group: "bundle", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+				Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+			},
+			{
+				OutputName: "sidecar", Group: "bundle", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+				Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "sidecar_{{.Os}}_{{.Arch}}")},
+			},
+		},
+		Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+	}
+	if _, err := Run(context.Background(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Artifacts) != 1 || m.Artifacts[0].Name != "bundle_linux_amd64.tar.gz" {
+		t.Fatalf("manifest artifacts = %+v, want one bundle archive", m.Artifacts)
+	}
+
+	f, err := os.Open(m.Artifacts[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, header.Name)
+			if header.FileInfo().Mode()&0o111 == 0 {
+				t.Errorf("%s is not executable", header.Name)
+			}
+		}
+	}
+	slices.Sort(files)
+	// The directory name includes the current git tag
+	if len(files) != 2 || !strings.HasPrefix(files[0], "bundle_") ||
+		!strings.HasSuffix(files[0], "_linux_amd64/app") || !strings.HasSuffix(files[1], "_linux_amd64/sidecar") {
+		t.Errorf("archive files = %v, want app and sidecar in one bundle directory", files)
+	}
+}
+
+func TestRunPrebuiltMissing(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "sidecar_linux_arm64")
+	cfg := &config.Config{
+		OutDir: filepath.Join(t.TempDir(), "dist"),
+		Builds: []config.BuildConfig{{
+			OutputName: "sidecar", Goos: []string{"linux"}, Goarch: []string{"arm64"},
+			Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(filepath.Dir(missing), "sidecar_{{.Os}}_{{.Arch}}")},
+		}},
+	}
+	_, err := Run(context.Background(), cfg, Options{})
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Run() error = %v, want the missing path %s", err, missing)
+	}
+}
@@ -0,0 +1,125 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+	"golang.org/x/sync/errgroup"
+)
+
+// SBOMTemplateData is the template context of sboms args.
+type SBOMTemplateData struct {
+	// Artifact is the absolute path of the archive or binary.
+	Artifact string
+	// ArtifactName is the file name of the artifact.
+	ArtifactName string
+	// Document is the absolute path the SBOM must be written to.
+	Document string
+	// Format is the document format: cyclonedx or spdx.
+	Format  string
+	Version string
+	Channel string
+	Os      string
+	Arch    string
+}
+
+// sbomAvailable returns an error when the tool of sbomCfg cannot be found.
+func sbomAvailable(sbomCfg config.SBOMConfig) error {
+	cmd, _ := sbomCfg.Command()
+	if _, err := exec.LookPath(cmd); err != nil {
+		return fmt.Errorf("sboms require %s: %w", cmd, err)
+	}
+	return nil
+}
+
+// sbomTargets returns the entries that get an SBOM: the archives, and the
+// binaries of artifacts that were not archived.
+func sbomTargets(entries []manifest.Artifact) []manifest.Artifact {
+	var targets []manifest.Artifact
+	for _, e := range entries {
+		if e.Type == manifest.TypeArchive || e.Type == manifest.TypeBinary {
+			targets = append(targets, e)
+		}
+	}
+	return targets
+}
+
+// generateSBOMs runs the sboms tool for every archive or binary among
+// entries, in parallel, writing <artifact>.sbom.json next to it. It returns
+// the manifest entries of the documents.
+func generateSBOMs(ctx context.Context, cfg *config.Config, rel config.ReleaseData, entries []manifest.Artifact) ([]manifest.Artifact, error) {
+	if len(cfg.SBOMs) == 0 {
+		return nil, nil
+	}
+	sbomCfg := cfg.SBOMs[0]
+	name, argTemplates := sbomCfg.Command()
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(concurrency)
+
+	targets := sbomTargets(entries)
+	documents := make([]manifest.Artifact, len(targets))
+	for i, target := range targets {
+		artifactPath, err := filepath.Abs(target.Path)
+		if err != nil {
+			return nil, err
+		}
+		data := SBOMTemplateData{
+			Artifact:     artifactPath,
+			ArtifactName: target.Name,
+			Document:     artifactPath + manifest.SBOMSuffix,
+			Format:       sbomCfg.DocumentFormat(),
+			Version:      rel.Version,
+			Channel:      rel.Channel,
+			Os:           target.Goos,
+			Arch:         target.Goarch,
+		}
+		args := make([]string, len(argTemplates))
+		for j, arg := range argTemplates {
+			if args[j], err = tmpl.Process("sbom_arg", arg, data); err != nil {
+				return nil, fmt.Errorf("sboms[0]: process arg %q: %w", arg, err)
+			}
+		}
+
+		documents[i] = manifest.Artifact{
+			Name:         target.Name + manifest.SBOMSuffix,
+			Path:         target.Path + manifest.SBOMSuffix,
+			Type:         manifest.TypeSBOM,
+			Goos:         target.Goos,
+			Goarch:       target.Goarch,
+			Goarm:        target.Goarm,
+			Variant:      target.Variant,
+			Builds:       target.Builds,
+			Instrumented: target.Instrumented,
+		}
+		eg.Go(func() error {
+			log.Printf("Generating %s SBOM for %s", data.Format, target.Name)
+			cmd := exec.CommandContext(ctx, name, args...)
+			cmd.Dir = cfg.Dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("sbom for %s: %s: %w: %s", target.Name, name, err, strings.TrimSpace(string(out)))
+			}
+			if _, err := os.Stat(data.Document); err != nil {
+				return fmt.Errorf("sbom for %s: %s did not write %s", target.Name, name, data.Document)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
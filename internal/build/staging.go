@@ -0,0 +1,109 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/helpers"
+)
+
+// link and rename are replaced by tests to simulate other filesystems.
+var (
+	link   = os.Link
+	rename = os.Rename
+)
+
+// stager places files into out_dir by the staging_strategy of the config:
+// prebuilt binaries and the bare binaries of the binary archive format.
+// It counts the bytes it placed and those it had to copy for the summary.
+type stager struct {
+	strategy string
+
+	mu     sync.Mutex
+	files  int
+	size   int64
+	copied int64
+	// warned is set once a failed hard link has been logged
+	warned bool
+}
+
+func newStager(cfg *config.Config) *stager {
+	return &stager{strategy: cfg.Staging()}
+}
+
+// stage places src at dst by strategy, replacing dst. A hard link falls
+// back to a copy when src and dst cannot share one, e.g. on different
+// filesystems, and a move to a copy and removal across filesystems.
+func (s *stager) stage(src, dst, strategy string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	copied := false
+	switch strategy {
+	case config.StagingHardlink:
+		if err := link(src, dst); err != nil {
+			s.warn(dst, err)
+			if err := copyFile(src, dst); err != nil {
+				return err
+			}
+			copied = true
+		}
+	case config.StagingMove:
+		err := rename(src, dst)
+		if errors.Is(err, syscall.EXDEV) {
+			if err = copyFile(src, dst); err == nil {
+				err = os.Remove(src)
+			}
+			copied = true
+		}
+		if err != nil {
+			return fmt.Errorf("move %s: %w", src, err)
+		}
+	default:
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		copied = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files++
+	s.size += info.Size()
+	if copied {
+		s.copied += info.Size()
+	}
+	return nil
+}
+
+// warn logs the first hard link that failed, as the others into the same
+// out_dir usually fail for the same reason.
+func (s *stager) warn(dst string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.warned {
+		s.warned = true
+		log.Printf("Warning: cannot hard link into %s, copying instead: %v", filepath.Dir(dst), err)
+	}
+}
+
+// summary describes the disk out_dir gained by staging against copying
+// every file, or is empty when nothing was staged.
+func (s *stager) summary() string {
+	if s.files == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Staged %d file(s) by %s: disk usage %s instead of %s with copies",
+		s.files, s.strategy, helpers.FormatBytes(s.copied), helpers.FormatBytes(s.size))
+}
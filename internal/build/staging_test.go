@@ -0,0 +1,163 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// crossDevice makes hard links and renames fail as they do between two
+// filesystems until the test ends.
+func crossDevice(t *testing.T) {
+	t.Helper()
+	link = func(oldname, newname string) error {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EXDEV}
+	}
+	rename = func(oldname, newname string) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { link, rename = os.Link, os.Rename })
+}
+
+func TestStagerStage(t *testing.T) {
+	tests := []struct {
+		strategy    string
+		crossDevice bool
+		// linked: dst is src; kept: src still exists; copied: bytes copied
+		linked, kept bool
+		copied       int64
+	}{
+		{strategy: config.StagingCopy, kept: true, copied: 3},
+		{strategy: config.StagingHardlink, linked: true, kept: true},
+		{strategy: config.StagingMove},
+		{strategy: config.StagingHardlink, crossDevice: true, kept: true, copied: 3},
+		{strategy: config.StagingMove, crossDevice: true, copied: 3},
+	}
+	for _, tt := range tests {
+		name := tt.strategy
+		if tt.crossDevice {
+			name += " across filesystems"
+		}
+		t.Run(name, func(t *testing.T) {
+			if tt.crossDevice {
+				crossDevice(t)
+			}
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+			if err := os.WriteFile(src, []byte("app"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			// A stale dst is replaced, not written through
+			if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			stage := newStager(&config.Config{StagingStrategy: tt.strategy})
+			if err := stage.stage(src, dst, tt.strategy); err != nil {
+				t.Fatalf("stage() error = %v", err)
+			}
+
+			if data, err := os.ReadFile(dst); err != nil || string(data) != "app" {
+				t.Fatalf("dst = %q, %v, want app", data, err)
+			}
+			srcInfo, err := os.Stat(src)
+			if kept := err == nil; kept != tt.kept {
+				t.Fatalf("src kept = %v, want %v", kept, tt.kept)
+			}
+			if tt.kept {
+				dstInfo, err := os.Stat(dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if linked := os.SameFile(srcInfo, dstInfo); linked != tt.linked {
+					t.Errorf("dst linked to src = %v, want %v", linked, tt.linked)
+				}
+			}
+			if stage.files != 1 || stage.size != 3 || stage.copied != tt.copied {
+				t.Errorf("stats = %d files, %d bytes, %d copied; want 1, 3, %d", stage.files, stage.size, stage.copied, tt.copied)
+			}
+			summary := stage.summary()
+			if !strings.Contains(summary, "by "+tt.strategy) || !strings.Contains(summary, "instead of 3 B") {
+				t.Errorf("summary() = %q", summary)
+			}
+		})
+	}
+}
+
+func TestRunStagingPrebuilt(t *testing.T) {
+	binDir := t.TempDir()
+	src := filepath.Join(binDir, "app_linux_amd64")
+	if err := os.WriteFile(src, []byte("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			ID: "app", OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+			Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"binary"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+	}
+	if _, err := Run(t.Context(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The binary-format copy of the staged prebuilt binary is src itself
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(outDir, "app_linux_amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(srcInfo, info) {
+		t.Error("the binary in out_dir is not hard linked to the prebuilt binary")
+	}
+}
+
+// TestRunStagingPrebuiltMode checks that a prebuilt binary without the
+// executable bits is copied rather than linked, so that making the staged
+// binary executable leaves src alone.
+func TestRunStagingPrebuiltMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on windows")
+	}
+	binDir := t.TempDir()
+	src := filepath.Join(binDir, "app_linux_amd64")
+	if err := os.WriteFile(src, []byte("app"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "dist")
+	cfg := &config.Config{
+		OutDir: outDir,
+		Builds: []config.BuildConfig{{
+			ID: "app", OutputName: "app", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+			Prebuilt: &config.PrebuiltConfig{PathTemplate: filepath.Join(binDir, "{{.Binary}}_{{.Os}}_{{.Arch}}")},
+		}},
+		Archives: []config.ArchiveConfig{{Formats: []string{"binary"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"}},
+	}
+	if _, err := Run(t.Context(), cfg, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srcInfo.Mode().Perm() != 0o644 {
+		t.Errorf("prebuilt binary mode = %v, want it unchanged", srcInfo.Mode().Perm())
+	}
+	info, err := os.Stat(filepath.Join(outDir, "app_linux_amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(srcInfo, info) || info.Mode().Perm() != 0o755 {
+		t.Errorf("staged binary mode = %v, want an executable copy", info.Mode().Perm())
+	}
+}
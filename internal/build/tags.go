@@ -0,0 +1,42 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// renderTags renders the tags templates of a build into the value of its
+// -tags argument. Entries may render to several comma-separated tags;
+// empty ones are dropped.
+func renderTags(tags []string, data any) (string, error) {
+	var rendered []string
+	for i, t := range tags {
+		out, err := tmpl.Process("tag", t, data)
+		if err != nil {
+			return "", fmt.Errorf("tags[%d]: %w", i, err)
+		}
+		for tag := range strings.SplitSeq(out, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			if !validTag(tag) {
+				return "", fmt.Errorf("tags[%d]: %q is not a build tag (letters, digits, _ and . only)", i, tag)
+			}
+			rendered = append(rendered, tag)
+		}
+	}
+	return strings.Join(rendered, ","), nil
+}
+
+// validTag reports whether tag may appear in a build constraint.
+func validTag(tag string) bool {
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
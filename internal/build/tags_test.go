@@ -0,0 +1,34 @@
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderTags(t *testing.T) {
+	data := map[string]any{"Env": map[string]string{"EDITION": "enterprise", "EXTRA": "netgo, osusergo", "EMPTY": ""}}
+	tests := []struct {
+		tags    []string
+		want    string
+		wantErr string
+	}{
+		{nil, "", ""},
+		{[]string{"sqlite_static", "{{.Env.EDITION}}"}, "sqlite_static,enterprise", ""},
+		{[]string{"{{.Env.EXTRA}}", "go1.21"}, "netgo,osusergo,go1.21", ""},
+		{[]string{"{{.Env.EMPTY}}", "netgo"}, "netgo", ""},
+		{[]string{"netgo", "two words"}, "", `tags[1]: "two words" is not a build tag`},
+		{[]string{"{{.Env.EDITION"}, "", "tags[0]:"},
+	}
+	for _, tt := range tests {
+		got, err := renderTags(tt.tags, data)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("renderTags(%q) error = %v, want %q", tt.tags, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("renderTags(%q) = %q, %v; want %q", tt.tags, got, err, tt.want)
+		}
+	}
+}
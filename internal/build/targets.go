@@ -0,0 +1,273 @@
+package build
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/toolchain"
+)
+
+// Target is one resolved combination of the build matrix. Targets with a
+// non-empty SkipReason are reported but not built, and those with a
+// non-empty InvalidReason fail the build.
+type Target struct {
+	Build  string `json:"build"`
+	Goos   string `json:"goos"`
+	Goarch string `json:"goarch"`
+	Goarm  string `json:"goarm,omitempty"`
+	// Variant is the GOAMD64, GOARM64, GOMIPS or GORISCV64 value of
+	// targets of those goarch values, e.g. "v3".
+	Variant    string `json:"variant,omitempty"`
+	SkipReason string `json:"skip_reason,omitempty"`
+	// InvalidReason is set by CheckedTargets on targets the toolchain of
+	// the build cannot compile.
+	InvalidReason string `json:"invalid_reason,omitempty"`
+}
+
+// Skipped reports whether the target is excluded from the build.
+func (t Target) Skipped() bool {
+	return t.SkipReason != ""
+}
+
+// String returns the target as goos/goarch[/armN or /variant].
+func (t Target) String() string {
+	switch {
+	case t.Goarm != "":
+		return fmt.Sprintf("%s/%s/arm%s", t.Goos, t.Goarch, t.Goarm)
+	case t.Variant != "":
+		return t.Goos + "/" + t.Goarch + "/" + t.Variant
+	}
+	return t.Goos + "/" + t.Goarch
+}
+
+// variant returns the sub-architecture variant of t: GOARM of arm
+// targets, else Variant.
+func (t Target) variant() string {
+	return cmp.Or(t.Goarm, t.Variant)
+}
+
+// hostPlatform returns the platform single-target builds produce: GOOS and
+// GOARCH from the environment, or the platform gcx runs on.
+func hostPlatform() (goos, goarch string) {
+	goos, goarch = envvars.Get("GOOS"), envvars.Get("GOARCH")
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	return goos, goarch
+}
+
+// forHost keeps the targets built for hostPlatform().
+func forHost(targets []Target) []Target {
+	goos, goarch := hostPlatform()
+	var host []Target
+	for _, t := range targets {
+		if t.Goos == goos && t.Goarch == goarch {
+			host = append(host, t)
+		}
+	}
+	return host
+}
+
+// buildTargets returns the targets of buildCfg that are built: the
+// resolved matrix without skipped targets, only the host's with
+// singleTarget.
+func buildTargets(buildCfg config.BuildConfig, singleTarget bool) []Target {
+	var targets []Target
+	for _, t := range ResolveTargets(buildCfg) {
+		if !t.Skipped() {
+			targets = append(targets, t)
+		}
+	}
+	if singleTarget {
+		targets = forHost(targets)
+	}
+	return targets
+}
+
+// binaryName returns the base name of the binary produced by buildCfg.
+func binaryName(buildCfg config.BuildConfig) string {
+	if buildCfg.OutputName != "" {
+		return buildCfg.OutputName
+	}
+	parts := strings.Split(buildCfg.Main, "/")
+	return parts[len(parts)-1]
+}
+
+// ResolveTargets expands the goos × goarch × variant matrix of buildCfg,
+// where the variants are goarm, goamd64, goarm64, gomips or goriscv64 by
+// goarch, or returns its targets entries when set. It is the single source
+// of truth for which targets Run compiles.
+func ResolveTargets(buildCfg config.BuildConfig) []Target {
+	name := binaryName(buildCfg)
+
+	var targets []Target
+	add := func(t Target) {
+		t.Build = name
+		if t.SkipReason == "" {
+			t.SkipReason = ignoreReason(buildCfg, t)
+		}
+		targets = append(targets, t)
+	}
+	if len(buildCfg.Targets) > 0 {
+		// Listed targets are wanted as they are; only an explicit ignore
+		// list skips them, not the linux-only default for arm
+		if buildCfg.Ignore == nil {
+			buildCfg.Ignore = []config.TargetMatch{}
+		}
+		for _, p := range buildCfg.Platforms() {
+			t := Target{Goos: p.Goos, Goarch: p.Goarch, Variant: p.Variant}
+			if p.Goarch == "arm" {
+				t.Goarm, t.Variant = p.Variant, ""
+			}
+			t.SkipReason = wasmSkipReason(p.Goos, p.Goarch)
+			add(t)
+		}
+		return targets
+	}
+	for _, goos := range buildCfg.Goos {
+		for _, goarch := range buildCfg.Goarch {
+			if reason := wasmSkipReason(goos, goarch); reason != "" {
+				add(Target{Goos: goos, Goarch: goarch, SkipReason: reason})
+				continue
+			}
+			// A rule without goarm skips goos/arm before variant expansion
+			if reason := ignoreReason(buildCfg, Target{Goos: goos, Goarch: goarch}); reason != "" {
+				add(Target{Goos: goos, Goarch: goarch, SkipReason: reason})
+				continue
+			}
+			if _, variants := buildCfg.Variants(goarch); len(variants) > 0 {
+				for _, variant := range variants {
+					t := Target{Goos: goos, Goarch: goarch, Variant: variant}
+					if goarch == "arm" {
+						t.Goarm, t.Variant = variant, ""
+					}
+					add(t)
+				}
+				continue
+			}
+			add(Target{Goos: goos, Goarch: goarch})
+		}
+	}
+	return targets
+}
+
+// ignoreReason returns why t matches the ignore list of buildCfg, or "".
+// Builds without an ignore list only build arm for linux.
+func ignoreReason(buildCfg config.BuildConfig, t Target) string {
+	if buildCfg.Ignore == nil {
+		if t.Goarch == "arm" && t.Goos != "linux" {
+			return "arm builds are only produced for linux (set ignore to change)"
+		}
+		return ""
+	}
+	for i, ignore := range buildCfg.Ignore {
+		if ignore.Matches(t.Goos, t.Goarch, t.Goarm) {
+			return fmt.Sprintf("matches ignore[%d]", i)
+		}
+	}
+	return ""
+}
+
+// CheckedTargets resolves the targets of every build in cfg and marks those go tool dist list does not know as
+// invalid, exactly as CheckTargets rejects them before a build.
+func CheckedTargets(ctx context.Context, cfg *config.Config) []Target {
+	var targets []Target
+	for _, buildCfg := range cfg.Builds {
+		resolved := ResolveTargets(buildCfg)
+		markUnknownTargets(ctx, cfg, buildCfg, resolved)
+		targets = append(targets, resolved...)
+	}
+	return targets
+}
+
+// CheckTargets reports every goos/goarch pair the builds of cfg compile
+// that go tool dist list does not know, e.g. the typo linux/amd46, so the
+// matrix fails before anything is built rather than after other targets.
+func CheckTargets(ctx context.Context, cfg *config.Config, singleTarget bool) error {
+	var unknown []string
+	for i, buildCfg := range cfg.Builds {
+		targets := buildTargets(buildCfg, singleTarget)
+		markUnknownTargets(ctx, cfg, buildCfg, targets)
+		var seen []string
+		for _, t := range targets {
+			pair := t.Goos + "/" + t.Goarch
+			if t.InvalidReason == "" || slices.Contains(seen, pair) {
+				continue
+			}
+			seen = append(seen, pair)
+			unknown = append(unknown, fmt.Sprintf("builds[%d] %s: goos %q, goarch %q", i, t.Build, t.Goos, t.Goarch))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%d target(s) not in go tool dist list (set allow_unknown_targets on a build for an experimental toolchain):\n  %s",
+			len(unknown), strings.Join(unknown, "\n  "))
+	}
+	return nil
+}
+
+// markUnknownTargets sets InvalidReason on the targets of buildCfg that are
+// not skipped and not in the go tool dist list of its gobinary. Prebuilt
+// builds and builds with allow_unknown_targets are not checked, and a build
+// is only warned about when its gobinary cannot list its platforms.
+func markUnknownTargets(ctx context.Context, cfg *config.Config, buildCfg config.BuildConfig, targets []Target) {
+	if buildCfg.AllowUnknownTargets || buildCfg.Prebuilt != nil {
+		return
+	}
+	name := binaryName(buildCfg)
+	dir := buildDir(cfg, buildCfg)
+	goBinary, err := lookGoBinary(dir, buildCfg)
+	if err != nil {
+		log.Printf("Warning: not checking the targets of build %s: %v", name, err)
+		return
+	}
+	known, err := toolchain.DistList(ctx, goBinary, dir)
+	if err != nil {
+		log.Printf("Warning: not checking the targets of build %s: %v", name, err)
+		return
+	}
+	for i, t := range targets {
+		if !t.Skipped() && !slices.Contains(known, t.Goos+"/"+t.Goarch) {
+			targets[i].InvalidReason = "not in go tool dist list"
+		}
+	}
+}
+
+// WriteTargetsTable prints targets as an aligned table, one line per target.
+func WriteTargetsTable(w io.Writer, targets []Target) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BUILD\tGOOS\tGOARCH\tVARIANT\tSTATUS")
+	for _, t := range targets {
+		status := "build"
+		switch {
+		case t.Skipped():
+			status = "skip: " + t.SkipReason
+		case t.InvalidReason != "":
+			status = "invalid: " + t.InvalidReason
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.Build, t.Goos, t.Goarch, cmp.Or(t.variant(), "-"), status)
+	}
+	return tw.Flush()
+}
+
+// WriteTargetsJSON prints targets as indented JSON.
+func WriteTargetsJSON(w io.Writer, targets []Target) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(targets); err != nil {
+		return fmt.Errorf("encode targets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestResolveTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.BuildConfig
+		want    []string
+		skipped []string
+	}{
+		{
+			name: "matrix",
+			cfg:  config.BuildConfig{Main: "./cmd/app", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64", "arm64"}},
+			want: []string{"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64"},
+		},
+		{
+			name:    "goarm expands on linux only",
+			cfg:     config.BuildConfig{Main: "./cmd/app", Goos: []string{"linux", "darwin"}, Goarch: []string{"arm"}, Goarm: []string{"6", "7"}},
+			want:    []string{"linux/arm/arm6", "linux/arm/arm7"},
+			skipped: []string{"darwin/arm"},
+		},
+		{
+			name:    "wasm pairs",
+			cfg:     config.BuildConfig{Main: "./cmd/app", Goos: []string{"linux", "js", "wasip1"}, Goarch: []string{"amd64", "wasm"}},
+			want:    []string{"linux/amd64", "js/wasm", "wasip1/wasm"},
+			skipped: []string{"linux/wasm", "js/amd64", "wasip1/amd64"},
+		},
+		{
+			name: "sub-architecture variants",
+			cfg: config.BuildConfig{
+				Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64", "mipsle", "arm64"},
+				Goamd64: []string{"v1", "v3"}, Gomips: []string{"softfloat"},
+			},
+			want: []string{"linux/amd64/v1", "linux/amd64/v3", "linux/mipsle/softfloat", "linux/arm64"},
+		},
+		{
+			name: "arm without goarm",
+			cfg:  config.BuildConfig{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"arm"}},
+			want: []string{"linux/arm"},
+		},
+		{
+			name: "ignore list",
+			cfg: config.BuildConfig{
+				Main: "./cmd/app", Goos: []string{"linux", "windows", "darwin"}, Goarch: []string{"amd64", "arm64", "386"},
+				Ignore: []config.TargetMatch{{Goos: "darwin", Goarch: "386"}, {Goarch: "386", Goos: "windows"}, {Goos: "windows", Goarch: "arm64"}},
+			},
+			want:    []string{"linux/amd64", "linux/arm64", "linux/386", "windows/amd64", "darwin/amd64", "darwin/arm64"},
+			skipped: []string{"windows/arm64", "windows/386", "darwin/386"},
+		},
+		{
+			name: "ignore replaces the linux-only arm rule",
+			cfg: config.BuildConfig{
+				Main: "./cmd/app", Goos: []string{"linux", "freebsd", "windows"}, Goarch: []string{"arm"}, Goarm: []string{"6", "7"},
+				Ignore: []config.TargetMatch{{Goos: "windows"}, {Goarm: "6", Goos: "freebsd"}},
+			},
+			want:    []string{"linux/arm/arm6", "linux/arm/arm7", "freebsd/arm/arm7"},
+			skipped: []string{"freebsd/arm/arm6", "windows/arm"},
+		},
+		{
+			name: "targets shorthand",
+			cfg: config.BuildConfig{
+				Main: "./cmd/app", Targets: []string{"linux/amd64", "linux/arm/v7", "darwin/arm/v6", "linux/amd64/v3", "js/wasm"},
+			},
+			want: []string{"linux/amd64", "linux/arm/arm7", "darwin/arm/arm6", "linux/amd64/v3", "js/wasm"},
+		},
+		{
+			name: "targets with ignore",
+			cfg: config.BuildConfig{
+				Main: "./cmd/app", Targets: []string{"linux/amd64", "windows/arm64"},
+				Ignore: []config.TargetMatch{{Goos: "windows"}},
+			},
+			want:    []string{"linux/amd64"},
+			skipped: []string{"windows/arm64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var built, skipped []string
+			for _, target := range ResolveTargets(tt.cfg) {
+				if target.Build != "app" {
+					t.Errorf("Build = %q, want app", target.Build)
+				}
+				if target.Skipped() {
+					skipped = append(skipped, target.String())
+				} else {
+					built = append(built, target.String())
+				}
+			}
+			if strings.Join(built, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("built = %v, want %v", built, tt.want)
+			}
+			if strings.Join(skipped, ",") != strings.Join(tt.skipped, ",") {
+				t.Errorf("skipped = %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestWriteTargets(t *testing.T) {
+	targets := []Target{
+		{Build: "app", Goos: "linux", Goarch: "arm", Goarm: "7"},
+		{Build: "app", Goos: "darwin", Goarch: "arm", SkipReason: "arm builds are only produced for linux"},
+	}
+
+	var table bytes.Buffer
+	if err := WriteTargetsTable(&table, targets); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], "build") || !strings.Contains(lines[2], "skip: arm builds") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := WriteTargetsJSON(&out, targets); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Target
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1] != targets[1] {
+		t.Errorf("unexpected JSON: %s", out.String())
+	}
+}
+
+// TestRunVariants builds two GOAMD64 variants into their own directories.
+func TestRunVariants(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{
+		Dir:    dir,
+		OutDir: filepath.Join(t.TempDir(), "dist"),
+		Builds: []config.BuildConfig{{
+			Main: ".", OutputName: "app",
+			Goos: []string{"linux"}, Goarch: []string{"amd64"}, Goamd64: []string{"v1", "v3"},
+		}},
+	}
+	artifacts, err := Run(context.Background(), cfg, Options{SkipArchives: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("built %d artifacts, want 2", len(artifacts))
+	}
+	for _, a := range artifacts {
+		if !strings.HasSuffix(a.DirPath, "_linux_amd64_"+a.Variant) {
+			t.Errorf("output directory %s does not end with the variant %s", a.DirPath, a.Variant)
+		}
+		out, err := exec.Command("go", "version", "-m", filepath.Join(a.DirPath, a.FileName())).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "GOAMD64=" + a.Variant; !strings.Contains(string(out), want) {
+			t.Errorf("build info of %s lacks %s:\n%s", a.DirPath, want, out)
+		}
+	}
+}
+
+func TestCheckSingleTarget(t *testing.T) {
+	t.Setenv("GOOS", "linux")
+	t.Setenv("GOARCH", "amd64")
+	cfg := &config.Config{Builds: []config.BuildConfig{
+		{Main: "./cmd/app", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64", "arm64"}},
+	}}
+
+	if err := checkSingleTarget(cfg, false); err == nil || !strings.Contains(err.Error(), "4 would be built") {
+		t.Errorf("checkSingleTarget() error = %v, want 4 targets", err)
+	}
+	if err := checkSingleTarget(cfg, true); err != nil {
+		t.Errorf("checkSingleTarget(single) error = %v", err)
+	}
+
+	cfg.Builds = append(cfg.Builds, config.BuildConfig{Main: "./cmd/cli", Goos: []string{"linux"}, Goarch: []string{"amd64"}})
+	if err := checkSingleTarget(cfg, true); err == nil || !strings.Contains(err.Error(), "app linux/amd64, cli linux/amd64") {
+		t.Errorf("checkSingleTarget(single) error = %v, want two builds listed", err)
+	}
+}
+
+func TestCheckTargets(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	cfg := &config.Config{Builds: []config.BuildConfig{
+		{Main: "./cmd/app", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64", "arm64"}},
+		{Main: "./cmd/api", Goos: []string{"linux", "windos"}, Goarch: []string{"amd64", "amd46"}},
+		{Main: "./cmd/web", Goos: []string{"js", "linux"}, Goarch: []string{"wasm"}},
+		{Main: "./cmd/worker", Targets: []string{"linux/amd64", "plan10/amd64"}},
+	}}
+	err := CheckTargets(context.Background(), cfg, false)
+	if err == nil {
+		t.Fatal("CheckTargets() succeeded, want the unknown targets reported")
+	}
+	want := `4 target(s) not in go tool dist list (set allow_unknown_targets on a build for an experimental toolchain):
+  builds[1] api: goos "linux", goarch "amd46"
+  builds[1] api: goos "windos", goarch "amd64"
+  builds[1] api: goos "windos", goarch "amd46"
+  builds[3] worker: goos "plan10", goarch "amd64"`
+	if err.Error() != want {
+		t.Errorf("CheckTargets() error =\n%s\nwant\n%s", err, want)
+	}
+
+	cfg.Builds[1].AllowUnknownTargets = true
+	cfg.Builds[3].AllowUnknownTargets = true
+	if err := CheckTargets(context.Background(), cfg, false); err != nil {
+		t.Errorf("CheckTargets() with allow_unknown_targets error = %v", err)
+	}
+}
+
+// TestCheckTargetsGoBinary checks builds against the platforms of their
+// gobinary and leaves prebuilt builds out.
+func TestCheckTargetsGoBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$*\" = 'tool dist list' ] && printf 'linux/amd64\\nwasip1/wasm\\n'\n"
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Dir: dir, Builds: []config.BuildConfig{
+		{Main: ".", OutputName: "app", GoBinary: "./bin/fakego", Targets: []string{"wasip1/wasm", "linux/arm64"}},
+		{OutputName: "sidecar", Prebuilt: &config.PrebuiltConfig{PathTemplate: "bin/sidecar"}, Targets: []string{"plan10/amd64"}},
+	}}
+	err := CheckTargets(context.Background(), cfg, false)
+	want := `1 target(s) not in go tool dist list (set allow_unknown_targets on a build for an experimental toolchain):
+  builds[0] app: goos "linux", goarch "arm64"`
+	if err == nil || err.Error() != want {
+		t.Errorf("CheckTargets() error = %v, want\n%s", err, want)
+	}
+}
+
+// TestCheckedTargets lists the targets of a build with an unknown goarch as
+// invalid, as the build rejects them.
+func TestCheckedTargets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gobinary is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$*\" = 'tool dist list' ] && printf 'linux/amd64\\nlinux/arm64\\n'\n"
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "fakego"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Dir: dir, Builds: []config.BuildConfig{
+		{Main: ".", OutputName: "app", GoBinary: "./bin/fakego", Goos: []string{"linux"}, Goarch: []string{"amd64", "amd46"}},
+	}}
+
+	targets := CheckedTargets(context.Background(), cfg)
+	if len(targets) != 2 || targets[0].InvalidReason != "" || targets[1].InvalidReason != "not in go tool dist list" {
+		t.Fatalf("CheckedTargets() = %+v", targets)
+	}
+
+	var table bytes.Buffer
+	if err := WriteTargetsTable(&table, targets); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], "build") || !strings.HasSuffix(lines[2], "invalid: not in go tool dist list") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := WriteTargetsJSON(&out, targets); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"invalid_reason": "not in go tool dist list"`) {
+		t.Errorf("unexpected JSON: %s", out.String())
+	}
+}
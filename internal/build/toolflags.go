@@ -0,0 +1,52 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// toolFlags renders the gcflags or asmflags templates of a target into go
+// build arguments, e.g. -gcflags "all=-N -l". Entries are
+// [pattern=]flags like the go build flag; those with the same package
+// pattern are joined into one argument, as a repeated flag for a pattern
+// replaces the earlier one. Entries that render to nothing are dropped.
+func toolFlags(name string, templates []string, data any) ([]string, error) {
+	var patterns []string
+	flags := make(map[string][]string)
+	for i, t := range templates {
+		out, err := tmpl.Process(name, t, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", name, i, err)
+		}
+		out = strings.TrimSpace(out)
+		if out == "" {
+			continue
+		}
+		var pattern string
+		if !strings.HasPrefix(out, "-") {
+			p, rest, ok := strings.Cut(out, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s[%d] %q: want flags or pattern=flags, e.g. all=-N -l", name, i, out)
+			}
+			pattern, out = p, rest
+		}
+		if _, ok := flags[pattern]; !ok {
+			patterns = append(patterns, pattern)
+		}
+		if out = strings.TrimSpace(out); out != "" {
+			flags[pattern] = append(flags[pattern], out)
+		}
+	}
+
+	var args []string
+	for _, pattern := range patterns {
+		value := strings.Join(flags[pattern], " ")
+		if pattern != "" {
+			value = pattern + "=" + value
+		}
+		args = append(args, "-"+name, value)
+	}
+	return args, nil
+}
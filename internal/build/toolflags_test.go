@@ -0,0 +1,35 @@
+package build
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestToolFlags(t *testing.T) {
+	data := map[string]any{"Env": map[string]string{"DEBUG": "-N -l", "EMPTY": ""}}
+	tests := []struct {
+		templates []string
+		want      []string
+		wantErr   string
+	}{
+		{nil, nil, ""},
+		{[]string{"all=-N -l"}, []string{"-gcflags", "all=-N -l"}, ""},
+		{[]string{"all={{.Env.DEBUG}}", "-m", "all=-B", "{{.Env.EMPTY}}"}, []string{"-gcflags", "all=-N -l -B", "-gcflags", "-m"}, ""},
+		{[]string{"example.com/app/...=-d=checkptr"}, []string{"-gcflags", "example.com/app/...=-d=checkptr"}, ""},
+		{[]string{"all"}, nil, `gcflags[0] "all": want flags or pattern=flags`},
+		{[]string{"-m", "all={{.Env.DEBUG"}, nil, "gcflags[1]:"},
+	}
+	for _, tt := range tests {
+		got, err := toolFlags("gcflags", tt.templates, data)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("toolFlags(%q) error = %v, want %q", tt.templates, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("toolFlags(%q) = %q, %v; want %q", tt.templates, got, err, tt.want)
+		}
+	}
+}
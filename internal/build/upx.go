@@ -0,0 +1,47 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// upxAvailable returns an error when a build compresses binaries and upx
+// cannot be found in PATH.
+func upxAvailable(cfg *config.Config) error {
+	if !slices.ContainsFunc(cfg.Builds, func(b config.BuildConfig) bool { return b.UPX.Enabled }) {
+		return nil
+	}
+	if _, err := exec.LookPath("upx"); err != nil {
+		return fmt.Errorf("upx compression requires upx: %w", err)
+	}
+	return nil
+}
+
+// compressUPX compresses the binary at path in place.
+func compressUPX(ctx context.Context, upxCfg config.UPXConfig, path string) error {
+	log.Printf("Compressing %s with upx", path)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "upx", upxArgs(upxCfg, path)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("upx %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// upxArgs returns the arguments of upx compressing the binary at path.
+func upxArgs(upxCfg config.UPXConfig, path string) []string {
+	args := []string{"-q"}
+	if upxCfg.Level > 0 {
+		args = append(args, "-"+strconv.Itoa(upxCfg.Level))
+	}
+	return append(args, path)
+}
@@ -0,0 +1,83 @@
+package build
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/binfmt"
+)
+
+// verifyArchive checks that every executable in the archive at path is
+// built for the platform artifacts.json records for the archive, so a
+// template mixup cannot ship a darwin binary in a linux tarball.
+func verifyArchive(path string, artifact Artifact) error {
+	var found int
+	err := archive.Files(path, func(name string, r io.Reader) error {
+		br := bufio.NewReader(r)
+		// Short files cannot be executables; Peek reports them with an error
+		header, _ := br.Peek(binfmt.HeaderSize)
+		if binfmt.Sniff(header) == "" {
+			return nil
+		}
+		info, err := detectStream(br)
+		if errors.Is(err, binfmt.ErrNotExecutable) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("verify %s in %s: %w", name, filepath.Base(path), err)
+		}
+		found++
+		return checkPlatform(filepath.Base(path)+": "+name, info, artifact)
+	})
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		log.Printf("Warning: verify_contents found no executable in %s", filepath.Base(path))
+	}
+	return nil
+}
+
+// verifyBinary checks the bare binary at path like verifyArchive.
+func verifyBinary(path string, artifact Artifact) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := binfmt.Detect(f)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", filepath.Base(path), err)
+	}
+	return checkPlatform(filepath.Base(path), info, artifact)
+}
+
+// detectStream spools an archive entry to a temporary file, as the build
+// info of Go binaries is read at offsets from their section headers.
+func detectStream(r io.Reader) (binfmt.Info, error) {
+	f, err := os.CreateTemp("", "gcx-verify-*")
+	if err != nil {
+		return binfmt.Info{}, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if _, err := io.Copy(f, r); err != nil {
+		return binfmt.Info{}, err
+	}
+	return binfmt.Detect(f)
+}
+
+func checkPlatform(name string, info binfmt.Info, artifact Artifact) error {
+	if info.Matches(artifact.OS, artifact.Arch) {
+		return nil
+	}
+	return fmt.Errorf("%s is built for %s, but artifacts.json records %s/%s for it", name, info, artifact.OS, artifact.Arch)
+}
@@ -0,0 +1,108 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// TestRunVersionFormat checks that version_format changes {{.Version}} in
+// ldflags, out_dir, output directories, archive names, blob directories
+// and the manifest together, while {{.Tag}} stays the raw tag.
+func TestRunVersionFormat(t *testing.T) {
+	for _, tool := range []string{"go", "git"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skip(tool + " not installed")
+		}
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nimport \"fmt\"\n\nvar version, tag string\n\nfunc main() { fmt.Print(version + \"|\" + tag) }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=test@example.com", "-c", "user.name=test", "-c", "commit.gpgsign=false", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"-c", "tag.gpgsign=false", "tag", "v1.2.3"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	for _, tt := range []struct {
+		format, version string
+	}{
+		{"", "v1.2.3"},
+		{config.VersionWithoutV, "1.2.3"},
+		{config.VersionWithV, "v1.2.3"},
+	} {
+		t.Run("format "+tt.format, func(t *testing.T) {
+			cfg := &config.Config{
+				Dir:           dir,
+				OutDir:        filepath.Join(t.TempDir(), "dist", "{{.Version}}"),
+				VersionFormat: tt.format,
+				Builds: []config.BuildConfig{{
+					Main: ".", OutputName: "app",
+					Goos: []string{runtime.GOOS}, Goarch: []string{runtime.GOARCH},
+					Ldflags: []string{"-X main.version={{.Version}} -X main.tag={{.Tag}}"},
+				}},
+				Archives: []config.ArchiveConfig{{Formats: []string{"tar.gz"}, NameTemplate: "{{.Binary}}_{{.Version}}_{{.Os}}_{{.Arch}}"}},
+			}
+			artifacts, err := Run(context.Background(), cfg, Options{})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			a := artifacts[0]
+			outDir := filepath.Dir(a.DirPath)
+			if filepath.Base(outDir) != tt.version {
+				t.Errorf("out_dir = %s, want .../%s", outDir, tt.version)
+			}
+			if want := "app_" + tt.version + "_" + runtime.GOOS + "_" + runtime.GOARCH; filepath.Base(a.DirPath) != want {
+				t.Errorf("output directory = %s, want %s", filepath.Base(a.DirPath), want)
+			}
+			// The output directory is removed once archived; run the binary
+			// from the archive
+			archivePath := filepath.Join(outDir, "app_"+tt.version+"_"+runtime.GOOS+"_"+runtime.GOARCH+".tar.gz")
+			binary := filepath.Join(t.TempDir(), "app")
+			f, err := os.OpenFile(binary, os.O_CREATE|os.O_WRONLY, 0o755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := archive.ExtractFile(archivePath, filepath.Base(a.DirPath)+"/app", f); err != nil {
+				t.Fatalf("archive: %v", err)
+			}
+			_ = f.Close()
+			out, err := exec.Command(binary).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.version + "|v1.2.3"; string(out) != want {
+				t.Errorf("ldflags output = %q, want %q", out, want)
+			}
+			m, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Version != tt.version {
+				t.Errorf("manifest version = %q, want %q", m.Version, tt.version)
+			}
+			blob := config.BlobConfig{Directory: "releases/{{.Version}}/{{.Tag}}"}
+			if got, err := blob.RemoteDir(cfg.Release("v1.2.3")); err != nil || got != "releases/"+tt.version+"/v1.2.3" {
+				t.Errorf("blob directory = %q, %v", got, err)
+			}
+		})
+	}
+}
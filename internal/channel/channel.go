@@ -0,0 +1,62 @@
+// Package channel resolves the release channel of a build: stable, beta or
+// nightly. Templates see it as {{.Channel}}.
+package channel
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/git"
+)
+
+// Release channels.
+const (
+	Stable  = "stable"
+	Beta    = "beta"
+	Nightly = "nightly"
+)
+
+// Names lists the release channels.
+var Names = []string{Stable, Beta, Nightly}
+
+// Validate checks that name is a release channel.
+func Validate(name string) error {
+	if !slices.Contains(Names, name) {
+		return fmt.Errorf("unsupported channel %q: expected one of %s", name, strings.Join(Names, ", "))
+	}
+	return nil
+}
+
+// FromTag returns the channel of a build of tag: nightly when HEAD is not
+// exactly at the tag, beta for prerelease tags such as v1.2.0-rc.1 and
+// stable otherwise.
+func FromTag(tag string, tagged bool) string {
+	if !tagged {
+		return Nightly
+	}
+	// Build metadata after "+" may contain hyphens too
+	core, _, _ := strings.Cut(tag, "+")
+	if strings.Contains(core, "-") {
+		return Beta
+	}
+	return Stable
+}
+
+// Resolve returns name when it is set and otherwise detects the channel
+// from the git tag of HEAD.
+func Resolve(ctx context.Context, name string) (string, error) {
+	if name != "" {
+		if err := Validate(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	// Untagged builds are nightly whatever the previous tag, and GetTag
+	// would log a warning in repositories without tags
+	if !git.IsTagged(ctx) {
+		return Nightly, nil
+	}
+	return FromTag(git.GetTag(ctx), true), nil
+}
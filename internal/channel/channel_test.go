@@ -0,0 +1,38 @@
+package channel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromTag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		tagged bool
+		want   string
+	}{
+		{tag: "v1.2.0", tagged: true, want: Stable},
+		{tag: "v1.2.0+build.5", tagged: true, want: Stable},
+		{tag: "v1.2.0-rc.1", tagged: true, want: Beta},
+		{tag: "v1.2.0-beta+exp-sha.5114f85", tagged: true, want: Beta},
+		{tag: "v1.2.0", tagged: false, want: Nightly},
+		{tag: "0.0.0", tagged: false, want: Nightly},
+	}
+	for _, tt := range tests {
+		if got := FromTag(tt.tag, tt.tagged); got != tt.want {
+			t.Errorf("FromTag(%q, %v) = %q, want %q", tt.tag, tt.tagged, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	got, err := Resolve(t.Context(), Beta)
+	if err != nil || got != Beta {
+		t.Errorf("Resolve(beta) = %q, %v", got, err)
+	}
+
+	_, err = Resolve(t.Context(), "canary")
+	if err == nil || !strings.Contains(err.Error(), "expected one of stable, beta, nightly") {
+		t.Errorf("Resolve(canary) error = %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package checksum
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ShortLen is the number of hex digits returned by Digest.ShortSHA256.
+const ShortLen = 6
+
+// Digest holds the digests of a local file.
+type Digest struct {
+	Size   int64
+	MD5    []byte
+	SHA256 []byte
+}
+
+// MD5Hex returns the hex-encoded MD5 digest.
+func (d Digest) MD5Hex() string { return hex.EncodeToString(d.MD5) }
+
+// SHA256Hex returns the hex-encoded SHA-256 digest.
+func (d Digest) SHA256Hex() string { return hex.EncodeToString(d.SHA256) }
+
+// ShortSHA256 returns the first ShortLen hex digits of the SHA-256 digest,
+// as embedded in content-addressed file names.
+func (d Digest) ShortSHA256() string { return d.SHA256Hex()[:ShortLen] }
+
+// SHA256Base64 returns the base64-encoded SHA-256 digest as used by S3 checksum headers.
+func (d Digest) SHA256Base64() string { return base64.StdEncoding.EncodeToString(d.SHA256) }
+
+// File computes the digests of the file at path in a single pass.
+func File(path string) (Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Digest{}, fmt.Errorf("open file: %w", err)
+	}
+	defer func() {
+		_ = f.Close() // read-only, safe to ignore
+	}()
+
+	md5h := md5.New()
+	sha256h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(md5h, sha256h), f)
+	if err != nil {
+		return Digest{}, fmt.Errorf("read file: %w", err)
+	}
+	return Digest{Size: n, MD5: md5h.Sum(nil), SHA256: sha256h.Sum(nil)}, nil
+}
+
+// Algorithms lists the hash algorithms of checksums files, in the order
+// of preference.
+var Algorithms = []string{"sha256", "sha512", "sha1", "md5", "blake2b"}
+
+// NewHash returns a new hash for algorithm, one of Algorithms. blake2b is
+// BLAKE2b-512, as printed by b2sum.
+func NewHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "blake2b":
+		return blake2b.New512(nil)
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q: expected one of %s", algorithm, strings.Join(Algorithms, ", "))
+	}
+}
+
+// Sums computes the hex digests of the file at path for each of algorithms
+// in a single pass.
+func Sums(path string, algorithms []string) ([]string, error) {
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, alg := range algorithms {
+		h, err := NewHash(alg)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i], writers[i] = h, h
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer func() {
+		_ = f.Close() // read-only, safe to ignore
+	}()
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	sums := make([]string, len(hashes))
+	for i, h := range hashes {
+		sums[i] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, nil
+}
+
+// Cache memoizes file digests so each artifact is hashed once per run,
+// no matter how many destinations it is uploaded to.
+type Cache struct {
+	mu      sync.Mutex
+	digests map[string]Digest
+}
+
+// NewCache creates an empty digest cache.
+func NewCache() *Cache {
+	return &Cache{digests: make(map[string]Digest)}
+}
+
+// File returns the cached digests for path, computing them on first use.
+func (c *Cache) File(path string) (Digest, error) {
+	c.mu.Lock()
+	d, ok := c.digests[path]
+	c.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+
+	d, err := File(path)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	c.mu.Lock()
+	c.digests[path] = d
+	c.mu.Unlock()
+	return d, nil
+}
+
+// Parse reads a checksums file in GNU coreutils ("<hex>  <name>" or
+// "<hex> *<name>") or BSD ("SHA256 (<name>) = <hex>") format and returns
+// the hex digests keyed by file name.
+func Parse(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := bsdLineRegex.FindStringSubmatch(line); m != nil {
+			sums[m[1]] = strings.ToLower(m[2])
+			continue
+		}
+
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || !isHex(hash) {
+			return nil, fmt.Errorf("line %d: malformed checksum entry", lineNo)
+		}
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		sums[name] = strings.ToLower(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read checksums: %w", err)
+	}
+	return sums, nil
+}
+
+var bsdLineRegex = regexp.MustCompile(`^[A-Za-z0-9-]+ \((.+)\) = ([0-9a-fA-F]+)$`)
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Verify checks the file at path against a hex digest. The hash algorithm
+// is chosen from the digest length (MD5, SHA-1, SHA-256 or SHA-512); a
+// 512-bit digest may also be BLAKE2b.
+func Verify(path, expected string) error {
+	var algorithms []string
+	switch len(expected) {
+	case md5.Size * 2:
+		algorithms = []string{"md5"}
+	case sha1.Size * 2:
+		algorithms = []string{"sha1"}
+	case sha256.Size * 2:
+		algorithms = []string{"sha256"}
+	case sha512.Size * 2:
+		algorithms = []string{"sha512", "blake2b"}
+	default:
+		return fmt.Errorf("unsupported digest length %d", len(expected))
+	}
+
+	sums, err := Sums(path, algorithms)
+	if err != nil {
+		return err
+	}
+	for _, actual := range sums {
+		if strings.EqualFold(actual, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, expected, sums[0])
+}
@@ -0,0 +1,140 @@
+package checksum
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Size != 11 {
+		t.Errorf("Size = %d, want 11", d.Size)
+	}
+	if got, want := d.MD5Hex(), "5eb63bbbe01eeed093cb22bb8f5acdc3"; got != want {
+		t.Errorf("MD5Hex() = %q, want %q", got, want)
+	}
+	if got, want := d.SHA256Hex(), "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"; got != want {
+		t.Errorf("SHA256Hex() = %q, want %q", got, want)
+	}
+	if got, want := d.SHA256Base64(), "uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="; got != want {
+		t.Errorf("SHA256Base64() = %q, want %q", got, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("first"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache()
+	first, err := c.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache must not re-read the file once the digest is known.
+	if err := os.WriteFile(path, []byte("second"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SHA256Hex() != second.SHA256Hex() {
+		t.Error("expected cached digest to be returned")
+	}
+
+	if _, err := c.File(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := `b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9  app_linux_amd64.tar.gz
+5eb63bbbe01eeed093cb22bb8f5acdc3 *app_windows_amd64.zip
+
+SHA256 (app_darwin_arm64.tar.gz) = b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9
+`
+	sums, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"app_linux_amd64.tar.gz":  "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		"app_windows_amd64.zip":   "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		"app_darwin_arm64.tar.gz": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+	}
+	if len(sums) != len(want) {
+		t.Fatalf("Parse() returned %d entries, want %d: %v", len(sums), len(want), sums)
+	}
+	for name, sum := range want {
+		if sums[name] != sum {
+			t.Errorf("sums[%q] = %q, want %q", name, sums[name], sum)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("not a checksum line\n")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "md5", expected: "5eb63bbbe01eeed093cb22bb8f5acdc3"},
+		{name: "sha1", expected: "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"},
+		{name: "sha256", expected: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		{name: "sha512", expected: "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"},
+		{name: "blake2b", expected: "021ced8799296ceca557832ab941a50b4a11f83478cf141f51f933f653ab9fbcc05a037cddbed06e309bf334942c4e58cdf1a46e237911ccd7fcf9787cbc7fd0"},
+		{name: "uppercase", expected: "B94D27B9934D3E08A52E52D7DA7DABFAC484EFE37A5380EE9088F7ACE2EFCDE9"},
+		{name: "mismatch", expected: "00000000000000000000000000000000", wantErr: true},
+		{name: "unknown length", expected: "abcd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(path, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sums, err := Sums(path, []string{"sha256", "md5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", "5eb63bbbe01eeed093cb22bb8f5acdc3"}
+	if strings.Join(sums, ",") != strings.Join(want, ",") {
+		t.Errorf("Sums() = %v, want %v", sums, want)
+	}
+
+	if _, err := Sums(path, []string{"crc32"}); err == nil || !strings.Contains(err.Error(), "expected one of sha256, sha512, sha1, md5, blake2b") {
+		t.Errorf("unknown algorithm error = %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package checksums writes the checksums files of a release directory in
+// GNU coreutils or BSD format.
+package checksums
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// File is the name of the checksums file of a single algorithm.
+const File = "checksums.txt"
+
+// Line formats of checksums files.
+const (
+	// FormatGNU writes "<hex>  <name>" lines, as sha256sum prints them.
+	FormatGNU = "gnu"
+	// FormatBSD writes "SHA256 (<name>) = <hex>" lines, as shasum --tag
+	// and sha256sum --tag print them.
+	FormatBSD = "bsd"
+)
+
+// Formats lists the supported line formats.
+var Formats = []string{FormatGNU, FormatBSD}
+
+// Options controls how entries are written.
+type Options struct {
+	// Format is FormatGNU (the default) or FormatBSD.
+	Format string
+	// Prefix is a relative directory every name is recorded under, e.g.
+	// v1.2.0 for artifacts served from a version subdirectory.
+	Prefix string
+}
+
+// Entry is one checksummed file.
+type Entry struct {
+	Name string
+	Sum  string
+}
+
+// Files returns the names of the checksums files for algorithms:
+// checksums.txt for a single algorithm, checksums_<algorithm>.txt for each
+// of several.
+func Files(algorithms []string) []string {
+	if len(algorithms) == 1 {
+		return []string{File}
+	}
+	names := make([]string, len(algorithms))
+	for i, alg := range algorithms {
+		names[i] = fileFor(alg)
+	}
+	return names
+}
+
+func fileFor(algorithm string) string {
+	return "checksums_" + algorithm + ".txt"
+}
+
+// IsFile reports whether name is a checksums file of any algorithm.
+func IsFile(name string) bool {
+	if name == File {
+		return true
+	}
+	for _, alg := range checksum.Algorithms {
+		if name == fileFor(alg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode writes entries of algorithm to w in opts.Format, with their names
+// under opts.Prefix.
+func Encode(w io.Writer, algorithm string, entries []Entry, opts Options) error {
+	for _, e := range entries {
+		name := e.Name
+		if opts.Prefix != "" {
+			name = path.Join(opts.Prefix, name)
+		}
+		var err error
+		switch opts.Format {
+		case "", FormatGNU:
+			_, err = fmt.Fprintf(w, "%s  %s\n", e.Sum, name)
+		case FormatBSD:
+			_, err = fmt.Fprintf(w, "%s (%s) = %s\n", bsdTag(algorithm), name, e.Sum)
+		default:
+			return fmt.Errorf("unsupported checksums format %q: expected one of %s", opts.Format, strings.Join(Formats, ", "))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bsdTag returns the algorithm name BSD-style lines start with, as
+// shasum and b2sum print it.
+func bsdTag(algorithm string) string {
+	if algorithm == "blake2b" {
+		return "BLAKE2b"
+	}
+	return strings.ToUpper(algorithm)
+}
+
+// Write writes the sums of the files directly in dir for each of
+// algorithms, named by Files, and returns their paths. Each file is read
+// once. The build manifest, checksums files and signatures are not listed.
+func Write(dir string, algorithms []string, opts Options) ([]string, error) {
+	if opts.Prefix != "" && (path.IsAbs(opts.Prefix) || !filepath.IsLocal(filepath.FromSlash(opts.Prefix))) {
+		return nil, fmt.Errorf("checksums prefix %q must be a relative path", opts.Prefix)
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", dir, err)
+	}
+
+	entries := make([][]Entry, len(algorithms))
+	for _, e := range dirEntries {
+		name := e.Name()
+		if !e.Type().IsRegular() || name == manifest.FileName || IsFile(name) || strings.HasSuffix(name, ".sig") {
+			continue
+		}
+		sums, err := checksum.Sums(filepath.Join(dir, name), algorithms)
+		if err != nil {
+			return nil, fmt.Errorf("checksum %s: %w", name, err)
+		}
+		for i, sum := range sums {
+			entries[i] = append(entries[i], Entry{Name: name, Sum: sum})
+		}
+	}
+
+	paths := make([]string, 0, len(algorithms))
+	for i, name := range Files(algorithms) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, algorithms[i], entries[i], opts); err != nil {
+			return nil, err
+		}
+		sumsPath := filepath.Join(dir, name)
+		if err := os.WriteFile(sumsPath, buf.Bytes(), 0o644); err != nil {
+			return nil, fmt.Errorf("write checksums: %w", err)
+		}
+		paths = append(paths, sumsPath)
+	}
+	return paths, nil
+}
@@ -0,0 +1,143 @@
+package checksums
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// writeRelease writes the files of a release directory, including those
+// Write must not list.
+func writeRelease(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"app_linux_amd64.tar.gz": "archive",
+		"app_windows_amd64.zip":  "zip archive",
+		manifest.FileName:        "{}",
+		"old.txt.sig":            "sig",
+		File:                     "stale",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "app_linux_amd64"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestWriteGolden(t *testing.T) {
+	tests := []struct {
+		golden     string
+		algorithms []string
+		opts       Options
+	}{
+		{"gnu_sha256.golden", []string{"sha256"}, Options{}},
+		{"bsd_sha256.golden", []string{"sha256"}, Options{Format: FormatBSD}},
+		{"gnu_prefix.golden", []string{"sha256"}, Options{Format: FormatGNU, Prefix: "v1.2.0"}},
+		{"bsd_prefix.golden", []string{"sha256"}, Options{Format: FormatBSD, Prefix: "releases/v1.2.0/"}},
+		{"bsd_blake2b.golden", []string{"sha512", "blake2b"}, Options{Format: FormatBSD}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			dir := writeRelease(t)
+			paths, err := Write(dir, tt.algorithms, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != len(tt.algorithms) {
+				t.Fatalf("paths = %v", paths)
+			}
+			// Every file of several algorithms goes into one golden file
+			var got []byte
+			for i, path := range paths {
+				if want := Files(tt.algorithms)[i]; filepath.Base(path) != want {
+					t.Errorf("paths[%d] = %s, want %s", i, path, want)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, data...)
+			}
+			checkGolden(t, tt.golden, got)
+
+			// What gcx verify reads back
+			sums, err := checksum.Parse(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(sums) != 2 {
+				t.Errorf("Parse() = %v, want the two archives", sums)
+			}
+		})
+	}
+}
+
+// TestWriteCheckTools checks the written files with sha256sum -c and
+// shasum -c run from the directory the prefix is relative to.
+func TestWriteCheckTools(t *testing.T) {
+	for _, tool := range [][]string{{"sha256sum", "-c"}, {"shasum", "-a", "256", "-c"}} {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			t.Logf("%s not installed", tool[0])
+			continue
+		}
+		for _, format := range Formats {
+			root := t.TempDir()
+			dir := filepath.Join(root, "v1.2.0")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "app.tar.gz"), []byte("archive"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			paths, err := Write(dir, []string{"sha256"}, Options{Format: format, Prefix: "v1.2.0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(tool[0], append(tool[1:], paths[0])...)
+			cmd.Dir = root
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s with %s format: %v: %s", tool[0], format, err, out)
+			}
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	dir := writeRelease(t)
+	if _, err := Write(dir, []string{"sha256"}, Options{Format: "json"}); err == nil {
+		t.Error("Write() with an unknown format succeeded")
+	}
+	if _, err := Write(dir, []string{"sha256"}, Options{Prefix: "../v1.2.0"}); err == nil {
+		t.Error("Write() with a prefix outside the directory succeeded")
+	}
+}
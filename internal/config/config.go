@@ -1,140 +1,2157 @@
 package config
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/channel"
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/checksums"
+	"github.com/sxwebdev/gcx/internal/configtypes"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/include"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/schedule"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+	"github.com/sxwebdev/gcx/internal/toolchain"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the top-level gcx configuration.
 type Config struct {
-	OutDir      string          `yaml:"out_dir"`
-	Concurrency int             `yaml:"concurrency,omitempty"`
-	Before      HooksConfig     `yaml:"before,omitempty"`
-	After       HooksConfig     `yaml:"after,omitempty"`
-	Builds      []BuildConfig   `yaml:"builds,omitempty"`
-	Archives    []ArchiveConfig `yaml:"archives,omitempty"`
-	Blobs       []BlobConfig    `yaml:"blobs,omitempty"`
-	Deploys     []DeployConfig  `yaml:"deploys,omitempty"`
+	OutDir      string `yaml:"out_dir"`
+	Concurrency int    `yaml:"concurrency,omitempty"`
+	// Parallelism caps the concurrent go build processes across all
+	// targets of all builds (default: concurrency).
+	Parallelism int `yaml:"parallelism,omitempty"`
+	// ProjectName names the project in archive name templates (default:
+	// the name of the config directory).
+	ProjectName string `yaml:"project_name,omitempty"`
+	// VersionFormat controls how {{.Version}} renders the git tag:
+	// raw (default, as is), with_v or without_v. {{.Tag}} is always raw.
+	VersionFormat string `yaml:"version_format,omitempty"`
+	// GoVersion is a constraint on the go toolchain, e.g. ">=1.22".
+	GoVersion string `yaml:"go_version,omitempty"`
+	// StrictToolchain warns when the toolchain is newer than go.mod declares.
+	StrictToolchain bool `yaml:"strict_toolchain,omitempty"`
+	// StagingStrategy is how files are placed into out_dir between
+	// stages: copy, hardlink (default) or move. Hard links fall back to
+	// copies across filesystems.
+	StagingStrategy string        `yaml:"staging_strategy,omitempty"`
+	Before          HooksConfig   `yaml:"before,omitempty"`
+	After           HooksConfig   `yaml:"after,omitempty"`
+	Builds          []BuildConfig `yaml:"builds,omitempty"`
+	// EmbedChecks fail the build when embedded assets are older than
+	// their sources.
+	EmbedChecks    []EmbedCheckConfig    `yaml:"embed_checks,omitempty"`
+	Archives       []ArchiveConfig       `yaml:"archives,omitempty"`
+	Checksum       ChecksumConfig        `yaml:"checksum,omitempty"`
+	Signs          []SignConfig          `yaml:"signs,omitempty"`
+	SBOMs          []SBOMConfig          `yaml:"sboms,omitempty"`
+	GeneratedFiles []GeneratedFileConfig `yaml:"generated_files,omitempty"`
+	Publish        PublishConfig         `yaml:"publish,omitempty"`
+	Blobs          []BlobConfig          `yaml:"blobs,omitempty"`
+	Announce       AnnounceConfig        `yaml:"announce,omitempty"`
+	Deploys        []DeployConfig        `yaml:"deploys,omitempty"`
+	DeployPolicy   DeployPolicyConfig    `yaml:"deploy_policy,omitempty"`
+	GC             GCConfig              `yaml:"gc,omitempty"`
+	Changelog      ChangelogConfig       `yaml:"changelog,omitempty"`
+	// ReleaseSettings holds the deadline budget of gcx release; Release is
+	// the method rendering ReleaseData.
+	ReleaseSettings ReleaseConfig `yaml:"release,omitempty"`
+	// GoPrivate is set as GOPRIVATE for hooks and every build.
+	GoPrivate configtypes.StringList `yaml:"goprivate,omitempty"`
+	// GitAuth provides credentials for private modules to hooks and
+	// builds without their own git_auth.
+	GitAuth *GitAuthConfig `yaml:"git_auth,omitempty"`
+
+	// Dir is the directory relative paths were resolved against by
+	// ResolvePaths. Empty means the working directory.
+	Dir string `yaml:"-"`
+	// Channel is the release channel resolved from --channel or the git
+	// tag: stable, beta or nightly.
+	Channel string `yaml:"-"`
+}
+
+// Project returns project_name, or the name of the config directory when
+// it is not set.
+func (c *Config) Project() string {
+	if c.ProjectName != "" {
+		return c.ProjectName
+	}
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(dir)
+}
+
+// ReleaseData is the template data of templates rendered once per release:
+// out_dir, blob directories, object names and enabled expressions.
+type ReleaseData struct {
+	// Version is the tag formatted by version_format.
+	Version string
+	Channel string
+	// Tag is the git tag as is.
+	Tag string
+}
+
+// Release returns the template data of tag on the configured channel.
+func (c *Config) Release(tag string) ReleaseData {
+	return ReleaseData{Version: c.FormatVersion(tag), Channel: c.Channel, Tag: tag}
+}
+
+// Version formats of version_format.
+const (
+	VersionRaw      = "raw"
+	VersionWithV    = "with_v"
+	VersionWithoutV = "without_v"
+)
+
+// Staging strategies of staging_strategy.
+const (
+	StagingCopy     = "copy"
+	StagingHardlink = "hardlink"
+	StagingMove     = "move"
+)
+
+// Staging returns the staging_strategy, hardlink when unset.
+func (c *Config) Staging() string {
+	return cmp.Or(c.StagingStrategy, StagingHardlink)
+}
+
+// FormatVersion returns tag as {{.Version}} renders it: with_v adds a
+// leading v to tags starting with a digit and without_v removes it from
+// tags like v1.2.3. Other tags, e.g. with a tag prefix, are kept as is.
+func (c *Config) FormatVersion(tag string) string {
+	startsWithDigit := func(s string) bool { return s != "" && s[0] >= '0' && s[0] <= '9' }
+	switch c.VersionFormat {
+	case VersionWithV:
+		if startsWithDigit(tag) {
+			return "v" + tag
+		}
+	case VersionWithoutV:
+		if rest, ok := strings.CutPrefix(tag, "v"); ok && startsWithDigit(rest) {
+			return rest
+		}
+	}
+	return tag
+}
+
+// GCConfig controls pruning of old build outputs and gcx caches by `gcx gc`.
+type GCConfig struct {
+	// KeepLast is the number of most recent versioned output directories to keep.
+	KeepLast int `yaml:"keep_last,omitempty"`
+	// MaxAge prunes output directories older than this (beyond KeepLast).
+	MaxAge configtypes.Duration `yaml:"max_age,omitempty"`
+	// CacheDir is the gcx cache directory (default: .gcx/cache).
+	CacheDir string `yaml:"cache_dir,omitempty"`
+	// CacheMaxAge removes cache entries not modified for this long.
+	CacheMaxAge configtypes.Duration `yaml:"cache_max_age,omitempty"`
+	// CacheMaxSize trims the oldest cache entries until the cache fits.
+	CacheMaxSize configtypes.Size `yaml:"cache_max_size,omitempty"`
+}
+
+// ChangelogConfig holds settings for gcx release changelog.
+type ChangelogConfig struct {
+	// RepoURL is the repository web URL used for the compare link when the
+	// git checkout has no remote, e.g. https://github.com/acme/app.
+	RepoURL string `yaml:"repo_url,omitempty"`
+	// GroupBy groups conventional commits into sections: type, scope or
+	// type_then_scope. Empty lists the commits in git log order.
+	GroupBy string `yaml:"group_by,omitempty"`
+	// ScopeAliases maps a scope to the scope it is listed under, e.g.
+	// apiv2 to api.
+	ScopeAliases map[string]string `yaml:"scope_aliases,omitempty"`
+}
+
+// Options returns the git.GetChangelog options of the config.
+func (c ChangelogConfig) Options() git.ChangelogOptions {
+	return git.ChangelogOptions{RepoURL: c.RepoURL, GroupBy: c.GroupBy, ScopeAliases: c.ScopeAliases}
+}
+
+// ReleaseStages lists the stages of gcx release in order.
+var ReleaseStages = []string{"build", "publish", "deploy"}
+
+// ReleaseConfig holds settings for gcx release.
+type ReleaseConfig struct {
+	// Timeout bounds the whole release; each stage gets a share of it by
+	// StageWeights.
+	Timeout configtypes.Duration `yaml:"timeout,omitempty"`
+	// StageWeights maps a stage to its share of Timeout; stages not
+	// listed weigh 1.
+	StageWeights map[string]int `yaml:"stage_weights,omitempty"`
+}
+
+// Validate checks that Timeout is not negative and StageWeights are
+// positive weights of known stages.
+func (r *ReleaseConfig) Validate() error {
+	if r.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	for _, stage := range slices.Sorted(maps.Keys(r.StageWeights)) {
+		if !slices.Contains(ReleaseStages, stage) {
+			return fmt.Errorf("stage_weights: unknown stage %q, expected one of %s", stage, strings.Join(ReleaseStages, ", "))
+		}
+		if r.StageWeights[stage] <= 0 {
+			return fmt.Errorf("stage_weights: weight of %s must be positive", stage)
+		}
+	}
+	return nil
+}
+
+// Validate checks that RepoURL is an http(s) URL, GroupBy a grouping
+// and ScopeAliases lower-case scopes.
+func (c *ChangelogConfig) Validate() error {
+	if c.RepoURL != "" {
+		u, err := url.Parse(c.RepoURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("repo_url %q must be an http(s) URL", c.RepoURL)
+		}
+	}
+	if c.GroupBy != "" && !slices.Contains(git.GroupBys, c.GroupBy) {
+		return fmt.Errorf("group_by must be one of %s, got %q", strings.Join(git.GroupBys, ", "), c.GroupBy)
+	}
+	for _, alias := range slices.Sorted(maps.Keys(c.ScopeAliases)) {
+		for _, scope := range []string{alias, c.ScopeAliases[alias]} {
+			if !scopeRegex.MatchString(scope) {
+				return fmt.Errorf("scope_aliases: %q must be a lower-case scope such as api or internal/build", scope)
+			}
+		}
+		if _, ok := c.ScopeAliases[c.ScopeAliases[alias]]; ok {
+			return fmt.Errorf("scope_aliases: %s maps to %s, which is an alias itself", alias, c.ScopeAliases[alias])
+		}
+	}
+	return nil
+}
+
+// scopeRegex matches the scopes of scope_aliases.
+var scopeRegex = regexp.MustCompile(`^[a-z0-9_./-]+$`)
+
+// HooksConfig holds shell commands to execute before/after build.
+type HooksConfig struct {
+	Hooks []Hook `yaml:"hooks,omitempty"`
+}
+
+// Validate checks that every hook has a command and well-formed tags.
+func (h *HooksConfig) Validate() error {
+	for i, hook := range h.Hooks {
+		if strings.TrimSpace(hook.Cmd) == "" {
+			return fmt.Errorf("hooks[%d]: cmd is required", i)
+		}
+		for _, tag := range hook.Tags {
+			if tag == "" || strings.ContainsAny(tag, ", \t") {
+				return fmt.Errorf("hooks[%d]: tag %q must be non-empty without commas or whitespace", i, tag)
+			}
+		}
+	}
+	return nil
+}
+
+// Hook is a shell command run via sh -c. A plain string in YAML is the
+// command alone; the object form {cmd, tags} labels it for the
+// --hooks-tags and --skip-hooks-tags filters.
+type Hook struct {
+	Cmd string `yaml:"cmd"`
+	// Tags select the hook with the filters; untagged hooks always run.
+	Tags []string `yaml:"tags,omitempty"`
+}
+
+// hookObject decodes the object form without the Hook methods.
+type hookObject Hook
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (h *Hook) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*h = Hook{Cmd: n.Value}
+		return nil
+	}
+	return n.Decode((*hookObject)(h))
+}
+
+// MarshalYAML implements yaml.Marshaler. Untagged hooks are written as
+// plain strings.
+func (h Hook) MarshalYAML() (any, error) {
+	if len(h.Tags) == 0 {
+		return h.Cmd, nil
+	}
+	return hookObject(h), nil
+}
+
+// BuildConfig defines a cross-compilation build target.
+type BuildConfig struct {
+	// ID names the build for the builds filters of archives and blobs.
+	ID   string `yaml:"id,omitempty"`
+	Main string `yaml:"main"`
+	// Dir is the working directory of go build and its go commands, e.g. a
+	// module of a go.work workspace; main is relative to it (default: the
+	// config file's directory).
+	Dir string `yaml:"dir,omitempty"`
+	// GoBinary is the tool compiling the build instead of go, e.g. garble
+	// or tinygo: a command on PATH or a path relative to dir.
+	GoBinary string `yaml:"gobinary,omitempty"`
+	// Command is the subcommand of GoBinary with any arguments before the
+	// build flags (default: build).
+	Command               string   `yaml:"command,omitempty"`
+	OutputName            string   `yaml:"output_name,omitempty"`
+	DisablePlatformSuffix bool     `yaml:"disable_platform_suffix,omitempty"`
+	Goos                  []string `yaml:"goos"`
+	Goarch                []string `yaml:"goarch"`
+	Goarm                 []string `yaml:"goarm,omitempty"`
+	Flags                 []string `yaml:"flags,omitempty"`
+	Ldflags               []string `yaml:"ldflags,omitempty"`
+	Env                   []string `yaml:"env,omitempty"`
+	// Goamd64, Goarm64, Gomips and Goriscv64 list the GOAMD64, GOARM64,
+	// GOMIPS (mips and mipsle) and GORISCV64 variants built for their
+	// goarch, like Goarm for arm.
+	Goamd64   []string `yaml:"goamd64,omitempty"`
+	Goarm64   []string `yaml:"goarm64,omitempty"`
+	Gomips    []string `yaml:"gomips,omitempty"`
+	Goriscv64 []string `yaml:"goriscv64,omitempty"`
+	// Targets lists the matrix as goos/goarch or goos/goarch/variant
+	// entries, e.g. linux/arm/v7 or linux/amd64/v3, instead of goos,
+	// goarch and the variant lists.
+	Targets []string `yaml:"targets,omitempty"`
+	// AllowUnknownTargets skips checking the targets against go tool dist
+	// list, for experimental toolchains that build other platforms.
+	AllowUnknownTargets bool `yaml:"allow_unknown_targets,omitempty"`
+	// Tags are build tags passed as one -tags argument; entries are
+	// templates and may hold several comma-separated tags.
+	Tags []string `yaml:"tags,omitempty"`
+	// Gcflags and Asmflags are passed as -gcflags and -asmflags. Entries
+	// are templates; those with the same package pattern, e.g. all=, are
+	// joined into one argument.
+	Gcflags  []string `yaml:"gcflags,omitempty"`
+	Asmflags []string `yaml:"asmflags,omitempty"`
+	// Buildmode is passed as -buildmode, e.g. pie or c-shared. Library
+	// modes name the output after the library conventions of the target.
+	Buildmode string `yaml:"buildmode,omitempty"`
+	// Timeout kills the go build of a single target that runs longer,
+	// e.g. a wedged cgo link, and fails the build naming the target.
+	Timeout configtypes.Duration `yaml:"timeout,omitempty"`
+	// Extensions overrides the binary extension per GOOS, e.g.
+	// {windows: ".exe", js: ".wasm"}. An empty value removes the extension.
+	Extensions map[string]string `yaml:"extensions,omitempty"`
+	// IncludeWasmExec copies wasm_exec.js from the Go distribution next to
+	// js/wasm binaries.
+	IncludeWasmExec bool `yaml:"include_wasm_exec,omitempty"`
+	// OnlyIfChanged skips the build unless a file matching one of these
+	// globs changed since the previous tag.
+	OnlyIfChanged []string `yaml:"only_if_changed,omitempty"`
+	// TagPrefix restricts the tags compared by OnlyIfChanged, e.g. "api/".
+	TagPrefix string `yaml:"tag_prefix,omitempty"`
+	// Group puts the binaries of all builds with the same group into one
+	// output directory, and so one archive, per target.
+	Group string `yaml:"group,omitempty"`
+	// Prebuilt copies existing binaries instead of running go build.
+	Prebuilt *PrebuiltConfig `yaml:"prebuilt,omitempty"`
+	// UPX compresses the binaries before archiving.
+	UPX UPXConfig `yaml:"upx,omitempty"`
+	// EmbedChangelog sets a string variable to the changelog of the release.
+	EmbedChangelog *EmbedChangelogConfig `yaml:"embed_changelog,omitempty"`
+	// GitAuth replaces the top-level git_auth for this build.
+	GitAuth *GitAuthConfig `yaml:"git_auth,omitempty"`
+	// Ignore skips matching combinations of the goos × goarch × goarm
+	// matrix. Unset, arm is only built for linux.
+	Ignore []TargetMatch `yaml:"ignore,omitempty"`
+	// Overrides change flags, ldflags and env of matching targets, in order.
+	Overrides []OverrideConfig `yaml:"overrides,omitempty"`
+	// CGO enables cgo with a C toolchain per target.
+	CGO *CGOConfig `yaml:"cgo,omitempty"`
+	// Coverage builds coverage-instrumented binaries with go build -cover.
+	Coverage *CoverageConfig `yaml:"coverage,omitempty"`
+}
+
+// Instrumented reports whether the build produces coverage-instrumented
+// binaries.
+func (b *BuildConfig) Instrumented() bool {
+	return b.Coverage != nil && b.Coverage.Enabled
+}
+
+// archVariant is a sub-architecture setting of the build matrix: the
+// BuildConfig list of its variants, the environment variable selecting
+// one and the goarch values it applies to.
+type archVariant struct {
+	key    string
+	env    string
+	goarch []string
+	list   func(b *BuildConfig) []string
+	// valid matches the accepted values; nil accepts any (goarm)
+	valid *regexp.Regexp
+}
+
+var archVariants = []archVariant{
+	{key: "goarm", env: "GOARM", goarch: []string{"arm"}, list: func(b *BuildConfig) []string { return b.Goarm }},
+	{key: "goamd64", env: "GOAMD64", goarch: []string{"amd64"}, list: func(b *BuildConfig) []string { return b.Goamd64 },
+		valid: regexp.MustCompile(`^v[1-4]$`)},
+	{key: "goarm64", env: "GOARM64", goarch: []string{"arm64"}, list: func(b *BuildConfig) []string { return b.Goarm64 },
+		valid: regexp.MustCompile(`^v(8\.[0-9]|9\.[0-5])(,(lse|crypto))*$`)},
+	{key: "gomips", env: "GOMIPS", goarch: []string{"mips", "mipsle"}, list: func(b *BuildConfig) []string { return b.Gomips },
+		valid: regexp.MustCompile(`^(hardfloat|softfloat)$`)},
+	{key: "goriscv64", env: "GORISCV64", goarch: []string{"riscv64"}, list: func(b *BuildConfig) []string { return b.Goriscv64 },
+		valid: regexp.MustCompile(`^rva2[023]u64$`)},
+}
+
+// Variants returns the environment variable selecting the sub-architecture
+// variant of goarch and the variants to build, e.g. GOAMD64 and [v1 v3].
+// The env is empty for a goarch without variants.
+func (b *BuildConfig) Variants(goarch string) (env string, variants []string) {
+	for _, v := range archVariants {
+		if slices.Contains(v.goarch, goarch) {
+			return v.env, v.list(b)
+		}
+	}
+	return "", nil
+}
+
+// UnusedVariants returns the keys of the variant lists that apply to no
+// goarch of the build, e.g. goamd64 without amd64.
+func (b *BuildConfig) UnusedVariants() []string {
+	var keys []string
+	for _, v := range archVariants {
+		if len(v.list(b)) > 0 && !slices.ContainsFunc(v.goarch, func(goarch string) bool { return slices.Contains(b.Goarch, goarch) }) {
+			keys = append(keys, v.key)
+		}
+	}
+	return keys
+}
+
+// Platform is a parsed entry of BuildConfig.Targets. Variant is the ARM
+// version (without v) of arm, or the value of the goarch's variant list.
+type Platform struct {
+	Goos    string
+	Goarch  string
+	Variant string
+}
+
+var (
+	platformName = regexp.MustCompile(`^[a-z0-9]+$`)
+	armVersion   = regexp.MustCompile(`^[5-7]$`)
+)
+
+// ParsePlatform parses a targets entry: goos/goarch or goos/goarch/variant,
+// where the variant of arm is v5, v6 or v7 and that of amd64, arm64, mips,
+// mipsle and riscv64 a value of goamd64, goarm64, gomips or goriscv64.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || !platformName.MatchString(parts[0]) || !platformName.MatchString(parts[1]) {
+		return Platform{}, fmt.Errorf("%q must be goos/goarch or goos/goarch/variant, e.g. linux/arm/v7", s)
+	}
+	p := Platform{Goos: parts[0], Goarch: parts[1]}
+	if len(parts) == 2 {
+		return p, nil
+	}
+	p.Variant = parts[2]
+	if p.Goarch == "arm" {
+		p.Variant = strings.TrimPrefix(p.Variant, "v")
+		if !armVersion.MatchString(p.Variant) {
+			return Platform{}, fmt.Errorf("%q: the arm version must be v5, v6 or v7", s)
+		}
+		return p, nil
+	}
+	for _, v := range archVariants {
+		if slices.Contains(v.goarch, p.Goarch) {
+			if !v.valid.MatchString(p.Variant) {
+				return Platform{}, fmt.Errorf("%q: %q is not a valid %s value", s, p.Variant, v.env)
+			}
+			return p, nil
+		}
+	}
+	return Platform{}, fmt.Errorf("%q: %s has no variants", s, p.Goarch)
+}
+
+// Platforms returns the parsed Targets, skipping entries Validate rejects.
+func (b *BuildConfig) Platforms() []Platform {
+	platforms := make([]Platform, 0, len(b.Targets))
+	for _, s := range b.Targets {
+		if p, err := ParsePlatform(s); err == nil {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// buildsGoos reports whether the build has targets of goos.
+func (b *BuildConfig) buildsGoos(goos string) bool {
+	if len(b.Targets) > 0 {
+		return slices.ContainsFunc(b.Platforms(), func(p Platform) bool { return p.Goos == goos })
+	}
+	return slices.Contains(b.Goos, goos)
+}
+
+// validateTargets checks Targets and that no list it replaces is set.
+func (b *BuildConfig) validateTargets() error {
+	if len(b.Goos) > 0 {
+		return fmt.Errorf("targets and goos are mutually exclusive")
+	}
+	if len(b.Goarch) > 0 {
+		return fmt.Errorf("targets and goarch are mutually exclusive")
+	}
+	for _, v := range archVariants {
+		if len(v.list(b)) > 0 {
+			return fmt.Errorf("targets and %s are mutually exclusive; put the variant in the entry, e.g. linux/amd64/v3", v.key)
+		}
+	}
+	seen := make(map[Platform]bool)
+	for i, s := range b.Targets {
+		p, err := ParsePlatform(s)
+		if err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if seen[p] {
+			return fmt.Errorf("targets[%d]: %q is listed twice", i, s)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// CGOConfig builds targets with cgo and their own C toolchain.
+type CGOConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Strict fails the build when a target has no toolchain; otherwise
+	// such targets are built with CGO_ENABLED=0.
+	Strict bool `yaml:"strict,omitempty"`
+	// Targets are keyed by goos/goarch, or goos/arm/armN for one ARM
+	// version, as gcx build --list-targets prints them.
+	Targets map[string]CToolchain `yaml:"targets,omitempty"`
+}
+
+// CToolchain is the C toolchain of a cgo target.
+type CToolchain struct {
+	// CC is the C compiler, e.g. "zig cc -target x86_64-linux-musl".
+	CC  string `yaml:"cc,omitempty"`
+	CXX string `yaml:"cxx,omitempty"`
+	// CFlags and LDFlags are set as CGO_CFLAGS and CGO_LDFLAGS.
+	CFlags  string `yaml:"cflags,omitempty"`
+	LDFlags string `yaml:"ldflags,omitempty"`
+}
+
+// Toolchain returns the toolchain of the target goos/goarch/goarm: the
+// entry of its ARM version, else the one of goos/goarch.
+func (c *CGOConfig) Toolchain(goos, goarch, goarm string) (CToolchain, bool) {
+	if goarm != "" {
+		if tc, ok := c.Targets[goos+"/"+goarch+"/arm"+goarm]; ok {
+			return tc, true
+		}
+	}
+	tc, ok := c.Targets[goos+"/"+goarch]
+	return tc, ok
+}
+
+// Validate checks the target keys of the cgo section.
+func (c *CGOConfig) Validate() error {
+	for _, key := range slices.Sorted(maps.Keys(c.Targets)) {
+		parts := strings.Split(key, "/")
+		valid := len(parts) == 2 && parts[0] != "" && parts[1] != ""
+		if len(parts) == 3 {
+			goarm, ok := strings.CutPrefix(parts[2], "arm")
+			valid = parts[0] != "" && parts[1] == "arm" && ok && goarm != ""
+		}
+		if !valid {
+			return fmt.Errorf("targets: %q must be goos/goarch or goos/arm/armN", key)
+		}
+	}
+	return nil
+}
+
+// Coverage modes of go build -covermode.
+const (
+	CovermodeSet    = "set"
+	CovermodeCount  = "count"
+	CovermodeAtomic = "atomic"
+)
+
+// CoverageConfig builds binaries instrumented with go build -cover (Go
+// 1.20+). They write coverage data to the directory in GOCOVERDIR when
+// they exit, for go tool covdata to merge.
+type CoverageConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Covermode is set, count or atomic (default: go build's, set).
+	Covermode string `yaml:"covermode,omitempty"`
+}
+
+// Flags returns the go build flags of the coverage settings.
+func (c *CoverageConfig) Flags() []string {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	flags := []string{"-cover"}
+	if c.Covermode != "" {
+		flags = append(flags, "-covermode="+c.Covermode)
+	}
+	return flags
+}
+
+// Validate checks the coverage mode.
+func (c *CoverageConfig) Validate() error {
+	switch c.Covermode {
+	case "", CovermodeSet, CovermodeCount, CovermodeAtomic:
+		return nil
+	default:
+		return fmt.Errorf("covermode must be set, count or atomic, got %q", c.Covermode)
+	}
+}
+
+// setsEnv reports whether env, in KEY=value form, sets name.
+func setsEnv(env []string, name string) bool {
+	return slices.ContainsFunc(env, func(kv string) bool { return strings.HasPrefix(kv, name+"=") })
+}
+
+// TargetMatch matches build targets; empty fields match any value.
+type TargetMatch struct {
+	Goos   string `yaml:"goos,omitempty"`
+	Goarch string `yaml:"goarch,omitempty"`
+	Goarm  string `yaml:"goarm,omitempty"`
+}
+
+// Matches reports whether the target goos/goarch/goarm matches m.
+func (m TargetMatch) Matches(goos, goarch, goarm string) bool {
+	return (m.Goos == "" || m.Goos == goos) &&
+		(m.Goarch == "" || m.Goarch == goarch) &&
+		(m.Goarm == "" || m.Goarm == goarm)
+}
+
+// Override merge modes.
+const (
+	MergeAppend  = "append"
+	MergeReplace = "replace"
+)
+
+// OverrideConfig changes the go build settings of the targets it matches.
+type OverrideConfig struct {
+	TargetMatch `yaml:",inline"`
+	// Merge is append (default), adding the entries after those of the
+	// build, or replace, using them instead. It applies to each of flags,
+	// ldflags, gcflags, asmflags and env that the override sets.
+	Merge    string   `yaml:"merge,omitempty"`
+	Flags    []string `yaml:"flags,omitempty"`
+	Ldflags  []string `yaml:"ldflags,omitempty"`
+	Gcflags  []string `yaml:"gcflags,omitempty"`
+	Asmflags []string `yaml:"asmflags,omitempty"`
+	Env      []string `yaml:"env,omitempty"`
+	// Buildmode replaces the build's buildmode when set.
+	Buildmode string `yaml:"buildmode,omitempty"`
+}
+
+// Buildmodes accepted by go build -buildmode.
+var buildmodes = []string{"default", "exe", "pie", "c-shared", "c-archive", "archive", "shared", "plugin"}
+
+// validateBuildmode checks that mode, when set, is a go build buildmode.
+func validateBuildmode(mode string) error {
+	if mode != "" && !slices.Contains(buildmodes, mode) {
+		return fmt.Errorf("buildmode must be one of %s, got %q", strings.Join(buildmodes, ", "), mode)
+	}
+	return nil
+}
+
+// hasFlag reports whether go build flags set one of the named flags.
+func hasFlag(flags []string, names ...string) bool {
+	for _, f := range flags {
+		for field := range strings.FieldsSeq(f) {
+			name, _, _ := strings.Cut(strings.TrimLeft(field, "-"), "=")
+			if strings.HasPrefix(field, "-") && slices.Contains(names, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks the match and merge mode of an override.
+func (o *OverrideConfig) Validate() error {
+	if o.TargetMatch == (TargetMatch{}) {
+		return fmt.Errorf("at least one of goos, goarch and goarm is required")
+	}
+	switch o.Merge {
+	case "", MergeAppend, MergeReplace:
+	default:
+		return fmt.Errorf("merge must be append or replace, got %q", o.Merge)
+	}
+	return validateBuildmode(o.Buildmode)
+}
+
+// GitAuthConfig is a token for private module downloads over https. It
+// is given to hook and go build subprocesses through a temporary .netrc
+// and git credential store, which are removed after the build.
+type GitAuthConfig struct {
+	// TokenEnv names the environment variable holding the token.
+	TokenEnv string `yaml:"token_env"`
+	// Username is sent with the token (default: x-access-token, which
+	// GitHub and Gitea accept; GitLab expects oauth2).
+	Username string `yaml:"username,omitempty"`
+	// Hosts receive the token, e.g. github.com.
+	Hosts []string `yaml:"hosts"`
+}
+
+// User returns the username sent with the token.
+func (g *GitAuthConfig) User() string {
+	if g.Username == "" {
+		return "x-access-token"
+	}
+	return g.Username
+}
+
+// Validate checks GitAuthConfig for a token variable and plain host names.
+func (g *GitAuthConfig) Validate() error {
+	if g.TokenEnv == "" {
+		return fmt.Errorf("token_env is required")
+	}
+	if len(g.Hosts) == 0 {
+		return fmt.Errorf("at least one host is required")
+	}
+	for _, h := range g.Hosts {
+		if h == "" || strings.ContainsAny(h, "/@: \t") {
+			return fmt.Errorf("host %q must be a plain host name, e.g. github.com", h)
+		}
+	}
+	return nil
+}
+
+// EmbedChangelogConfig names the variable the changelog between the
+// previous and the current tag is embedded in.
+type EmbedChangelogConfig struct {
+	// Var is the qualified string variable, e.g. "main.changelog" or
+	// "github.com/acme/app/internal/version.Changelog".
+	Var string `yaml:"var"`
+}
+
+// Split returns the import path and the name of Var.
+func (e *EmbedChangelogConfig) Split() (importPath, name string) {
+	i := strings.LastIndex(e.Var, ".")
+	if i < 0 {
+		return "", e.Var
+	}
+	return e.Var[:i], e.Var[i+1:]
+}
+
+var identRegex = regexp.MustCompile(`^[\p{L}_][\p{L}\p{Nd}_]*$`)
+
+// Validate checks that Var is a qualified variable name.
+func (e *EmbedChangelogConfig) Validate() error {
+	importPath, name := e.Split()
+	if importPath == "" || strings.HasSuffix(importPath, "/") || !identRegex.MatchString(name) {
+		return fmt.Errorf("var %q must be a qualified variable such as main.changelog", e.Var)
+	}
+	return nil
+}
+
+// UPXConfig compresses the binaries of a build with upx.
+type UPXConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Level is the compression level from 1 (fastest) to 9 (best); 0 uses
+	// the upx default.
+	Level int `yaml:"level,omitempty"`
+	// Targets limits compression to these goos/goarch targets, which may
+	// be patterns such as "linux/*".
+	Targets []string `yaml:"targets,omitempty"`
+	// Exclude skips these targets, e.g. darwin/arm64, where upx output
+	// does not run.
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// Applies reports whether binaries of goos/goarch are compressed.
+func (u *UPXConfig) Applies(goos, goarch string) bool {
+	if !u.Enabled {
+		return false
+	}
+	target := goos + "/" + goarch
+	matches := func(patterns []string) bool {
+		return slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(p, target)
+			return ok
+		})
+	}
+	if len(u.Targets) > 0 && !matches(u.Targets) {
+		return false
+	}
+	return !matches(u.Exclude)
+}
+
+// Validate checks the level and the target patterns.
+func (u *UPXConfig) Validate() error {
+	if u.Level < 0 || u.Level > 9 {
+		return fmt.Errorf("level %d must be between 1 and 9", u.Level)
+	}
+	for _, p := range slices.Concat(u.Targets, u.Exclude) {
+		if _, err := path.Match(p, ""); err != nil || strings.Count(p, "/") != 1 {
+			return fmt.Errorf("target %q must be a goos/goarch pattern", p)
+		}
+	}
+	return nil
+}
+
+// PrebuiltConfig locates binaries built outside of gcx.
+type PrebuiltConfig struct {
+	// PathTemplate is the binary of one target, e.g.
+	// "./bin/sidecar_{{.Os}}_{{.Arch}}". It supports the archive
+	// name_template fields and {{.Arm}}.
+	PathTemplate string `yaml:"path_template"`
+}
+
+// ArchiveConfig defines how built binaries are archived.
+type ArchiveConfig struct {
+	// Formats are archive formats, or binary to copy the bare binary
+	// under the rendered name.
+	Formats      []string `yaml:"formats,omitempty"`
+	NameTemplate string   `yaml:"name_template,omitempty"`
+	// CompressionLevel is the gzip level (1-9) of tar.gz archives and the
+	// zstd level (1-22) of tar.zst archives.
+	CompressionLevel int `yaml:"compression_level,omitempty"`
+	// Files are extra files copied into every archive next to the
+	// binary, e.g. LICENSE* or completions/*.
+	Files []ArchiveFile `yaml:"files,omitempty"`
+	// Strict fails the build when a files glob matches nothing instead
+	// of logging a warning.
+	Strict bool `yaml:"strict,omitempty"`
+	// Reproducible normalizes entry times and modes, so the same sources
+	// give byte-identical archives, and records the normalized header
+	// values in the archive contents of artifacts.json.
+	Reproducible bool `yaml:"reproducible,omitempty"`
+	// VerifyContents checks that the executables in every archive, and
+	// bare binaries, are built for the platform of the archive.
+	VerifyContents bool `yaml:"verify_contents,omitempty"`
+	// Builds limits the archive config to the artifacts of the builds
+	// with these ids. Empty means all builds.
+	Builds []string `yaml:"builds,omitempty"`
+}
+
+// Applies reports whether the archive config archives a directory with
+// the binaries of the builds with ids: its builds filter is empty or names
+// one of them.
+func (a ArchiveConfig) Applies(ids []string) bool {
+	return len(a.Builds) == 0 || slices.ContainsFunc(ids, func(id string) bool { return slices.Contains(a.Builds, id) })
+}
+
+// ArchiveFile is a glob of extra archive files. A plain string in YAML is
+// the glob alone.
+type ArchiveFile struct {
+	// Src is a glob relative to the config directory. Matched
+	// directories are added recursively.
+	Src string `yaml:"src"`
+	// Dst is the directory inside the archive the matches are copied
+	// to. Matches keep their path relative to the config directory when
+	// it is empty.
+	Dst string `yaml:"dst,omitempty"`
+}
+
+// archiveFile is the mapping form of ArchiveFile.
+type archiveFile ArchiveFile
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (f *ArchiveFile) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*f = ArchiveFile{Src: n.Value}
+		return nil
+	}
+	return n.Decode((*archiveFile)(f))
+}
+
+// MarshalYAML implements yaml.Marshaler. Files without dst are written as
+// plain strings.
+func (f ArchiveFile) MarshalYAML() (any, error) {
+	if f.Dst == "" {
+		return f.Src, nil
+	}
+	return archiveFile(f), nil
+}
+
+// Validate checks that f is a valid glob with a relative dst.
+func (f *ArchiveFile) Validate() error {
+	if f.Src == "" {
+		return fmt.Errorf("src must not be empty")
+	}
+	if _, err := filepath.Match(f.Src, ""); err != nil {
+		return fmt.Errorf("src %q: %w", f.Src, err)
+	}
+	if f.Dst != "" {
+		if path.IsAbs(f.Dst) || !filepath.IsLocal(filepath.FromSlash(f.Dst)) {
+			return fmt.Errorf("dst %q must be a relative path inside the archive", f.Dst)
+		}
+	}
+	return nil
+}
+
+// ChecksumConfig selects the checksums files written to out_dir.
+type ChecksumConfig struct {
+	// Algorithm is one algorithm or a list of them; each gets its own
+	// checksums file when more than one is set.
+	Algorithm configtypes.StringList `yaml:"algorithm,omitempty"`
+	// Format is the line format: gnu ("<hex>  <name>", the default) or bsd
+	// ("SHA256 (<name>) = <hex>").
+	Format string `yaml:"format,omitempty"`
+	// PathPrefix is a template of the directory names are recorded under,
+	// e.g. {{.Version}} when artifacts are served from a version
+	// subdirectory and checked from its parent.
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+}
+
+// Enabled reports whether checksums files are written without signs: any
+// field of the checksum block is set.
+func (c ChecksumConfig) Enabled() bool {
+	return len(c.Algorithm) > 0 || c.Format != "" || c.PathPrefix != ""
+}
+
+// Algorithms returns the configured algorithms, sha256 by default.
+func (c ChecksumConfig) Algorithms() []string {
+	if len(c.Algorithm) == 0 {
+		return []string{"sha256"}
+	}
+	return c.Algorithm
+}
+
+// SignConfig defines how release files are signed.
+type SignConfig struct {
+	// Provider is the signing tool: "ssh" (ssh-keygen -Y sign) signs the
+	// checksums files, "cosign" (cosign sign-blob) also signs every archive.
+	Provider string `yaml:"provider"`
+	// KeyPath is the private key to sign with. When empty, ssh uses the
+	// first key of the running ssh-agent and cosign signs keyless through
+	// the OIDC identity of the CI job.
+	KeyPath string `yaml:"key_path,omitempty"`
+	// KeyEnv names the environment variable holding the key: the private
+	// key itself (PEM or OpenSSH format) or the path of the key file.
+	KeyEnv string `yaml:"key_env,omitempty"`
+	// Identity is the principal written to the allowed_signers snippet
+	// (default: gcx).
+	Identity string `yaml:"identity,omitempty"`
+}
+
+// SBOM document formats.
+const (
+	SBOMCycloneDX = "cyclonedx"
+	SBOMSPDX      = "spdx"
+)
+
+// SBOMConfig generates a software bill of materials for every release
+// artifact by running an external tool such as syft.
+type SBOMConfig struct {
+	// Format is the document format: cyclonedx (default) or spdx. It
+	// selects the default args and is available as {{.Format}}.
+	Format string `yaml:"format,omitempty"`
+	// Cmd is the SBOM tool (default: syft).
+	Cmd string `yaml:"cmd,omitempty"`
+	// Args are templates with {{.Artifact}}, the path of the artifact, and
+	// {{.Document}}, the <artifact>.sbom.json the tool must write.
+	Args []string `yaml:"args,omitempty"`
+}
+
+// DocumentFormat returns the document format, cyclonedx by default.
+func (s *SBOMConfig) DocumentFormat() string {
+	if s.Format == "" {
+		return SBOMCycloneDX
+	}
+	return s.Format
+}
+
+// Command returns the tool and argument templates, defaulting to
+// syft scan writing the JSON document of the format.
+func (s *SBOMConfig) Command() (string, []string) {
+	cmd := s.Cmd
+	if cmd == "" {
+		cmd = "syft"
+	}
+	args := s.Args
+	if len(args) == 0 {
+		args = []string{"scan", "{{.Artifact}}", "--output", "{{.Format}}-json={{.Document}}"}
+	}
+	return cmd, args
+}
+
+// GeneratedFileConfig renders an extra release file, such as an install
+// script, from a template after archiving.
+type GeneratedFileConfig struct {
+	// NameTemplate is the file name in out_dir; it supports {{.Version}}.
+	NameTemplate string `yaml:"name_template"`
+	// ContentTemplate is an inline template for the file content.
+	ContentTemplate string `yaml:"content_template,omitempty"`
+	// Source is a template file used instead of ContentTemplate.
+	Source string `yaml:"source,omitempty"`
+}
+
+// Compare modes of embed_checks.
+const (
+	// CompareAuto compares mtimes, or the stamp when they come from a git
+	// checkout.
+	CompareAuto  = "auto"
+	CompareMtime = "mtime"
+	// CompareHash always compares the sources with the stamp.
+	CompareHash = "hash"
+)
+
+// EmbedCheckConfig guards an embedded directory, e.g. a web UI built by a
+// frontend toolchain, against being released older than its sources.
+type EmbedCheckConfig struct {
+	// Path is the embedded file or directory, relative to the config
+	// directory.
+	Path string `yaml:"path"`
+	// NewerThan lists the source files, directories or globs ("**"
+	// matches any number of directories) Path must not be older than.
+	NewerThan configtypes.StringList `yaml:"newer_than"`
+	// Compare is auto (default), mtime or hash.
+	Compare string `yaml:"compare,omitempty"`
+	// Stamp is a file recording the SHA-256 of every source, written when
+	// the mtimes show Path is fresh; hash comparisons check the sources
+	// against it.
+	Stamp string `yaml:"stamp,omitempty"`
+}
+
+// Validate checks EmbedCheckConfig for a path, sources and a stamp when
+// hashing.
+func (e *EmbedCheckConfig) Validate() error {
+	if e.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if len(e.NewerThan) == 0 {
+		return fmt.Errorf("newer_than is required")
+	}
+	for _, pattern := range e.NewerThan {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("newer_than %q: %w", pattern, err)
+		}
+	}
+	switch e.Compare {
+	case "", CompareAuto, CompareMtime:
+	case CompareHash:
+		if e.Stamp == "" {
+			return fmt.Errorf("stamp is required with compare: hash")
+		}
+	default:
+		return fmt.Errorf("compare must be auto, mtime or hash, got %q", e.Compare)
+	}
+	return nil
+}
+
+// PublishConfig holds settings for the whole publish stage.
+type PublishConfig struct {
+	// Timeout bounds the entire publish stage across all destinations.
+	Timeout configtypes.Duration `yaml:"timeout,omitempty"`
+}
+
+// BlobConfig defines a publish destination (S3 or SSH).
+type BlobConfig struct {
+	Provider string `yaml:"provider"`
+	Name     string `yaml:"name"`
+	// S3 fields
+	Bucket   string `yaml:"bucket,omitempty"`
+	Region   string `yaml:"region,omitempty"`
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Metadata is the user metadata (x-amz-meta-*) of each object; values
+	// are templates with ObjectHeaderData.
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	// ContentDispositionTemplate renders the Content-Disposition of each
+	// object, e.g. 'attachment; filename="{{.Name}}"'.
+	ContentDispositionTemplate string `yaml:"content_disposition_template,omitempty"`
+	// SSH fields
+	Server                string           `yaml:"server,omitempty"`
+	User                  string           `yaml:"user,omitempty"`
+	KeyPath               string           `yaml:"key_path,omitempty"`
+	KeyRaw                string           `yaml:"key_raw,omitempty"`
+	InsecureIgnoreHostKey bool             `yaml:"insecure_ignore_host_key,omitempty"`
+	SSHBackend            string           `yaml:"ssh_backend,omitempty"`
+	SFTPConcurrency       int              `yaml:"sftp_concurrency,omitempty"`
+	SFTPBufferSize        configtypes.Size `yaml:"sftp_buffer_size,omitempty"`
+	SFTPFallback          bool             `yaml:"sftp_fallback,omitempty"`
+	// Common
+	Directory string `yaml:"directory"`
+	// ObjectTemplate renders the remote file name of each uploaded file,
+	// e.g. "{{.Name}}" or "{{.ShortSha256}}-{{.Name}}". Defaults to the
+	// local file name.
+	ObjectTemplate string `yaml:"object_template,omitempty"`
+	MaxAttempts    int    `yaml:"max_attempts,omitempty"`
+	// Enabled is a template rendering to true or false, e.g.
+	// '{{eq .Channel "stable"}}'. Empty means enabled.
+	Enabled string `yaml:"enabled,omitempty"`
+	// PublishMetadata also uploads gcx metadata such as artifacts.json,
+	// e.g. to an internal audit bucket.
+	PublishMetadata bool `yaml:"publish_metadata,omitempty"`
+	// Builds limits the upload to the artifacts of the builds with these
+	// ids, as recorded in artifacts.json. Empty means all files.
+	Builds []string `yaml:"builds,omitempty"`
+	// Types limits the upload to the artifacts of these types, e.g.
+	// archive and checksum, as recorded in artifacts.json. Empty means all
+	// types.
+	Types []string `yaml:"types,omitempty"`
+	// MaxObjectSize is the largest file the destination accepts; Oversize
+	// decides what happens to larger files.
+	MaxObjectSize configtypes.Size `yaml:"max_object_size,omitempty"`
+	// Oversize is fail (default), skip or split.
+	Oversize string `yaml:"oversize,omitempty"`
+	// Dedupe is plain (default), uploading every file of every version,
+	// or content_addressed, storing each content once under
+	// cas/<sha256> and server-side copying it to the versioned path.
+	Dedupe string `yaml:"dedupe,omitempty"`
+}
+
+// Dedupe modes of BlobConfig.
+const (
+	DedupePlain            = "plain"
+	DedupeContentAddressed = "content_addressed"
+)
+
+// Oversize policies of BlobConfig.
+const (
+	OversizeFail  = "fail"
+	OversizeSkip  = "skip"
+	OversizeSplit = "split"
+)
+
+// DeployConfig defines a deployment target.
+type DeployConfig struct {
+	Name     string `yaml:"name"`
+	Provider string `yaml:"provider"`
+	// SSH fields
+	Server                string    `yaml:"server,omitempty"`
+	User                  string    `yaml:"user,omitempty"`
+	KeyPath               string    `yaml:"key_path,omitempty"`
+	KeyRaw                string    `yaml:"key_raw,omitempty"`
+	InsecureIgnoreHostKey bool      `yaml:"insecure_ignore_host_key,omitempty"`
+	SSHBackend            string    `yaml:"ssh_backend,omitempty"`
+	Commands              []Command `yaml:"commands,omitempty"`
+	// Steps replaces commands when the deploy also downloads artifacts on
+	// the target host.
+	Steps []DeployStep `yaml:"steps,omitempty"`
+	// RequestPTY runs every command on a pseudo-terminal, for commands
+	// such as sudo with requiretty that refuse to run without one.
+	RequestPTY    bool     `yaml:"request_pty,omitempty"`
+	OnlyIfChanged []string `yaml:"only_if_changed,omitempty"`
+	// Enabled is a template rendering to true or false; see BlobConfig.
+	Enabled   string `yaml:"enabled,omitempty"`
+	TagPrefix string `yaml:"tag_prefix,omitempty"`
+	// Releases fields
+	BasePath     string   `yaml:"base_path,omitempty"`
+	Artifacts    []string `yaml:"artifacts,omitempty"`
+	Extract      bool     `yaml:"extract,omitempty"`
+	Shared       []string `yaml:"shared,omitempty"`
+	KeepReleases int      `yaml:"keep_releases,omitempty"`
+	// Coverdir is the remote directory exported as GOCOVERDIR to the
+	// commands once the deploy ships a coverage-instrumented artifact
+	// (releases default: base_path/shared/coverage).
+	Coverdir string `yaml:"coverdir,omitempty"`
+	// SkipUnchanged keeps the dest of upload and download steps that
+	// already has the artifact's SHA-256, and skips the run steps marked
+	// only_on_change when no file changed. gcx deploy --force disables it.
+	SkipUnchanged bool `yaml:"skip_unchanged,omitempty"`
+	// Approval must pass before any command runs.
+	Approval *ApprovalConfig `yaml:"approval,omitempty"`
+	// PostLogs tails a remote command after the commands succeeded.
+	PostLogs *PostLogsConfig `yaml:"post_logs,omitempty"`
+	// Alerts
+	Alerts AlertConfig `yaml:"alerts,omitempty"`
+}
+
+// PostLogsConfig streams the output of a remote command, e.g.
+// journalctl -fu myapp, into the deploy log once the deploy commands have
+// succeeded. A line matching error_pattern fails the deploy; the tail ends
+// without a failure after duration, when a line matches stop_pattern or
+// when the command exits.
+type PostLogsConfig struct {
+	Command string `yaml:"command"`
+	// Duration bounds the tail (default: 1m).
+	Duration configtypes.Duration `yaml:"duration,omitempty"`
+	// StopPattern is a regular expression ending the tail successfully.
+	StopPattern string `yaml:"stop_pattern,omitempty"`
+	// ErrorPattern is a regular expression failing the deploy, which rolls
+	// back a releases deploy and sends the failure alert.
+	ErrorPattern string `yaml:"error_pattern,omitempty"`
+}
+
+// Validate checks that PostLogsConfig has a command and valid patterns.
+func (p *PostLogsConfig) Validate() error {
+	if p.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	if p.Duration < 0 {
+		return fmt.Errorf("duration must not be negative")
+	}
+	if _, err := regexp.Compile(p.StopPattern); err != nil {
+		return fmt.Errorf("stop_pattern: %w", err)
+	}
+	if _, err := regexp.Compile(p.ErrorPattern); err != nil {
+		return fmt.Errorf("error_pattern: %w", err)
+	}
+	return nil
+}
+
+// ApprovalConfig is an approval gate of a deploy: a local command that
+// exits 0 when the deploy is approved, or a URL polled until it reports
+// approved or rejected. Both are templates with .Name, .Version and
+// .Channel.
+type ApprovalConfig struct {
+	Command string `yaml:"command,omitempty"`
+	// URL must answer {"status": "approved"}, {"status": "rejected"} or
+	// any other status while the decision is pending.
+	URL string `yaml:"url,omitempty"`
+	// TokenEnv names a variable whose value is sent as a bearer token to URL.
+	TokenEnv string `yaml:"token_env,omitempty"`
+	// Timeout bounds the wait for approval (default: 30m).
+	Timeout configtypes.Duration `yaml:"timeout,omitempty"`
+	// PollInterval is the time between requests to URL (default: 30s).
+	PollInterval configtypes.Duration `yaml:"poll_interval,omitempty"`
+	// AllowOverride lets gcx deploy --yes skip the gate.
+	AllowOverride bool `yaml:"allow_override,omitempty"`
+}
+
+// Validate checks that ApprovalConfig has exactly one of command and url.
+func (a *ApprovalConfig) Validate() error {
+	if (a.Command == "") == (a.URL == "") {
+		return fmt.Errorf("exactly one of command and url is required")
+	}
+	if a.URL != "" && !strings.HasPrefix(a.URL, "https://") && !strings.HasPrefix(a.URL, "http://") {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if a.TokenEnv != "" && a.URL == "" {
+		return fmt.Errorf("token_env requires url")
+	}
+	if a.Timeout < 0 || a.PollInterval < 0 {
+		return fmt.Errorf("timeout and poll_interval must not be negative")
+	}
+	return nil
+}
+
+// Command is a deploy command, or an include of a centrally maintained
+// command list written as {include_url: https://..., sha256: ...}.
+type Command struct {
+	Run string
+	// IncludeURL is a YAML list of commands spliced in by ResolveIncludes.
+	IncludeURL string
+	// SHA256 pins the content of IncludeURL.
+	SHA256 string
+}
+
+// commandInclude decodes includes without the Command methods.
+type commandInclude struct {
+	IncludeURL string `yaml:"include_url"`
+	SHA256     string `yaml:"sha256"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Command) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*c = Command{Run: n.Value}
+		return nil
+	}
+	var inc commandInclude
+	if err := n.Decode(&inc); err != nil {
+		return err
+	}
+	*c = Command{IncludeURL: inc.IncludeURL, SHA256: inc.SHA256}
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. Commands are written as plain
+// strings.
+func (c Command) MarshalYAML() (any, error) {
+	if c.IncludeURL == "" {
+		return c.Run, nil
+	}
+	return commandInclude{IncludeURL: c.IncludeURL, SHA256: c.SHA256}, nil
+}
+
+// Validate checks that c is a command or a hash-pinned http(s) include.
+func (c *Command) Validate() error {
+	if c.IncludeURL == "" {
+		if c.Run == "" {
+			return fmt.Errorf("command must not be empty")
+		}
+		return nil
+	}
+	u, err := url.Parse(c.IncludeURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("include_url %q must be an http(s) URL", c.IncludeURL)
+	}
+	if !sha256Regex.MatchString(c.SHA256) {
+		return fmt.Errorf("include_url requires sha256, the lowercase hex SHA-256 of the list")
+	}
+	return nil
+}
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// DeployStep is a remote command, a download or an upload run by a deploy.
+// Exactly one of Run, Download and Upload is set.
+type DeployStep struct {
+	Run      string        `yaml:"run,omitempty"`
+	Download *DownloadStep `yaml:"download,omitempty"`
+	Upload   *UploadStep   `yaml:"upload,omitempty"`
+	// RequestPTY runs this command on a pseudo-terminal.
+	RequestPTY bool `yaml:"request_pty,omitempty"`
+	// OnlyOnChange skips this command, e.g. a restart, when skip_unchanged
+	// found every earlier upload and download up to date.
+	OnlyOnChange bool `yaml:"only_on_change,omitempty"`
+}
+
+// DownloadStep makes the target host fetch a file over HTTP(S) and verify
+// its SHA-256 before the next step runs. All fields are templates with
+// {{.Version}}, {{.ArtifactName "glob"}} and {{.ArtifactSha256 "glob"}},
+// which look up the artifact in the build manifest.
+type DownloadStep struct {
+	URLTemplate string `yaml:"url_template"`
+	Dest        string `yaml:"dest"`
+	SHA256      string `yaml:"sha256"`
+}
+
+// UploadStep copies a build artifact from out_dir to the target host over
+// SSH. With extract, only one file of an archive artifact is uploaded.
+type UploadStep struct {
+	// Artifact is a glob matching the name of exactly one artifact in
+	// the build manifest, e.g. "*_linux_amd64.tar.gz".
+	Artifact string `yaml:"artifact"`
+	// Dest is the remote file path, a template with {{.Version}} and
+	// {{.Channel}}; parent directories are created.
+	Dest string `yaml:"dest"`
+	// Extract uploads a single entry of the archive instead of the archive.
+	Extract bool `yaml:"extract,omitempty"`
+	// Entry selects the file to extract by its path in the archive or its
+	// base name (default: the only executable file).
+	Entry string `yaml:"entry,omitempty"`
+}
+
+// DeploySteps returns the steps of the deploy, with commands as run steps.
+// With request_pty on the deploy every run step requests a PTY. Includes
+// must have been resolved with ResolveIncludes.
+func (d *DeployConfig) DeploySteps() []DeployStep {
+	steps := slices.Clone(d.Steps)
+	if len(steps) == 0 {
+		steps = make([]DeployStep, 0, len(d.Commands))
+		for _, cmd := range d.Commands {
+			steps = append(steps, DeployStep{Run: cmd.Run})
+		}
+	}
+	for i := range steps {
+		if steps[i].Run != "" && d.RequestPTY {
+			steps[i].RequestPTY = true
+		}
+	}
+	return steps
+}
+
+// ResolveIncludes splices the commands listed by include_url entries into
+// the deploy commands. Lists are verified against their sha256 and cached
+// by hash in cacheDir, so later runs need no network.
+func (d *DeployConfig) ResolveIncludes(ctx context.Context, cacheDir string) error {
+	if !slices.ContainsFunc(d.Commands, func(c Command) bool { return c.IncludeURL != "" }) {
+		return nil
+	}
+	cmds := make([]Command, 0, len(d.Commands))
+	for i, cmd := range d.Commands {
+		if cmd.IncludeURL == "" {
+			cmds = append(cmds, cmd)
+			continue
+		}
+		list, err := include.Commands(ctx, cmd.IncludeURL, cmd.SHA256, cacheDir)
+		if err != nil {
+			return fmt.Errorf("commands[%d]: %w", i, err)
+		}
+		for _, run := range list {
+			cmds = append(cmds, Command{Run: run})
+		}
+	}
+	d.Commands = cmds
+	return nil
+}
+
+// ResolveIncludes resolves the includes of every deploy, caching them in
+// include.CacheDir below the config directory.
+func (c *Config) ResolveIncludes(ctx context.Context) error {
+	cacheDir := filepath.Join(c.Dir, include.CacheDir)
+	for i := range c.Deploys {
+		if err := c.Deploys[i].ResolveIncludes(ctx, cacheDir); err != nil {
+			return fmt.Errorf("deploys[%d] %s: %w", i, c.Deploys[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// RunCommands returns the remote commands of the deploy, from commands or
+// the run steps.
+func (d *DeployConfig) RunCommands() []string {
+	var cmds []string
+	for _, step := range d.DeploySteps() {
+		if step.Run != "" {
+			cmds = append(cmds, step.Run)
+		}
+	}
+	return cmds
+}
+
+// DeployPolicyConfig restricts the remote commands deploys may run.
+type DeployPolicyConfig struct {
+	// Deny adds regular expressions to the built-in deny-list.
+	Deny []string `yaml:"deny,omitempty"`
+	// Allow, when set, requires every command to match one of its
+	// regular expressions.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// AlertConfig contains notification settings.
+type AlertConfig struct {
+	URLs []string `yaml:"urls,omitempty"`
+	// Enabled is a template rendering to true or false; see BlobConfig.
+	Enabled string `yaml:"enabled,omitempty"`
+	// DedupeWindow suppresses alerts with the same app, version and status
+	// as one already sent within the window.
+	DedupeWindow configtypes.Duration `yaml:"dedupe_window,omitempty"`
+	// Location is the IANA time zone schedule rules are evaluated in
+	// (default: the local time zone).
+	Location string `yaml:"location,omitempty"`
+	// Schedule routes alerts by time and status. The first matching rule
+	// wins; alerts no rule matches go to URLs.
+	Schedule []AlertRule `yaml:"schedule,omitempty"`
+}
+
+// AlertRule routes alerts sent during a weekly window.
+type AlertRule struct {
+	// Days are weekdays or ranges, e.g. "mon-fri" (default: every day).
+	Days []string `yaml:"days,omitempty"`
+	// Hours is a daily range such as "09:00-18:00" (default: the whole day).
+	Hours string `yaml:"hours,omitempty"`
+	// Status limits the rule to "success", "failed" or "not_approved"
+	// alerts.
+	Status []string `yaml:"status,omitempty"`
+	URLs   []string `yaml:"urls,omitempty"`
+	// Drop discards matching alerts instead of sending them.
+	Drop bool `yaml:"drop,omitempty"`
+	// Enabled is a template rendering to true or false; a disabled rule
+	// matches no alert, e.g. '{{eq .Channel "nightly"}}' routes only
+	// nightly alerts.
+	Enabled string `yaml:"enabled,omitempty"`
+}
+
+// Announcer types.
+const (
+	AnnouncerShoutrrr = "shoutrrr"
+	AnnouncerWebhook  = "webhook"
+	AnnouncerExec     = "exec"
+)
+
+// AnnounceConfig configures the announcers run once a release is
+// published to every destination.
+type AnnounceConfig struct {
+	// DownloadURL renders the link of each published artifact, e.g.
+	// "https://dl.example.com/{{.Version}}/{{.Object}}". It supports the
+	// object_template fields and {{.Object}}, the remote object name.
+	DownloadURL string `yaml:"download_url,omitempty"`
+	// Blob names the destination whose object_template names the linked
+	// objects (default: the only blob).
+	Blob       string            `yaml:"blob,omitempty"`
+	Announcers []AnnouncerConfig `yaml:"announcers,omitempty"`
+}
+
+// AnnouncerConfig is one announcement channel of a release.
+type AnnouncerConfig struct {
+	Name string `yaml:"name"`
+	// Type is shoutrrr, webhook or exec.
+	Type string `yaml:"type"`
+	// URLs are the shoutrrr service URLs of a shoutrrr announcer.
+	URLs []string `yaml:"urls,omitempty"`
+	// Template is the message of a shoutrrr announcer (default: version,
+	// duration, artifact links and changelog).
+	Template string `yaml:"template,omitempty"`
+	// URL receives the announcement as a JSON POST from a webhook announcer.
+	URL string `yaml:"url,omitempty"`
+	// Headers are added to the webhook request; values support {{.Env.NAME}}.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Command is run by an exec announcer via sh -c with the announcement
+	// as JSON on stdin.
+	Command string `yaml:"command,omitempty"`
+	// Enabled is a template rendering to true or false; see BlobConfig.
+	Enabled string `yaml:"enabled,omitempty"`
+}
+
+// Validate checks the announcers for unique names and the settings of
+// their type.
+func (a *AnnounceConfig) Validate() error {
+	names := make(map[string]bool)
+	for i, announcer := range a.Announcers {
+		if err := announcer.Validate(); err != nil {
+			return fmt.Errorf("announcers[%d]: %w", i, err)
+		}
+		if names[announcer.Name] {
+			return fmt.Errorf("announcers[%d]: duplicate name %q", i, announcer.Name)
+		}
+		names[announcer.Name] = true
+	}
+	return nil
+}
+
+// Validate checks that the announcer has a name and exactly the settings
+// of its type.
+func (a *AnnouncerConfig) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	var required string
+	var missing bool
+	switch a.Type {
+	case AnnouncerShoutrrr:
+		required, missing = "urls", len(a.URLs) == 0
+	case AnnouncerWebhook:
+		required, missing = "url", a.URL == ""
+		if u, err := url.Parse(a.URL); !missing && (err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "") {
+			return fmt.Errorf("url %q must be an http(s) URL", a.URL)
+		}
+	case AnnouncerExec:
+		required, missing = "command", a.Command == ""
+	default:
+		return fmt.Errorf("unsupported type %q: expected %s, %s or %s", a.Type, AnnouncerShoutrrr, AnnouncerWebhook, AnnouncerExec)
+	}
+	if missing {
+		return fmt.Errorf("%s is required for %s announcers", required, a.Type)
+	}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"urls", len(a.URLs) > 0 && a.Type != AnnouncerShoutrrr},
+		{"template", a.Template != "" && a.Type != AnnouncerShoutrrr},
+		{"url", a.URL != "" && a.Type != AnnouncerWebhook},
+		{"headers", len(a.Headers) > 0 && a.Type != AnnouncerWebhook},
+		{"command", a.Command != "" && a.Type != AnnouncerExec},
+	} {
+		if f.set {
+			return fmt.Errorf("%s is not supported by %s announcers", f.name, a.Type)
+		}
+	}
+	return nil
+}
+
+// IsEnabled evaluates the enabled expression of the announcer for rel.
+func (a *AnnouncerConfig) IsEnabled(rel ReleaseData) (bool, error) {
+	return enabled(a.Enabled, rel)
+}
+
+// Load reads and parses a YAML configuration file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config file: %w", configtypes.WithPath(data, err))
+	}
+	if cfg.OutDir == "" {
+		cfg.OutDir = "dist"
+	}
+	return &cfg, nil
+}
+
+// ResolvePaths makes relative out_dir, gc.cache_dir, key_path, source and
+// prebuilt.path_template values relative to dir, usually the directory of
+// the config file, and records dir so builds and hooks run there.
+func (c *Config) ResolvePaths(dir string) {
+	if dir == "" || filepath.Clean(dir) == "." {
+		return
+	}
+	c.Dir = dir
+	c.OutDir = resolvePath(dir, c.OutDir)
+	c.GC.CacheDir = resolvePath(dir, c.GC.CacheDir)
+	for i := range c.Blobs {
+		c.Blobs[i].KeyPath = resolvePath(dir, c.Blobs[i].KeyPath)
+	}
+	for i := range c.Deploys {
+		c.Deploys[i].KeyPath = resolvePath(dir, c.Deploys[i].KeyPath)
+	}
+	for i := range c.Signs {
+		c.Signs[i].KeyPath = resolvePath(dir, c.Signs[i].KeyPath)
+	}
+	for i := range c.Builds {
+		c.Builds[i].Dir = resolvePath(dir, c.Builds[i].Dir)
+		if c.Builds[i].Prebuilt != nil {
+			c.Builds[i].Prebuilt.PathTemplate = resolvePath(dir, c.Builds[i].Prebuilt.PathTemplate)
+		}
+	}
+	for i := range c.GeneratedFiles {
+		c.GeneratedFiles[i].Source = resolvePath(dir, c.GeneratedFiles[i].Source)
+	}
+}
+
+// resolvePath joins a relative p onto dir. Empty, absolute and
+// home-relative paths are returned unchanged.
+func resolvePath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "~") {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// OutputDir renders the out_dir template for the given git tag,
+// e.g. "dist/{{.Version}}" or "dist/{{.Channel}}/{{.Version}}".
+func (c *Config) OutputDir(tag string) (string, error) {
+	dir, err := tmpl.Process("out_dir", c.OutDir, c.Release(tag))
+	if err != nil {
+		return "", fmt.Errorf("process out_dir template: %w", err)
+	}
+	return dir, nil
+}
+
+// checkBuildIDs fails when a builds filter names an id no build has.
+func checkBuildIDs(filter []string, ids map[string]bool) error {
+	for _, id := range filter {
+		if !ids[id] {
+			return fmt.Errorf("builds: unknown build id %q", id)
+		}
+	}
+	return nil
+}
+
+// Validate checks the entire configuration for correctness.
+func (c *Config) Validate() error {
+	if len(c.Builds) == 0 {
+		return fmt.Errorf("at least one build configuration is required")
+	}
+	if c.Parallelism < 0 {
+		return fmt.Errorf("parallelism: must not be negative")
+	}
+	switch c.VersionFormat {
+	case "", VersionRaw, VersionWithV, VersionWithoutV:
+	default:
+		return fmt.Errorf("version_format: must be raw, with_v or without_v, got %q", c.VersionFormat)
+	}
+	switch c.StagingStrategy {
+	case "", StagingCopy, StagingHardlink, StagingMove:
+	default:
+		return fmt.Errorf("staging_strategy: must be copy, hardlink or move, got %q", c.StagingStrategy)
+	}
+	if c.GoVersion != "" {
+		if _, err := toolchain.ParseConstraint(c.GoVersion); err != nil {
+			return fmt.Errorf("go_version: %w", err)
+		}
+	}
+	if c.GitAuth != nil {
+		if err := c.GitAuth.Validate(); err != nil {
+			return fmt.Errorf("git_auth: %w", err)
+		}
+	}
+	if err := c.Before.Validate(); err != nil {
+		return fmt.Errorf("before: %w", err)
+	}
+	if err := c.After.Validate(); err != nil {
+		return fmt.Errorf("after: %w", err)
+	}
+	ids := make(map[string]bool)
+	instrumented := make(map[string]bool)
+	for i, b := range c.Builds {
+		if err := b.Validate(); err != nil {
+			return fmt.Errorf("builds[%d]: %w", i, err)
+		}
+		if b.Group != "" {
+			// One archive per group cannot be labeled both ways
+			if cover, ok := instrumented[b.Group]; ok && cover != b.Instrumented() {
+				return fmt.Errorf("builds[%d]: group %q mixes coverage-instrumented and normal builds", i, b.Group)
+			}
+			instrumented[b.Group] = b.Instrumented()
+		}
+		if b.ID != "" {
+			if ids[b.ID] {
+				return fmt.Errorf("builds[%d]: id %q is used by another build", i, b.ID)
+			}
+			ids[b.ID] = true
+		}
+	}
+	for i, blob := range c.Blobs {
+		if err := blob.Validate(); err != nil {
+			return fmt.Errorf("blobs[%d]: %w", i, err)
+		}
+		if err := checkBuildIDs(blob.Builds, ids); err != nil {
+			return fmt.Errorf("blobs[%d]: %w", i, err)
+		}
+	}
+	if err := c.Announce.Validate(); err != nil {
+		return fmt.Errorf("announce: %w", err)
+	}
+	if c.Announce.Blob != "" && !slices.ContainsFunc(c.Blobs, func(b BlobConfig) bool { return b.Name == c.Announce.Blob }) {
+		return fmt.Errorf("announce: blob %q does not name a blobs entry", c.Announce.Blob)
+	}
+	for i, deploy := range c.Deploys {
+		if err := deploy.Validate(); err != nil {
+			return fmt.Errorf("deploys[%d]: %w", i, err)
+		}
+	}
+	for i, archive := range c.Archives {
+		if err := archive.Validate(); err != nil {
+			return fmt.Errorf("archives[%d]: %w", i, err)
+		}
+		if err := checkBuildIDs(archive.Builds, ids); err != nil {
+			return fmt.Errorf("archives[%d]: %w", i, err)
+		}
+	}
+	for i, file := range c.GeneratedFiles {
+		if err := file.Validate(); err != nil {
+			return fmt.Errorf("generated_files[%d]: %w", i, err)
+		}
+	}
+	for i, check := range c.EmbedChecks {
+		if err := check.Validate(); err != nil {
+			return fmt.Errorf("embed_checks[%d]: %w", i, err)
+		}
+	}
+	if err := c.DeployPolicy.Validate(); err != nil {
+		return fmt.Errorf("deploy_policy: %w", err)
+	}
+	if err := c.Checksum.Validate(); err != nil {
+		return fmt.Errorf("checksum: %w", err)
+	}
+	if len(c.Signs) > 1 {
+		return fmt.Errorf("signs: only one entry is supported")
+	}
+	for i, sign := range c.Signs {
+		if err := sign.Validate(); err != nil {
+			return fmt.Errorf("signs[%d]: %w", i, err)
+		}
+	}
+	if len(c.SBOMs) > 1 {
+		return fmt.Errorf("sboms: only one entry is supported")
+	}
+	for i, sbom := range c.SBOMs {
+		if err := sbom.Validate(); err != nil {
+			return fmt.Errorf("sboms[%d]: %w", i, err)
+		}
+	}
+	if err := c.GC.Validate(); err != nil {
+		return fmt.Errorf("gc: %w", err)
+	}
+	if err := c.Changelog.Validate(); err != nil {
+		return fmt.Errorf("changelog: %w", err)
+	}
+	if err := c.ReleaseSettings.Validate(); err != nil {
+		return fmt.Errorf("release: %w", err)
+	}
+	return nil
+}
+
+// Validate checks SBOMConfig for a supported format.
+func (s *SBOMConfig) Validate() error {
+	if s.Format != "" && s.Format != SBOMCycloneDX && s.Format != SBOMSPDX {
+		return fmt.Errorf("unsupported sbom format %q: expected %s or %s", s.Format, SBOMCycloneDX, SBOMSPDX)
+	}
+	return nil
+}
+
+// Validate checks ChecksumConfig for supported, distinct algorithms, a
+// known format and a relative path_prefix.
+func (c *ChecksumConfig) Validate() error {
+	for i, alg := range c.Algorithm {
+		if !slices.Contains(checksum.Algorithms, alg) {
+			return fmt.Errorf("unsupported checksum algorithm %q: expected one of %s", alg, strings.Join(checksum.Algorithms, ", "))
+		}
+		if slices.Contains(c.Algorithm[:i], alg) {
+			return fmt.Errorf("checksum algorithm %q is listed twice", alg)
+		}
+	}
+	if c.Format != "" && !slices.Contains(checksums.Formats, c.Format) {
+		return fmt.Errorf("format must be one of %s, got %q", strings.Join(checksums.Formats, ", "), c.Format)
+	}
+	if _, err := c.Prefix(ReleaseData{Version: "v1.0.0", Channel: "stable", Tag: "v1.0.0"}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Prefix renders the path_prefix template of the release.
+func (c ChecksumConfig) Prefix(rel ReleaseData) (string, error) {
+	prefix, err := tmpl.Process("path_prefix", c.PathPrefix, rel)
+	if err != nil {
+		return "", fmt.Errorf("process path_prefix template: %w", err)
+	}
+	if prefix != "" && (path.IsAbs(prefix) || !filepath.IsLocal(filepath.FromSlash(prefix))) {
+		return "", fmt.Errorf("path_prefix %q must be a relative path", prefix)
+	}
+	return prefix, nil
+}
+
+// Validate checks SignConfig for a supported provider.
+func (s *SignConfig) Validate() error {
+	switch s.Provider {
+	case "ssh", "cosign":
+	default:
+		return fmt.Errorf("unsupported sign provider: %s", s.Provider)
+	}
+	if s.KeyPath != "" && s.KeyEnv != "" {
+		return fmt.Errorf("key_path and key_env are mutually exclusive")
+	}
+	if strings.ContainsAny(s.Identity, " \t\n") {
+		return fmt.Errorf("identity must not contain whitespace")
+	}
+	return nil
+}
+
+// Validate checks GeneratedFileConfig for a name and exactly one content source.
+func (g *GeneratedFileConfig) Validate() error {
+	if g.NameTemplate == "" {
+		return fmt.Errorf("name_template is required")
+	}
+	if (g.ContentTemplate == "") == (g.Source == "") {
+		return fmt.Errorf("exactly one of content_template or source is required")
+	}
+	return nil
 }
 
-// HooksConfig holds shell commands to execute before/after build.
-type HooksConfig struct {
-	Hooks []string `yaml:"hooks,omitempty"`
+// Validate checks GCConfig for invalid budgets.
+func (g *GCConfig) Validate() error {
+	if g.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative")
+	}
+	if g.CacheMaxSize < 0 {
+		return fmt.Errorf("cache_max_size must not be negative")
+	}
+	return nil
 }
 
-// BuildConfig defines a cross-compilation build target.
-type BuildConfig struct {
-	Main                  string   `yaml:"main"`
-	OutputName            string   `yaml:"output_name,omitempty"`
-	DisablePlatformSuffix bool     `yaml:"disable_platform_suffix,omitempty"`
-	Goos                  []string `yaml:"goos"`
-	Goarch                []string `yaml:"goarch"`
-	Goarm                 []string `yaml:"goarm,omitempty"`
-	Flags                 []string `yaml:"flags,omitempty"`
-	Ldflags               []string `yaml:"ldflags,omitempty"`
-	Env                   []string `yaml:"env,omitempty"`
+// validateToolFlags checks that gcflags and asmflags, when the build or
+// one of its overrides sets them, are not also set in flags.
+func (b *BuildConfig) validateToolFlags() error {
+	for _, f := range []struct {
+		name  string
+		build []string
+		set   func(o OverrideConfig) []string
+	}{
+		{"gcflags", b.Gcflags, func(o OverrideConfig) []string { return o.Gcflags }},
+		{"asmflags", b.Asmflags, func(o OverrideConfig) []string { return o.Asmflags }},
+	} {
+		set := len(f.build) > 0
+		for _, o := range b.Overrides {
+			set = set || len(f.set(o)) > 0
+		}
+		if !set {
+			continue
+		}
+		if b.Prebuilt != nil {
+			return fmt.Errorf("%s require go build, not prebuilt", f.name)
+		}
+		if hasFlag(b.Flags, f.name) {
+			return fmt.Errorf("%s and a -%s flag in flags are mutually exclusive", f.name, f.name)
+		}
+		for i, o := range b.Overrides {
+			if hasFlag(o.Flags, f.name) {
+				return fmt.Errorf("overrides[%d]: %s and a -%s flag in flags are mutually exclusive", i, f.name, f.name)
+			}
+		}
+	}
+	return nil
 }
 
-// ArchiveConfig defines how built binaries are archived.
-type ArchiveConfig struct {
-	Formats      []string `yaml:"formats,omitempty"`
-	NameTemplate string   `yaml:"name_template,omitempty"`
+// Validate checks BuildConfig for required fields.
+func (b *BuildConfig) Validate() error {
+	switch {
+	case b.Prebuilt != nil:
+		if b.Main != "" {
+			return fmt.Errorf("main and prebuilt are mutually exclusive")
+		}
+		if b.Prebuilt.PathTemplate == "" {
+			return fmt.Errorf("prebuilt.path_template is required")
+		}
+		if b.OutputName == "" {
+			return fmt.Errorf("output_name is required for prebuilt builds")
+		}
+		if b.Dir != "" {
+			return fmt.Errorf("dir requires go build, not prebuilt")
+		}
+	case b.Main == "":
+		return fmt.Errorf("main is required")
+	case strings.Contains(b.Main, "{{") && b.OutputName == "":
+		// The binary is named after main, which is only rendered at build time
+		return fmt.Errorf("output_name is required when main is a template")
+	}
+	if len(b.Targets) > 0 {
+		if err := b.validateTargets(); err != nil {
+			return err
+		}
+	} else {
+		if len(b.Goos) == 0 {
+			return fmt.Errorf("at least one goos value (or targets) is required")
+		}
+		if len(b.Goarch) == 0 {
+			return fmt.Errorf("at least one goarch value (or targets) is required")
+		}
+	}
+	if b.IncludeWasmExec && !b.buildsGoos("js") {
+		return fmt.Errorf("include_wasm_exec requires goos js")
+	}
+	if b.Buildmode != "" && b.Prebuilt != nil {
+		return fmt.Errorf("buildmode requires go build, not prebuilt")
+	}
+	if b.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if b.Timeout > 0 && b.Prebuilt != nil {
+		return fmt.Errorf("timeout requires go build, not prebuilt")
+	}
+	if err := validateBuildmode(b.Buildmode); err != nil {
+		return err
+	}
+	if b.Buildmode != "" || slices.ContainsFunc(b.Overrides, func(o OverrideConfig) bool { return o.Buildmode != "" }) {
+		if hasFlag(b.Flags, "buildmode") || slices.ContainsFunc(b.Overrides, func(o OverrideConfig) bool { return hasFlag(o.Flags, "buildmode") }) {
+			return fmt.Errorf("buildmode and a -buildmode flag in flags are mutually exclusive")
+		}
+	}
+	for goos, ext := range b.Extensions {
+		if strings.ContainsAny(ext, `/\`) {
+			return fmt.Errorf("extensions[%s]: %q must not contain path separators", goos, ext)
+		}
+	}
+	if err := b.UPX.Validate(); err != nil {
+		return fmt.Errorf("upx: %w", err)
+	}
+	if b.EmbedChangelog != nil {
+		if b.Prebuilt != nil {
+			return fmt.Errorf("embed_changelog requires go build, not prebuilt")
+		}
+		if err := b.EmbedChangelog.Validate(); err != nil {
+			return fmt.Errorf("embed_changelog: %w", err)
+		}
+	}
+	if b.GitAuth != nil {
+		if b.Prebuilt != nil {
+			return fmt.Errorf("git_auth requires go build, not prebuilt")
+		}
+		if err := b.GitAuth.Validate(); err != nil {
+			return fmt.Errorf("git_auth: %w", err)
+		}
+	}
+	if len(b.Tags) > 0 {
+		if b.Prebuilt != nil {
+			return fmt.Errorf("tags require go build, not prebuilt")
+		}
+		if hasFlag(b.Flags, "tags") {
+			return fmt.Errorf("tags and a -tags flag in flags are mutually exclusive")
+		}
+		for i, o := range b.Overrides {
+			if hasFlag(o.Flags, "tags") {
+				return fmt.Errorf("overrides[%d]: tags and a -tags flag in flags are mutually exclusive", i)
+			}
+		}
+	}
+	if err := b.validateToolFlags(); err != nil {
+		return err
+	}
+	if (b.GoBinary != "" || b.Command != "") && b.Prebuilt != nil {
+		return fmt.Errorf("gobinary and command require go build, not prebuilt")
+	}
+	if b.Command != "" && strings.TrimSpace(b.Command) == "" {
+		return fmt.Errorf("command must not be blank")
+	}
+	if b.CGO != nil {
+		if b.Prebuilt != nil {
+			return fmt.Errorf("cgo requires go build, not prebuilt")
+		}
+		if err := b.CGO.Validate(); err != nil {
+			return fmt.Errorf("cgo: %w", err)
+		}
+		if b.CGO.Enabled && setsEnv(b.Env, "CGO_ENABLED") {
+			return fmt.Errorf("cgo sets CGO_ENABLED per target; remove it from env")
+		}
+		for i, o := range b.Overrides {
+			if b.CGO.Enabled && setsEnv(o.Env, "CGO_ENABLED") {
+				return fmt.Errorf("overrides[%d]: cgo sets CGO_ENABLED per target; remove it from env", i)
+			}
+		}
+	}
+	if b.Coverage != nil {
+		if b.Prebuilt != nil {
+			return fmt.Errorf("coverage requires go build, not prebuilt")
+		}
+		if err := b.Coverage.Validate(); err != nil {
+			return fmt.Errorf("coverage: %w", err)
+		}
+		if b.Coverage.Enabled && hasFlag(b.Flags, "cover", "covermode") {
+			return fmt.Errorf("coverage sets -cover; remove it from flags")
+		}
+		for i, o := range b.Overrides {
+			if b.Coverage.Enabled && hasFlag(o.Flags, "cover", "covermode") {
+				return fmt.Errorf("overrides[%d]: coverage sets -cover; remove it from flags", i)
+			}
+		}
+	}
+	for _, v := range archVariants {
+		for i, variant := range v.list(b) {
+			if v.valid != nil && !v.valid.MatchString(variant) {
+				return fmt.Errorf("%s[%d]: %q is not a valid %s value", v.key, i, variant, v.env)
+			}
+		}
+	}
+	for i, ignore := range b.Ignore {
+		if ignore == (TargetMatch{}) {
+			return fmt.Errorf("ignore[%d]: at least one of goos, goarch and goarm is required", i)
+		}
+	}
+	for i, o := range b.Overrides {
+		if b.Prebuilt != nil {
+			return fmt.Errorf("overrides require go build, not prebuilt")
+		}
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("overrides[%d]: %w", i, err)
+		}
+	}
+	return nil
 }
 
-// BlobConfig defines a publish destination (S3 or SSH).
-type BlobConfig struct {
-	Provider string `yaml:"provider"`
-	Name     string `yaml:"name"`
-	// S3 fields
-	Bucket   string `yaml:"bucket,omitempty"`
-	Region   string `yaml:"region,omitempty"`
-	Endpoint string `yaml:"endpoint,omitempty"`
-	// SSH fields
-	Server                string `yaml:"server,omitempty"`
-	User                  string `yaml:"user,omitempty"`
-	KeyPath               string `yaml:"key_path,omitempty"`
-	KeyRaw                string `yaml:"key_raw,omitempty"`
-	InsecureIgnoreHostKey bool   `yaml:"insecure_ignore_host_key,omitempty"`
-	// Common
-	Directory string `yaml:"directory"`
+// ObjectTemplateData is the template context of a blob object_template.
+type ObjectTemplateData struct {
+	// Name is the local file name.
+	Name    string
+	Version string
+	Channel string
+	// ShortSha256 is the short SHA-256 of the file content.
+	ShortSha256 string
 }
 
-// DeployConfig defines a deployment target.
-type DeployConfig struct {
-	Name     string `yaml:"name"`
-	Provider string `yaml:"provider"`
-	// SSH fields
-	Server                string   `yaml:"server,omitempty"`
-	User                  string   `yaml:"user,omitempty"`
-	KeyPath               string   `yaml:"key_path,omitempty"`
-	KeyRaw                string   `yaml:"key_raw,omitempty"`
-	InsecureIgnoreHostKey bool     `yaml:"insecure_ignore_host_key,omitempty"`
-	Commands              []string `yaml:"commands"`
-	// Alerts
-	Alerts AlertConfig `yaml:"alerts,omitempty"`
+// ObjectHeaderData is the template context of blob metadata values and
+// content_disposition_template.
+type ObjectHeaderData struct {
+	ObjectTemplateData
+	// Object is the remote file name rendered by object_template.
+	Object string
+	Commit string
+	Env    map[string]string
 }
 
-// AlertConfig contains notification settings.
-type AlertConfig struct {
-	URLs []string `yaml:"urls,omitempty"`
-}
+// maxS3Metadata is the size limit of the user metadata of an S3 object.
+const maxS3Metadata = 2048
 
-// Load reads and parses a YAML configuration file.
-func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("read config file: %w", err)
+// ObjectHeaders renders the user metadata and Content-Disposition of an
+// object. Both are empty when the blob configures none.
+func (b *BlobConfig) ObjectHeaders(data ObjectHeaderData) (map[string]string, string, error) {
+	var metadata map[string]string
+	size := 0
+	for key, value := range b.Metadata {
+		out, err := tmpl.Process("metadata", value, data)
+		if err != nil {
+			return nil, "", fmt.Errorf("process metadata %s: %w", key, err)
+		}
+		if strings.ContainsFunc(out, isControl) {
+			return nil, "", fmt.Errorf("metadata %s rendered to %q, which contains control characters", key, out)
+		}
+		if metadata == nil {
+			metadata = make(map[string]string, len(b.Metadata))
+		}
+		metadata[key] = out
+		size += len(key) + len(out)
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parse config file: %w", err)
+	if size > maxS3Metadata {
+		return nil, "", fmt.Errorf("metadata of %s is %d bytes, S3 allows %d", data.Object, size, maxS3Metadata)
 	}
-	if cfg.OutDir == "" {
-		cfg.OutDir = "dist"
+
+	disposition, err := tmpl.Process("content_disposition_template", b.ContentDispositionTemplate, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("process content_disposition_template: %w", err)
 	}
-	return &cfg, nil
+	if strings.ContainsFunc(disposition, isControl) {
+		return nil, "", fmt.Errorf("content_disposition_template rendered to %q, which contains control characters", disposition)
+	}
+	return metadata, disposition, nil
 }
 
-// Validate checks the entire configuration for correctness.
-func (c *Config) Validate() error {
-	if len(c.Builds) == 0 {
-		return fmt.Errorf("at least one build configuration is required")
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// validateMetadataKey checks an S3 user metadata name: it is sent as an
+// x-amz-meta-<key> header, so only letters, digits, hyphens and dots
+// pass every S3 implementation and proxy.
+func validateMetadataKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("metadata: empty key")
 	}
-	for i, b := range c.Builds {
-		if err := b.Validate(); err != nil {
-			return fmt.Errorf("builds[%d]: %w", i, err)
-		}
+	if strings.HasPrefix(strings.ToLower(key), "x-amz-") {
+		return fmt.Errorf("metadata: key %q must not have the x-amz- prefix, it is added", key)
 	}
-	for i, blob := range c.Blobs {
-		if err := blob.Validate(); err != nil {
-			return fmt.Errorf("blobs[%d]: %w", i, err)
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return fmt.Errorf("metadata: key %q may only contain letters, digits, hyphens and dots", key)
 		}
 	}
-	for i, deploy := range c.Deploys {
-		if err := deploy.Validate(); err != nil {
-			return fmt.Errorf("deploys[%d]: %w", i, err)
-		}
+	return nil
+}
+
+// RemoteDir renders the directory template of the release and normalizes
+// it for the provider, see normalizeRemoteDir.
+func (b *BlobConfig) RemoteDir(rel ReleaseData) (string, error) {
+	dir, err := tmpl.Process("directory", b.Directory, rel)
+	if err != nil {
+		return "", fmt.Errorf("process directory template: %w", err)
 	}
-	for i, archive := range c.Archives {
-		if err := archive.Validate(); err != nil {
-			return fmt.Errorf("archives[%d]: %w", i, err)
-		}
+	normalized, err := normalizeRemoteDir(b.Provider, dir)
+	if err != nil {
+		return "", fmt.Errorf("directory rendered to %q: %w", dir, err)
 	}
-	return nil
+	return normalized, nil
 }
 
-// Validate checks BuildConfig for required fields.
-func (b *BuildConfig) Validate() error {
-	if b.Main == "" {
-		return fmt.Errorf("main is required")
+// ObjectName renders the remote file name of the local file name.
+func (b *BlobConfig) ObjectName(name string, rel ReleaseData, shortSha256 string) (string, error) {
+	if b.ObjectTemplate == "" {
+		return name, nil
+	}
+	data := ObjectTemplateData{Name: name, Version: rel.Version, Channel: rel.Channel, ShortSha256: shortSha256}
+	object, err := tmpl.Process("object_template", b.ObjectTemplate, data)
+	if err != nil {
+		return "", fmt.Errorf("process object template: %w", err)
+	}
+	if object == "" || strings.ContainsAny(object, `/\`) || object == "." || object == ".." {
+		return "", fmt.Errorf("object_template rendered to %q, want a plain file name", object)
+	}
+	return object, nil
+}
+
+// IsEnabled evaluates the enabled expression of the blob for rel.
+func (b *BlobConfig) IsEnabled(rel ReleaseData) (bool, error) {
+	return enabled(b.Enabled, rel)
+}
+
+// IsEnabled evaluates the enabled expression of the deploy for rel.
+func (d *DeployConfig) IsEnabled(rel ReleaseData) (bool, error) {
+	return enabled(d.Enabled, rel)
+}
+
+// IsEnabled evaluates the enabled expression of the alerts for rel.
+func (a *AlertConfig) IsEnabled(rel ReleaseData) (bool, error) {
+	return enabled(a.Enabled, rel)
+}
+
+// IsEnabled evaluates the enabled expression of the rule for rel.
+func (r *AlertRule) IsEnabled(rel ReleaseData) (bool, error) {
+	return enabled(r.Enabled, rel)
+}
+
+// enabled renders an enabled expression, which must yield true or false.
+// An empty expression is enabled.
+func enabled(expr string, rel ReleaseData) (bool, error) {
+	if expr == "" {
+		return true, nil
+	}
+	out, err := tmpl.Process("enabled", expr, rel)
+	if err != nil {
+		return false, err
 	}
-	if len(b.Goos) == 0 {
-		return fmt.Errorf("at least one goos value is required")
+	switch strings.TrimSpace(out) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("enabled rendered to %q, want true or false", out)
 	}
-	if len(b.Goarch) == 0 {
-		return fmt.Errorf("at least one goarch value is required")
+}
+
+// validateEnabled evaluates expr for every channel, so mistakes show up
+// before the release that would hit them.
+func validateEnabled(expr string) error {
+	for _, name := range channel.Names {
+		if _, err := enabled(expr, ReleaseData{Version: "v1.0.0", Channel: name, Tag: "v1.0.0"}); err != nil {
+			return fmt.Errorf("enabled: %w", err)
+		}
 	}
 	return nil
 }
@@ -144,6 +2161,38 @@ func (b *BlobConfig) Validate() error {
 	if b.Name == "" {
 		return fmt.Errorf("name is required")
 	}
+	if err := validateEnabled(b.Enabled); err != nil {
+		return err
+	}
+	if b.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative")
+	}
+	if b.MaxObjectSize < 0 {
+		return fmt.Errorf("max_object_size must not be negative")
+	}
+	for _, t := range b.Types {
+		if !slices.Contains(manifest.Types, t) {
+			return fmt.Errorf("types: unknown artifact type %q, expected one of %s", t, strings.Join(manifest.Types, ", "))
+		}
+	}
+	switch b.Oversize {
+	case "", OversizeFail:
+	case OversizeSkip, OversizeSplit:
+		if b.MaxObjectSize == 0 {
+			return fmt.Errorf("oversize %s requires max_object_size", b.Oversize)
+		}
+	default:
+		return fmt.Errorf("oversize must be fail, skip or split, got %q", b.Oversize)
+	}
+	switch b.Dedupe {
+	case "", DedupePlain:
+	case DedupeContentAddressed:
+		if b.Provider != "s3" {
+			return fmt.Errorf("dedupe content_addressed requires the s3 provider")
+		}
+	default:
+		return fmt.Errorf("dedupe must be plain or content_addressed, got %q", b.Dedupe)
+	}
 	switch b.Provider {
 	case "s3":
 		if b.Bucket == "" {
@@ -155,6 +2204,14 @@ func (b *BlobConfig) Validate() error {
 		if b.Directory == "" {
 			return fmt.Errorf("directory is required for s3 provider")
 		}
+		for key := range b.Metadata {
+			if err := validateMetadataKey(key); err != nil {
+				return err
+			}
+		}
+		if _, _, err := b.ObjectHeaders(ObjectHeaderData{}); err != nil {
+			return err
+		}
 	case "ssh":
 		if b.Server == "" {
 			return fmt.Errorf("server is required for ssh provider")
@@ -171,48 +2228,271 @@ func (b *BlobConfig) Validate() error {
 		if b.Directory == "" {
 			return fmt.Errorf("directory is required for ssh provider")
 		}
+		if len(b.Metadata) > 0 || b.ContentDispositionTemplate != "" {
+			return fmt.Errorf("metadata and content_disposition_template require the s3 provider")
+		}
+		if err := validateSSHBackend(b.SSHBackend); err != nil {
+			return err
+		}
+		if b.SFTPConcurrency < 0 {
+			return fmt.Errorf("sftp_concurrency must not be negative")
+		}
+		if b.SFTPBufferSize < 0 {
+			return fmt.Errorf("sftp_buffer_size must not be negative")
+		}
 	default:
 		return fmt.Errorf("unsupported provider: %s", b.Provider)
 	}
+	// The release being published is checked by gcx config validate
+	if _, err := b.RemoteDir(ReleaseData{Version: "v1.0.0", Channel: "stable", Tag: "v1.0.0"}); err != nil {
+		return err
+	}
 	return nil
 }
 
+// CoverageDir returns the remote GOCOVERDIR of coverage-instrumented
+// deploys, or "" when the deploy has none.
+func (d *DeployConfig) CoverageDir() string {
+	if d.Coverdir == "" && d.Provider == "releases" && d.BasePath != "" {
+		return path.Join(d.BasePath, "shared", "coverage")
+	}
+	return d.Coverdir
+}
+
 // Validate checks DeployConfig for required fields.
 func (d *DeployConfig) Validate() error {
 	if d.Name == "" {
 		return fmt.Errorf("name is required")
 	}
+	if err := validateEnabled(d.Enabled); err != nil {
+		return err
+	}
 	switch d.Provider {
 	case "ssh":
-		if d.Server == "" {
-			return fmt.Errorf("server is required for ssh provider")
+		if err := d.validateSSH(); err != nil {
+			return err
 		}
-		if d.User == "" {
-			return fmt.Errorf("user is required for ssh provider")
+		if len(d.Commands) == 0 && len(d.Steps) == 0 {
+			return fmt.Errorf("at least one command or step is required")
 		}
-		if d.KeyPath == "" && d.KeyRaw == "" {
-			return fmt.Errorf("either key_path or key_raw is required for ssh provider")
+	case "releases":
+		if err := d.validateSSH(); err != nil {
+			return err
 		}
-		if d.KeyPath != "" && d.KeyRaw != "" {
-			return fmt.Errorf("only one of key_path or key_raw should be provided")
+		if !path.IsAbs(d.BasePath) {
+			return fmt.Errorf("base_path must be an absolute path for releases provider")
+		}
+		if len(d.Artifacts) == 0 {
+			return fmt.Errorf("at least one artifacts pattern is required for releases provider")
 		}
-		if len(d.Commands) == 0 {
-			return fmt.Errorf("at least one command is required")
+		if d.KeepReleases < 0 {
+			return fmt.Errorf("keep_releases must not be negative")
+		}
+		for _, p := range d.Shared {
+			if p == "" || path.IsAbs(p) || strings.HasPrefix(path.Clean(p), "..") {
+				return fmt.Errorf("shared path %q must be relative to base_path/shared", p)
+			}
 		}
 	default:
 		return fmt.Errorf("unsupported deploy provider: %s", d.Provider)
 	}
+	if len(d.Commands) > 0 && len(d.Steps) > 0 {
+		return fmt.Errorf("commands and steps are mutually exclusive")
+	}
+	if d.Coverdir != "" && !path.IsAbs(d.Coverdir) {
+		return fmt.Errorf("coverdir must be an absolute path")
+	}
+	copies := slices.ContainsFunc(d.Steps, func(s DeployStep) bool { return s.Upload != nil || s.Download != nil })
+	if d.SkipUnchanged && (d.Provider != "ssh" || !copies) {
+		return fmt.Errorf("skip_unchanged requires the ssh provider with upload or download steps")
+	}
+	if !d.SkipUnchanged && slices.ContainsFunc(d.Steps, func(s DeployStep) bool { return s.OnlyOnChange }) {
+		return fmt.Errorf("only_on_change requires skip_unchanged")
+	}
+	for i, cmd := range d.Commands {
+		if err := cmd.Validate(); err != nil {
+			return fmt.Errorf("commands[%d]: %w", i, err)
+		}
+	}
+	for i, step := range d.Steps {
+		if err := step.Validate(); err != nil {
+			return fmt.Errorf("steps[%d]: %w", i, err)
+		}
+	}
+	if d.Approval != nil {
+		if err := d.Approval.Validate(); err != nil {
+			return fmt.Errorf("approval: %w", err)
+		}
+	}
+	if d.PostLogs != nil {
+		if err := d.PostLogs.Validate(); err != nil {
+			return fmt.Errorf("post_logs: %w", err)
+		}
+	}
+	if err := d.Alerts.Validate(); err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that a step is either a command or a complete download.
+func (s *DeployStep) Validate() error {
+	kinds := 0
+	for _, set := range []bool{s.Run != "", s.Download != nil, s.Upload != nil} {
+		if set {
+			kinds++
+		}
+	}
+	switch {
+	case kinds != 1:
+		return fmt.Errorf("exactly one of run, download and upload is required")
+	case s.Run != "":
+		return nil
+	case s.RequestPTY:
+		return fmt.Errorf("request_pty only applies to run steps")
+	case s.OnlyOnChange:
+		return fmt.Errorf("only_on_change only applies to run steps")
+	case s.Upload != nil:
+		return s.Upload.Validate()
+	case s.Download.URLTemplate == "":
+		return fmt.Errorf("download.url_template is required")
+	case s.Download.Dest == "":
+		return fmt.Errorf("download.dest is required")
+	case s.Download.SHA256 == "":
+		return fmt.Errorf("download.sha256 is required")
+	}
+	return nil
+}
+
+// Validate checks UploadStep for an artifact glob and a destination.
+func (u *UploadStep) Validate() error {
+	switch {
+	case u.Artifact == "":
+		return fmt.Errorf("upload.artifact is required")
+	case u.Dest == "":
+		return fmt.Errorf("upload.dest is required")
+	case u.Entry != "" && !u.Extract:
+		return fmt.Errorf("upload.entry requires upload.extract")
+	}
+	if _, err := path.Match(u.Artifact, ""); err != nil {
+		return fmt.Errorf("upload.artifact: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that the policy patterns are valid regular expressions.
+func (p *DeployPolicyConfig) Validate() error {
+	for i, pattern := range p.Deny {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("deny[%d]: %w", i, err)
+		}
+	}
+	for i, pattern := range p.Allow {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("allow[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks AlertConfig schedule rules and location.
+func (a *AlertConfig) Validate() error {
+	if a.DedupeWindow < 0 {
+		return fmt.Errorf("dedupe_window must not be negative")
+	}
+	if err := validateEnabled(a.Enabled); err != nil {
+		return err
+	}
+	if _, err := schedule.LoadLocation(a.Location); err != nil {
+		return err
+	}
+	for i, rule := range a.Schedule {
+		if _, err := schedule.Parse(rule.Days, rule.Hours); err != nil {
+			return fmt.Errorf("schedule[%d]: %w", i, err)
+		}
+		for _, status := range rule.Status {
+			if status != "success" && status != "failed" && status != "not_approved" {
+				return fmt.Errorf("schedule[%d]: unsupported status %q (expected success, failed or not_approved)", i, status)
+			}
+		}
+		if rule.Drop && len(rule.URLs) > 0 {
+			return fmt.Errorf("schedule[%d]: drop and urls are mutually exclusive", i)
+		}
+		if !rule.Drop && len(rule.URLs) == 0 {
+			return fmt.Errorf("schedule[%d]: either urls or drop is required", i)
+		}
+		if err := validateEnabled(rule.Enabled); err != nil {
+			return fmt.Errorf("schedule[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
 
+// validateSSH checks the connection fields shared by SSH-based deploy providers.
+func (d *DeployConfig) validateSSH() error {
+	if d.Server == "" {
+		return fmt.Errorf("server is required for %s provider", d.Provider)
+	}
+	if d.User == "" {
+		return fmt.Errorf("user is required for %s provider", d.Provider)
+	}
+	if d.KeyPath == "" && d.KeyRaw == "" {
+		return fmt.Errorf("either key_path or key_raw is required for %s provider", d.Provider)
+	}
+	if d.KeyPath != "" && d.KeyRaw != "" {
+		return fmt.Errorf("only one of key_path or key_raw should be provided")
+	}
+	return validateSSHBackend(d.SSHBackend)
+}
+
+func validateSSHBackend(backend string) error {
+	switch backend {
+	case "", "goph", "native":
+		return nil
+	default:
+		return fmt.Errorf("unsupported ssh_backend: %s (expected goph or native)", backend)
+	}
+}
+
 // Validate checks ArchiveConfig for supported formats.
 func (a *ArchiveConfig) Validate() error {
 	for _, f := range a.Formats {
-		switch f {
-		case "tar.gz", "zip":
-			// ok
+		if f == archive.BinaryFormat {
+			continue
+		}
+		if _, err := archive.New(f, a.CompressionLevel); err != nil {
+			return fmt.Errorf("%w, or %s", err, archive.BinaryFormat)
+		}
+	}
+	if slices.Contains(a.Formats, archive.BinaryFormat) {
+		switch {
+		case a.NameTemplate == "":
+			// The default name is the one of the directory being archived
+			return fmt.Errorf("name_template is required with the %s format, e.g. {{.Binary}}_{{.Os}}_{{.Arch}}", archive.BinaryFormat)
+		case len(a.Files) > 0 && len(a.Formats) == 1:
+			return fmt.Errorf("files do not apply to the %s format", archive.BinaryFormat)
+		}
+	}
+	if a.CompressionLevel != 0 {
+		// One level applies to every format of the block, so the narrowest
+		// range among them wins
+		var format string
+		maxLevel := 0
+		switch {
+		case slices.Contains(a.Formats, "tar.gz"):
+			format, maxLevel = "tar.gz", 9
+		case slices.Contains(a.Formats, "tar.zst"):
+			format, maxLevel = "tar.zst", 22
 		default:
-			return fmt.Errorf("unsupported archive format: %s", f)
+			return fmt.Errorf("compression_level only applies to the tar.gz and tar.zst formats")
+		}
+		if a.CompressionLevel < 1 || a.CompressionLevel > maxLevel {
+			return fmt.Errorf("compression_level must be between 1 and %d for %s, got %d", maxLevel, format, a.CompressionLevel)
+		}
+	}
+	for i, f := range a.Files {
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("files[%d]: %w", i, err)
 		}
 	}
 	return nil
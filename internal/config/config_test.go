@@ -3,7 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/configtypes"
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoad(t *testing.T) {
@@ -99,6 +105,212 @@ func TestConfigValidate(t *testing.T) {
 			t.Error("expected error for invalid build")
 		}
 	})
+
+	t.Run("templated main", func(t *testing.T) {
+		build := BuildConfig{Main: "./gen/{{.Version}}/cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}}
+		cfg := &Config{Builds: []BuildConfig{build}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "output_name is required when main is a template") {
+			t.Errorf("Validate() error = %v, want output_name required", err)
+		}
+
+		cfg.Builds[0].OutputName = "app"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("prebuilt build", func(t *testing.T) {
+		build := BuildConfig{
+			OutputName: "sidecar", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+			Prebuilt: &PrebuiltConfig{PathTemplate: "bin/sidecar_{{.Os}}_{{.Arch}}"},
+		}
+		cfg := &Config{Builds: []BuildConfig{build}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		build.Main = "./cmd/sidecar"
+		cfg.Builds = []BuildConfig{build}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for main with prebuilt")
+		}
+
+		build.Main, build.Dir = "", "services/sidecar"
+		cfg.Builds = []BuildConfig{build}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for dir with prebuilt")
+		}
+
+		build.Dir, build.GoBinary = "", "garble"
+		cfg.Builds = []BuildConfig{build}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for gobinary with prebuilt")
+		}
+	})
+
+	t.Run("build ids", func(t *testing.T) {
+		cfg := &Config{
+			Builds: []BuildConfig{
+				{ID: "server", Main: "./cmd/server", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+				{ID: "cli", Main: "./cmd/cli", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+			},
+			Archives: []ArchiveConfig{{Formats: []string{"tar.gz"}, Builds: []string{"server"}}},
+			Blobs: []BlobConfig{{
+				Provider: "s3", Name: "s3", Bucket: "b", Endpoint: "https://s3.example.com",
+				Directory: "releases", Builds: []string{"cli"},
+			}},
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cfg.Archives[0].Builds = []string{"worker"}
+		if err := cfg.Validate(); err == nil || err.Error() != `archives[0]: builds: unknown build id "worker"` {
+			t.Errorf("unknown archive build id: error = %v", err)
+		}
+		cfg.Archives[0].Builds = nil
+
+		cfg.Blobs[0].Builds = []string{"worker"}
+		if err := cfg.Validate(); err == nil || err.Error() != `blobs[0]: builds: unknown build id "worker"` {
+			t.Errorf("unknown blob build id: error = %v", err)
+		}
+		cfg.Blobs[0].Builds = nil
+
+		cfg.Builds[1].ID = "server"
+		if err := cfg.Validate(); err == nil || err.Error() != `builds[1]: id "server" is used by another build` {
+			t.Errorf("duplicate build id: error = %v", err)
+		}
+	})
+
+	t.Run("gobinary", func(t *testing.T) {
+		build := BuildConfig{
+			Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+			GoBinary: "garble", Command: "-literals build",
+		}
+		cfg := &Config{Builds: []BuildConfig{build}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		build.Command = " "
+		cfg.Builds = []BuildConfig{build}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for blank command")
+		}
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		build := BuildConfig{
+			Main: "./cmd/app", Goos: []string{"linux", "darwin"}, Goarch: []string{"amd64", "386"},
+			Ignore: []TargetMatch{{Goos: "darwin", Goarch: "386"}},
+		}
+		if err := build.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		build.Ignore = append(build.Ignore, TargetMatch{})
+		if err := build.Validate(); err == nil || err.Error() != "ignore[1]: at least one of goos, goarch and goarm is required" {
+			t.Errorf("Validate() error = %v, want an empty ignore entry rejected", err)
+		}
+		if !build.Ignore[0].Matches("darwin", "386", "") || build.Ignore[0].Matches("linux", "386", "") {
+			t.Error("Matches() does not compare goos and goarch")
+		}
+	})
+
+	t.Run("invalid go_version", func(t *testing.T) {
+		cfg := &Config{
+			GoVersion: ">=latest",
+			Builds: []BuildConfig{
+				{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+			},
+		}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for invalid go_version")
+		}
+	})
+
+	t.Run("invalid changelog repo_url", func(t *testing.T) {
+		cfg := &Config{
+			Changelog: ChangelogConfig{RepoURL: "git@github.com:acme/app.git"},
+			Builds: []BuildConfig{
+				{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+			},
+		}
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for a non-http repo_url")
+		}
+	})
+
+	t.Run("negative parallelism", func(t *testing.T) {
+		cfg := &Config{
+			Parallelism: -1,
+			Builds: []BuildConfig{
+				{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+			},
+		}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "parallelism: must not be negative") {
+			t.Errorf("Validate() error = %v, want negative parallelism rejected", err)
+		}
+	})
+
+	t.Run("release stage_weights", func(t *testing.T) {
+		tests := []struct {
+			weights map[string]int
+			wantErr string
+		}{
+			{map[string]int{"build": 2, "deploy": 1}, ""},
+			{map[string]int{"announce": 1}, `unknown stage "announce"`},
+			{map[string]int{"publish": 0}, "weight of publish must be positive"},
+		}
+		for _, tt := range tests {
+			cfg := &Config{
+				ReleaseSettings: ReleaseConfig{Timeout: configtypes.Duration(time.Hour), StageWeights: tt.weights},
+				Builds: []BuildConfig{
+					{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}},
+				},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), "release: stage_weights: "+tt.wantErr)) {
+				t.Errorf("Validate(%v) error = %v, want %q", tt.weights, err, tt.wantErr)
+			}
+		}
+	})
+}
+
+func TestAnnounceConfigValidate(t *testing.T) {
+	valid := []AnnouncerConfig{
+		{Name: "discord", Type: AnnouncerShoutrrr, URLs: []string{"discord://token@id"}},
+		{Name: "feed", Type: AnnouncerWebhook, URL: "https://example.com/hook", Headers: map[string]string{"Authorization": "Bearer {{.Env.TOKEN}}"}},
+		{Name: "script", Type: AnnouncerExec, Command: "./scripts/feed.sh"},
+	}
+	a := AnnounceConfig{Announcers: valid}
+	if err := a.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		announcer AnnouncerConfig
+		wantErr   string
+	}{
+		{AnnouncerConfig{Type: AnnouncerExec, Command: "true"}, "name is required"},
+		{AnnouncerConfig{Name: "x", Type: "slack"}, `unsupported type "slack"`},
+		{AnnouncerConfig{Name: "x", Type: AnnouncerShoutrrr}, "urls is required for shoutrrr announcers"},
+		{AnnouncerConfig{Name: "x", Type: AnnouncerWebhook, URL: "ftp://example.com"}, "must be an http(s) URL"},
+		{AnnouncerConfig{Name: "x", Type: AnnouncerExec, Command: "true", Template: "{{.Version}}"}, "template is not supported by exec announcers"},
+		{AnnouncerConfig{Name: "discord", Type: AnnouncerExec, Command: "true"}, `duplicate name "discord"`},
+	} {
+		a := AnnounceConfig{Announcers: append(valid[:1:1], tt.announcer)}
+		if err := a.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%+v) error = %v, want %q", tt.announcer, err, tt.wantErr)
+		}
+	}
+
+	cfg := &Config{
+		Builds:   []BuildConfig{{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}}},
+		Announce: AnnounceConfig{Blob: "s3", Announcers: valid},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `blob "s3" does not name a blobs entry`) {
+		t.Errorf("Validate() error = %v, want the unknown blob rejected", err)
+	}
 }
 
 func TestBlobConfigValidate(t *testing.T) {
@@ -125,6 +337,124 @@ func TestBlobConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "s3 metadata and content disposition",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Metadata:                   map[string]string{"commit": "{{.Commit}}", "Build-URL": "{{.Env.BUILD_URL}}", "gcx.version": "{{.Version}}"},
+				ContentDispositionTemplate: `attachment; filename="{{.Name}}"`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "split oversize",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				MaxObjectSize: 2 << 30, Oversize: OversizeSplit,
+			},
+			wantErr: false,
+		},
+		{
+			name: "skip oversize without max_object_size",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Oversize: OversizeSkip,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown oversize",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				MaxObjectSize: 1024, Oversize: "truncate",
+			},
+			wantErr: true,
+		},
+		{
+			name: "s3 content addressed",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Dedupe: DedupeContentAddressed,
+			},
+			wantErr: false,
+		},
+		{
+			name: "ssh content addressed",
+			cfg: BlobConfig{
+				Name: "test", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key", Directory: "/releases",
+				Dedupe: DedupeContentAddressed,
+			},
+			wantErr: true,
+		},
+		{
+			name: "artifact types",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Types: []string{"archive", "checksum"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown artifact type",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Types: []string{"archives"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown dedupe",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Dedupe: "hardlink",
+			},
+			wantErr: true,
+		},
+		{
+			name: "s3 metadata key with underscore",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Metadata: map[string]string{"build_url": "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "s3 metadata key with x-amz- prefix",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				Metadata: map[string]string{"x-amz-meta-commit": "x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "s3 content disposition with unknown field",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+				ContentDispositionTemplate: "attachment; filename={{.File}}",
+			},
+			wantErr: true,
+		},
+		{
+			name: "ssh metadata",
+			cfg: BlobConfig{
+				Name: "test", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key", Directory: "/releases",
+				Metadata: map[string]string{"commit": "x"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "s3 missing bucket",
 			cfg: BlobConfig{
@@ -149,6 +479,14 @@ func TestBlobConfigValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "enabled not a boolean",
+			cfg: BlobConfig{
+				Name: "test", Provider: "s3", Enabled: "{{.Channel}}",
+				Bucket: "b", Endpoint: "https://s3.example.com", Directory: "/releases",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -176,7 +514,7 @@ func TestDeployConfigValidate(t *testing.T) {
 			cfg: DeployConfig{
 				Name: "prod", Provider: "ssh",
 				Server: "host", User: "user", KeyPath: "/key",
-				Commands: []string{"systemctl restart app"},
+				Commands: []Command{{Run: "systemctl restart app"}},
 			},
 			wantErr: false,
 		},
@@ -188,29 +526,1079 @@ func TestDeployConfigValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := tt.cfg.Validate()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestArchiveConfigValidate(t *testing.T) {
-	t.Run("valid formats", func(t *testing.T) {
-		a := ArchiveConfig{Formats: []string{"tar.gz", "zip"}}
-		if err := a.Validate(); err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-	})
-
-	t.Run("unsupported format", func(t *testing.T) {
-		a := ArchiveConfig{Formats: []string{"rar"}}
-		if err := a.Validate(); err == nil {
-			t.Error("expected error for unsupported format")
-		}
-	})
+		{
+			name: "valid download steps",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{
+					{Download: &DownloadStep{
+						URLTemplate: "https://cdn.example.com/{{.ArtifactName \"*_linux_amd64.tar.gz\"}}",
+						Dest:        "/tmp/app.tar.gz",
+						SHA256:      "{{.ArtifactSha256 \"*_linux_amd64.tar.gz\"}}",
+					}},
+					{Run: "tar -xzf /tmp/app.tar.gz -C /opt/app"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "skip_unchanged with upload and only_on_change",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				SkipUnchanged: true,
+				Steps: []DeployStep{
+					{Upload: &UploadStep{Artifact: "app_*.tar.gz", Dest: "/opt/app/app", Extract: true}},
+					{Run: "systemctl restart app", OnlyOnChange: true},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "skip_unchanged without copy steps",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				SkipUnchanged: true,
+				Commands:      []Command{{Run: "true"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "only_on_change without skip_unchanged",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{{Run: "systemctl restart app", OnlyOnChange: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "only_on_change on an upload",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				SkipUnchanged: true,
+				Steps:         []DeployStep{{Upload: &UploadStep{Artifact: "app", Dest: "/opt/app/app"}, OnlyOnChange: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative coverdir",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Coverdir: "coverage",
+			},
+			wantErr: true,
+		},
+		{
+			name: "approval command",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Approval: &ApprovalConfig{Command: "./scripts/approved.sh {{.Version}}"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "approval command and url",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Approval: &ApprovalConfig{Command: "true", URL: "https://cab.example.com/approve"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "approval url without scheme",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Approval: &ApprovalConfig{URL: "cab.example.com/approve"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "post logs",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				PostLogs: &PostLogsConfig{Command: "journalctl -fu app", ErrorPattern: `panic:|level=fatal`},
+			},
+			wantErr: false,
+		},
+		{
+			name: "post logs without command",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				PostLogs: &PostLogsConfig{StopPattern: "started"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "post logs with invalid pattern",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				PostLogs: &PostLogsConfig{Command: "journalctl -fu app", ErrorPattern: "panic("},
+			},
+			wantErr: true,
+		},
+		{
+			name: "commands and steps",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Steps:    []DeployStep{{Run: "true"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "step with run and download",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{{Run: "true", Download: &DownloadStep{URLTemplate: "u", Dest: "d", SHA256: "s"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "download without sha256",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{{Download: &DownloadStep{URLTemplate: "u", Dest: "d"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "download step with request_pty",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{{Download: &DownloadStep{URLTemplate: "u", Dest: "d", SHA256: "s"}, RequestPTY: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid upload steps",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{
+					{Upload: &UploadStep{Artifact: "*_linux_amd64.tar.gz", Dest: "/opt/app/bin/app", Extract: true}},
+					{Upload: &UploadStep{Artifact: "tools_*.zip", Dest: "/opt/app/bin/migrate", Extract: true, Entry: "migrate"}},
+					{Run: "systemctl restart app"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "step with download and upload",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{{
+					Download: &DownloadStep{URLTemplate: "u", Dest: "d", SHA256: "s"},
+					Upload:   &UploadStep{Artifact: "*.tar.gz", Dest: "d"},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "upload entry without extract",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Steps: []DeployStep{{Upload: &UploadStep{Artifact: "*.tar.gz", Dest: "d", Entry: "app"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid releases deploy",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "releases",
+				Server: "host", User: "user", KeyPath: "/key",
+				BasePath: "/opt/app", Artifacts: []string{"*_linux_amd64.tar.gz"},
+				Shared: []string{"config.yaml", "logs"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "releases relative base_path",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "releases",
+				Server: "host", User: "user", KeyPath: "/key",
+				BasePath: "opt/app", Artifacts: []string{"*.tar.gz"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "releases shared path escapes",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "releases",
+				Server: "host", User: "user", KeyPath: "/key",
+				BasePath: "/opt/app", Artifacts: []string{"*.tar.gz"},
+				Shared: []string{"../etc"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "alert schedule with unknown location",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Alerts:   AlertConfig{Location: "Mars/Olympus"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "alert schedule rule without urls",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Alerts:   AlertConfig{Schedule: []AlertRule{{Days: []string{"mon-fri"}, Hours: "09:00-18:00"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid alert schedule",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{Run: "true"}},
+				Alerts: AlertConfig{Location: "Europe/Berlin", Schedule: []AlertRule{
+					{Days: []string{"mon-fri"}, Hours: "09:00-18:00", URLs: []string{"generic://chat"}},
+					{Status: []string{"success"}, Drop: true},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid include",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{IncludeURL: "https://ops.example.com/api.yaml", SHA256: strings.Repeat("ab", 32)}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "include without sha256",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{IncludeURL: "https://ops.example.com/api.yaml"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "include from file URL",
+			cfg: DeployConfig{
+				Name: "prod", Provider: "ssh",
+				Server: "host", User: "user", KeyPath: "/key",
+				Commands: []Command{{IncludeURL: "file:///etc/api.yaml", SHA256: strings.Repeat("ab", 32)}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommandYAML(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	var d DeployConfig
+	src := "commands:\n  - systemctl restart app\n  - include_url: https://ops.example.com/api.yaml\n    sha256: " + sum + "\n"
+	if err := yaml.Unmarshal([]byte(src), &d); err != nil {
+		t.Fatal(err)
+	}
+	want := []Command{{Run: "systemctl restart app"}, {IncludeURL: "https://ops.example.com/api.yaml", SHA256: sum}}
+	if !slices.Equal(d.Commands, want) {
+		t.Errorf("commands = %+v, want %+v", d.Commands, want)
+	}
+
+	out, err := yaml.Marshal(d.Commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantOut := "- systemctl restart app\n- include_url: https://ops.example.com/api.yaml\n  sha256: " + sum + "\n"; string(out) != wantOut {
+		t.Errorf("marshal = %q, want %q", out, wantOut)
+	}
+}
+
+func TestHookYAML(t *testing.T) {
+	var h HooksConfig
+	src := "hooks:\n  - go mod tidy\n  - cmd: npm run build\n    tags: [slow, frontend]\n"
+	if err := yaml.Unmarshal([]byte(src), &h); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Hooks) != 2 || h.Hooks[0].Cmd != "go mod tidy" || len(h.Hooks[0].Tags) != 0 ||
+		h.Hooks[1].Cmd != "npm run build" || !slices.Equal(h.Hooks[1].Tags, []string{"slow", "frontend"}) {
+		t.Errorf("hooks = %+v", h.Hooks)
+	}
+
+	out, err := yaml.Marshal(h.Hooks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- go mod tidy\n- cmd: npm run build\n  tags:\n    - slow\n    - frontend\n"; string(out) != want {
+		t.Errorf("marshal = %q, want %q", out, want)
+	}
+}
+
+func TestHooksConfigValidate(t *testing.T) {
+	good := HooksConfig{Hooks: []Hook{{Cmd: "go mod tidy"}, {Cmd: "npm run build", Tags: []string{"slow"}}}}
+	if err := good.Validate(); err != nil {
+		t.Errorf("Validate(%+v) error = %v", good, err)
+	}
+	for _, bad := range []HooksConfig{
+		{Hooks: []Hook{{Tags: []string{"slow"}}}},
+		{Hooks: []Hook{{Cmd: "make", Tags: []string{""}}}},
+		{Hooks: []Hook{{Cmd: "make", Tags: []string{"slow,codegen"}}}},
+		{Hooks: []Hook{{Cmd: "make", Tags: []string{"two words"}}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestChangelogConfigValidate(t *testing.T) {
+	good := ChangelogConfig{GroupBy: "type_then_scope", ScopeAliases: map[string]string{"apiv2": "api", "cmd/worker": "worker"}}
+	if err := good.Validate(); err != nil {
+		t.Errorf("Validate(%+v) error = %v", good, err)
+	}
+	for _, bad := range []ChangelogConfig{
+		{GroupBy: "author"},
+		{ScopeAliases: map[string]string{"API": "api"}},
+		{ScopeAliases: map[string]string{"apiv2": ""}},
+		{ScopeAliases: map[string]string{"apiv2": "api v2"}},
+		{ScopeAliases: map[string]string{"apiv1": "apiv2", "apiv2": "api"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestDeploySteps(t *testing.T) {
+	d := DeployConfig{
+		RequestPTY: true,
+		Steps: []DeployStep{
+			{Download: &DownloadStep{URLTemplate: "u", Dest: "d", SHA256: "s"}},
+			{Run: "sudo systemctl restart app"},
+		},
+	}
+	steps := d.DeploySteps()
+	if steps[0].RequestPTY || !steps[1].RequestPTY {
+		t.Errorf("steps = %+v, want a PTY for the run step only", steps)
+	}
+	if d.Steps[1].RequestPTY {
+		t.Error("DeploySteps modified the config")
+	}
+
+	d = DeployConfig{Commands: []Command{{Run: "true"}}}
+	if steps := d.DeploySteps(); len(steps) != 1 || steps[0].Run != "true" || steps[0].RequestPTY {
+		t.Errorf("steps = %+v", steps)
+	}
+}
+
+func TestIsEnabled(t *testing.T) {
+	b := BlobConfig{Enabled: `{{ eq .Channel "stable" }}`}
+	tests := []struct {
+		channel string
+		want    bool
+	}{
+		{"stable", true},
+		{"beta", false},
+		{"nightly", false},
+	}
+	for _, tt := range tests {
+		got, err := b.IsEnabled(ReleaseData{Version: "v1.0.0", Channel: tt.channel})
+		if err != nil || got != tt.want {
+			t.Errorf("IsEnabled(%s) = %v, %v; want %v", tt.channel, got, err, tt.want)
+		}
+	}
+
+	if got, err := (&DeployConfig{}).IsEnabled(ReleaseData{}); err != nil || !got {
+		t.Errorf("empty enabled = %v, %v; want true", got, err)
+	}
+	if _, err := (&AlertConfig{Enabled: "yes"}).IsEnabled(ReleaseData{}); err == nil || !strings.Contains(err.Error(), "want true or false") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestArchiveConfigValidate(t *testing.T) {
+	t.Run("valid formats", func(t *testing.T) {
+		for _, a := range []ArchiveConfig{
+			{Formats: []string{"tar.gz", "tar.xz", "tar.zst", "zip"}, CompressionLevel: 9},
+			{Formats: []string{"tar.zst", "zip"}, CompressionLevel: 19},
+			{Formats: []string{"tar.gz"}, CompressionLevel: 1},
+		} {
+			if err := a.Validate(); err != nil {
+				t.Errorf("Validate(%+v) error = %v", a, err)
+			}
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		a := ArchiveConfig{Formats: []string{"rar"}}
+		if err := a.Validate(); err == nil {
+			t.Error("expected error for unsupported format")
+		}
+	})
+
+	t.Run("binary format", func(t *testing.T) {
+		valid := []ArchiveConfig{
+			{Formats: []string{"binary"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}"},
+			{Formats: []string{"binary", "tar.gz"}, NameTemplate: "{{.Binary}}_{{.Os}}_{{.Arch}}", Files: []ArchiveFile{{Src: "LICENSE"}}},
+		}
+		for _, a := range valid {
+			if err := a.Validate(); err != nil {
+				t.Errorf("Validate(%+v) error = %v", a, err)
+			}
+		}
+		invalid := []ArchiveConfig{
+			{Formats: []string{"binary"}},
+			{Formats: []string{"binary"}, NameTemplate: "{{.Binary}}", Files: []ArchiveFile{{Src: "LICENSE"}}},
+		}
+		for _, a := range invalid {
+			if err := a.Validate(); err == nil {
+				t.Errorf("expected error for %+v", a)
+			}
+		}
+	})
+
+	t.Run("compression level", func(t *testing.T) {
+		for _, a := range []ArchiveConfig{
+			{Formats: []string{"tar.zst"}, CompressionLevel: 23},
+			{Formats: []string{"tar.gz"}, CompressionLevel: 10},
+			{Formats: []string{"tar.gz", "tar.zst"}, CompressionLevel: 19},
+			{Formats: []string{"tar.xz", "zip"}, CompressionLevel: 3},
+		} {
+			if err := a.Validate(); err == nil {
+				t.Errorf("expected error for %+v", a)
+			}
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		valid := ArchiveConfig{Files: []ArchiveFile{{Src: "LICENSE*"}, {Src: "completions/*", Dst: "share/completions"}}}
+		if err := valid.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		for _, f := range []ArchiveFile{{}, {Src: "[a"}, {Src: "README.md", Dst: "/docs"}, {Src: "README.md", Dst: "../docs"}} {
+			a := ArchiveConfig{Files: []ArchiveFile{f}}
+			if err := a.Validate(); err == nil || !strings.HasPrefix(err.Error(), "files[0]: ") {
+				t.Errorf("Validate(%+v) error = %v", f, err)
+			}
+		}
+	})
+}
+
+func TestArchiveFileYAML(t *testing.T) {
+	var a ArchiveConfig
+	src := "files:\n  - LICENSE*\n  - src: completions/*\n    dst: completions\n"
+	if err := yaml.Unmarshal([]byte(src), &a); err != nil {
+		t.Fatal(err)
+	}
+	want := []ArchiveFile{{Src: "LICENSE*"}, {Src: "completions/*", Dst: "completions"}}
+	if !slices.Equal(a.Files, want) {
+		t.Errorf("files = %+v, want %+v", a.Files, want)
+	}
+
+	out, err := yaml.Marshal(a.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantOut := "- LICENSE*\n- src: completions/*\n  dst: completions\n"; string(out) != wantOut {
+		t.Errorf("marshal = %q, want %q", out, wantOut)
+	}
+}
+
+func TestOverrideConfig(t *testing.T) {
+	var b BuildConfig
+	src := "overrides:\n  - goos: windows\n    goarch: amd64\n    merge: replace\n    env: [CC=x86_64-w64-mingw32-gcc]\n    ldflags: []\n"
+	if err := yaml.Unmarshal([]byte(src), &b); err != nil {
+		t.Fatal(err)
+	}
+	o := b.Overrides[0]
+	if o.TargetMatch != (TargetMatch{Goos: "windows", Goarch: "amd64"}) || o.Merge != MergeReplace {
+		t.Errorf("override = %+v", o)
+	}
+	if o.Ldflags == nil || len(o.Ldflags) != 0 || o.Flags != nil {
+		t.Errorf("ldflags: [] must be set and empty, flags unset: %#v, %#v", o.Ldflags, o.Flags)
+	}
+	if err := o.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	for _, bad := range []OverrideConfig{
+		{Flags: []string{"-race"}},
+		{TargetMatch: TargetMatch{Goos: "linux"}, Merge: "prepend"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestBuildConfigToolFlags(t *testing.T) {
+	base := func() BuildConfig {
+		return BuildConfig{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Flags: []string{"-trimpath"}}
+	}
+	b := base()
+	b.Gcflags = []string{"all=-N -l"}
+	b.Overrides = []OverrideConfig{{TargetMatch: TargetMatch{Goos: "linux"}, Asmflags: []string{"-trimpath=/src"}}}
+	if err := b.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		mutate func(*BuildConfig)
+		err    string
+	}{
+		{func(b *BuildConfig) { b.Gcflags, b.Flags = []string{"-m"}, []string{"-gcflags=all=-N"} }, "gcflags and a -gcflags flag in flags are mutually exclusive"},
+		{func(b *BuildConfig) {
+			b.Overrides = []OverrideConfig{{TargetMatch: TargetMatch{Goos: "linux"}, Asmflags: []string{"-trimpath"}, Flags: []string{"-asmflags", "-S"}}}
+		}, "overrides[0]: asmflags and a -asmflags flag in flags are mutually exclusive"},
+		{func(b *BuildConfig) {
+			b.Main, b.Flags, b.OutputName = "", nil, "app"
+			b.Prebuilt = &PrebuiltConfig{PathTemplate: "bin/{{.Os}}/app"}
+			b.Gcflags = []string{"all=-N -l"}
+		}, "gcflags require go build, not prebuilt"},
+	}
+	for _, tt := range tests {
+		b := base()
+		tt.mutate(&b)
+		if err := b.Validate(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Validate() error = %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestBuildConfigBuildmode(t *testing.T) {
+	base := func() BuildConfig {
+		return BuildConfig{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Flags: []string{"-buildmode=pie"}}
+	}
+	b := base()
+	if err := b.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	b.Flags, b.Buildmode = nil, "pie"
+	b.Overrides = []OverrideConfig{{TargetMatch: TargetMatch{Goos: "linux"}, Buildmode: "c-shared"}}
+	if err := b.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		mutate func(*BuildConfig)
+		err    string
+	}{
+		{func(b *BuildConfig) { b.Buildmode = "dll" }, `buildmode must be one of default, exe, pie, c-shared, c-archive, archive, shared, plugin, got "dll"`},
+		{func(b *BuildConfig) { b.Buildmode = "pie" }, "buildmode and a -buildmode flag in flags are mutually exclusive"},
+		{func(b *BuildConfig) {
+			b.Flags = nil
+			b.Overrides = []OverrideConfig{{TargetMatch: TargetMatch{Goos: "linux"}, Buildmode: "shared-lib"}}
+		}, `overrides[0]: buildmode must be one of`},
+		{func(b *BuildConfig) {
+			b.Main, b.Flags, b.OutputName = "", nil, "app"
+			b.Prebuilt = &PrebuiltConfig{PathTemplate: "bin/{{.Os}}/app"}
+			b.Buildmode = "pie"
+		}, "buildmode requires go build, not prebuilt"},
+	}
+	for _, tt := range tests {
+		b := base()
+		tt.mutate(&b)
+		if err := b.Validate(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Validate() error = %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestBuildConfigTags(t *testing.T) {
+	base := func() BuildConfig {
+		return BuildConfig{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Tags: []string{"netgo", "{{.Env.EDITION}}"}}
+	}
+	b := base()
+	b.Flags = []string{"-trimpath", "-ldflags=-s"}
+	if err := b.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	for name, mutate := range map[string]func(*BuildConfig){
+		"tags flag":          func(b *BuildConfig) { b.Flags = []string{"-tags=sqlite_static"} },
+		"separate tags flag": func(b *BuildConfig) { b.Flags = []string{"-trimpath --tags sqlite_static"} },
+		"override tags flag": func(b *BuildConfig) {
+			b.Overrides = []OverrideConfig{{TargetMatch: TargetMatch{Goos: "linux"}, Flags: []string{"-tags", "osusergo"}}}
+		},
+		"prebuilt": func(b *BuildConfig) {
+			b.Main = ""
+			b.OutputName = "app"
+			b.Prebuilt = &PrebuiltConfig{PathTemplate: "bin/{{.Os}}/app"}
+		},
+	} {
+		b := base()
+		mutate(&b)
+		if err := b.Validate(); err == nil {
+			t.Errorf("%s: Validate() succeeded, want an error", name)
+		}
+	}
+
+	b = base()
+	b.Tags = nil
+	b.Flags = []string{"-tags=sqlite_static"}
+	if err := b.Validate(); err != nil {
+		t.Errorf("-tags in flags without tags: Validate() error = %v", err)
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	tests := []struct {
+		format, tag, want string
+	}{
+		{"", "v1.2.3", "v1.2.3"},
+		{VersionRaw, "1.2.3", "1.2.3"},
+		{VersionWithoutV, "v1.2.3", "1.2.3"},
+		{VersionWithoutV, "1.2.3", "1.2.3"},
+		{VersionWithoutV, "api/v1.2.3", "api/v1.2.3"},
+		{VersionWithoutV, "vnext", "vnext"},
+		{VersionWithV, "1.2.3-beta.1", "v1.2.3-beta.1"},
+		{VersionWithV, "v1.2.3", "v1.2.3"},
+	}
+	for _, tt := range tests {
+		cfg := Config{VersionFormat: tt.format, Channel: "stable"}
+		rel := cfg.Release(tt.tag)
+		if rel.Version != tt.want || rel.Tag != tt.tag {
+			t.Errorf("%s: Release(%q) = %+v, want Version %q", tt.format, tt.tag, rel, tt.want)
+		}
+	}
+
+	cfg := Config{Builds: []BuildConfig{{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}}}, VersionFormat: "no_v"}
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "version_format:") {
+		t.Errorf("Validate() error = %v, want a version_format error", err)
+	}
+}
+
+func TestStagingStrategy(t *testing.T) {
+	cfg := Config{Builds: []BuildConfig{{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}}}}
+	if got := cfg.Staging(); got != StagingHardlink {
+		t.Errorf("Staging() = %q, want %q by default", got, StagingHardlink)
+	}
+	for _, strategy := range []string{StagingCopy, StagingHardlink, StagingMove} {
+		cfg.StagingStrategy = strategy
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: Validate() error = %v", strategy, err)
+		}
+	}
+	cfg.StagingStrategy = "symlink"
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "staging_strategy:") {
+		t.Errorf("Validate() error = %v, want a staging_strategy error", err)
+	}
+}
+
+func TestBuildConfigVariants(t *testing.T) {
+	b := BuildConfig{
+		Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64", "mips", "riscv64"},
+		Goamd64: []string{"v3"}, Goarm64: []string{"v8.2,lse"}, Gomips: []string{"softfloat"}, Goriscv64: []string{"rva22u64"},
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if env, variants := b.Variants("mips"); env != "GOMIPS" || !slices.Equal(variants, []string{"softfloat"}) {
+		t.Errorf("Variants(mips) = %s, %v", env, variants)
+	}
+	if env, variants := b.Variants("386"); env != "" || variants != nil {
+		t.Errorf("Variants(386) = %s, %v; want none", env, variants)
+	}
+	if got := b.UnusedVariants(); !slices.Equal(got, []string{"goarm64"}) {
+		t.Errorf("UnusedVariants() = %v, want [goarm64]", got)
+	}
+
+	for _, mutate := range []func(*BuildConfig){
+		func(b *BuildConfig) { b.Goamd64 = []string{"v5"} },
+		func(b *BuildConfig) { b.Goarm64 = []string{"8.0"} },
+		func(b *BuildConfig) { b.Gomips = []string{"soft"} },
+		func(b *BuildConfig) { b.Goriscv64 = []string{"../x"} },
+	} {
+		b := b
+		mutate(&b)
+		if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "is not a valid") {
+			t.Errorf("Validate() error = %v, want an invalid variant", err)
+		}
+	}
+}
+
+func TestBuildConfigTargets(t *testing.T) {
+	b := BuildConfig{Main: ".", Targets: []string{"linux/amd64", "linux/arm/v7", "linux/amd64/v3", "js/wasm"}, IncludeWasmExec: true}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := []Platform{{"linux", "amd64", ""}, {"linux", "arm", "7"}, {"linux", "amd64", "v3"}, {"js", "wasm", ""}}
+	if got := b.Platforms(); !slices.Equal(got, want) {
+		t.Errorf("Platforms() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		mutate func(*BuildConfig)
+		err    string
+	}{
+		{func(b *BuildConfig) { b.Targets = []string{"linux"} }, `targets[0]: "linux" must be goos/goarch or goos/goarch/variant`},
+		{func(b *BuildConfig) { b.Targets = []string{"linux/arm/v8"} }, `targets[0]: "linux/arm/v8": the arm version must be v5, v6 or v7`},
+		{func(b *BuildConfig) { b.Targets = []string{"linux/386/v2"} }, `targets[0]: "linux/386/v2": 386 has no variants`},
+		{func(b *BuildConfig) { b.Targets = []string{"linux/amd64/v5"} }, `targets[0]: "linux/amd64/v5": "v5" is not a valid GOAMD64 value`},
+		{func(b *BuildConfig) { b.Targets = []string{"linux/arm/7", "linux/arm/v7"} }, `targets[1]: "linux/arm/v7" is listed twice`},
+		{func(b *BuildConfig) { b.Goos = []string{"linux"} }, "targets and goos are mutually exclusive"},
+		{func(b *BuildConfig) { b.Goamd64 = []string{"v3"} }, "targets and goamd64 are mutually exclusive"},
+		{func(b *BuildConfig) { b.Targets = []string{"linux/amd64"} }, "include_wasm_exec requires goos js"},
+	}
+	for _, tt := range tests {
+		b := b
+		tt.mutate(&b)
+		if err := b.Validate(); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("Validate() error = %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestCoverageConfig(t *testing.T) {
+	b := BuildConfig{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Coverage: &CoverageConfig{Enabled: true, Covermode: CovermodeAtomic}}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := b.Coverage.Flags(); !slices.Equal(got, []string{"-cover", "-covermode=atomic"}) {
+		t.Errorf("Flags() = %v", got)
+	}
+
+	tests := []struct {
+		mutate func(*BuildConfig)
+		err    string
+	}{
+		{func(b *BuildConfig) { b.Coverage = &CoverageConfig{Enabled: true, Covermode: "full"} }, `coverage: covermode must be set, count or atomic, got "full"`},
+		{func(b *BuildConfig) { b.Flags = []string{"-trimpath -cover"} }, "coverage sets -cover; remove it from flags"},
+		{func(b *BuildConfig) { b.Overrides = []OverrideConfig{{Flags: []string{"-covermode=count"}}} }, "overrides[0]: coverage sets -cover; remove it from flags"},
+		{func(b *BuildConfig) {
+			b.Main, b.OutputName, b.Prebuilt = "", "app", &PrebuiltConfig{PathTemplate: "bin/app"}
+		}, "coverage requires go build, not prebuilt"},
+	}
+	for _, tt := range tests {
+		b := b
+		tt.mutate(&b)
+		if err := b.Validate(); err == nil || err.Error() != tt.err {
+			t.Errorf("Validate() error = %v, want %q", err, tt.err)
+		}
+	}
+
+	normal := BuildConfig{Main: "./cmd/cli", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Group: "suite"}
+	b.Group = "suite"
+	cfg := Config{Builds: []BuildConfig{normal, b}}
+	if err := cfg.Validate(); err == nil || err.Error() != `builds[1]: group "suite" mixes coverage-instrumented and normal builds` {
+		t.Errorf("Validate() error = %v, want a mixed group", err)
+	}
+
+	deploy := DeployConfig{Provider: "releases", BasePath: "/srv/app"}
+	if got := deploy.CoverageDir(); got != "/srv/app/shared/coverage" {
+		t.Errorf("CoverageDir() = %q", got)
+	}
+	deploy.Coverdir = "/var/lib/app/coverage"
+	if got := deploy.CoverageDir(); got != deploy.Coverdir {
+		t.Errorf("CoverageDir() = %q", got)
+	}
+}
+
+func TestCGOConfig(t *testing.T) {
+	var b BuildConfig
+	src := "main: .\ngoos: [linux]\ngoarch: [amd64, arm]\ncgo:\n  enabled: true\n  strict: true\n  targets:\n    linux/amd64: {cc: zig cc -target x86_64-linux-musl}\n    linux/arm/arm7: {cc: arm-linux-gnueabihf-gcc, cflags: -O2}\n"
+	if err := yaml.Unmarshal([]byte(src), &b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if tc, ok := b.CGO.Toolchain("linux", "arm", "7"); !ok || tc.CC != "arm-linux-gnueabihf-gcc" || tc.CFlags != "-O2" {
+		t.Errorf("Toolchain(linux/arm/7) = %+v, %v", tc, ok)
+	}
+	if tc, ok := b.CGO.Toolchain("linux", "amd64", ""); !ok || tc.CC != "zig cc -target x86_64-linux-musl" {
+		t.Errorf("Toolchain(linux/amd64) = %+v, %v", tc, ok)
+	}
+	if _, ok := b.CGO.Toolchain("linux", "arm", "6"); ok {
+		t.Error("Toolchain(linux/arm/6) found the arm7 entry")
+	}
+
+	for name, mutate := range map[string]func(*BuildConfig){
+		"bad key":         func(b *BuildConfig) { b.CGO.Targets["linux"] = CToolchain{} },
+		"bad arm key":     func(b *BuildConfig) { b.CGO.Targets["linux/arm/7"] = CToolchain{} },
+		"CGO_ENABLED env": func(b *BuildConfig) { b.Env = []string{"CGO_ENABLED=0"} },
+		"override env": func(b *BuildConfig) {
+			b.Overrides = []OverrideConfig{{TargetMatch: TargetMatch{Goos: "linux"}, Env: []string{"CGO_ENABLED=1"}}}
+		},
+		"prebuilt": func(b *BuildConfig) {
+			b.Main, b.OutputName, b.Prebuilt = "", "app", &PrebuiltConfig{PathTemplate: "bin/app"}
+		},
+	} {
+		var b BuildConfig
+		if err := yaml.Unmarshal([]byte(src), &b); err != nil {
+			t.Fatal(err)
+		}
+		mutate(&b)
+		if err := b.Validate(); err == nil {
+			t.Errorf("%s: Validate() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSignConfigValidate(t *testing.T) {
+	for _, s := range []SignConfig{
+		{Provider: "ssh"},
+		{Provider: "cosign"},
+		{Provider: "cosign", KeyEnv: "COSIGN_PRIVATE_KEY"},
+		{Provider: "ssh", KeyEnv: "SSH_SIGNING_KEY"},
+	} {
+		if err := s.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", s, err)
+		}
+	}
+	for _, s := range []SignConfig{
+		{Provider: "gpg"},
+		{Provider: "ssh", KeyPath: "id_ed25519", KeyEnv: "KEY"},
+		{Provider: "cosign", KeyPath: "cosign.key", KeyEnv: "KEY"},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestSBOMConfig(t *testing.T) {
+	s := SBOMConfig{}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cmd, args := s.Command()
+	if cmd != "syft" || s.DocumentFormat() != SBOMCycloneDX || !slices.Contains(args, "{{.Format}}-json={{.Document}}") {
+		t.Errorf("defaults = %s %v, format %s", cmd, args, s.DocumentFormat())
+	}
+	if err := (&SBOMConfig{Format: "swid"}).Validate(); err == nil {
+		t.Error("Validate() succeeded for an unsupported format")
+	}
+}
+
+func TestUPXConfig(t *testing.T) {
+	u := UPXConfig{Enabled: true, Targets: []string{"linux/*", "darwin/*"}, Exclude: []string{"darwin/arm64"}}
+	if err := u.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[[2]string]bool{
+		{"linux", "arm"}:     true,
+		{"darwin", "amd64"}:  true,
+		{"darwin", "arm64"}:  false,
+		{"windows", "amd64"}: false,
+	} {
+		if got := u.Applies(target[0], target[1]); got != want {
+			t.Errorf("Applies(%s/%s) = %v, want %v", target[0], target[1], got, want)
+		}
+	}
+	if (&UPXConfig{Targets: []string{"linux/amd64"}}).Applies("linux", "amd64") {
+		t.Error("Applies() = true with upx disabled")
+	}
+	for _, bad := range []UPXConfig{{Level: 10}, {Exclude: []string{"darwin"}}, {Targets: []string{"linux/["}}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestEmbedCheckConfigValidate(t *testing.T) {
+	e := EmbedCheckConfig{Path: "ui/dist", NewerThan: []string{"web/src/**/*.ts", "web/package.json"}}
+	if err := e.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []EmbedCheckConfig{
+		{NewerThan: []string{"web/src"}},
+		{Path: "ui/dist"},
+		{Path: "ui/dist", NewerThan: []string{"web/[src"}},
+		{Path: "ui/dist", NewerThan: []string{"web/src"}, Compare: "ctime"},
+		{Path: "ui/dist", NewerThan: []string{"web/src"}, Compare: CompareHash},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestEmbedChangelogConfigValidate(t *testing.T) {
+	for _, v := range []string{"main.changelog", "github.com/acme/app/internal/version.Changelog"} {
+		if err := (&EmbedChangelogConfig{Var: v}).Validate(); err != nil {
+			t.Errorf("Validate(%s) error = %v", v, err)
+		}
+	}
+	for _, v := range []string{"", "changelog", "main.", "github.com/acme/app/.x", "main.change-log"} {
+		if err := (&EmbedChangelogConfig{Var: v}).Validate(); err == nil {
+			t.Errorf("Validate(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestGitAuthConfigValidate(t *testing.T) {
+	good := &GitAuthConfig{TokenEnv: "GH_TOKEN", Hosts: []string{"github.com", "git.example.com"}}
+	if err := good.Validate(); err != nil {
+		t.Errorf("Validate(%+v) error = %v", good, err)
+	}
+	if got := good.User(); got != "x-access-token" {
+		t.Errorf("User() = %q, want x-access-token", got)
+	}
+	for _, bad := range []*GitAuthConfig{
+		{Hosts: []string{"github.com"}},
+		{TokenEnv: "GH_TOKEN"},
+		{TokenEnv: "GH_TOKEN", Hosts: []string{"https://github.com"}},
+		{TokenEnv: "GH_TOKEN", Hosts: []string{"github.com/acme"}},
+		{TokenEnv: "GH_TOKEN", Hosts: []string{""}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+
+	build := BuildConfig{
+		OutputName: "sidecar", Goos: []string{"linux"}, Goarch: []string{"amd64"},
+		Prebuilt: &PrebuiltConfig{PathTemplate: "bin/sidecar_{{.Os}}_{{.Arch}}"},
+		GitAuth:  good,
+	}
+	if err := build.Validate(); err == nil {
+		t.Error("expected error for git_auth with prebuilt")
+	}
+}
+
+func TestChecksumConfigValidate(t *testing.T) {
+	t.Run("valid algorithms", func(t *testing.T) {
+		c := ChecksumConfig{Algorithm: configtypes.StringList{"sha256", "sha512", "sha1", "md5", "blake2b"}}
+		if err := c.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		c := ChecksumConfig{Algorithm: configtypes.StringList{"sha256", "crc32"}}
+		err := c.Validate()
+		if err == nil || !strings.Contains(err.Error(), `unsupported checksum algorithm "crc32": expected one of sha256`) {
+			t.Errorf("error = %v", err)
+		}
+	})
+
+	t.Run("duplicate algorithm", func(t *testing.T) {
+		c := ChecksumConfig{Algorithm: configtypes.StringList{"sha256", "sha256"}}
+		if err := c.Validate(); err == nil {
+			t.Error("expected error for a duplicate algorithm")
+		}
+	})
+
+	t.Run("format and path_prefix", func(t *testing.T) {
+		c := ChecksumConfig{Format: "bsd", PathPrefix: "releases/{{.Version}}"}
+		if err := c.Validate(); err != nil || !c.Enabled() {
+			t.Errorf("Validate() = %v, Enabled() = %v", err, c.Enabled())
+		}
+		prefix, err := c.Prefix(ReleaseData{Version: "v1.2.0"})
+		if err != nil || prefix != "releases/v1.2.0" {
+			t.Errorf("Prefix() = %q, %v", prefix, err)
+		}
+	})
+
+	for name, tt := range map[string]struct {
+		c   ChecksumConfig
+		err string
+	}{
+		"unknown format":  {ChecksumConfig{Format: "coreutils"}, `format must be one of gnu, bsd, got "coreutils"`},
+		"absolute prefix": {ChecksumConfig{PathPrefix: "/{{.Version}}"}, `path_prefix "/v1.0.0" must be a relative path`},
+		"prefix outside":  {ChecksumConfig{PathPrefix: "../{{.Version}}"}, `path_prefix "../v1.0.0" must be a relative path`},
+		"template error":  {ChecksumConfig{PathPrefix: "{{.Version"}, "process path_prefix template"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.c.Validate(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	cfg := &Config{
+		OutDir:  "dist/{{.Version}}",
+		GC:      GCConfig{CacheDir: "/var/cache/gcx"},
+		Blobs:   []BlobConfig{{KeyPath: "~/.ssh/id_ed25519"}},
+		Deploys: []DeployConfig{{KeyPath: "keys/deploy"}},
+		Builds:  []BuildConfig{{Dir: "services/api"}, {}},
+	}
+	cfg.ResolvePaths("services/api")
+
+	if cfg.Dir != "services/api" {
+		t.Errorf("Dir = %q", cfg.Dir)
+	}
+	if cfg.OutDir != "services/api/dist/{{.Version}}" {
+		t.Errorf("OutDir = %q", cfg.OutDir)
+	}
+	if cfg.GC.CacheDir != "/var/cache/gcx" {
+		t.Errorf("absolute CacheDir changed to %q", cfg.GC.CacheDir)
+	}
+	if cfg.Blobs[0].KeyPath != "~/.ssh/id_ed25519" {
+		t.Errorf("home-relative KeyPath changed to %q", cfg.Blobs[0].KeyPath)
+	}
+	if cfg.Deploys[0].KeyPath != "services/api/keys/deploy" {
+		t.Errorf("deploy KeyPath = %q", cfg.Deploys[0].KeyPath)
+	}
+	if cfg.Builds[0].Dir != "services/api/services/api" || cfg.Builds[1].Dir != "" {
+		t.Errorf("build dirs = %q, %q", cfg.Builds[0].Dir, cfg.Builds[1].Dir)
+	}
+
+	same := &Config{OutDir: "dist"}
+	same.ResolvePaths(".")
+	if same.Dir != "" || same.OutDir != "dist" {
+		t.Errorf("config in the working directory changed: %+v", same)
+	}
+}
+
+func TestProject(t *testing.T) {
+	cfg := &Config{Dir: "services/api"}
+	if got := cfg.Project(); got != "api" {
+		t.Errorf("Project() = %q, want the config directory name", got)
+	}
+	cfg.ProjectName = "myproject"
+	if got := cfg.Project(); got != "myproject" {
+		t.Errorf("Project() = %q, want project_name", got)
+	}
+}
+
+func TestBuildConfigTimeout(t *testing.T) {
+	b := BuildConfig{Main: ".", Goos: []string{"linux"}, Goarch: []string{"amd64"}, Timeout: configtypes.Duration(10 * time.Minute)}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	b.Timeout = configtypes.Duration(-time.Second)
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "timeout must not be negative") {
+		t.Errorf("Validate() error = %v, want a negative timeout error", err)
+	}
+	b.Timeout, b.Main, b.OutputName = configtypes.Duration(time.Minute), "", "app"
+	b.Prebuilt = &PrebuiltConfig{PathTemplate: "bin/{{.Os}}/app"}
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "timeout requires go build") {
+		t.Errorf("Validate() error = %v, want a prebuilt timeout error", err)
+	}
 }
@@ -0,0 +1,595 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sxwebdev/gcx/internal/configtypes"
+	"gopkg.in/yaml.v3"
+)
+
+// pathSegment is one step of a config path: a mapping key or a sequence index.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+var (
+	pathPartRegex = regexp.MustCompile(`^([A-Za-z0-9_]+)((?:\[\d+\])*)$`)
+	aliasRegex    = regexp.MustCompile(`^\*[^\s,\[\]{}]+$`)
+)
+
+// parsePath splits a path such as "builds[0].goos" into segments.
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		m := pathPartRegex.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		segments = append(segments, pathSegment{key: m[1]})
+		for _, idx := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if idx == "" {
+				continue
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in path %q", path)
+			}
+			segments = append(segments, pathSegment{index: n})
+		}
+	}
+	return segments, nil
+}
+
+// checkPath makes sure segments name a field of t by its yaml tags, so that
+// typos are reported instead of being written to the file.
+func checkPath(t reflect.Type, segments []pathSegment) error {
+	prefix := ""
+	for _, seg := range segments {
+		if seg.key == "" {
+			if t.Kind() != reflect.Slice {
+				return fmt.Errorf("%s is not a list", prefix)
+			}
+			t = t.Elem()
+			prefix += "[" + strconv.Itoa(seg.index) + "]"
+			continue
+		}
+
+		if prefix != "" {
+			prefix += "."
+		}
+		prefix += seg.key
+
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			field, ok := yamlField(t, seg.key)
+			if !ok {
+				return fmt.Errorf("unknown field %s", prefix)
+			}
+			t = field.Type
+		default:
+			return fmt.Errorf("%s: parent is not a mapping", prefix)
+		}
+	}
+	return nil
+}
+
+// yamlField finds the field of struct t with the yaml name, looking into
+// embedded structs tagged ",inline" as yaml does.
+func yamlField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if tag == name {
+			return f, true
+		}
+		if f.Anonymous && tag == "" && opts == "inline" && f.Type.Kind() == reflect.Struct {
+			if field, ok := yamlField(f.Type, name); ok {
+				return field, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Set replaces the value at path (e.g. "builds[0].goos") in the YAML document
+// data with value, which is itself parsed as YAML ("[linux, darwin]", "*anchor").
+// Only the text of the edited value changes: comments, anchors, blank lines
+// and formatting of the rest of the document are kept byte for byte. A
+// missing last key is appended to its mapping.
+func Set(data []byte, path, value string) ([]byte, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPath(reflect.TypeFor[Config](), segments); err != nil {
+		return nil, err
+	}
+
+	newValue, err := parseValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("config file is empty")
+	}
+
+	e := &editor{data: data, lineStarts: lineStarts(data)}
+
+	node := doc.Content[0]
+	var stack []frame
+	for i, seg := range segments {
+		if node.Kind == yaml.AliasNode {
+			return nil, fmt.Errorf("%s goes through alias *%s; edit the anchored value instead", path, node.Value)
+		}
+
+		if seg.key == "" {
+			if node.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("%s: not a list in the config file", path)
+			}
+			if seg.index >= len(node.Content) {
+				return nil, fmt.Errorf("%s: index %d out of range (%d items)", path, seg.index, len(node.Content))
+			}
+			stack = append(stack, frame{parent: node, index: seg.index})
+			node = node.Content[seg.index]
+			continue
+		}
+
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: %s is not a mapping in the config file", path, seg.key)
+		}
+		idx := -1
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == seg.key {
+				idx = j + 1
+			}
+		}
+		if idx < 0 {
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("%s: %s not found in the config file", path, seg.key)
+			}
+			if err := e.insert(stack, node, seg.key, newValue); err != nil {
+				return nil, fmt.Errorf("set %s: %w", path, err)
+			}
+			return e.result(path)
+		}
+		stack = append(stack, frame{parent: node, index: idx})
+		node = node.Content[idx]
+	}
+
+	if err := e.replace(stack, node, newValue); err != nil {
+		return nil, fmt.Errorf("set %s: %w", path, err)
+	}
+	return e.result(path)
+}
+
+// parseValue parses a command-line value as a YAML node. A bare "*name"
+// becomes an alias of an anchor defined in the config file.
+func parseValue(value string) (*yaml.Node, error) {
+	if aliasRegex.MatchString(value) {
+		return &yaml.Node{Kind: yaml.AliasNode, Value: value[1:]}, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, fmt.Errorf("parse value %q: %w", value, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// frame records how a node was reached: parent.Content[index].
+type frame struct {
+	parent *yaml.Node
+	index  int
+}
+
+// editor splices rendered values into the original document text.
+type editor struct {
+	data       []byte
+	lineStarts []int
+}
+
+// result returns the edited document after making sure it still decodes
+// into Config.
+func (e *editor) result(path string) ([]byte, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(e.data, &cfg); err != nil {
+		return nil, fmt.Errorf("set %s: %w", path, configtypes.WithPath(e.data, err))
+	}
+	return e.data, nil
+}
+
+func lineStarts(data []byte) []int {
+	starts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// offset converts a 1-based line and rune column to a byte offset.
+func (e *editor) offset(line, column int) int {
+	off := e.lineStarts[line-1]
+	for col := 1; col < column && off < len(e.data); col++ {
+		_, size := utf8.DecodeRune(e.data[off:])
+		off += size
+	}
+	return off
+}
+
+// lineEnd returns the offset of the newline ending line (or the end of data).
+func (e *editor) lineEnd(line int) int {
+	if line < len(e.lineStarts) {
+		return e.lineStarts[line] - 1
+	}
+	return len(e.data)
+}
+
+func (e *editor) line(line int) string {
+	return string(e.data[e.lineStarts[line-1]:e.lineEnd(line)])
+}
+
+func (e *editor) lineCount() int {
+	n := len(e.lineStarts)
+	if e.lineStarts[n-1] == len(e.data) {
+		n-- // trailing newline
+	}
+	return n
+}
+
+func (e *editor) splice(start, end int, text string) {
+	var buf bytes.Buffer
+	buf.Write(e.data[:start])
+	buf.WriteString(text)
+	buf.Write(e.data[end:])
+	e.data = buf.Bytes()
+}
+
+// nextLine returns the line of the first node after the subtree reached
+// through stack, or one past the last line.
+func (e *editor) nextLine(stack []frame) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		f := stack[i]
+		if f.index+1 < len(f.parent.Content) {
+			return f.parent.Content[f.index+1].Line
+		}
+	}
+	return e.lineCount() + 1
+}
+
+// lastLine returns the last line holding content of the block subtree
+// reached through stack. Trailing blank and comment-only lines are left to
+// whatever follows.
+func (e *editor) lastLine(stack []frame, from int) int {
+	last := e.nextLine(stack) - 1
+	for last > from {
+		text := strings.TrimSpace(e.line(last))
+		if text != "" && !strings.HasPrefix(text, "#") {
+			break
+		}
+		last--
+	}
+	return last
+}
+
+// flowAncestor returns the depth of the outermost flow collection in stack.
+func flowAncestor(stack []frame) (int, bool) {
+	for i, f := range stack {
+		if f.parent.Style&yaml.FlowStyle != 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func isBlockCollection(n *yaml.Node) bool {
+	return (n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode) && n.Style&yaml.FlowStyle == 0
+}
+
+func hasProperties(n *yaml.Node) bool {
+	return n.Anchor != "" || n.Style&yaml.TaggedStyle != 0
+}
+
+// replace swaps the value node reached through stack for newValue.
+func (e *editor) replace(stack []frame, old, newValue *yaml.Node) error {
+	top := stack[len(stack)-1]
+	if newValue.Anchor == "" {
+		newValue.Anchor = old.Anchor
+	}
+
+	// Values inside flow collections are edited by re-rendering the
+	// outermost flow collection, which spans a line or two at most.
+	if depth, ok := flowAncestor(stack); ok {
+		top.parent.Content[top.index] = newValue
+		return e.rewriteFlow(stack[depth].parent)
+	}
+
+	var key *yaml.Node
+	if top.parent.Kind == yaml.MappingNode {
+		key = top.parent.Content[top.index-1]
+	}
+	start := e.offset(old.Line, old.Column)
+
+	if !isBlockCollection(old) && old.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		end, err := e.inlineEnd(old, start)
+		if err != nil {
+			return err
+		}
+		text, err := renderFlow(newValue)
+		if err != nil {
+			return err
+		}
+		e.splice(start, end, text)
+		return nil
+	}
+
+	// Block values end with the last content line before the next node
+	last := e.lastLine(stack, old.Line)
+	end := e.lineEnd(last)
+
+	if isBlockCollection(old) && (newValue.Kind == yaml.MappingNode || newValue.Kind == yaml.SequenceNode) {
+		bodyStart := start
+		if hasProperties(old) && len(old.Content) > 0 {
+			// Keep "&anchor" on its line and replace the body below it
+			first := old.Content[0]
+			bodyStart = e.lineStarts[first.Line-1]
+			for e.data[bodyStart] == ' ' || e.data[bodyStart] == '\t' {
+				bodyStart++
+			}
+			newValue.Anchor = ""
+		}
+		indent := strings.Repeat(" ", bodyStart-e.lineStarts[lineOf(e.lineStarts, bodyStart)])
+		text, err := renderBlock(newValue, indent)
+		if err != nil {
+			return err
+		}
+		e.splice(bodyStart, end, text)
+		return nil
+	}
+
+	text, err := renderFlow(newValue)
+	if err != nil {
+		return err
+	}
+	if key == nil || old.Line == key.Line {
+		e.splice(start, end, text)
+		return nil
+	}
+
+	// A block value below its key becomes an inline value after "key:",
+	// keeping a comment on the key line.
+	colon, err := e.colonAfter(key)
+	if err != nil {
+		return err
+	}
+	if comment := strings.TrimSpace(string(e.data[colon:e.lineEnd(key.Line)])); comment != "" {
+		text += " " + comment
+	}
+	e.splice(colon, end, " "+text)
+	return nil
+}
+
+// insert appends key: value to mapping m reached through stack.
+func (e *editor) insert(stack []frame, m *yaml.Node, key string, value *yaml.Node) error {
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	if m.Style&yaml.FlowStyle != 0 || len(m.Content) == 0 {
+		m.Style |= yaml.FlowStyle
+		m.Content = append(m.Content, keyNode, value)
+		if depth, ok := flowAncestor(stack); ok {
+			return e.rewriteFlow(stack[depth].parent)
+		}
+		return e.rewriteFlow(m)
+	}
+
+	text, err := renderFlow(value)
+	if err != nil {
+		return err
+	}
+	keyText, err := renderFlow(keyNode)
+	if err != nil {
+		return err
+	}
+
+	first := m.Content[0]
+	indent := strings.Repeat(" ", first.Column-1)
+	at := e.lineEnd(e.lastLine(stack, first.Line))
+	line := indent + keyText + ": " + text
+	if at == len(e.data) {
+		e.splice(at, at, "\n"+line+"\n")
+	} else {
+		e.splice(at+1, at+1, line+"\n")
+	}
+	return nil
+}
+
+// rewriteFlow re-renders the flow collection n in place.
+func (e *editor) rewriteFlow(n *yaml.Node) error {
+	start := e.offset(n.Line, n.Column)
+	end, err := e.inlineEnd(n, start)
+	if err != nil {
+		return err
+	}
+	text, err := renderFlow(n)
+	if err != nil {
+		return err
+	}
+	e.splice(start, end, text)
+	return nil
+}
+
+// inlineEnd returns the end offset of a scalar, alias or flow collection
+// starting at start.
+func (e *editor) inlineEnd(n *yaml.Node, start int) (int, error) {
+	i := start
+	// Skip "&anchor" and "!tag" properties
+	for i < len(e.data) && (e.data[i] == '&' || e.data[i] == '!') {
+		for i < len(e.data) && !isSpace(e.data[i]) {
+			i++
+		}
+		for i < len(e.data) && isSpace(e.data[i]) {
+			i++
+		}
+	}
+	if i >= len(e.data) {
+		return i, nil
+	}
+
+	switch {
+	case n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode:
+		return matchBracket(e.data, i)
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		for j := i + 1; j < len(e.data); j++ {
+			switch e.data[j] {
+			case '\\':
+				j++
+			case '"':
+				return j + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated quoted value at line %d", n.Line)
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		for j := i + 1; j < len(e.data); j++ {
+			if e.data[j] != '\'' {
+				continue
+			}
+			if j+1 < len(e.data) && e.data[j+1] == '\'' {
+				j++
+				continue
+			}
+			return j + 1, nil
+		}
+		return 0, fmt.Errorf("unterminated quoted value at line %d", n.Line)
+	}
+
+	// Plain scalars and aliases end at a comment or the end of the line
+	j := i
+	for j < len(e.data) && e.data[j] != '\n' {
+		if e.data[j] == '#' && j > i && isSpace(e.data[j-1]) {
+			break
+		}
+		j++
+	}
+	for j > i && isSpace(e.data[j-1]) {
+		j--
+	}
+	return j, nil
+}
+
+// colonAfter returns the offset just after the ':' that ends key.
+func (e *editor) colonAfter(key *yaml.Node) (int, error) {
+	i := e.offset(key.Line, key.Column)
+	if key.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		var err error
+		if i, err = e.inlineEnd(key, i); err != nil {
+			return 0, err
+		}
+	}
+	lineEnd := e.lineEnd(key.Line)
+	for ; i < lineEnd; i++ {
+		if e.data[i] == ':' && (i+1 == lineEnd || isSpace(e.data[i+1]) || e.data[i+1] == '\r') {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("no ':' after key %s at line %d", key.Value, key.Line)
+}
+
+func matchBracket(data []byte, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated flow collection at offset %d", start)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func lineOf(starts []int, off int) int {
+	line := 0
+	for i, s := range starts {
+		if s > off {
+			break
+		}
+		line = i
+	}
+	return line
+}
+
+// renderFlow renders n on a single line.
+func renderFlow(n *yaml.Node) (string, error) {
+	setFlow(n, true)
+	return render(n)
+}
+
+// renderBlock renders n in block style. Lines after the first are prefixed
+// with indent, the first one is placed where the old value started.
+func renderBlock(n *yaml.Node, indent string) (string, error) {
+	setFlow(n, false)
+	text, err := render(n)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(text, "\n", "\n"+indent), nil
+}
+
+func setFlow(n *yaml.Node, flow bool) {
+	if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+		if flow {
+			n.Style |= yaml.FlowStyle
+		} else {
+			n.Style &^= yaml.FlowStyle
+		}
+	}
+	for _, c := range n.Content {
+		setFlow(c, flow)
+	}
+}
+
+func render(n *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(n); err != nil {
+		return "", fmt.Errorf("render value: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("render value: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
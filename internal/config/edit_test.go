@@ -0,0 +1,229 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const commentedConfig = `# gcx configuration
+out_dir: dist # where artifacts go
+
+# Shared target list
+x-oses: &oses
+  - linux
+  - darwin
+
+builds:
+  # The main binary
+  - main: ./cmd/app
+    goos: *oses
+    goarch:
+      - amd64 # most servers
+      - arm64
+
+    # Linker flags
+    ldflags: ["-s -w", "-X main.version={{.Version}}"]
+
+  - main: ./cmd/worker # background jobs
+    goos:
+      - linux
+    goarch: [amd64]
+    overrides:
+      - goos: linux # static binaries
+        env: [CGO_ENABLED=0]
+
+archives:
+  - formats: [tar.gz, zip]
+    name_template: "{{.Binary}}_{{.Os}}_{{.Arch}}" # keep in sync with install.sh
+`
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		value string
+		// old and new describe the only lines expected to change
+		old, new string
+	}{
+		{
+			name:  "block sequence",
+			path:  "builds[0].goarch",
+			value: "[amd64, arm64, riscv64]",
+			old:   "      - amd64 # most servers\n      - arm64\n",
+			new:   "      - amd64\n      - arm64\n      - riscv64\n",
+		},
+		{
+			name:  "block sequence to scalar keeps key comment",
+			path:  "builds[1].goos",
+			value: "*oses",
+			old:   "    goos:\n      - linux\n",
+			new:   "    goos: *oses\n",
+		},
+		{
+			name:  "scalar keeps line comment",
+			path:  "out_dir",
+			value: "dist/{{.Version}}",
+			old:   "out_dir: dist # where",
+			new:   "out_dir: dist/{{.Version}} # where",
+		},
+		{
+			name:  "quoted scalar",
+			path:  "archives[0].name_template",
+			value: "'{{.Binary}}_{{.Version}}'",
+			old:   `name_template: "{{.Binary}}_{{.Os}}_{{.Arch}}" # keep`,
+			new:   `name_template: '{{.Binary}}_{{.Version}}' # keep`,
+		},
+		{
+			name:  "flow sequence",
+			path:  "builds[1].goarch",
+			value: "[amd64, arm64]",
+			old:   "    goarch: [amd64]\n",
+			new:   "    goarch: [amd64, arm64]\n",
+		},
+		{
+			name:  "item of flow sequence",
+			path:  "archives[0].formats[0]",
+			value: "zip",
+			old:   "formats: [tar.gz, zip]",
+			new:   "formats: [zip, zip]",
+		},
+		{
+			name:  "alias",
+			path:  "builds[0].goos",
+			value: "[windows]",
+			old:   "    goos: *oses\n",
+			new:   "    goos: [windows]\n",
+		},
+		{
+			name:  "new key is appended to its mapping",
+			path:  "builds[0].output_name",
+			value: "app",
+			old:   "    ldflags: [\"-s -w\", \"-X main.version={{.Version}}\"]\n",
+			new:   "    ldflags: [\"-s -w\", \"-X main.version={{.Version}}\"]\n    output_name: app\n",
+		},
+		{
+			name:  "inline field of an override",
+			path:  "builds[1].overrides[0].goos",
+			value: "darwin",
+			old:   "      - goos: linux # static binaries\n",
+			new:   "      - goos: darwin # static binaries\n",
+		},
+		{
+			name:  "new inline field of an override",
+			path:  "builds[1].overrides[0].goarch",
+			value: "arm64",
+			old:   "        env: [CGO_ENABLED=0]\n",
+			new:   "        env: [CGO_ENABLED=0]\n        goarch: arm64\n",
+		},
+		{
+			name:  "new top-level key",
+			path:  "concurrency",
+			value: "4",
+			old:   "# keep in sync with install.sh\n",
+			new:   "# keep in sync with install.sh\nconcurrency: 4\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(commentedConfig, tt.old) {
+				t.Fatalf("test config does not contain %q", tt.old)
+			}
+			got, err := Set([]byte(commentedConfig), tt.path, tt.value)
+			if err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			want := strings.Replace(commentedConfig, tt.old, tt.new, 1)
+			if string(got) != want {
+				t.Errorf("Set() produced unrelated changes:\n--- got\n%s\n--- want\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSetAnchoredValue(t *testing.T) {
+	data := `builds:
+  - main: ./cmd/app
+    goos: &oses
+      - linux
+    goarch: [amd64]
+  - main: ./cmd/worker
+    goos: *oses
+    goarch: [amd64]
+`
+	got, err := Set([]byte(data), "builds[0].goos", "[darwin, windows]")
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	want := strings.Replace(data, "      - linux\n", "      - darwin\n      - windows\n", 1)
+	if string(got) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The anchor survives, so aliases follow the new value
+	var cfg Config
+	if err := yaml.Unmarshal(got, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cfg.Builds[1].Goos, ",") != "darwin,windows" {
+		t.Errorf("aliased goos = %v", cfg.Builds[1].Goos)
+	}
+
+	if _, err := Set([]byte(want), "builds[1].goos[0]", "linux"); err == nil || !strings.Contains(err.Error(), "alias") {
+		t.Errorf("editing through an alias should fail, got %v", err)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		value string
+		want  string
+	}{
+		{name: "unknown field", path: "builds[0].gooss", value: "linux", want: "unknown field builds[0].gooss"},
+		{name: "unknown override field", path: "builds[1].overrides[0].gooss", value: "linux", want: "unknown field builds[1].overrides[0].gooss"},
+		{name: "wrong type", path: "builds[0].goarch", value: "amd64", want: "cannot unmarshal"},
+		{name: "index out of range", path: "builds[5].main", value: ".", want: "out of range"},
+		{name: "invalid path", path: "builds[x]", value: ".", want: "invalid path"},
+		{name: "missing parent", path: "gc.keep_last", value: "3", want: "gc not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Set([]byte(commentedConfig), tt.path, tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Set() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	cfg := &Config{
+		OutDir: "dist",
+		Builds: []BuildConfig{{Main: "./cmd/app", Goos: []string{"linux"}, Goarch: []string{"amd64"}}},
+	}
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"out_dir: dist # Output directory",
+		"- main: ./cmd/app # Path to the main package",
+		"goos: # Target operating systems",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Marshal() output is missing %q:\n%s", want, data)
+		}
+	}
+
+	var decoded Config
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Marshal() output does not parse: %v", err)
+	}
+	if decoded.Builds[0].Main != "./cmd/app" || decoded.Builds[0].Goos[0] != "linux" {
+		t.Errorf("round trip mismatch: %+v", decoded.Builds[0])
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldDocs holds the short descriptions Marshal writes next to each field,
+// keyed by YAML path without sequence indexes (e.g. "builds.goos").
+var fieldDocs = map[string]string{
+	"out_dir":           "Output directory; may use {{.Version}}, e.g. dist/{{.Version}}",
+	"concurrency":       "Max parallel builds and archives (default: number of CPUs)",
+	"parallelism":       "Max concurrent go build processes across all targets (default: concurrency)",
+	"project_name":      "Project name for archive name templates (default: config directory name)",
+	"version_format":    "How {{.Version}} renders the tag: raw (default), with_v or without_v; {{.Tag}} stays raw",
+	"go_version":        "Required go toolchain, e.g. >=1.22",
+	"strict_toolchain":  "Warn when the toolchain is newer than go.mod declares",
+	"staging_strategy":  "How prebuilt and binary-format files enter out_dir: hardlink (default; copy across filesystems), copy or move",
+	"goprivate":         "GOPRIVATE patterns for hooks and every build, e.g. github.com/acme/*",
+	"git_auth":          "Token for private modules, given to hooks and go build via a temporary .netrc",
+	"before":            "Hooks executed before the build",
+	"after":             "Hooks executed after the build",
+	"before.hooks":      "Shell commands run sequentially via sh -c, or {cmd, tags} objects",
+	"after.hooks":       "Shell commands run sequentially via sh -c, or {cmd, tags} objects",
+	"before.hooks.cmd":  "Shell command run via sh -c",
+	"before.hooks.tags": "Tags for --hooks-tags/--skip-hooks-tags; untagged hooks always run",
+	"after.hooks.cmd":   "Shell command run via sh -c",
+	"after.hooks.tags":  "Tags for --hooks-tags/--skip-hooks-tags; untagged hooks always run",
+	"builds":            "Build configurations",
+	"embed_checks":      "Fail the build when embedded assets are older than their sources (gcx build --skip-embed-checks skips them)",
+	"archives":          "Archive settings",
+	"checksum":          "Checksums files written to out_dir",
+	"signs":             "Sign the checksums files with ssh-keygen -Y sign, or also the archives with cosign",
+	"sboms":             "Generate <artifact>.sbom.json per archive with syft or another tool",
+	"generated_files":   "Extra release files rendered from templates after archiving",
+	"publish":           "Settings for the whole publish stage",
+	"blobs":             "Publish destinations",
+	"announce":          "Announcers run after every destination is published (gcx publish --skip-announce skips them)",
+	"deploys":           "Deploy targets",
+	"deploy_policy":     "Deny/allow-lists checked against deploy commands",
+	"gc":                "Pruning budgets for gcx gc",
+	"changelog":         "Settings for gcx release changelog",
+	"release":           "Deadline budget of gcx release",
+
+	"builds.main":                    "Path to the main package; supports templates (then set output_name)",
+	"builds.dir":                     "Working directory of go build, e.g. a go.work module (default: the config directory)",
+	"builds.gobinary":                "Tool run instead of go, e.g. garble or tinygo (on PATH or relative to dir)",
+	"builds.command":                 "Subcommand of gobinary (default: build)",
+	"builds.id":                      "Build id referenced by the builds filters of archives and blobs",
+	"builds.output_name":             "Binary name (default: last element of main)",
+	"builds.disable_platform_suffix": "Do not add _os_arch to the output directory",
+	"builds.goos":                    "Target operating systems",
+	"builds.goarch":                  "Target architectures",
+	"builds.goarm":                   "ARM versions for goarch arm",
+	"builds.goamd64":                 "GOAMD64 levels for goarch amd64, e.g. v1, v3",
+	"builds.goarm64":                 "GOARM64 versions for goarch arm64, e.g. v8.0, v9.0,lse",
+	"builds.gomips":                  "GOMIPS values for goarch mips and mipsle: hardfloat or softfloat",
+	"builds.goriscv64":               "GORISCV64 profiles for goarch riscv64: rva20u64, rva22u64 or rva23u64",
+	"builds.targets":                 "exact targets as goos/goarch[/variant], e.g. linux/arm/v7, instead of goos, goarch and variant lists",
+	"builds.allow_unknown_targets":   "Do not check targets against go tool dist list, e.g. for an experimental toolchain",
+	"builds.flags":                   "Flags passed to go build; support templates, empty ones are omitted",
+	"builds.ldflags":                 "Linker flags; support {{.Version}}, {{.Commit}}, {{.Date}} and {{.Env.NAME}}",
+	"builds.gcflags":                 "Compiler flags passed as -gcflags, e.g. all=-N -l; support templates",
+	"builds.asmflags":                "Assembler flags passed as -asmflags; support templates",
+	"builds.buildmode":               "Passed as -buildmode, e.g. pie or c-shared; c-shared outputs .so, .dylib or .dll plus a .h header",
+	"builds.timeout":                 "Kill the go build of one target after this long, e.g. 10m, and fail naming it",
+	"builds.env":                     "Extra environment variables for go build; values support templates",
+	"builds.tags":                    "Build tags passed as one -tags argument; support {{.Env.NAME}}",
+	"builds.extensions":              "Binary extension per GOOS (defaults: windows .exe, js/wasip1 .wasm)",
+	"builds.include_wasm_exec":       "Copy wasm_exec.js from the Go distribution next to js/wasm binaries",
+	"builds.only_if_changed":         "Skip the build unless a matching file changed since the previous tag",
+	"builds.tag_prefix":              "Only compare tags with this prefix for only_if_changed",
+	"builds.group":                   "Builds with the same group share output directories and archives",
+	"builds.prebuilt":                "Copy existing binaries instead of running go build",
+	"builds.embed_changelog":         "Set a string variable (e.g. main.changelog) to the release changelog",
+	"builds.git_auth":                "Credentials for this build's private modules; replaces the top-level git_auth",
+	"builds.ignore":                  "goos/goarch/goarm combinations to skip (default: arm only on linux)",
+	"builds.overrides":               "flags, ldflags, gcflags, asmflags, env and buildmode for matching goos/goarch/goarm targets; merge: append or replace",
+	"builds.upx":                     "Compress binaries with upx before archiving (gcx build --skip-upx skips it)",
+	"builds.cgo":                     "Build with CGO_ENABLED=1 and a C toolchain per target",
+	"builds.cgo.strict":              "Fail when a target has no toolchain (default: build it with CGO_ENABLED=0)",
+	"builds.cgo.targets":             "goos/goarch (or goos/arm/armN) -> cc, cxx, cflags (CGO_CFLAGS), ldflags (CGO_LDFLAGS)",
+	"builds.coverage":                "Coverage-instrumented build with go build -cover; output dirs end in _cover",
+	"builds.coverage.covermode":      "set, count or atomic (default: set)",
+
+	"git_auth.token_env": "Environment variable holding the token (never written to dist or logs)",
+	"git_auth.username":  "Username sent with the token (default: x-access-token; GitLab: oauth2)",
+	"git_auth.hosts":     "Hosts that receive the token, e.g. github.com",
+
+	"archives.formats":           "Archive formats: tar, tar.gz, tar.xz, tar.zst, zip, or binary for the bare binary",
+	"archives.name_template":     "Archive name without extension; {{.ShortSha256}} embeds the archive hash",
+	"archives.compression_level": "gzip level of tar.gz (1-9) and zstd level of tar.zst (1-22) archives",
+	"archives.files":             "Extra files (globs relative to the config directory) copied into every archive",
+	"archives.files.src":         "Glob of files or directories to add, e.g. LICENSE* or completions/*",
+	"archives.files.dst":         "Directory inside the archive for the matches (default: their relative path)",
+	"archives.strict":            "Fail the build when a files glob matches nothing",
+	"archives.builds":            "Only archive the artifacts of these build ids (default: all builds)",
+	"archives.reproducible":      "Normalize entry times (SOURCE_DATE_EPOCH or the commit time) and modes",
+	"archives.verify_contents":   "Fail when an executable in an archive is not built for the archive's goos/goarch",
+
+	"embed_checks.path":       "Embedded file or directory, e.g. ui/dist",
+	"embed_checks.newer_than": "Source files, directories or globs the path must not be older than",
+	"embed_checks.compare":    "auto (default: hash for a clean git checkout, else mtime), mtime or hash",
+	"embed_checks.stamp":      "File recording the SHA-256 of the sources, rewritten when the mtimes show the path is fresh",
+
+	"changelog.repo_url":      "Repository web URL for the compare link when the checkout has no remote",
+	"changelog.group_by":      "Group conventional commits into sections: type, scope or type_then_scope",
+	"changelog.scope_aliases": "Scopes listed under another scope, e.g. {apiv2: api}",
+	"release.timeout":         "Bound the whole gcx release, e.g. 45m; each stage gets a share (gcx release --timeout overrides it)",
+	"release.stage_weights":   "Share of timeout per stage (build, publish, deploy); unlisted stages weigh 1",
+
+	"checksum.algorithm":   "sha256, sha512, sha1, md5 or blake2b, or a list for one file per algorithm",
+	"checksum.format":      "gnu (<hex>  <name>, default) or bsd (SHA256 (<name>) = <hex>)",
+	"checksum.path_prefix": "Directory template names are recorded under, e.g. {{.Version}}",
+
+	"blobs.enabled":                      `Template rendering to true or false, e.g. '{{ eq .Channel "stable" }}'`,
+	"blobs.max_object_size":              "Largest file the destination accepts, e.g. 2GiB (default: no limit)",
+	"blobs.oversize":                     "Larger files: fail (default), skip with a warning, or split into NAME.partNNN plus NAME.parts.json",
+	"blobs.dedupe":                       "plain (default) or content_addressed: s3 stores each content once under cas/<sha256> and copies it server-side",
+	"blobs.builds":                       "Only upload the artifacts of these build ids (default: all files)",
+	"blobs.types":                        "Only upload artifacts of these types: binary, archive, checksum, sbom or file (default: all types)",
+	"blobs.publish_metadata":             "Also upload gcx metadata such as artifacts.json (default: excluded)",
+	"blobs.metadata":                     "S3 user metadata (x-amz-meta-*) per object; values support {{.Commit}}, {{.Env.NAME}}",
+	"blobs.content_disposition_template": `S3 Content-Disposition per object, e.g. 'attachment; filename="{{.Name}}"'`,
+
+	"announce.download_url":        "Artifact link template; object_template fields and {{.Object}}, the remote object name",
+	"announce.blob":                "Blob whose object_template names the linked objects (default: the only blob)",
+	"announce.announcers.type":     "shoutrrr (urls, template), webhook (url, headers; JSON POST) or exec (command; JSON on stdin)",
+	"announce.announcers.template": "shoutrrr message; .Version, .Channel, .Changelog, .Duration, .Artifacts (Name, URL)",
+	"announce.announcers.headers":  "Webhook request headers; values support {{.Env.NAME}}",
+	"announce.announcers.enabled":  "Template rendering to true or false; skips the announcer when false",
+
+	"deploys.commands":       "Commands, or {include_url, sha256} to splice in a pinned remote list",
+	"deploys.steps":          "Run, download and upload steps; replaces commands",
+	"deploys.request_pty":    "Run commands on a pseudo-terminal (sudo with requiretty, docker login)",
+	"deploys.enabled":        "Template rendering to true or false; skips the deploy when false",
+	"deploys.approval":       "Approval gate before any command: command (exit 0) or url polled for {\"status\": \"approved\"}",
+	"deploys.post_logs":      "Remote command tailed after the deploy; error_pattern fails it, stop_pattern or duration (1m) end it",
+	"deploys.skip_unchanged": "ssh: keep upload/download dests already matching their sha256; skip only_on_change steps when nothing changed",
+	"deploys.coverdir":       "GOCOVERDIR of the commands once a coverage-instrumented artifact ships (releases: base_path/shared/coverage)",
+}
+
+// Marshal encodes cfg as YAML, annotating every field with a line comment
+// from fieldDocs.
+func Marshal(cfg *Config) ([]byte, error) {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("encode config: %w", err)
+	}
+	annotate(&root, "")
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func annotate(n *yaml.Node, prefix string) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			path := key.Value
+			if prefix != "" {
+				path = prefix + "." + key.Value
+			}
+			if doc, ok := fieldDocs[path]; ok {
+				key.LineComment = doc
+			}
+			annotate(value, path)
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			annotate(item, prefix)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// s3AvoidChars are the characters AWS advises against in object keys, as
+// many tools and URLs mangle them.
+const s3AvoidChars = "{}^%`[]\"<>~#|"
+
+// Length limits of remote directories.
+const (
+	s3MaxKeyLength  = 1024
+	posixNameLength = 255
+)
+
+// normalizeRemoteDir cleans the rendered blob directory dir and checks it
+// against the path rules of provider, so a bad template fails before
+// anything connects rather than deep into the upload. Doubled slashes, "."
+// segments and trailing slashes are removed; S3 keys also lose a leading
+// slash. Traversal with "..", control and invisible formatting characters,
+// backslashes and segments with surrounding whitespace are rejected for
+// every provider.
+func normalizeRemoteDir(provider, dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if !utf8.ValidString(dir) {
+		return "", errors.New("is not valid UTF-8")
+	}
+	for _, r := range dir {
+		if unicode.In(r, unicode.Cc, unicode.Cf) {
+			return "", fmt.Errorf("contains the control character %U, e.g. a newline from a template", r)
+		}
+	}
+	if strings.Contains(dir, `\`) {
+		return "", errors.New(`contains a backslash; separate directories with "/"`)
+	}
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == ".." {
+			return "", errors.New(`contains "..", which leaves the directory`)
+		}
+		if strings.TrimSpace(segment) != segment {
+			return "", fmt.Errorf("segment %q starts or ends with whitespace", segment)
+		}
+	}
+
+	switch provider {
+	case "s3":
+		if i := strings.IndexAny(dir, s3AvoidChars); i >= 0 {
+			return "", fmt.Errorf("contains %q, which S3 object keys should not use", dir[i])
+		}
+		dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+		if len(dir) >= s3MaxKeyLength {
+			return "", fmt.Errorf("is %d bytes, leaving no room for file names in the %d bytes of an S3 object key", len(dir), s3MaxKeyLength)
+		}
+	case "ssh":
+		if strings.HasPrefix(dir, "~") {
+			return "", errors.New("starts with ~, which is not expanded; use a path relative to the home directory")
+		}
+		dir = path.Clean(dir)
+		for _, segment := range strings.Split(dir, "/") {
+			if len(segment) > posixNameLength {
+				return "", fmt.Errorf("segment %.20q... is %d bytes, file names are at most %d", segment, len(segment), posixNameLength)
+			}
+		}
+	default:
+		dir = path.Clean(dir)
+	}
+	return dir, nil
+}
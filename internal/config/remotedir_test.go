@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRemoteDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		dir      string
+		want     string
+		wantErr  string
+	}{
+		{"plain", "s3", "releases/v1.0.0", "releases/v1.0.0", ""},
+		{"empty", "s3", "", "", ""},
+		{"s3 leading slash", "s3", "/releases/v1.0.0", "releases/v1.0.0", ""},
+		{"s3 root", "s3", "/", "", ""},
+		{"doubled slashes", "s3", "releases//stable///v1.0.0/", "releases/stable/v1.0.0", ""},
+		{"dot segments", "s3", "./releases/./v1.0.0", "releases/v1.0.0", ""},
+		{"ssh absolute", "ssh", "/var/www//releases/v1.0.0/", "/var/www/releases/v1.0.0", ""},
+		{"ssh relative", "ssh", "releases/v1.0.0", "releases/v1.0.0", ""},
+		{"unicode", "s3", "релизы/版本/v1.0.0", "релизы/版本/v1.0.0", ""},
+		{"inner spaces", "ssh", "/srv/my app/v1.0.0", "/srv/my app/v1.0.0", ""},
+		{"traversal", "ssh", "/var/www/../../etc", "", `contains ".."`},
+		{"traversal from version", "s3", "releases/../../v1.0.0", "", `contains ".."`},
+		{"lone dotdot", "s3", "..", "", `contains ".."`},
+		{"newline", "s3", "releases/v1.0.0\n", "", "control character U+000A"},
+		{"tab", "ssh", "/srv/\tv1", "", "control character U+0009"},
+		{"NUL", "ssh", "/srv/v1\x00", "", "control character U+0000"},
+		{"bidi override", "s3", "releases/\u202ev1", "", "control character U+202E"},
+		{"invalid UTF-8", "s3", "releases/\xffv1", "", "not valid UTF-8"},
+		{"backslash", "s3", `releases\v1.0.0`, "", "backslash"},
+		{"leading space", "s3", "releases/ v1.0.0", "", `segment " v1.0.0"`},
+		{"trailing space", "ssh", "/srv/v1.0.0 ", "", `segment "v1.0.0 "`},
+		{"non-breaking space", "s3", "releases/v1\u00a0", "", "whitespace"},
+		{"s3 braces from a broken template", "s3", "releases/{v1}", "", `contains '{'`},
+		{"s3 hash", "s3", "releases/#1", "", `contains '#'`},
+		{"s3 percent", "s3", "releases/100%", "", `contains '%'`},
+		{"ssh allows hash", "ssh", "/srv/#1", "/srv/#1", ""},
+		{"s3 too long", "s3", strings.Repeat("a", 1024), "", "1024 bytes"},
+		{"ssh tilde", "ssh", "~/releases", "", "not expanded"},
+		{"ssh long segment", "ssh", "/srv/" + strings.Repeat("a", 256), "", "256 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeRemoteDir(tt.provider, tt.dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("normalizeRemoteDir(%q, %q) error = %v, want %q", tt.provider, tt.dir, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("normalizeRemoteDir(%q, %q) = %q, %v; want %q", tt.provider, tt.dir, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlobConfigRemoteDir(t *testing.T) {
+	blob := BlobConfig{
+		Name: "s3", Provider: "s3", Bucket: "b", Endpoint: "https://s3.example.com",
+		Directory: "/releases/{{.Channel}}/{{.Version}}",
+	}
+	if err := blob.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := blob.RemoteDir(ReleaseData{Version: "v1.2.0", Channel: "beta"}); err != nil || got != "releases/beta/v1.2.0" {
+		t.Errorf("RemoteDir() = %q, %v; want releases/beta/v1.2.0", got, err)
+	}
+
+	// The error shows the rendered value
+	_, err := blob.RemoteDir(ReleaseData{Version: "../v1.2.0", Channel: "beta"})
+	if want := `directory rendered to "/releases/beta/../v1.2.0"`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("RemoteDir() error = %v, want %q", err, want)
+	}
+
+	blob.Directory = "releases/{{.Version}}\n"
+	if err := blob.Validate(); err == nil || !strings.Contains(err.Error(), "control character U+000A") {
+		t.Errorf("Validate() error = %v, want the newline rejected", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Package configtypes provides human-friendly value types for the gcx
+// configuration, such as durations ("90s", "1h30m", "30d") and sizes
+// ("10MB", "1.5GiB"), with YAML decoding errors that point at the offending
+// field.
+package configtypes
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error describes a config value that could not be parsed.
+type Error struct {
+	Kind    string // "duration", "size" or "list"
+	Value   string
+	Example string
+	Line    int
+	Column  int
+	// Path is the YAML path of the value (e.g. "builds[0].timeout"). It is
+	// filled in by WithPath once the whole document is available.
+	Path string
+}
+
+func (e *Error) Error() string {
+	var where string
+	switch {
+	case e.Path != "" && e.Line > 0:
+		where = fmt.Sprintf(" at %s (line %d)", e.Path, e.Line)
+	case e.Path != "":
+		where = " at " + e.Path
+	case e.Line > 0:
+		where = fmt.Sprintf(" at line %d", e.Line)
+	}
+	return fmt.Sprintf("invalid %s %q%s: expected a value like %s", e.Kind, e.Value, where, e.Example)
+}
+
+// WithPath resolves the YAML path of a configtypes error returned while
+// decoding data. Errors of other types are returned unchanged.
+func WithPath(data []byte, err error) error {
+	var valueErr *Error
+	if !errors.As(err, &valueErr) || valueErr.Path != "" || valueErr.Line == 0 {
+		return err
+	}
+
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) != nil {
+		return err
+	}
+	if path, ok := NodePath(&root, valueErr.Line, valueErr.Column); ok {
+		valueErr.Path = path
+	}
+	return err
+}
+
+// NodePath returns the dotted YAML path (e.g. "deploys[1].alerts.urls[0]")
+// of the node located at line and column.
+func NodePath(root *yaml.Node, line, column int) (string, bool) {
+	return nodePath(root, "", line, column)
+}
+
+func nodePath(n *yaml.Node, prefix string, line, column int) (string, bool) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if p, ok := nodePath(c, prefix, line, column); ok {
+				return p, true
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			p := key.Value
+			if prefix != "" {
+				p = prefix + "." + key.Value
+			}
+			if value.Line == line && value.Column == column {
+				return p, true
+			}
+			if found, ok := nodePath(value, p, line, column); ok {
+				return found, true
+			}
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := prefix + "[" + strconv.Itoa(i) + "]"
+			if c.Line == line && c.Column == column {
+				return p, true
+			}
+			if found, ok := nodePath(c, p, line, column); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
+
+// scalarValue returns the trimmed scalar value of a node or a parse error.
+func scalarValue(n *yaml.Node, kind, example string) (string, error) {
+	if n.Kind != yaml.ScalarNode {
+		return "", &Error{Kind: kind, Value: n.Tag, Example: example, Line: n.Line, Column: n.Column}
+	}
+	return strings.TrimSpace(n.Value), nil
+}
@@ -0,0 +1,245 @@
+package configtypes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "90s", want: 90 * time.Second},
+		{in: "5m", want: 5 * time.Minute},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "30d", want: 30 * 24 * time.Hour},
+		{in: "1d12h", want: 36 * time.Hour},
+		{in: "1.5d", want: 36 * time.Hour},
+		{in: "250ms", want: 250 * time.Millisecond},
+		{in: "0", want: 0},
+		{in: "", wantErr: true},
+		{in: "90", wantErr: true},
+		{in: "five minutes", wantErr: true},
+		{in: "-5m", wantErr: true},
+		{in: "5x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got.Std() != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got.Std(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "10MB", want: 10_000_000},
+		{in: "10mb", want: 10_000_000},
+		{in: "10 MB", want: 10_000_000},
+		{in: "1K", want: 1000},
+		{in: "1KiB", want: 1024},
+		{in: "1.5GiB", want: 1536 << 20},
+		{in: "2TB", want: 2_000_000_000_000},
+		{in: "1TiB", want: 1 << 40},
+		{in: "", wantErr: true},
+		{in: "MB", wantErr: true},
+		{in: "10XB", wantErr: true},
+		{in: "-1MB", wantErr: true},
+		{in: "1.2.3MB", wantErr: true},
+		{in: "99999999999TiB", wantErr: true},
+		{in: "9223372036854774784", want: 9223372036854774784},
+		{in: "9223372036854775807", wantErr: true},
+		{in: "9223372036854775808", wantErr: true},
+		{in: "8388608TiB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got.Bytes() != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	tests := []struct {
+		in   Size
+		want string
+	}{
+		{0, "0"},
+		{512, "512"},
+		{1024, "1KiB"},
+		{1536 << 20, "1536MiB"},
+		{1 << 30, "1GiB"},
+		{10_000_000, "10000000"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Size(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+type testConfig struct {
+	Timeout Duration `yaml:"timeout"`
+	Builds  []struct {
+		Limit Size `yaml:"limit"`
+	} `yaml:"builds"`
+	Nested struct {
+		Retention Duration `yaml:"retention"`
+	} `yaml:"nested"`
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	data := `
+timeout: 1h30m
+builds:
+  - limit: 10MB
+  - limit: 1.5GiB
+nested:
+  retention: 7d
+`
+	var cfg testConfig
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout.Std() != 90*time.Minute {
+		t.Errorf("Timeout = %v", cfg.Timeout)
+	}
+	if cfg.Builds[1].Limit.Bytes() != 1536<<20 {
+		t.Errorf("Builds[1].Limit = %d", cfg.Builds[1].Limit)
+	}
+	if cfg.Nested.Retention.Std() != 7*24*time.Hour {
+		t.Errorf("Retention = %v", cfg.Nested.Retention)
+	}
+}
+
+func TestUnmarshalYAMLErrorPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantPath string
+		wantKind string
+		wantLine int
+	}{
+		{
+			name:     "top-level duration",
+			data:     "timeout: soon\n",
+			wantPath: "timeout",
+			wantKind: "duration",
+			wantLine: 1,
+		},
+		{
+			name:     "size in sequence",
+			data:     "builds:\n  - limit: 10MB\n  - limit: lots\n",
+			wantPath: "builds[1].limit",
+			wantKind: "size",
+			wantLine: 3,
+		},
+		{
+			name:     "nested mapping",
+			data:     "nested:\n  retention: [1, 2]\n",
+			wantPath: "nested.retention",
+			wantKind: "duration",
+			wantLine: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+			err := WithPath([]byte(tt.data), yaml.Unmarshal([]byte(tt.data), &cfg))
+
+			var valueErr *Error
+			if !errors.As(err, &valueErr) {
+				t.Fatalf("expected *Error, got %v", err)
+			}
+			if valueErr.Path != tt.wantPath {
+				t.Errorf("Path = %q, want %q", valueErr.Path, tt.wantPath)
+			}
+			if valueErr.Kind != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", valueErr.Kind, tt.wantKind)
+			}
+			if valueErr.Line != tt.wantLine {
+				t.Errorf("Line = %d, want %d", valueErr.Line, tt.wantLine)
+			}
+			msg := err.Error()
+			if !strings.Contains(msg, tt.wantPath) || !strings.Contains(msg, "expected a value like") {
+				t.Errorf("error message lacks path or example: %q", msg)
+			}
+		})
+	}
+}
+
+func TestWithPathPassesThroughOtherErrors(t *testing.T) {
+	other := errors.New("boom")
+	if got := WithPath([]byte("a: b"), other); got != other {
+		t.Errorf("WithPath() = %v, want original error", got)
+	}
+	if WithPath(nil, nil) != nil {
+		t.Error("WithPath(nil) should return nil")
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	v := struct {
+		Timeout Duration `yaml:"timeout"`
+		Limit   Size     `yaml:"limit"`
+	}{Duration(90 * time.Second), Size(1 << 30)}
+
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "timeout: 1m30s\nlimit: 1GiB\n"; got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+}
+
+func TestStringList(t *testing.T) {
+	var v struct {
+		One  StringList `yaml:"one"`
+		Many StringList `yaml:"many"`
+	}
+	if err := yaml.Unmarshal([]byte("one: sha256\nmany: [sha256, sha512]\n"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.One) != 1 || v.One[0] != "sha256" {
+		t.Errorf("One = %v", v.One)
+	}
+	if len(v.Many) != 2 || v.Many[1] != "sha512" {
+		t.Errorf("Many = %v", v.Many)
+	}
+
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "one: sha256\nmany:\n    - sha256\n    - sha512\n"; got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+
+	if err := yaml.Unmarshal([]byte("one: {a: b}\n"), &v); err == nil || !strings.Contains(err.Error(), "invalid list") {
+		t.Errorf("mapping error = %v", err)
+	}
+}
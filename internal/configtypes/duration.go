@@ -0,0 +1,72 @@
+package configtypes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const durationExample = `"90s", "5m", "1h30m" or "30d"`
+
+var dayRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)d`)
+
+// Duration is a time.Duration that is written in config files using Go
+// duration syntax, extended with a "d" (24h) unit for retention ages.
+type Duration time.Duration
+
+// ParseDuration parses strings such as "90s", "1h30m" or "7d12h".
+func ParseDuration(s string) (Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var convErr error
+	expanded := dayRegex.ReplaceAllStringFunc(s, func(m string) string {
+		days, err := strconv.ParseFloat(m[:len(m)-1], 64)
+		if err != nil {
+			convErr = err
+			return m
+		}
+		return strconv.FormatFloat(days*24, 'f', -1, 64) + "h"
+	})
+	if convErr != nil {
+		return 0, convErr
+	}
+
+	d, err := time.ParseDuration(expanded)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration")
+	}
+	return Duration(d), nil
+}
+
+// Std returns the value as a time.Duration.
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+// String formats the duration using Go duration syntax.
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	s, err := scalarValue(n, "duration", durationExample)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseDuration(s)
+	if err != nil {
+		return &Error{Kind: "duration", Value: s, Example: durationExample, Line: n.Line, Column: n.Column}
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
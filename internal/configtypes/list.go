@@ -0,0 +1,44 @@
+package configtypes
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const listExample = `sha256 or [sha256, sha512]`
+
+// StringList is a list of strings that may also be written as a single
+// string, e.g. "algorithm: sha256" or "algorithm: [sha256, sha512]".
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *StringList) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		*l = StringList{strings.TrimSpace(n.Value)}
+		return nil
+	case yaml.SequenceNode:
+		list := make(StringList, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := scalarValue(item, "list item", listExample)
+			if err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		*l = list
+		return nil
+	default:
+		return &Error{Kind: "list", Value: n.Tag, Example: listExample, Line: n.Line, Column: n.Column}
+	}
+}
+
+// MarshalYAML implements yaml.Marshaler. A single value is written as a
+// plain string.
+func (l StringList) MarshalYAML() (any, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []string(l), nil
+}
@@ -0,0 +1,93 @@
+package configtypes
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const sizeExample = `"512", "10MB" or "1.5GiB"`
+
+var sizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"t":   1000 * 1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// Size is a number of bytes written in config files with an optional
+// decimal (KB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit.
+type Size int64
+
+// ParseSize parses strings such as "512", "10MB" or "1.5GiB".
+func ParseSize(s string) (Size, error) {
+	m := sizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("malformed size")
+	}
+	unit, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", m[2])
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	// float64(math.MaxInt64) rounds up to 1<<63, which no longer fits
+	bytes := math.Round(n * float64(unit))
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size too large")
+	}
+	return Size(bytes), nil
+}
+
+// Bytes returns the size in bytes.
+func (s Size) Bytes() int64 { return int64(s) }
+
+// String formats the size using the largest binary unit that represents it exactly.
+func (s Size) String() string {
+	for _, u := range []struct {
+		name string
+		size int64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if s != 0 && int64(s)%u.size == 0 {
+			return strconv.FormatInt(int64(s)/u.size, 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *Size) UnmarshalYAML(n *yaml.Node) error {
+	v, err := scalarValue(n, "size", sizeExample)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseSize(v)
+	if err != nil {
+		return &Error{Kind: "size", Value: v, Example: sizeExample, Line: n.Line, Column: n.Column}
+	}
+	*s = parsed
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Size) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
@@ -0,0 +1,173 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// Defaults of approval.timeout and approval.poll_interval.
+const (
+	defaultApprovalTimeout      = 30 * time.Minute
+	defaultApprovalPollInterval = 30 * time.Second
+)
+
+// ErrNotApproved matches deploys stopped by their approval gate, which are
+// reported apart from failed ones.
+var ErrNotApproved = errors.New("not approved")
+
+// NotApprovedError is a deploy whose approval was rejected or timed out.
+type NotApprovedError struct {
+	Deploy string
+	Reason string
+}
+
+func (e *NotApprovedError) Error() string {
+	return fmt.Sprintf("deploy %s not approved: %s", e.Deploy, e.Reason)
+}
+
+func (e *NotApprovedError) Is(target error) bool { return target == ErrNotApproved }
+
+// ApprovalData is the template data of approval.command and approval.url.
+type ApprovalData struct {
+	Name    string
+	Version string
+	Channel string
+}
+
+// approvalClient polls approval URLs.
+var approvalClient = &http.Client{Timeout: 30 * time.Second}
+
+// awaitApproval returns nil once the deploy is approved, and a
+// *NotApprovedError when it is rejected or approval.timeout passes. The
+// command runs in dir.
+func awaitApproval(ctx context.Context, dir string, cfg config.ApprovalConfig, data ApprovalData) error {
+	timeout := time.Duration(cfg.Timeout)
+	if timeout == 0 {
+		timeout = defaultApprovalTimeout
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	if cfg.Command != "" {
+		err = approvalCommand(waitCtx, dir, cfg.Command, data)
+	} else {
+		err = pollApproval(waitCtx, cfg, data)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		return &NotApprovedError{Deploy: data.Name, Reason: fmt.Sprintf("no approval within %s", timeout)}
+	}
+	return err
+}
+
+// approvalCommand runs command with sh -c; exit status 0 approves.
+func approvalCommand(ctx context.Context, dir, command string, data ApprovalData) error {
+	command, err := tmpl.Process("approval command", command, data)
+	if err != nil {
+		return err
+	}
+	log.Printf("Waiting for approval of %s: %s", data.Name, command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GCX_DEPLOY_NAME="+data.Name, "GCX_VERSION="+data.Version, "GCX_CHANNEL="+data.Channel)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return &NotApprovedError{Deploy: data.Name, Reason: fmt.Sprintf("approval command exited with status %d", exitErr.ExitCode())}
+	}
+	if err != nil {
+		return fmt.Errorf("approval command: %w", err)
+	}
+	return nil
+}
+
+// pollApproval requests the approval URL every poll interval until it
+// reports approved or rejected. Failed requests are retried.
+func pollApproval(ctx context.Context, cfg config.ApprovalConfig, data ApprovalData) error {
+	url, err := tmpl.Process("approval url", cfg.URL, data)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(cfg.PollInterval)
+	if interval == 0 {
+		interval = defaultApprovalPollInterval
+	}
+	var token string
+	if cfg.TokenEnv != "" {
+		if token = envvars.Named(cfg.TokenEnv); token == "" {
+			return fmt.Errorf("approval: environment variable %s is not set", cfg.TokenEnv)
+		}
+	}
+
+	log.Printf("Waiting for approval of %s", data.Name)
+	for {
+		status, err := approvalStatus(ctx, url, token)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Warning: approval of %s: %v", data.Name, err)
+		case status == "approved":
+			log.Printf("Deploy %s approved", data.Name)
+			return nil
+		case status == "rejected":
+			return &NotApprovedError{Deploy: data.Name, Reason: "rejected"}
+		default:
+			log.Printf("Approval of %s is %s; checking again in %s", data.Name, status, interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// approvalStatus returns the lower-cased status reported by url.
+func approvalStatus(ctx context.Context, url, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := approvalClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if body.Status == "" {
+		return "", fmt.Errorf(`response has no "status"`)
+	}
+	return strings.ToLower(body.Status), nil
+}
@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/configtypes"
+)
+
+func TestAwaitApprovalCommand(t *testing.T) {
+	data := ApprovalData{Name: "prod", Version: "v1.2.0", Channel: "stable"}
+	tests := []struct {
+		name    string
+		command string
+		timeout time.Duration
+		want    string
+	}{
+		{name: "approved", command: `test "{{.Version}}" = v1.2.0 && test "$GCX_DEPLOY_NAME" = prod`},
+		{name: "rejected", command: "exit 3", want: "deploy prod not approved: approval command exited with status 3"},
+		{name: "timeout", command: "exec sleep 5", timeout: 50 * time.Millisecond, want: "deploy prod not approved: no approval within 50ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.ApprovalConfig{Command: tt.command, Timeout: configtypes.Duration(tt.timeout)}
+			err := awaitApproval(context.Background(), t.TempDir(), cfg, data)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("awaitApproval() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrNotApproved) || err.Error() != tt.want {
+				t.Errorf("awaitApproval() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAwaitApprovalURL(t *testing.T) {
+	t.Setenv("GCX_TEST_APPROVAL_TOKEN", "s3cret")
+	data := ApprovalData{Name: "prod", Version: "v1.2.0"}
+
+	serve := func(t *testing.T, statuses ...string) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer s3cret" || r.URL.Path != "/tickets/v1.2.0" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			n := int(calls.Add(1)) - 1
+			if n >= len(statuses) {
+				n = len(statuses) - 1
+			}
+			if statuses[n] == "error" {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status": "` + statuses[n] + `"}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &calls
+	}
+	approval := func(srv *httptest.Server) config.ApprovalConfig {
+		return config.ApprovalConfig{
+			URL:          srv.URL + "/tickets/{{.Version}}",
+			TokenEnv:     "GCX_TEST_APPROVAL_TOKEN",
+			Timeout:      configtypes.Duration(time.Second),
+			PollInterval: configtypes.Duration(10 * time.Millisecond),
+		}
+	}
+
+	t.Run("approved after pending and errors", func(t *testing.T) {
+		srv, calls := serve(t, "pending", "error", "Approved")
+		if err := awaitApproval(context.Background(), "", approval(srv), data); err != nil {
+			t.Fatalf("awaitApproval() error = %v", err)
+		}
+		if got := calls.Load(); got != 3 {
+			t.Errorf("requests = %d, want 3", got)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		srv, _ := serve(t, "pending", "rejected")
+		err := awaitApproval(context.Background(), "", approval(srv), data)
+		if !errors.Is(err, ErrNotApproved) || err.Error() != "deploy prod not approved: rejected" {
+			t.Errorf("awaitApproval() error = %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		srv, _ := serve(t, "pending")
+		cfg := approval(srv)
+		cfg.Timeout = configtypes.Duration(50 * time.Millisecond)
+		err := awaitApproval(context.Background(), "", cfg, data)
+		if !errors.Is(err, ErrNotApproved) || !strings.Contains(err.Error(), "no approval within 50ms") {
+			t.Errorf("awaitApproval() error = %v", err)
+		}
+	})
+}
+
+func TestRunNotApproved(t *testing.T) {
+	deployCfg := config.DeployConfig{
+		Name:     "prod",
+		Provider: "ssh",
+		Server:   "127.0.0.1:1",
+		User:     "deploy",
+		KeyRaw:   "not a key",
+		Commands: []config.Command{{Run: "true"}},
+		Approval: &config.ApprovalConfig{Command: "false"},
+	}
+	cfg := &config.Config{OutDir: t.TempDir(), Deploys: []config.DeployConfig{deployCfg}}
+
+	for _, yes := range []bool{false, true} {
+		err := Run(context.Background(), cfg, "", Options{Yes: yes})
+		if !errors.Is(err, ErrNotApproved) || strings.Contains(err.Error(), "failed") {
+			t.Errorf("Run(yes=%v) error = %v, want not approved", yes, err)
+		}
+	}
+
+	// With allow_override, --yes skips the gate and the deploy itself runs
+	cfg.Deploys[0].Approval.AllowOverride = true
+	err := Run(context.Background(), cfg, "", Options{Yes: true})
+	if err == nil || errors.Is(err, ErrNotApproved) {
+		t.Errorf("Run(yes) with allow_override error = %v, want the deploy's own failure", err)
+	}
+}
@@ -0,0 +1,98 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// CheckResult holds the outcome of a pre-flight connectivity check for one deploy target.
+type CheckResult struct {
+	Name    string
+	Host    string
+	Latency time.Duration
+	Err     error
+}
+
+// Status returns a short human-readable status for the check result.
+func (r CheckResult) Status() string {
+	if r.Err != nil {
+		return "FAILED: " + r.Err.Error()
+	}
+	return "ok"
+}
+
+// Check verifies connectivity and authentication for the selected deploy targets
+// without executing any of the configured commands. All selected targets are checked
+// even when some of them fail; the returned error reports how many failed.
+func Check(ctx context.Context, cfg *config.Config, deployName string, runNoop bool) ([]CheckResult, error) {
+	if len(cfg.Deploys) == 0 {
+		return nil, fmt.Errorf("no deploy configurations found")
+	}
+
+	var deploys []config.DeployConfig
+	for _, deploy := range cfg.Deploys {
+		if deployName == "" || deploy.Name == deployName {
+			deploys = append(deploys, deploy)
+		}
+	}
+	if len(deploys) == 0 {
+		return nil, fmt.Errorf("deploy configuration %q not found", deployName)
+	}
+
+	results := make([]CheckResult, 0, len(deploys))
+	var failed int
+	for _, deployCfg := range deploys {
+		start := time.Now()
+		err := checkDeploy(ctx, deployCfg, runNoop)
+		results = append(results, CheckResult{
+			Name:    deployCfg.Name,
+			Host:    deployCfg.Server,
+			Latency: time.Since(start),
+			Err:     err,
+		})
+		if err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d deploy target(s) failed the check", failed, len(results))
+	}
+	return results, nil
+}
+
+func checkDeploy(ctx context.Context, deployCfg config.DeployConfig, runNoop bool) error {
+	deployer, err := NewDeployer(deployCfg, Release{})
+	if err != nil {
+		return err
+	}
+	return deployer.Check(ctx, runNoop)
+}
+
+// resolveHost makes sure the host part of server can be resolved via DNS.
+func resolveHost(ctx context.Context, server string) error {
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	return nil
+}
+
+// WriteCheckTable prints check results as an aligned host/status/latency table.
+func WriteCheckTable(w io.Writer, results []CheckResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tHOST\tSTATUS\tLATENCY")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Name, r.Host, r.Status(), r.Latency.Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
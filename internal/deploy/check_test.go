@@ -0,0 +1,73 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestCheckSelection(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no deploys", func(t *testing.T) {
+		if _, err := Check(ctx, &config.Config{}, "", false); err == nil {
+			t.Error("expected error for empty deploys")
+		}
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		cfg := &config.Config{Deploys: []config.DeployConfig{{Name: "prod", Provider: "ssh"}}}
+		if _, err := Check(ctx, cfg, "staging", false); err == nil {
+			t.Error("expected error for unknown deploy name")
+		}
+	})
+
+	t.Run("unsupported provider is reported per target", func(t *testing.T) {
+		cfg := &config.Config{Deploys: []config.DeployConfig{
+			{Name: "a", Provider: "ftp", Server: "a.example.com"},
+			{Name: "b", Provider: "ftp", Server: "b.example.com"},
+		}}
+		results, err := Check(ctx, cfg, "", false)
+		if err == nil {
+			t.Fatal("expected aggregated error")
+		}
+		if len(results) != 2 {
+			t.Fatalf("len(results) = %d, want 2", len(results))
+		}
+		for _, r := range results {
+			if r.Err == nil {
+				t.Errorf("result %q: expected error", r.Name)
+			}
+		}
+	})
+}
+
+func TestWriteCheckTable(t *testing.T) {
+	results := []CheckResult{
+		{Name: "prod", Host: "prod.example.com", Latency: 120 * time.Millisecond},
+		{Name: "staging", Host: "staging.example.com", Latency: time.Second, Err: errors.New("connection refused")},
+	}
+
+	var sb strings.Builder
+	if err := WriteCheckTable(&sb, results); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %q", len(lines), sb.String())
+	}
+	if !strings.HasPrefix(lines[0], "NAME") {
+		t.Errorf("missing header: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "ok") || !strings.Contains(lines[1], "120ms") {
+		t.Errorf("unexpected success row: %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "FAILED: connection refused") {
+		t.Errorf("unexpected failure row: %q", lines[2])
+	}
+}
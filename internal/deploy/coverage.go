@@ -0,0 +1,59 @@
+package deploy
+
+import (
+	"log"
+
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/shellutil"
+	"github.com/sxwebdev/gcx/internal/sshutil"
+)
+
+// coverage exports GOCOVERDIR to the run steps of a deploy once it ships a
+// coverage-instrumented artifact, so the binaries the commands start write
+// their coverage data to dir.
+type coverage struct {
+	deploy string
+	// dir is the remote coverdir; empty when the deploy has none
+	dir string
+	// shipped is set once the deploy ships an instrumented artifact
+	shipped bool
+	active  bool
+}
+
+func newCoverage(deploy, dir string) *coverage {
+	return &coverage{deploy: deploy, dir: dir}
+}
+
+// ship notes an artifact shipped by the deploy.
+func (c *coverage) ship(a manifest.Artifact) {
+	if c != nil && a.Instrumented {
+		c.shipped = true
+	}
+}
+
+// activate creates dir and logs how to collect the coverage data, once
+// the deploy has shipped an instrumented artifact.
+func (c *coverage) activate(client sshutil.Client) error {
+	if c == nil || !c.shipped || c.active {
+		return nil
+	}
+	c.active = true
+	if c.dir == "" {
+		log.Printf("Warning: %s deploys a coverage-instrumented build without coverdir; "+
+			"its binaries write no coverage data unless GOCOVERDIR is set where they run", c.deploy)
+		return nil
+	}
+	log.Printf("%s deploys a coverage-instrumented build: commands run with GOCOVERDIR=%s. "+
+		"Set it in the service environment too, e.g. Environment=GOCOVERDIR=%s in a systemd unit, "+
+		"and merge the data with go tool covdata", c.deploy, c.dir, c.dir)
+	_, err := run(client, "mkdir -p "+shellutil.Quote(c.dir))
+	return err
+}
+
+// command returns cmd exporting GOCOVERDIR once coverage is active.
+func (c *coverage) command(cmd string) string {
+	if c == nil || !c.active || c.dir == "" {
+		return cmd
+	}
+	return "export GOCOVERDIR=" + shellutil.Quote(c.dir) + "; " + cmd
+}
@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestRunStepsCoverage(t *testing.T) {
+	// Commands must only see the exported coverdir
+	t.Setenv("GOCOVERDIR", "")
+	outDir := t.TempDir()
+	m := &manifest.Manifest{Version: "v1.2.0"}
+	for _, name := range []string{"app", "app-cover"} {
+		path := filepath.Join(outDir, name)
+		if err := os.WriteFile(path, []byte(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		m.Artifacts = append(m.Artifacts, manifest.Artifact{Name: name, Path: path, Type: manifest.TypeBinary, Instrumented: name == "app-cover"})
+	}
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+	release := Release{Version: "v1.2.0", ArtifactsDir: outDir}
+
+	tests := []struct {
+		artifact string
+		exported bool
+	}{
+		{artifact: "app"},
+		{artifact: "app-cover", exported: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.artifact, func(t *testing.T) {
+			remote := t.TempDir()
+			coverDir := filepath.Join(remote, "coverage")
+			steps := []config.DeployStep{
+				{Upload: &config.UploadStep{Artifact: tt.artifact, Dest: filepath.Join(remote, "bin", "app")}},
+				{Run: "echo \"$GOCOVERDIR\" > " + filepath.Join(remote, "env")},
+			}
+			if err := runSteps(t.Context(), localClient{}, steps, release, stepsOptions{cover: newCoverage("staging", coverDir)}); err != nil {
+				t.Fatal(err)
+			}
+			env, err := os.ReadFile(filepath.Join(remote, "env"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := ""
+			if tt.exported {
+				want = coverDir
+			}
+			if got := strings.TrimSpace(string(env)); got != want {
+				t.Errorf("GOCOVERDIR = %q, want %q", got, want)
+			}
+			if _, err := os.Stat(coverDir); (err == nil) != tt.exported {
+				t.Errorf("coverdir exists = %v, want %v", err == nil, tt.exported)
+			}
+		})
+	}
+}
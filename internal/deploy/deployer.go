@@ -2,32 +2,69 @@ package deploy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"time"
 
 	"github.com/sxwebdev/gcx/internal/config"
 	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/include"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/metrics"
 	"github.com/sxwebdev/gcx/internal/notify"
+	"github.com/sxwebdev/gcx/internal/policy"
 )
 
 // Deployer executes deployment commands.
 type Deployer interface {
 	Name() string
 	Deploy(ctx context.Context) error
+	// Check verifies that the target is reachable and the configured credentials work
+	// without running any deploy commands. When runNoop is set, a no-op command is
+	// executed as well.
+	Check(ctx context.Context, runNoop bool) error
+}
+
+// Release identifies the build output shipped by a deploy.
+type Release struct {
+	Version string
+	Channel string
+	// ArtifactsDir is the rendered out_dir holding artifacts.json.
+	ArtifactsDir string
 }
 
 // NewDeployer creates a Deployer from a DeployConfig.
-func NewDeployer(cfg config.DeployConfig) (Deployer, error) {
+func NewDeployer(cfg config.DeployConfig, release Release) (Deployer, error) {
 	switch cfg.Provider {
 	case "ssh":
-		return NewSSHDeployer(cfg)
+		return NewSSHDeployer(cfg, release)
+	case "releases":
+		return NewReleasesDeployer(cfg, release)
 	default:
 		return nil, fmt.Errorf("unsupported deploy provider: %s", cfg.Provider)
 	}
 }
 
+// Options holds command-line overrides for Run.
+type Options struct {
+	// ForceAll deploys every target, ignoring only_if_changed.
+	ForceAll bool
+	// PolicyOverride is the reason for deploying despite deploy_policy
+	// violations. It is logged and included in alerts.
+	PolicyOverride string
+	// Yes skips the approval gates that set approval.allow_override.
+	Yes bool
+	// Force uploads and downloads every file and runs every command,
+	// ignoring skip_unchanged.
+	Force bool
+}
+
 // Run executes deployments according to the configuration.
-func Run(ctx context.Context, cfg *config.Config, deployName string) error {
+func Run(ctx context.Context, cfg *config.Config, deployName string, opts Options) (err error) {
+	defer func(start time.Time) { metrics.ObserveStage("deploy", start, err) }(time.Now())
+
 	if len(cfg.Deploys) == 0 {
 		return fmt.Errorf("no deploy configurations found")
 	}
@@ -35,46 +72,125 @@ func Run(ctx context.Context, cfg *config.Config, deployName string) error {
 	if deployName != "" {
 		for _, deploy := range cfg.Deploys {
 			if deploy.Name == deployName {
-				return executeDeploy(ctx, deploy)
+				return executeDeploy(ctx, cfg, deploy, opts)
 			}
 		}
 		return fmt.Errorf("deploy configuration %q not found", deployName)
 	}
 
 	for _, deploy := range cfg.Deploys {
-		if err := executeDeploy(ctx, deploy); err != nil {
+		if err := executeDeploy(ctx, cfg, deploy, opts); err != nil {
+			if errors.Is(err, ErrNotApproved) {
+				return err
+			}
 			return fmt.Errorf("deploy %q failed: %w", deploy.Name, err)
 		}
 	}
 	return nil
 }
 
-func executeDeploy(ctx context.Context, deployCfg config.DeployConfig) error {
+func executeDeploy(ctx context.Context, cfg *config.Config, deployCfg config.DeployConfig, opts Options) error {
+	tag := git.GetTag(ctx)
+	rel := cfg.Release(tag)
+	enabled, err := deployCfg.IsEnabled(rel)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		log.Printf("Skipping deploy %s: disabled on channel %s", deployCfg.Name, rel.Channel)
+		return nil
+	}
+
+	if len(deployCfg.OnlyIfChanged) > 0 && !opts.ForceAll {
+		changes, err := git.GetChanges(ctx, deployCfg.TagPrefix)
+		if err != nil {
+			return fmt.Errorf("detect changes: %w", err)
+		}
+		if !changes.Any(deployCfg.OnlyIfChanged) {
+			log.Printf("Skipping deploy %s: no changes matching only_if_changed between %s and %s",
+				deployCfg.Name, changes.From, changes.To)
+			return nil
+		}
+	}
+
+	if err := deployCfg.ResolveIncludes(ctx, filepath.Join(cfg.Dir, include.CacheDir)); err != nil {
+		return fmt.Errorf("deploy %s: %w", deployCfg.Name, err)
+	}
+
+	var override string
+	if err := policy.Check(cfg.DeployPolicy, deployCfg); err != nil {
+		if opts.PolicyOverride == "" {
+			return err
+		}
+		log.Printf("Warning: %v", err)
+		log.Printf("Deploy policy overridden for %s: %s", deployCfg.Name, opts.PolicyOverride)
+		override = opts.PolicyOverride
+	}
+
 	log.Printf("Executing deploy: %s", deployCfg.Name)
+	if opts.Force && deployCfg.SkipUnchanged {
+		log.Printf("--force: copying every file of %s, ignoring skip_unchanged", deployCfg.Name)
+		deployCfg.SkipUnchanged = false
+	}
 
-	version := git.GetTag(ctx)
+	artifactsDir, err := cfg.OutputDir(tag)
+	if err != nil {
+		return err
+	}
 
-	deployer, err := NewDeployer(deployCfg)
+	deployer, err := NewDeployer(deployCfg, Release{Version: rel.Version, Channel: rel.Channel, ArtifactsDir: artifactsDir})
 	if err != nil {
 		return err
 	}
 
+	alerter := notify.NewAlerter(deployCfg.Alerts, filepath.Join(cfg.Dir, notify.StateDir))
 	alertData := notify.AlertData{
-		AppName: deployCfg.Name,
-		Version: version,
+		AppName:        deployCfg.Name,
+		Version:        rel.Version,
+		Channel:        rel.Channel,
+		PolicyOverride: override,
+	}
+
+	if approval := deployCfg.Approval; approval != nil {
+		if opts.Yes && approval.AllowOverride {
+			log.Printf("Warning: approval of %s skipped with --yes", deployCfg.Name)
+			alertData.ApprovalOverride = true
+		} else {
+			if opts.Yes {
+				log.Printf("--yes does not skip the approval of %s: approval.allow_override is not set", deployCfg.Name)
+			}
+			data := ApprovalData{Name: deployCfg.Name, Version: rel.Version, Channel: rel.Channel}
+			if err := awaitApproval(ctx, cfg.Dir, *approval, data); err != nil {
+				if errors.Is(err, ErrNotApproved) {
+					alertData.Status = "Not approved"
+					alertData.Error = err.Error()
+					if err := alerter.Send(alertData); err != nil {
+						log.Printf("Failed to send not approved alert: %v", err)
+					}
+				}
+				return err
+			}
+		}
+	}
+
+	start := time.Now()
+	deployErr := inject.Check("deploy", deployCfg.Name)
+	if deployErr == nil {
+		deployErr = deployer.Deploy(ctx)
 	}
+	metrics.ObserveDeploy(deployCfg.Name, deployCfg.Server, time.Since(start), deployErr)
 
-	if deployErr := deployer.Deploy(ctx); deployErr != nil {
+	if deployErr != nil {
 		alertData.Status = "Failed"
 		alertData.Error = deployErr.Error()
-		if err := notify.Send(deployCfg.Alerts.URLs, alertData); err != nil {
+		if err := alerter.Send(alertData); err != nil {
 			log.Printf("Failed to send failure alert: %v", err)
 		}
 		return deployErr
 	}
 
 	alertData.Status = "Success"
-	if err := notify.Send(deployCfg.Alerts.URLs, alertData); err != nil {
+	if err := alerter.Send(alertData); err != nil {
 		log.Printf("Failed to send success alert: %v", err)
 	}
 
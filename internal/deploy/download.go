@@ -0,0 +1,271 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/shellutil"
+	"github.com/sxwebdev/gcx/internal/sshutil"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// StepData is the template context of download and upload steps.
+type StepData struct {
+	Version  string
+	Channel  string
+	manifest *manifest.Manifest
+	cover    *coverage
+}
+
+// ArtifactName returns the name of the only manifest artifact matching the
+// glob pattern, e.g. {{.ArtifactName "*_linux_amd64.tar.gz"}}.
+func (d StepData) ArtifactName(pattern string) (string, error) {
+	a, err := d.artifact(pattern)
+	return a.Name, err
+}
+
+// ArtifactSha256 returns the SHA-256 recorded in the manifest for the only
+// artifact matching the glob pattern.
+func (d StepData) ArtifactSha256(pattern string) (string, error) {
+	a, err := d.artifact(pattern)
+	if err != nil {
+		return "", err
+	}
+	if a.SHA256 == "" {
+		return "", fmt.Errorf("%s has no sha256 in %s, rebuild with this gcx version", a.Name, manifest.FileName)
+	}
+	return a.SHA256, nil
+}
+
+func (d StepData) artifact(pattern string) (manifest.Artifact, error) {
+	var matches []manifest.Artifact
+	for _, a := range d.manifest.Artifacts {
+		if ok, _ := path.Match(pattern, a.Name); ok {
+			matches = append(matches, a)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return manifest.Artifact{}, fmt.Errorf("no artifact matches %q", pattern)
+	case 1:
+		d.cover.ship(matches[0])
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, a := range matches {
+			names[i] = a.Name
+		}
+		return manifest.Artifact{}, fmt.Errorf("%q matches %d artifacts: %s", pattern, len(matches), strings.Join(names, ", "))
+	}
+}
+
+// DownloadError reports that the target host could not fetch a file.
+type DownloadError struct {
+	URL    string
+	Dest   string
+	Output string
+	Err    error
+}
+
+func (e *DownloadError) Error() string {
+	msg := fmt.Sprintf("download %s to %s failed: %v", e.URL, e.Dest, e.Err)
+	if e.Output != "" {
+		msg += ": " + e.Output
+	}
+	return msg
+}
+
+func (e *DownloadError) Unwrap() error { return e.Err }
+
+// ChecksumError reports a downloaded file whose SHA-256 does not match the
+// manifest. The file is removed from the target host.
+type ChecksumError struct {
+	URL      string
+	Dest     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s downloaded from %s: expected sha256 %s, got %s", e.Dest, e.URL, e.Expected, e.Actual)
+}
+
+// stepsOptions tune how runSteps runs the steps.
+type stepsOptions struct {
+	// cover exports GOCOVERDIR to the commands once an instrumented
+	// artifact ships; it may be nil.
+	cover *coverage
+	// skipUnchanged skips uploads and downloads whose dest already has the
+	// expected SHA-256, and only_on_change commands unless an earlier
+	// upload or download changed a file.
+	skipUnchanged bool
+}
+
+// runSteps runs the deploy steps in order and stops at the first failure.
+// The manifest in artifactsDir is only read when a download or upload step
+// needs it.
+// ctx only stops commands run on a PTY.
+func runSteps(ctx context.Context, client sshutil.Client, steps []config.DeployStep, release Release, opts stepsOptions) error {
+	cover := opts.cover
+	var (
+		data            *StepData
+		copied, changed bool
+	)
+	for i, step := range steps {
+		// --fail-at deploy:cmdN counts steps from 1
+		if err := inject.Check("deploy", fmt.Sprintf("cmd%d", i+1)); err != nil {
+			return fmt.Errorf("steps[%d]: %w", i, err)
+		}
+		if step.Run != "" {
+			if step.OnlyOnChange && opts.skipUnchanged && !changed {
+				log.Printf("Skipping command, nothing changed: %s", step.Run)
+				continue
+			}
+			if err := cover.activate(client); err != nil {
+				return fmt.Errorf("steps[%d]: %w", i, err)
+			}
+			var (
+				out []byte
+				err error
+			)
+			if step.RequestPTY {
+				log.Printf("Executing command on a PTY: %s", step.Run)
+				out, err = client.RunPTY(ctx, cover.command(step.Run))
+			} else {
+				log.Printf("Executing command: %s", step.Run)
+				out, err = client.Run(cover.command(step.Run))
+			}
+			if err != nil {
+				return fmt.Errorf("command %q failed: %w", step.Run, err)
+			}
+			log.Printf("Command output:\n%s", string(out))
+			continue
+		}
+
+		if data == nil {
+			m, err := manifest.Load(filepath.Join(release.ArtifactsDir, manifest.FileName))
+			if err != nil {
+				return fmt.Errorf("steps[%d]: %w", i, err)
+			}
+			data = &StepData{Version: release.Version, Channel: release.Channel, manifest: m, cover: cover}
+		}
+		var (
+			updated bool
+			err     error
+		)
+		if step.Upload != nil {
+			updated, err = upload(client, *step.Upload, *data, opts.skipUnchanged)
+		} else {
+			updated, err = download(client, *step.Download, *data, opts.skipUnchanged)
+		}
+		if err != nil {
+			return fmt.Errorf("steps[%d]: %w", i, err)
+		}
+		copied = true
+		changed = changed || updated
+	}
+	if opts.skipUnchanged && copied && !changed {
+		log.Printf("Already up to date: every uploaded and downloaded file matches its sha256")
+	}
+	return nil
+}
+
+// remoteSHA256 returns the SHA-256 of the file at p on the target host, or
+// "" when there is no such file.
+func remoteSHA256(client sshutil.Client, p string) (string, error) {
+	q := shellutil.Quote(p)
+	out, err := client.Run("if [ -f " + q + " ]; then sha256sum " + q + " 2>/dev/null || shasum -a 256 " + q + "; fi")
+	if err != nil {
+		return "", fmt.Errorf("compute checksum of %s: %w", p, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// upToDate reports whether dest on the target host has the SHA-256 want.
+func upToDate(client sshutil.Client, dest, want string) (bool, error) {
+	got, err := remoteSHA256(client, dest)
+	if err != nil {
+		return false, err
+	}
+	if got == want {
+		log.Printf("%s is already up to date (sha256 %s)", dest, want)
+		return true, nil
+	}
+	return false, nil
+}
+
+// download makes the target host fetch the file with curl or wget into a
+// temporary file next to dest, which replaces dest once its SHA-256 matches.
+// With skipUnchanged, a dest that already matches is kept. It reports
+// whether dest was replaced.
+func download(client sshutil.Client, step config.DownloadStep, data StepData, skipUnchanged bool) (bool, error) {
+	url, err := tmpl.Process("url_template", step.URLTemplate, data)
+	if err != nil {
+		return false, err
+	}
+	dest, err := tmpl.Process("dest", step.Dest, data)
+	if err != nil {
+		return false, err
+	}
+	want, err := tmpl.Process("sha256", step.SHA256, data)
+	if err != nil {
+		return false, err
+	}
+	want = strings.ToLower(strings.TrimSpace(want))
+	if !sha256Regex.MatchString(want) {
+		return false, fmt.Errorf("sha256 rendered to %q, want 64 hex digits", want)
+	}
+	if skipUnchanged {
+		if ok, err := upToDate(client, dest, want); ok || err != nil {
+			return false, err
+		}
+	}
+
+	partial := dest + ".part"
+	log.Printf("Downloading %s to %s on the target", url, dest)
+	if out, err := client.Run(fetchCommand(url, partial)); err != nil {
+		_, _ = client.Run("rm -f " + shellutil.Quote(partial))
+		return false, &DownloadError{URL: url, Dest: dest, Output: strings.TrimSpace(string(out)), Err: err}
+	}
+
+	got, err := remoteSHA256(client, partial)
+	if err != nil {
+		return false, err
+	}
+	if got == "" {
+		return false, fmt.Errorf("compute checksum of %s: empty output", partial)
+	}
+	if got != want {
+		_, _ = client.Run("rm -f " + shellutil.Quote(partial))
+		return false, &ChecksumError{URL: url, Dest: dest, Expected: want, Actual: got}
+	}
+
+	if _, err := run(client, "mv -f "+shellutil.Quote(partial)+" "+shellutil.Quote(dest)); err != nil {
+		return false, err
+	}
+	log.Printf("Verified %s (sha256 %s)", dest, want)
+	return true, nil
+}
+
+// fetchCommand downloads url to dest with curl, or wget when curl is missing.
+func fetchCommand(url, dest string) string {
+	u, d := shellutil.Quote(url), shellutil.Quote(dest)
+	return fmt.Sprintf("mkdir -p %s && if command -v curl >/dev/null 2>&1; then curl -fsSL --retry 3 -o %s %s; "+
+		"elif command -v wget >/dev/null 2>&1; then wget -q -O %s %s; "+
+		"else echo 'neither curl nor wget is installed' >&2; exit 127; fi",
+		shellutil.Quote(path.Dir(dest)), d, u, d, u)
+}
@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestRunStepsDownload(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+
+	outDir := t.TempDir()
+	content := []byte("archive v1.2.0")
+	archive := filepath.Join(outDir, "app_v1.2.0_linux_amd64.tar.gz")
+	if err := os.WriteFile(archive, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	m := &manifest.Manifest{Version: "v1.2.0", Artifacts: []manifest.Artifact{
+		{Name: "app_v1.2.0_linux_amd64.tar.gz", Path: archive, SHA256: hex.EncodeToString(sum[:])},
+		{Name: "app_v1.2.0_darwin_arm64.tar.gz", Path: "unused"},
+	}}
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+	release := Release{Version: "v1.2.0", ArtifactsDir: outDir}
+
+	remote := t.TempDir()
+	step := func(url, sha string) config.DeployStep {
+		return config.DeployStep{Download: &config.DownloadStep{
+			URLTemplate: url,
+			Dest:        filepath.Join(remote, "app", "{{.Version}}.tar.gz"),
+			SHA256:      sha,
+		}}
+	}
+	url := "file://" + outDir + `/{{.ArtifactName "*_linux_amd64.tar.gz"}}`
+	dest := filepath.Join(remote, "app", "v1.2.0.tar.gz")
+
+	t.Run("verified", func(t *testing.T) {
+		steps := []config.DeployStep{
+			step(url, `{{.ArtifactSha256 "*_linux_amd64.tar.gz"}}`),
+			{Run: "test -f " + dest},
+		}
+		if err := runSteps(t.Context(), localClient{}, steps, release, stepsOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(dest)
+		if err != nil || string(got) != string(content) {
+			t.Fatalf("dest = %q, %v", got, err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		steps := []config.DeployStep{
+			step(url, strings.Repeat("0", 64)),
+			{Run: "echo must not run && false"},
+		}
+		err := runSteps(t.Context(), localClient{}, steps, release, stepsOptions{})
+		var sumErr *ChecksumError
+		if !errors.As(err, &sumErr) {
+			t.Fatalf("error = %v, want a ChecksumError", err)
+		}
+		if sumErr.Actual != hex.EncodeToString(sum[:]) {
+			t.Errorf("Actual = %s", sumErr.Actual)
+		}
+		if _, err := os.Stat(dest + ".part"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("partial download was kept: %v", err)
+		}
+	})
+
+	t.Run("download error", func(t *testing.T) {
+		steps := []config.DeployStep{step("file://"+outDir+"/missing.tar.gz", `{{.ArtifactSha256 "*_linux_amd64.tar.gz"}}`)}
+		err := runSteps(t.Context(), localClient{}, steps, release, stepsOptions{})
+		var dlErr *DownloadError
+		if !errors.As(err, &dlErr) {
+			t.Fatalf("error = %v, want a DownloadError", err)
+		}
+		var sumErr *ChecksumError
+		if errors.As(err, &sumErr) {
+			t.Error("download error reported as checksum mismatch")
+		}
+	})
+
+	t.Run("ambiguous pattern", func(t *testing.T) {
+		steps := []config.DeployStep{step(url, `{{.ArtifactSha256 "app_*"}}`)}
+		if err := runSteps(t.Context(), localClient{}, steps, release, stepsOptions{}); err == nil || !strings.Contains(err.Error(), "matches 2 artifacts") {
+			t.Fatalf("error = %v, want an ambiguous match", err)
+		}
+	})
+}
+
+// ptyClient records which commands ran on a PTY.
+type ptyClient struct {
+	localClient
+	pty []string
+}
+
+func (c *ptyClient) RunPTY(_ context.Context, cmd string) ([]byte, error) {
+	c.pty = append(c.pty, cmd)
+	return c.Run(cmd)
+}
+
+func TestRunStepsPTY(t *testing.T) {
+	client := &ptyClient{}
+	steps := []config.DeployStep{
+		{Run: "true"},
+		{Run: "echo tty", RequestPTY: true},
+	}
+	if err := runSteps(t.Context(), client, steps, Release{}, stepsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.pty) != 1 || client.pty[0] != "echo tty" {
+		t.Errorf("commands on a PTY = %v, want only the request_pty step", client.pty)
+	}
+}
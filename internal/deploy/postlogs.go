@@ -0,0 +1,130 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/sshutil"
+)
+
+// defaultPostLogsDuration bounds a post_logs tail without duration.
+const defaultPostLogsDuration = time.Minute
+
+var (
+	errStopPattern  = errors.New("stop_pattern matched")
+	errErrorPattern = errors.New("error_pattern matched")
+)
+
+// tailLogs streams the post_logs command into the log after a deploy. A
+// line matching error_pattern fails the deploy. The tail ends without an
+// error after the duration, on a stop_pattern line, when the command exits
+// (a failing command is only logged) and when ctx is done, so Ctrl-C
+// stops tailing but keeps the deploy successful.
+func tailLogs(ctx context.Context, client sshutil.Client, cfg *config.PostLogsConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	w := &lineMatcher{}
+	var err error
+	if w.stop, err = compilePattern(cfg.StopPattern); err != nil {
+		return fmt.Errorf("post_logs.stop_pattern: %w", err)
+	}
+	if w.fail, err = compilePattern(cfg.ErrorPattern); err != nil {
+		return fmt.Errorf("post_logs.error_pattern: %w", err)
+	}
+	duration := cfg.Duration.Std()
+	if duration == 0 {
+		duration = defaultPostLogsDuration
+	}
+
+	tailCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	tailCtx, cancelTimeout := context.WithTimeout(tailCtx, duration)
+	defer cancelTimeout()
+	w.cancel = cancel
+
+	log.Printf("Tailing %q for up to %s", cfg.Command, duration)
+	streamErr := client.Stream(tailCtx, cfg.Command, w)
+	w.flush()
+
+	cause := context.Cause(tailCtx)
+	switch {
+	case errors.Is(cause, errErrorPattern):
+		return fmt.Errorf("post_logs: line matches error_pattern: %s", w.matched)
+	case ctx.Err() != nil:
+		log.Printf("Log tail interrupted; the deploy itself succeeded")
+	case errors.Is(cause, errStopPattern):
+		log.Printf("Log tail ended: %s", w.matched)
+	case errors.Is(cause, context.DeadlineExceeded):
+		log.Printf("Log tail ended after %s without errors", duration)
+	case streamErr != nil:
+		log.Printf("Warning: post_logs command %q failed: %v", cfg.Command, streamErr)
+	}
+	return nil
+}
+
+// compilePattern compiles a regular expression; an empty pattern matches
+// nothing.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// lineMatcher logs the streamed output line by line and cancels the tail
+// on the first line matching fail or stop.
+type lineMatcher struct {
+	stop, fail *regexp.Regexp
+	cancel     context.CancelCauseFunc
+
+	mu      sync.Mutex
+	buf     []byte
+	matched string
+}
+
+func (m *lineMatcher) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buf = append(m.buf, p...)
+	for {
+		i := bytes.IndexByte(m.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		m.line(string(bytes.TrimSuffix(m.buf[:i], []byte("\r"))))
+		m.buf = m.buf[i+1:]
+	}
+}
+
+// flush handles an unterminated last line.
+func (m *lineMatcher) flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.buf) > 0 {
+		m.line(string(m.buf))
+		m.buf = nil
+	}
+}
+
+func (m *lineMatcher) line(line string) {
+	if m.matched != "" {
+		return
+	}
+	log.Printf("post_logs: %s", line)
+	switch {
+	case m.fail != nil && m.fail.MatchString(line):
+		m.matched = line
+		m.cancel(errErrorPattern)
+	case m.stop != nil && m.stop.MatchString(line):
+		m.matched = line
+		m.cancel(errStopPattern)
+	}
+}
@@ -0,0 +1,68 @@
+package deploy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/configtypes"
+)
+
+func TestTailLogs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.PostLogsConfig
+		wantErr string
+	}{
+		{
+			name:    "error pattern",
+			cfg:     config.PostLogsConfig{Command: "echo ok; echo 'level=fatal msg=boom'; sleep 10", ErrorPattern: "level=fatal", StopPattern: "never"},
+			wantErr: "level=fatal msg=boom",
+		},
+		{
+			name: "stop pattern before an error",
+			cfg:  config.PostLogsConfig{Command: "echo listening; echo 'panic: x'; sleep 10", StopPattern: "listening", ErrorPattern: "panic"},
+		},
+		{
+			name: "duration",
+			cfg:  config.PostLogsConfig{Command: "echo ok; sleep 10", Duration: configtypes.Duration(200 * time.Millisecond), ErrorPattern: "panic"},
+		},
+		{
+			name:    "unterminated last line",
+			cfg:     config.PostLogsConfig{Command: "printf 'panic: x'", ErrorPattern: "panic"},
+			wantErr: "panic: x",
+		},
+		{
+			name: "failing command",
+			cfg:  config.PostLogsConfig{Command: "exit 1", ErrorPattern: "panic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			err := tailLogs(t.Context(), localClient{}, &tt.cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("tailLogs() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("tailLogs() error = %v, want %q", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("tailLogs() took %s, want it to stop the command", elapsed)
+			}
+		})
+	}
+}
+
+// TestTailLogsInterrupted checks that Ctrl-C stops the tail without
+// failing the deploy.
+func TestTailLogsInterrupted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+	cfg := &config.PostLogsConfig{Command: "sleep 1; echo 'panic: late'; sleep 10", ErrorPattern: "panic"}
+	if err := tailLogs(ctx, localClient{}, cfg); err != nil {
+		t.Errorf("tailLogs() error = %v, want nil after an interrupt", err)
+	}
+}
@@ -0,0 +1,264 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/shellutil"
+	"github.com/sxwebdev/gcx/internal/sshutil"
+)
+
+// defaultKeepReleases is the number of releases kept when keep_releases is unset.
+const defaultKeepReleases = 5
+
+// ReleasesDeployer ships artifacts into base_path/releases/<version> on a
+// remote server and switches the base_path/current symlink to it.
+type ReleasesDeployer struct {
+	name      string
+	sshCfg    sshutil.ClientConfig
+	basePath  string
+	artifacts []string
+	extract   bool
+	shared    []string
+	keep      int
+	steps     []config.DeployStep
+	postLogs  *config.PostLogsConfig
+	release   Release
+	coverDir  string
+
+	newClient func(context.Context, sshutil.ClientConfig) (sshutil.Client, error)
+}
+
+// NewReleasesDeployer creates a ReleasesDeployer from config.
+func NewReleasesDeployer(cfg config.DeployConfig, release Release) (*ReleasesDeployer, error) {
+	keep := cfg.KeepReleases
+	if keep == 0 {
+		keep = defaultKeepReleases
+	}
+	return &ReleasesDeployer{
+		name: cfg.Name,
+		sshCfg: sshutil.ClientConfig{
+			Server:                cfg.Server,
+			User:                  cfg.User,
+			KeyPath:               cfg.KeyPath,
+			KeyRaw:                cfg.KeyRaw,
+			InsecureIgnoreHostKey: cfg.InsecureIgnoreHostKey,
+			Backend:               cfg.SSHBackend,
+		},
+		basePath:  path.Clean(cfg.BasePath),
+		artifacts: cfg.Artifacts,
+		extract:   cfg.Extract,
+		shared:    cfg.Shared,
+		keep:      keep,
+		steps:     cfg.DeploySteps(),
+		postLogs:  cfg.PostLogs,
+		release:   release,
+		coverDir:  cfg.CoverageDir(),
+		newClient: sshutil.NewClient,
+	}, nil
+}
+
+func (d *ReleasesDeployer) Name() string { return d.name }
+
+func (d *ReleasesDeployer) Check(ctx context.Context, runNoop bool) error {
+	return checkSSH(ctx, d.sshCfg, runNoop)
+}
+
+func (d *ReleasesDeployer) Deploy(ctx context.Context) error {
+	if d.release.Version == "" {
+		return fmt.Errorf("release version is empty")
+	}
+	files, err := d.selectArtifacts()
+	if err != nil {
+		return err
+	}
+
+	client, err := d.newClient(ctx, d.sshCfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	defer sshutil.CloseOnDone(ctx, client)()
+
+	releaseDir := path.Join(d.basePath, "releases", d.release.Version)
+	current := path.Join(d.basePath, "current")
+
+	if _, err := run(client, "mkdir -p "+shellutil.Quote(releaseDir)); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := d.ship(client, file, releaseDir); err != nil {
+			return err
+		}
+	}
+	if err := d.linkShared(client, releaseDir); err != nil {
+		return err
+	}
+
+	out, err := run(client, "readlink "+shellutil.Quote(current)+" || true")
+	if err != nil {
+		return err
+	}
+	previous := strings.TrimSpace(string(out))
+
+	log.Printf("Switching %s to %s", current, releaseDir)
+	if err := switchCurrent(client, current, releaseDir); err != nil {
+		return err
+	}
+
+	cover := newCoverage(d.name, d.coverDir)
+	for _, file := range files {
+		cover.ship(file)
+	}
+	err = runSteps(ctx, client, d.steps, d.release, stepsOptions{cover: cover})
+	if err == nil {
+		err = tailLogs(ctx, client, d.postLogs)
+	}
+	if err != nil {
+		if previous == "" {
+			return err
+		}
+		log.Printf("Rolling %s back to %s", current, previous)
+		if rbErr := switchCurrent(client, current, previous); rbErr != nil {
+			return fmt.Errorf("%w; rollback failed: %v", err, rbErr)
+		}
+		return fmt.Errorf("%w; rolled back to %s", err, previous)
+	}
+
+	return d.prune(client)
+}
+
+// selectArtifacts returns the manifest artifacts matching the configured
+// patterns. Patterns match the slash path relative to the artifacts dir.
+func (d *ReleasesDeployer) selectArtifacts() ([]manifest.Artifact, error) {
+	m, err := manifest.Load(filepath.Join(d.release.ArtifactsDir, manifest.FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []manifest.Artifact
+	names := make(map[string]string)
+	for _, a := range m.Artifacts {
+		rel, err := filepath.Rel(d.release.ArtifactsDir, a.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = a.Name
+		}
+		rel = filepath.ToSlash(rel)
+		if !helpers.MatchAnyGlob(d.artifacts, rel) {
+			continue
+		}
+		if other, ok := names[a.Name]; ok {
+			return nil, fmt.Errorf("artifacts %s and %s both ship as %s", other, rel, a.Name)
+		}
+		names[a.Name] = rel
+		selected = append(selected, a)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no artifacts in %s match %s", d.release.ArtifactsDir, strings.Join(d.artifacts, ", "))
+	}
+	return selected, nil
+}
+
+// ship uploads an artifact into releaseDir, unpacking archives when extract is set.
+func (d *ReleasesDeployer) ship(client sshutil.Client, a manifest.Artifact, releaseDir string) error {
+	remote := path.Join(releaseDir, a.Name)
+	log.Printf("Uploading %s to %s", a.Path, remote)
+	if err := client.Upload(a.Path, remote); err != nil {
+		return fmt.Errorf("upload %s: %w", a.Name, err)
+	}
+	if !d.extract {
+		return nil
+	}
+
+	var unpack string
+	switch {
+	case strings.HasSuffix(a.Name, ".tar"):
+		unpack = fmt.Sprintf("tar -xf %s -C %s", shellutil.Quote(remote), shellutil.Quote(releaseDir))
+	case strings.HasSuffix(a.Name, ".tar.gz"):
+		unpack = fmt.Sprintf("tar -xzf %s -C %s", shellutil.Quote(remote), shellutil.Quote(releaseDir))
+	case strings.HasSuffix(a.Name, ".tar.xz"):
+		unpack = fmt.Sprintf("tar -xJf %s -C %s", shellutil.Quote(remote), shellutil.Quote(releaseDir))
+	case strings.HasSuffix(a.Name, ".tar.zst"):
+		unpack = fmt.Sprintf("tar --zstd -xf %s -C %s", shellutil.Quote(remote), shellutil.Quote(releaseDir))
+	case strings.HasSuffix(a.Name, ".zip"):
+		unpack = fmt.Sprintf("unzip -o -q %s -d %s", shellutil.Quote(remote), shellutil.Quote(releaseDir))
+	default:
+		return nil
+	}
+	_, err := run(client, unpack+" && rm -f "+shellutil.Quote(remote))
+	return err
+}
+
+// linkShared replaces each shared path in the release with a symlink into
+// base_path/shared.
+func (d *ReleasesDeployer) linkShared(client sshutil.Client, releaseDir string) error {
+	for _, p := range d.shared {
+		target := path.Join(d.basePath, "shared", p)
+		link := path.Join(releaseDir, p)
+		cmd := fmt.Sprintf("mkdir -p %s && rm -rf %s && ln -sfn %s %s",
+			shellutil.Quote(path.Dir(link)), shellutil.Quote(link),
+			shellutil.Quote(target), shellutil.Quote(link))
+		if _, err := run(client, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune removes the oldest releases beyond keep_releases, never touching the
+// release current points at.
+func (d *ReleasesDeployer) prune(client sshutil.Client) error {
+	releasesDir := path.Join(d.basePath, "releases")
+	out, err := run(client, "ls -1t "+shellutil.Quote(releasesDir))
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	kept := 0
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name == "" || name == d.release.Version {
+			continue
+		}
+		// The new release always counts towards the limit
+		if kept < d.keep-1 {
+			kept++
+			continue
+		}
+		stale = append(stale, shellutil.Quote(path.Join(releasesDir, name)))
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(stale)
+	log.Printf("Removing %d old release(s)", len(stale))
+	_, err = run(client, "rm -rf "+strings.Join(stale, " "))
+	return err
+}
+
+// switchCurrent atomically points the current symlink at target by renaming
+// a temporary link over it.
+func switchCurrent(client sshutil.Client, current, target string) error {
+	tmp := current + ".gcx-tmp"
+	cmd := fmt.Sprintf("ln -sfn %s %s && mv -Tf %s %s",
+		shellutil.Quote(target), shellutil.Quote(tmp),
+		shellutil.Quote(tmp), shellutil.Quote(current))
+	_, err := run(client, cmd)
+	return err
+}
+
+func run(client sshutil.Client, cmd string) ([]byte, error) {
+	out, err := client.Run(cmd)
+	if err != nil {
+		return out, fmt.Errorf("command %q failed: %w: %s", cmd, err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
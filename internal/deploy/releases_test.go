@@ -0,0 +1,225 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/sshutil"
+)
+
+// localClient runs commands and uploads on the local machine.
+type localClient struct {
+	sshutil.Client
+}
+
+func (localClient) Run(cmd string) ([]byte, error) {
+	return exec.Command("sh", "-c", cmd).CombinedOutput()
+}
+
+func (localClient) Stream(ctx context.Context, cmd string, w io.Writer) error {
+	c := exec.CommandContext(ctx, "sh", "-c", cmd)
+	c.Stdout, c.Stderr = w, w
+	// sleep outlives the killed shell and would hold the pipes open
+	c.WaitDelay = 100 * time.Millisecond
+	err := c.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (localClient) Upload(localPath, remotePath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := os.Create(remotePath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (localClient) Close() error { return nil }
+
+func newTestReleases(t *testing.T, cfg config.DeployConfig, version string) (*ReleasesDeployer, string) {
+	t.Helper()
+	outDir := t.TempDir()
+	binDir := filepath.Join(outDir, "app_linux_amd64")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	binPath := filepath.Join(binDir, "app")
+	if err := os.WriteFile(binPath, []byte(version), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m := &manifest.Manifest{Version: version, Artifacts: []manifest.Artifact{
+		{Name: "app", Path: binPath, Type: manifest.TypeBinary},
+	}}
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.BasePath == "" {
+		cfg.BasePath = t.TempDir()
+	}
+	if cfg.Artifacts == nil {
+		cfg.Artifacts = []string{"*_linux_amd64/app"}
+	}
+	d, err := NewReleasesDeployer(cfg, Release{Version: version, ArtifactsDir: outDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.newClient = func(context.Context, sshutil.ClientConfig) (sshutil.Client, error) { return localClient{}, nil }
+	return d, cfg.BasePath
+}
+
+func currentTarget(t *testing.T, base string) string {
+	t.Helper()
+	target, err := os.Readlink(filepath.Join(base, "current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Base(target)
+}
+
+func TestReleasesDeploy(t *testing.T) {
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "shared", "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Older releases with distinct modification times
+	for i, v := range []string{"v0.1.0", "v0.2.0", "v0.3.0"} {
+		dir := filepath.Join(base, "releases", v)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(time.Duration(i-10) * time.Hour)
+		if err := os.Chtimes(dir, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.DeployConfig{
+		Name:         "prod",
+		BasePath:     base,
+		Shared:       []string{"config"},
+		KeepReleases: 2,
+		Commands:     []config.Command{{Run: "test -f " + filepath.Join(base, "current", "app")}},
+	}
+	d, _ := newTestReleases(t, cfg, "v1.0.0")
+	if err := d.Deploy(context.Background()); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	if got := currentTarget(t, base); got != "v1.0.0" {
+		t.Errorf("current -> %s, want v1.0.0", got)
+	}
+	data, err := os.ReadFile(filepath.Join(base, "current", "app"))
+	if err != nil || string(data) != "v1.0.0" {
+		t.Errorf("current/app = %q, %v", data, err)
+	}
+	if target, err := os.Readlink(filepath.Join(base, "releases", "v1.0.0", "config")); err != nil || target != filepath.Join(base, "shared", "config") {
+		t.Errorf("shared link = %q, %v", target, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(base, "releases"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "v0.3.0,v1.0.0" {
+		t.Errorf("releases after prune = %s, want v0.3.0,v1.0.0", got)
+	}
+}
+
+func TestReleasesDeployRollsBack(t *testing.T) {
+	base := t.TempDir()
+	d, _ := newTestReleases(t, config.DeployConfig{BasePath: base}, "v1.0.0")
+	if err := d.Deploy(context.Background()); err != nil {
+		t.Fatalf("first Deploy() error = %v", err)
+	}
+
+	d, _ = newTestReleases(t, config.DeployConfig{BasePath: base, Commands: []config.Command{{Run: "exit 3"}}}, "v1.1.0")
+	err := d.Deploy(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Deploy() error = %v, want rollback", err)
+	}
+	if got := currentTarget(t, base); got != "v1.0.0" {
+		t.Errorf("current -> %s after rollback, want v1.0.0", got)
+	}
+}
+
+// TestReleasesDeployPostLogs checks that an error_pattern line fails a
+// deploy whose commands succeeded and rolls it back.
+func TestReleasesDeployPostLogs(t *testing.T) {
+	base := t.TempDir()
+	d, _ := newTestReleases(t, config.DeployConfig{BasePath: base}, "v1.0.0")
+	if err := d.Deploy(context.Background()); err != nil {
+		t.Fatalf("first Deploy() error = %v", err)
+	}
+
+	d, _ = newTestReleases(t, config.DeployConfig{
+		BasePath: base,
+		Commands: []config.Command{{Run: "true"}},
+		PostLogs: &config.PostLogsConfig{Command: "echo started; echo 'panic: nil map'; sleep 10", ErrorPattern: "^panic:"},
+	}, "v1.1.0")
+	err := d.Deploy(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "panic: nil map") || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Deploy() error = %v, want an error_pattern match and rollback", err)
+	}
+	if got := currentTarget(t, base); got != "v1.0.0" {
+		t.Errorf("current -> %s after rollback, want v1.0.0", got)
+	}
+}
+
+func TestReleasesDeployInjectedFailure(t *testing.T) {
+	base := t.TempDir()
+	d, _ := newTestReleases(t, config.DeployConfig{BasePath: base}, "v1.0.0")
+	if err := d.Deploy(context.Background()); err != nil {
+		t.Fatalf("first Deploy() error = %v", err)
+	}
+
+	marker := filepath.Join(t.TempDir(), "cmd1")
+	cmds := []config.Command{{Run: "touch " + marker}, {Run: "true"}}
+	d, _ = newTestReleases(t, config.DeployConfig{BasePath: base, Commands: cmds}, "v1.1.0")
+	inject.Set(&inject.Point{Stage: "deploy", Target: "cmd2"})
+	defer inject.Set(nil)
+
+	// Rolls back like the failing command in TestReleasesDeployRollsBack
+	err := d.Deploy(context.Background())
+	if !errors.Is(err, inject.ErrInjected) || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Deploy() error = %v, want an injected failure and rollback", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("cmd1 did not run before the injected failure: %v", err)
+	}
+	if got := currentTarget(t, base); got != "v1.0.0" {
+		t.Errorf("current -> %s after rollback, want v1.0.0", got)
+	}
+}
+
+func TestReleasesSelectArtifacts(t *testing.T) {
+	d, _ := newTestReleases(t, config.DeployConfig{Artifacts: []string{"*_darwin_*/app"}}, "v1.0.0")
+	if err := d.Deploy(context.Background()); err == nil || !strings.Contains(err.Error(), "no artifacts") {
+		t.Errorf("Deploy() error = %v, want no matching artifacts", err)
+	}
+}
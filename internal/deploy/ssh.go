@@ -3,7 +3,6 @@ package deploy
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"github.com/sxwebdev/gcx/internal/config"
 	"github.com/sxwebdev/gcx/internal/sshutil"
@@ -13,11 +12,17 @@ import (
 type SSHDeployer struct {
 	name     string
 	sshCfg   sshutil.ClientConfig
-	commands []string
+	steps    []config.DeployStep
+	postLogs *config.PostLogsConfig
+	release  Release
+	coverDir string
+	// skipUnchanged keeps files that are already up to date
+	skipUnchanged bool
 }
 
-// NewSSHDeployer creates an SSHDeployer from config.
-func NewSSHDeployer(cfg config.DeployConfig) (*SSHDeployer, error) {
+// NewSSHDeployer creates an SSHDeployer from config. release is only used
+// by download steps.
+func NewSSHDeployer(cfg config.DeployConfig, release Release) (*SSHDeployer, error) {
 	return &SSHDeployer{
 		name: cfg.Name,
 		sshCfg: sshutil.ClientConfig{
@@ -26,27 +31,55 @@ func NewSSHDeployer(cfg config.DeployConfig) (*SSHDeployer, error) {
 			KeyPath:               cfg.KeyPath,
 			KeyRaw:                cfg.KeyRaw,
 			InsecureIgnoreHostKey: cfg.InsecureIgnoreHostKey,
+			Backend:               cfg.SSHBackend,
 		},
-		commands: cfg.Commands,
+		steps:    cfg.DeploySteps(),
+		postLogs: cfg.PostLogs,
+		release:  release,
+		coverDir: cfg.CoverageDir(),
+		// Cleared by gcx deploy --force
+		skipUnchanged: cfg.SkipUnchanged,
 	}, nil
 }
 
 func (d *SSHDeployer) Name() string { return d.name }
 
-func (d *SSHDeployer) Deploy(_ context.Context) error {
-	client, err := sshutil.NewClient(d.sshCfg)
+func (d *SSHDeployer) Deploy(ctx context.Context) error {
+	client, err := sshutil.NewClient(ctx, d.sshCfg)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = client.Close() }()
+	defer sshutil.CloseOnDone(ctx, client)()
 
-	for _, cmd := range d.commands {
-		log.Printf("Executing command: %s", cmd)
-		out, err := client.Run(cmd)
-		if err != nil {
-			return fmt.Errorf("command %q failed: %w", cmd, err)
+	opts := stepsOptions{cover: newCoverage(d.name, d.coverDir), skipUnchanged: d.skipUnchanged}
+	if err := runSteps(ctx, client, d.steps, d.release, opts); err != nil {
+		return err
+	}
+	return tailLogs(ctx, client, d.postLogs)
+}
+
+func (d *SSHDeployer) Check(ctx context.Context, runNoop bool) error {
+	return checkSSH(ctx, d.sshCfg, runNoop)
+}
+
+// checkSSH resolves the server, connects and optionally runs a no-op command.
+func checkSSH(ctx context.Context, sshCfg sshutil.ClientConfig, runNoop bool) error {
+	if err := resolveHost(ctx, sshCfg.Server); err != nil {
+		return err
+	}
+
+	client, err := sshutil.NewClient(ctx, sshCfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	defer sshutil.CloseOnDone(ctx, client)()
+
+	if runNoop {
+		if _, err := client.Run("true"); err != nil {
+			return fmt.Errorf("no-op command failed: %w", err)
 		}
-		log.Printf("Command output:\n%s", string(out))
 	}
 
 	return nil
@@ -0,0 +1,177 @@
+package deploy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/shellutil"
+	"github.com/sxwebdev/gcx/internal/sshutil"
+	"github.com/sxwebdev/gcx/internal/tmpl"
+)
+
+// maxListedEntries bounds the archive entries named in selection errors.
+const maxListedEntries = 10
+
+// upload copies the artifact matching step.Artifact to a temporary file
+// next to dest on the target host, which then replaces dest. With extract,
+// the selected entry of the archive is extracted to a local temporary file
+// and uploaded with its mode instead, so the host needs no tar or unzip.
+// With skipUnchanged, a dest that already has the SHA-256 of the artifact
+// or entry is kept. It reports whether dest was replaced.
+func upload(client sshutil.Client, step config.UploadStep, data StepData, skipUnchanged bool) (bool, error) {
+	a, err := data.artifact(step.Artifact)
+	if err != nil {
+		return false, err
+	}
+	dest, err := tmpl.Process("dest", step.Dest, data)
+	if err != nil {
+		return false, err
+	}
+
+	local, mode := a.Path, ""
+	var entry archive.Entry
+	if step.Extract {
+		if entry, err = selectEntry(a.Path, step.Entry); err != nil {
+			return false, err
+		}
+	}
+	if skipUnchanged {
+		want, err := uploadSHA256(a, step.Extract, entry)
+		if err != nil {
+			return false, err
+		}
+		if ok, err := upToDate(client, dest, want); ok || err != nil {
+			return false, err
+		}
+	}
+	if step.Extract {
+		tmp, err := os.CreateTemp("", "gcx-upload-*")
+		if err != nil {
+			return false, err
+		}
+		defer func() { _ = os.Remove(tmp.Name()) }()
+		err = archive.ExtractFile(a.Path, entry.Path, tmp)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return false, err
+		}
+		local, mode = tmp.Name(), entry.Mode
+		log.Printf("Uploading %s from %s to %s", entry.Path, a.Name, dest)
+	} else {
+		log.Printf("Uploading %s to %s", a.Name, dest)
+	}
+
+	partial := dest + ".part"
+	if _, err := run(client, "mkdir -p "+shellutil.Quote(path.Dir(dest))); err != nil {
+		return false, err
+	}
+	if err := client.Upload(local, partial); err != nil {
+		_, _ = client.Run("rm -f " + shellutil.Quote(partial))
+		return false, fmt.Errorf("upload %s to %s: %w", a.Name, dest, err)
+	}
+	install := "mv -f " + shellutil.Quote(partial) + " " + shellutil.Quote(dest)
+	if mode != "" {
+		install = "chmod " + mode + " " + shellutil.Quote(partial) + " && " + install
+	}
+	if _, err := run(client, install); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// uploadSHA256 returns the SHA-256 dest has once a is uploaded: that of
+// the extracted entry, or of the artifact as recorded in the manifest.
+func uploadSHA256(a manifest.Artifact, extract bool, entry archive.Entry) (string, error) {
+	if extract {
+		if entry.SHA256 == "" {
+			return "", fmt.Errorf("%s has no sha256 for %s", a.Name, entry.Path)
+		}
+		return entry.SHA256, nil
+	}
+	if a.SHA256 != "" {
+		return a.SHA256, nil
+	}
+	digest, err := checksum.File(a.Path)
+	if err != nil {
+		return "", err
+	}
+	return digest.SHA256Hex(), nil
+}
+
+// selectEntry returns the file of the archive at archivePath to extract:
+// the one whose path or base name is name, or without a name the only
+// executable file, or the only file.
+func selectEntry(archivePath, name string) (archive.Entry, error) {
+	entries, err := archive.Contents(archivePath)
+	if err != nil {
+		return archive.Entry{}, err
+	}
+	var files, executables []archive.Entry
+	for _, e := range entries {
+		if strings.HasSuffix(e.Path, "/") {
+			continue
+		}
+		files = append(files, e)
+		if mode, err := strconv.ParseUint(e.Mode, 8, 32); err == nil && mode&0o111 != 0 {
+			executables = append(executables, e)
+		}
+	}
+	base := path.Base(archivePath)
+	if len(files) == 0 {
+		return archive.Entry{}, fmt.Errorf("%s has no files to extract", base)
+	}
+
+	if name != "" {
+		var matches []archive.Entry
+		for _, e := range files {
+			if e.Path == name {
+				return e, nil
+			}
+			if path.Base(e.Path) == name {
+				matches = append(matches, e)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return archive.Entry{}, fmt.Errorf("%s has no file %q; upload.entry must be one of: %s", base, name, listEntries(files))
+		case 1:
+			return matches[0], nil
+		default:
+			return archive.Entry{}, fmt.Errorf("%q matches %d files in %s: %s; set upload.entry to the full path", name, len(matches), base, listEntries(matches))
+		}
+	}
+
+	switch {
+	case len(executables) == 1:
+		return executables[0], nil
+	case len(executables) == 0 && len(files) == 1:
+		return files[0], nil
+	case len(executables) > 1:
+		return archive.Entry{}, fmt.Errorf("%s has %d executable files: %s; set upload.entry to the one to upload", base, len(executables), listEntries(executables))
+	default:
+		return archive.Entry{}, fmt.Errorf("%s has no executable file among %d files: %s; set upload.entry to the one to upload", base, len(files), listEntries(files))
+	}
+}
+
+// listEntries joins the paths of entries, eliding all but the first
+// maxListedEntries.
+func listEntries(entries []archive.Entry) string {
+	paths := make([]string, 0, min(len(entries), maxListedEntries))
+	for _, e := range entries[:min(len(entries), maxListedEntries)] {
+		paths = append(paths, e.Path)
+	}
+	if more := len(entries) - len(paths); more > 0 {
+		paths = append(paths, fmt.Sprintf("and %d more", more))
+	}
+	return strings.Join(paths, ", ")
+}
@@ -0,0 +1,214 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// testArchive archives files, a map of names to content with an
+// executable mode for names ending in "*", into outDir/name.
+func testArchive(t *testing.T, outDir, name string, files map[string]string) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "app_v1.2.0_linux_amd64")
+	for file, content := range files {
+		mode := os.FileMode(0o644)
+		if strings.HasSuffix(file, "*") {
+			file, mode = strings.TrimSuffix(file, "*"), 0o755
+		}
+		p := filepath.Join(src, file)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	format, err := archive.FormatOf(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := archive.New(format, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(outDir, name)
+	if err := a.Archive(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	return dest
+}
+
+func TestRunStepsUpload(t *testing.T) {
+	outDir := t.TempDir()
+	tarGz := testArchive(t, outDir, "app_v1.2.0_linux_amd64.tar.gz", map[string]string{
+		"app*": "binary v1.2.0", "README.md": "readme",
+	})
+	zipPath := testArchive(t, outDir, "tools_v1.2.0_linux_amd64.zip", map[string]string{
+		"bin/app*": "app", "bin/migrate*": "migrate", "docs/app": "docs",
+	})
+	m := &manifest.Manifest{Version: "v1.2.0", Artifacts: []manifest.Artifact{
+		{Name: filepath.Base(tarGz), Path: tarGz},
+		{Name: filepath.Base(zipPath), Path: zipPath},
+	}}
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+	release := Release{Version: "v1.2.0", ArtifactsDir: outDir}
+	remote := t.TempDir()
+
+	runUpload := func(step config.UploadStep) error {
+		return runSteps(context.Background(), localClient{}, []config.DeployStep{{Upload: &step}}, release, stepsOptions{})
+	}
+
+	t.Run("archive as is", func(t *testing.T) {
+		dest := filepath.Join(remote, "{{.Version}}", "app.tar.gz")
+		if err := runUpload(config.UploadStep{Artifact: "app_*_linux_amd64.tar.gz", Dest: dest}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(remote, "v1.2.0", "app.tar.gz"))
+		want, _ := os.ReadFile(tarGz)
+		if err != nil || string(got) != string(want) {
+			t.Errorf("uploaded archive differs: %v", err)
+		}
+	})
+
+	t.Run("single executable", func(t *testing.T) {
+		dest := filepath.Join(remote, "bin", "app")
+		if err := runUpload(config.UploadStep{Artifact: "app_*.tar.gz", Dest: dest, Extract: true}); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o755 {
+			t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+		}
+		if got, _ := os.ReadFile(dest); string(got) != "binary v1.2.0" {
+			t.Errorf("content = %q", got)
+		}
+		if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+			t.Errorf("temporary file left behind: %v", err)
+		}
+	})
+
+	t.Run("entry by base name", func(t *testing.T) {
+		dest := filepath.Join(remote, "migrate")
+		if err := runUpload(config.UploadStep{Artifact: "tools_*", Dest: dest, Extract: true, Entry: "migrate"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(dest); string(got) != "migrate" {
+			t.Errorf("content = %q", got)
+		}
+	})
+
+	t.Run("entry by path", func(t *testing.T) {
+		dest := filepath.Join(remote, "docs")
+		entry := "app_v1.2.0_linux_amd64/docs/app"
+		if err := runUpload(config.UploadStep{Artifact: "tools_*", Dest: dest, Extract: true, Entry: entry}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(dest); string(got) != "docs" {
+			t.Errorf("content = %q", got)
+		}
+	})
+
+	errTests := []struct {
+		name string
+		step config.UploadStep
+		want string
+	}{
+		{
+			name: "several executables",
+			step: config.UploadStep{Artifact: "tools_*", Extract: true},
+			want: "tools_v1.2.0_linux_amd64.zip has 2 executable files: app_v1.2.0_linux_amd64/bin/app, app_v1.2.0_linux_amd64/bin/migrate; set upload.entry",
+		},
+		{
+			name: "ambiguous base name",
+			step: config.UploadStep{Artifact: "tools_*", Extract: true, Entry: "app"},
+			want: `"app" matches 2 files in tools_v1.2.0_linux_amd64.zip: app_v1.2.0_linux_amd64/bin/app, app_v1.2.0_linux_amd64/docs/app; set upload.entry to the full path`,
+		},
+		{
+			name: "missing entry",
+			step: config.UploadStep{Artifact: "app_*", Extract: true, Entry: "server"},
+			want: `app_v1.2.0_linux_amd64.tar.gz has no file "server"; upload.entry must be one of: app_v1.2.0_linux_amd64/`,
+		},
+		{
+			name: "missing artifact",
+			step: config.UploadStep{Artifact: "*_windows_amd64.zip", Extract: true},
+			want: `no artifact matches "*_windows_amd64.zip"`,
+		},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.step.Dest = filepath.Join(remote, "unused")
+			err := runUpload(tt.step)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if _, err := os.Stat(tt.step.Dest); !os.IsNotExist(err) {
+				t.Errorf("%s was written", tt.step.Dest)
+			}
+		})
+	}
+}
+
+func TestRunStepsSkipUnchanged(t *testing.T) {
+	outDir := t.TempDir()
+	tarGz := testArchive(t, outDir, "app_v1.2.0_linux_amd64.tar.gz", map[string]string{"app*": "binary v1.2.0"})
+	configFile := filepath.Join(outDir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("port: 80"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := &manifest.Manifest{Version: "v1.2.0", Artifacts: []manifest.Artifact{
+		{Name: filepath.Base(tarGz), Path: tarGz},
+		{Name: "config.yaml", Path: configFile},
+	}}
+	if err := manifest.Write(filepath.Join(outDir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+	release := Release{Version: "v1.2.0", ArtifactsDir: outDir}
+
+	remote := t.TempDir()
+	restarts := filepath.Join(remote, "restarts")
+	steps := []config.DeployStep{
+		{Upload: &config.UploadStep{Artifact: "app_*.tar.gz", Dest: filepath.Join(remote, "app"), Extract: true}},
+		{Upload: &config.UploadStep{Artifact: "config.yaml", Dest: filepath.Join(remote, "config.yaml")}},
+		{Run: "echo restart >> " + restarts, OnlyOnChange: true},
+	}
+	deploy := func(skipUnchanged bool) int {
+		t.Helper()
+		if err := runSteps(t.Context(), localClient{}, steps, release, stepsOptions{skipUnchanged: skipUnchanged}); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(restarts)
+		return strings.Count(string(data), "restart")
+	}
+
+	if got := deploy(true); got != 1 {
+		t.Fatalf("restarts after the first deploy = %d, want 1", got)
+	}
+	if got := deploy(true); got != 1 {
+		t.Errorf("restarts after an unchanged deploy = %d, want 1", got)
+	}
+	if err := os.WriteFile(filepath.Join(remote, "config.yaml"), []byte("port: 8080"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := deploy(true); got != 2 {
+		t.Errorf("restarts after a changed remote file = %d, want 2", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(remote, "config.yaml")); string(data) != "port: 80" {
+		t.Errorf("config.yaml = %q, want it restored", data)
+	}
+	// --force clears skip_unchanged
+	if got := deploy(false); got != 3 {
+		t.Errorf("restarts after a forced deploy = %d, want 3", got)
+	}
+}
@@ -0,0 +1,87 @@
+// Package envvars declares the environment variables gcx reads and is the
+// only place that reads them: every feature declares its variables in
+// Registry and looks them up with Get, while variables whose names come
+// from the config (token_env, key_env, {{.Env.NAME}}) are read with Named.
+// TestLookupsDeclared fails on os.Getenv and os.LookupEnv calls elsewhere
+// and on Get and cli.EnvVars names missing from Registry.
+package envvars
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Var is an environment variable read by gcx.
+type Var struct {
+	Name    string `json:"name"`
+	Purpose string `json:"purpose"`
+	// RequiredWhen describes when the variable must be set; empty for
+	// optional variables.
+	RequiredWhen string `json:"required_when,omitempty"`
+}
+
+// Registry lists the environment variables gcx reads by name.
+var Registry = []Var{
+	{Name: "GCX_CHANNEL", Purpose: "Release channel, like --channel"},
+	{Name: "GCX_CWD_RELATIVE_PATHS", Purpose: "Resolve config paths against the working directory, like --cwd-relative-paths"},
+	{Name: "GCX_VERSION", Purpose: "Version used instead of the git tag, like gcx build --version"},
+	{Name: "GCX_COMMIT", Purpose: "Commit hash used instead of the HEAD commit, like gcx build --commit"},
+	{Name: "GCX_CONFIRM_VERSION", Purpose: "Ask before releasing a version that is not the latest remote tag, like --confirm-version"},
+	{Name: "GCX_METRICS_PUSH_URL", Purpose: "Prometheus Pushgateway URL for run metrics, like --metrics-push-url"},
+	{Name: "GCX_METRICS_FILE", Purpose: "File to write run metrics to, like --metrics-file"},
+	{Name: "AWS_ACCESS_KEY_ID", Purpose: "S3 access key", RequiredWhen: "a blob uses the s3 provider"},
+	{Name: "AWS_SECRET_ACCESS_KEY", Purpose: "S3 secret key", RequiredWhen: "a blob uses the s3 provider"},
+	{Name: "COSIGN_PASSWORD", Purpose: "Password of an encrypted cosign key (read by cosign)"},
+	{Name: "SOURCE_DATE_EPOCH", Purpose: "Entry time of reproducible archives (default: commit time of HEAD)"},
+	{Name: "GOOS", Purpose: "Target OS of gcx build --single-target (default: the host)"},
+	{Name: "GOARCH", Purpose: "Target architecture of gcx build --single-target (default: the host)"},
+	{Name: "GIT_CONFIG_COUNT", Purpose: "Existing git config entries that git_auth adds to"},
+	{Name: "GITHUB_ACTIONS", Purpose: "Detects GitHub Actions for build annotations"},
+	{Name: "GITHUB_TOKEN", Purpose: "GitHub API token for attestations", RequiredWhen: "gcx attest subjects --push"},
+	{Name: "ACTIONS_ID_TOKEN_REQUEST_URL", Purpose: "OIDC token endpoint of the GitHub Actions job", RequiredWhen: "gcx attest subjects --push"},
+	{Name: "ACTIONS_ID_TOKEN_REQUEST_TOKEN", Purpose: "OIDC token request token of the GitHub Actions job", RequiredWhen: "gcx attest subjects --push"},
+	{Name: "GITHUB_REPOSITORY", Purpose: "owner/repo the attestations belong to", RequiredWhen: "gcx attest subjects --push"},
+	{Name: "GITHUB_API_URL", Purpose: "GitHub API URL (default: https://api.github.com)"},
+	{Name: "GITHUB_SERVER_URL", Purpose: "GitHub server URL (default: https://github.com)"},
+}
+
+// Declared reports whether name is in Registry.
+func Declared(name string) bool {
+	for _, v := range Registry {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretName matches the names of variables that usually hold
+// credentials, e.g. GITHUB_TOKEN or AWS_SECRET_ACCESS_KEY.
+var secretName = regexp.MustCompile(`(?i)TOKEN|SECRET|PASSWORD|PASSWD|PASSPHRASE|CREDENTIAL|PRIVATE|AUTH|KEY`)
+
+// Secret reports whether the value of name must not be printed.
+func Secret(name string) bool {
+	return secretName.MatchString(name)
+}
+
+// Get returns the value of the declared variable name.
+func Get(name string) string {
+	return os.Getenv(name)
+}
+
+// Named returns the value of a variable named in the config.
+func Named(name string) string {
+	return os.Getenv(name)
+}
+
+// Environ returns the environment as a map for templates.
+func Environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
@@ -0,0 +1,153 @@
+package envvars
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// TestLookupsDeclared fails on environment lookups that bypass the
+// registry: os.Getenv and os.LookupEnv outside this package, and names
+// passed to Get or cli.EnvVars that are not in Registry.
+func TestLookupsDeclared(t *testing.T) {
+	root := filepath.Join("..", "..")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") || path == filepath.Join(root, "internal", "envvars") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if pkg, ok := n.X.(*ast.Ident); ok && pkg.Name == "os" && (n.Sel.Name == "Getenv" || n.Sel.Name == "LookupEnv") {
+					t.Errorf("%s: os.%s; use envvars.Get or envvars.Named", fset.Position(n.Pos()), n.Sel.Name)
+				}
+			case *ast.CallExpr:
+				sel, ok := n.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				pkg, ok := sel.X.(*ast.Ident)
+				if !ok || !slices.Contains([]string{"envvars.Get", "cli.EnvVars"}, pkg.Name+"."+sel.Sel.Name) {
+					return true
+				}
+				for _, arg := range n.Args {
+					lit, ok := arg.(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						t.Errorf("%s: %s.%s with a computed name; use envvars.Named", fset.Position(arg.Pos()), pkg.Name, sel.Sel.Name)
+						continue
+					}
+					if name, _ := strconv.Unquote(lit.Value); !Declared(name) {
+						t.Errorf("%s: %s is not declared in envvars.Registry", fset.Position(arg.Pos()), name)
+					}
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, v := range Registry {
+		if v.Name == "" || v.Purpose == "" || seen[v.Name] {
+			t.Errorf("registry entry %+v: want a unique name and a purpose", v)
+		}
+		seen[v.Name] = true
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIA-test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("DEPLOY_TOKEN", "tok-3f9a")
+	t.Setenv("GCX_CHANNEL", "")
+
+	cfg := &config.Config{
+		Builds: []config.BuildConfig{{Ldflags: []string{"-X main.edition={{.Env.EDITION}}"}}},
+		Signs:  []config.SignConfig{{Provider: "cosign", KeyEnv: "COSIGN_KEY"}},
+		Blobs:  []config.BlobConfig{{Name: "cdn", Provider: "s3"}},
+		Deploys: []config.DeployConfig{{
+			Name: "prod", Approval: &config.ApprovalConfig{TokenEnv: "DEPLOY_TOKEN"},
+		}},
+	}
+	statuses, err := Report(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Status)
+	var names []string
+	for _, s := range statuses {
+		byName[s.Name] = s
+		names = append(names, s.Name)
+	}
+
+	if got, want := names[len(Registry):], []string{"COSIGN_KEY", "DEPLOY_TOKEN", "EDITION"}; !slices.Equal(got, want) {
+		t.Errorf("config variables = %v, want %v", got, want)
+	}
+	tests := []struct {
+		name   string
+		set    bool
+		usedBy []string
+	}{
+		{"AWS_ACCESS_KEY_ID", true, []string{"blobs[0]"}},
+		{"AWS_SECRET_ACCESS_KEY", false, []string{"blobs[0]"}},
+		{"COSIGN_PASSWORD", false, []string{"signs[0]"}},
+		{"COSIGN_KEY", false, []string{"signs[0].key_env"}},
+		{"DEPLOY_TOKEN", true, []string{"deploys[0].approval.token_env"}},
+		{"EDITION", false, []string{"templates"}},
+		{"GCX_CHANNEL", false, nil},
+	}
+	for _, tt := range tests {
+		s := byName[tt.name]
+		if s.Set != tt.set || !slices.Equal(s.UsedBy, tt.usedBy) {
+			t.Errorf("%s: set = %v, used by %v; want %v, %v", tt.name, s.Set, s.UsedBy, tt.set, tt.usedBy)
+		}
+	}
+
+	var sb strings.Builder
+	if err := WriteTable(&sb, statuses); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), "AKIA-test") || strings.Contains(sb.String(), "tok-3f9a") {
+		t.Errorf("table prints a value:\n%s", sb.String())
+	}
+}
+
+func TestSecret(t *testing.T) {
+	for _, name := range []string{"GITHUB_TOKEN", "AWS_SECRET_ACCESS_KEY", "COSIGN_PASSWORD", "deploy_key", "GIT_AUTH"} {
+		if !Secret(name) {
+			t.Errorf("Secret(%s) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"USER_NAME", "GOOS", "SOURCE_DATE_EPOCH"} {
+		if Secret(name) {
+			t.Errorf("Secret(%s) = true, want false", name)
+		}
+	}
+}
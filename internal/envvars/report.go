@@ -0,0 +1,137 @@
+package envvars
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Status is a variable as gcx env reports it. The value is never
+// included.
+type Status struct {
+	Var
+	Set bool `json:"set"`
+	// UsedBy lists the configured features that read the variable.
+	UsedBy []string `json:"used_by,omitempty"`
+}
+
+// templateRef matches the {{.Env.NAME}} references of config templates.
+var templateRef = regexp.MustCompile(`\.Env\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Report returns the status of the variables in Registry followed by
+// those named in cfg, which may be nil when there is no config.
+func Report(cfg *config.Config) ([]Status, error) {
+	used := make(map[string][]string)
+	var named []Var
+	if cfg != nil {
+		var err error
+		if named, err = usedBy(cfg, used); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, v := range slices.Concat(Registry, named) {
+		statuses = append(statuses, Status{Var: v, Set: Named(v.Name) != "", UsedBy: used[v.Name]})
+	}
+	return statuses, nil
+}
+
+// usedBy records in used the features of cfg that read each variable and
+// returns the variables cfg names that are not in Registry.
+func usedBy(cfg *config.Config, used map[string][]string) ([]Var, error) {
+	var named []Var
+	use := func(name, feature string) {
+		if !slices.Contains(used[name], feature) {
+			used[name] = append(used[name], feature)
+		}
+	}
+	name := func(name, feature, purpose, requiredWhen string) {
+		if name == "" {
+			return
+		}
+		if !Declared(name) && !slices.ContainsFunc(named, func(v Var) bool { return v.Name == name }) {
+			named = append(named, Var{Name: name, Purpose: purpose, RequiredWhen: requiredWhen})
+		}
+		use(name, feature)
+	}
+
+	gitAuth := func(auth *config.GitAuthConfig, feature string) {
+		if auth == nil {
+			return
+		}
+		name(auth.TokenEnv, feature+".token_env", "Token for private modules", feature+" is configured")
+		use("GIT_CONFIG_COUNT", feature)
+	}
+	gitAuth(cfg.GitAuth, "git_auth")
+	for i, b := range cfg.Builds {
+		gitAuth(b.GitAuth, fmt.Sprintf("builds[%d].git_auth", i))
+	}
+	for i, a := range cfg.Archives {
+		if a.Reproducible {
+			use("SOURCE_DATE_EPOCH", fmt.Sprintf("archives[%d]", i))
+		}
+	}
+	for i, s := range cfg.Signs {
+		feature := fmt.Sprintf("signs[%d]", i)
+		name(s.KeyEnv, feature+".key_env", "Signing key or key file path", feature+" is configured")
+		if s.Provider == "cosign" && (s.KeyPath != "" || s.KeyEnv != "") {
+			use("COSIGN_PASSWORD", feature)
+		}
+	}
+	for i, b := range cfg.Blobs {
+		if b.Provider == "s3" {
+			feature := fmt.Sprintf("blobs[%d]", i)
+			use("AWS_ACCESS_KEY_ID", feature)
+			use("AWS_SECRET_ACCESS_KEY", feature)
+		}
+	}
+	for i, d := range cfg.Deploys {
+		if d.Approval != nil {
+			feature := fmt.Sprintf("deploys[%d].approval", i)
+			name(d.Approval.TokenEnv, feature+".token_env", "Approval service token", feature+" is configured")
+		}
+	}
+
+	// Templates may reference any variable; an unset one renders empty
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	for _, m := range templateRef.FindAllStringSubmatch(string(data), -1) {
+		name(m[1], "templates", "Referenced as {{.Env."+m[1]+"}}", "")
+	}
+	return named, nil
+}
+
+// WriteTable prints statuses as an aligned table, one line per variable.
+func WriteTable(w io.Writer, statuses []Status) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSET\tREQUIRED WHEN\tUSED BY\tPURPOSE")
+	for _, s := range statuses {
+		set := "no"
+		if s.Set {
+			set = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, set, cmp.Or(s.RequiredWhen, "-"), cmp.Or(strings.Join(s.UsedBy, ", "), "-"), s.Purpose)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON prints statuses as indented JSON.
+func WriteJSON(w io.Writer, statuses []Status) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(statuses); err != nil {
+		return fmt.Errorf("encode env: %w", err)
+	}
+	return nil
+}
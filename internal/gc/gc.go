@@ -0,0 +1,271 @@
+package gc
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// DefaultKeepLast is used when neither keep_last nor max_age is configured.
+const DefaultKeepLast = 3
+
+// DefaultCacheDir is the gcx cache directory relative to the config directory.
+const DefaultCacheDir = ".gcx/cache"
+
+// versionMarker is rendered in place of {{.Version}} to locate it in out_dir.
+const versionMarker = "__GCX_VERSION__"
+
+// now is replaced in tests.
+var now = time.Now
+
+// Options controls what Run prunes.
+type Options struct {
+	KeepLast     int
+	MaxAge       time.Duration
+	CacheDir     string
+	CacheMaxAge  time.Duration
+	CacheMaxSize int64
+	DryRun       bool
+}
+
+// OptionsFromConfig returns the options configured in the gc section.
+func OptionsFromConfig(cfg config.GCConfig) Options {
+	return Options{
+		KeepLast:     cfg.KeepLast,
+		MaxAge:       cfg.MaxAge.Std(),
+		CacheDir:     cfg.CacheDir,
+		CacheMaxAge:  cfg.CacheMaxAge.Std(),
+		CacheMaxSize: cfg.CacheMaxSize.Bytes(),
+	}
+}
+
+// Removal describes a pruned (or, in dry-run mode, prunable) path.
+type Removal struct {
+	Path   string
+	Size   int64
+	Reason string
+}
+
+// Run prunes old versioned output directories and trims the gcx cache.
+// Only output directories containing a build manifest are considered, so
+// directories not created by gcx are never touched.
+func Run(cfg *config.Config, opts Options) ([]Removal, error) {
+	if opts.KeepLast == 0 && opts.MaxAge == 0 {
+		opts.KeepLast = DefaultKeepLast
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = filepath.Join(cfg.Dir, DefaultCacheDir)
+	}
+
+	outputs, err := pruneOutputs(cfg.OutDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := trimCache(opts)
+	if err != nil {
+		return nil, err
+	}
+	return append(outputs, cache...), nil
+}
+
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func pruneOutputs(outDirTemplate string, opts Options) ([]Removal, error) {
+	root, prefix, suffix, rest, ok := outputLayout(outDirTemplate)
+	if !ok {
+		// out_dir is not versioned: there is only one output directory
+		return nil, nil
+	}
+
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read output root %s: %w", root, err)
+	}
+
+	var outputs []entry
+	for _, d := range dirs {
+		name := d.Name()
+		if !d.IsDir() || len(name) <= len(prefix)+len(suffix) ||
+			!strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		dir := filepath.Join(root, name)
+		manifestPath := filepath.Join(dir, rest, manifest.FileName)
+		info, err := os.Stat(manifestPath)
+		if err != nil {
+			continue // not created by gcx
+		}
+
+		created := info.ModTime()
+		if m, err := manifest.Load(manifestPath); err == nil && !m.Created.IsZero() {
+			created = m.Created
+		}
+		size, _, err := usage(dir)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, entry{path: dir, size: size, modTime: created})
+	}
+
+	// Newest first
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].modTime.After(outputs[j].modTime) })
+
+	var removals []Removal
+	for i, o := range outputs {
+		if opts.KeepLast > 0 && i < opts.KeepLast {
+			continue
+		}
+		age := now().Sub(o.modTime)
+		if opts.MaxAge > 0 && age <= opts.MaxAge {
+			continue
+		}
+		reason := fmt.Sprintf("older than the last %d builds", opts.KeepLast)
+		if opts.MaxAge > 0 {
+			reason = fmt.Sprintf("built %s ago", age.Round(time.Hour))
+		}
+		r := Removal{Path: o.path, Size: o.size, Reason: reason}
+		if err := remove(r, opts.DryRun); err != nil {
+			return removals, err
+		}
+		removals = append(removals, r)
+	}
+	return removals, nil
+}
+
+func trimCache(opts Options) ([]Removal, error) {
+	if opts.CacheMaxAge == 0 && opts.CacheMaxSize == 0 {
+		return nil, nil
+	}
+
+	items, err := os.ReadDir(opts.CacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read cache directory %s: %w", opts.CacheDir, err)
+	}
+
+	var entries []entry
+	var total int64
+	for _, item := range items {
+		p := filepath.Join(opts.CacheDir, item.Name())
+		size, modTime, err := usage(p)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{path: p, size: size, modTime: modTime})
+		total += size
+	}
+
+	// Oldest first
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+
+	var removals []Removal
+	for _, e := range entries {
+		var reason string
+		switch {
+		case opts.CacheMaxAge > 0 && now().Sub(e.modTime) > opts.CacheMaxAge:
+			reason = fmt.Sprintf("cache entry unused for %s", now().Sub(e.modTime).Round(time.Hour))
+		case opts.CacheMaxSize > 0 && total > opts.CacheMaxSize:
+			reason = fmt.Sprintf("cache over budget (%s > %s)", helpers.FormatBytes(total), helpers.FormatBytes(opts.CacheMaxSize))
+		default:
+			continue
+		}
+		r := Removal{Path: e.path, Size: e.size, Reason: reason}
+		if err := remove(r, opts.DryRun); err != nil {
+			return removals, err
+		}
+		removals = append(removals, r)
+		total -= e.size
+	}
+	return removals, nil
+}
+
+func remove(r Removal, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+	if err := os.RemoveAll(r.Path); err != nil {
+		return fmt.Errorf("remove %s: %w", r.Path, err)
+	}
+	log.Printf("Removed %s (%s): %s", r.Path, helpers.FormatBytes(r.Size), r.Reason)
+	return nil
+}
+
+// outputLayout splits the out_dir template around {{.Version}}: versioned
+// directories are entries of root named prefix+version+suffix, and the build
+// output lives in rest below them.
+func outputLayout(outDirTemplate string) (root, prefix, suffix, rest string, ok bool) {
+	cfg := config.Config{OutDir: outDirTemplate}
+	rendered, err := cfg.OutputDir(versionMarker)
+	if err != nil {
+		return "", "", "", "", false
+	}
+	before, after, found := strings.Cut(filepath.ToSlash(rendered), versionMarker)
+	if !found {
+		return "", "", "", "", false
+	}
+
+	root = "."
+	if i := strings.LastIndex(before, "/"); i >= 0 {
+		root, prefix = before[:i], before[i+1:]
+		if root == "" {
+			root = "/"
+		}
+	} else {
+		prefix = before
+	}
+	suffix, rest, _ = strings.Cut(after, "/")
+	return filepath.FromSlash(root), prefix, suffix, filepath.FromSlash(rest), true
+}
+
+// usage returns the total size of the files under p and their latest modification time.
+func usage(p string) (int64, time.Time, error) {
+	var size int64
+	var latest time.Time
+	err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("measure %s: %w", p, err)
+	}
+	return size, latest, nil
+}
+
+// Freed returns the total size of the removals.
+func Freed(removals []Removal) int64 {
+	var total int64
+	for _, r := range removals {
+		total += r.Size
+	}
+	return total
+}
@@ -0,0 +1,143 @@
+package gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestOutputLayout(t *testing.T) {
+	tests := []struct {
+		template                   string
+		root, prefix, suffix, rest string
+		ok                         bool
+	}{
+		{template: "dist", ok: false},
+		{template: "dist/{{.Version}}", root: "dist", ok: true},
+		{template: "{{.Version}}", root: ".", ok: true},
+		{template: "build/app-{{.Version}}-out/bin", root: "build", prefix: "app-", suffix: "-out", rest: "bin", ok: true},
+	}
+	for _, tt := range tests {
+		root, prefix, suffix, rest, ok := outputLayout(tt.template)
+		if ok != tt.ok || (ok && (root != tt.root || prefix != tt.prefix || suffix != tt.suffix || rest != tt.rest)) {
+			t.Errorf("outputLayout(%q) = %q, %q, %q, %q, %v", tt.template, root, prefix, suffix, rest, ok)
+		}
+	}
+}
+
+func writeBuild(t *testing.T, dir string, created time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.tar.gz"), make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.Write(filepath.Join(dir, manifest.FileName), &manifest.Manifest{Created: created}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunOutputs(t *testing.T) {
+	base := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	t.Cleanup(func() { now = time.Now })
+
+	setup := func(t *testing.T) string {
+		root := t.TempDir()
+		for i, v := range []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0"} {
+			writeBuild(t, filepath.Join(root, v), base.Add(time.Duration(i-10)*24*time.Hour))
+		}
+		// Not created by gcx: no manifest
+		if err := os.MkdirAll(filepath.Join(root, "v0.1.0"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	t.Run("keep last", func(t *testing.T) {
+		root := setup(t)
+		cfg := &config.Config{OutDir: filepath.Join(root, "{{.Version}}")}
+		removals, err := Run(cfg, Options{KeepLast: 2, CacheDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(removals) != 2 {
+			t.Fatalf("expected 2 removals, got %+v", removals)
+		}
+		for _, v := range []string{"v1.0.0", "v1.1.0"} {
+			if _, err := os.Stat(filepath.Join(root, v)); !os.IsNotExist(err) {
+				t.Errorf("%s should have been removed", v)
+			}
+		}
+		for _, v := range []string{"v1.2.0", "v1.3.0", "v0.1.0"} {
+			if _, err := os.Stat(filepath.Join(root, v)); err != nil {
+				t.Errorf("%s should have been kept: %v", v, err)
+			}
+		}
+		if Freed(removals) <= 200 {
+			t.Errorf("Freed() = %d, want > 200", Freed(removals))
+		}
+	})
+
+	t.Run("max age with dry run", func(t *testing.T) {
+		root := setup(t)
+		cfg := &config.Config{OutDir: filepath.Join(root, "{{.Version}}")}
+		removals, err := Run(cfg, Options{KeepLast: 1, MaxAge: 8 * 24 * time.Hour, DryRun: true, CacheDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// v1.0.0 (10d) and v1.1.0 (9d) are older than 8d
+		if len(removals) != 2 {
+			t.Fatalf("expected 2 removals, got %+v", removals)
+		}
+		if _, err := os.Stat(filepath.Join(root, "v1.0.0")); err != nil {
+			t.Error("dry run must not remove anything")
+		}
+	})
+
+	t.Run("unversioned out_dir", func(t *testing.T) {
+		root := setup(t)
+		removals, err := Run(&config.Config{OutDir: root}, Options{KeepLast: 1, CacheDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(removals) != 0 {
+			t.Errorf("expected no removals, got %+v", removals)
+		}
+	})
+}
+
+func TestTrimCache(t *testing.T) {
+	base := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	t.Cleanup(func() { now = time.Now })
+
+	cacheDir := t.TempDir()
+	for i, name := range []string{"a", "b", "c"} {
+		p := filepath.Join(cacheDir, name)
+		if err := os.WriteFile(p, make([]byte, 1000), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mod := base.Add(-time.Duration(3-i) * 24 * time.Hour)
+		if err := os.Chtimes(p, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removals, err := trimCache(Options{CacheDir: cacheDir, CacheMaxAge: 60 * time.Hour, CacheMaxSize: 1500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "a" is 3 days old; then "b" is removed to fit the 1500 byte budget
+	if len(removals) != 2 || filepath.Base(removals[0].Path) != "a" || filepath.Base(removals[1].Path) != "b" {
+		t.Fatalf("unexpected removals: %+v", removals)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "c")); err != nil {
+		t.Errorf("newest entry should be kept: %v", err)
+	}
+}
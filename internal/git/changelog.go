@@ -0,0 +1,220 @@
+package git
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Changelog groupings of ChangelogOptions.GroupBy.
+const (
+	GroupByType          = "type"
+	GroupByScope         = "scope"
+	GroupByTypeThenScope = "type_then_scope"
+)
+
+// GroupBys lists the supported changelog groupings.
+var GroupBys = []string{GroupByType, GroupByScope, GroupByTypeThenScope}
+
+// Unscoped is the scope section of commits without a scope.
+const Unscoped = "unscoped"
+
+// otherChanges is the type section of commits without a type.
+const otherChanges = "Other Changes"
+
+// ChangelogOptions configure GetChangelog.
+type ChangelogOptions struct {
+	// RepoURL is the compare link base when the checkout has no remote.
+	RepoURL string
+	// GroupBy groups conventional commits into sections by type, scope or
+	// both; empty lists the commits in git log order.
+	GroupBy string
+	// ScopeAliases maps a scope to the scope it is listed under, e.g.
+	// "apiv2" to "api".
+	ScopeAliases map[string]string
+}
+
+// typeTitles are the section titles of the conventional commit types, in
+// the order the sections are rendered.
+var typeTitles = []struct{ typ, title string }{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"refactor", "Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build System"},
+	{"ci", "Continuous Integration"},
+	{"chore", "Chores"},
+}
+
+// conventionalRegex matches a conventional commit subject such as
+// "feat(api)!: drop v1" as type, scope, breaking marker and description.
+var conventionalRegex = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()]*)\))?(!)?: +(.+)$`)
+
+// scopeRegex matches a subject prefixed with a scope only, as in the Go
+// style "worker: fix leak" or "internal/build: add hooks".
+var scopeRegex = regexp.MustCompile(`^([\w./-]+): +(.+)$`)
+
+// commit is a commit listed in the changelog.
+type commit struct {
+	subject, author, hash string
+}
+
+// entry is a commit with its subject parsed. typ is empty unless the
+// subject starts with a type of typeTitles; scope is empty when the
+// subject names none.
+type entry struct {
+	commit
+	typ, scope, description string
+	breaking                bool
+}
+
+// parseCommit parses the subject of c and resolves its scope alias.
+// Types and scopes are lowered, so aliases must be lower case.
+func parseCommit(c commit, aliases map[string]string) entry {
+	e := entry{commit: c, description: c.subject}
+	if m := conventionalRegex.FindStringSubmatch(c.subject); m != nil && knownType(m[1]) {
+		e.typ, e.breaking, e.description = strings.ToLower(m[1]), m[3] != "", m[4]
+		e.scope = strings.ToLower(strings.TrimSpace(m[2]))
+	} else if m := scopeRegex.FindStringSubmatch(c.subject); m != nil {
+		e.scope, e.description = strings.ToLower(m[1]), m[2]
+	}
+	if scope, ok := aliases[e.scope]; ok {
+		e.scope = scope
+	}
+	return e
+}
+
+// knownType reports whether typ is a type of typeTitles.
+func knownType(typ string) bool {
+	return slices.ContainsFunc(typeTitles, func(t struct{ typ, title string }) bool { return t.typ == strings.ToLower(typ) })
+}
+
+// typeTitle returns the type section of e.
+func (e entry) typeTitle() string {
+	for _, t := range typeTitles {
+		if t.typ == e.typ {
+			return t.title
+		}
+	}
+	return otherChanges
+}
+
+// sectionScope returns the scope section of e.
+func (e entry) sectionScope() string {
+	return cmp.Or(e.scope, Unscoped)
+}
+
+// line renders e as a list item. Parts of the subject that are section
+// headings under groupBy are left out of it.
+func (e entry) line(groupBy string) string {
+	text := e.subject
+	switch {
+	case groupBy == "":
+	case groupBy == GroupByScope && e.typ != "":
+		text = e.typ
+		if e.breaking {
+			text += "!"
+		}
+		text += ": " + e.description
+	case groupBy == GroupByScope || groupBy == GroupByTypeThenScope:
+		text = e.description
+	case e.typ != "":
+		// Grouped by type
+		text = e.description
+		if e.scope != "" {
+			text = "**" + e.scope + ":** " + text
+		}
+	}
+	if e.breaking && groupBy != "" && groupBy != GroupByScope {
+		text = "**BREAKING:** " + text
+	}
+	return fmt.Sprintf("* %s by @%s in %s", text, e.author, e.hash)
+}
+
+// section is a heading with the commits listed under it.
+type section struct {
+	title   string
+	entries []entry
+}
+
+// group sorts entries into sections keyed by key, ordered by rank and then
+// by title; entries keep their order within a section.
+func group(entries []entry, key func(entry) string, rank func(string) int) []section {
+	var sections []section
+	for _, e := range entries {
+		title := key(e)
+		i := slices.IndexFunc(sections, func(s section) bool { return s.title == title })
+		if i < 0 {
+			sections = append(sections, section{title: title})
+			i = len(sections) - 1
+		}
+		sections[i].entries = append(sections[i].entries, e)
+	}
+	slices.SortStableFunc(sections, func(a, b section) int {
+		return cmp.Or(cmp.Compare(rank(a.title), rank(b.title)), cmp.Compare(a.title, b.title))
+	})
+	return sections
+}
+
+// typeRank orders type sections like typeTitles, with Other Changes last.
+func typeRank(title string) int {
+	if i := slices.IndexFunc(typeTitles, func(t struct{ typ, title string }) bool { return t.title == title }); i >= 0 {
+		return i
+	}
+	return len(typeTitles)
+}
+
+// scopeRank orders scope sections by name, with the unscoped one last.
+func scopeRank(title string) int {
+	if title == Unscoped {
+		return 1
+	}
+	return 0
+}
+
+// renderChangelog renders the list of commits grouped by opts.GroupBy,
+// with a "### " heading per section and a "#### " heading per scope below
+// the type sections of type_then_scope.
+func renderChangelog(commits []commit, opts ChangelogOptions) string {
+	entries := make([]entry, len(commits))
+	for i, c := range commits {
+		entries[i] = parseCommit(c, opts.ScopeAliases)
+	}
+
+	var blocks []string
+	list := func(entries []entry) {
+		lines := make([]string, len(entries))
+		for i, e := range entries {
+			lines[i] = e.line(opts.GroupBy)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	switch opts.GroupBy {
+	case GroupByType:
+		for _, s := range group(entries, entry.typeTitle, typeRank) {
+			blocks = append(blocks, "### "+s.title)
+			list(s.entries)
+		}
+	case GroupByScope:
+		for _, s := range group(entries, entry.sectionScope, scopeRank) {
+			blocks = append(blocks, "### "+s.title)
+			list(s.entries)
+		}
+	case GroupByTypeThenScope:
+		for _, s := range group(entries, entry.typeTitle, typeRank) {
+			blocks = append(blocks, "### "+s.title)
+			for _, scoped := range group(s.entries, entry.sectionScope, scopeRank) {
+				blocks = append(blocks, "#### "+scoped.title)
+				list(scoped.entries)
+			}
+		}
+	default:
+		list(entries)
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseCommit(t *testing.T) {
+	aliases := map[string]string{"apiv2": "api"}
+	tests := []struct {
+		subject          string
+		typ, scope, desc string
+		breaking         bool
+	}{
+		{"feat(api): add users endpoint", "feat", "api", "add users endpoint", false},
+		{"Fix(Worker)!: retry forever", "fix", "worker", "retry forever", true},
+		{"feat(apiv2): add v2 routes", "feat", "api", "add v2 routes", false},
+		{"fix: handle empty config", "fix", "", "handle empty config", false},
+		{"internal/build: add hooks", "", "internal/build", "add hooks", false},
+		{"apiv2: rename field", "", "api", "rename field", false},
+		{"wip(api): spike", "", "", "wip(api): spike", false},
+		{"Merge pull request #12 from acme/main", "", "", "Merge pull request #12 from acme/main", false},
+		{"feat:missing space", "", "", "feat:missing space", false},
+	}
+	for _, tt := range tests {
+		e := parseCommit(commit{subject: tt.subject}, aliases)
+		if e.typ != tt.typ || e.scope != tt.scope || e.description != tt.desc || e.breaking != tt.breaking {
+			t.Errorf("parseCommit(%q) = %q, %q, %q, %v; want %q, %q, %q, %v", tt.subject,
+				e.typ, e.scope, e.description, e.breaking, tt.typ, tt.scope, tt.desc, tt.breaking)
+		}
+	}
+}
+
+func TestRenderChangelog(t *testing.T) {
+	// Commits with both, either and neither of type and scope
+	commits := []commit{
+		{"feat(api): add users endpoint", "ann", "a1"},
+		{"fix: handle empty config", "bob", "b2"},
+		{"worker: drain queue on shutdown", "cat", "c3"},
+		{"Update README", "dan", "d4"},
+		{"feat(apiv2)!: drop v1 routes", "ann", "e5"},
+		{"fix(worker): stop leaking goroutines", "bob", "f6"},
+	}
+	aliases := map[string]string{"apiv2": "api"}
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{"", `* feat(api): add users endpoint by @ann in a1
+* fix: handle empty config by @bob in b2
+* worker: drain queue on shutdown by @cat in c3
+* Update README by @dan in d4
+* feat(apiv2)!: drop v1 routes by @ann in e5
+* fix(worker): stop leaking goroutines by @bob in f6
+`},
+		{GroupByType, `### Features
+
+* **api:** add users endpoint by @ann in a1
+* **BREAKING:** **api:** drop v1 routes by @ann in e5
+
+### Bug Fixes
+
+* handle empty config by @bob in b2
+* **worker:** stop leaking goroutines by @bob in f6
+
+### Other Changes
+
+* worker: drain queue on shutdown by @cat in c3
+* Update README by @dan in d4
+`},
+		{GroupByScope, `### api
+
+* feat: add users endpoint by @ann in a1
+* feat!: drop v1 routes by @ann in e5
+
+### worker
+
+* drain queue on shutdown by @cat in c3
+* fix: stop leaking goroutines by @bob in f6
+
+### unscoped
+
+* fix: handle empty config by @bob in b2
+* Update README by @dan in d4
+`},
+		{GroupByTypeThenScope, `### Features
+
+#### api
+
+* add users endpoint by @ann in a1
+* **BREAKING:** drop v1 routes by @ann in e5
+
+### Bug Fixes
+
+#### worker
+
+* stop leaking goroutines by @bob in f6
+
+#### unscoped
+
+* handle empty config by @bob in b2
+
+### Other Changes
+
+#### worker
+
+* drain queue on shutdown by @cat in c3
+
+#### unscoped
+
+* Update README by @dan in d4
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			if got := renderChangelog(commits, ChangelogOptions{GroupBy: tt.groupBy, ScopeAliases: aliases}); got != tt.want {
+				t.Errorf("renderChangelog() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetChangelogGroupBy(t *testing.T) {
+	dir := initRepo(t)
+	commitFile(t, dir, "main.go")
+	runGit(t, "tag", "v1.0.0")
+	for _, subject := range []string{"fix(api): check input", "feat: add flag"} {
+		runGit(t, "commit", "-q", "--allow-empty", "-m", subject)
+	}
+	runGit(t, "tag", "v1.1.0")
+
+	got, err := GetChangelog(context.Background(), "v1.0.0", "v1.1.0", ChangelogOptions{GroupBy: GroupByType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## What's Changed\n\n### Features\n\n* add flag by @test in "
+	if !strings.HasPrefix(got, want) || !strings.Contains(got, "### Bug Fixes\n\n* **api:** check input by @test in ") {
+		t.Errorf("changelog = %q, want type sections", got)
+	}
+}
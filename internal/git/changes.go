@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/helpers"
+)
+
+// Changes lists the files changed between two tags.
+type Changes struct {
+	From  string
+	To    string
+	Files []string
+}
+
+// Any reports whether any changed file matches one of globs. Changes without
+// a previous tag (the first release) match everything.
+func (c *Changes) Any(globs []string) bool {
+	if c.From == "" {
+		return true
+	}
+	for _, f := range c.Files {
+		if helpers.MatchAnyGlob(globs, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetChanges returns the files changed between the current tag and the
+// previous one. Only tags starting with tagPrefix are considered, so
+// per-service tags such as "api/v1.2.0" are compared with each other.
+// From is empty when there is no previous tag.
+func GetChanges(ctx context.Context, tagPrefix string) (*Changes, error) {
+	current, err := describeTag(ctx, tagPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "tag", "-l", tagPrefix+"*", "--sort=-v:refname").Output()
+	if err != nil {
+		return nil, fmt.Errorf("list git tags: %w", err)
+	}
+
+	changes := &Changes{To: current}
+	tags := strings.Fields(string(out))
+	for i, tag := range tags {
+		if tag == current && i+1 < len(tags) {
+			changes.From = tags[i+1]
+			break
+		}
+	}
+	if changes.From == "" {
+		return changes, nil
+	}
+
+	// -z keeps paths with spaces or non-ASCII characters intact and unquoted.
+	out, err = exec.CommandContext(ctx, "git", "diff", "--name-only", "-z", changes.From, changes.To).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s..%s: %w", changes.From, changes.To, err)
+	}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			changes.Files = append(changes.Files, file)
+		}
+	}
+	return changes, nil
+}
+
+func describeTag(ctx context.Context, tagPrefix string) (string, error) {
+	args := []string{"describe", "--tags", "--abbrev=0"}
+	if tagPrefix != "" {
+		args = append(args, "--match", tagPrefix+"*")
+	}
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("find current tag with prefix %q: %w", tagPrefix, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
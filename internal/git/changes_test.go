@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	runGit(t, "init", "-q")
+	runGit(t, "config", "user.email", "test@example.com")
+	runGit(t, "config", "user.name", "test")
+	runGit(t, "config", "commit.gpgsign", "false")
+	runGit(t, "config", "tag.gpgsign", "false")
+	return dir
+}
+
+func runGit(t *testing.T, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func commitFile(t *testing.T, dir, name string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(p)
+	if err := os.WriteFile(p, append(data, 'x'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, "add", "-A")
+	runGit(t, "commit", "-q", "-m", "change "+name)
+}
+
+func TestGetChanges(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+
+	commitFile(t, dir, "services/api/main.go")
+	commitFile(t, dir, "services/web/main.go")
+	runGit(t, "tag", "api/v1.0.0")
+	runGit(t, "tag", "web/v1.0.0")
+
+	changes, err := GetChanges(ctx, "api/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.From != "" || !changes.Any([]string{"nothing/**"}) {
+		t.Errorf("first release should match everything: %+v", changes)
+	}
+
+	commitFile(t, dir, "services/web/main.go")
+	runGit(t, "tag", "web/v1.1.0")
+	commitFile(t, dir, "services/api/handler.go")
+	runGit(t, "tag", "api/v1.1.0")
+
+	changes, err = GetChanges(ctx, "api/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.From != "api/v1.0.0" || changes.To != "api/v1.1.0" {
+		t.Fatalf("unexpected range %s..%s", changes.From, changes.To)
+	}
+	if !slices.Equal(changes.Files, []string{"services/api/handler.go", "services/web/main.go"}) {
+		t.Errorf("Files = %v", changes.Files)
+	}
+
+	changes, err = GetChanges(ctx, "web/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.From != "web/v1.0.0" || changes.To != "web/v1.1.0" {
+		t.Fatalf("unexpected range %s..%s", changes.From, changes.To)
+	}
+	if !changes.Any([]string{"services/web/**"}) || changes.Any([]string{"services/api/**"}) {
+		t.Errorf("unexpected matches for %v", changes.Files)
+	}
+}
+
+func TestGetChangesPathsWithSpaces(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+
+	commitFile(t, dir, "services/api/main.go")
+	runGit(t, "tag", "v1.0.0")
+	commitFile(t, dir, "services/my api/main.go")
+	commitFile(t, dir, "docs/ünïcode.md")
+	runGit(t, "tag", "v1.1.0")
+
+	changes, err := GetChanges(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changes.Files, []string{"docs/ünïcode.md", "services/my api/main.go"}) {
+		t.Errorf("Files = %q", changes.Files)
+	}
+	if !changes.Any([]string{"services/my api/**"}) {
+		t.Errorf("expected a match for the spaced path in %q", changes.Files)
+	}
+}
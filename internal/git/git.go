@@ -1,20 +1,53 @@
 package git
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var stableTagRegex = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
 
 const defaultVersion = "0.0.0"
 
-// GetTag returns the current git tag. Returns "0.0.0" if not found.
+// Overrides of the tag and commit hash read from the repository, set by
+// SetVersion and SetCommit.
+var (
+	versionOverride string
+	commitOverride  string
+)
+
+// SetVersion makes GetTag return version instead of the latest tag, e.g.
+// for a CI that creates the tag only after the artifacts are validated.
+// An empty version restores the git tag.
+func SetVersion(version string) {
+	versionOverride = version
+}
+
+// SetCommit makes GetCommitHash return hash instead of the HEAD commit.
+// An empty hash restores the HEAD commit.
+func SetCommit(hash string) {
+	commitOverride = hash
+}
+
+// GetTag returns the version set by SetVersion, else the current git tag.
+// Returns "0.0.0" if not found.
 func GetTag(ctx context.Context) string {
+	if versionOverride != "" {
+		return versionOverride
+	}
 	cmd := exec.CommandContext(ctx, "git", "describe", "--tags", "--abbrev=0")
 	out, err := cmd.Output()
 	if err != nil {
@@ -29,6 +62,12 @@ func GetTag(ctx context.Context) string {
 	return tag
 }
 
+// IsTagged reports whether HEAD is exactly at a tag, as opposed to a
+// snapshot built from commits after the latest tag.
+func IsTagged(ctx context.Context) bool {
+	return exec.CommandContext(ctx, "git", "describe", "--tags", "--exact-match", "HEAD").Run() == nil
+}
+
 // GetPreviousTag returns the previous git tag before the current one.
 func GetPreviousTag(ctx context.Context) string {
 	cmd := exec.CommandContext(ctx, "git", "tag", "-l", "--sort=-v:refname")
@@ -85,8 +124,12 @@ func GetPreviousStableTag(ctx context.Context) string {
 	return defaultVersion
 }
 
-// GetCommitHash returns the short git commit hash.
+// GetCommitHash returns the hash set by SetCommit, else the short git
+// commit hash.
 func GetCommitHash(ctx context.Context) string {
+	if commitOverride != "" {
+		return commitOverride
+	}
 	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD")
 	out, err := cmd.Output()
 	if err != nil {
@@ -96,38 +139,202 @@ func GetCommitHash(ctx context.Context) string {
 	return strings.TrimSpace(string(out))
 }
 
-// GetChangelog returns a markdown formatted changelog between two tags.
-func GetChangelog(ctx context.Context, from, to string) (string, error) {
-	remoteCmd := exec.CommandContext(ctx, "git", "config", "--get", "remote.origin.url")
-	remoteOut, err := remoteCmd.Output()
+// CommitTime returns the committer time of HEAD.
+func CommitTime(ctx context.Context) (time.Time, error) {
+	out, err := exec.CommandContext(ctx, "git", "log", "-1", "--format=%ct").Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get commit time: %w", err)
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
 	if err != nil {
-		return "", fmt.Errorf("failed to get remote URL: %w", err)
+		return time.Time{}, fmt.Errorf("parse commit time: %w", err)
 	}
+	return time.Unix(sec, 0).UTC(), nil
+}
 
-	repoURL := strings.TrimSpace(string(remoteOut))
-	repoURL = strings.TrimSuffix(repoURL, ".git")
-	if strings.HasPrefix(repoURL, "git@") {
+// RemoteURL returns the URL of the origin remote, or of the first
+// configured remote when there is no origin.
+func RemoteURL(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "config", "--get", "remote.origin.url").Output()
+	if err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	out, err = exec.CommandContext(ctx, "git", "remote").Output()
+	if err != nil {
+		return "", fmt.Errorf("list remotes: %w", err)
+	}
+	name, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if name == "" {
+		return "", fmt.Errorf("no git remote configured")
+	}
+	out, err = exec.CommandContext(ctx, "git", "remote", "get-url", name).Output()
+	if err != nil {
+		return "", fmt.Errorf("get URL of remote %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// webURL converts a remote URL to the https URL of the repository.
+func webURL(remote string) string {
+	repoURL := strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+	switch {
+	case strings.HasPrefix(repoURL, "git@"):
 		repoURL = strings.Replace(repoURL, ":", "/", 1)
 		repoURL = strings.Replace(repoURL, "git@", "https://", 1)
+	case strings.HasPrefix(repoURL, "ssh://"):
+		repoURL = "https://" + strings.TrimPrefix(strings.TrimPrefix(repoURL, "ssh://"), "git@")
 	}
+	return repoURL
+}
+
+// compareRef escapes tag for a compare URL. Slashes separate path
+// segments on forges, but "+" (as in v1.0.0+build) must be escaped.
+func compareRef(tag string) string {
+	segments := strings.Split(tag, "/")
+	for i, s := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(s), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
 
+// GetChangelog returns a markdown formatted changelog between two tags,
+// grouped by opts.GroupBy. The compare link uses the origin remote, any
+// other remote or opts.RepoURL, in that order, and is left out when none
+// of them is available.
+func GetChangelog(ctx context.Context, from, to string, opts ChangelogOptions) (string, error) {
 	if from == defaultVersion || from == "" {
 		return "", nil
 	}
 
 	cmd := exec.CommandContext(ctx, "git", "log",
-		"--pretty=format:* %s by @%an in %h",
+		"--pretty=format:%s%x1f%an%x1f%h",
 		fmt.Sprintf("%s..%s", from, to))
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get git log: %w", err)
 	}
+	var commits []commit
+	for line := range strings.Lines(string(out)) {
+		fields := strings.SplitN(strings.TrimSuffix(line, "\n"), "\x1f", 3)
+		if len(fields) == 3 {
+			commits = append(commits, commit{subject: fields[0], author: fields[1], hash: fields[2]})
+		}
+	}
+
+	repoURL := opts.RepoURL
+	if remote, err := RemoteURL(ctx); err == nil {
+		repoURL = remote
+	} else if repoURL == "" {
+		log.Printf("Warning: changelog without compare link: %v", err)
+	}
+	repoURL = webURL(repoURL)
 
 	var sb strings.Builder
 	sb.WriteString("## What's Changed\n\n")
-	sb.WriteString(string(out) + "\n")
-	sb.WriteString("\n")
-	fmt.Fprintf(&sb, "**Full Changelog**: %s/compare/%s...%s\n", repoURL, from, to)
+	sb.WriteString(renderChangelog(commits, opts))
+	if repoURL != "" {
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "**Full Changelog**: %s/compare/%s...%s\n", repoURL, compareRef(from), compareRef(to))
+	}
 
 	return sb.String(), nil
 }
+
+// ShowFile returns the content of path at rev, e.g. go.mod at a previous
+// tag. path is relative to dir, which is also where git runs.
+func ShowFile(ctx context.Context, dir, rev, path string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "show", rev+":./"+path)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s:%s: %w", rev, path, err)
+	}
+	return out, nil
+}
+
+// Pristine reports whether path, relative to dir, holds tracked files and
+// neither it nor any of others has local changes or untracked files, so
+// their mtimes are those of the checkout rather than of a build.
+func Pristine(ctx context.Context, dir, path string, others ...string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "--", path)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("git ls-files %s: %w", path, err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return false, nil
+	}
+
+	args := append([]string{"status", "--porcelain", "--untracked-files=all", "--", path}, others...)
+	cmd = exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err = cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("git status %s: %w", path, err)
+	}
+	return len(bytes.TrimSpace(out)) == 0, nil
+}
+
+// SourceDigest identifies the source files of the work tree of dir: the
+// hash of the HEAD tree or, with local changes, the hex SHA-256 of it, the
+// diff against HEAD and the untracked files that are not ignored. Paths
+// of exclude, e.g. the output directory, are left out.
+func SourceDigest(ctx context.Context, dir string, exclude ...string) (string, error) {
+	git := func(args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return out, nil
+	}
+	tree, err := git("rev-parse", "HEAD^{tree}")
+	if err != nil {
+		return "", err
+	}
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	pathspecs := []string{":/"}
+	for _, path := range exclude {
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsAbs(path) {
+			path = rel
+		}
+		pathspecs = append(pathspecs, ":(exclude)"+filepath.ToSlash(path))
+	}
+	diff, err := git(append([]string{"diff", "--binary", "HEAD", "--"}, pathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	untracked, err := git(append([]string{"ls-files", "--others", "--exclude-standard", "--full-name", "-z", "--"}, pathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	if len(diff) == 0 && len(untracked) == 0 {
+		return strings.TrimSpace(string(tree)), nil
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00", bytes.TrimSpace(tree), len(diff))
+	h.Write(diff)
+	for name := range strings.SplitSeq(strings.TrimSuffix(string(untracked), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		f, err := os.Open(filepath.Join(strings.TrimSpace(string(top)), filepath.FromSlash(name)))
+		if err != nil {
+			return "", fmt.Errorf("read untracked file: %w", err)
+		}
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, f)
+		_ = f.Close() // read-only, safe to ignore
+		if err != nil {
+			return "", fmt.Errorf("read untracked file %s: %w", name, err)
+		}
+		fmt.Fprintf(h, "%s\x00%x\x00", name, fileHash.Sum(nil))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -2,6 +2,9 @@ package git
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -26,6 +29,27 @@ func TestGetCommitHash(t *testing.T) {
 	}
 }
 
+func TestSetVersion(t *testing.T) {
+	ctx := context.Background()
+	SetVersion("v2.0.0-rc.1")
+	SetCommit("abc1234")
+	t.Cleanup(func() {
+		SetVersion("")
+		SetCommit("")
+	})
+	if got := GetTag(ctx); got != "v2.0.0-rc.1" {
+		t.Errorf("GetTag() = %q, want v2.0.0-rc.1", got)
+	}
+	if got := GetCommitHash(ctx); got != "abc1234" {
+		t.Errorf("GetCommitHash() = %q, want abc1234", got)
+	}
+
+	SetCommit("")
+	if got := GetCommitHash(ctx); got == "abc1234" || got == "" {
+		t.Errorf("GetCommitHash() = %q after SetCommit(\"\"), want the HEAD commit", got)
+	}
+}
+
 func TestGetPreviousTag(t *testing.T) {
 	ctx := context.Background()
 	tag := GetPreviousTag(ctx)
@@ -41,3 +65,126 @@ func TestGetPreviousStableTag(t *testing.T) {
 		t.Error("GetPreviousStableTag returned empty string")
 	}
 }
+
+func TestGetChangelog(t *testing.T) {
+	tests := []struct {
+		name    string
+		remotes [][2]string
+		repoURL string
+		want    string
+	}{
+		{
+			name:    "origin",
+			remotes: [][2]string{{"upstream", "https://example.com/other/app.git"}, {"origin", "git@github.com:acme/app.git"}},
+			repoURL: "https://example.com/ignored",
+			want:    "**Full Changelog**: https://github.com/acme/app/compare/v1.0.0...v1.1.0%2Bbuild.1\n",
+		},
+		{
+			name:    "other remote",
+			remotes: [][2]string{{"upstream", "ssh://git@gitlab.com/acme/app.git"}},
+			want:    "**Full Changelog**: https://gitlab.com/acme/app/compare/v1.0.0...v1.1.0%2Bbuild.1\n",
+		},
+		{
+			name:    "repo_url",
+			repoURL: "https://github.com/acme/app",
+			want:    "**Full Changelog**: https://github.com/acme/app/compare/v1.0.0...v1.1.0%2Bbuild.1\n",
+		},
+		{
+			name: "no remote",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := initRepo(t)
+			for _, r := range tt.remotes {
+				runGit(t, "remote", "add", r[0], r[1])
+			}
+			commitFile(t, dir, "main.go")
+			runGit(t, "tag", "v1.0.0")
+			commitFile(t, dir, "main.go")
+			runGit(t, "tag", "v1.1.0+build.1")
+
+			got, err := GetChangelog(context.Background(), "v1.0.0", "v1.1.0+build.1", ChangelogOptions{RepoURL: tt.repoURL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, "* change main.go by @test") || !strings.HasSuffix(got, tt.want) {
+				t.Errorf("changelog = %q, want suffix %q", got, tt.want)
+			}
+			if tt.repoURL == "" && len(tt.remotes) == 0 && strings.Contains(got, "Full Changelog") {
+				t.Errorf("changelog has a compare link without any repository URL: %q", got)
+			}
+		})
+	}
+}
+
+func TestPristine(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+	commitFile(t, dir, "ui/dist/index.html")
+	commitFile(t, dir, "web/src/app.ts")
+
+	pristine := func() bool {
+		t.Helper()
+		ok, err := Pristine(ctx, dir, "ui/dist", "web/src")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !pristine() {
+		t.Error("Pristine() of a clean checkout = false")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web/src/new.ts"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if pristine() {
+		t.Error("Pristine() with an untracked source = true")
+	}
+	if ok, err := Pristine(ctx, dir, "ui/missing"); err != nil || ok {
+		t.Errorf("Pristine() of an untracked path = %v, %v", ok, err)
+	}
+}
+
+func TestSourceDigest(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+	commitFile(t, dir, "main.go")
+
+	digest := func() string {
+		t.Helper()
+		d, err := SourceDigest(ctx, dir, filepath.Join(dir, "dist"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	clean := digest()
+	if err := os.MkdirAll(filepath.Join(dir, "dist"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dist", "app"), []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := digest(); got != clean {
+		t.Errorf("SourceDigest() changed by an excluded file: %s, want %s", got, clean)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	untracked := digest()
+	if untracked == clean {
+		t.Error("SourceDigest() ignores untracked files")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := digest(); got == untracked {
+		t.Error("SourceDigest() ignores changes of tracked files")
+	}
+	commitFile(t, dir, "new.go")
+	if got := digest(); got == clean {
+		t.Error("SourceDigest() of a new commit matches the previous one")
+	}
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Info describes the checkout a command releases from.
+type Info struct {
+	// Tag and Commit come from GetTag and GetCommitHash, which the build,
+	// publish and deploy stages use, so they match the produced artifacts.
+	Tag    string
+	Commit string
+	// Branch is empty for a detached HEAD.
+	Branch string
+	// Dirty is set when tracked or untracked files have changes.
+	Dirty bool
+}
+
+// Resolve returns the Info of the working directory's checkout.
+func Resolve(ctx context.Context) Info {
+	info := Info{Tag: GetTag(ctx), Commit: GetCommitHash(ctx)}
+	if out, err := exec.CommandContext(ctx, "git", "symbolic-ref", "--short", "-q", "HEAD").Output(); err == nil {
+		info.Branch = strings.TrimSpace(string(out))
+	}
+	if out, err := exec.CommandContext(ctx, "git", "status", "--porcelain").Output(); err == nil {
+		info.Dirty = len(strings.TrimSpace(string(out))) > 0
+	}
+	return info
+}
+
+// LatestRemoteTag returns the highest version tag of the origin remote (or
+// the first remote), as sorted by git's version sort. It is empty when the
+// remote has no tags.
+func LatestRemoteTag(ctx context.Context) (string, error) {
+	remote, err := RemoteURL(ctx)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.CommandContext(ctx, "git", "ls-remote", "--tags", "--refs", "--sort=-v:refname", remote).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("list tags of %s: %w", remote, ctx.Err())
+		}
+		return "", fmt.Errorf("list tags of %s: %w", remote, err)
+	}
+	for line := range strings.Lines(string(out)) {
+		if _, ref, ok := strings.Cut(strings.TrimSpace(line), "\trefs/tags/"); ok {
+			return ref, nil
+		}
+	}
+	return "", nil
+}
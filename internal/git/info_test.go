@@ -0,0 +1,51 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+	runGit(t, "checkout", "-q", "-b", "main")
+	commitFile(t, dir, "a.txt")
+	runGit(t, "tag", "v1.2.0")
+
+	info := Resolve(ctx)
+	if info.Tag != "v1.2.0" || info.Commit != GetCommitHash(ctx) || info.Branch != "main" || info.Dirty {
+		t.Errorf("Resolve() = %+v", info)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, "checkout", "-q", "--detach")
+	if info := Resolve(ctx); info.Branch != "" || !info.Dirty {
+		t.Errorf("Resolve() on a dirty detached HEAD = %+v", info)
+	}
+}
+
+func TestLatestRemoteTag(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+	remote := t.TempDir()
+	runGit(t, "init", "-q", "--bare", remote)
+	runGit(t, "remote", "add", "origin", remote)
+
+	commitFile(t, dir, "a.txt")
+	runGit(t, "push", "-q", "origin", "HEAD:refs/heads/main")
+	if tag, err := LatestRemoteTag(ctx); err != nil || tag != "" {
+		t.Errorf("LatestRemoteTag() without tags = %q, %v", tag, err)
+	}
+
+	for _, tag := range []string{"v1.9.0", "v1.10.0", "v1.2.0"} {
+		runGit(t, "tag", tag)
+	}
+	runGit(t, "push", "-q", "origin", "--tags")
+	if tag, err := LatestRemoteTag(ctx); err != nil || tag != "v1.10.0" {
+		t.Errorf("LatestRemoteTag() = %q, %v; want v1.10.0", tag, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Package gitauth gives hook and go build subprocesses access to private
+// modules. Credentials are written to a temporary directory and passed
+// through the environment of those subprocesses only; gcx itself, dist and
+// the logs never see the token.
+package gitauth
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+)
+
+// Env is the environment of subprocesses that fetch private modules.
+type Env struct {
+	dir  string
+	vars []string
+}
+
+// Setup materializes cfg as a .netrc and a git credential store in a new
+// temporary directory and returns the variables pointing at them, plus
+// GOPRIVATE when goprivate is set. Both may be empty, which returns nil.
+// The caller must Close the result.
+func Setup(cfg *config.GitAuthConfig, goprivate []string) (*Env, error) {
+	if cfg == nil && len(goprivate) == 0 {
+		return nil, nil
+	}
+	e := &Env{}
+	if len(goprivate) > 0 {
+		e.vars = append(e.vars, "GOPRIVATE="+strings.Join(goprivate, ","))
+	}
+	if cfg == nil {
+		return e, nil
+	}
+
+	token := envvars.Named(cfg.TokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("git_auth: environment variable %s is not set", cfg.TokenEnv)
+	}
+	dir, err := os.MkdirTemp("", "gcx-gitauth-*")
+	if err != nil {
+		return nil, fmt.Errorf("git_auth: %w", err)
+	}
+	e.dir = dir
+
+	var netrc, store strings.Builder
+	for _, host := range cfg.Hosts {
+		fmt.Fprintf(&netrc, "machine %s login %s password %s\n", host, cfg.User(), token)
+		u := url.URL{Scheme: "https", User: url.UserPassword(cfg.User(), token), Host: host}
+		store.WriteString(u.String() + "\n")
+	}
+	netrcPath := filepath.Join(dir, ".netrc")
+	storePath := filepath.Join(dir, "git-credentials")
+	for path, data := range map[string]string{netrcPath: netrc.String(), storePath: store.String()} {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			_ = e.Close()
+			return nil, fmt.Errorf("git_auth: %w", err)
+		}
+	}
+
+	// GIT_CONFIG_COUNT entries add to, rather than replace, those already
+	// in the environment.
+	base, _ := strconv.Atoi(envvars.Get("GIT_CONFIG_COUNT"))
+	for i, host := range cfg.Hosts {
+		n := strconv.Itoa(base + i)
+		e.vars = append(e.vars,
+			"GIT_CONFIG_KEY_"+n+"=credential.https://"+host+".helper",
+			"GIT_CONFIG_VALUE_"+n+"=store --file="+storePath,
+		)
+	}
+	e.vars = append(e.vars,
+		"GIT_CONFIG_COUNT="+strconv.Itoa(base+len(cfg.Hosts)),
+		"NETRC="+netrcPath,
+		"GIT_TERMINAL_PROMPT=0",
+	)
+	return e, nil
+}
+
+// Vars returns the variables in "KEY=value" form. A nil Env has none.
+func (e *Env) Vars() []string {
+	if e == nil {
+		return nil
+	}
+	return e.vars
+}
+
+// Dir returns the temporary directory of the credential files, which
+// differs on every run; it is empty without git_auth.
+func (e *Env) Dir() string {
+	if e == nil {
+		return ""
+	}
+	return e.dir
+}
+
+// Close removes the credential files. It is safe on a nil Env.
+func (e *Env) Close() error {
+	if e == nil || e.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(e.dir)
+	e.dir = ""
+	return err
+}
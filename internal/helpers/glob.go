@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether the slash-separated name matches pattern.
+// Patterns use path.Match syntax per segment, plus "**" matching any number
+// of segments. A trailing slash matches everything below a directory, so
+// "services/api/" is the same as "services/api/**".
+func MatchGlob(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// MatchAnyGlob reports whether name matches at least one of patterns.
+func MatchAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
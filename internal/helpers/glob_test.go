@@ -0,0 +1,39 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/helpers"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"services/api/**", "services/api/main.go", true},
+		{"services/api/**", "services/api/internal/x/y.go", true},
+		{"services/api/**", "services/apiv2/main.go", false},
+		{"services/api/", "services/api/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"**/*.go", "a/b/c.md", false},
+		{"pkg/*/go.mod", "pkg/shared/go.mod", true},
+		{"pkg/*/go.mod", "pkg/shared/sub/go.mod", false},
+		{"go.mod", "go.mod", true},
+		{"go.mod", "sub/go.mod", false},
+		{"services/**/Dockerfile", "services/Dockerfile", true},
+		{"services/**/Dockerfile", "services/a/b/Dockerfile", true},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		if got := helpers.MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+
+	if !helpers.MatchAnyGlob([]string{"docs/**", "go.sum"}, "go.sum") {
+		t.Error("MatchAnyGlob should match the second pattern")
+	}
+}
@@ -0,0 +1,22 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/helpers"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                "0 B",
+		1023:             "1023 B",
+		1536:             "1.5 KiB",
+		10 * 1024 * 1024: "10.0 MiB",
+		3 << 30:          "3.0 GiB",
+	}
+	for n, want := range tests {
+		if got := helpers.FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
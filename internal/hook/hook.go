@@ -3,6 +3,7 @@ package hook
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -11,14 +12,36 @@ import (
 // Run executes shell hooks sequentially using "sh -c" for proper shell semantics.
 // It supports quoted arguments, pipes, redirections, and other shell features.
 func Run(ctx context.Context, hooks []string) error {
+	return RunIn(ctx, "", hooks)
+}
+
+// RunIn is like Run but executes the hooks in dir. An empty dir means the
+// working directory.
+func RunIn(ctx context.Context, dir string, hooks []string) error {
+	return RunOutput(ctx, dir, os.Stdout, hooks)
+}
+
+// RunOutput is like RunIn but writes the standard output of the hooks to
+// stdout, e.g. os.Stderr when stdout carries data.
+func RunOutput(ctx context.Context, dir string, stdout io.Writer, hooks []string) error {
+	return RunEnv(ctx, dir, stdout, nil, hooks)
+}
+
+// RunEnv is like RunOutput but adds env, in "KEY=value" form, to the
+// environment of the hooks.
+func RunEnv(ctx context.Context, dir string, stdout io.Writer, env, hooks []string) error {
 	for _, h := range hooks {
 		if h == "" {
 			continue
 		}
 		log.Printf("Executing hook: %s", h)
 		cmd := exec.CommandContext(ctx, "sh", "-c", h)
-		cmd.Stdout = os.Stdout
+		cmd.Dir = dir
+		cmd.Stdout = stdout
 		cmd.Stderr = os.Stderr
+		if len(env) > 0 {
+			cmd.Env = append(os.Environ(), env...)
+		}
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("hook %q failed: %w", h, err)
 		}
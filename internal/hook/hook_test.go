@@ -2,6 +2,7 @@ package hook
 
 import (
 	"context"
+	"strings"
 	"testing"
 )
 
@@ -61,3 +62,14 @@ func TestRun(t *testing.T) {
 		}
 	})
 }
+
+func TestRunEnv(t *testing.T) {
+	var out strings.Builder
+	err := RunEnv(context.Background(), "", &out, []string{"GCX_HOOK_TEST=hello"}, []string{`echo "$GCX_HOOK_TEST"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "hello" {
+		t.Errorf("output = %q, want hello", got)
+	}
+}
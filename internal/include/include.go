@@ -0,0 +1,112 @@
+// Package include fetches centrally maintained deploy command lists. Each
+// list is pinned by the SHA-256 of the fetched file and cached by that hash,
+// so once fetched it is used offline.
+package include
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CacheDir is the include cache relative to the config directory.
+const CacheDir = ".gcx/cache/includes"
+
+// maxSize bounds a fetched command list.
+const maxSize = 1 << 20
+
+// client fetches command lists.
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Commands returns the commands listed by the YAML file at url, whose
+// SHA-256 must be sum. The file is read from cacheDir when present there
+// and downloaded and cached otherwise.
+func Commands(ctx context.Context, url, sum, cacheDir string) ([]string, error) {
+	cachePath := filepath.Join(cacheDir, sum+".yaml")
+	data, err := os.ReadFile(cachePath)
+	if err == nil && digest(data) != sum {
+		// A corrupted cache entry is fetched again
+		err = errors.New("cached file does not match its hash")
+	}
+	if err != nil {
+		if data, err = fetch(ctx, url); err != nil {
+			return nil, fmt.Errorf("fetch %s: %w", url, err)
+		}
+		if got := digest(data); got != sum {
+			return nil, fmt.Errorf("sha256 mismatch for %s: got %s, want %s", url, got, sum)
+		}
+		if err := writeCache(cachePath, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var cmds []string
+	if err := yaml.Unmarshal(data, &cmds); err != nil {
+		return nil, fmt.Errorf("parse %s: want a YAML list of commands: %w", url, err)
+	}
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("%s lists no commands", url)
+	}
+	return cmds, nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// writeCache stores data at path through a temporary file, so concurrent
+// runs never read a partial entry.
+func writeCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create include cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".include-*")
+	if err != nil {
+		return fmt.Errorf("write include cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write include cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write include cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write include cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package include
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const list = "- systemctl restart api\n- curl -fsS localhost:8080/healthz\n"
+
+func TestCommands(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(list))
+	}))
+	cacheDir := t.TempDir()
+	want := []string{"systemctl restart api", "curl -fsS localhost:8080/healthz"}
+
+	cmds, err := Commands(t.Context(), srv.URL+"/api.yaml", digest([]byte(list)), cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cmds, want) {
+		t.Errorf("commands = %q, want %q", cmds, want)
+	}
+
+	// Cached lists are used without the server
+	srv.Close()
+	cmds, err = Commands(t.Context(), srv.URL+"/api.yaml", digest([]byte(list)), cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cmds, want) {
+		t.Errorf("cached commands = %q, want %q", cmds, want)
+	}
+}
+
+func TestCommandsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(list))
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		path    string
+		sum     string
+		wantErr string
+	}{
+		{"hash mismatch", "/api.yaml", strings.Repeat("ab", 32), "sha256 mismatch"},
+		{"not found", "/missing.yaml", digest([]byte(list)), "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Commands(t.Context(), srv.URL+tt.path, tt.sum, t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package inject implements the hidden --fail-at developer flag, which
+// makes gcx fail at a chosen point of a stage so the failure handling
+// (rollback, alerts, summaries, exit codes) can be exercised without a real
+// outage. Injected failures travel the same paths as real ones.
+package inject
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Stages lists the stages that accept an injection point.
+var Stages = []string{"build", "publish", "deploy"}
+
+// ErrInjected matches every injected failure with errors.Is.
+var ErrInjected = errors.New("injected failure")
+
+// Point is where a failure is injected: a stage and, optionally, one
+// addressable step of it. An empty Target fails the first step reached.
+type Point struct {
+	Stage  string
+	Target string
+}
+
+func (p Point) String() string {
+	if p.Target == "" {
+		return p.Stage
+	}
+	return p.Stage + ":" + p.Target
+}
+
+// Parse parses stage[:target], e.g. build:3, publish:s3-prod or
+// deploy:cmd2.
+func Parse(s string) (Point, error) {
+	stage, target, _ := strings.Cut(strings.TrimSpace(s), ":")
+	if slices.Contains(Stages, stage) {
+		return Point{Stage: stage, Target: target}, nil
+	}
+	return Point{}, fmt.Errorf("--fail-at %q: unknown stage %q, expected one of %s",
+		s, stage, strings.Join(Stages, ", "))
+}
+
+// Error is an injected failure.
+type Error struct {
+	Point Point
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("injected failure at %s (--fail-at)", e.Point)
+}
+
+func (e *Error) Is(target error) bool { return target == ErrInjected }
+
+var (
+	mu     sync.Mutex
+	active *Point
+)
+
+// Set arms p for the rest of the run; nil disarms it.
+func Set(p *Point) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = p
+}
+
+// Armed reports whether Check would fail for stage and ids, without
+// firing the point.
+func Armed(stage string, ids ...string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return active != nil && active.Stage == stage && (active.Target == "" || slices.Contains(ids, active.Target))
+}
+
+// Check returns an injected failure when the armed point is in stage and
+// its target is empty or one of ids, the identities of the current step.
+// Each armed point fires once.
+func Check(stage string, ids ...string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil || active.Stage != stage {
+		return nil
+	}
+	if active.Target != "" && !slices.Contains(ids, active.Target) {
+		return nil
+	}
+	p := *active
+	active = nil
+	log.Printf("INJECTED FAILURE at %s (--fail-at)", p)
+	return &Error{Point: p}
+}
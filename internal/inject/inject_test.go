@@ -0,0 +1,64 @@
+package inject
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Point
+		wantErr string
+	}{
+		{in: "build:3", want: Point{Stage: "build", Target: "3"}},
+		{in: "publish:s3-prod", want: Point{Stage: "publish", Target: "s3-prod"}},
+		{in: "deploy:cmd2", want: Point{Stage: "deploy", Target: "cmd2"}},
+		{in: "deploy", want: Point{Stage: "deploy"}},
+		{in: "sign:1", wantErr: `unknown stage "sign"`},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Cleanup(func() { Set(nil) })
+
+	if err := Check("build", "1"); err != nil {
+		t.Fatalf("Check() without an armed point = %v", err)
+	}
+
+	Set(&Point{Stage: "build", Target: "2"})
+	if err := Check("publish", "2"); err != nil {
+		t.Errorf("Check() in another stage = %v", err)
+	}
+	if err := Check("build", "1", "linux/amd64"); err != nil {
+		t.Errorf("Check() of another target = %v", err)
+	}
+	if Armed("build", "1") || !Armed("build", "2") || !Armed("build", "2") {
+		t.Error("Armed() does not match Check() or fired the point")
+	}
+	err := Check("build", "2", "darwin/arm64")
+	if !errors.Is(err, ErrInjected) || err.Error() != "injected failure at build:2 (--fail-at)" {
+		t.Fatalf("Check() = %v, want an injected failure", err)
+	}
+	if err := Check("build", "2"); err != nil || Armed("build", "2") {
+		t.Errorf("Check() after the point fired = %v", err)
+	}
+
+	Set(&Point{Stage: "deploy"})
+	if err := Check("deploy", "prod"); !errors.Is(err, ErrInjected) {
+		t.Errorf("Check() of a stage-wide point = %v", err)
+	}
+}
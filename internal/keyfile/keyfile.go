@@ -0,0 +1,86 @@
+// Package keyfile writes raw private keys to disk for external tools such
+// as ssh-keygen. Each key gets a private temporary directory that is
+// removed on Close or as soon as the context is canceled, e.g. by SIGINT,
+// whichever comes first. Errors name the file at most, never the key.
+package keyfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// name is the key file inside the temporary directory.
+const name = "key"
+
+// File is a raw key materialized on disk.
+type File struct {
+	dir  string
+	stop func() bool
+	once sync.Once
+	err  error
+}
+
+// Write stores raw in a 0600 file inside a new 0700 temporary directory.
+// The key ends with a newline, which ssh-keygen requires. The caller must
+// Close the result.
+func Write(ctx context.Context, raw string) (*File, error) {
+	dir, err := os.MkdirTemp("", "gcx-key-*")
+	if err != nil {
+		return nil, fmt.Errorf("create key directory: %w", err)
+	}
+	f := &File{dir: dir}
+	if !strings.HasSuffix(raw, "\n") {
+		raw += "\n"
+	}
+	if err := writeFile(filepath.Join(dir, name), raw); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	f.stop = context.AfterFunc(ctx, func() { _ = f.remove() })
+	return f, nil
+}
+
+func writeFile(path, data string) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("create key file: %w", err)
+	}
+	if _, err := out.WriteString(data); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("write key file %s: %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("write key file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Path returns the path of the key file.
+func (f *File) Path() string {
+	return filepath.Join(f.dir, name)
+}
+
+// Close removes the key and its directory. It is safe to call more than
+// once and on a nil File.
+func (f *File) Close() error {
+	if f == nil {
+		return nil
+	}
+	if f.stop != nil {
+		f.stop()
+	}
+	return f.remove()
+}
+
+func (f *File) remove() error {
+	f.once.Do(func() {
+		if err := os.RemoveAll(f.dir); err != nil {
+			f.err = fmt.Errorf("remove key directory: %w", err)
+		}
+	})
+	return f.err
+}
@@ -0,0 +1,152 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/archive"
+)
+
+// FileName is the name of the manifest file written next to artifacts.
+const FileName = "artifacts.json"
+
+// SBOMSuffix is appended to the artifact name to name its SBOM document.
+const SBOMSuffix = ".sbom.json"
+
+// ContentsSuffix is appended to the archive name to name the sidecar
+// listing its contents.
+const ContentsSuffix = ".contents.json"
+
+// Artifact types.
+const (
+	TypeBinary   = "binary"
+	TypeArchive  = "archive"
+	TypeChecksum = "checksum"
+	TypeSBOM     = "sbom"
+	TypeFile     = "file"
+)
+
+// Types lists the artifact types.
+var Types = []string{TypeBinary, TypeArchive, TypeChecksum, TypeSBOM, TypeFile}
+
+// Artifact describes a single file in the manifest.
+type Artifact struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Goos   string `json:"goos,omitempty"`
+	Goarch string `json:"goarch,omitempty"`
+	Goarm  string `json:"goarm,omitempty"`
+	// Variant is the GOAMD64, GOARM64, GOMIPS or GORISCV64 value.
+	Variant string `json:"variant,omitempty"`
+	// Builds are the ids of the builds whose binaries the artifact holds.
+	Builds []string `json:"builds,omitempty"`
+	// Instrumented marks coverage-instrumented binaries and their
+	// archives, built with go build -cover and not meant for the public.
+	Instrumented bool   `json:"instrumented,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	Remote       string `json:"remote,omitempty"`
+	Verified     bool   `json:"verified,omitempty"`
+	// Contents lists the entries of an archive. Long lists are written to
+	// the ContentsFile sidecar next to the archive instead.
+	Contents     []archive.Entry `json:"contents,omitempty"`
+	ContentsFile string          `json:"contents_file,omitempty"`
+}
+
+// Command is the go build process of one binary, recorded for external
+// build caches that key on how a binary was built.
+type Command struct {
+	// Binary is the path of the built binary. Paths below the config
+	// directory, here and in Args and Dir, are relative to it.
+	Binary  string `json:"binary"`
+	Build   string `json:"build,omitempty"`
+	Goos    string `json:"goos"`
+	Goarch  string `json:"goarch"`
+	Goarm   string `json:"goarm,omitempty"`
+	Variant string `json:"variant,omitempty"`
+	// Args is the argv of the process, the go binary first.
+	Args []string `json:"args"`
+	// Dir is the working directory of the process; empty is that of gcx.
+	Dir string `json:"dir,omitempty"`
+	// Env is the sorted environment of the process that affects its
+	// output, as KEY=value. Values of secrets are "sha256:" and the hex
+	// SHA-256 of the value instead.
+	Env []string `json:"env"`
+	// GoVersion is the go toolchain selected in Dir, e.g. "1.22.3".
+	GoVersion string `json:"go_version,omitempty"`
+	// Source identifies the source files: the git tree hash of HEAD, or a
+	// SHA-256 that also covers local changes.
+	Source string `json:"source,omitempty"`
+	// Fingerprint is the hex SHA-256 of Args, Dir, Env, GoVersion, Source
+	// and the files gcx generated for the build, e.g. an embedded
+	// changelog. The build date in Args and Env counts as the
+	// SOURCE_DATE_EPOCH or commit time, so identical inputs give the same
+	// fingerprint on every run and in every checkout.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Manifest is the content of artifacts.json.
+type Manifest struct {
+	Version string `json:"version,omitempty"`
+	// Channel is the release channel of the build: stable, beta or nightly.
+	Channel string `json:"channel,omitempty"`
+	Source  string `json:"source,omitempty"`
+	// GoVersion is the go toolchain that built the artifacts, e.g. "1.22.3".
+	GoVersion string `json:"go_version,omitempty"`
+	// Started is when the build began; announcements measure the release
+	// duration from it.
+	Started   time.Time  `json:"started,omitzero"`
+	Created   time.Time  `json:"created,omitzero"`
+	Artifacts []Artifact `json:"artifacts"`
+	// Commands are the go build processes of the binaries; prebuilt
+	// binaries have none.
+	Commands []Command `json:"commands,omitempty"`
+}
+
+// Write serializes the manifest to path as indented JSON.
+func Write(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// Load reads a manifest from path.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// TypeFromName guesses the artifact type from a file name.
+func TypeFromName(name string) string {
+	switch {
+	case IsChecksumFile(name):
+		return TypeChecksum
+	case strings.HasSuffix(name, SBOMSuffix):
+		return TypeSBOM
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".zip"):
+		return TypeArchive
+	default:
+		return TypeFile
+	}
+}
+
+// IsChecksumFile reports whether name looks like a checksums file.
+func IsChecksumFile(name string) bool {
+	return strings.Contains(name, "checksums") && strings.HasSuffix(name, ".txt")
+}
@@ -0,0 +1,47 @@
+package manifest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	m := &Manifest{
+		Version: "v1.4.2",
+		Source:  "s3://bucket/releases/v1.4.2",
+		Artifacts: []Artifact{
+			{Name: "app_linux_amd64.tar.gz", Path: "dist/app_linux_amd64.tar.gz", Type: TypeArchive, Size: 42, Verified: true},
+		},
+	}
+	if err := Write(path, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != m.Version || got.Source != m.Source || len(got.Artifacts) != 1 || !reflect.DeepEqual(got.Artifacts[0], m.Artifacts[0]) {
+		t.Errorf("Load() = %+v, want %+v", got, m)
+	}
+}
+
+func TestTypeFromName(t *testing.T) {
+	tests := map[string]string{
+		"checksums.txt":          TypeChecksum,
+		"app_checksums.txt":      TypeChecksum,
+		"app_linux_amd64.tar":    TypeArchive,
+		"app_linux_amd64.tar.gz": TypeArchive,
+		"app_linux_arm.tar.xz":   TypeArchive,
+		"app_linux_arm.tar.zst":  TypeArchive,
+		"app_windows_amd64.zip":  TypeArchive,
+		"README.md":              TypeFile,
+	}
+	for name, want := range tests {
+		if got := TypeFromName(name); got != want {
+			t.Errorf("TypeFromName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
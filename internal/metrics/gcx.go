@@ -0,0 +1,48 @@
+package metrics
+
+import "time"
+
+// ObserveStage records the duration and result of a pipeline stage
+// (build, publish, deploy) in the Default registry.
+func ObserveStage(stage string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	Default.Set("gcx_stage_duration_seconds", "Duration of a gcx stage.",
+		Labels{"stage": stage}, time.Since(start).Seconds())
+	Default.Add("gcx_stage_runs_total", "Number of gcx stage runs by result.",
+		Labels{"stage": stage, "result": result}, 1)
+}
+
+// ObserveBuild records the compile duration of one build target.
+func ObserveBuild(build, target string, d time.Duration) {
+	Default.Set("gcx_build_duration_seconds", "Compile duration per build target.",
+		Labels{"build": build, "target": target}, d.Seconds())
+}
+
+// ObserveArtifact records the size of a produced artifact.
+func ObserveArtifact(name, typ string, size int64) {
+	Default.Set("gcx_artifact_size_bytes", "Size of produced artifacts.",
+		Labels{"artifact": name, "type": typ}, float64(size))
+}
+
+// ObserveUpload records one uploaded file for a publish destination.
+func ObserveUpload(provider, destination string, size int64, d time.Duration) {
+	labels := Labels{"provider": provider, "destination": destination}
+	Default.Add("gcx_upload_bytes_total", "Bytes uploaded per publish destination.", labels, float64(size))
+	Default.Add("gcx_upload_duration_seconds_total", "Time spent uploading per publish destination.", labels, d.Seconds())
+	Default.Add("gcx_uploads_total", "Files uploaded per publish destination.", labels, 1)
+}
+
+// ObserveDeploy records the duration and result of a deploy to one host.
+func ObserveDeploy(deploy, host string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	Default.Set("gcx_deploy_duration_seconds", "Deploy duration per host.",
+		Labels{"deploy": deploy, "host": host}, d.Seconds())
+	Default.Add("gcx_deploys_total", "Deploys per host by result.",
+		Labels{"deploy": deploy, "host": host, "result": result}, 1)
+}
@@ -0,0 +1,231 @@
+// Package metrics is a minimal registry of counters and gauges rendered in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types.
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+)
+
+// Labels are metric label names and values.
+type Labels map[string]string
+
+// Default is the registry gcx records into during a run.
+var Default = NewRegistry()
+
+// Registry holds metric families keyed by name.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples map[string]*sample
+}
+
+type sample struct {
+	labels Labels
+	value  float64
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Add increments the counter name{labels} by v.
+func (r *Registry) Add(name, help string, labels Labels, v float64) {
+	r.update(name, help, TypeCounter, labels, func(s *sample) { s.value += v })
+}
+
+// Set sets the gauge name{labels} to v.
+func (r *Registry) Set(name, help string, labels Labels, v float64) {
+	r.update(name, help, TypeGauge, labels, func(s *sample) { s.value = v })
+}
+
+func (r *Registry) update(name, help, typ string, labels Labels, fn func(*sample)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ, samples: make(map[string]*sample)}
+		r.families[name] = f
+	}
+	key := labels.String()
+	s, ok := f.samples[key]
+	if !ok {
+		s = &sample{labels: labels}
+		f.samples[key] = s
+	}
+	fn(s)
+}
+
+// Empty reports whether nothing was recorded.
+func (r *Registry) Empty() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.families) == 0
+}
+
+// WriteText writes all metrics in the text exposition format. extra labels
+// (e.g. job and version) are added to every sample.
+func (r *Registry) WriteText(w io.Writer, extra Labels) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		f := r.families[name]
+		if f.help != "" {
+			fmt.Fprintf(&buf, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		}
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", f.name, f.typ)
+
+		keys := make([]string, 0, len(f.samples))
+		for key := range f.samples {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.samples[key]
+			fmt.Fprintf(&buf, "%s%s %s\n", f.name, merge(extra, s.labels).String(), formatValue(s.value))
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFile writes the metrics to path, e.g. for the node_exporter textfile collector.
+func (r *Registry) WriteFile(path string, extra Labels) error {
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf, extra); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("write metrics file: %w", err)
+	}
+	return nil
+}
+
+// Push replaces the metrics group job/<job>[/<label>/<value>...] on a
+// Prometheus Pushgateway at baseURL.
+func (r *Registry) Push(ctx context.Context, baseURL, job string, grouping Labels) error {
+	var body bytes.Buffer
+	if err := r.WriteText(&body, nil); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, pushURL(baseURL, job, grouping), &body)
+	if err != nil {
+		return fmt.Errorf("create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push metrics: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push metrics: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func pushURL(baseURL, job string, grouping Labels) string {
+	u := strings.TrimSuffix(baseURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	for _, name := range grouping.names() {
+		if v := grouping[name]; v != "" {
+			u += "/" + url.PathEscape(name) + "/" + url.PathEscape(v)
+		}
+	}
+	return u
+}
+
+func (l Labels) names() []string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String renders labels as {a="1",b="2"} with sorted names, or "" when empty.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range l.names() {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(l[name]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// merge returns the union of a and b; b wins on conflicts.
+func merge(a, b Labels) Labels {
+	if len(a) == 0 {
+		return b
+	}
+	out := make(Labels, len(a)+len(b))
+	for k, v := range a {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
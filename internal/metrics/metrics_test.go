@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Add("gcx_uploads_total", "Files uploaded.", Labels{"provider": "s3", "destination": "prod"}, 1)
+	r.Add("gcx_uploads_total", "Files uploaded.", Labels{"provider": "s3", "destination": "prod"}, 2)
+	r.Add("gcx_uploads_total", "Files uploaded.", Labels{"provider": "ssh", "destination": "mirror"}, 1)
+	r.Set("gcx_build_duration_seconds", "Compile duration.", Labels{"target": "linux/amd64"}, 1.5)
+	r.Set("gcx_build_duration_seconds", "Compile duration.", Labels{"target": "linux/amd64"}, 2.25)
+	r.Set("gcx_weird", "Line one\nline two", Labels{"path": `C:\dist "x"`}, 0)
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb, Labels{"job": "gcx", "version": "v1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP gcx_build_duration_seconds Compile duration.
+# TYPE gcx_build_duration_seconds gauge
+gcx_build_duration_seconds{job="gcx",target="linux/amd64",version="v1.0.0"} 2.25
+# HELP gcx_uploads_total Files uploaded.
+# TYPE gcx_uploads_total counter
+gcx_uploads_total{destination="mirror",job="gcx",provider="ssh",version="v1.0.0"} 1
+gcx_uploads_total{destination="prod",job="gcx",provider="s3",version="v1.0.0"} 3
+# HELP gcx_weird Line one\nline two
+# TYPE gcx_weird gauge
+gcx_weird{job="gcx",path="C:\\dist \"x\"",version="v1.0.0"} 0
+`
+	if sb.String() != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
+
+func TestWriteTextWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	r.Add("gcx_runs_total", "", nil, 1)
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "# TYPE gcx_runs_total counter\ngcx_runs_total 1\n"; sb.String() != want {
+		t.Errorf("WriteText() = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestPush(t *testing.T) {
+	var gotMethod, gotPath, gotBody, gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotMethod, gotPath, gotType = req.Method, req.URL.EscapedPath(), req.Header.Get("Content-Type")
+		body, _ := io.ReadAll(req.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r := NewRegistry()
+	r.Add("gcx_deploys_total", "Deploys.", Labels{"result": "success"}, 1)
+	if err := r.Push(context.Background(), srv.URL+"/", "gcx", Labels{"version": "v1.0.0+build/1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotMethod != http.MethodPut {
+		t.Errorf("method = %s, want PUT", gotMethod)
+	}
+	if want := "/metrics/job/gcx/version/v1.0.0+build%2F1"; gotPath != want {
+		t.Errorf("path = %s, want %s", gotPath, want)
+	}
+	if !strings.HasPrefix(gotType, "text/plain") {
+		t.Errorf("content type = %q", gotType)
+	}
+	if !strings.Contains(gotBody, `gcx_deploys_total{result="success"} 1`) {
+		t.Errorf("unexpected body: %q", gotBody)
+	}
+}
+
+func TestPushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	r := NewRegistry()
+	r.Add("x", "", nil, 1)
+	err := r.Push(context.Background(), srv.URL, "gcx", nil)
+	if err == nil || !strings.Contains(err.Error(), "bad metrics") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
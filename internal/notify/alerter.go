@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/schedule"
+)
+
+// StateDir is the local gcx state directory relative to the config directory.
+const StateDir = ".gcx/state"
+
+// dedupeFile records when each alert was last sent, inside StateDir.
+const dedupeFile = "alerts.json"
+
+// now and send are replaced in tests.
+var (
+	now  = time.Now
+	send = Send
+)
+
+// Route returns the URLs the alert data, with status "Success", "Failed" or
+// "Not approved", sent at t goes to. The first enabled schedule rule matching t and status
+// decides; a dropping rule yields no URLs. Without a match the alert goes to
+// cfg.URLs.
+func Route(cfg config.AlertConfig, data AlertData, t time.Time) ([]string, error) {
+	loc, err := schedule.LoadLocation(cfg.Location)
+	if err != nil {
+		return nil, err
+	}
+	rel := config.ReleaseData{Version: data.Version, Channel: data.Channel}
+	for i, rule := range cfg.Schedule {
+		if len(rule.Status) > 0 && !slices.Contains(rule.Status, statusKey(data.Status)) {
+			continue
+		}
+		enabled, err := rule.IsEnabled(rel)
+		if err != nil {
+			return nil, fmt.Errorf("schedule[%d]: %w", i, err)
+		}
+		if !enabled {
+			continue
+		}
+		window, err := schedule.Parse(rule.Days, rule.Hours)
+		if err != nil {
+			return nil, fmt.Errorf("schedule[%d]: %w", i, err)
+		}
+		if !window.Contains(t, loc) {
+			continue
+		}
+		if rule.Drop {
+			return nil, nil
+		}
+		return rule.URLs, nil
+	}
+	return cfg.URLs, nil
+}
+
+// Alerter sends alerts routed by the schedule and suppresses duplicates
+// within the dedupe window.
+type Alerter struct {
+	cfg       config.AlertConfig
+	statePath string
+}
+
+// NewAlerter creates an Alerter that keeps its dedupe state in stateDir.
+func NewAlerter(cfg config.AlertConfig, stateDir string) *Alerter {
+	return &Alerter{cfg: cfg, statePath: filepath.Join(stateDir, dedupeFile)}
+}
+
+// Send routes and sends data unless the alerts are disabled for its channel
+// or an identical alert was sent within the dedupe window.
+func (a *Alerter) Send(data AlertData) error {
+	enabled, err := a.cfg.IsEnabled(config.ReleaseData{Version: data.Version, Channel: data.Channel})
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		log.Printf("Alert for %s %s (%s) disabled on channel %s", data.AppName, data.Version, data.Status, data.Channel)
+		return nil
+	}
+
+	t := now()
+	urls, err := Route(a.cfg, data, t)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		if len(a.cfg.Schedule) > 0 {
+			log.Printf("Alert for %s %s (%s) dropped by schedule", data.AppName, data.Version, data.Status)
+		}
+		return nil
+	}
+
+	window := a.cfg.DedupeWindow.Std()
+	if window <= 0 {
+		return send(urls, data)
+	}
+
+	key := data.AppName + "|" + data.Version + "|" + data.Status
+	sent := a.loadState()
+	if last, ok := sent[key]; ok && t.Sub(last) < window {
+		log.Printf("Alert for %s %s (%s) suppressed: already sent at %s", data.AppName, data.Version, data.Status, last.Format(time.RFC3339))
+		return nil
+	}
+	if err := send(urls, data); err != nil {
+		return err
+	}
+
+	sent[key] = t
+	for k, last := range sent {
+		if t.Sub(last) >= window {
+			delete(sent, k)
+		}
+	}
+	if err := a.saveState(sent); err != nil {
+		log.Printf("Warning: failed to record sent alert: %v", err)
+	}
+	return nil
+}
+
+// loadState reads the dedupe state. A missing or unreadable file means no
+// alert was sent yet, so alerts are never lost to a corrupt state file.
+func (a *Alerter) loadState() map[string]time.Time {
+	sent := make(map[string]time.Time)
+	data, err := os.ReadFile(a.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return sent
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &sent)
+	}
+	if err != nil {
+		log.Printf("Warning: ignoring alert state %s: %v", a.statePath, err)
+		return make(map[string]time.Time)
+	}
+	return sent
+}
+
+func (a *Alerter) saveState(sent map[string]time.Time) error {
+	data, err := json.MarshalIndent(sent, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal alert state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(a.statePath), 0o755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	if err := os.WriteFile(a.statePath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write alert state: %w", err)
+	}
+	return nil
+}
+
+// statusKey returns the schedule status of an alert status, e.g.
+// not_approved for "Not approved".
+func statusKey(status string) string {
+	return strings.ReplaceAll(strings.ToLower(status), " ", "_")
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/configtypes"
+)
+
+// pagingConfig sends business-hour alerts to chat, pages on failures at
+// any other time and drops the remaining successes.
+var pagingConfig = config.AlertConfig{
+	URLs:     []string{"generic://default"},
+	Location: "America/New_York",
+	Schedule: []config.AlertRule{
+		{Days: []string{"mon-fri"}, Hours: "09:00-17:00", URLs: []string{"generic://chat"}},
+		{Status: []string{"failed"}, URLs: []string{"generic://pager"}},
+		{Status: []string{"success"}, Drop: true},
+		{Status: []string{"not_approved"}, URLs: []string{"generic://cab"}},
+	},
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		utc    string
+		want   []string
+	}{
+		// 2024-06-03 is a Monday; New York is UTC-4 in summer
+		{"business hours", "Failed", "2024-06-03T14:00:00Z", []string{"generic://chat"}},
+		{"failure at night", "Failed", "2024-06-04T02:00:00Z", []string{"generic://pager"}},
+		{"success at night", "Success", "2024-06-04T02:00:00Z", nil},
+		{"not approved at night", "Not approved", "2024-06-04T02:00:00Z", []string{"generic://cab"}},
+		// Monday 03:00 UTC is Sunday 23:00 in New York
+		{"sunday in local time", "Failed", "2024-06-03T03:00:00Z", []string{"generic://pager"}},
+		{"friday evening in local time", "Failed", "2024-06-08T00:30:00Z", []string{"generic://pager"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.utc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Route(pagingConfig, AlertData{Status: tt.status}, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Route(%s, %s) = %v, want %v", tt.status, tt.utc, got, tt.want)
+			}
+		})
+	}
+
+	got, err := Route(config.AlertConfig{URLs: []string{"generic://default"}}, AlertData{Status: "Failed"}, time.Now())
+	if err != nil || !slices.Equal(got, []string{"generic://default"}) {
+		t.Errorf("Route without schedule = %v, %v", got, err)
+	}
+}
+
+func TestRouteChannel(t *testing.T) {
+	cfg := config.AlertConfig{
+		URLs: []string{"generic://releases"},
+		Schedule: []config.AlertRule{
+			{Enabled: `{{eq .Channel "nightly"}}`, URLs: []string{"generic://nightly"}},
+		},
+	}
+	for channel, want := range map[string]string{"nightly": "generic://nightly", "stable": "generic://releases"} {
+		got, err := Route(cfg, AlertData{Channel: channel, Status: "Failed"}, time.Now())
+		if err != nil || !slices.Equal(got, []string{want}) {
+			t.Errorf("Route(%s) = %v, %v; want %s", channel, got, err, want)
+		}
+	}
+}
+
+func TestAlerterDisabled(t *testing.T) {
+	var sent int
+	send = func([]string, AlertData) error {
+		sent++
+		return nil
+	}
+	defer func() { send = Send }()
+
+	cfg := config.AlertConfig{URLs: []string{"generic://chat"}, Enabled: `{{ne .Channel "nightly"}}`}
+	for _, channel := range []string{"nightly", "beta"} {
+		data := AlertData{AppName: "prod", Version: "v1.0.0", Channel: channel, Status: "Failed"}
+		if err := NewAlerter(cfg, t.TempDir()).Send(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent != 1 {
+		t.Errorf("sent %d alerts, want only the beta one", sent)
+	}
+}
+
+func TestAlerterDedupe(t *testing.T) {
+	var sent []AlertData
+	send = func(_ []string, data AlertData) error {
+		sent = append(sent, data)
+		return nil
+	}
+	clock := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { send, now = Send, time.Now }()
+
+	cfg := config.AlertConfig{URLs: []string{"generic://chat"}, DedupeWindow: configtypes.Duration(10 * time.Minute)}
+	stateDir := t.TempDir()
+	failed := AlertData{AppName: "prod", Version: "v1.0.0", Status: "Failed", Error: "boom"}
+
+	for range 3 {
+		if err := NewAlerter(cfg, stateDir).Send(failed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent %d alerts within the window, want 1", len(sent))
+	}
+
+	// A different status is not a duplicate
+	if err := NewAlerter(cfg, stateDir).Send(AlertData{AppName: "prod", Version: "v1.0.0", Status: "Success"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent %d alerts, want 2 after a status change", len(sent))
+	}
+
+	clock = clock.Add(11 * time.Minute)
+	if err := NewAlerter(cfg, stateDir).Send(failed); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 3 {
+		t.Errorf("sent %d alerts, want 3 once the window passed", len(sent))
+	}
+}
@@ -12,15 +12,25 @@ import (
 type AlertData struct {
 	AppName string
 	Version string
+	Channel string
 	Status  string
 	Error   string
+	// PolicyOverride is the reason given for deploying despite
+	// deploy_policy violations.
+	PolicyOverride string
+	// ApprovalOverride marks deploys whose approval gate was skipped
+	// with --yes.
+	ApprovalOverride bool
 }
 
 const alertTemplate = `Deployment Status Update
 Application: {{.AppName}}
 Version: {{.Version}}
-Status: {{.Status}}
-{{if .Error}}Error: {{.Error}}{{end}}`
+{{if .Channel}}Channel: {{.Channel}}
+{{end}}Status: {{.Status}}
+{{if .PolicyOverride}}Policy override: {{.PolicyOverride}}
+{{end}}{{if .ApprovalOverride}}Approval: overridden with --yes
+{{end}}{{if .Error}}Error: {{.Error}}{{end}}`
 
 // Send sends a notification through shoutrrr to the given URLs.
 func Send(urls []string, data AlertData) error {
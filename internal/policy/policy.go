@@ -0,0 +1,114 @@
+// Package policy checks deploy commands against the deploy_policy deny-
+// and allow-lists before they are sent to a server.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+// rmRecursive matches "rm" with a recursive flag and any further options,
+// e.g. "rm -rf", "rm -r -f", "rm --recursive --force".
+const rmRecursive = `\brm\s+(?:-\S+\s+)*?(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s+(?:-\S+\s+)*`
+
+// argEnd matches the end of a shell word.
+const argEnd = `(?:$|[\s;&|)])`
+
+// DefaultDeny is always part of the deny-list.
+var DefaultDeny = []string{
+	// rm -rf / and rm -rf /*
+	rmRecursive + `/\*?` + argEnd,
+	// rm -rf $DIR, "${DIR}"/ or $DIR/* remove / or the working directory
+	// when DIR is unset; ${DIR:?} is allowed since it aborts instead
+	rmRecursive + `"?\$\{?\w+\}?"?/?\*?` + argEnd,
+}
+
+// Violation is a deploy command rejected by the policy.
+type Violation struct {
+	Deploy  string
+	Command string
+	// Pattern is the deny pattern the command matched. It is empty when
+	// the command matched none of the allow patterns.
+	Pattern string
+}
+
+func (v Violation) String() string {
+	if v.Pattern == "" {
+		return fmt.Sprintf("deploy %q: command %q matches no allow pattern", v.Deploy, v.Command)
+	}
+	return fmt.Sprintf("deploy %q: command %q matches deny pattern `%s`", v.Deploy, v.Command, v.Pattern)
+}
+
+// Error lists every policy violation.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d deploy policy violation(s):", len(e.Violations))
+	for _, v := range e.Violations {
+		sb.WriteString("\n  " + v.String())
+	}
+	return sb.String()
+}
+
+// Check matches the commands of deploys against p and returns an *Error
+// listing every violation.
+func Check(p config.DeployPolicyConfig, deploys ...config.DeployConfig) error {
+	deny, err := compile(append(DefaultDeny, p.Deny...))
+	if err != nil {
+		return fmt.Errorf("deploy_policy.deny: %w", err)
+	}
+	allow, err := compile(p.Allow)
+	if err != nil {
+		return fmt.Errorf("deploy_policy.allow: %w", err)
+	}
+
+	var violations []Violation
+	for _, d := range deploys {
+		for _, cmd := range d.RunCommands() {
+			if v, ok := check(deny, allow, cmd); !ok {
+				v.Deploy = d.Name
+				violations = append(violations, v)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// check reports whether cmd passes the policy and otherwise describes why not.
+func check(deny, allow []*regexp.Regexp, cmd string) (Violation, bool) {
+	for _, re := range deny {
+		if re.MatchString(cmd) {
+			return Violation{Command: cmd, Pattern: re.String()}, false
+		}
+	}
+	if len(allow) == 0 {
+		return Violation{}, true
+	}
+	for _, re := range allow {
+		if re.MatchString(cmd) {
+			return Violation{}, true
+		}
+	}
+	return Violation{Command: cmd}, false
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestDefaultDeny(t *testing.T) {
+	tests := []struct {
+		cmd    string
+		denied bool
+	}{
+		{"rm -rf /", true},
+		{"rm -rf /*", true},
+		{"sudo rm -r -f --no-preserve-root /", true},
+		{"rm -rf ${RELEASE_DIR}/", true},
+		{"rm -rf $RELEASE_DIR", true},
+		{`rm -fr "$RELEASE_DIR"/*`, true},
+		{"cd /tmp && rm -rf $DIR; ls", true},
+		{"rm -rf ${RELEASE_DIR:?}/", false},
+		{"rm -rf $RELEASE_DIR/old", false},
+		{"rm -rf /opt/app/releases/old", false},
+		{"rm -f $PIDFILE", false},
+		{"systemctl restart app", false},
+	}
+	for _, tt := range tests {
+		err := Check(config.DeployPolicyConfig{}, config.DeployConfig{Name: "prod", Commands: []config.Command{{Run: tt.cmd}}})
+		if (err != nil) != tt.denied {
+			t.Errorf("Check(%q) error = %v, want denied %v", tt.cmd, err, tt.denied)
+		}
+	}
+}
+
+func TestCheckAllowList(t *testing.T) {
+	p := config.DeployPolicyConfig{
+		Allow: []string{`^systemctl (restart|status) myapp$`},
+		Deny:  []string{`\bcurl\b.*\|\s*sh`},
+	}
+	deploys := []config.DeployConfig{
+		{Name: "prod", Commands: []config.Command{{Run: "systemctl restart myapp"}, {Run: "reboot"}}},
+		{Name: "staging", Commands: []config.Command{{Run: "curl -s https://x | sh"}}},
+	}
+
+	err := Check(p, deploys...)
+	var policyErr *Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Check() error = %v, want *Error", err)
+	}
+	want := []Violation{
+		{Deploy: "prod", Command: "reboot"},
+		{Deploy: "staging", Command: "curl -s https://x | sh", Pattern: `\bcurl\b.*\|\s*sh`},
+	}
+	if len(policyErr.Violations) != len(want) {
+		t.Fatalf("violations = %+v, want %+v", policyErr.Violations, want)
+	}
+	for i := range want {
+		if policyErr.Violations[i] != want[i] {
+			t.Errorf("violation %d = %+v, want %+v", i, policyErr.Violations[i], want[i])
+		}
+	}
+}
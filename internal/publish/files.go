@@ -0,0 +1,78 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// manifestFiles returns the names of the files in artifactsDir that
+// artifacts.json lists, narrowed to the artifacts of the builds with ids
+// and of types when set. Without filters the metadata files are included,
+// for publishable to decide on. It returns nil, selecting every file, when
+// there is neither an artifacts.json nor a filter.
+func manifestFiles(artifactsDir string, ids, types []string) (map[string]bool, error) {
+	filtered := len(ids) > 0 || len(types) > 0
+	m, err := manifest.Load(filepath.Join(artifactsDir, manifest.FileName))
+	if !filtered && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		if len(ids) > 0 {
+			return nil, fmt.Errorf("builds filter: %w", err)
+		}
+		if len(types) > 0 {
+			return nil, fmt.Errorf("types filter: %w", err)
+		}
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, a := range m.Artifacts {
+		if len(ids) > 0 && !slices.ContainsFunc(a.Builds, func(id string) bool { return slices.Contains(ids, id) }) {
+			continue
+		}
+		if len(types) > 0 && !slices.Contains(types, a.Type) {
+			continue
+		}
+		names[a.Name] = true
+	}
+	if !filtered {
+		for _, name := range metadataFiles {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// fileFilter returns whether the destination name uploads a file of
+// artifactsDir: a publishable file that artifacts.json lists, when there
+// is one, with the builds and types filters applied. Files missing from
+// artifacts.json, e.g. left over from another build, are logged.
+func fileFilter(name, artifactsDir string, files []os.DirEntry, builds, types []string, metadata bool) (func(os.DirEntry) bool, error) {
+	only, err := manifestFiles(artifactsDir, builds, types)
+	if err != nil {
+		return nil, err
+	}
+	if only != nil && len(builds) == 0 && len(types) == 0 {
+		var unlisted []string
+		for _, file := range files {
+			if publishable(file, metadata) && !only[file.Name()] {
+				unlisted = append(unlisted, file.Name())
+			}
+		}
+		if len(unlisted) > 0 {
+			log.Printf("Not publishing files missing from %s to %s: %s", manifest.FileName, name, strings.Join(unlisted, ", "))
+		}
+	}
+	return func(file os.DirEntry) bool {
+		return publishable(file, metadata) && (only == nil || only[file.Name()])
+	}, nil
+}
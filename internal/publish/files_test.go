@@ -0,0 +1,73 @@
+package publish
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestManifestFiles(t *testing.T) {
+	dir := t.TempDir()
+	if only, err := manifestFiles(dir, nil, nil); only != nil || err != nil {
+		t.Fatalf("manifestFiles() without artifacts.json = %v, %v; want nil", only, err)
+	}
+	if _, err := manifestFiles(dir, []string{"server"}, nil); err == nil {
+		t.Fatal("expected error without artifacts.json")
+	}
+	if _, err := manifestFiles(dir, nil, []string{manifest.TypeArchive}); err == nil {
+		t.Fatal("expected error without artifacts.json")
+	}
+
+	m := &manifest.Manifest{Artifacts: []manifest.Artifact{
+		{Name: "server_linux_amd64.tar.gz", Type: manifest.TypeArchive, Builds: []string{"server"}},
+		{Name: "server_linux_amd64.tar.gz.sbom.json", Type: manifest.TypeSBOM, Builds: []string{"server"}},
+		{Name: "suite_linux_amd64.tar.gz", Type: manifest.TypeArchive, Builds: []string{"cli", "worker"}},
+		{Name: "cli_linux_amd64", Type: manifest.TypeBinary, Builds: []string{"cli"}},
+		{Name: "checksums.txt", Type: manifest.TypeChecksum},
+	}}
+	if err := manifest.Write(filepath.Join(dir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		builds []string
+		types  []string
+		want   []string
+	}{
+		{
+			name: "all",
+			want: []string{"artifacts.json", "checksums.txt", "cli_linux_amd64", "server_linux_amd64.tar.gz", "server_linux_amd64.tar.gz.sbom.json", "suite_linux_amd64.tar.gz"},
+		},
+		{
+			name:   "builds",
+			builds: []string{"server", "worker"},
+			want:   []string{"server_linux_amd64.tar.gz", "server_linux_amd64.tar.gz.sbom.json", "suite_linux_amd64.tar.gz"},
+		},
+		{
+			name:  "types",
+			types: []string{manifest.TypeArchive, manifest.TypeChecksum},
+			want:  []string{"checksums.txt", "server_linux_amd64.tar.gz", "suite_linux_amd64.tar.gz"},
+		},
+		{
+			name:   "builds and types",
+			builds: []string{"server"},
+			types:  []string{manifest.TypeArchive},
+			want:   []string{"server_linux_amd64.tar.gz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			only, err := manifestFiles(dir, tt.builds, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slices.Sorted(maps.Keys(only)); !slices.Equal(got, tt.want) {
+				t.Errorf("manifestFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
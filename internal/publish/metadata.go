@@ -0,0 +1,64 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// metadataFiles are the gcx metadata files of the artifacts directory.
+// They may reveal local paths and configuration, so only destinations with
+// publish_metadata receive them.
+var metadataFiles = []string{manifest.FileName}
+
+// workDirs hold gcx working files below the artifacts directory.
+var workDirs = []string{".work", ".gcx-cache"}
+
+// IsMetadata reports whether name, relative to the artifacts directory, is
+// gcx metadata rather than a release artifact: a metadata file, the publish
+// state, or anything in a working directory.
+func IsMetadata(name string) bool {
+	name = filepath.ToSlash(filepath.Clean(name))
+	if name == StateFileName || slices.Contains(metadataFiles, name) {
+		return true
+	}
+	first, _, _ := strings.Cut(name, "/")
+	return slices.Contains(workDirs, first)
+}
+
+// publishable reports whether a file in the artifacts directory is uploaded.
+// Metadata files are uploaded only with metadata set; the publish state
+// describes the local run and is never uploaded.
+func publishable(file os.DirEntry, metadata bool) bool {
+	switch {
+	case file.IsDir(), file.Name() == StateFileName:
+		return false
+	case IsMetadata(file.Name()):
+		return metadata
+	}
+	return true
+}
+
+// excludedMetadata returns the names of the metadata files and working
+// directories (with a trailing slash) in artifactsDir that a destination
+// without publish_metadata does not receive.
+func excludedMetadata(artifactsDir string) []string {
+	files, err := os.ReadDir(artifactsDir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, file := range files {
+		switch {
+		case !IsMetadata(file.Name()) || file.Name() == StateFileName:
+		case file.IsDir():
+			names = append(names, file.Name()+"/")
+		default:
+			names = append(names, file.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,60 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsMetadata(t *testing.T) {
+	for name, want := range map[string]bool{
+		"artifacts.json":              true,
+		"publish-state.json":          true,
+		".work/config.resolved.yaml":  true,
+		".gcx-cache/includes/x":       true,
+		"app_linux_amd64.tar.gz":      false,
+		"checksums.txt":               false,
+		"app_linux_amd64.sbom.json":   false,
+		"workdir/artifacts.json.note": false,
+	} {
+		if got := IsMetadata(name); got != want {
+			t.Errorf("IsMetadata(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPublishable(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.tar.gz", "checksums.txt", "artifacts.json", StateFileName} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".work"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := func(metadata bool) []string {
+		var names []string
+		for _, file := range files {
+			if publishable(file, metadata) {
+				names = append(names, file.Name())
+			}
+		}
+		return names
+	}
+	if got, want := published(false), []string{"app.tar.gz", "checksums.txt"}; !slices.Equal(got, want) {
+		t.Errorf("published without publish_metadata = %v, want %v", got, want)
+	}
+	if got, want := published(true), []string{"app.tar.gz", "artifacts.json", "checksums.txt"}; !slices.Equal(got, want) {
+		t.Errorf("published with publish_metadata = %v, want %v", got, want)
+	}
+	if got := excludedMetadata(dir); !slices.Equal(got, []string{".work/", "artifacts.json"}) {
+		t.Errorf("excludedMetadata() = %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package publish
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/split"
+)
+
+// localFile is a file to upload: name is recorded in the publish state.
+type localFile struct {
+	name string
+	path string
+}
+
+// oversize applies the max_object_size and oversize settings of a blob.
+type oversize struct {
+	blob   string
+	max    int64
+	policy string
+	// dir holds the parts of split files, created on first use
+	dir string
+}
+
+func newOversize(blob string, maxSize int64, policy string) *oversize {
+	return &oversize{blob: blob, max: maxSize, policy: cmp.Or(policy, config.OversizeFail)}
+}
+
+// files returns the files to upload for name in artifactsDir: the file
+// itself, nothing when it is skipped, or its parts and their manifest.
+// Skipped and split files are noted in state for the summary.
+func (o *oversize) files(artifactsDir, name string, state *State) ([]localFile, error) {
+	path := filepath.Join(artifactsDir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if o.max == 0 || info.Size() <= o.max {
+		return []localFile{{name, path}}, nil
+	}
+
+	size := fmt.Sprintf("%s is %s, over max_object_size %s", name, helpers.FormatBytes(info.Size()), helpers.FormatBytes(o.max))
+	switch o.policy {
+	case config.OversizeSkip:
+		log.Printf("Warning: NOT publishing %s to %s: %s (oversize: skip)", name, o.blob, size)
+		state.NoteOversize(o.blob, fmt.Sprintf("%s (%s) skipped", name, helpers.FormatBytes(info.Size())))
+		return nil, nil
+	case config.OversizeSplit:
+		if o.dir == "" {
+			if o.dir, err = os.MkdirTemp("", "gcx-split-"); err != nil {
+				return nil, fmt.Errorf("split %s: %w", name, err)
+			}
+		}
+		paths, err := split.File(path, o.dir, o.max)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Warning: %s; publishing it to %s as %d parts and %s", size, o.blob, len(paths)-1, filepath.Base(paths[len(paths)-1]))
+		state.NoteOversize(o.blob, fmt.Sprintf("%s (%s) split into %d parts", name, helpers.FormatBytes(info.Size()), len(paths)-1))
+		files := make([]localFile, 0, len(paths))
+		for _, p := range paths {
+			files = append(files, localFile{filepath.Base(p), p})
+		}
+		return files, nil
+	default:
+		return nil, fmt.Errorf("%s (set oversize: skip or split to publish the rest)", size)
+	}
+}
+
+// Close removes the parts of split files.
+func (o *oversize) Close() error {
+	if o.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(o.dir)
+}
+
+// selectFiles returns the files to upload among files in artifactsDir:
+// those include selects, after applying ov.
+func selectFiles(artifactsDir string, files []os.DirEntry, include func(os.DirEntry) bool, ov *oversize, state *State) ([]localFile, error) {
+	var uploads []localFile
+	for _, file := range files {
+		if !include(file) {
+			continue
+		}
+		f, err := ov.files(artifactsDir, file.Name(), state)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, f...)
+	}
+	return uploads, nil
+}
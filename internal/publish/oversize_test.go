@@ -0,0 +1,93 @@
+package publish
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+)
+
+func TestS3PublishOversize(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	tests := []struct {
+		oversize string
+		keys     []string
+		notes    []string
+		err      string
+	}{
+		{
+			oversize: config.OversizeSkip,
+			keys:     []string{"/releases/v1.0.0/app.tar.gz"},
+			notes:    []string{"debug.tar.gz (10 B) skipped"},
+		},
+		{
+			oversize: config.OversizeSplit,
+			keys: []string{
+				"/releases/v1.0.0/app.tar.gz",
+				"/releases/v1.0.0/debug.tar.gz.part001",
+				"/releases/v1.0.0/debug.tar.gz.part002",
+				"/releases/v1.0.0/debug.tar.gz.parts.json",
+			},
+			notes: []string{"debug.tar.gz (10 B) split into 2 parts"},
+		},
+		{
+			oversize: config.OversizeFail,
+			err:      "debug.tar.gz is 10 B, over max_object_size 8 B",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.oversize, func(t *testing.T) {
+			fake := &fakeS3{headers: make(map[string]http.Header)}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			dir := t.TempDir()
+			for name, content := range map[string]string{"app.tar.gz": "app", "debug.tar.gz": "0123456789"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			blob := config.BlobConfig{
+				Provider: "s3", Name: "s3", Bucket: "releases", Region: "us-east-1",
+				Endpoint: srv.URL, Directory: "{{.Version}}",
+				MaxObjectSize: 8, Oversize: tt.oversize,
+			}
+			if err := blob.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewS3Publisher(blob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := NewState(filepath.Join(dir, StateFileName), "v1.0.0")
+			err = p.Publish(context.Background(), dir, config.ReleaseData{Version: "v1.0.0"}, state)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Publish() error = %v, want %q", err, tt.err)
+				}
+				if len(fake.headers) != 0 {
+					t.Errorf("uploaded %v before failing", slices.Sorted(maps.Keys(fake.headers)))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			if got := slices.Sorted(maps.Keys(fake.headers)); !slices.Equal(got, tt.keys) {
+				t.Errorf("uploaded %v, want %v", got, tt.keys)
+			}
+			if got := state.Oversize("s3"); !slices.Equal(got, tt.notes) {
+				t.Errorf("oversize notes = %v, want %v", got, tt.notes)
+			}
+		})
+	}
+}
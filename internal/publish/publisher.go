@@ -2,17 +2,30 @@ package publish
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/sxwebdev/gcx/internal/build"
 	"github.com/sxwebdev/gcx/internal/config"
 	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/inject"
+	"github.com/sxwebdev/gcx/internal/metrics"
 )
 
 // Publisher uploads artifacts to a remote destination.
 type Publisher interface {
 	Name() string
-	Publish(ctx context.Context, artifactsDir string, version string) error
+	// Publish uploads the artifacts in artifactsDir, skipping those state
+	// records as done and recording each successful upload.
+	Publish(ctx context.Context, artifactsDir string, rel config.ReleaseData, state *State) error
 }
 
 // NewPublisher creates a Publisher from a BlobConfig.
@@ -27,10 +40,95 @@ func NewPublisher(cfg config.BlobConfig) (Publisher, error) {
 	}
 }
 
-// Run publishes artifacts to configured destinations.
-func Run(ctx context.Context, cfg *config.Config, publishName string) error {
-	artifactsDir := cfg.OutDir
+// newPublisher is replaced in tests.
+var newPublisher = NewPublisher
+
+// RemoteFile describes an entry in a publish destination.
+type RemoteFile struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Fetcher lists and downloads previously published artifacts.
+type Fetcher interface {
+	Name() string
+	// List returns the entries directly under dir. A missing directory yields no entries.
+	List(ctx context.Context, dir string) ([]RemoteFile, error)
+	// Download copies the remote file at remotePath to localPath.
+	Download(ctx context.Context, remotePath, localPath string) error
+	// Close releases any connection held by the fetcher.
+	Close() error
+}
+
+// NewFetcher creates a Fetcher from a BlobConfig.
+func NewFetcher(cfg config.BlobConfig) (Fetcher, error) {
+	switch cfg.Provider {
+	case "s3":
+		return NewS3Publisher(cfg)
+	case "ssh":
+		return NewSSHPublisher(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported publish provider: %s", cfg.Provider)
+	}
+}
+
+// Options holds command-line overrides for Run.
+type Options struct {
+	// Resume skips destination and artifact pairs recorded as uploaded in
+	// the publish state file of a previous run.
+	Resume bool
+}
+
+// Result is the outcome of publishing to one destination.
+type Result struct {
+	Destination string
+	// Uploaded is the number of artifacts uploaded by this run.
+	Uploaded int
+	// Skipped is the number of artifacts already uploaded by a previous run.
+	Skipped int
+	// Disabled reports that the enabled expression of the destination is
+	// false for the release.
+	Disabled bool
+	// Oversize lists the files over the destination's max_object_size
+	// that were skipped or split.
+	Oversize []string
+	// Transfer counts the bytes sent and referenced by a destination with
+	// dedupe: content_addressed, nil otherwise.
+	Transfer *Transfer
+	Err      error
+}
+
+// Status returns "ok", "disabled" or FAILED with the error message.
+func (r Result) Status() string {
+	switch {
+	case r.Err != nil:
+		return "FAILED: " + r.Err.Error()
+	case r.Disabled:
+		return "disabled"
+	default:
+		return "ok"
+	}
+}
+
+// Run publishes artifacts to the configured destinations. Every destination
+// is attempted even if an earlier one fails; the returned error joins all
+// failures. Uploaded pairs are recorded in out_dir/publish-state.json.
+func Run(ctx context.Context, cfg *config.Config, publishName string, opts Options) (err error) {
+	defer func(start time.Time) { metrics.ObserveStage("publish", start, err) }(time.Now())
+
 	tag := git.GetTag(ctx)
+	rel := cfg.Release(tag)
+	artifactsDir, err := cfg.OutputDir(tag)
+	if err != nil {
+		return err
+	}
+
+	if err := build.CheckNames(cfg, tag); err != nil {
+		return err
+	}
 
 	var blobs []config.BlobConfig
 	if publishName != "" {
@@ -48,15 +146,134 @@ func Run(ctx context.Context, cfg *config.Config, publishName string) error {
 		blobs = cfg.Blobs
 	}
 
+	statePath := filepath.Join(artifactsDir, StateFileName)
+	state := NewState(statePath, tag)
+	if opts.Resume {
+		if state, err = LoadState(statePath, tag); err != nil {
+			return err
+		}
+	}
+
+	timeout := cfg.Publish.Timeout.Std()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	results := make([]Result, 0, len(blobs))
+	var errs []error
+	// oversized and deduped make even a single destination print the summary
+	var oversized, deduped bool
 	for _, blob := range blobs {
-		publisher, err := NewPublisher(blob)
+		result := Result{Destination: blob.Name, Skipped: state.Count(blob.Name)}
+		if ctx.Err() != nil {
+			// The stage deadline passed while publishing an earlier destination
+			result.Err = fmt.Errorf("not attempted: %w", ctx.Err())
+			results = append(results, result)
+			continue
+		}
+
+		enabled, err := blob.IsEnabled(rel)
 		if err != nil {
-			return fmt.Errorf("create publisher %q: %w", blob.Name, err)
+			result.Err = err
+			errs = append(errs, fmt.Errorf("publish %q: %w", blob.Name, err))
+			results = append(results, result)
+			continue
+		}
+		if !enabled {
+			log.Printf("Skipping %s: disabled on channel %s", blob.Name, rel.Channel)
+			result.Disabled = true
+			results = append(results, result)
+			continue
+		}
+
+		result.Err = publishOne(ctx, blob, artifactsDir, rel, state)
+		if result.Err != nil && timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Err = newTimeoutError(timeout, blob.Name, result.Err)
+		}
+		result.Uploaded = state.Count(blob.Name) - result.Skipped
+		result.Oversize = state.Oversize(blob.Name)
+		if len(result.Oversize) > 0 {
+			oversized = true
+		}
+		if t, ok := state.Transfer(blob.Name); ok {
+			result.Transfer = &t
+			deduped = true
+		}
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("publish %q: %w", blob.Name, result.Err))
+		}
+		results = append(results, result)
+	}
+
+	if len(blobs) > 1 || len(errs) > 0 || oversized || deduped {
+		if err := WriteSummary(os.Stdout, rel.Channel, results); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d destination(s) failed, re-run with --resume to retry them: %w",
+			len(errs), len(blobs), errors.Join(errs...))
+	}
+	return nil
+}
+
+func publishOne(ctx context.Context, blob config.BlobConfig, artifactsDir string, rel config.ReleaseData, state *State) error {
+	publisher, err := newPublisher(blob)
+	if err != nil {
+		return fmt.Errorf("create publisher: %w", err)
+	}
+	log.Printf("Publishing to: %s", publisher.Name())
+	if excluded := excludedMetadata(artifactsDir); len(excluded) > 0 && !blob.PublishMetadata {
+		log.Printf("Not publishing gcx metadata to %s: %s (set publish_metadata: true to include it)",
+			blob.Name, strings.Join(excluded, ", "))
+	}
+	if len(blob.Builds) > 0 {
+		log.Printf("Publishing only the artifacts of builds %s to %s", strings.Join(blob.Builds, ", "), blob.Name)
+	}
+	if err := inject.Check("publish", blob.Name); err != nil {
+		return err
+	}
+	return publisher.Publish(ctx, artifactsDir, rel, state)
+}
+
+// WriteSummary prints the release channel, one line per destination with
+// its status, the files skipped or split for max_object_size and the bytes
+// content-addressed destinations sent and referenced.
+func WriteSummary(w io.Writer, channel string, results []Result) error {
+	if channel != "" {
+		fmt.Fprintf(w, "Channel: %s\n", channel)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DESTINATION\tUPLOADED\tSKIPPED\tSTATUS")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", r.Destination, r.Uploaded, r.Skipped, r.Status())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	header := false
+	for _, r := range results {
+		for _, note := range r.Oversize {
+			if !header {
+				fmt.Fprintln(w, "Over max_object_size:")
+				header = true
+			}
+			fmt.Fprintf(w, "  %s: %s\n", r.Destination, note)
+		}
+	}
+	header = false
+	for _, r := range results {
+		if r.Transfer == nil {
+			continue
 		}
-		log.Printf("Publishing to: %s", publisher.Name())
-		if err := publisher.Publish(ctx, artifactsDir, tag); err != nil {
-			return fmt.Errorf("publish %q: %w", blob.Name, err)
+		if !header {
+			fmt.Fprintln(w, "Content addressed:")
+			header = true
 		}
+		fmt.Fprintf(w, "  %s: sent %s, referenced %s\n", r.Destination,
+			helpers.FormatBytes(r.Transfer.Sent), helpers.FormatBytes(r.Transfer.Referenced))
 	}
 	return nil
 }
@@ -7,61 +7,87 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/sxwebdev/gcx/internal/checksum"
 	"github.com/sxwebdev/gcx/internal/config"
-	"github.com/sxwebdev/gcx/internal/tmpl"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/metrics"
 )
 
 // S3Publisher uploads artifacts to S3-compatible storage.
 type S3Publisher struct {
-	name      string
-	bucket    string
-	region    string
-	endpoint  string
-	directory string
+	name        string
+	bucket      string
+	region      string
+	endpoint    string
+	maxAttempts int
+	// remoteDir renders and normalizes the directory template
+	remoteDir func(rel config.ReleaseData) (string, error)
+	// objectName renders the remote file name from the object_template
+	objectName func(name string, rel config.ReleaseData, shortSha256 string) (string, error)
+	// metadata uploads gcx metadata files (publish_metadata)
+	metadata bool
+	// builds limits the upload to the artifacts of these build ids
+	builds []string
+	// types limits the upload to the artifacts of these types
+	types []string
+	// maxObjectSize and oversize handle files the destination rejects
+	maxObjectSize int64
+	oversize      string
+	// objectHeaders renders the user metadata and Content-Disposition;
+	// hasHeaders is set when the blob configures either
+	objectHeaders func(data config.ObjectHeaderData) (map[string]string, string, error)
+	hasHeaders    bool
+	// dedupe is plain or content_addressed
+	dedupe string
 }
 
+// casPrefix is the bucket directory of content-addressed objects, named
+// by the SHA-256 of their content.
+const casPrefix = "cas"
+
+// maxCopySize is the largest object S3 copies in a single CopyObject
+// request.
+const maxCopySize = 5 << 30
+
 // NewS3Publisher creates an S3Publisher from config.
 func NewS3Publisher(cfg config.BlobConfig) (*S3Publisher, error) {
 	return &S3Publisher{
-		name:      cfg.Name,
-		bucket:    cfg.Bucket,
-		region:    cfg.Region,
-		endpoint:  cfg.Endpoint,
-		directory: cfg.Directory,
+		name:          cfg.Name,
+		bucket:        cfg.Bucket,
+		region:        cfg.Region,
+		endpoint:      cfg.Endpoint,
+		remoteDir:     cfg.RemoteDir,
+		maxAttempts:   cfg.MaxAttempts,
+		objectName:    cfg.ObjectName,
+		metadata:      cfg.PublishMetadata,
+		builds:        cfg.Builds,
+		types:         cfg.Types,
+		maxObjectSize: cfg.MaxObjectSize.Bytes(),
+		oversize:      cfg.Oversize,
+		objectHeaders: cfg.ObjectHeaders,
+		hasHeaders:    len(cfg.Metadata) > 0 || cfg.ContentDispositionTemplate != "",
+		dedupe:        cfg.Dedupe,
 	}, nil
 }
 
 func (p *S3Publisher) Name() string { return p.name }
 
-func (p *S3Publisher) Publish(ctx context.Context, artifactsDir string, version string) error {
-	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-	if accessKey == "" || secretKey == "" {
-		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
-	}
-
-	remoteDir, err := tmpl.Process("directory", p.directory, map[string]string{"Version": version})
-	if err != nil {
-		return fmt.Errorf("process directory template: %w", err)
-	}
-
-	urlData, err := url.Parse(p.endpoint)
+func (p *S3Publisher) Publish(ctx context.Context, artifactsDir string, rel config.ReleaseData, state *State) error {
+	remoteDir, err := p.remoteDir(rel)
 	if err != nil {
-		return fmt.Errorf("parse endpoint: %w", err)
+		return err
 	}
 
-	client, err := minio.New(urlData.Host, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: strings.HasPrefix(p.endpoint, "https"),
-		Region: p.region,
-	})
+	client, err := p.newClient()
 	if err != nil {
-		return fmt.Errorf("create S3 client: %w", err)
+		return err
 	}
 
 	exists, err := client.BucketExists(ctx, p.bucket)
@@ -79,32 +105,263 @@ func (p *S3Publisher) Publish(ctx context.Context, artifactsDir string, version
 	if err != nil {
 		return fmt.Errorf("read directory %s: %w", artifactsDir, err)
 	}
+	selected, err := fileFilter(p.name, artifactsDir, files, p.builds, p.types, p.metadata)
+	if err != nil {
+		return err
+	}
+
+	// Header templates see the commit and environment of the publish run
+	headerData := config.ObjectHeaderData{ObjectTemplateData: config.ObjectTemplateData{Version: rel.Version, Channel: rel.Channel}}
+	if p.hasHeaders {
+		headerData.Commit = git.GetCommitHash(ctx)
+		headerData.Env = envvars.Environ()
+	}
+
+	ov := newOversize(p.name, p.maxObjectSize, p.oversize)
+	defer func() { _ = ov.Close() }()
+	uploads, err := selectFiles(artifactsDir, files, selected, ov, state)
+	if err != nil {
+		return err
+	}
 
-	for _, file := range files {
-		if file.IsDir() {
+	for _, file := range uploads {
+		if state.Has(p.name, file.name) {
+			log.Printf("Skipping %s: already published to %s", file.name, p.name)
 			continue
 		}
-		localFilePath := filepath.Join(artifactsDir, file.Name())
+		localFilePath := file.path
+		digest, err := localDigests.File(localFilePath)
+		if err != nil {
+			return fmt.Errorf("checksum file %s: %w", localFilePath, err)
+		}
+		objectName, err := p.objectName(file.name, rel, digest.ShortSHA256())
+		if err != nil {
+			return err
+		}
 		// Use path.Join (not filepath.Join) for URL-style S3 paths
-		remotePath := path.Join(remoteDir, file.Name())
-
-		log.Printf("Uploading %s to s3://%s/%s", localFilePath, p.bucket, remotePath)
+		remotePath := path.Join(remoteDir, objectName)
+		opts := minio.PutObjectOptions{SendContentMd5: true}
+		if p.hasHeaders {
+			headerData.Name, headerData.ShortSha256, headerData.Object = file.name, digest.ShortSHA256(), objectName
+			if opts.UserMetadata, opts.ContentDisposition, err = p.objectHeaders(headerData); err != nil {
+				return err
+			}
+		}
 
-		f, err := os.Open(localFilePath)
+		if p.dedupe == config.DedupeContentAddressed {
+			err = p.publishContentAddressed(ctx, client, localFilePath, remotePath, digest, opts, state)
+		} else {
+			log.Printf("Uploading %s to s3://%s/%s", localFilePath, p.bucket, remotePath)
+			err = p.upload(ctx, client, localFilePath, remotePath, digest, opts)
+		}
 		if err != nil {
-			return fmt.Errorf("open file %s: %w", localFilePath, err)
+			return err
+		}
+		if err := state.Record(p.name, file.name); err != nil {
+			return err
 		}
+	}
+	if t, ok := state.Transfer(p.name); ok {
+		log.Printf("Sent %s to %s, referenced %s it already stored", helpers.FormatBytes(t.Sent), p.name, helpers.FormatBytes(t.Referenced))
+	}
+	return nil
+}
 
-		stat, err := f.Stat()
-		if err != nil {
-			_ = f.Close()
-			return fmt.Errorf("stat file %s: %w", localFilePath, err)
+// upload puts localFilePath at remotePath, verified against digest.
+func (p *S3Publisher) upload(ctx context.Context, client *minio.Client, localFilePath, remotePath string, digest checksum.Digest, opts minio.PutObjectOptions) error {
+	var info minio.UploadInfo
+	start := time.Now()
+	err := uploadVerified(remotePath, p.maxAttempts,
+		func() (err error) {
+			info, err = p.putObject(ctx, client, localFilePath, remotePath, opts)
+			return interrupted(ctx, "s3://"+p.bucket+"/"+remotePath, err)
+		},
+		func() error { return verifyS3Upload(remotePath, info, digest) },
+		func() error { return client.RemoveObject(ctx, p.bucket, remotePath, minio.RemoveObjectOptions{}) },
+	)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveUpload("s3", p.name, digest.Size, time.Since(start))
+	return nil
+}
+
+// publishContentAddressed stores the content of localFilePath once under
+// cas/<sha256>, uploading it only when the bucket has no intact copy, and
+// copies it server-side to remotePath with the headers of opts.
+func (p *S3Publisher) publishContentAddressed(ctx context.Context, client *minio.Client, localFilePath, remotePath string, digest checksum.Digest, opts minio.PutObjectOptions, state *State) error {
+	casPath := path.Join(casPrefix, digest.SHA256Hex())
+	stored, err := p.casStored(ctx, client, casPath, digest)
+	if err != nil {
+		return err
+	}
+	if stored {
+		log.Printf("Referencing %s from s3://%s/%s", localFilePath, p.bucket, casPath)
+		state.AddTransfer(p.name, 0, digest.Size)
+	} else {
+		log.Printf("Uploading %s to s3://%s/%s", localFilePath, p.bucket, casPath)
+		// The stored content is shared by versions, so it gets no
+		// version-specific headers
+		if err := p.upload(ctx, client, localFilePath, casPath, digest, minio.PutObjectOptions{SendContentMd5: true}); err != nil {
+			return err
 		}
+		state.AddTransfer(p.name, digest.Size, 0)
+	}
 
-		_, err = client.PutObject(ctx, p.bucket, remotePath, f, stat.Size(), minio.PutObjectOptions{})
-		_ = f.Close()
-		if err != nil {
-			return fmt.Errorf("upload file %s: %w", localFilePath, err)
+	dst := minio.CopyDestOptions{Bucket: p.bucket, Object: remotePath}
+	if p.hasHeaders {
+		dst.ReplaceMetadata = true
+		dst.UserMetadata, dst.ContentDisposition = opts.UserMetadata, opts.ContentDisposition
+	}
+	src := minio.CopySrcOptions{Bucket: p.bucket, Object: casPath}
+	copyObject := client.CopyObject
+	if digest.Size > maxCopySize {
+		// ComposeObject copies the object in parts
+		copyObject = func(ctx context.Context, dst minio.CopyDestOptions, src minio.CopySrcOptions) (minio.UploadInfo, error) {
+			return client.ComposeObject(ctx, dst, src)
+		}
+	}
+	if _, err := copyObject(ctx, dst, src); err != nil {
+		return fmt.Errorf("copy s3://%s/%s to %s: %w", p.bucket, casPath, remotePath, interrupted(ctx, "s3://"+p.bucket+"/"+remotePath, err))
+	}
+	return nil
+}
+
+// casStored reports whether casPath holds the content of digest. A
+// mismatching object is reported and overwritten by the caller.
+func (p *S3Publisher) casStored(ctx context.Context, client *minio.Client, casPath string, digest checksum.Digest) (bool, error) {
+	info, err := client.StatObject(ctx, p.bucket, casPath, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat s3://%s/%s: %w", p.bucket, casPath, err)
+	}
+	if err := verifyS3Upload(casPath, minio.UploadInfo{Size: info.Size, ETag: info.ETag}, digest); err != nil {
+		log.Printf("Warning: replacing s3://%s/%s: %v", p.bucket, casPath, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (p *S3Publisher) newClient() (*minio.Client, error) {
+	accessKey := envvars.Get("AWS_ACCESS_KEY_ID")
+	secretKey := envvars.Get("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	urlData, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+
+	client, err := minio.New(urlData.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: strings.HasPrefix(p.endpoint, "https"),
+		Region: p.region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create S3 client: %w", err)
+	}
+	return client, nil
+}
+
+// List returns the objects and common prefixes directly under dir.
+func (p *S3Publisher) List(ctx context.Context, dir string) ([]RemoteFile, error) {
+	client, err := p.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(dir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var files []RemoteFile
+	for obj := range client.ListObjects(ctx, p.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("list s3://%s/%s: %w", p.bucket, prefix, obj.Err)
+		}
+		name := strings.TrimPrefix(obj.Key, prefix)
+		isDir := strings.HasSuffix(name, "/")
+		name = strings.TrimSuffix(name, "/")
+		if name == "" {
+			continue
+		}
+		files = append(files, RemoteFile{
+			Name:    name,
+			Path:    strings.TrimSuffix(obj.Key, "/"),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+			IsDir:   isDir,
+		})
+	}
+	return files, nil
+}
+
+// Download fetches the object at remotePath into localPath.
+func (p *S3Publisher) Download(ctx context.Context, remotePath, localPath string) error {
+	client, err := p.newClient()
+	if err != nil {
+		return err
+	}
+	if err := client.FGetObject(ctx, p.bucket, remotePath, localPath, minio.GetObjectOptions{}); err != nil {
+		return fmt.Errorf("download s3://%s/%s: %w", p.bucket, remotePath, err)
+	}
+	return nil
+}
+
+// Close is a no-op; S3 requests are stateless.
+func (p *S3Publisher) Close() error { return nil }
+
+func (p *S3Publisher) putObject(ctx context.Context, client *minio.Client, localFilePath, remotePath string, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	f, err := os.Open(localFilePath)
+	if err != nil {
+		return minio.UploadInfo{}, fmt.Errorf("open file %s: %w", localFilePath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return minio.UploadInfo{}, fmt.Errorf("stat file %s: %w", localFilePath, err)
+	}
+
+	// opts sets Content-MD5, which makes the server reject corrupted
+	// requests (and parts of multipart uploads) before they are stored.
+	info, err := client.PutObject(ctx, p.bucket, remotePath, f, stat.Size(), opts)
+	if err != nil {
+		return minio.UploadInfo{}, fmt.Errorf("upload file %s: %w", localFilePath, err)
+	}
+	return info, nil
+}
+
+// verifyS3Upload compares the upload result with the local digest. A full-object
+// SHA-256 checksum is preferred when the provider returns one; otherwise the ETag
+// is compared with the MD5 for single-part uploads. Multipart ETags are not plain
+// digests, so only the size can be checked for them (parts are verified by
+// Content-MD5 during upload).
+func verifyS3Upload(remotePath string, info minio.UploadInfo, digest checksum.Digest) error {
+	if info.Size != digest.Size {
+		return &IntegrityError{
+			Path:     remotePath,
+			Expected: fmt.Sprintf("%d bytes", digest.Size),
+			Actual:   fmt.Sprintf("%d bytes", info.Size),
+		}
+	}
+
+	if info.ChecksumSHA256 != "" && !strings.Contains(info.ChecksumSHA256, "-") {
+		if want := digest.SHA256Base64(); info.ChecksumSHA256 != want {
+			return &IntegrityError{Path: remotePath, Expected: "sha256 " + want, Actual: "sha256 " + info.ChecksumSHA256}
+		}
+		return nil
+	}
+
+	etag := strings.Trim(info.ETag, `"`)
+	if etag != "" && !strings.Contains(etag, "-") {
+		if want := digest.MD5Hex(); !strings.EqualFold(etag, want) {
+			return &IntegrityError{Path: remotePath, Expected: "etag " + want, Actual: "etag " + etag}
 		}
 	}
 	return nil
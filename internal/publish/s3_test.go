@@ -0,0 +1,272 @@
+package publish
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// fakeS3 reports every bucket as existing, accepts single-part PutObject
+// requests and records their headers by object key.
+type fakeS3 struct {
+	mu      sync.Mutex
+	headers map[string]http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		return
+	}
+	if r.Method != http.MethodPut {
+		http.Error(w, "unexpected request", http.StatusNotImplemented)
+		return
+	}
+	// The body is aws-chunked over plain HTTP; answer with the Content-MD5
+	// the client computed, which is what verifyS3Upload compares
+	sum, err := base64.StdEncoding.DecodeString(r.Header.Get("Content-Md5"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, _ = io.Copy(io.Discard, r.Body)
+	f.mu.Lock()
+	f.headers[r.URL.Path] = r.Header.Clone()
+	f.mu.Unlock()
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum)+`"`)
+}
+
+func TestS3PublishHeaders(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("GCX_TEST_BUILD_URL", "https://ci.example.com/builds/42")
+	fake := &fakeS3{headers: make(map[string]http.Header)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app_linux_amd64.tar.gz"), []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	blob := config.BlobConfig{
+		Provider: "s3", Name: "s3", Bucket: "releases", Region: "us-east-1",
+		Endpoint:       srv.URL,
+		Directory:      "{{.Version}}",
+		ObjectTemplate: "{{.ShortSha256}}-{{.Name}}",
+		Metadata: map[string]string{
+			"version":   "{{.Version}}",
+			"build-url": "{{.Env.GCX_TEST_BUILD_URL}}",
+		},
+		ContentDispositionTemplate: `attachment; filename="{{.Name}}"`,
+	}
+	if err := blob.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewS3Publisher(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := config.ReleaseData{Version: "v1.0.0", Channel: "stable"}
+	if err := p.Publish(context.Background(), dir, rel, NewState(filepath.Join(dir, StateFileName), "v1.0.0")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if len(fake.headers) != 1 {
+		t.Fatalf("uploaded %d objects, want 1", len(fake.headers))
+	}
+	for key, h := range fake.headers {
+		if !strings.HasPrefix(key, "/releases/v1.0.0/") || !strings.HasSuffix(key, "-app_linux_amd64.tar.gz") {
+			t.Errorf("object key = %q", key)
+		}
+		if got := h.Get("Content-Disposition"); got != `attachment; filename="app_linux_amd64.tar.gz"` {
+			t.Errorf("Content-Disposition = %q", got)
+		}
+		if got := h.Get("X-Amz-Meta-Version"); got != "v1.0.0" {
+			t.Errorf("x-amz-meta-version = %q", got)
+		}
+		if got := h.Get("X-Amz-Meta-Build-Url"); got != "https://ci.example.com/builds/42" {
+			t.Errorf("x-amz-meta-build-url = %q", got)
+		}
+	}
+}
+
+// casS3 is a fake S3 that stores the size and ETag of PutObject requests
+// and serves them to HeadObject and server-side CopyObject requests.
+type casS3 struct {
+	mu      sync.Mutex
+	objects map[string]casObject
+	puts    []string
+	copies  []string
+}
+
+type casObject struct {
+	size int64
+	etag string
+}
+
+func (f *casS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodHead:
+		if strings.Count(strings.Trim(key, "/"), "/") == 0 {
+			return // the bucket
+		}
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.size, 10))
+		w.Header().Set("ETag", `"`+obj.etag+`"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	case http.MethodPut:
+		_, _ = io.Copy(io.Discard, r.Body)
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			obj, ok := f.objects["/"+strings.TrimPrefix(src, "/")]
+			if !ok {
+				http.Error(w, "no copy source "+src, http.StatusNotFound)
+				return
+			}
+			f.objects[key] = obj
+			f.copies = append(f.copies, key)
+			fmt.Fprintf(w, `<CopyObjectResult><LastModified>%s</LastModified><ETag>"%s"</ETag></CopyObjectResult>`,
+				time.Now().UTC().Format(time.RFC3339), obj.etag)
+			return
+		}
+		sum, err := base64.StdEncoding.DecodeString(r.Header.Get("Content-Md5"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		size, _ := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		f.objects[key] = casObject{size: size, etag: hex.EncodeToString(sum)}
+		f.puts = append(f.puts, key)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum)+`"`)
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.String(), http.StatusNotImplemented)
+	}
+}
+
+func TestS3PublishContentAddressed(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	fake := &casS3{objects: make(map[string]casObject)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app_linux_amd64.tar.gz"), []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	blob := config.BlobConfig{
+		Provider: "s3", Name: "s3", Bucket: "releases", Region: "us-east-1",
+		Endpoint:  srv.URL,
+		Directory: "{{.Version}}",
+		Dedupe:    config.DedupeContentAddressed,
+	}
+	if err := blob.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewS3Publisher(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish := func(version string) Transfer {
+		t.Helper()
+		state := NewState(filepath.Join(t.TempDir(), StateFileName), version)
+		rel := config.ReleaseData{Version: version}
+		if err := p.Publish(context.Background(), dir, rel, state); err != nil {
+			t.Fatalf("Publish(%s) error = %v", version, err)
+		}
+		transfer, ok := state.Transfer("s3")
+		if !ok {
+			t.Fatalf("Publish(%s) counted no transfer", version)
+		}
+		return transfer
+	}
+
+	if got := publish("v1.0.0"); got != (Transfer{Sent: 7}) {
+		t.Errorf("first transfer = %+v, want 7 bytes sent", got)
+	}
+	if got := publish("v1.0.1"); got != (Transfer{Referenced: 7}) {
+		t.Errorf("second transfer = %+v, want 7 bytes referenced", got)
+	}
+
+	if len(fake.puts) != 1 || !strings.HasPrefix(fake.puts[0], "/releases/cas/") {
+		t.Errorf("uploaded %v, want one cas/ object", fake.puts)
+	}
+	want := []string{"/releases/v1.0.0/app_linux_amd64.tar.gz", "/releases/v1.0.1/app_linux_amd64.tar.gz"}
+	if !slices.Equal(fake.copies, want) {
+		t.Errorf("copied %v, want %v", fake.copies, want)
+	}
+}
+
+func TestS3PublishManifestFiles(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	fake := &fakeS3{headers: make(map[string]http.Header)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"app_linux_amd64.tar.gz", "checksums.txt", "stray.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &manifest.Manifest{Artifacts: []manifest.Artifact{
+		{Name: "app_linux_amd64.tar.gz", Type: manifest.TypeArchive},
+		{Name: "checksums.txt", Type: manifest.TypeChecksum},
+	}}
+	if err := manifest.Write(filepath.Join(dir, manifest.FileName), m); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		types []string
+		want  []string
+	}{
+		// stray.txt is not listed in artifacts.json
+		{want: []string{"/releases/v1.0.0/app_linux_amd64.tar.gz", "/releases/v1.0.0/checksums.txt"}},
+		{types: []string{manifest.TypeChecksum}, want: []string{"/releases/v1.0.0/checksums.txt"}},
+	}
+	for _, tt := range tests {
+		clear(fake.headers)
+		blob := config.BlobConfig{
+			Provider: "s3", Name: "s3", Bucket: "releases", Region: "us-east-1",
+			Endpoint: srv.URL, Directory: "{{.Version}}", Types: tt.types,
+		}
+		p, err := NewS3Publisher(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := NewState(filepath.Join(t.TempDir(), StateFileName), "v1.0.0")
+		if err := p.Publish(context.Background(), dir, config.ReleaseData{Version: "v1.0.0"}, state); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if got := slices.Sorted(maps.Keys(fake.headers)); !slices.Equal(got, tt.want) {
+			t.Errorf("types %v: uploaded %v, want %v", tt.types, got, tt.want)
+		}
+	}
+}
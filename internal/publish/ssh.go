@@ -2,22 +2,44 @@ package publish
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/sxwebdev/gcx/internal/checksum"
 	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/metrics"
 	"github.com/sxwebdev/gcx/internal/shellutil"
 	"github.com/sxwebdev/gcx/internal/sshutil"
-	"github.com/sxwebdev/gcx/internal/tmpl"
 )
 
 // SSHPublisher uploads artifacts to a remote server via SSH/SFTP.
 type SSHPublisher struct {
-	name      string
-	sshCfg    sshutil.ClientConfig
-	directory string
+	name        string
+	sshCfg      sshutil.ClientConfig
+	maxAttempts int
+	// remoteDir renders and normalizes the directory template
+	remoteDir func(rel config.ReleaseData) (string, error)
+	// objectName renders the remote file name from the object_template
+	objectName func(name string, rel config.ReleaseData, shortSha256 string) (string, error)
+	// metadata uploads gcx metadata files (publish_metadata)
+	metadata bool
+	// builds limits the upload to the artifacts of these build ids
+	builds []string
+	// types limits the upload to the artifacts of these types
+	types []string
+	// maxObjectSize and oversize handle files the destination rejects
+	maxObjectSize int64
+	oversize      string
+
+	// client is the connection used by the Fetcher methods.
+	client sshutil.Client
 }
 
 // NewSSHPublisher creates an SSHPublisher from config.
@@ -30,24 +52,37 @@ func NewSSHPublisher(cfg config.BlobConfig) (*SSHPublisher, error) {
 			KeyPath:               cfg.KeyPath,
 			KeyRaw:                cfg.KeyRaw,
 			InsecureIgnoreHostKey: cfg.InsecureIgnoreHostKey,
+			Backend:               cfg.SSHBackend,
+			SFTPConcurrency:       cfg.SFTPConcurrency,
+			SFTPBufferSize:        int(cfg.SFTPBufferSize.Bytes()),
+			SFTPFallback:          cfg.SFTPFallback,
 		},
-		directory: cfg.Directory,
+		remoteDir:     cfg.RemoteDir,
+		maxAttempts:   cfg.MaxAttempts,
+		objectName:    cfg.ObjectName,
+		metadata:      cfg.PublishMetadata,
+		builds:        cfg.Builds,
+		types:         cfg.Types,
+		maxObjectSize: cfg.MaxObjectSize.Bytes(),
+		oversize:      cfg.Oversize,
 	}, nil
 }
 
 func (p *SSHPublisher) Name() string { return p.name }
 
-func (p *SSHPublisher) Publish(_ context.Context, artifactsDir string, version string) error {
-	remoteDir, err := tmpl.Process("directory", p.directory, map[string]string{"Version": version})
+func (p *SSHPublisher) Publish(ctx context.Context, artifactsDir string, rel config.ReleaseData, state *State) error {
+	remoteDir, err := p.remoteDir(rel)
 	if err != nil {
-		return fmt.Errorf("process directory template: %w", err)
+		return err
 	}
 
-	client, err := sshutil.NewClient(p.sshCfg)
+	client, err := sshutil.NewClient(ctx, p.sshCfg)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = client.Close() }()
+	// Unblocks a stuck transfer when the publish stage is cancelled
+	defer sshutil.CloseOnDone(ctx, client)()
 
 	// Shell-safe mkdir (fixes command injection vulnerability)
 	if _, err := client.Run("mkdir -p " + shellutil.Quote(remoteDir)); err != nil {
@@ -58,19 +93,147 @@ func (p *SSHPublisher) Publish(_ context.Context, artifactsDir string, version s
 	if err != nil {
 		return fmt.Errorf("read directory %s: %w", artifactsDir, err)
 	}
+	selected, err := fileFilter(p.name, artifactsDir, files, p.builds, p.types, p.metadata)
+	if err != nil {
+		return err
+	}
 
-	for _, file := range files {
-		if file.IsDir() {
+	ov := newOversize(p.name, p.maxObjectSize, p.oversize)
+	defer func() { _ = ov.Close() }()
+	uploads, err := selectFiles(artifactsDir, files, selected, ov, state)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range uploads {
+		if state.Has(p.name, file.name) {
+			log.Printf("Skipping %s: already published to %s", file.name, p.name)
 			continue
 		}
-		localFilePath := filepath.Join(artifactsDir, file.Name())
-		remotePath := filepath.Join(remoteDir, file.Name())
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		localFilePath := file.path
+		digest, err := localDigests.File(localFilePath)
+		if err != nil {
+			return fmt.Errorf("checksum file %s: %w", localFilePath, err)
+		}
+		objectName, err := p.objectName(file.name, rel, digest.ShortSHA256())
+		if err != nil {
+			return err
+		}
+		remotePath := filepath.Join(remoteDir, objectName)
 		log.Printf("Uploading %s to %s:%s", localFilePath, p.sshCfg.Server, remotePath)
 
-		if err := client.Upload(localFilePath, remotePath); err != nil {
-			return fmt.Errorf("upload file %s: %w", localFilePath, err)
+		start := time.Now()
+		err = uploadVerified(remotePath, p.maxAttempts,
+			func() error {
+				if err := client.Upload(localFilePath, remotePath); err != nil {
+					return interrupted(ctx, p.sshCfg.Server+":"+remotePath, fmt.Errorf("upload file %s: %w", localFilePath, err))
+				}
+				return nil
+			},
+			func() error { return verifySSHUpload(client, remotePath, digest) },
+			func() error {
+				_, err := client.Run("rm -f " + shellutil.Quote(remotePath))
+				return err
+			},
+		)
+		if err != nil {
+			return err
+		}
+		metrics.ObserveUpload("ssh", p.name, digest.Size, time.Since(start))
+		if err := state.Record(p.name, file.name); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+func (p *SSHPublisher) fetchClient(ctx context.Context) (sshutil.Client, error) {
+	if p.client != nil {
+		return p.client, nil
+	}
+	client, err := sshutil.NewClient(ctx, p.sshCfg)
+	if err != nil {
+		return nil, err
+	}
+	p.client = client
+	return client, nil
+}
+
+// List returns the entries directly under dir on the remote server.
+func (p *SSHPublisher) List(ctx context.Context, dir string) ([]RemoteFile, error) {
+	client, err := p.fetchClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sshutil.CloseOnDone(ctx, client)()
+
+	entries, err := client.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list %s:%s: %w", p.sshCfg.Server, dir, err)
+	}
+
+	files := make([]RemoteFile, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, RemoteFile{
+			Name:    e.Name(),
+			Path:    path.Join(dir, e.Name()),
+			Size:    e.Size(),
+			ModTime: e.ModTime(),
+			IsDir:   e.IsDir(),
+		})
+	}
+	return files, nil
+}
+
+// Download copies remotePath from the server to localPath.
+func (p *SSHPublisher) Download(ctx context.Context, remotePath, localPath string) error {
+	client, err := p.fetchClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer sshutil.CloseOnDone(ctx, client)()
+	if err := client.Download(remotePath, localPath); err != nil {
+		return fmt.Errorf("download %s:%s: %w", p.sshCfg.Server, remotePath, err)
+	}
+	return nil
+}
+
+// Close closes the connection opened by the Fetcher methods.
+func (p *SSHPublisher) Close() error {
+	if p.client == nil {
+		return nil
+	}
+	err := p.client.Close()
+	p.client = nil
+	return err
+}
+
+// remoteRunner runs a command on a remote host and returns its output.
+type remoteRunner interface {
+	Run(cmd string) ([]byte, error)
+}
+
+// verifySSHUpload compares the sha256sum of the remote file with the local digest.
+func verifySSHUpload(client remoteRunner, remotePath string, digest checksum.Digest) error {
+	out, err := client.Run("sha256sum " + shellutil.Quote(remotePath))
+	if err != nil {
+		return fmt.Errorf("compute remote checksum of %s: %w", remotePath, err)
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return fmt.Errorf("compute remote checksum of %s: empty sha256sum output", remotePath)
+	}
+
+	if want := digest.SHA256Hex(); !strings.EqualFold(fields[0], want) {
+		return &IntegrityError{Path: remotePath, Expected: "sha256 " + want, Actual: "sha256 " + fields[0]}
+	}
+	return nil
+}
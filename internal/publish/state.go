@@ -0,0 +1,141 @@
+package publish
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// StateFileName is the publish state file written to the artifacts directory.
+const StateFileName = "publish-state.json"
+
+// State records which artifacts were uploaded to which destination, so an
+// interrupted or partially failed publish can be resumed.
+type State struct {
+	mu   sync.Mutex
+	path string
+
+	Version string `json:"version"`
+	// Done maps a destination name to the artifact names uploaded to it.
+	Done map[string][]string `json:"done"`
+
+	// oversize maps a destination name to the files of this run that
+	// exceeded its max_object_size; it is not saved.
+	oversize map[string][]string
+	// transfers maps a content-addressed destination to the bytes of
+	// this run; it is not saved.
+	transfers map[string]Transfer
+}
+
+// Transfer counts the bytes of the files published to a content-addressed
+// destination.
+type Transfer struct {
+	// Sent is the size of the contents uploaded.
+	Sent int64
+	// Referenced is the size of the contents the destination already
+	// stored, which were copied server-side.
+	Referenced int64
+}
+
+// NewState returns an empty state for version that is saved to path.
+func NewState(path, version string) *State {
+	return &State{path: path, Version: version, Done: make(map[string][]string)}
+}
+
+// LoadState reads the state at path. A missing file, or one written for
+// another version, yields an empty state.
+func LoadState(path, version string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewState(path, version), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read publish state: %w", err)
+	}
+
+	s := NewState(path, version)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse publish state %s: %w", path, err)
+	}
+	if s.Version != version || s.Done == nil {
+		return NewState(path, version), nil
+	}
+	return s, nil
+}
+
+// Has reports whether name was uploaded to destination.
+func (s *State) Has(destination, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.Done[destination], name)
+}
+
+// Count returns the number of artifacts uploaded to destination.
+func (s *State) Count(destination string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Done[destination])
+}
+
+// Record marks name as uploaded to destination and saves the state, so the
+// file is accurate even if the process is killed mid-publish.
+func (s *State) Record(destination, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.Done[destination], name) {
+		s.Done[destination] = append(s.Done[destination], name)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal publish state: %w", err)
+	}
+	if err := os.WriteFile(s.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write publish state: %w", err)
+	}
+	return nil
+}
+
+// NoteOversize records that a file exceeded the max_object_size of
+// destination, e.g. "debug.tar.gz (2.4 GiB) skipped".
+func (s *State) NoteOversize(destination, note string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oversize == nil {
+		s.oversize = make(map[string][]string)
+	}
+	s.oversize[destination] = append(s.oversize[destination], note)
+}
+
+// Oversize returns the notes of NoteOversize for destination.
+func (s *State) Oversize(destination string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.oversize[destination]
+}
+
+// AddTransfer adds the bytes of one file published to the
+// content-addressed destination.
+func (s *State) AddTransfer(destination string, sent, referenced int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transfers == nil {
+		s.transfers = make(map[string]Transfer)
+	}
+	t := s.transfers[destination]
+	t.Sent += sent
+	t.Referenced += referenced
+	s.transfers[destination] = t
+}
+
+// Transfer returns the bytes AddTransfer counted for destination, and
+// false when it counted none.
+func (s *State) Transfer(destination string) (Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[destination]
+	return t, ok
+}
@@ -0,0 +1,184 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/inject"
+)
+
+func TestStateLoadRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StateFileName)
+
+	state, err := LoadState(path, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Record("s3", "app.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadState(path, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Has("s3", "app.tar.gz") || loaded.Has("ssh", "app.tar.gz") {
+		t.Errorf("unexpected state: %+v", loaded.Done)
+	}
+
+	other, err := LoadState(path, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Count("s3") != 0 {
+		t.Error("state of another version should be ignored")
+	}
+}
+
+// fakePublisher records every artifact and fails once failures reaches zero.
+type fakePublisher struct {
+	name     string
+	failures *int
+}
+
+func (p *fakePublisher) Name() string { return p.name }
+
+func (p *fakePublisher) Publish(_ context.Context, artifactsDir string, _ config.ReleaseData, state *State) error {
+	files, err := os.ReadDir(artifactsDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !publishable(file, false) || state.Has(p.name, file.Name()) {
+			continue
+		}
+		if *p.failures > 0 {
+			*p.failures--
+			return errors.New("connection reset")
+		}
+		if err := state.Record(p.name, file.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRunPartialFailureAndResume(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.tar.gz", "b.tar.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failures := map[string]*int{"one": new(int), "two": new(int), "three": new(int)}
+	*failures["two"] = 1
+	newPublisher = func(cfg config.BlobConfig) (Publisher, error) {
+		return &fakePublisher{name: cfg.Name, failures: failures[cfg.Name]}, nil
+	}
+	defer func() { newPublisher = NewPublisher }()
+
+	cfg := &config.Config{OutDir: dir, Blobs: []config.BlobConfig{{Name: "one"}, {Name: "two"}, {Name: "three"}}}
+	err := Run(context.Background(), cfg, "", Options{})
+	if err == nil || !strings.Contains(err.Error(), `publish "two"`) || !strings.Contains(err.Error(), "1 of 3") {
+		t.Fatalf("Run() error = %v, want failure of two only", err)
+	}
+
+	// The state is keyed by the current git tag, so inspect the file as-is
+	data, err := os.ReadFile(filepath.Join(dir, StateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"three"`) {
+		t.Errorf("destination after the failure was not attempted: %s", data)
+	}
+
+	if err := Run(context.Background(), cfg, "", Options{Resume: true}); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+}
+
+func TestRunInjectedFailure(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.tar.gz"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newPublisher = func(cfg config.BlobConfig) (Publisher, error) {
+		return &fakePublisher{name: cfg.Name, failures: new(int)}, nil
+	}
+	defer func() { newPublisher = NewPublisher }()
+	inject.Set(&inject.Point{Stage: "publish", Target: "two"})
+	defer inject.Set(nil)
+
+	// The injected failure is reported like the real one in
+	// TestRunPartialFailureAndResume
+	cfg := &config.Config{OutDir: dir, Blobs: []config.BlobConfig{{Name: "one"}, {Name: "two"}, {Name: "three"}}}
+	err := Run(context.Background(), cfg, "", Options{})
+	if !errors.Is(err, inject.ErrInjected) || !strings.Contains(err.Error(), `publish "two"`) || !strings.Contains(err.Error(), "1 of 3") {
+		t.Fatalf("Run() error = %v, want an injected failure of two only", err)
+	}
+	if !strings.Contains(err.Error(), "injected failure at publish:two") {
+		t.Errorf("Run() error = %v, want the failure labeled as injected", err)
+	}
+
+	if err := Run(context.Background(), cfg, "", Options{Resume: true}); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+}
+
+func TestRunDisabled(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.tar.gz"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var published []string
+	newPublisher = func(cfg config.BlobConfig) (Publisher, error) {
+		published = append(published, cfg.Name)
+		return &fakePublisher{name: cfg.Name, failures: new(int)}, nil
+	}
+	defer func() { newPublisher = NewPublisher }()
+
+	cfg := &config.Config{OutDir: dir, Channel: "nightly", Blobs: []config.BlobConfig{
+		{Name: "releases", Enabled: `{{eq .Channel "stable"}}`},
+		{Name: "nightlies", Enabled: `{{eq .Channel "nightly"}}`},
+	}}
+	if err := Run(context.Background(), cfg, "", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0] != "nightlies" {
+		t.Errorf("published to %v, want nightlies only", published)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	var sb strings.Builder
+	err := WriteSummary(&sb, "beta", []Result{
+		{Destination: "s3", Uploaded: 2},
+		{Destination: "ssh", Uploaded: 1, Skipped: 1, Err: errors.New("connection reset")},
+		{Destination: "cdn", Disabled: true},
+		{Destination: "store", Uploaded: 4, Oversize: []string{"debug.tar.gz (2.4 GiB) split into 2 parts"}},
+		{Destination: "cas", Uploaded: 3, Transfer: &Transfer{Sent: 2048, Referenced: 3 << 20}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != 11 || lines[0] != "Channel: beta" || !strings.HasPrefix(lines[1], "DESTINATION") {
+		t.Fatalf("unexpected summary:\n%s", sb.String())
+	}
+	if !strings.Contains(lines[3], "FAILED: connection reset") || !strings.Contains(lines[2], "ok") || !strings.Contains(lines[4], "disabled") {
+		t.Errorf("unexpected status lines:\n%s", sb.String())
+	}
+	if lines[7] != "Over max_object_size:" || lines[8] != "  store: debug.tar.gz (2.4 GiB) split into 2 parts" {
+		t.Errorf("unexpected oversize lines:\n%s", sb.String())
+	}
+	if lines[9] != "Content addressed:" || lines[10] != "  cas: sent 2.0 KiB, referenced 3.0 MiB" {
+		t.Errorf("unexpected content addressed lines:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,65 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PartialUploadError reports an upload interrupted by context cancellation.
+// The remote object may exist in an incomplete state.
+type PartialUploadError struct {
+	Path string
+	Err  error
+}
+
+func (e *PartialUploadError) Error() string {
+	return fmt.Sprintf("upload %s interrupted: %v", e.Path, e.Err)
+}
+
+func (e *PartialUploadError) Unwrap() error { return e.Err }
+
+// TimeoutError is returned when the publish stage exceeds publish.timeout.
+type TimeoutError struct {
+	After       time.Duration
+	Destination string
+	// Partial lists remote objects that may be incompletely uploaded.
+	Partial []string
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("publish timed out after %s while publishing to %q", e.After, e.Destination)
+	for i, p := range e.Partial {
+		if i == 0 {
+			msg += "; partially uploaded: "
+		} else {
+			msg += ", "
+		}
+		msg += p
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+func newTimeoutError(after time.Duration, destination string, err error) *TimeoutError {
+	timeoutErr := &TimeoutError{After: after, Destination: destination, Err: err}
+	var partial *PartialUploadError
+	if errors.As(err, &partial) {
+		timeoutErr.Partial = append(timeoutErr.Partial, partial.Path)
+		log.Printf("Warning: %s may be partially uploaded", partial.Path)
+	}
+	return timeoutErr
+}
+
+// interrupted wraps err as a PartialUploadError when ctx was cancelled during
+// the upload of remotePath.
+func interrupted(ctx context.Context, remotePath string, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return &PartialUploadError{Path: remotePath, Err: ctx.Err()}
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterrupted(t *testing.T) {
+	uploadErr := errors.New("connection closed")
+
+	if err := interrupted(context.Background(), "s3://b/k", uploadErr); err != uploadErr {
+		t.Errorf("live context: got %v, want original error", err)
+	}
+	if err := interrupted(context.Background(), "s3://b/k", nil); err != nil {
+		t.Errorf("nil error: got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err := interrupted(ctx, "s3://b/k", uploadErr)
+	var partial *PartialUploadError
+	if !errors.As(err, &partial) || partial.Path != "s3://b/k" {
+		t.Fatalf("expected PartialUploadError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("PartialUploadError should unwrap to the context error")
+	}
+}
+
+func TestTimeoutError(t *testing.T) {
+	err := fmt.Errorf("upload: %w", &PartialUploadError{Path: "host:/srv/app.tar.gz", Err: context.DeadlineExceeded})
+	timeoutErr := newTimeoutError(5*time.Minute, "prod", err)
+
+	want := `publish timed out after 5m0s while publishing to "prod"; partially uploaded: host:/srv/app.tar.gz`
+	if timeoutErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", timeoutErr.Error(), want)
+	}
+	if !errors.Is(timeoutErr, context.DeadlineExceeded) {
+		t.Error("TimeoutError should unwrap to context.DeadlineExceeded")
+	}
+
+	plain := newTimeoutError(time.Second, "s3", context.DeadlineExceeded)
+	if len(plain.Partial) != 0 || strings.Contains(plain.Error(), "partially") {
+		t.Errorf("unexpected partial uploads: %q", plain.Error())
+	}
+}
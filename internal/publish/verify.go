@@ -0,0 +1,61 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+)
+
+// defaultMaxAttempts is the number of upload attempts made when an
+// uploaded file fails the integrity check and max_attempts is not set.
+const defaultMaxAttempts = 3
+
+// localDigests caches digests of local artifacts across all destinations of a run.
+var localDigests = checksum.NewCache()
+
+// IntegrityError reports that an uploaded object does not match the local file.
+type IntegrityError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed for %s: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// uploadVerified runs upload followed by verify. When verification reports an
+// IntegrityError, the remote object is removed and the upload is retried until
+// maxAttempts is reached. Any other error is returned immediately.
+func uploadVerified(remotePath string, maxAttempts int, upload, verify, remove func() error) error {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := upload(); err != nil {
+			return err
+		}
+
+		err := verify()
+		if err == nil {
+			return nil
+		}
+
+		var integrityErr *IntegrityError
+		if !errors.As(err, &integrityErr) {
+			return err
+		}
+		lastErr = err
+
+		log.Printf("Warning: %v (attempt %d/%d)", err, attempt, maxAttempts)
+		if err := remove(); err != nil {
+			log.Printf("Warning: failed to remove corrupted remote object %s: %v", remotePath, err)
+		}
+	}
+
+	return fmt.Errorf("upload %s: giving up after %d attempt(s): %w", remotePath, maxAttempts, lastErr)
+}
@@ -0,0 +1,158 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/sxwebdev/gcx/internal/checksum"
+)
+
+func TestUploadVerified(t *testing.T) {
+	mismatch := &IntegrityError{Path: "f", Expected: "a", Actual: "b"}
+
+	t.Run("success on first attempt", func(t *testing.T) {
+		var uploads, removes int
+		err := uploadVerified("f", 3,
+			func() error { uploads++; return nil },
+			func() error { return nil },
+			func() error { removes++; return nil },
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if uploads != 1 || removes != 0 {
+			t.Errorf("uploads = %d, removes = %d, want 1, 0", uploads, removes)
+		}
+	})
+
+	t.Run("retries after mismatch", func(t *testing.T) {
+		var uploads, removes int
+		err := uploadVerified("f", 3,
+			func() error { uploads++; return nil },
+			func() error {
+				if uploads < 2 {
+					return mismatch
+				}
+				return nil
+			},
+			func() error { removes++; return nil },
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if uploads != 2 || removes != 1 {
+			t.Errorf("uploads = %d, removes = %d, want 2, 1", uploads, removes)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var uploads, removes int
+		err := uploadVerified("f", 2,
+			func() error { uploads++; return nil },
+			func() error { return mismatch },
+			func() error { removes++; return errors.New("remove failed") },
+		)
+		var integrityErr *IntegrityError
+		if !errors.As(err, &integrityErr) {
+			t.Fatalf("expected IntegrityError, got %v", err)
+		}
+		if uploads != 2 || removes != 2 {
+			t.Errorf("uploads = %d, removes = %d, want 2, 2", uploads, removes)
+		}
+	})
+
+	t.Run("upload error is not retried", func(t *testing.T) {
+		var uploads int
+		err := uploadVerified("f", 3,
+			func() error { uploads++; return errors.New("network down") },
+			func() error { return nil },
+			func() error { return nil },
+		)
+		if err == nil || uploads != 1 {
+			t.Errorf("err = %v, uploads = %d, want error and 1 upload", err, uploads)
+		}
+	})
+
+	t.Run("default attempts", func(t *testing.T) {
+		var uploads int
+		_ = uploadVerified("f", 0,
+			func() error { uploads++; return nil },
+			func() error { return mismatch },
+			func() error { return nil },
+		)
+		if uploads != defaultMaxAttempts {
+			t.Errorf("uploads = %d, want %d", uploads, defaultMaxAttempts)
+		}
+	})
+}
+
+func TestVerifyS3Upload(t *testing.T) {
+	digest := checksum.Digest{
+		Size:   11,
+		MD5:    []byte{0x5e, 0xb6, 0x3b, 0xbb, 0xe0, 0x1e, 0xee, 0xd0, 0x93, 0xcb, 0x22, 0xbb, 0x8f, 0x5a, 0xcd, 0xc3},
+		SHA256: make([]byte, 32),
+	}
+
+	tests := []struct {
+		name    string
+		info    minio.UploadInfo
+		wantErr bool
+	}{
+		{name: "matching etag", info: minio.UploadInfo{Size: 11, ETag: `"5eb63bbbe01eeed093cb22bb8f5acdc3"`}},
+		{name: "mismatching etag", info: minio.UploadInfo{Size: 11, ETag: "00000000000000000000000000000000"}, wantErr: true},
+		{name: "size mismatch", info: minio.UploadInfo{Size: 10, ETag: "5eb63bbbe01eeed093cb22bb8f5acdc3"}, wantErr: true},
+		{name: "multipart etag", info: minio.UploadInfo{Size: 11, ETag: "abcdef-3"}},
+		{name: "matching sha256", info: minio.UploadInfo{Size: 11, ChecksumSHA256: digest.SHA256Base64(), ETag: "bogus"}},
+		{name: "mismatching sha256", info: minio.UploadInfo{Size: 11, ChecksumSHA256: "AAAA"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyS3Upload("releases/app.tar.gz", tt.info, digest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyS3Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type fakeRunner struct {
+	out []byte
+	err error
+	cmd string
+}
+
+func (f *fakeRunner) Run(cmd string) ([]byte, error) {
+	f.cmd = cmd
+	return f.out, f.err
+}
+
+func TestVerifySSHUpload(t *testing.T) {
+	digest := checksum.Digest{SHA256: []byte{0xab, 0xcd}}
+
+	t.Run("match", func(t *testing.T) {
+		r := &fakeRunner{out: []byte("abcd  /srv/app's.tar.gz\n")}
+		if err := verifySSHUpload(r, "/srv/app's.tar.gz", digest); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if want := `sha256sum '/srv/app'\''s.tar.gz'`; r.cmd != want {
+			t.Errorf("cmd = %q, want %q", r.cmd, want)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		r := &fakeRunner{out: []byte("ffff  /srv/app.tar.gz\n")}
+		var integrityErr *IntegrityError
+		if err := verifySSHUpload(r, "/srv/app.tar.gz", digest); !errors.As(err, &integrityErr) {
+			t.Errorf("expected IntegrityError, got %v", err)
+		}
+	})
+
+	t.Run("command failure", func(t *testing.T) {
+		r := &fakeRunner{err: fmt.Errorf("sha256sum: not found")}
+		if err := verifySSHUpload(r, "/srv/app.tar.gz", digest); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
@@ -0,0 +1,239 @@
+// Package release compares a build with a previously published release.
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+// Artifact statuses.
+const (
+	StatusAdded     = "added"
+	StatusRemoved   = "removed"
+	StatusChanged   = "changed"
+	StatusUnchanged = "unchanged"
+)
+
+// ArtifactDiff compares one artifact across two releases.
+type ArtifactDiff struct {
+	// Name is the current name, or the previous one for removed artifacts.
+	Name     string `json:"name"`
+	Platform string `json:"platform,omitempty"`
+	Status   string `json:"status"`
+	OldSize  int64  `json:"old_size"`
+	NewSize  int64  `json:"new_size"`
+	// Delta is NewSize - OldSize.
+	Delta int64 `json:"delta"`
+}
+
+// DependencyDiff is a module added, removed or upgraded between releases.
+// Old is empty for added and New for removed modules.
+type DependencyDiff struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Diff is the difference between the current build and a previous release.
+type Diff struct {
+	Current  string `json:"current"`
+	Previous string `json:"previous"`
+	// SizeOnly is set when the previous release has no published manifest,
+	// so artifacts are matched by name and compared by size only.
+	SizeOnly     bool           `json:"size_only"`
+	Artifacts    []ArtifactDiff `json:"artifacts"`
+	OldGoVersion string         `json:"old_go_version,omitempty"`
+	NewGoVersion string         `json:"new_go_version,omitempty"`
+	// Dependencies is nil when the previous go.mod is unavailable.
+	Dependencies []DependencyDiff `json:"dependencies"`
+}
+
+// Compare matches the artifacts of cur with those of prev. Names are
+// compared with the version replaced, so myapp_v1.3.0_linux_amd64.tar.gz
+// matches myapp_v1.4.0_linux_amd64.tar.gz. sizeOnly marks prev as built
+// from a remote listing without checksums or platforms.
+func Compare(prev, cur *manifest.Manifest, sizeOnly bool) Diff {
+	d := Diff{
+		Current:      cur.Version,
+		Previous:     prev.Version,
+		SizeOnly:     sizeOnly,
+		Artifacts:    []ArtifactDiff{},
+		OldGoVersion: prev.GoVersion,
+		NewGoVersion: cur.GoVersion,
+		Dependencies: []DependencyDiff{},
+	}
+
+	previous := make(map[string]manifest.Artifact, len(prev.Artifacts))
+	for _, a := range prev.Artifacts {
+		previous[versionless(a.Name, prev.Version)] = a
+	}
+
+	for _, a := range cur.Artifacts {
+		key := versionless(a.Name, cur.Version)
+		entry := ArtifactDiff{Name: a.Name, Platform: platform(a), Status: StatusAdded, NewSize: a.Size, Delta: a.Size}
+		if old, ok := previous[key]; ok {
+			delete(previous, key)
+			entry.OldSize = old.Size
+			entry.Delta = a.Size - old.Size
+			entry.Status = StatusUnchanged
+			if old.Size != a.Size || (!sizeOnly && old.SHA256 != "" && old.SHA256 != a.SHA256) {
+				entry.Status = StatusChanged
+			}
+		}
+		d.Artifacts = append(d.Artifacts, entry)
+	}
+
+	// Removed artifacts follow in the order of the previous manifest
+	for _, a := range prev.Artifacts {
+		if _, ok := previous[versionless(a.Name, prev.Version)]; ok {
+			d.Artifacts = append(d.Artifacts, ArtifactDiff{Name: a.Name, Platform: platform(a), Status: StatusRemoved, OldSize: a.Size, Delta: -a.Size})
+		}
+	}
+	return d
+}
+
+// DependencyChanges lists the modules whose required version differs
+// between two go.mod require sets, sorted by module path.
+func DependencyChanges(prev, cur map[string]string) []DependencyDiff {
+	paths := slices.Sorted(maps.Keys(cur))
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	changes := []DependencyDiff{}
+	for _, path := range paths {
+		if prev[path] != cur[path] {
+			changes = append(changes, DependencyDiff{Path: path, Old: prev[path], New: cur[path]})
+		}
+	}
+	return changes
+}
+
+// versionless replaces version, with and without its "v" prefix, in name.
+func versionless(name, version string) string {
+	if version == "" {
+		return name
+	}
+	name = strings.ReplaceAll(name, version, "{version}")
+	if bare := strings.TrimPrefix(version, "v"); bare != version {
+		name = strings.ReplaceAll(name, bare, "{version}")
+	}
+	return name
+}
+
+func platform(a manifest.Artifact) string {
+	switch {
+	case a.Goos == "":
+		return ""
+	case a.Goarm != "":
+		return fmt.Sprintf("%s/%s/arm%s", a.Goos, a.Goarch, a.Goarm)
+	case a.Variant != "":
+		return a.Goos + "/" + a.Goarch + "/" + a.Variant
+	default:
+		return a.Goos + "/" + a.Goarch
+	}
+}
+
+// WriteTable prints d for humans.
+func WriteTable(w io.Writer, d Diff) error {
+	fmt.Fprintf(w, "Comparing %s with %s\n", d.Current, d.Previous)
+	if d.SizeOnly {
+		fmt.Fprintf(w, "%s has no published %s; comparing file sizes only\n", d.Previous, manifest.FileName)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ARTIFACT\tPLATFORM\tSTATUS\tSIZE\tDELTA")
+	for _, a := range d.Artifacts {
+		size := helpers.FormatBytes(a.NewSize)
+		if a.Status == StatusRemoved {
+			size = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Name, orDash(a.Platform), a.Status, size, formatDelta(a))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	switch {
+	case d.OldGoVersion == d.NewGoVersion:
+		fmt.Fprintf(w, "Go: %s (unchanged)\n", orUnknown(d.NewGoVersion))
+	default:
+		fmt.Fprintf(w, "Go: %s -> %s\n", orUnknown(d.OldGoVersion), orUnknown(d.NewGoVersion))
+	}
+
+	switch {
+	case d.Dependencies == nil:
+		fmt.Fprintf(w, "Dependencies: unknown (go.mod of %s not available)\n", d.Previous)
+		return nil
+	case len(d.Dependencies) == 0:
+		fmt.Fprintln(w, "Dependencies: unchanged")
+		return nil
+	}
+	fmt.Fprintln(w, "Dependencies:")
+	for _, dep := range d.Dependencies {
+		switch {
+		case dep.Old == "":
+			fmt.Fprintf(w, "  + %s %s\n", dep.Path, dep.New)
+		case dep.New == "":
+			fmt.Fprintf(w, "  - %s %s\n", dep.Path, dep.Old)
+		default:
+			fmt.Fprintf(w, "  ~ %s %s -> %s\n", dep.Path, dep.Old, dep.New)
+		}
+	}
+	return nil
+}
+
+// WriteJSON prints d as indented JSON.
+func WriteJSON(w io.Writer, d Diff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return fmt.Errorf("encode release diff: %w", err)
+	}
+	return nil
+}
+
+func formatDelta(a ArtifactDiff) string {
+	delta := a.Delta
+	switch {
+	case a.Status == StatusAdded || a.Status == StatusRemoved:
+		return "-"
+	case delta == 0:
+		return "0 B"
+	}
+	sign, abs := "+", delta
+	if delta < 0 {
+		sign, abs = "-", -delta
+	}
+	if a.OldSize == 0 {
+		return sign + helpers.FormatBytes(abs)
+	}
+	return fmt.Sprintf("%s%s (%s%.1f%%)", sign, helpers.FormatBytes(abs), sign, float64(abs)*100/float64(a.OldSize))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
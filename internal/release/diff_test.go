@@ -0,0 +1,85 @@
+package release
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sxwebdev/gcx/internal/manifest"
+)
+
+func TestCompare(t *testing.T) {
+	prev := &manifest.Manifest{
+		Version:   "v1.3.0",
+		GoVersion: "1.22.3",
+		Artifacts: []manifest.Artifact{
+			{Name: "app_v1.3.0_linux_amd64.tar.gz", Goos: "linux", Goarch: "amd64", Size: 1000, SHA256: "aa"},
+			{Name: "app_1.3.0_darwin_arm64.tar.gz", Goos: "darwin", Goarch: "arm64", Size: 900, SHA256: "bb"},
+			{Name: "app_v1.3.0_windows_386.zip", Goos: "windows", Goarch: "386", Size: 800},
+		},
+	}
+	cur := &manifest.Manifest{
+		Version:   "v1.4.0",
+		GoVersion: "1.23.1",
+		Artifacts: []manifest.Artifact{
+			{Name: "app_v1.4.0_linux_amd64.tar.gz", Goos: "linux", Goarch: "amd64", Size: 1100, SHA256: "cc"},
+			{Name: "app_1.4.0_darwin_arm64.tar.gz", Goos: "darwin", Goarch: "arm64", Size: 900, SHA256: "bb"},
+			{Name: "app_v1.4.0_linux_arm_7.tar.gz", Goos: "linux", Goarch: "arm", Goarm: "7", Size: 700},
+		},
+	}
+
+	d := Compare(prev, cur, false)
+	type row struct {
+		name, platform, status string
+		delta                  int64
+	}
+	var got []row
+	for _, a := range d.Artifacts {
+		got = append(got, row{a.Name, a.Platform, a.Status, a.Delta})
+	}
+	want := []row{
+		{"app_v1.4.0_linux_amd64.tar.gz", "linux/amd64", StatusChanged, 100},
+		{"app_1.4.0_darwin_arm64.tar.gz", "darwin/arm64", StatusUnchanged, 0},
+		{"app_v1.4.0_linux_arm_7.tar.gz", "linux/arm/arm7", StatusAdded, 700},
+		{"app_v1.3.0_windows_386.zip", "windows/386", StatusRemoved, -800},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("artifacts =\n%v\nwant\n%v", got, want)
+	}
+
+	var buf bytes.Buffer
+	d.Dependencies = nil
+	if err := WriteTable(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"Comparing v1.4.0 with v1.3.0", "+100 B (+10.0%)", "Go: 1.22.3 -> 1.23.1", "Dependencies: unknown"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("table missing %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestCompareSizeOnly(t *testing.T) {
+	prev := &manifest.Manifest{Version: "v1.3.0", Artifacts: []manifest.Artifact{{Name: "app_v1.3.0.tar.gz", Size: 10}}}
+	cur := &manifest.Manifest{Version: "v1.4.0", Artifacts: []manifest.Artifact{{Name: "app_v1.4.0.tar.gz", Size: 10, SHA256: "aa"}}}
+	d := Compare(prev, cur, true)
+	if !d.SizeOnly || d.Artifacts[0].Status != StatusUnchanged {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestDependencyChanges(t *testing.T) {
+	prev := map[string]string{"a.example/x": "v1.0.0", "b.example/y": "v0.1.0", "c.example/z": "v2.0.0"}
+	cur := map[string]string{"a.example/x": "v1.1.0", "c.example/z": "v2.0.0", "d.example/w": "v0.0.1"}
+	got := DependencyChanges(prev, cur)
+	want := []DependencyDiff{
+		{Path: "a.example/x", Old: "v1.0.0", New: "v1.1.0"},
+		{Path: "b.example/y", Old: "v0.1.0"},
+		{Path: "d.example/w", New: "v0.0.1"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("DependencyChanges() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sxwebdev/gcx/internal/artifacts"
+	"github.com/sxwebdev/gcx/internal/channel"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/git"
+	"github.com/sxwebdev/gcx/internal/manifest"
+	"github.com/sxwebdev/gcx/internal/toolchain"
+)
+
+// Options selects the release to compare the current build with.
+type Options struct {
+	// Against is the previous version; it defaults to the previous git tag.
+	Against string
+	// From is a local artifacts.json, or a directory holding one.
+	From string
+	// Blob is the destination the previous release was published to. It
+	// is used when From is empty.
+	Blob *config.BlobConfig
+}
+
+// Run compares the build of the current git tag in out_dir with the
+// previous release. Dependencies are nil when the go.mod of the previous
+// tag cannot be read.
+func Run(ctx context.Context, cfg *config.Config, opts Options) (Diff, error) {
+	current := git.GetTag(ctx)
+	outDir, err := cfg.OutputDir(current)
+	if err != nil {
+		return Diff{}, err
+	}
+	cur, err := manifest.Load(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		return Diff{}, fmt.Errorf("load current build (run gcx build first): %w", err)
+	}
+
+	against := opts.Against
+	if against == "" {
+		against = git.GetPreviousTag(ctx)
+		if against == "0.0.0" {
+			return Diff{}, fmt.Errorf("no previous git tag found, pass --against")
+		}
+	}
+
+	prev, complete, err := loadPrevious(ctx, opts, against, cfg.FormatVersion(against))
+	if err != nil {
+		return Diff{}, err
+	}
+	d := Compare(prev, cur, !complete)
+
+	deps, err := dependencyChanges(ctx, cfg.Dir, against)
+	if err != nil {
+		log.Printf("Warning: dependency changes unavailable: %v", err)
+	}
+	d.Dependencies = deps
+	return d, nil
+}
+
+// loadPrevious loads the manifest of the previous release: tag is its git
+// tag and version the tag formatted by version_format, as published.
+func loadPrevious(ctx context.Context, opts Options, tag, version string) (*manifest.Manifest, bool, error) {
+	if opts.From == "" {
+		if opts.Blob == nil {
+			return nil, false, fmt.Errorf("a publish configuration or a local manifest is required")
+		}
+		// The previous release was published on the channel of its own tag
+		return artifacts.PublishedManifest(ctx, *opts.Blob, channel.FromTag(tag, true), version)
+	}
+
+	path := opts.From
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, manifest.FileName)
+	}
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if m.Version != "" && m.Version != version {
+		log.Printf("Warning: %s describes %s, not %s", path, m.Version, version)
+	}
+	if m.Version == "" {
+		m.Version = version
+	}
+	return m, true, nil
+}
+
+// dependencyChanges compares the go.mod of the previous tag with the one
+// in dir.
+func dependencyChanges(ctx context.Context, dir, previous string) ([]DependencyDiff, error) {
+	prevMod, err := git.ShowFile(ctx, dir, previous, "go.mod")
+	if err != nil {
+		return nil, err
+	}
+	curMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("read go.mod: %w", err)
+	}
+	return DependencyChanges(toolchain.ModRequires(prevMod), toolchain.ModRequires(curMod)), nil
+}
@@ -0,0 +1,273 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// StateDir is the directory of out_dir holding the release stage state.
+const StateDir = ".gcx-state"
+
+// stateFileName is the stage state file in StateDir.
+const stateFileName = "release.json"
+
+// Stage statuses recorded in the state file.
+const (
+	StagePending = "pending"
+	// StagePartial marks a stage that started but failed; it is resumed
+	// rather than skipped.
+	StagePartial = "partial"
+	StageDone    = "done"
+)
+
+// Stage is one step of gcx release.
+type Stage struct {
+	Name string
+	// Run executes the stage. resume is set when an earlier attempt of
+	// the stage failed part way, e.g. so publish skips finished uploads.
+	Run func(ctx context.Context, resume bool) error
+}
+
+// StageOptions controls RunStages.
+type StageOptions struct {
+	// Resume skips the stages a previous run of the same version and
+	// commit completed and resumes the partial one.
+	Resume bool
+	// Retries is the number of times a failed stage is retried before
+	// the run fails.
+	Retries int
+	// RetryDelay is the wait before each retry.
+	RetryDelay time.Duration
+	// Timeout bounds the whole run. Each stage gets the time left divided
+	// among it and the stages after it by Weights, so time a stage does
+	// not use rolls over to the next ones.
+	Timeout time.Duration
+	// Weights maps a stage name to its share of Timeout (default: 1).
+	Weights map[string]int
+	// Summary, when set, receives a table of the time each stage used
+	// and its budget once the run ends.
+	Summary io.Writer
+}
+
+// ErrStageDeadline is returned when a stage exceeds its share of
+// StageOptions.Timeout.
+var ErrStageDeadline = errors.New("stage deadline exceeded")
+
+// Statuses of StageResult.
+const (
+	ResultDone     = "done"
+	ResultSkipped  = "skipped"
+	ResultFailed   = "failed"
+	ResultDeadline = "deadline exceeded"
+	ResultNotRun   = "not run"
+)
+
+// StageResult is the outcome of one stage of a run.
+type StageResult struct {
+	Name   string
+	Status string
+	Used   time.Duration
+	// Budget is the share of StageOptions.Timeout of the stage, zero
+	// without a timeout.
+	Budget time.Duration
+}
+
+// State is the progress of gcx release for one version and commit.
+type State struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	// Stages maps a stage name to its status.
+	Stages map[string]string `json:"stages"`
+}
+
+// RunStages runs stages in order, recording their progress in
+// outDir/.gcx-state, so a failed release can be resumed with
+// opts.Resume. State of another version or commit is discarded. With
+// opts.Timeout, a stage exceeding its budget fails with ErrStageDeadline.
+func RunStages(ctx context.Context, outDir, version, commit string, stages []Stage, opts StageOptions) error {
+	path := filepath.Join(outDir, StateDir, stateFileName)
+	state := newState(version, commit, stages)
+	if opts.Resume {
+		var err error
+		if state, err = loadState(path, version, commit, stages); err != nil {
+			return err
+		}
+	}
+
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+	}
+	results := make([]StageResult, len(stages))
+	for i, stage := range stages {
+		results[i] = StageResult{Name: stage.Name, Status: ResultNotRun}
+	}
+	err := runStages(ctx, path, state, stages, results, deadline, opts)
+	if opts.Summary != nil {
+		if serr := WriteStageSummary(opts.Summary, results); serr != nil {
+			return errors.Join(err, serr)
+		}
+	}
+	return err
+}
+
+func runStages(ctx context.Context, path string, state *State, stages []Stage, results []StageResult, deadline time.Time, opts StageOptions) error {
+	for i, stage := range stages {
+		status := state.Stages[stage.Name]
+		if status == StageDone {
+			log.Printf("Skipping %s: completed by a previous run of %s", stage.Name, state.Version)
+			results[i].Status = ResultSkipped
+			continue
+		}
+
+		stageCtx, budget, cancel := stageContext(ctx, state, stages[i:], deadline, opts.Weights)
+		results[i].Budget = budget
+		start := time.Now()
+		err := runStage(stageCtx, stage, status == StagePartial, opts)
+		results[i].Used = time.Since(start)
+		results[i].Status = ResultDone
+		if err != nil {
+			results[i].Status = ResultFailed
+			if errors.Is(context.Cause(stageCtx), ErrStageDeadline) && ctx.Err() == nil {
+				results[i].Status = ResultDeadline
+				err = fmt.Errorf("%w: %s used its budget of %s: %w", ErrStageDeadline, stage.Name, budget.Round(time.Second), err)
+			}
+		}
+		cancel()
+
+		state.Stages[stage.Name] = StageDone
+		if err != nil {
+			state.Stages[stage.Name] = StagePartial
+		}
+		// Saved after the stage, as build recreates out_dir
+		if serr := state.save(path); serr != nil {
+			return errors.Join(err, serr)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w (re-run with --resume to continue from here)", stage.Name, err)
+		}
+	}
+	return nil
+}
+
+// stageContext derives the context of remaining[0] from ctx. With a
+// deadline, the stage gets the time left times its weight over the
+// weights of the remaining stages not done yet.
+func stageContext(ctx context.Context, state *State, remaining []Stage, deadline time.Time, weights map[string]int) (context.Context, time.Duration, context.CancelFunc) {
+	if deadline.IsZero() {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, 0, cancel
+	}
+	total := 0
+	for _, stage := range remaining {
+		if state.Stages[stage.Name] != StageDone {
+			total += stageWeight(weights, stage.Name)
+		}
+	}
+	left := max(time.Until(deadline), 0)
+	budget := time.Duration(int64(left) * int64(stageWeight(weights, remaining[0].Name)) / int64(total))
+	ctx, cancel := context.WithTimeoutCause(ctx, budget, ErrStageDeadline)
+	return ctx, budget, cancel
+}
+
+func stageWeight(weights map[string]int, stage string) int {
+	if w, ok := weights[stage]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// WriteStageSummary prints one line per stage with the time it used, its
+// budget and its status.
+func WriteStageSummary(w io.Writer, results []StageResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tUSED\tBUDGET\tSTATUS")
+	for _, r := range results {
+		used, budget := "-", "-"
+		if r.Status != ResultSkipped && r.Status != ResultNotRun {
+			used = r.Used.Round(time.Millisecond).String()
+		}
+		if r.Budget > 0 {
+			budget = r.Budget.Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Name, used, budget, r.Status)
+	}
+	return tw.Flush()
+}
+
+// runStage runs stage, retrying it up to opts.Retries times.
+func runStage(ctx context.Context, stage Stage, resume bool, opts StageOptions) error {
+	for attempt := 0; ; attempt++ {
+		if resume {
+			log.Printf("Resuming %s", stage.Name)
+		}
+		err := stage.Run(ctx, resume)
+		if err == nil || attempt >= opts.Retries || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Warning: %s failed: %v; retrying in %s (retry %d of %d)", stage.Name, err, opts.RetryDelay, attempt+1, opts.Retries)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(opts.RetryDelay):
+		}
+		resume = true
+	}
+}
+
+func newState(version, commit string, stages []Stage) *State {
+	s := &State{Version: version, Commit: commit, Stages: make(map[string]string)}
+	for _, stage := range stages {
+		s.Stages[stage.Name] = StagePending
+	}
+	return s
+}
+
+// loadState reads the state at path. A missing file yields a new state,
+// and one of another version or commit is discarded.
+func loadState(path, version, commit string, stages []Stage) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("No release state in %s, starting from the first stage", filepath.Dir(path))
+		return newState(version, commit, stages), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read release state: %w", err)
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse release state %s: %w", path, err)
+	}
+	if s.Version != version || s.Commit != commit || s.Stages == nil {
+		log.Printf("Discarding release state of %s (commit %s): releasing %s (commit %s)", s.Version, s.Commit, version, commit)
+		return newState(version, commit, stages), nil
+	}
+	for _, stage := range stages {
+		if s.Stages[stage.Name] == "" {
+			s.Stages[stage.Name] = StagePending
+		}
+	}
+	return &s, nil
+}
+
+func (s *State) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal release state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("write release state: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write release state: %w", err)
+	}
+	return nil
+}
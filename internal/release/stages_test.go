@@ -0,0 +1,168 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStages returns build, publish and deploy stages recording their runs
+// in calls; publish fails while failures is positive.
+func fakeStages(calls *[]string, failures *int) []Stage {
+	stage := func(name string, fail *int) Stage {
+		return Stage{Name: name, Run: func(_ context.Context, resume bool) error {
+			call := name
+			if resume {
+				call += " (resume)"
+			}
+			*calls = append(*calls, call)
+			if fail != nil && *fail > 0 {
+				*fail--
+				return errors.New("connection reset")
+			}
+			return nil
+		}}
+	}
+	return []Stage{stage("build", nil), stage("publish", failures), stage("deploy", nil)}
+}
+
+func TestRunStagesResume(t *testing.T) {
+	dir := t.TempDir()
+	var calls []string
+	failures := 1
+	stages := fakeStages(&calls, &failures)
+
+	err := RunStages(context.Background(), dir, "v1.2.0", "abc1234", stages, StageOptions{})
+	if err == nil || !strings.Contains(err.Error(), "publish: connection reset") {
+		t.Fatalf("RunStages() error = %v, want the publish failure", err)
+	}
+	if want := []string{"build", "publish"}; !slices.Equal(calls, want) {
+		t.Errorf("first run = %v, want %v", calls, want)
+	}
+	state, err := loadState(filepath.Join(dir, StateDir, stateFileName), "v1.2.0", "abc1234", stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"build": StageDone, "publish": StagePartial, "deploy": StagePending}
+	if !maps.Equal(state.Stages, want) {
+		t.Errorf("state = %v, want %v", state.Stages, want)
+	}
+
+	calls = nil
+	if err := RunStages(context.Background(), dir, "v1.2.0", "abc1234", stages, StageOptions{Resume: true}); err != nil {
+		t.Fatalf("RunStages(resume) error = %v", err)
+	}
+	if want := []string{"publish (resume)", "deploy"}; !slices.Equal(calls, want) {
+		t.Errorf("resumed run = %v, want %v", calls, want)
+	}
+
+	// Another commit must not resume the state of this one
+	calls = nil
+	if err := RunStages(context.Background(), dir, "v1.2.0", "def5678", stages, StageOptions{Resume: true}); err != nil {
+		t.Fatalf("RunStages(other commit) error = %v", err)
+	}
+	if want := []string{"build", "publish", "deploy"}; !slices.Equal(calls, want) {
+		t.Errorf("run of another commit = %v, want %v", calls, want)
+	}
+}
+
+func TestRunStagesRetry(t *testing.T) {
+	tests := []struct {
+		failures, retries int
+		calls             []string
+		wantErr           bool
+	}{
+		{2, 2, []string{"build", "publish", "publish (resume)", "publish (resume)", "deploy"}, false},
+		{2, 1, []string{"build", "publish", "publish (resume)"}, true},
+	}
+	for _, tt := range tests {
+		var calls []string
+		failures := tt.failures
+		err := RunStages(context.Background(), t.TempDir(), "v1.2.0", "abc1234", fakeStages(&calls, &failures), StageOptions{Retries: tt.retries})
+		if (err != nil) != tt.wantErr || !slices.Equal(calls, tt.calls) {
+			t.Errorf("%d failures, %d retries: calls = %v, err = %v; want %v, error %v", tt.failures, tt.retries, calls, err, tt.calls, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadStateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), stateFileName)
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadState(path, "v1.2.0", "abc1234", nil); err == nil || !strings.Contains(err.Error(), "parse release state") {
+		t.Errorf("loadState() error = %v, want a parse error", err)
+	}
+}
+
+func TestRunStagesBudget(t *testing.T) {
+	budgets := make(map[string]time.Duration)
+	stage := func(name string, hang bool) Stage {
+		return Stage{Name: name, Run: func(ctx context.Context, _ bool) error {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatalf("%s: context has no deadline", name)
+			}
+			budgets[name] = time.Until(deadline)
+			if hang {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		}}
+	}
+	stages := []Stage{stage("build", false), stage("publish", true), stage("deploy", false)}
+
+	var summary bytes.Buffer
+	err := RunStages(context.Background(), t.TempDir(), "v1.2.0", "abc1234", stages, StageOptions{
+		Timeout: 400 * time.Millisecond,
+		Weights: map[string]int{"build": 2},
+		Summary: &summary,
+		Retries: 3,
+	})
+	if !errors.Is(err, ErrStageDeadline) || !strings.Contains(err.Error(), "publish: stage deadline exceeded") {
+		t.Fatalf("RunStages() error = %v, want the publish stage deadline", err)
+	}
+	// build gets 2/4 of the timeout and publish 1/2 of the rest, as build
+	// returns at once
+	if d := budgets["build"]; d < 150*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("build budget = %s, want about 200ms", d)
+	}
+	if d := budgets["publish"]; d < 150*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("publish budget = %s, want about 200ms", d)
+	}
+	if _, ok := budgets["deploy"]; ok {
+		t.Error("deploy ran after publish exceeded its budget")
+	}
+
+	lines := strings.Split(strings.TrimSpace(summary.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "STAGE") {
+		t.Fatalf("summary = %q, want a header and one line per stage", summary.String())
+	}
+	for i, want := range []string{ResultDone, ResultDeadline, ResultNotRun} {
+		if !strings.HasSuffix(lines[i+1], want) {
+			t.Errorf("summary line %q, want status %s", lines[i+1], want)
+		}
+	}
+}
+
+func TestRunStagesNoTimeout(t *testing.T) {
+	var calls []string
+	failures := 0
+	var summary bytes.Buffer
+	if err := RunStages(context.Background(), t.TempDir(), "v1.2.0", "abc1234", fakeStages(&calls, &failures), StageOptions{Summary: &summary}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(summary.String()), "\n")[1:] {
+		if fields := strings.Fields(line); len(fields) != 4 || fields[2] != "-" || fields[3] != ResultDone {
+			t.Errorf("summary line %q, want no budget and status done", line)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package schedule matches times against weekly windows such as
+// "mon-fri 09:00-18:00" evaluated in a given time zone.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the zone database so locations resolve on hosts without one
+	_ "time/tzdata"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Window is a set of weekdays and a daily time range.
+type Window struct {
+	days [7]bool
+	// start and end are minutes since midnight, end up to 1440 for 24:00.
+	// end < start wraps past midnight; start == end covers the whole day.
+	start, end int
+}
+
+// Parse builds a window from day specs ("mon", "monday", "mon-fri",
+// "fri-mon") and an hours range ("09:00-18:00", "22:00-06:00"). No days
+// means every day and no hours means the whole day. Days are checked
+// against the local date of the time itself, so "fri" with "22:00-06:00"
+// matches Friday 23:00 and Friday 02:00 but not Saturday 02:00.
+func Parse(days []string, hours string) (Window, error) {
+	var w Window
+	if len(days) == 0 {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	for _, spec := range days {
+		from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), "-")
+		first, err := parseDay(from)
+		if err != nil {
+			return Window{}, err
+		}
+		last := first
+		if isRange {
+			if last, err = parseDay(to); err != nil {
+				return Window{}, err
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	if hours == "" {
+		return w, nil
+	}
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid hours %q: expected a range like 09:00-18:00", hours)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return Window{}, fmt.Errorf("invalid hours %q: %w", hours, err)
+	}
+	if w.start == minutesPerDay {
+		return Window{}, fmt.Errorf("invalid hours %q: 24:00 is only allowed as the end", hours)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return Window{}, fmt.Errorf("invalid hours %q: %w", hours, err)
+	}
+	if w.start == w.end {
+		return Window{}, fmt.Errorf("invalid hours %q: start and end are equal", hours)
+	}
+	return w, nil
+}
+
+// Contains reports whether t, converted to loc, falls inside the window.
+func (w Window) Contains(t time.Time, loc *time.Location) bool {
+	t = t.In(loc)
+	if !w.days[t.Weekday()] {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	switch {
+	case w.start == w.end:
+		return true
+	case w.start < w.end:
+		return minute >= w.start && minute < w.end
+	default:
+		return minute >= w.start || minute < w.end
+	}
+}
+
+// LoadLocation resolves an IANA time zone name. An empty name is the local
+// time zone of the host.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid location %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+func parseDay(s string) (time.Weekday, error) {
+	if len(s) >= 3 {
+		if d, ok := weekdays[s[:3]]; ok && strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q: expected mon..sun or a range like mon-fri", s)
+}
+
+// minutesPerDay is the value of "24:00".
+const minutesPerDay = 24 * 60
+
+// parseClock parses "HH:MM" into minutes since midnight. "24:00" is allowed
+// as the end of the day and parses to minutesPerDay, so "00:00-24:00"
+// covers the whole day.
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if !ok || herr != nil || merr != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("%q is not a HH:MM time", s)
+	}
+	return h*60 + m, nil
+}
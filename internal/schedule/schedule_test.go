@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		days  []string
+		hours string
+	}{
+		{days: []string{"funday"}},
+		{days: []string{"mon-"}},
+		{hours: "09:00"},
+		{hours: "9-18"},
+		{hours: "09:00-25:00"},
+		{hours: "09:60-18:00"},
+		{hours: "09:00-09:00"},
+		{hours: "24:00-06:00"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.days, tt.hours); err == nil {
+			t.Errorf("Parse(%q, %q) succeeded, want error", tt.days, tt.hours)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	berlin, err := LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		days  []string
+		hours string
+		loc   *time.Location
+		utc   string
+		want  bool
+	}{
+		// 2024-06-03 is a Monday; Berlin is UTC+2 in summer
+		{"business hours start", []string{"mon-fri"}, "09:00-18:00", berlin, "2024-06-03T07:00:00Z", true},
+		{"before business hours", []string{"mon-fri"}, "09:00-18:00", berlin, "2024-06-03T06:59:00Z", false},
+		{"end is exclusive", []string{"mon-fri"}, "09:00-18:00", berlin, "2024-06-03T16:00:00Z", false},
+		// Monday 01:00 UTC is still Sunday evening in New York
+		{"weekday differs from UTC", []string{"mon-fri"}, "", newYork, "2024-06-03T01:00:00Z", false},
+		{"weekend in local time", []string{"sat-sun"}, "", newYork, "2024-06-03T01:00:00Z", true},
+		// Friday 22:30 UTC is Saturday 00:30 in Berlin
+		{"crosses midnight into saturday", []string{"fri"}, "", berlin, "2024-06-07T22:30:00Z", false},
+		{"night range before midnight", nil, "22:00-06:00", berlin, "2024-06-03T21:00:00Z", true},
+		{"night range after midnight", nil, "22:00-06:00", berlin, "2024-06-03T03:30:00Z", true},
+		{"night range during day", nil, "22:00-06:00", berlin, "2024-06-03T10:00:00Z", false},
+		{"wrapping day range", []string{"fri-mon"}, "", berlin, "2024-06-09T12:00:00Z", true},
+		{"wrapping day range excludes tuesday", []string{"fri-mon"}, "", berlin, "2024-06-04T12:00:00Z", false},
+		{"full day names", []string{"Monday"}, "18:00-24:00", berlin, "2024-06-03T21:59:00Z", true},
+		{"until midnight excludes the next day", []string{"Monday"}, "18:00-24:00", berlin, "2024-06-03T22:00:00Z", false},
+		{"whole day until 24:00 at midnight", nil, "00:00-24:00", berlin, "2024-06-03T22:00:00Z", true},
+		{"whole day until 24:00 before midnight", nil, "00:00-24:00", berlin, "2024-06-03T21:59:00Z", true},
+		// Berlin switches to UTC+1 in winter, so 07:30 UTC is 08:30 local
+		{"standard time offset", []string{"mon-fri"}, "09:00-18:00", berlin, "2024-01-08T07:30:00Z", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := Parse(tt.days, tt.hours)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at, err := time.Parse(time.RFC3339, tt.utc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Contains(at, tt.loc); got != tt.want {
+				t.Errorf("Contains(%s in %s) = %v, want %v", tt.utc, tt.loc, got, tt.want)
+			}
+		})
+	}
+}
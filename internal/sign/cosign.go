@@ -0,0 +1,98 @@
+package sign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+)
+
+// CosignAvailable returns an error when cosign cannot be found in PATH.
+func CosignAvailable() error {
+	if _, err := exec.LookPath("cosign"); err != nil {
+		return fmt.Errorf("cosign signing requires cosign (v2+): %w", err)
+	}
+	return nil
+}
+
+// Cosign signs files with cosign sign-blob, either with a key or keyless
+// with a Fulcio certificate for the OIDC identity of the CI job.
+type Cosign struct {
+	cfg config.SignConfig
+}
+
+// NewCosign creates a Cosign signer from config.
+func NewCosign(cfg config.SignConfig) *Cosign {
+	return &Cosign{cfg: cfg}
+}
+
+// Keyless reports whether c signs without a key.
+func (c *Cosign) Keyless() bool {
+	return keyless(c.cfg)
+}
+
+func keyless(cfg config.SignConfig) bool {
+	return cfg.KeyPath == "" && cfg.KeyEnv == ""
+}
+
+// key returns the cosign key reference. A key_env variable holding a PEM
+// key is passed as env://NAME, so the key never appears in arguments.
+func (c *Cosign) key() (string, error) {
+	name := c.cfg.KeyEnv
+	if name == "" {
+		return c.cfg.KeyPath, nil
+	}
+	value := envvars.Named(name)
+	switch {
+	case value == "":
+		return "", fmt.Errorf("key_env %s is not set", name)
+	case strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN"):
+		return "env://" + name, nil
+	default:
+		return value, nil
+	}
+}
+
+// Sign writes the signature of path to path.sig and, when keyless, the
+// signing certificate to path.pem. It returns the paths written.
+func (c *Cosign) Sign(ctx context.Context, path string) ([]string, error) {
+	outputs := SignatureFiles(c.cfg, path)
+	args := []string{"sign-blob", "--yes", "--output-signature", outputs[0]}
+	if c.Keyless() {
+		args = append(args, "--output-certificate", outputs[1])
+	} else {
+		key, err := c.key()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--key", key)
+	}
+	args = append(args, path)
+
+	cmd := exec.CommandContext(ctx, "cosign", args...)
+	cmd.Stdin = os.Stdin // password prompts unless COSIGN_PASSWORD is set
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("cosign sign-blob %s: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return outputs, nil
+}
+
+// SignatureFiles returns the files signing path with cfg writes: path.sig,
+// and path.pem for keyless cosign signing.
+func SignatureFiles(cfg config.SignConfig, path string) []string {
+	files := []string{path + ".sig"}
+	if cfg.Provider == "cosign" && keyless(cfg) {
+		files = append(files, path+".pem")
+	}
+	return files
+}
+
+// SignsArchives reports whether cfg signs every archive in addition to the
+// checksums files.
+func SignsArchives(cfg config.SignConfig) bool {
+	return cfg.Provider == "cosign"
+}
@@ -0,0 +1,248 @@
+package sign
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sxwebdev/gcx/internal/checksum"
+	"github.com/sxwebdev/gcx/internal/checksums"
+	"github.com/sxwebdev/gcx/internal/config"
+	"github.com/sxwebdev/gcx/internal/envvars"
+	"github.com/sxwebdev/gcx/internal/keyfile"
+)
+
+const (
+	// Namespace is the ssh-keygen signature namespace used for release files.
+	Namespace = "file"
+	// DefaultIdentity is the principal used when sign.identity is empty.
+	DefaultIdentity = "gcx"
+)
+
+// Available returns an error when ssh-keygen cannot be found in PATH.
+func Available() error {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		return fmt.Errorf("ssh signing requires ssh-keygen (OpenSSH 8.1+): %w", err)
+	}
+	return nil
+}
+
+// SSH signs files with ssh-keygen -Y sign.
+type SSH struct {
+	keyPath  string
+	identity string
+	// keyFile holds a raw key_env key for ssh-keygen until Close
+	keyFile *keyfile.File
+}
+
+// NewSSH creates an SSH signer from config. A key_env variable holding a
+// private key rather than a key path is written to a private temporary
+// file, which is removed by Close or when ctx is canceled.
+func NewSSH(ctx context.Context, cfg config.SignConfig) (*SSH, error) {
+	identity := cfg.Identity
+	if identity == "" {
+		identity = DefaultIdentity
+	}
+	s := &SSH{keyPath: cfg.KeyPath, identity: identity}
+	if cfg.KeyEnv == "" {
+		return s, nil
+	}
+	value := envvars.Named(cfg.KeyEnv)
+	switch {
+	case value == "":
+		return nil, fmt.Errorf("key_env %s is not set", cfg.KeyEnv)
+	case strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN"):
+		f, err := keyfile.Write(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("key_env %s: %w", cfg.KeyEnv, err)
+		}
+		s.keyFile, s.keyPath = f, f.Path()
+	default:
+		s.keyPath = value
+	}
+	return s, nil
+}
+
+// Close removes the temporary key file of a key_env key.
+func (s *SSH) Close() error {
+	return s.keyFile.Close()
+}
+
+// Sign writes the signature of path to path.sig and returns its path.
+func (s *SSH) Sign(ctx context.Context, path string) (string, error) {
+	key := s.keyPath
+	if key == "" {
+		// With a public key, ssh-keygen asks the agent for the private half
+		pub, err := agentKey(ctx)
+		if err != nil {
+			return "", err
+		}
+		tmp, err := os.CreateTemp("", "gcx-sign-*.pub")
+		if err != nil {
+			return "", fmt.Errorf("write agent key: %w", err)
+		}
+		defer func() { _ = os.Remove(tmp.Name()) }()
+		if _, err := tmp.WriteString(pub + "\n"); err != nil {
+			_ = tmp.Close()
+			return "", fmt.Errorf("write agent key: %w", err)
+		}
+		if err := tmp.Close(); err != nil {
+			return "", fmt.Errorf("write agent key: %w", err)
+		}
+		key = tmp.Name()
+	}
+
+	sigPath := path + ".sig"
+	// ssh-keygen refuses to overwrite an existing signature
+	if err := os.Remove(sigPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("remove old signature: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "ssh-keygen", "-Y", "sign", "-f", key, "-n", Namespace, path)
+	cmd.Stdin = os.Stdin // passphrase prompts
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ssh-keygen -Y sign: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return sigPath, nil
+}
+
+// PublicKey returns the public key matching the signing key.
+func (s *SSH) PublicKey(ctx context.Context) (string, error) {
+	if s.keyPath == "" {
+		return agentKey(ctx)
+	}
+	if data, err := os.ReadFile(s.keyPath + ".pub"); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	out, err := exec.CommandContext(ctx, "ssh-keygen", "-y", "-f", s.keyPath).Output()
+	if err != nil {
+		return "", fmt.Errorf("read public key of %s: %w", s.keyPath, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// AllowedSigners returns the allowed_signers line verifiers need to trust
+// signatures made by s.
+func (s *SSH) AllowedSigners(ctx context.Context) (string, error) {
+	pub, err := s.PublicKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Drop the key comment, it is not part of the allowed_signers format
+	fields := strings.Fields(pub)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("malformed public key %q", pub)
+	}
+	return fmt.Sprintf("%s namespaces=%q %s %s", s.identity, Namespace, fields[0], fields[1]), nil
+}
+
+// agentKey returns the first public key held by the running ssh-agent.
+func agentKey(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "ssh-add", "-L").Output()
+	if err != nil {
+		return "", fmt.Errorf("no key_path set and no ssh-agent key available: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if scanner.Scan() && scanner.Text() != "" {
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+	return "", fmt.Errorf("no key_path set and the ssh-agent holds no keys")
+}
+
+// Verify checks sigPath against path with ssh-keygen -Y verify.
+func Verify(ctx context.Context, allowedSigners, identity, path, sigPath string) error {
+	data, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer func() { _ = data.Close() }()
+
+	cmd := exec.CommandContext(ctx, "ssh-keygen", "-Y", "verify",
+		"-f", allowedSigners, "-I", identity, "-n", Namespace, "-s", sigPath)
+	cmd.Stdin = data
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("signature of %s is not valid for %s: %s", filepath.Base(path), identity, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// VerifyDir verifies the signatures of the checksums files in dir and
+// then every file they list.
+func VerifyDir(ctx context.Context, dir, allowedSigners, identity string) error {
+	if err := Available(); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", dir, err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && checksums.IsFile(e.Name()) {
+			files = append(files, e.Name())
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no %s in %s", checksums.File, dir)
+	}
+
+	for _, file := range files {
+		if err := verifyChecksums(ctx, dir, file, allowedSigners, identity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyChecksums verifies the signature of the checksums file named file
+// and every file it lists.
+func verifyChecksums(ctx context.Context, dir, file, allowedSigners, identity string) error {
+	path := filepath.Join(dir, file)
+	if err := Verify(ctx, allowedSigners, identity, path, path+".sig"); err != nil {
+		return err
+	}
+	log.Printf("Signature of %s is valid for %s", file, identity)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open checksums file: %w", err)
+	}
+	sums, err := checksum.Parse(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checksum.Verify(listedPath(dir, name), sums[name]); err != nil {
+			return fmt.Errorf("verify %s: %w", name, err)
+		}
+	}
+	log.Printf("Verified %d file(s) in %s against %s", len(names), dir, file)
+	return nil
+}
+
+// listedPath returns the path in dir of a file listed as name. Names
+// recorded under a checksum.path_prefix that does not exist in dir are
+// looked up by their base name, where gcx build wrote them.
+func listedPath(dir, name string) string {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if _, err := os.Stat(p); err != nil && path.Base(name) != name {
+		return filepath.Join(dir, path.Base(name))
+	}
+	return p
+}
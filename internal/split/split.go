@@ -0,0 +1,178 @@
+// Package split cuts files that exceed a destination's object size limit
+// into numbered parts and joins them back.
+//
+// A file NAME is split into NAME.part001, NAME.part002, ... of at most the
+// part size each, plus the reassembly manifest NAME.parts.json listing the
+// parts in order with their sizes and SHA-256 and the size and SHA-256 of
+// the whole file.
+package split
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ManifestSuffix is appended to the file name to name its reassembly
+// manifest.
+const ManifestSuffix = ".parts.json"
+
+// Part is one piece of a split file.
+type Part struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes a split file and its parts in order.
+type Manifest struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Parts  []Part `json:"parts"`
+}
+
+// PartName returns the name of the n-th part (from 1) of the file name.
+func PartName(name string, n int) string {
+	return fmt.Sprintf("%s.part%03d", name, n)
+}
+
+// File splits the file at path into parts of at most partSize bytes in
+// dir and writes their manifest there. It returns the paths of the parts
+// followed by the manifest.
+func File(path, dir string, partSize int64) ([]string, error) {
+	if partSize <= 0 {
+		return nil, fmt.Errorf("split %s: part size must be positive", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("split %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	name := filepath.Base(path)
+	m := Manifest{Name: name}
+	whole := sha256.New()
+	var paths []string
+	for n := 1; ; n++ {
+		partPath := filepath.Join(dir, PartName(name, n))
+		part, err := writePart(partPath, io.TeeReader(io.LimitReader(f, partSize), whole))
+		if err != nil {
+			return nil, fmt.Errorf("split %s: %w", path, err)
+		}
+		if part.Size == 0 && n > 1 {
+			// The previous part ended exactly at the end of the file
+			_ = os.Remove(partPath)
+			break
+		}
+		m.Parts = append(m.Parts, part)
+		m.Size += part.Size
+		paths = append(paths, partPath)
+		if part.Size < partSize {
+			break
+		}
+	}
+	m.SHA256 = hex.EncodeToString(whole.Sum(nil))
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal parts manifest: %w", err)
+	}
+	manifestPath := filepath.Join(dir, name+ManifestSuffix)
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("write parts manifest: %w", err)
+	}
+	return append(paths, manifestPath), nil
+}
+
+// writePart copies r to a new file at path.
+func writePart(path string, r io.Reader) (Part, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return Part{}, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, h), r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Join reassembles the file described by the manifest at manifestPath from
+// the parts next to it and writes it to outPath, by default the original
+// name next to the manifest. Every part and the joined file are verified
+// against the manifest; nothing is left at outPath when one differs.
+func Join(manifestPath, outPath string) (string, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return "", fmt.Errorf("read parts manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", fmt.Errorf("parse parts manifest %s: %w", manifestPath, err)
+	}
+	if m.Name == "" || filepath.Base(m.Name) != m.Name || len(m.Parts) == 0 {
+		return "", fmt.Errorf("parts manifest %s: invalid name or no parts", manifestPath)
+	}
+	dir := filepath.Dir(manifestPath)
+	if outPath == "" {
+		outPath = filepath.Join(dir, m.Name)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), "."+filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("join %s: %w", m.Name, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = joinParts(tmp, dir, m)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("join %s: %w", m.Name, err)
+	}
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		return "", fmt.Errorf("join %s: %w", m.Name, err)
+	}
+	return outPath, nil
+}
+
+// joinParts appends the parts of m in dir to w, verifying each part and
+// the whole.
+func joinParts(w io.Writer, dir string, m Manifest) error {
+	whole := sha256.New()
+	var size int64
+	for _, p := range m.Parts {
+		if filepath.Base(p.Name) != p.Name {
+			return fmt.Errorf("invalid part name %q", p.Name)
+		}
+		f, err := os.Open(filepath.Join(dir, p.Name))
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(w, whole, h), f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); n != p.Size || got != p.SHA256 {
+			return fmt.Errorf("part %s: got %d bytes with sha256 %s, want %d bytes with sha256 %s", p.Name, n, got, p.Size, p.SHA256)
+		}
+		size += n
+	}
+	if got := hex.EncodeToString(whole.Sum(nil)); size != m.Size || got != m.SHA256 {
+		return errors.New("joined file does not match the manifest size and sha256")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package split
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFileJoin(t *testing.T) {
+	tests := []struct {
+		size  int
+		parts []string
+	}{
+		{10, []string{"big.bin.part001", "big.bin.part002", "big.bin.part003"}},
+		{8, []string{"big.bin.part001", "big.bin.part002"}},
+		{3, []string{"big.bin.part001"}},
+	}
+	for _, tt := range tests {
+		src := t.TempDir()
+		content := bytes.Repeat([]byte("x"), tt.size)
+		path := filepath.Join(src, "big.bin")
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+		paths, err := File(path, dir, 4)
+		if err != nil {
+			t.Fatalf("File() error = %v", err)
+		}
+		var names []string
+		for _, p := range paths {
+			names = append(names, filepath.Base(p))
+		}
+		if want := append(slices.Clone(tt.parts), "big.bin"+ManifestSuffix); !slices.Equal(names, want) {
+			t.Errorf("%d bytes: parts = %v, want %v", tt.size, names, want)
+		}
+
+		joined, err := Join(paths[len(paths)-1], "")
+		if err != nil {
+			t.Fatalf("Join() error = %v", err)
+		}
+		if got, _ := os.ReadFile(joined); !bytes.Equal(got, content) || joined != filepath.Join(dir, "big.bin") {
+			t.Errorf("%d bytes: joined %s = %q", tt.size, joined, got)
+		}
+	}
+}
+
+func TestJoinCorruptPart(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "app.tar.gz")
+	if err := os.WriteFile(src, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	paths, err := File(src, dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(paths[1], []byte("456X"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "joined")
+	if _, err := Join(paths[len(paths)-1], out); err == nil || !strings.Contains(err.Error(), "part app.tar.gz.part002") {
+		t.Fatalf("Join() error = %v, want a part002 mismatch", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output of a failed join exists: %v", err)
+	}
+}
@@ -1,12 +1,71 @@
 package sshutil
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/melbahja/goph"
 	"github.com/sxwebdev/gcx/internal/helpers"
 )
 
+// Supported SSH client backends.
+const (
+	BackendGoph   = "goph"
+	BackendNative = "native"
+)
+
+// Client is an SSH connection used by the publish and deploy providers.
+type Client interface {
+	// Run executes cmd on the remote host and returns its combined output.
+	Run(cmd string) ([]byte, error)
+	// RunPTY executes cmd on a pseudo-terminal and returns its output, in
+	// which stderr is merged by the terminal. The session is closed when
+	// ctx is done.
+	RunPTY(ctx context.Context, cmd string) ([]byte, error)
+	// Stream executes cmd and writes its stdout and stderr to w as they
+	// arrive. The command is stopped when ctx is done.
+	Stream(ctx context.Context, cmd string, w io.Writer) error
+	// Upload copies a local file to remotePath.
+	Upload(localPath, remotePath string) error
+	// Download copies remotePath to a local file.
+	Download(remotePath, localPath string) error
+	// ReadDir lists the entries of a remote directory.
+	ReadDir(remotePath string) ([]os.FileInfo, error)
+	Close() error
+}
+
+// CloseOnDone closes client once ctx is done, as sessions ignore the
+// context and closing the connection is the only way to stop them. The
+// returned stop function, usually deferred, cancels this.
+func CloseOnDone(ctx context.Context, client Client) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { _ = client.Close() })
+}
+
+// gophClient adds directory listing to goph.Client.
+type gophClient struct {
+	*goph.Client
+}
+
+func (c gophClient) RunPTY(ctx context.Context, cmd string) ([]byte, error) {
+	return runPTY(ctx, c.Client.Client, cmd)
+}
+
+func (c gophClient) Stream(ctx context.Context, cmd string, w io.Writer) error {
+	return stream(ctx, c.Client.Client, cmd, w)
+}
+
+func (c gophClient) ReadDir(remotePath string) ([]os.FileInfo, error) {
+	ftp, err := c.NewSftp()
+	if err != nil {
+		return nil, fmt.Errorf("start sftp session: %w", err)
+	}
+	defer func() { _ = ftp.Close() }()
+
+	return ftp.ReadDir(remotePath)
+}
+
 // ClientConfig holds SSH connection parameters.
 type ClientConfig struct {
 	Server                string
@@ -14,6 +73,14 @@ type ClientConfig struct {
 	KeyPath               string
 	KeyRaw                string
 	InsecureIgnoreHostKey bool
+	// Backend selects the client implementation: "goph" (default) or "native".
+	Backend string
+	// SFTPConcurrency is the number of concurrent write requests per file (native backend).
+	SFTPConcurrency int
+	// SFTPBufferSize is the SFTP packet size in bytes (native backend).
+	SFTPBufferSize int
+	// SFTPFallback streams uploads through "cat" when the server has no SFTP subsystem (native backend).
+	SFTPFallback bool
 }
 
 // Validate checks that the SSH client configuration is valid.
@@ -30,22 +97,33 @@ func (c *ClientConfig) Validate() error {
 	if c.KeyPath != "" && c.KeyRaw != "" {
 		return fmt.Errorf("only one of key_path or key_raw should be provided")
 	}
+	switch c.Backend {
+	case "", BackendGoph, BackendNative:
+	default:
+		return fmt.Errorf("unsupported ssh backend: %s", c.Backend)
+	}
 	return nil
 }
 
 // NewClient creates a new SSH client from the given configuration.
 // It handles key loading, known hosts verification, and client creation.
-func NewClient(cfg ClientConfig) (*goph.Client, error) {
+// ctx bounds the known hosts scan; sessions ignore it, so callers stop them
+// with CloseOnDone.
+func NewClient(ctx context.Context, cfg ClientConfig) (Client, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid SSH configuration: %w", err)
 	}
 
 	if !cfg.InsecureIgnoreHostKey {
-		if err := EnsureKnownHost(cfg.Server); err != nil {
+		if err := EnsureKnownHost(ctx, cfg.Server); err != nil {
 			return nil, fmt.Errorf("known hosts check failed: %w", err)
 		}
 	}
 
+	if cfg.Backend == BackendNative {
+		return newNativeClient(cfg)
+	}
+
 	var (
 		auth goph.Auth
 		err  error
@@ -71,12 +149,12 @@ func NewClient(cfg ClientConfig) (*goph.Client, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create insecure SSH client: %w", err)
 		}
-		return client, nil
+		return gophClient{client}, nil
 	}
 
 	client, err := goph.New(cfg.User, cfg.Server, auth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SSH client: %w", err)
 	}
-	return client, nil
+	return gophClient{client}, nil
 }
@@ -1,6 +1,10 @@
 package sshutil
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+)
 
 func TestClientConfigValidate(t *testing.T) {
 	tests := []struct {
@@ -48,3 +52,38 @@ func TestClientConfigValidate(t *testing.T) {
 		})
 	}
 }
+
+// closeClient records Close on a Client whose other methods are not used.
+type closeClient struct {
+	Client
+	closed chan struct{}
+}
+
+func (c closeClient) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestCloseOnDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	client := closeClient{closed: make(chan struct{})}
+	defer CloseOnDone(ctx, client)()
+	cancel()
+	select {
+	case <-client.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client not closed after the context was cancelled")
+	}
+
+	ctx, cancel = context.WithCancel(t.Context())
+	client = closeClient{closed: make(chan struct{})}
+	if !CloseOnDone(ctx, client)() {
+		t.Error("stop() = false before the context was done")
+	}
+	cancel()
+	select {
+	case <-client.closed:
+		t.Error("client closed after stop()")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,6 +1,7 @@
 package sshutil
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,8 +11,9 @@ import (
 )
 
 // EnsureKnownHost checks if the server is in known_hosts.
-// If the known_hosts file doesn't exist, it creates it and runs ssh-keyscan.
-func EnsureKnownHost(server string) error {
+// If the known_hosts file doesn't exist, it creates it and runs ssh-keyscan,
+// which is stopped when ctx is done.
+func EnsureKnownHost(ctx context.Context, server string) error {
 	knownHostsPath, err := helpers.ExpandPath("~/.ssh/known_hosts")
 	if err != nil {
 		return fmt.Errorf("failed to expand known hosts path: %w", err)
@@ -30,7 +32,7 @@ func EnsureKnownHost(server string) error {
 		return fmt.Errorf("failed to create known_hosts file: %w", err)
 	}
 
-	cmd := exec.Command("ssh-keyscan", "-H", server)
+	cmd := exec.CommandContext(ctx, "ssh-keyscan", "-H", server)
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("ssh-keyscan failed for %s: %w", server, err)
@@ -0,0 +1,231 @@
+package sshutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/sxwebdev/gcx/internal/helpers"
+	"github.com/sxwebdev/gcx/internal/shellutil"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	defaultSFTPConcurrency = 64
+	// dialTimeout matches the connection timeout used by the goph backend.
+	dialTimeout = 20 * time.Second
+)
+
+// nativeClient is a Client built directly on golang.org/x/crypto/ssh and pkg/sftp.
+type nativeClient struct {
+	conn *ssh.Client
+	cfg  ClientConfig
+}
+
+func newNativeClient(cfg ClientConfig) (*nativeClient, error) {
+	signer, err := loadSigner(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !cfg.InsecureIgnoreHostKey {
+		knownHostsPath, err := helpers.ExpandPath("~/.ssh/known_hosts")
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand known hosts path: %w", err)
+		}
+		hostKeyCallback, err = knownhosts.New(knownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known hosts: %w", err)
+		}
+	}
+
+	conn, err := ssh.Dial("tcp", serverAddr(cfg.Server), &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	return &nativeClient{conn: conn, cfg: cfg}, nil
+}
+
+func loadSigner(cfg ClientConfig) (ssh.Signer, error) {
+	if cfg.KeyRaw != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(cfg.KeyRaw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key from raw data: %w", err)
+		}
+		return signer, nil
+	}
+
+	keyPath, err := helpers.ExpandPath(cfg.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand key path: %w", err)
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH key from %s: %w", keyPath, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH key from %s: %w", keyPath, err)
+	}
+	return signer, nil
+}
+
+// serverAddr appends the default SSH port when server has none.
+func serverAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "22")
+}
+
+// Run executes cmd in a new session and returns its combined output,
+// matching the goph backend.
+func (c *nativeClient) Run(cmd string) ([]byte, error) {
+	sess, err := c.conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = sess.Close() }()
+
+	return sess.CombinedOutput(cmd)
+}
+
+// RunPTY executes cmd on a pseudo-terminal in a new session.
+func (c *nativeClient) RunPTY(ctx context.Context, cmd string) ([]byte, error) {
+	return runPTY(ctx, c.conn, cmd)
+}
+
+// Stream executes cmd in a new session and writes its output to w.
+func (c *nativeClient) Stream(ctx context.Context, cmd string, w io.Writer) error {
+	return stream(ctx, c.conn, cmd, w)
+}
+
+// Upload copies a local file to remotePath over SFTP. When the server has no
+// SFTP subsystem and sftp_fallback is enabled, the file is streamed through
+// "cat" in an exec session instead.
+func (c *nativeClient) Upload(localPath, remotePath string) error {
+	client, err := sftp.NewClient(c.conn, sftpOptions(c.cfg)...)
+	if err != nil {
+		if !c.cfg.SFTPFallback {
+			return fmt.Errorf("start sftp session: %w", err)
+		}
+		return c.uploadExec(localPath, remotePath)
+	}
+	defer func() { _ = client.Close() }()
+
+	return uploadSFTP(client, localPath, remotePath)
+}
+
+// Download copies remotePath to localPath over SFTP.
+func (c *nativeClient) Download(remotePath, localPath string) (retErr error) {
+	client, err := sftp.NewClient(c.conn, sftpOptions(c.cfg)...)
+	if err != nil {
+		return fmt.Errorf("start sftp session: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	remote, err := client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = remote.Close() }()
+
+	local, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := local.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	_, err = remote.WriteTo(local)
+	return err
+}
+
+// ReadDir lists a remote directory over SFTP.
+func (c *nativeClient) ReadDir(remotePath string) ([]os.FileInfo, error) {
+	client, err := sftp.NewClient(c.conn, sftpOptions(c.cfg)...)
+	if err != nil {
+		return nil, fmt.Errorf("start sftp session: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	return client.ReadDir(remotePath)
+}
+
+func (c *nativeClient) uploadExec(localPath, remotePath string) error {
+	local, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = local.Close() }()
+
+	sess, err := c.conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sess.Close() }()
+
+	sess.Stdin = local
+	if out, err := sess.CombinedOutput("cat > " + shellutil.Quote(remotePath)); err != nil {
+		return fmt.Errorf("stream upload: %w: %s", err, out)
+	}
+	return nil
+}
+
+func (c *nativeClient) Close() error {
+	return c.conn.Close()
+}
+
+// sftpOptions translates the throughput knobs into pkg/sftp options.
+func sftpOptions(cfg ClientConfig) []sftp.ClientOption {
+	concurrency := cfg.SFTPConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSFTPConcurrency
+	}
+	opts := []sftp.ClientOption{
+		sftp.UseConcurrentWrites(true),
+		sftp.MaxConcurrentRequestsPerFile(concurrency),
+	}
+	if cfg.SFTPBufferSize > 0 {
+		opts = append(opts, sftp.MaxPacketUnchecked(cfg.SFTPBufferSize))
+	}
+	return opts
+}
+
+func uploadSFTP(client *sftp.Client, localPath, remotePath string) (retErr error) {
+	local, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = local.Close() }()
+
+	remote, err := client.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("create remote file %s: %w", path.Clean(remotePath), err)
+	}
+	defer func() {
+		if err := remote.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	// ReadFrom issues concurrent write requests when concurrent writes are enabled.
+	_, err = remote.ReadFrom(local)
+	return err
+}
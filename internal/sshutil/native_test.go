@@ -0,0 +1,240 @@
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestServerAddr(t *testing.T) {
+	tests := map[string]string{
+		"example.com":      "example.com:22",
+		"example.com:2222": "example.com:2222",
+		"10.0.0.1":         "10.0.0.1:22",
+		"[::1]:2200":       "[::1]:2200",
+	}
+	for in, want := range tests {
+		if got := serverAddr(in); got != want {
+			t.Errorf("serverAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNativeClientRun(t *testing.T) {
+	srv := startTestServer(t, false)
+
+	client, err := NewClient(context.Background(), srv.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	out, err := client.Run("echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "hello\n" {
+		t.Errorf("output = %q, want %q", out, "hello\n")
+	}
+
+	if _, err := client.Run("exit 3"); err == nil {
+		t.Error("expected error for failing command")
+	}
+}
+
+func TestNativeClientRunPTY(t *testing.T) {
+	srv := startTestServer(t, false)
+
+	client, err := NewClient(context.Background(), srv.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	t.Run("merged output", func(t *testing.T) {
+		out, err := client.RunPTY(t.Context(), "echo $TERM; echo oops >&2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(out) != "dumb\noops\n" {
+			t.Errorf("output = %q, want %q", out, "dumb\noops\n")
+		}
+	})
+
+	t.Run("exit status", func(t *testing.T) {
+		out, err := client.RunPTY(t.Context(), "echo partial; exit 3")
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+			t.Fatalf("error = %v, want exit status 3", err)
+		}
+		if string(out) != "partial\n" {
+			t.Errorf("output = %q", out)
+		}
+	})
+
+	// The session is closed on the deadline instead of waiting for the
+	// command; the output streamed until then is kept
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		out, err := client.RunPTY(ctx, "echo started; sleep 3")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("error = %v, want deadline exceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("RunPTY returned after %s", elapsed)
+		}
+		if string(out) != "started\n" {
+			t.Errorf("output = %q", out)
+		}
+	})
+}
+
+// TestBackendsRunIdentically runs the same commands through goph and the
+// native backend and expects identical output and error semantics.
+func TestBackendsRunIdentically(t *testing.T) {
+	srv := startTestServer(t, false)
+
+	native, err := NewClient(context.Background(), srv.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = native.Close() }()
+
+	auth, err := goph.RawKey(srv.KeyRaw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, _ := net.SplitHostPort(srv.Addr)
+	port, _ := strconv.Atoi(portStr)
+	gophClient, err := goph.NewConn(&goph.Config{
+		User:     "test",
+		Addr:     host,
+		Port:     uint(port),
+		Auth:     auth,
+		Callback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = gophClient.Close() }()
+
+	for _, cmd := range []string{"echo out", "echo err >&2", "printf 'a\\nb'", "exit 2", "true"} {
+		t.Run(cmd, func(t *testing.T) {
+			wantOut, wantErr := gophClient.Run(cmd)
+			gotOut, gotErr := native.Run(cmd)
+			if !bytes.Equal(gotOut, wantOut) {
+				t.Errorf("output = %q, goph returned %q", gotOut, wantOut)
+			}
+			if (gotErr != nil) != (wantErr != nil) {
+				t.Errorf("error = %v, goph returned %v", gotErr, wantErr)
+			}
+			if wantErr != nil && gotErr != nil && gotErr.Error() != wantErr.Error() {
+				t.Errorf("error = %q, goph returned %q", gotErr, wantErr)
+			}
+		})
+	}
+}
+
+func TestNativeClientUpload(t *testing.T) {
+	content := []byte("binary contents\n")
+
+	for _, tt := range []struct {
+		name     string
+		noSFTP   bool
+		fallback bool
+		wantErr  bool
+	}{
+		{name: "sftp"},
+		{name: "fallback to exec", noSFTP: true, fallback: true},
+		{name: "no sftp without fallback", noSFTP: true, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := startTestServer(t, tt.noSFTP)
+			cfg := srv.config()
+			cfg.SFTPFallback = tt.fallback
+
+			client, err := NewClient(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = client.Close() }()
+
+			dir := t.TempDir()
+			local := filepath.Join(dir, "local")
+			remote := filepath.Join(dir, "remote dir's file")
+			if err := os.WriteFile(local, content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Upload(local, remote)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := os.ReadFile(remote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("remote content = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+// BenchmarkNativeUpload shows the effect of the sftp_buffer_size and
+// sftp_concurrency knobs on upload throughput.
+func BenchmarkNativeUpload(b *testing.B) {
+	srv := startTestServer(b, false)
+
+	dir := b.TempDir()
+	local := filepath.Join(dir, "payload")
+	payload := make([]byte, 32<<20)
+	if _, err := rand.Read(payload); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(local, payload, 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, knobs := range []struct{ concurrency, bufferSize int }{
+		{1, 32 << 10},
+		{64, 32 << 10},
+		{64, 128 << 10},
+	} {
+		b.Run(fmt.Sprintf("concurrency=%d/buffer=%dKiB", knobs.concurrency, knobs.bufferSize>>10), func(b *testing.B) {
+			cfg := srv.config()
+			cfg.SFTPConcurrency = knobs.concurrency
+			cfg.SFTPBufferSize = knobs.bufferSize
+
+			client, err := NewClient(context.Background(), cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer func() { _ = client.Close() }()
+
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+			for b.Loop() {
+				if err := client.Upload(local, filepath.Join(dir, "remote")); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PTY settings of RunPTY sessions. The output ends up in logs, so the
+// terminal is dumb, does not echo and keeps plain LF line endings.
+const (
+	ptyTerm = "dumb"
+	ptyRows = 40
+	ptyCols = 200
+)
+
+var ptyModes = ssh.TerminalModes{
+	ssh.ECHO:          0,
+	ssh.ONLCR:         0,
+	ssh.TTY_OP_ISPEED: 14400,
+	ssh.TTY_OP_OSPEED: 14400,
+}
+
+// lockedBuffer collects stdout and stderr, which the session copies
+// concurrently.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Bytes returns a copy of the output so far with CRLF line endings of
+// servers ignoring ONLCR turned into LF.
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.ReplaceAll(b.buf.Bytes(), []byte("\r\n"), []byte("\n"))
+}
+
+// runPTY executes cmd on a pseudo-terminal in a new session of conn. The
+// terminal merges stderr into stdout; a non-zero exit status is returned as
+// *ssh.ExitError like Run. Closing the session is the only way to stop a
+// command waiting on its terminal, so it is closed as soon as ctx is done
+// and the output received until then is returned with ctx.Err().
+func runPTY(ctx context.Context, conn *ssh.Client, cmd string) ([]byte, error) {
+	sess, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = sess.Close() }()
+
+	if err := sess.RequestPty(ptyTerm, ptyRows, ptyCols, ptyModes); err != nil {
+		return nil, fmt.Errorf("request pty: %w", err)
+	}
+	var out lockedBuffer
+	sess.Stdout, sess.Stderr = &out, &out
+	if err := sess.Start(cmd); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- sess.Wait() }()
+	select {
+	case err := <-done:
+		return out.Bytes(), err
+	case <-ctx.Done():
+		// The remote side gets SIGHUP when its terminal goes away; the
+		// Wait goroutine ends once the server closes the channel
+		_ = sess.Close()
+		return out.Bytes(), ctx.Err()
+	}
+}
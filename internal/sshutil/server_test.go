@@ -0,0 +1,201 @@
+package sshutil
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is a minimal in-process SSH server supporting exec requests
+// (run locally via sh -c) and, optionally, the sftp subsystem. After a
+// pty-req, commands get TERM and their stderr is merged into stdout as on
+// a real terminal.
+type testServer struct {
+	Addr   string
+	KeyRaw string
+
+	noSFTP bool
+	ln     net.Listener
+	wg     sync.WaitGroup
+}
+
+func startTestServer(tb testing.TB, noSFTP bool) *testServer {
+	tb.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	serverCfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	serverCfg.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	s := &testServer{
+		Addr:   ln.Addr().String(),
+		KeyRaw: string(pem.EncodeToMemory(block)),
+		noSFTP: noSFTP,
+		ln:     ln,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serveConn(conn, serverCfg)
+			}()
+		}
+	}()
+
+	tb.Cleanup(func() {
+		_ = ln.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *testServer) config() ClientConfig {
+	return ClientConfig{
+		Server:                s.Addr,
+		User:                  "test",
+		KeyRaw:                s.KeyRaw,
+		InsecureIgnoreHostKey: true,
+		Backend:               BackendNative,
+	}
+}
+
+func (s *testServer) serveConn(conn net.Conn, cfg *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	defer func() { _ = sconn.Close() }()
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go s.serveSession(ch, chReqs)
+	}
+}
+
+func (s *testServer) serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer func() { _ = ch.Close() }()
+
+	var term string
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var payload struct {
+				Term                         string
+				Columns, Rows, Width, Height uint32
+				Modes                        string
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			term = payload.Term
+			_ = req.Reply(true, nil)
+		case "env":
+			_ = req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+
+			cmd := exec.Command("sh", "-c", payload.Command)
+			cmd.Stdin = ch
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+			if term != "" {
+				cmd.Stderr = ch
+				cmd.Env = append(os.Environ(), "TERM="+term)
+			}
+
+			status := uint32(0)
+			if err := cmd.Run(); err != nil {
+				status = 1
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) {
+					status = uint32(exitErr.ExitCode())
+				}
+			}
+			exitStatus := make([]byte, 4)
+			binary.BigEndian.PutUint32(exitStatus, status)
+			_, _ = ch.SendRequest("exit-status", false, exitStatus)
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" || s.noSFTP {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+
+			server, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			_ = server.Serve()
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package sshutil
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// lockedWriter serializes the concurrent stdout and stderr copies of a
+// session into one writer.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// stream executes cmd in a new session of conn and writes its stdout and
+// stderr to w as they arrive. Commands such as journalctl -f never exit,
+// so when ctx is done the command is sent SIGTERM, the session is closed
+// and ctx.Err() is returned.
+func stream(ctx context.Context, conn *ssh.Client, cmd string, w io.Writer) error {
+	sess, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sess.Close() }()
+
+	out := &lockedWriter{w: w}
+	sess.Stdout, sess.Stderr = out, out
+	if err := sess.Start(cmd); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- sess.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// Servers without signal support leave the command to die of
+		// SIGPIPE on its next write to the closed channel
+		_ = sess.Signal(ssh.SIGTERM)
+		_ = sess.Close()
+		return ctx.Err()
+	}
+}
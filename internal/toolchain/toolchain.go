@@ -0,0 +1,271 @@
+package toolchain
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"go/version"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// clause is a single comparison such as ">=1.22".
+type clause struct {
+	op      string
+	version string
+}
+
+// Constraint is a set of version comparisons that must all hold, e.g.
+// ">=1.22, <1.25".
+type Constraint []clause
+
+var operators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// operatorSpace matches blanks between an operator and its version (">= 1.22").
+var operatorSpace = regexp.MustCompile(`([<>=!]=?)\s+`)
+
+// ParseConstraint parses comma or space separated comparisons. A version
+// without an operator must match exactly. Versions without a patch number
+// mean the first release of that line, so ">=1.22" accepts 1.22.0.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+	compact := operatorSpace.ReplaceAllString(s, "$1")
+	for _, field := range strings.FieldsFunc(compact, func(r rune) bool { return r == ',' || r == ' ' }) {
+		op := "="
+		for _, candidate := range operators {
+			if strings.HasPrefix(field, candidate) {
+				op = candidate
+				break
+			}
+		}
+		v := strings.TrimPrefix(strings.TrimPrefix(field, op), "go")
+		if !version.IsValid("go" + v) {
+			return nil, fmt.Errorf("invalid go version %q in constraint %q", v, s)
+		}
+		if op == "==" {
+			op = "="
+		}
+		c = append(c, clause{op: op, version: normalize(v)})
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("empty go version constraint")
+	}
+	return c, nil
+}
+
+// Check reports whether the version v (e.g. "1.22.3") satisfies c.
+func (c Constraint) Check(v string) bool {
+	for _, cl := range c {
+		cmp := Compare(v, cl.version)
+		var ok bool
+		switch cl.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare compares two Go versions without the "go" prefix, treating "1.22"
+// as "1.22.0".
+func Compare(a, b string) int {
+	return version.Compare("go"+normalize(a), "go"+normalize(b))
+}
+
+// normalize turns a language version such as "1.22" into the matching
+// release "1.22.0". Prereleases and full versions are returned unchanged.
+func normalize(v string) string {
+	if strings.Count(v, ".") == 1 && strings.Trim(v, "0123456789.") == "" {
+		return v + ".0"
+	}
+	return v
+}
+
+// The functions running the go command take goBinary, the go command or a
+// tool wrapping it such as a build's gobinary; empty means go.
+
+// command returns the goBinary command running args in dir.
+func command(ctx context.Context, goBinary, dir string, args ...string) *exec.Cmd {
+	if goBinary == "" {
+		goBinary = "go"
+	}
+	cmd := exec.CommandContext(ctx, goBinary, args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// Version runs "go version" in dir and returns the toolchain version, e.g.
+// "1.22.3". The directory matters because go.mod may select the toolchain.
+func Version(ctx context.Context, goBinary, dir string) (string, error) {
+	out, err := command(ctx, goBinary, dir, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("run %s version: %w", filepath.Base(cmp.Or(goBinary, "go")), err)
+	}
+	return parseVersionOutput(string(out))
+}
+
+// GOROOT returns the root of the go toolchain selected in dir as reported
+// by "go env GOROOT".
+func GOROOT(ctx context.Context, goBinary, dir string) (string, error) {
+	out, err := command(ctx, goBinary, dir, "env", "GOROOT").Output()
+	if err != nil {
+		return "", fmt.Errorf("run %s env GOROOT: %w", filepath.Base(cmp.Or(goBinary, "go")), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// distLists caches the output of DistList per go command and directory.
+var distLists sync.Map
+
+// DistList returns the GOOS/GOARCH pairs, e.g. "linux/amd64", the go
+// toolchain selected in dir can build, as listed by "go tool dist list".
+// The list is cached for the rest of the run.
+func DistList(ctx context.Context, goBinary, dir string) ([]string, error) {
+	key := [2]string{goBinary, dir}
+	if list, ok := distLists.Load(key); ok {
+		return list.([]string), nil
+	}
+	out, err := command(ctx, goBinary, dir, "tool", "dist", "list").Output()
+	if err != nil {
+		return nil, fmt.Errorf("run %s tool dist list: %w", filepath.Base(cmp.Or(goBinary, "go")), err)
+	}
+	list := strings.Fields(string(out))
+	if len(list) == 0 {
+		// Tools wrapping go may accept the command without listing anything
+		return nil, fmt.Errorf("%s tool dist list listed no platforms", filepath.Base(cmp.Or(goBinary, "go")))
+	}
+	distLists.Store(key, list)
+	return list, nil
+}
+
+// goVersionRegex finds the go version in the version output of go
+// ("go version go1.22.3 linux/amd64") and of tools reporting the go they
+// use, such as tinygo ("... (using go version go1.22.3 and LLVM ...)").
+var goVersionRegex = regexp.MustCompile(`(?:^|\s|\()go version (go\S+)`)
+
+// parseVersionOutput extracts the version from "go version go1.22.3 linux/amd64".
+func parseVersionOutput(out string) (string, error) {
+	m := goVersionRegex.FindStringSubmatch(out)
+	if m == nil || !version.IsValid(m[1]) {
+		return "", fmt.Errorf("unrecognized go version output %q", strings.TrimSpace(out))
+	}
+	return strings.TrimPrefix(m[1], "go"), nil
+}
+
+// ModVersions returns the go and toolchain directives of a go.mod file.
+// The toolchain directive is empty when absent.
+func ModVersions(path string) (goVersion, toolchainVersion string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			goVersion = fields[1]
+		case "toolchain":
+			toolchainVersion = strings.TrimPrefix(fields[1], "go")
+		}
+	}
+	if goVersion == "" {
+		return "", "", fmt.Errorf("%s has no go directive", path)
+	}
+	return goVersion, toolchainVersion, nil
+}
+
+// ModRequires returns the module versions required by go.mod content,
+// keyed by module path. Both "require path version" lines and require
+// blocks are recognized; comments such as "// indirect" are ignored.
+func ModRequires(data []byte) map[string]string {
+	requires := make(map[string]string)
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		switch {
+		case inBlock && len(fields) == 1 && fields[0] == ")":
+			inBlock = false
+		case inBlock && len(fields) == 2:
+			requires[fields[0]] = fields[1]
+		case len(fields) == 2 && fields[0] == "require" && fields[1] == "(":
+			inBlock = true
+		case len(fields) == 3 && fields[0] == "require":
+			requires[fields[1]] = fields[2]
+		}
+	}
+	return requires
+}
+
+// Verify checks the toolchain of goBinary against the go_version constraint
+// and the go directive of the go.mod in dir (the working directory when
+// empty). With strict set it also warns when the toolchain is newer than the
+// one go.mod declares. Without a constraint or a go.mod there is nothing to
+// check and go is not run.
+func Verify(ctx context.Context, goBinary, dir, constraint string, strict bool) error {
+	modGo, modToolchain, err := ModVersions(filepath.Join(dir, "go.mod"))
+	hasMod := !errors.Is(err, os.ErrNotExist)
+	if err != nil && hasMod {
+		return fmt.Errorf("read go.mod: %w", err)
+	}
+	if constraint == "" && !hasMod {
+		return nil
+	}
+
+	current, err := Version(ctx, goBinary, dir)
+	if err != nil {
+		return err
+	}
+
+	if constraint != "" {
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			return err
+		}
+		if !c.Check(current) {
+			return fmt.Errorf("go version %s does not satisfy go_version %q", current, constraint)
+		}
+	}
+
+	if !hasMod {
+		return nil
+	}
+	if Compare(current, modGo) < 0 {
+		return fmt.Errorf("go version %s is older than go %s required by go.mod", current, modGo)
+	}
+
+	declared := modGo
+	if modToolchain != "" {
+		declared = modToolchain
+	}
+	if strict && Compare(current, declared) > 0 {
+		log.Printf("Warning: go version %s is newer than go %s declared in go.mod", current, declared)
+	}
+	return nil
+}
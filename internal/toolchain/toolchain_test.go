@@ -0,0 +1,89 @@
+package toolchain
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{">=1.22", "1.22.0", true},
+		{">=1.22", "1.22rc1", false},
+		{">=1.22", "1.21.13", false},
+		{">= 1.22, <1.24", "1.23.4", true},
+		{">=1.22 <1.24", "1.24.0", false},
+		{"1.22", "1.22.0", true},
+		{"=1.22.3", "1.22.4", false},
+		{"!=1.22.3", "1.22.4", true},
+		{">go1.21.5", "1.21.6", true},
+		{"<=1.22", "1.22.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint() error = %v", err)
+			}
+			if got := c.Check(tt.version); got != tt.want {
+				t.Errorf("Check(%s) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", ">=", ">=latest", "~1.22"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) expected error", s)
+		}
+	}
+}
+
+func TestParseVersionOutput(t *testing.T) {
+	got, err := parseVersionOutput("go version go1.22.3 linux/amd64\n")
+	if err != nil || got != "1.22.3" {
+		t.Errorf("parseVersionOutput() = %q, %v", got, err)
+	}
+	if _, err := parseVersionOutput("go version devel go1.24-abc123 linux/amd64"); err == nil {
+		t.Error("expected error for devel toolchain")
+	}
+	got, err = parseVersionOutput("tinygo version 0.31.2 linux/amd64 (using go version go1.22.1 and LLVM version 17.0.1)\n")
+	if err != nil || got != "1.22.1" {
+		t.Errorf("parseVersionOutput() of tinygo = %q, %v", got, err)
+	}
+}
+
+func TestModRequires(t *testing.T) {
+	data := "module example.com/app\n\ngo 1.22\n\nrequire go.example.com/single v0.1.0\n\n" +
+		"require (\n\tgo.example.com/dep v1.0.0\n\tgo.example.com/ind v2.1.0+incompatible // indirect\n)\n"
+	got := ModRequires([]byte(data))
+	want := map[string]string{
+		"go.example.com/single": "v0.1.0",
+		"go.example.com/dep":    "v1.0.0",
+		"go.example.com/ind":    "v2.1.0+incompatible",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("ModRequires() = %v, want %v", got, want)
+	}
+}
+
+func TestModVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go.mod")
+	data := "module example.com/app\n\ngo 1.22\n\ntoolchain go1.22.5\n\nrequire (\n\tgo.example.com/dep v1.0.0\n)\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	goVersion, toolchainVersion, err := ModVersions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goVersion != "1.22" || toolchainVersion != "1.22.5" {
+		t.Errorf("ModVersions() = %q, %q", goVersion, toolchainVersion)
+	}
+}